@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// airgapActions are the actions of "basar airgap".
+var airgapActions = []string{"keygen", "pack", "verify", "unpack"}
+
+// runAirgap implements "basar airgap keygen|pack|verify|unpack": moving the
+// cache and the mirrored symbol files across a data diode or sneakernet
+// as one signed archive. keygen writes an ed25519 key pair; pack signs the
+// archive with the private key, and verify and unpack check it with the
+// public one before anything is installed.
+func runAirgap(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	var action string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := newFlagSet("airgap")
+	overrideFlags(fs, &o)
+
+	var key, pubkey, output, mirror string
+	fs.StringVar(&key, "key", "", "")
+	fs.StringVar(&pubkey, "pubkey", "", "")
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+	fs.StringVar(&mirror, "mirror", "", "")
+
+	flags := &Flags{}
+	fs.BoolVar(&flags.Verbose, "v", false, "")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	switch action {
+	case "keygen":
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "basar: airgap keygen takes no arguments, got %q\n", fs.Arg(0))
+			return exitError
+		}
+		return airgapKeygen(output, stdout, stderr)
+	case "pack":
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "basar: airgap pack takes no arguments, got %q\n", fs.Arg(0))
+			return exitError
+		}
+	case "verify", "unpack":
+		if fs.NArg() != 1 {
+			fmt.Fprintf(stderr, "basar: airgap %s takes one archive (or - for stdin)\n", action)
+			return exitError
+		}
+	default:
+		fmt.Fprintf(stderr, "basar: airgap expects %s\n", strings.Join(airgapActions, ", "))
+		return exitError
+	}
+
+	cfg := config.NewWith(o)
+	c := cache.New(cfg)
+	logger, closeLog, err := newLogger(flags, cfg, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	defer closeLog()
+	c.SetLogger(logger)
+	for _, w := range cfg.Warnings {
+		logger.Warn(w)
+	}
+
+	if action == "pack" {
+		return airgapPack(c, key, output, mirror, stdout, stderr)
+	}
+
+	if pubkey == "" {
+		fmt.Fprintf(stderr, "basar: airgap %s needs --pubkey FILE, the public key of the packing side\n", action)
+		return exitError
+	}
+	pub, err := readPublicKey(pubkey)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	archive := fs.Arg(0)
+	var r io.Reader = os.Stdin
+	if archive != "-" {
+		f, err := os.Open(archive)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var manifest *cache.AirgapManifest
+	if action == "verify" {
+		manifest, err = c.VerifyAirgap(r, pub)
+	} else {
+		manifest, err = c.UnpackAirgap(r, pub, mirror)
+	}
+	if err != nil {
+		if errors.Is(err, cache.ErrInvalidBundle) {
+			fmt.Fprintf(stderr, "basar: %s: %v; the cache is unchanged\n", archive, err)
+		} else {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+		}
+		return exitError
+	}
+
+	created := manifest.Created.Format("2006-01-02 15:04 MST")
+	if action == "verify" {
+		fmt.Fprintf(stdout, "%s: signed by key %s, generation %d, packed %s, %d symbol files; OK\n",
+			archive, manifest.Key, manifest.Generation, created, manifest.MirrorFiles())
+		return exitOK
+	}
+	path, _ := c.Path()
+	fmt.Fprintf(stdout, "unpacked %s (key %s, generation %d, packed %s) into %s with %d symbol files\n",
+		archive, manifest.Key, manifest.Generation, created, path, manifest.MirrorFiles())
+	return exitOK
+}
+
+// airgapPack writes the signed archive to output, or stdout when it is
+// empty or "-".
+func airgapPack(c *cache.Cache, keyFile, output, mirror string, stdout, stderr io.Writer) int {
+	if keyFile == "" {
+		fmt.Fprintln(stderr, "basar: airgap pack needs --key FILE; create one with basar airgap keygen")
+		return exitError
+	}
+	key, err := readPrivateKey(keyFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	// Pack fully before touching the output so a failure leaves it intact
+	var buf bytes.Buffer
+	manifest, err := c.PackAirgap(&buf, key, mirror)
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if output == "" || output == "-" {
+		if _, err := buf.WriteTo(stdout); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "packed generation %d and %d symbol files into %s, signed by key %s\n",
+		manifest.Generation, manifest.MirrorFiles(), output, manifest.Key)
+	return exitOK
+}
+
+// airgapKeygen writes a new ed25519 key pair as PEM: the private key to
+// prefix, readable by its owner only, and the public key to prefix.pub.
+// Existing files are not overwritten.
+func airgapKeygen(prefix string, stdout, stderr io.Writer) int {
+	if prefix == "" {
+		prefix = "basar-airgap"
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: generating key: %v\n", err)
+		return exitError
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	for _, f := range []struct {
+		path  string
+		block *pem.Block
+		mode  os.FileMode
+	}{
+		{prefix, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER}, 0600},
+		{prefix + ".pub", &pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}, 0644},
+	} {
+		out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.mode)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		err = pem.Encode(out, f.block)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: writing %s: %v\n", f.path, err)
+			return exitError
+		}
+	}
+	fmt.Fprintf(stdout, "wrote key %s: %s (keep it on the packing side) and %s\n",
+		cache.AirgapKeyID(pub), prefix, prefix+".pub")
+	return exitOK
+}
+
+// readPrivateKey reads an ed25519 private key from a PEM file as
+// airgapKeygen writes it.
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// readPublicKey reads an ed25519 public key from a PEM file as
+// airgapKeygen writes it.
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return pub, nil
+}
+
+// readPEM returns the contents of the first PEM block of type typ in the
+// file at path.
+func readPEM(path, typ string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no %s block", path, typ)
+		}
+		if block.Type == typ {
+			return block.Bytes, nil
+		}
+	}
+}
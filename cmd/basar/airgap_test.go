@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunAirgap(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"version":1,"linux":{"Linux version 5.15.0-generic":["https://isf.example.org/5.15.json.xz"]}}`
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mirror := filepath.Join(env.tmpDir, "mirror")
+	if err := os.MkdirAll(filepath.Join(mirror, "isf.example.org"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mirror, "isf.example.org", "5.15.json.xz"), []byte("isf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := filepath.Join(env.tmpDir, "key")
+	archive := filepath.Join(env.tmpDir, "transfer.tar")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"airgap", "keygen", "-o", key}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(airgap keygen) = %d; stderr: %s", code, stderr.String())
+	}
+	if code := run([]string{"airgap", "keygen", "-o", key}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(airgap keygen) over an existing key = %d, expected %d", code, exitError)
+	}
+	if code := run([]string{"airgap", "pack", "-o", archive}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(airgap pack) without --key = %d, expected %d", code, exitError)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"airgap", "pack", "--key", key, "--mirror", mirror, "-o", archive}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(airgap pack) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 symbol files into "+archive) {
+		t.Errorf("pack output = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"airgap", "verify", "--pubkey", key + ".pub", archive}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(airgap verify) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.HasSuffix(stdout.String(), "; OK\n") {
+		t.Errorf("verify output = %q", stdout.String())
+	}
+
+	dest := filepath.Join(env.tmpDir, "air-gapped")
+	stdout.Reset()
+	if code := run([]string{"airgap", "unpack", "--pubkey", key + ".pub", "--cache-dir", dest, archive}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(airgap unpack) = %d; stderr: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(dest, "mirror", "isf.example.org", "5.15.json.xz")); err != nil {
+		t.Errorf("unpacked symbol file missing: %v", err)
+	}
+
+	// The private key does not verify, and neither does another pair
+	other := filepath.Join(env.tmpDir, "other")
+	if code := run([]string{"airgap", "keygen", "-o", other}, &stdout, &stderr); code != exitOK {
+		t.Fatal(stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"airgap", "verify", "--pubkey", other + ".pub", archive}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(airgap verify) with another key = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "not signed by key") {
+		t.Errorf("verify stderr = %q", stderr.String())
+	}
+	if code := run([]string{"airgap", "verify", "--pubkey", key, archive}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(airgap verify) with the private key = %d, expected %d", code, exitError)
+	}
+	if code := run([]string{"airgap"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(airgap) = %d, expected %d", code, exitError)
+	}
+}
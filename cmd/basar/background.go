@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// revalidateUnit names the transient systemd unit of background updates;
+// systemd refuses to start a second one while the first runs.
+const revalidateUnit = "basar-revalidate"
+
+// proxyEnv names the proxy variables fetches honor.
+var proxyEnv = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"}
+
+// startBackgroundUpdate starts `basar --smart-update --low-priority` with
+// the same overrides and the variables named by forward, as forwardedEnv
+// lists them, and returns without waiting for it, describing how it was
+// started. It runs as a transient systemd user unit where a user manager
+// is available, so it is tracked like the timer's runs, and otherwise as a
+// process detached from the terminal. Either way it appends to the log
+// file, as there is no one left to read its output.
+var startBackgroundUpdate = func(o config.Overrides, forward []string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locating basar: %w", err)
+	}
+	args := append(o.Args(), "--smart-update", "--log-file", "--low-priority")
+
+	if runtime.GOOS == "linux" && systemdRunAvailable() {
+		out, err := exec.Command("systemd-run", systemdRunArgs(exe, args, os.Environ(), forward)...).CombinedOutput()
+		if err == nil {
+			return "systemd-run", nil
+		}
+		if strings.Contains(string(out), "already") {
+			return "systemd-run (already running)", nil
+		}
+		// Fall back to a detached process, e.g. without a user session bus
+	}
+
+	cmd := exec.Command(exe, args...)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("starting background update: %w", err)
+	}
+	_ = cmd.Process.Release()
+	return "detached process", nil
+}
+
+// systemdRunAvailable reports whether systemd-run can start units in a
+// user service manager.
+func systemdRunAvailable() bool {
+	if _, err := exec.LookPath("systemd-run"); err != nil {
+		return false
+	}
+	return exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+// forwardedEnv returns the variables, besides the BASAR_ and XDG_ ones, a
+// background update of cfg needs: the proxy settings and the variables
+// its sources' token_env options name.
+func forwardedEnv(cfg *config.Config) []string {
+	names := slices.Clone(proxyEnv)
+	for _, source := range cfg.Sources {
+		if name := cfg.SourceOptions(source).TokenEnv; name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// systemdRunArgs returns the systemd-run arguments running exe with args
+// as a transient user unit. The user manager does not inherit the caller's
+// environment, so the BASAR_ and XDG_ variables set in env, and those named
+// by forward, are passed along by name: systemd-run reads their values from
+// its own environment, keeping secrets off its command line and out of ps.
+func systemdRunArgs(exe string, args, env, forward []string) []string {
+	out := []string{"--user", "--quiet", "--collect", "--unit=" + revalidateUnit}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "BASAR_") || strings.HasPrefix(name, "XDG_") || slices.Contains(forward, name) {
+			out = append(out, "--setenv="+name)
+		}
+	}
+	return append(append(out, "--", exe), args...)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestSystemdRunArgs(t *testing.T) {
+	env := []string{"HOME=/home/u", "BASAR_WEBHOOK_SECRET=s3cret", "XDG_CACHE_HOME=/tmp/c", "PATH=/usr/bin",
+		"HTTPS_PROXY=http://proxy:3128", "ISF_TOKEN=t0ken"}
+	args := systemdRunArgs("/usr/bin/basar", []string{"--profile", "work", "--smart-update"}, env, []string{"HTTPS_PROXY", "ISF_TOKEN"})
+
+	want := []string{
+		"--user", "--quiet", "--collect", "--unit=" + revalidateUnit,
+		"--setenv=BASAR_WEBHOOK_SECRET", "--setenv=XDG_CACHE_HOME", "--setenv=HTTPS_PROXY", "--setenv=ISF_TOKEN",
+		"--", "/usr/bin/basar", "--profile", "work", "--smart-update",
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("systemdRunArgs() = %v, expected %v", args, want)
+	}
+}
+
+func TestForwardedEnv(t *testing.T) {
+	cfg := config.New()
+	cfg.Sources = []string{"https://a.example/b.json", "https://b.example/b.json", "https://c.example/b.json"}
+	cfg.Options = map[string]config.SourceOptions{
+		"https://a.example/b.json": {TokenEnv: "A_TOKEN"},
+		"https://c.example/b.json": {TokenEnv: "A_TOKEN"},
+	}
+	names := forwardedEnv(cfg)
+	if !slices.Contains(names, "HTTPS_PROXY") || !slices.Contains(names, "no_proxy") {
+		t.Errorf("forwardedEnv() = %v, lacks the proxy variables", names)
+	}
+	if i := slices.Index(names, "A_TOKEN"); i < 0 || slices.Contains(names[i+1:], "A_TOKEN") {
+		t.Errorf("forwardedEnv() = %v, expected A_TOKEN once", names)
+	}
+}
+
+func TestRunStaleWhileRevalidate(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var started []config.Overrides
+	orig := startBackgroundUpdate
+	startBackgroundUpdate = func(o config.Overrides, forward []string) (string, error) {
+		started = append(started, o)
+		return "test", nil
+	}
+	defer func() { startBackgroundUpdate = orig }()
+
+	// Without a cache the update runs in the foreground
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--stale-while-revalidate"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run() = %d; stderr: %s", code, stderr.String())
+	}
+	if len(started) != 0 {
+		t.Error("a missing cache should not be updated in the background")
+	}
+
+	// An expired cache is printed as is while the update starts
+	expired := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(env.cacheFile, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if code := run([]string{"--stale-while-revalidate", "-p"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run() = %d; stderr: %s", code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != env.cacheFile {
+		t.Errorf("run(-p) printed %q, expected %s", stdout.String(), env.cacheFile)
+	}
+	if len(started) != 1 {
+		t.Fatalf("background updates started = %d, expected 1", len(started))
+	}
+	if info, _ := os.Stat(env.cacheFile); !info.ModTime().Equal(expired) {
+		t.Error("the cache should not be updated in the foreground")
+	}
+}
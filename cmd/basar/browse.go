@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// browsePage is how many matches browse lists at a time.
+const browsePage = 20
+
+// browseHelp lists the commands of browse.
+const browseHelp = `  TEXT          search banners containing TEXT (case-insensitive)
+  N             show banner N: its URLs, their trust, its sources, metadata
+  n, b          next and previous page of matches
+  m N...        mark or unmark banners for prefetch (m alone lists them)
+  p [N...]      prefetch the banners given, else those marked, else the one shown
+  sources       what each source contributes to the cache
+  ?             this help
+  q             quit
+`
+
+// runBrowse implements "basar browse [--plain] [--symbols-dir DIR]": an
+// interactive browser of the cache, for analysts to see which kernels
+// they have symbols for, where those come from, and to prefetch the ones
+// they need before going offline. On a terminal it takes the full
+// screen; with --plain, or when stdin or stdout is not a terminal, it
+// reads commands from stdin line by line.
+func runBrowse(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("browse")
+	overrideFlags(fs, &o)
+
+	var dir string
+	var plain bool
+	fs.StringVar(&dir, "symbols-dir", "", "")
+	fs.BoolVar(&plain, "plain", false, "")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: browse takes no arguments, got %q\n", fs.Args())
+		return exitError
+	}
+
+	// Banners are browsed where basar would point volatility3
+	c := cache.New(config.NewWith(o))
+	served := c
+	if sys := c.SystemCache(); sys != nil {
+		served = sys
+	}
+	served, err := c.Layered(served)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	stats := served.Stats()
+	if !stats.Valid {
+		fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+		return exitInvalid
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	b := &browser{cache: served, dir: dir, out: stdout, marked: make(map[string]bool)}
+	if !plain {
+		if term, ok := openTerminal(stdout); ok {
+			err := (&screen{b: b, stats: stats, term: term, out: stdout}).run(ctx)
+			term.restore()
+			if err != nil {
+				fmt.Fprintf(stderr, "basar: reading keys: %v\n", err)
+				return exitError
+			}
+			return exitOK
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d banners from %d sources, updated %s. Type text to search, ? for help.\n",
+		stats.Entries, len(stats.Sources), stats.UpdatedAt.Format(time.DateTime))
+
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "browse> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+		if !b.exec(ctx, scanner.Text()) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "basar: reading commands: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// browser is the state of a browse session: the last search, the page of
+// it listed, and the banners shown and marked.
+type browser struct {
+	cache *cache.Cache
+	dir   string
+	out   io.Writer
+
+	matches []cache.Match
+	page    int
+	shown   *cache.Match
+	marked  map[string]bool
+}
+
+// exec runs one command line, returning false when it quits.
+func (b *browser) exec(ctx context.Context, line string) bool {
+	line = strings.TrimSpace(line)
+	cmd, rest, _ := strings.Cut(line, " ")
+	switch cmd {
+	case "":
+	case "q", "quit", "exit":
+		return false
+	case "?", "help":
+		fmt.Fprint(b.out, browseHelp)
+	case "n":
+		b.turn(1)
+	case "b":
+		b.turn(-1)
+	case "m":
+		b.mark(strings.Fields(rest))
+	case "p":
+		b.prefetch(ctx, strings.Fields(rest))
+	case "sources":
+		b.sources()
+	default:
+		if n, err := strconv.Atoi(line); err == nil {
+			b.show(n)
+			return true
+		}
+		b.search(line)
+	}
+	return true
+}
+
+// search lists the banners containing query.
+func (b *browser) search(query string) {
+	matches, err := b.cache.LookupWith(query, cache.LookupOptions{IgnoreCase: true})
+	if err != nil {
+		fmt.Fprintf(b.out, "error: %v\n", err)
+		return
+	}
+	b.matches, b.page = matches, 0
+	if len(matches) == 0 {
+		fmt.Fprintf(b.out, "no banner matches %q\n", query)
+		return
+	}
+	b.list()
+}
+
+// turn moves by delta pages through the matches.
+func (b *browser) turn(delta int) {
+	page := b.page + delta
+	if page < 0 || page*browsePage >= len(b.matches) {
+		fmt.Fprintln(b.out, "no more matches")
+		return
+	}
+	b.page = page
+	b.list()
+}
+
+// list prints the current page of matches, numbered, with a * for those
+// marked.
+func (b *browser) list() {
+	start := b.page * browsePage
+	end := min(start+browsePage, len(b.matches))
+	for i := start; i < end; i++ {
+		m := b.matches[i]
+		mark := " "
+		if b.marked[m.Banner] {
+			mark = "*"
+		}
+		fmt.Fprintf(b.out, "%s%4d  %s  (%d URLs)\n", mark, i+1, m.Banner, len(m.URLs))
+	}
+	fmt.Fprintf(b.out, "%d-%d of %d", start+1, end, len(b.matches))
+	if end < len(b.matches) {
+		fmt.Fprint(b.out, "; n for more")
+	}
+	fmt.Fprintln(b.out)
+}
+
+// match returns match n of the last search, counting from 1.
+func (b *browser) match(n int) (*cache.Match, bool) {
+	if n < 1 || n > len(b.matches) {
+		fmt.Fprintf(b.out, "no banner %d; search first, then pick one of 1-%d\n", n, len(b.matches))
+		return nil, false
+	}
+	return &b.matches[n-1], true
+}
+
+// show prints banner n with its URLs, their trust levels, the sources
+// listing it, and its metadata.
+func (b *browser) show(n int) {
+	m, ok := b.match(n)
+	if !ok {
+		return
+	}
+	b.shown = m
+	for _, line := range details(*m) {
+		fmt.Fprintln(b.out, line)
+	}
+}
+
+// details describes m in lines: its banner, whether it was removed
+// upstream, its URLs with their trust levels, its sources, and its
+// metadata.
+func details(m cache.Match) []string {
+	lines := []string{m.Banner}
+	if m.Tombstone != nil {
+		lines = append(lines, fmt.Sprintf("  removed upstream %s, kept until %s",
+			m.Tombstone.Removed.Format(time.DateOnly), m.Tombstone.Expires.Format(time.DateOnly)))
+	}
+	lines = append(lines, "  urls:")
+	for _, u := range m.URLs {
+		lines = append(lines, fmt.Sprintf("    %s (%s)", u, urlTrust(m, u)))
+	}
+	if len(m.Sources) > 0 {
+		lines = append(lines, "  sources:")
+		for _, src := range m.Sources {
+			lines = append(lines, "    "+src)
+		}
+	}
+	if len(m.Metadata) > 0 {
+		lines = append(lines, "  metadata:")
+		names := make([]string, 0, len(m.Metadata))
+		for name := range m.Metadata {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("    %s: %s", name, metadataValue(m.Metadata[name])))
+		}
+	}
+	return lines
+}
+
+// mark toggles the marks of the banners numbered in args, or lists the
+// marked banners without any.
+func (b *browser) mark(args []string) {
+	if len(args) == 0 {
+		if len(b.marked) == 0 {
+			fmt.Fprintln(b.out, "nothing marked")
+			return
+		}
+		for _, banner := range sortedKeys(b.marked) {
+			fmt.Fprintf(b.out, "* %s\n", banner)
+		}
+		return
+	}
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(b.out, "not a banner number: %q\n", arg)
+			continue
+		}
+		m, ok := b.match(n)
+		if !ok {
+			continue
+		}
+		if b.marked[m.Banner] {
+			delete(b.marked, m.Banner)
+		} else {
+			b.marked[m.Banner] = true
+		}
+	}
+	fmt.Fprintf(b.out, "%d marked\n", len(b.marked))
+}
+
+// prefetch downloads the symbol files of the banners numbered in args,
+// else of those marked, else of the one shown, into the volatility3
+// symbols directory. Marks are cleared once prefetched.
+func (b *browser) prefetch(ctx context.Context, args []string) {
+	var banners []string
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(b.out, "not a banner number: %q\n", arg)
+			return
+		}
+		m, ok := b.match(n)
+		if !ok {
+			return
+		}
+		banners = append(banners, m.Banner)
+	}
+	fromMarks := len(banners) == 0 && len(b.marked) > 0
+	switch {
+	case fromMarks:
+		banners = sortedKeys(b.marked)
+	case len(banners) == 0 && b.shown != nil:
+		banners = []string{b.shown.Banner}
+	case len(banners) == 0:
+		fmt.Fprintln(b.out, "nothing to prefetch; mark or show a banner first")
+		return
+	}
+
+	res, err := b.cache.Prefetch(ctx, b.dir, banners)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(b.out, "prefetch canceled")
+			return
+		}
+		fmt.Fprintf(b.out, "error: %v\n", err)
+		return
+	}
+	for _, f := range res.Failed {
+		fmt.Fprintf(b.out, "failed: %s: %s\n", f.Banner, f.Error)
+	}
+	for _, f := range res.Files {
+		fmt.Fprintf(b.out, "  %s\n", f.Path)
+	}
+	fmt.Fprintln(b.out, prefetchSummary(res))
+	if fromMarks {
+		b.marked = make(map[string]bool)
+	}
+}
+
+// prefetchSummary sums res up in a line: the symbol files in the
+// directory and what was downloaded.
+func prefetchSummary(res *cache.PrefetchResult) string {
+	return fmt.Sprintf("%d symbol files in %s (%d downloaded, %d bytes)",
+		len(res.Files), res.Dir, res.Downloaded, res.Bytes)
+}
+
+// sources prints each configured source with the status of its last
+// fetch and how many banners it provides.
+func (b *browser) sources() {
+	for _, line := range sourceLines(b.cache.Stats()) {
+		fmt.Fprintln(b.out, line)
+	}
+}
+
+// sourceLines describes each source in stats in two lines: its URL, then
+// the status of its last fetch and how many banners it provides.
+func sourceLines(stats cache.Stats) []string {
+	var lines []string
+	for _, s := range stats.Sources {
+		status := s.Status
+		if status == "" {
+			status = "never fetched"
+		}
+		lines = append(lines, "  "+s.Source,
+			fmt.Sprintf("    %s, %d entries, %d banners in the cache", status, s.Entries, stats.Provenance[s.Source]))
+	}
+	return lines
+}
+
+// sortedKeys returns the keys of set, sorted.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
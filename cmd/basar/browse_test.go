@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestRunBrowse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	writeBrowseCache(t, env, server.URL)
+
+	old := stdin
+	defer func() { stdin = old }()
+	stdin = strings.NewReader("5.15.0\n2\nm 1 2\nm\n6.1\np\n1\np\n9\nn\n?\nq\nnot read\n")
+
+	dir := filepath.Join(env.tmpDir, "symbols")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"browse", "--symbols-dir", dir}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(browse) = %d; stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"3 banners from",
+		"1-2 of 2",
+		"Linux version 5.15.0-generic (buildd) #1 SMP\n  urls:\n    " + server.URL + "/5.15.json.xz (community)",
+		"2 marked",
+		"* Linux version 5.15.0-aws",
+		"2 symbol files in " + dir,
+		filepath.Join(dir, "linux", "6.1.json.xz"),
+		"1 symbol files in " + dir,
+		"no banner 9",
+		"no more matches",
+		"mark or unmark",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("browse output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "not read") {
+		t.Error("browse read past q")
+	}
+}
+
+// writeBrowseCache builds a cache of three banners whose symbol files are
+// at url, from the source file of the test env.
+func writeBrowseCache(t *testing.T, env *testEnv, url string) {
+	t.Helper()
+	index := `{"version":1,"linux":{"Linux version 5.15.0-generic (buildd) #1 SMP":["` + url + `/5.15.json.xz"],` +
+		`"Linux version 5.15.0-aws (buildd) #1 SMP":["` + url + `/5.15-aws.json.xz"],` +
+		`"Linux version 6.1.0-generic (debian) #1 SMP":["` + url + `/6.1.json.xz"]}}`
+	if err := os.WriteFile(env.sourceFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(env.configFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env.configFile, []byte(env.sourceFile+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+}
+
+func TestRunBrowseNoCache(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"browse"}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(browse) without a cache = %d, expected %d", code, exitInvalid)
+	}
+}
+
+func TestRunBrowseScreen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	writeBrowseCache(t, env, server.URL)
+
+	// Search 5.15, mark the second match, select the first, prefetch the
+	// marked one, show the sources, clear the search, and quit, each read
+	// on its own as typed
+	var keys []io.Reader
+	for _, typed := range []string{"5.15", "\x1b[B", "\t", "\x1b[A", "\r", "\x13", "\x1b", "\x03not read"} {
+		keys = append(keys, strings.NewReader(typed))
+	}
+	restored := 0
+	old := openTerminal
+	defer func() { openTerminal = old }()
+	openTerminal = func(io.Writer) (*terminal, bool) {
+		return &terminal{
+			in:      io.MultiReader(keys...),
+			size:    func() (int, int) { return 200, 16 },
+			restore: func() { restored++ },
+		}, true
+	}
+
+	dir := filepath.Join(env.tmpDir, "symbols")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"browse", "--symbols-dir", dir}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(browse) = %d; stderr: %s", code, stderr.String())
+	}
+	if restored != 1 {
+		t.Errorf("terminal restored %d times, expected once", restored)
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, ansiAltScreen) || !strings.HasSuffix(out, ansiMainScreen) {
+		t.Errorf("browse did not switch to the alternate screen and back:\n%q", out)
+	}
+	for _, want := range []string{
+		"Search: 5.15" + ansiReverse,
+		ansiReverse + "  Linux version 5.15.0-aws (buildd) #1 SMP",
+		"* Linux version 5.15.0-generic",
+		"    " + server.URL + "/5.15-aws.json.xz (community)",
+		"1 symbol files in " + dir + " (1 downloaded, 3 bytes)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("browse screens lack %q:\n%q", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "linux", "5.15.json.xz")); err != nil {
+		t.Errorf("marked banner not prefetched: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "linux", "5.15-aws.json.xz")); !os.IsNotExist(err) {
+		t.Errorf("selected banner prefetched along the marked one: %v", err)
+	}
+
+	// The last screen, after Esc cleared the search, lists every banner
+	// with the sources in the pane
+	last := out[strings.LastIndex(out, "\x1b[1;1H"):]
+	for _, want := range []string{"Search: " + ansiReverse, " sources", "  " + env.sourceFile, "ok, 3 entries, 3 banners in the cache", " 1 of 3 matches, 0 marked"} {
+		if !strings.Contains(last, want) {
+			t.Errorf("last screen lacks %q:\n%q", want, last)
+		}
+	}
+	if strings.Contains(last, "not read") {
+		t.Error("browse read keys past Ctrl-C")
+	}
+
+	// --plain keeps to the prompt on a terminal
+	openTerminal = func(io.Writer) (*terminal, bool) {
+		t.Error("browse --plain opened the terminal")
+		return nil, false
+	}
+	oldStdin := stdin
+	defer func() { stdin = oldStdin }()
+	stdin = strings.NewReader("q\n")
+	stdout.Reset()
+	if code := run([]string{"browse", "--plain"}, &stdout, &stderr); code != exitOK || !strings.Contains(stdout.String(), "browse> ") {
+		t.Errorf("run(browse --plain) = %d, output: %s", code, stdout.String())
+	}
+}
+
+func TestScreenRender(t *testing.T) {
+	b := &browser{marked: map[string]bool{"Linux version 5.15.0-7-generic #7": true}}
+	for i := 0; i < 10; i++ {
+		b.matches = append(b.matches, cache.Match{
+			Banner: fmt.Sprintf("Linux version 5.15.0-%d-generic #%d", i, i),
+			URLs:   []string{"https://example.com/a.json.xz", "https://example.com/b.json.xz"},
+		})
+	}
+	s := &screen{b: b, cursor: 8}
+
+	// 10 rows leave 3 for the list and 2 for the pane, which scroll to
+	// the selected banner and cut what does not fit
+	lines := s.render(30, 10)
+	if len(lines) != 10 {
+		t.Fatalf("render(30, 10) = %d lines, expected 10", len(lines))
+	}
+	for i, want := range []string{
+		2: "  Linux version 5.15.0-6-ge...",
+		3: "* Linux version 5.15.0-7-ge...",
+		4: ansiReverse + "  Linux version 5.15.0-8-ge..." + ansiReset,
+		6: "Linux version 5.15.0-8-gene...",
+		7: "  ... 3 more lines",
+		8: " 9 of 10 matches, 1 marked",
+	} {
+		if want != "" && lines[i] != want {
+			t.Errorf("line %d = %q, expected %q", i, lines[i], want)
+		}
+	}
+
+	if lines := s.render(30, 6); lines[0] != "terminal too small; Esc quits" || len(lines) != 6 {
+		t.Errorf("render(30, 6) = %q, expected the terminal too small", lines)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("a\x1b[A\x1bOB\x1b[1;5C\x1b[5~\x1b[6~\x1b[H\x1b[4~\x7f\t\r\x13\x1b[99zé\x1b"))
+	want := []key{
+		{r: 'a'}, {name: "up"}, {name: "down"}, {name: "right"}, {name: "pgup"}, {name: "pgdn"},
+		{name: "home"}, {name: "end"}, {name: "backspace"}, {name: "tab"}, {name: "enter"},
+		{name: "ctrl-s"}, {r: 'é'}, {name: "esc"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseKeys = %v, expected %v", got, want)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+// The ANSI sequences the full-screen browser draws with.
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // switch to the alternate screen, hide the cursor
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l" // show the cursor, back to the main screen
+	ansiReverse    = "\x1b[7m"
+)
+
+// screenHelp is the line of keys at the bottom of the full-screen browser.
+const screenHelp = " Up/Dn/PgUp/PgDn move  Tab mark  Enter prefetch  ^S sources  Esc quit"
+
+// terminal is the terminal the full-screen browser runs on, in raw mode.
+type terminal struct {
+	in      io.Reader
+	size    func() (width, height int)
+	resized <-chan os.Signal
+	restore func()
+}
+
+// openTerminal puts the terminal browse runs on in raw mode, reporting
+// false when stdin or stdout is not a terminal, or it cannot be.
+var openTerminal = func(stdout io.Writer) (*terminal, bool) {
+	in, ok := stdin.(*os.File)
+	if !ok || !stdinIsTerminal() || !isTerminal(stdout) {
+		return nil, false
+	}
+	restore, err := makeRaw(in)
+	if err != nil {
+		return nil, false
+	}
+	out := stdout.(*os.File)
+	t := &terminal{in: in, restore: restore, size: func() (int, int) {
+		if width, height, err := terminalSize(out); err == nil && width > 0 && height > 0 {
+			return width, height
+		}
+		return 80, 24
+	}}
+	if sig := resizeSignal(); sig != nil {
+		resized := make(chan os.Signal, 1)
+		signal.Notify(resized, sig)
+		t.resized = resized
+		t.restore = func() {
+			signal.Stop(resized)
+			restore()
+		}
+	}
+	return t, true
+}
+
+// key is a keypress: a named key such as "up" or "ctrl-s", or, without
+// a name, the rune typed.
+type key struct {
+	name string
+	r    rune
+}
+
+// parseKeys decodes the keypresses in buf, as terminals send them.
+func parseKeys(buf []byte) []key {
+	var keys []key
+	for len(buf) > 0 {
+		n := 1
+		switch c := buf[0]; {
+		case c == 0x1b:
+			var k key
+			k, n = parseEscape(buf)
+			if k.name != "" {
+				keys = append(keys, k)
+			}
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{name: "enter"})
+		case c == '\t':
+			keys = append(keys, key{name: "tab"})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{name: "backspace"})
+		case c < 0x20:
+			keys = append(keys, key{name: "ctrl-" + string(rune('a'+c-1))})
+		default:
+			var r rune
+			r, n = utf8.DecodeRune(buf)
+			keys = append(keys, key{r: r})
+		}
+		buf = buf[n:]
+	}
+	return keys
+}
+
+// escapeKeys names the keys terminals send as ESC [ or ESC O followed by
+// a letter, or by a number and ~.
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "1~": "home", "7~": "home",
+	"F": "end", "4~": "end", "8~": "end",
+	"3~": "delete", "5~": "pgup", "6~": "pgdn",
+}
+
+// parseEscape decodes the key starting with ESC in buf, returning it
+// with the number of bytes it takes. ESC alone is the Esc key; sequences
+// of keys not in escapeKeys have no name.
+func parseEscape(buf []byte) (key, int) {
+	if len(buf) < 2 || (buf[1] != '[' && buf[1] != 'O') {
+		return key{name: "esc"}, 1
+	}
+	for i := 2; i < len(buf); i++ {
+		if buf[i] < 0x40 || buf[i] > 0x7e {
+			continue
+		}
+		// Modifiers, as in ESC [1;5A for Ctrl-Up, do not change the key
+		seq := string(buf[2 : i+1])
+		if params, _, ok := strings.Cut(seq[:len(seq)-1], ";"); ok {
+			seq = string(buf[i])
+			if buf[i] == '~' {
+				seq = params + "~"
+			}
+		}
+		return key{name: escapeKeys[seq]}, i + 1
+	}
+	return key{}, len(buf)
+}
+
+// screen is the full-screen browser: a search line, the banners matching
+// it, one selected, and a pane with the details of that banner or the
+// sources of the cache. Marks and matches are kept in the browser.
+type screen struct {
+	b     *browser
+	stats cache.Stats
+	term  *terminal
+	out   io.Writer
+
+	query   string
+	cursor  int
+	top     int
+	sources bool
+	status  string
+	quit    bool
+}
+
+// run draws the screen and handles keys until Esc or Ctrl-C quits, the
+// input ends, or ctx is done.
+func (s *screen) run(ctx context.Context) error {
+	io.WriteString(s.out, ansiAltScreen)
+	defer io.WriteString(s.out, ansiMainScreen)
+
+	// Keys are read aside so resizes redraw while waiting for one
+	keys, errc := make(chan []key), make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := s.term.in.Read(buf)
+			if n > 0 {
+				select {
+				case keys <- parseKeys(buf[:n]):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	s.search()
+	for !s.quit {
+		s.draw()
+		select {
+		case pressed := <-keys:
+			for _, k := range pressed {
+				if !s.quit {
+					s.handle(ctx, k)
+				}
+			}
+		case <-s.term.resized:
+		case err := <-errc:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// handle acts on the keypress k.
+func (s *screen) handle(ctx context.Context, k key) {
+	switch k.name {
+	case "":
+		s.query += string(k.r)
+		s.search()
+	case "backspace":
+		if s.query != "" {
+			_, n := utf8.DecodeLastRuneInString(s.query)
+			s.query = s.query[:len(s.query)-n]
+			s.search()
+		}
+	case "ctrl-u":
+		s.query = ""
+		s.search()
+	case "up":
+		s.move(-1)
+	case "down":
+		s.move(1)
+	case "pgup":
+		s.move(-s.listHeight())
+	case "pgdn":
+		s.move(s.listHeight())
+	case "home":
+		s.move(-len(s.b.matches))
+	case "end":
+		s.move(len(s.b.matches))
+	case "tab":
+		s.mark()
+	case "enter":
+		s.prefetch(ctx)
+	case "ctrl-s":
+		s.sources = !s.sources
+	case "esc":
+		if s.query == "" {
+			s.quit = true
+			return
+		}
+		s.query = ""
+		s.search()
+	case "ctrl-c", "ctrl-d", "ctrl-q":
+		s.quit = true
+	}
+}
+
+// search lists the banners containing the query, case-insensitively,
+// selecting the first.
+func (s *screen) search() {
+	matches, err := s.b.cache.LookupWith(s.query, cache.LookupOptions{IgnoreCase: true})
+	if err != nil {
+		s.status = "error: " + err.Error()
+		return
+	}
+	s.b.matches, s.cursor, s.top, s.status = matches, 0, 0, ""
+}
+
+// move moves the selection by delta matches, stopping at either end.
+func (s *screen) move(delta int) {
+	s.cursor = max(min(s.cursor+delta, len(s.b.matches)-1), 0)
+}
+
+// selected returns the match selected, or nil without any.
+func (s *screen) selected() *cache.Match {
+	if s.cursor >= len(s.b.matches) {
+		return nil
+	}
+	return &s.b.matches[s.cursor]
+}
+
+// mark toggles the mark of the banner selected and selects the next.
+func (s *screen) mark() {
+	m := s.selected()
+	if m == nil {
+		return
+	}
+	if s.b.marked[m.Banner] {
+		delete(s.b.marked, m.Banner)
+	} else {
+		s.b.marked[m.Banner] = true
+	}
+	s.move(1)
+}
+
+// prefetch downloads the symbol files of the banners marked, else of the
+// one selected, into the volatility3 symbols directory, reporting on the
+// status line. Marks are cleared once prefetched.
+func (s *screen) prefetch(ctx context.Context) {
+	banners := sortedKeys(s.b.marked)
+	fromMarks := len(banners) > 0
+	if !fromMarks {
+		m := s.selected()
+		if m == nil {
+			s.status = "nothing to prefetch; mark or select a banner first"
+			return
+		}
+		banners = []string{m.Banner}
+	}
+	s.status = fmt.Sprintf("prefetching %d banners...", len(banners))
+	s.draw()
+
+	res, err := s.b.cache.Prefetch(ctx, s.b.dir, banners)
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.status = "prefetch canceled"
+	case err != nil:
+		s.status = "error: " + err.Error()
+	default:
+		s.status = prefetchSummary(res)
+		if len(res.Failed) > 0 {
+			s.status += fmt.Sprintf("; %d failed, %s: %s", len(res.Failed), res.Failed[0].Banner, res.Failed[0].Error)
+		}
+		if fromMarks {
+			s.b.marked = make(map[string]bool)
+		}
+	}
+}
+
+// layout splits the rows of a terminal height rows high between the
+// list and the pane, around the title, search, pane title, status, and
+// help lines.
+func layout(height int) (list, pane int) {
+	rows := height - 5
+	pane = rows * 2 / 5
+	return rows - pane, pane
+}
+
+// listHeight is how many matches the list shows at the current size.
+func (s *screen) listHeight() int {
+	_, height := s.term.size()
+	list, _ := layout(height)
+	return max(list, 1)
+}
+
+// draw writes the screen to the terminal, each line cleared before it is
+// written so no column is left over from the previous one.
+func (s *screen) draw() {
+	var buf strings.Builder
+	for i, line := range s.render(s.term.size()) {
+		fmt.Fprintf(&buf, "\x1b[%d;1H\x1b[K%s", i+1, line)
+	}
+	io.WriteString(s.out, buf.String())
+}
+
+// render lays the screen out in height lines of width columns, scrolling
+// the list to the match selected.
+func (s *screen) render(width, height int) []string {
+	lines := make([]string, 0, height)
+	list, pane := layout(height)
+	if width < 20 || pane < 1 {
+		lines = append(lines, fit("terminal too small; Esc quits", width))
+		for len(lines) < height {
+			lines = append(lines, "")
+		}
+		return lines
+	}
+
+	lines = append(lines,
+		bar(fmt.Sprintf(" basar browse  %d banners from %d sources, updated %s",
+			s.stats.Entries, len(s.stats.Sources), s.stats.UpdatedAt.Format(time.DateTime)), width),
+		fit(" Search: "+s.query, width-1)+ansiReverse+" "+ansiReset)
+
+	matches := s.b.matches
+	s.top = max(min(s.top, s.cursor), s.cursor-list+1, 0)
+	for i := s.top; i < s.top+list; i++ {
+		switch {
+		case i < len(matches):
+			mark := "  "
+			if s.b.marked[matches[i].Banner] {
+				mark = "* "
+			}
+			line := fit(mark+matches[i].Banner, width)
+			if i == s.cursor {
+				line = bar(line, width)
+			}
+			lines = append(lines, line)
+		case i == 0:
+			lines = append(lines, fit(fmt.Sprintf("  no banner matches %q", s.query), width))
+		default:
+			lines = append(lines, "")
+		}
+	}
+
+	title, content := " details", []string(nil)
+	if m := s.selected(); m != nil {
+		content = details(*m)
+	}
+	if s.sources {
+		title, content = " sources", sourceLines(s.stats)
+	}
+	lines = append(lines, bar(title, width))
+	if len(content) > pane {
+		more := len(content) - pane + 1
+		content = append(content[:pane-1:pane-1], fmt.Sprintf("  ... %d more lines", more))
+	}
+	for i := 0; i < pane; i++ {
+		line := ""
+		if i < len(content) {
+			line = fit(content[i], width)
+		}
+		lines = append(lines, line)
+	}
+
+	status := s.status
+	if status == "" {
+		status = fmt.Sprintf(" %d matches, %d marked", len(matches), len(s.b.marked))
+		if len(matches) > 0 {
+			status = fmt.Sprintf(" %d of %d matches, %d marked", s.cursor+1, len(matches), len(s.b.marked))
+		}
+	}
+	return append(lines, fit(status, width), bar(screenHelp, width))
+}
+
+// fit cuts text to width columns, ending it with ... when cut, and
+// replaces control characters so they cannot move the cursor.
+func fit(text string, width int) string {
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '?'
+		}
+		return r
+	}, text)
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	if width <= 3 {
+		return string(runes[:max(width, 0)])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// bar renders text in reverse video across width columns.
+func bar(text string, width int) string {
+	text = fit(text, width)
+	return ansiReverse + text + strings.Repeat(" ", width-utf8.RuneCountInString(text)) + ansiReset
+}
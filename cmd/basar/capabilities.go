@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+	"github.com/calilkhalil/basar/internal/hooks"
+	"github.com/calilkhalil/basar/internal/logging"
+)
+
+// Capabilities describes the features of this build on this platform, for
+// wrapper tooling.
+type Capabilities struct {
+	Platform          string   `json:"platform"`
+	SourceSchemes     []string `json:"source_schemes"`
+	StorageBackends   []string `json:"storage_backends"`
+	ServiceInstallers []string `json:"service_installers"`
+	ClearTargets      []string `json:"clear_targets"`
+	ExportFormats     []string `json:"export_formats"`
+	ReportFormats     []string `json:"report_formats"`
+	Presets           []string `json:"presets"`
+	LogFormats        []string `json:"log_formats"`
+	Hooks             []string `json:"hooks"`
+}
+
+// currentCapabilities reports the capabilities of the running binary.
+func currentCapabilities() Capabilities {
+	installers := cache.ServiceInstallers()
+	if installers == nil {
+		installers = []string{}
+	}
+
+	return Capabilities{
+		Platform:          runtime.GOOS + "/" + runtime.GOARCH,
+		SourceSchemes:     fetcher.Schemes,
+		StorageBackends:   cache.StorageBackends,
+		ServiceInstallers: installers,
+		ClearTargets:      cache.ClearTargets,
+		ExportFormats:     cache.ExportFormats,
+		ReportFormats:     cache.ReportFormats,
+		Presets:           config.Presets,
+		LogFormats:        []string{logging.FormatText, logging.FormatJSON},
+		Hooks:             []string{hooks.PreUpdate, hooks.PostUpdate},
+	}
+}
+
+// runCapabilities implements "basar capabilities [--json]".
+func runCapabilities(args []string, _ config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("capabilities")
+
+	var asJSON bool
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	caps := currentCapabilities()
+
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(caps); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding capabilities: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(stdout, "platform:           %s\n", caps.Platform)
+	fmt.Fprintf(stdout, "source schemes:     %s\n", strings.Join(caps.SourceSchemes, ", "))
+	fmt.Fprintf(stdout, "storage backends:   %s\n", strings.Join(caps.StorageBackends, ", "))
+	fmt.Fprintf(stdout, "service installers: %s\n", orNone(caps.ServiceInstallers))
+	fmt.Fprintf(stdout, "clear targets:      %s\n", strings.Join(caps.ClearTargets, ", "))
+	fmt.Fprintf(stdout, "export formats:     %s\n", strings.Join(caps.ExportFormats, ", "))
+	fmt.Fprintf(stdout, "report formats:     %s\n", strings.Join(caps.ReportFormats, ", "))
+	fmt.Fprintf(stdout, "presets:            %s\n", strings.Join(caps.Presets, ", "))
+	fmt.Fprintf(stdout, "log formats:        %s\n", strings.Join(caps.LogFormats, ", "))
+	fmt.Fprintf(stdout, "hooks:              %s\n", strings.Join(caps.Hooks, ", "))
+
+	return exitOK
+}
+
+// orNone joins items, or returns "none" for an empty list.
+func orNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunCapabilitiesJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"capabilities", "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(capabilities --json) = %d; stderr: %s", code, stderr.String())
+	}
+
+	var caps Capabilities
+	if err := json.Unmarshal(stdout.Bytes(), &caps); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if !slices.Contains(caps.SourceSchemes, "https") {
+		t.Errorf("source_schemes should include https, got %v", caps.SourceSchemes)
+	}
+	if caps.ServiceInstallers == nil {
+		t.Error("service_installers should be an empty list rather than null")
+	}
+	if caps.Platform == "" || len(caps.ClearTargets) == 0 || len(caps.ExportFormats) == 0 || len(caps.Presets) == 0 {
+		t.Errorf("incomplete capabilities: %+v", caps)
+	}
+}
+
+func TestRunCapabilitiesText(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"capabilities"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(capabilities) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "source schemes:") {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
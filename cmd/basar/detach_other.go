@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package main
+
+import "os/exec"
+
+// detach does nothing where processes cannot be detached from the
+// terminal; the background update still runs without being waited for.
+func detach(cmd *exec.Cmd) {}
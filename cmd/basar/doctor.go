@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runDoctor implements "basar doctor [--json]": it diagnoses the
+// installation and exits non-zero when any check finds a problem.
+func runDoctor(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("doctor")
+	overrideFlags(fs, &o)
+
+	var asJSON bool
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	cfg := config.NewWith(o)
+	findings := cache.New(cfg).Doctor(ctx)
+	for _, w := range cfg.Warnings {
+		findings = append(findings, cache.Finding{Check: "config", Severity: cache.FindingWarn, Message: w})
+	}
+
+	problems := 0
+	for _, f := range findings {
+		if f.Severity != cache.FindingOK {
+			problems++
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding findings: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%-6s %-8s %s\n", f.Severity, f.Check, f.Message)
+			if f.Fix != "" {
+				fmt.Fprintf(stdout, "%-15s fix: %s\n", "", f.Fix)
+			}
+		}
+		switch problems {
+		case 0:
+			fmt.Fprintln(stdout, "no problems found")
+		case 1:
+			fmt.Fprintln(stdout, "1 problem found")
+		default:
+			fmt.Fprintf(stdout, "%d problems found\n", problems)
+		}
+	}
+
+	if problems > 0 {
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestRunDoctor(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	t.Setenv("HOME", env.tmpDir)
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	// No cache yet: doctor reports it and fails
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"doctor", "--json"}, &stdout, &stderr); code != exitError {
+		t.Fatalf("run(doctor --json) = %d, expected %d", code, exitError)
+	}
+
+	var findings []cache.Finding
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	found := false
+	for _, f := range findings {
+		if f.Check == "cache" {
+			found = true
+			if f.Severity != cache.FindingError || f.Fix == "" {
+				t.Errorf("missing cache should be an actionable error, got %+v", f)
+			}
+		}
+		if f.Check == "source" && f.Severity != cache.FindingOK {
+			t.Errorf("local source should be reachable, got %+v", f)
+		}
+	}
+	if !found {
+		t.Errorf("no cache finding in %+v", findings)
+	}
+}
+
+func TestRunDoctorText(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	t.Setenv("HOME", env.tmpDir)
+
+	var stdout, stderr bytes.Buffer
+	run([]string{"doctor"}, &stdout, &stderr)
+
+	out := stdout.String()
+	if !strings.Contains(out, "fix: run `basar --init`") {
+		t.Errorf("expected a fix for the missing config, got:\n%s", out)
+	}
+	if !strings.Contains(out, "problems found") {
+		t.Errorf("expected a summary line, got:\n%s", out)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// mutates reports whether flags ask for an action that changes files,
+// which --dry-run can describe instead.
+func (f *Flags) mutates() bool {
+	return f.Update || f.SmartUpdate || f.RefreshSource != "" || f.Setup || f.Init ||
+		f.ConfigureVol3 || f.InstallService || f.Clear != ""
+}
+
+// planAction describes what the action flags ask for would do, taking the
+// actions in the order run does, and returns the name of the action with
+// its plan.
+func planAction(c *cache.Cache, cfg *config.Config, flags *Flags) (string, *cache.Plan, error) {
+	switch {
+	case flags.Setup:
+		plan, err := c.PlanSetup()
+		return "setup", plan, err
+	case flags.Init:
+		if _, err := os.Stat(cfg.ConfigFile); err == nil {
+			return "init", nil, fmt.Errorf("%w: %s", config.ErrConfigExists, cfg.ConfigFile)
+		}
+		return "init", &cache.Plan{Write: []string{cfg.ConfigFile}}, nil
+	case flags.InstallService:
+		plan, err := c.PlanInstallService()
+		return "install-service", plan, err
+	case flags.ConfigureVol3:
+		plan, err := c.PlanConfigureVolatility3(flags.Replace)
+		return "configure-vol3", plan, err
+	case flags.Clear != "":
+		plan, err := c.PlanClear(flags.Clear)
+		return "clear", plan, err
+	case flags.SmartUpdate:
+		plan, err := c.PlanSmartUpdate()
+		return "smart-update", plan, err
+	case flags.RefreshSource != "":
+		plan, err := c.PlanRefreshSource(flags.RefreshSource)
+		return "refresh-source", plan, err
+	}
+	plan, err := c.PlanUpdate()
+	return "update", plan, err
+}
+
+// planMigrations adds to plan the files from older layouts that would be
+// migrated first: moves write the new path and remove the old one, and
+// stale copies are removed.
+func planMigrations(plan *cache.Plan, pending []config.Migration) {
+	if len(pending) == 0 {
+		return
+	}
+	var write, remove []string
+	for _, m := range pending {
+		if !m.Stale {
+			write = append(write, m.To)
+		}
+		remove = append(remove, m.From)
+	}
+	for _, path := range plan.Write {
+		if !slices.Contains(write, path) {
+			write = append(write, path)
+		}
+	}
+	plan.Write = write
+	plan.Remove = append(remove, plan.Remove...)
+	plan.Notes = append(plan.Notes, "files from older layouts are migrated before the action, which is planned against the current layout")
+}
+
+// printPlan prints a line for each source plan would fetch, file it would
+// write or remove, and command it would run, then its notes.
+func printPlan(w io.Writer, plan *cache.Plan) {
+	for _, change := range []struct {
+		verb  string
+		items []string
+	}{
+		{"fetch", plan.Fetch},
+		{"write", plan.Write},
+		{"remove", plan.Remove},
+		{"run", plan.Run},
+	} {
+		for _, item := range change.items {
+			fmt.Fprintf(w, "would %-6s  %s\n", change.verb, item)
+		}
+	}
+	if plan.Empty() {
+		fmt.Fprintln(w, "nothing would change")
+	}
+	for _, note := range plan.Notes {
+		fmt.Fprintln(w, note)
+	}
+}
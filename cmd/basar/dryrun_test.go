@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunDryRun(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update", "--dry-run"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update --dry-run) = %d; stderr: %s", code, stderr.String())
+	}
+	for _, want := range []string{"would fetch   " + env.sourceFile, "would write   " + env.cacheFile} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("dry run output lacks %q:\n%s", want, stdout.String())
+		}
+	}
+	if _, err := os.Stat(env.cacheFile); !os.IsNotExist(err) {
+		t.Error("--update --dry-run wrote the cache")
+	}
+
+	// --clear needs no confirmation to show what it would remove
+	env.createCache(t)
+	withStdin(t, false, "")
+	stdout.Reset()
+	if code := run([]string{"--clear", "--dry-run", "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--clear --dry-run) = %d; stderr: %s", code, stderr.String())
+	}
+	var out struct {
+		Action string
+		Result struct{ Remove []string }
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("--json output: %v\n%s", err, stdout.String())
+	}
+	if out.Action != "clear" || len(out.Result.Remove) != 1 || out.Result.Remove[0] != env.cacheFile {
+		t.Errorf("--clear --dry-run --json = %+v", out)
+	}
+	if _, err := os.Stat(env.cacheFile); err != nil {
+		t.Error("--clear --dry-run removed the cache")
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--init", "--dry-run"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--init --dry-run) with a config = %d, expected %d", code, exitError)
+	}
+}
+
+func TestRunDryRunLegacyLayout(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+	oldMeta := filepath.Join(env.cacheDir, "basar", "meta.json")
+	if err := os.MkdirAll(filepath.Dir(oldMeta), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldMeta, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update", "--dry-run", "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update --dry-run) = %d; stderr: %s", code, stderr.String())
+	}
+	var out struct {
+		Result struct{ Write, Remove []string }
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("--json output: %v\n%s", err, stdout.String())
+	}
+	newMeta := filepath.Join(env.stateDir, "basar", "meta.json")
+	if !slices.Contains(out.Result.Remove, oldMeta) || !slices.Contains(out.Result.Write, newMeta) {
+		t.Errorf("dry run plan = %+v, expected %s moved to %s", out.Result, oldMeta, newMeta)
+	}
+	if _, err := os.Stat(oldMeta); err != nil {
+		t.Errorf("--dry-run migrated %s: %v", oldMeta, err)
+	}
+	if _, err := os.Stat(newMeta); !os.IsNotExist(err) {
+		t.Errorf("--dry-run wrote %s", newMeta)
+	}
+}
+
+func TestRunDryRunInstallServiceProfile(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("no cron scheduler on " + runtime.GOOS)
+	}
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	t.Setenv("BASAR_SCHEDULER", "cron")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--profile", "work", "--install-service", "--dry-run"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--install-service --dry-run) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), " --profile work --smart-update --low-priority ") {
+		t.Errorf("the installed job does not update the profile:\n%s", stdout.String())
+	}
+}
+
+func TestRunDryRunRequiresAction(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--path", "--dry-run"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--path --dry-run) = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "--dry-run requires") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runExport implements "basar export [--format html|bundle] [-o FILE]": it
+// renders the cache as a static page, or packs it as a bundle for "basar
+// import", to stdout or FILE. A FILE ending in .tar.gz or .tgz selects
+// the bundle format.
+func runExport(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("export")
+	overrideFlags(fs, &o)
+
+	var format, output string
+	fs.StringVar(&format, "format", cache.ExportHTML, "")
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+	fs.StringVar(&output, "out", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: export takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	}
+	if strings.HasSuffix(output, ".zst") {
+		fmt.Fprintln(stderr, "basar: zstd is not supported; bundles are gzip-compressed (use .tar.gz)")
+		return exitError
+	}
+	formatSet := false
+	fs.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if !formatSet && isBundleName(output) {
+		format = cache.ExportBundle
+	}
+
+	// Render fully before touching FILE so a failure leaves it intact
+	var buf bytes.Buffer
+	if err := cache.New(config.NewWith(o)).Export(&buf, format); err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if output == "" {
+		if _, err := buf.WriteTo(stdout); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// isBundleName reports whether a file name is that of a gzipped tar.
+func isBundleName(name string) bool {
+	for _, ext := range []string{".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExport(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createCache(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"export", "--format", "html"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(export) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Linux version 5.15.0-generic") {
+		t.Errorf("export should list the cached banner, got:\n%s", stdout.String())
+	}
+
+	out := filepath.Join(env.tmpDir, "index.html")
+	stdout.Reset()
+	if code := run([]string{"export", "-o", out}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(export -o) = %d; stderr: %s", code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("export -o should not write to stdout, got:\n%s", stdout.String())
+	}
+	if page, err := os.ReadFile(out); err != nil || !strings.Contains(string(page), "<!DOCTYPE html>") {
+		t.Errorf("export -o should write the page: %v", err)
+	}
+}
+
+func TestRunExportErrors(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"export"}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(export) without a cache = %d, expected %d", code, exitInvalid)
+	}
+
+	env.createCache(t)
+	if code := run([]string{"export", "--format", "pdf"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(export --format pdf) = %d, expected %d", code, exitError)
+	}
+}
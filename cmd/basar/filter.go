@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// runFilter implements "basar filter [--include P] [--exclude P]
+// [--min-kernel V] [--max-kernel V] [--dry-run] [-o FILE]": it applies the
+// configured banner and URL filters to the existing cache, in place or
+// into FILE. Banner filters given as flags replace the configured ones.
+func runFilter(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("filter")
+	overrideFlags(fs, &o)
+
+	adHoc := &config.BannerFilter{}
+	for _, kind := range []string{"include", "exclude", "min-kernel", "max-kernel"} {
+		kind := kind
+		fs.Func(kind, "", func(arg string) error { return adHoc.Add(kind, arg) })
+	}
+	var dryRun bool
+	var output string
+	fs.BoolVar(&dryRun, "dry-run", false, "")
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: filter takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	}
+
+	cfg := config.NewWith(o)
+	bf := cfg.BannerFilter
+	if !adHoc.Empty() {
+		bf = adHoc
+	}
+	c := cache.New(cfg)
+
+	var data *fetcher.BannerData
+	var counts *fetcher.FilterCounts
+	var err error
+	if dryRun || output != "" {
+		data, counts, err = c.Filtered(bf, cfg.URLFilter)
+	} else {
+		counts, err = c.ApplyFilters(bf, cfg.URLFilter)
+	}
+	switch {
+	case errors.Is(err, cache.ErrNoCache):
+		fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+		return exitInvalid
+	case errors.Is(err, cache.ErrNoFilters):
+		fmt.Fprintf(stderr, "basar: no filters; pass --include, --exclude, --min-kernel, or --max-kernel, or write %s\n",
+			cfg.BannerFilterFile)
+		return exitError
+	case err != nil:
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if output != "" && !dryRun {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(data); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding cache: %v\n", err)
+			return exitError
+		}
+		if output == "-" {
+			_, err = buf.WriteTo(stdout)
+		} else {
+			err = os.WriteFile(output, buf.Bytes(), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(stderr, "excluded %d banners, dropped %d URLs and %d banners left without one\n",
+		counts.Excluded, counts.URLs, counts.Banners)
+	return exitOK
+}
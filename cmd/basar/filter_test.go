@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestRunFilter(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	env.createCache(t)
+
+	var stdout, stderr bytes.Buffer
+	out := filepath.Join(env.tmpDir, "filtered.json")
+	if code := run([]string{"filter", "--min-kernel", "5.10", "-o", out}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(filter -o) = %d; stderr: %s", code, stderr.String())
+	}
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data fetcher.BannerData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Linux) != 1 || !strings.Contains(stderr.String(), "excluded 0 banners") {
+		t.Errorf("filter --min-kernel 5.10 wrote %s, stderr %q", raw, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"filter", "--max-kernel", "5.10", "-o", "-"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(filter -o -) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"linux":{}`) || !strings.Contains(stderr.String(), "excluded 1 banners") {
+		t.Errorf("filter --max-kernel 5.10 wrote %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"filter"}, &stdout, &stderr); code != exitError || !strings.Contains(stderr.String(), "no filters") {
+		t.Errorf("run(filter) without filters = %d, stderr %q", code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"filter", "--min-kernel", "five"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(filter --min-kernel five) = %d, expected %d", code, exitError)
+	}
+}
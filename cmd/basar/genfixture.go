@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fixture"
+)
+
+// defaultFixtureEntries is the size of a gen-fixture cache without
+// --entries, about that of the largest public sources combined.
+const defaultFixtureEntries = 100000
+
+// runGenFixture implements "basar gen-fixture [--entries N] [--seed N]
+// [-o FILE]": it writes a synthetic cache of N banners, for benchmarks and
+// performance tests, to stdout or FILE.
+func runGenFixture(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("gen-fixture")
+	overrideFlags(fs, &o)
+
+	opts := fixture.Options{}
+	var output string
+	fs.IntVar(&opts.Entries, "entries", defaultFixtureEntries, "")
+	fs.Int64Var(&opts.Seed, "seed", 1, "")
+	fs.IntVar(&opts.Jobs, "jobs", 0, "")
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: gen-fixture takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	}
+	if opts.Entries < 0 || opts.Jobs < 0 {
+		fmt.Fprintln(stderr, "basar: --entries and --jobs must not be negative")
+		return exitError
+	}
+
+	if output == "" {
+		if err := fixture.Generate(stdout, opts); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	err = fixture.Generate(f, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "wrote %d banners to %s\n", opts.Entries, output)
+	return exitOK
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestRunGenFixture(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	out := filepath.Join(env.tmpDir, "big.json")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"gen-fixture", "--entries", "5000", "-o", out}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(gen-fixture) = %d; stderr: %s", code, stderr.String())
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if n, err := fetcher.CountBanners(f); err != nil || n != 5000 {
+		t.Errorf("fixture has %d banners (%v), expected 5000", n, err)
+	}
+
+	// A fixture works as a source
+	if err := os.MkdirAll(filepath.Dir(env.configFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env.configFile, []byte(out+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run(--update) from a fixture = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"gen-fixture", "--entries", "10"}, &stdout, &stderr); code != exitOK || stdout.Len() == 0 {
+		t.Errorf("run(gen-fixture) to stdout = %d with %d bytes", code, stdout.Len())
+	}
+	if code := run([]string{"gen-fixture", "--entries", "-1"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(gen-fixture --entries -1) = %d, expected %d", code, exitError)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// HelpSchema describes the flags and commands of this binary, for
+// wrappers, GUIs, and completion generators.
+type HelpSchema struct {
+	Name     string          `json:"name"`
+	Usage    string          `json:"usage"`
+	Flags    []FlagSchema    `json:"flags"`
+	Commands []CommandSchema `json:"commands"`
+}
+
+// CommandSchema describes a command: its usage line, what it does, and the
+// flags it parses.
+type CommandSchema struct {
+	Name    string       `json:"name"`
+	Usage   string       `json:"usage"`
+	Summary string       `json:"summary"`
+	Flags   []FlagSchema `json:"flags"`
+}
+
+// FlagSchema describes a flag by its long name and the short names setting
+// the same value. Type is bool, optional (a switch taking an optional
+// =VALUE), string, list (a repeatable string), int, or duration.
+type FlagSchema struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+}
+
+// commandHelp holds the usage line and summary of each command; the flags
+// are read from the commands themselves.
+var commandHelp = map[string]struct{ usage, summary string }{
+	"airgap":       {"airgap keygen|pack|verify|unpack [--key F] [--pubkey F] [-o FILE] [ARCHIVE]", "move the cache and mirrored symbol files as one signed archive"},
+	"browse":       {"browse [--plain] [--symbols-dir DIR]", "search the cache interactively and prefetch symbols"},
+	"capabilities": {"capabilities [--json]", "list features available in this build"},
+	"doctor":       {"doctor [--json]", "diagnose the installation (exit 1 on problems)"},
+	"export":       {"export [--format html|bundle] [-o FILE]", "render the cache as a static web page, or pack it for import"},
+	"filter":       {"filter [--include P] [--min-kernel V] [-o FILE]", "apply banner and URL filters to the cache"},
+	"gen-fixture":  {"gen-fixture [--entries N] [-o FILE]", "write a synthetic cache of N banners for benchmarks"},
+	"help":         {"help [--json]", "show help, or describe the commands and flags as JSON"},
+	"import":       {"import BUNDLE", "replace the cache with an exported bundle, after checking it"},
+	"lookup":       {"lookup [-i] [-E|-F] [--release] [--provenance] [--metadata] [--trust] [-q] <banner>", "print symbol URLs for matching banners"},
+	"merge":        {"merge [-o FILE] SOURCE...", "merge banner indexes (- for stdin) without touching the cache"},
+	"mirror":       {"mirror [--dest DIR] [--match TEXT]", "download symbol files and index them as file:// URLs"},
+	"prefetch":     {"prefetch [--symbols-dir DIR] [banner...]", "download the running kernel's symbol files for volatility3"},
+	"prune":        {"prune --check-urls [--sample N] [--dry-run]", "check symbol URLs and remove the dead ones from the cache"},
+	"publish":      {"publish --oci oci://REGISTRY/REPO:TAG", "push the cache to an OCI registry as an artifact"},
+	"report":       {"report [--since 7d] [--format F]", "summarize recent updates (markdown, html)"},
+	"resolve":      {"resolve [--fetch] DUMP", "find kernel banners in a memory image and print their symbol URLs"},
+	"serve":        {"serve [--listen A] [--interval D] [--splay D] [--verify-interval D] [--serve-profile P] [--low-priority]", "keep the cache fresh and checked; serve /metrics, /healthz, /config, /lookup, /hooks/update"},
+	"sync-symbols": {"sync-symbols [--mirror DIR] [--symbols-dir DIR] [--match TEXT]", "place mirrored symbol files in volatility3's symbols directory"},
+	"verify-urls":  {"verify-urls [--json] [--time-format F] [banner]", "check symbol URLs and record their liveness"},
+}
+
+// Registered here, as the schema lists the commands map
+func init() {
+	commands["help"] = runHelp
+}
+
+// runHelp implements "basar help [--json]": the usage text, or the schema
+// of the commands and flags as JSON.
+func runHelp(args []string, _ config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("help")
+
+	var asJSON bool
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: help takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	}
+
+	if !asJSON {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(helpSchema()); err != nil {
+		fmt.Fprintf(stderr, "basar: encoding help: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// helpSchema describes the top-level flags and every command. The flags
+// are those of the flag sets the commands build, caught by running each
+// with a flag nothing defines, which fails before the command does
+// anything.
+func helpSchema() HelpSchema {
+	var last *flag.FlagSet
+	recordFlagSet = func(fs *flag.FlagSet) { last = fs }
+	defer func() { recordFlagSet = nil }()
+
+	_, _ = parseFlags(nil)
+	schema := HelpSchema{
+		Name:     "basar",
+		Usage:    "basar [flags] | basar <command> [args]",
+		Flags:    flagSchemas(last),
+		Commands: []CommandSchema{},
+	}
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		last = nil
+		commands[name]([]string{"--describe-flags-of-" + name}, config.Overrides{}, io.Discard, io.Discard)
+		schema.Commands = append(schema.Commands, CommandSchema{
+			Name:    name,
+			Usage:   commandHelp[name].usage,
+			Summary: commandHelp[name].summary,
+			Flags:   flagSchemas(last),
+		})
+	}
+	return schema
+}
+
+// flagSchemas describes the flags of fs, grouping the names that set the
+// same value.
+func flagSchemas(fs *flag.FlagSet) []FlagSchema {
+	flags := []FlagSchema{}
+	if fs == nil {
+		return flags
+	}
+	byTarget := make(map[uintptr]int)
+	fs.VisitAll(func(f *flag.Flag) {
+		target := flagTarget(f.Value)
+		if i, ok := byTarget[target]; ok && target != 0 {
+			// The longest name is the flag's, shorter ones aliases
+			alias := f.Name
+			if len(alias) > len(flags[i].Name) {
+				alias, flags[i].Name = flags[i].Name, alias
+			}
+			flags[i].Aliases = append(flags[i].Aliases, alias)
+			sort.Strings(flags[i].Aliases)
+			return
+		}
+		byTarget[target] = len(flags)
+		schema := FlagSchema{Name: f.Name, Type: flagType(f.Value), Default: f.DefValue}
+		if schema.Type == "bool" && schema.Default == "false" {
+			schema.Default = ""
+		}
+		flags = append(flags, schema)
+	})
+	return flags
+}
+
+// flagTarget returns the address of the variable a flag sets, 0 when it
+// cannot tell.
+func flagTarget(v flag.Value) uintptr {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Pointer:
+		return rv.Pointer()
+	case rv.Kind() == reflect.Struct && rv.NumField() > 0 && rv.Field(0).Kind() == reflect.Pointer:
+		return rv.Field(0).Pointer()
+	}
+	return 0
+}
+
+// flagType names the kind of value a flag takes.
+func flagType(v flag.Value) string {
+	if _, ok := v.(stringList); ok {
+		return "list"
+	}
+	if b, ok := v.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		if _, ok := v.(optionalString); ok {
+			return "optional"
+		}
+		return "bool"
+	}
+	if g, ok := v.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case int, int64, uint, uint64:
+			return "int"
+		case time.Duration:
+			return "duration"
+		}
+	}
+	return "string"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunHelpJSON(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"help", "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(help --json) = %d; stderr: %s", code, stderr.String())
+	}
+	var schema HelpSchema
+	if err := json.Unmarshal(stdout.Bytes(), &schema); err != nil {
+		t.Fatalf("help --json output is not JSON: %v\n%s", err, stdout.String())
+	}
+
+	find := func(flags []FlagSchema, name string) *FlagSchema {
+		for i := range flags {
+			if flags[i].Name == name {
+				return &flags[i]
+			}
+		}
+		return nil
+	}
+	if f := find(schema.Flags, "path"); f == nil || f.Type != "bool" || !slices.Equal(f.Aliases, []string{"p"}) {
+		t.Errorf("flag path = %+v, expected a bool with alias p", f)
+	}
+	if f := find(schema.Flags, "clear"); f == nil || f.Type != "optional" {
+		t.Errorf("flag clear = %+v, expected an optional value", f)
+	}
+	if f := find(schema.Flags, "time-format"); f == nil || f.Default != "both" {
+		t.Errorf("flag time-format = %+v, expected default both", f)
+	}
+
+	if len(schema.Commands) != len(commands) {
+		t.Errorf("schema lists %d commands, expected %d", len(schema.Commands), len(commands))
+	}
+	for _, cmd := range schema.Commands {
+		if cmd.Usage == "" || cmd.Summary == "" {
+			t.Errorf("command %s has no usage line or summary in commandHelp", cmd.Name)
+		}
+		if cmd.Name == "serve" {
+			if f := find(cmd.Flags, "interval"); f == nil || f.Type != "duration" || f.Default != "1h0m0s" {
+				t.Errorf("serve flag interval = %+v", f)
+			}
+		}
+		if cmd.Name == "mirror" {
+			if f := find(cmd.Flags, "match"); f == nil || f.Type != "list" {
+				t.Errorf("mirror flag match = %+v, expected a list", f)
+			}
+		}
+	}
+
+	// Describing the commands ran none of them
+	if _, err := os.Stat(env.cacheDir); !os.IsNotExist(err) {
+		t.Errorf("help --json touched the cache directory: %v", err)
+	}
+}
+
+func TestRunHelpCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"help"}, &stdout, &stderr); code != exitOK || !strings.Contains(stdout.String(), "Usage:") {
+		t.Errorf("run(help) = %d, output: %s", code, stdout.String())
+	}
+	if code := run([]string{"help", "lookup"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(help lookup) = %d, expected %d", code, exitError)
+	}
+}
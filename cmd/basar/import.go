@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runImport implements "basar import BUNDLE": it replaces the cache with
+// the one in a bundle written by "basar export --format bundle", read from
+// stdin when BUNDLE is "-".
+func runImport(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("import")
+	overrideFlags(fs, &o)
+
+	flags := &Flags{}
+	fs.BoolVar(&flags.Verbose, "v", false, "")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "basar: import takes one bundle (or - for stdin)")
+		return exitError
+	}
+	bundle := fs.Arg(0)
+
+	cfg := config.NewWith(o)
+	c := cache.New(cfg)
+	logger, closeLog, err := newLogger(flags, cfg, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	defer closeLog()
+	c.SetLogger(logger)
+	for _, w := range cfg.Warnings {
+		logger.Warn(w)
+	}
+
+	var r io.Reader = os.Stdin
+	if bundle != "-" {
+		f, err := os.Open(bundle)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		r = f
+	}
+
+	manifest, err := c.ImportBundle(r)
+	if err != nil {
+		if errors.Is(err, cache.ErrInvalidBundle) {
+			fmt.Fprintf(stderr, "basar: %s: %v; the cache is unchanged\n", bundle, err)
+		} else {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+		}
+		return exitError
+	}
+
+	path, _ := c.Path()
+	fmt.Fprintf(stdout, "imported %s (generation %d, exported %s) into %s\n",
+		bundle, manifest.Generation, manifest.Created.Format("2006-01-02 15:04 MST"), path)
+	return exitOK
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExportImportBundle(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createCache(t)
+
+	bundle := filepath.Join(env.tmpDir, "basar.tar.gz")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"export", "--out", bundle}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(export --out) = %d; stderr: %s", code, stderr.String())
+	}
+	if raw, err := os.ReadFile(bundle); err != nil || !bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		t.Fatalf("export --out %s should write a gzipped bundle: %v", filepath.Base(bundle), err)
+	}
+
+	// Import into an isolated instance
+	cacheDir := filepath.Join(env.tmpDir, "imported")
+	if code := run([]string{"--cache-dir", cacheDir, "import", bundle}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(import) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "imported "+bundle) {
+		t.Errorf("import output = %q", stdout.String())
+	}
+	if raw, err := os.ReadFile(filepath.Join(cacheDir, "banners.json")); err != nil || !strings.Contains(string(raw), "5.15.0-generic") {
+		t.Errorf("import should install the cache: %v", err)
+	}
+}
+
+func TestRunImportErrors(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"import"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(import) without a bundle = %d, expected %d", code, exitError)
+	}
+
+	corrupt := filepath.Join(env.tmpDir, "corrupt.tar.gz")
+	_ = os.WriteFile(corrupt, []byte{0x1f, 0x8b, 0, 0}, 0644)
+	stderr.Reset()
+	if code := run([]string{"import", corrupt}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(import) of a corrupt bundle = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "unchanged") {
+		t.Errorf("a corrupt bundle should say the cache is unchanged, got %q", stderr.String())
+	}
+
+	env.createCache(t)
+	if code := run([]string{"export", "-o", filepath.Join(env.tmpDir, "basar.tar.zst")}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(export -o .tar.zst) = %d, expected %d", code, exitError)
+	}
+}
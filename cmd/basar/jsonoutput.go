@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// ActionOutput is what --json prints for the actions of the top-level
+// flags in place of their text output: which action ran, its exit code,
+// the error when it failed, and what it reports, such as the
+// cache.UpdateResult of an update with its per-source outcomes.
+type ActionOutput struct {
+	Action   string `json:"action"`
+	OK       bool   `json:"ok"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Result   any    `json:"result,omitempty"`
+}
+
+// CheckOutput is the result of --check.
+type CheckOutput struct {
+	Valid bool   `json:"valid"`
+	Path  string `json:"path,omitempty"`
+}
+
+// ClearOutput is the result of --clear.
+type ClearOutput struct {
+	Target  string `json:"target"`
+	Removed string `json:"removed"`
+}
+
+// SetupOutput is the result of --setup and --init: the config file and,
+// after a setup, the URI of the cache.
+type SetupOutput struct {
+	Config string `json:"config"`
+	URI    string `json:"uri,omitempty"`
+}
+
+// ServiceOutput is the result of --install-service.
+type ServiceOutput struct {
+	Installed string `json:"installed"`
+}
+
+// LocationOutput is the result of --path and --uri.
+type LocationOutput struct {
+	Path string `json:"path,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// writeAction prints the outcome of action as JSON and returns its exit
+// code, or exitError if it cannot be written. Error messages still go to
+// stderr as well, so a script may read either.
+func writeAction(stdout, stderr io.Writer, format, action string, code int, err error, result any) int {
+	out := ActionOutput{
+		Action:   action,
+		OK:       code == exitOK || code == exitPartial,
+		ExitCode: code,
+		Result:   result,
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	if err := writeJSON(stdout, out, format); err != nil {
+		fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+		return exitError
+	}
+	return code
+}
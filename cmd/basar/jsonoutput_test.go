@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runJSON runs basar with args and decodes its --json output.
+func runJSON(t *testing.T, args ...string) (int, map[string]any) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	var out map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("run(%v) printed no JSON: %v\nstdout: %s\nstderr: %s", args, err, stdout.String(), stderr.String())
+	}
+	return code, out
+}
+
+func TestRunJSONUpdate(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	configDir := filepath.Dir(env.configFile)
+	_ = os.MkdirAll(configDir, 0755)
+	_ = os.WriteFile(env.configFile, []byte(env.sourceFile+"\n/nonexistent/file.json\n"), 0644)
+
+	code, out := runJSON(t, "--update", "--json")
+	if code != exitPartial {
+		t.Errorf("run(--update --json) = %d, expected %d", code, exitPartial)
+	}
+	if out["action"] != "update" || out["ok"] != true || out["exit_code"] != float64(exitPartial) {
+		t.Errorf("--update --json = %v", out)
+	}
+	result, _ := out["result"].(map[string]any)
+	if result["updated"] != true || result["entries_after"] != float64(2) {
+		t.Errorf("result = %v, expected an update to 2 entries", result)
+	}
+	sources, _ := result["sources"].([]any)
+	if len(sources) != 2 {
+		t.Fatalf("sources = %v, expected 2", sources)
+	}
+	if failed, _ := sources[1].(map[string]any); failed["status"] != "error" || failed["error"] == "" {
+		t.Errorf("missing source = %v, expected an error", failed)
+	}
+
+	code, out = runJSON(t, "--smart-update", "--json", "--time-format", "unix")
+	if code != exitPartial || out["action"] != "smart-update" {
+		t.Errorf("run(--smart-update --json) = %d, %v", code, out)
+	}
+	if result, _ := out["result"].(map[string]any); result["duration"] != nil || result["duration_seconds"] == nil {
+		t.Errorf("result = %v, expected the duration in seconds only", result)
+	}
+
+	code, out = runJSON(t, "--update", "--json", "--min-sources", "2")
+	if code != exitError || out["ok"] != false || !strings.Contains(out["error"].(string), "sources") {
+		t.Errorf("run(--update --json --min-sources 2) = %d, %v", code, out)
+	}
+}
+
+func TestRunJSONCheckAndClear(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	code, out := runJSON(t, "--check", "--json")
+	if code != exitInvalid || out["ok"] != false {
+		t.Errorf("run(--check --json) without a cache = %d, %v", code, out)
+	}
+	if result, _ := out["result"].(map[string]any); result["valid"] != false {
+		t.Errorf("result = %v, expected invalid", result)
+	}
+
+	env.createCache(t)
+	code, out = runJSON(t, "--check", "--json")
+	if result, _ := out["result"].(map[string]any); code != exitOK || result["valid"] != true || result["path"] != env.cacheFile {
+		t.Errorf("run(--check --json) = %d, %v", code, out)
+	}
+
+	code, out = runJSON(t, "--path", "--json")
+	if result, _ := out["result"].(map[string]any); code != exitOK || result["path"] != env.cacheFile {
+		t.Errorf("run(--path --json) = %d, %v", code, out)
+	}
+
+	code, out = runJSON(t, "--clear", "--force", "--json")
+	if result, _ := out["result"].(map[string]any); code != exitOK || out["action"] != "clear" || result["target"] != "cache" {
+		t.Errorf("run(--clear --force --json) = %d, %v", code, out)
+	}
+	if _, err := os.Stat(env.cacheFile); !os.IsNotExist(err) {
+		t.Error("cache file was not removed")
+	}
+}
+
+func TestRunJSONCommand(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	run([]string{"--json", "doctor"}, &stdout, &stderr)
+	var findings []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		t.Fatalf("basar --json doctor printed no JSON: %v\nstdout: %s", err, stdout.String())
+	}
+	if len(findings) == 0 {
+		t.Error("basar --json doctor printed no findings")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runLookup implements "basar lookup [--provenance] [--metadata] [--trust]
+// [-q] [-i] [-E|-F] [--release] <banner>". It exits exitOK for an exact
+// match, exitFuzzy when banners only contain the text, and exitNoMatch
+// when none does, so scripts can branch on the status alone. A missing
+// cache is exitInvalid, as for the other commands.
+func runLookup(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("lookup")
+	overrideFlags(fs, &o)
+
+	var provenance, metadata, trust, quiet bool
+	fs.BoolVar(&provenance, "provenance", false, "")
+	fs.BoolVar(&metadata, "metadata", false, "")
+	fs.BoolVar(&trust, "trust", false, "")
+	fs.BoolVar(&quiet, "quiet", false, "")
+	fs.BoolVar(&quiet, "q", false, "")
+
+	var opts cache.LookupOptions
+	fs.BoolVar(&opts.IgnoreCase, "ignore-case", false, "")
+	fs.BoolVar(&opts.IgnoreCase, "i", false, "")
+	fs.BoolVar(&opts.Release, "release", false, "")
+	// Like grep, the last of -E and -F wins
+	for _, name := range []string{"E", "regexp"} {
+		fs.BoolFunc(name, "", func(string) error { opts.Regexp = true; return nil })
+	}
+	for _, name := range []string{"F", "fixed-strings"} {
+		fs.BoolFunc(name, "", func(string) error { opts.Regexp = false; return nil })
+	}
+
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(stderr, "basar: lookup requires a banner or substring")
+		return exitError
+	}
+	query := strings.Join(rest, " ")
+
+	// Banners are looked up where basar would point volatility3
+	c := cache.New(config.NewWith(o))
+	served := c
+	if sys := c.SystemCache(); sys != nil {
+		served = sys
+	}
+	served, err = c.Layered(served)
+	var matches []cache.Match
+	if err == nil {
+		matches, err = served.LookupWith(query, opts)
+	}
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if len(matches) == 0 {
+		if !quiet {
+			fmt.Fprintf(stderr, "basar: no banner matches %q\n", query)
+		}
+		return exitNoMatch
+	}
+	code := exitOK
+	if !matches[0].Exact {
+		code = exitFuzzy
+	}
+
+	if quiet {
+		// Only the first URL, for $(basar lookup -q ...)
+		if urls := matches[0].URLs; len(urls) > 0 {
+			fmt.Fprintln(stdout, urls[0])
+		}
+		return code
+	}
+
+	for _, m := range matches {
+		if m.Tombstone != nil {
+			fmt.Fprintf(stderr, "basar: removed upstream %s, kept until %s: %s\n",
+				m.Tombstone.Removed.Format(time.DateOnly), m.Tombstone.Expires.Format(time.DateOnly), m.Banner)
+		}
+		fmt.Fprintln(stdout, m.Banner)
+		for _, u := range m.URLs {
+			if trust {
+				fmt.Fprintf(stdout, "  %s (%s)\n", u, urlTrust(m, u))
+				continue
+			}
+			fmt.Fprintf(stdout, "  %s\n", u)
+		}
+		if provenance {
+			for _, src := range m.Sources {
+				fmt.Fprintf(stdout, "  from %s\n", src)
+			}
+		}
+		if metadata {
+			names := make([]string, 0, len(m.Metadata))
+			for name := range m.Metadata {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(stdout, "  %s: %s\n", name, metadataValue(m.Metadata[name]))
+			}
+		}
+	}
+
+	return code
+}
+
+// urlTrust returns the trust level of a URL of m, config.TrustCommunity
+// when no source sets one.
+func urlTrust(m cache.Match, u string) string {
+	if level := m.Trust[u]; level != "" {
+		return level
+	}
+	return config.TrustCommunity
+}
+
+// metadataValue renders a metadata field for lookup: strings unquoted,
+// anything else as its JSON.
+func metadataValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// parseInterspersed parses fs allowing flags after positional arguments,
+// returning the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
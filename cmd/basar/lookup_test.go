@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunLookup(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	code := run([]string{"lookup", "Linux version 5.15.0-generic", "--provenance"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run(lookup) = %d, expected %d; stderr: %s", code, exitOK, stderr.String())
+	}
+
+	output := stdout.String()
+	if !strings.Contains(output, "https://example.com/5.15.0.json") {
+		t.Errorf("lookup output should contain symbol URL, got: %s", output)
+	}
+	if !strings.Contains(output, "from "+env.sourceFile) {
+		t.Errorf("lookup --provenance should list the source, got: %s", output)
+	}
+}
+
+func TestRunLookupMetadata(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	source := `{"version":1,"linux":{"Linux version 5.15.0-generic":["https://example.com/5.15.0.json"]},` +
+		`"metadata":{"Linux version 5.15.0-generic":{"compiler":"gcc 11.4.0","size":1024}}}`
+	if err := os.WriteFile(env.sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"lookup", "5.15.0", "--metadata"}, &stdout, &stderr); code != exitFuzzy {
+		t.Fatalf("run(lookup --metadata) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "  compiler: gcc 11.4.0\n  size: 1024\n") {
+		t.Errorf("lookup --metadata output = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"lookup", "5.15.0"}, &stdout, &stderr); code != exitFuzzy || strings.Contains(stdout.String(), "compiler") {
+		t.Errorf("lookup without --metadata = %d, %q", code, stdout.String())
+	}
+}
+
+func TestRunLookupTrust(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"lookup", "--trust", "Linux version 5.15.0-generic"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(lookup --trust) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "  https://example.com/5.15.0.json (community)\n") {
+		t.Errorf("lookup --trust output without trust levels = %q, expected community", stdout.String())
+	}
+
+	if err := os.WriteFile(env.configFile, []byte(env.sourceFile+" trust=trusted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"--update", "--force"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update --force) = %d; stderr: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"lookup", "--trust", "Linux version 5.15.0-generic"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(lookup --trust) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "  https://example.com/5.15.0.json (trusted)\n") {
+		t.Errorf("lookup --trust output = %q, expected the URL trusted", stdout.String())
+	}
+}
+
+func TestRunLookupTombstone(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	t.Setenv("BASAR_TOMBSTONE_TTL", "168h")
+	t.Setenv("BASAR_SHRINK_THRESHOLD", "0")
+
+	source := `{"version":1,"linux":{"Linux version 5.15.0-generic":["https://example.com/5.15.0.json"],` +
+		`"Linux version 6.1.0-generic":["https://example.com/6.1.0.json"]}}`
+	if err := os.WriteFile(env.sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+	source = `{"version":1,"linux":{"Linux version 6.1.0-generic":["https://example.com/6.1.0.json"]}}`
+	if err := os.WriteFile(env.sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"lookup", "5.15.0"}, &stdout, &stderr); code != exitFuzzy {
+		t.Fatalf("run(lookup) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "https://example.com/5.15.0.json") || !strings.Contains(stderr.String(), "removed upstream") {
+		t.Errorf("lookup of a tombstoned banner: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRunLookupExitCodes(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	source := `{"version":1,"linux":{"Linux version 5.15.0-generic":["https://example.com/5.15.0.json","https://mirror.example.com/5.15.0.json"],` +
+		`"Linux version 5.15.0-lowlatency":["https://example.com/5.15.0-lowlatency.json"]}}`
+	if err := os.WriteFile(env.sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"lookup", "-q", "Linux version 5.15.0-generic"}, exitOK, "https://example.com/5.15.0.json\n"},
+		{[]string{"lookup", "5.15.0", "--quiet"}, exitFuzzy, "https://example.com/5.15.0.json\n"},
+		{[]string{"lookup", "-q", "lowlatency"}, exitFuzzy, "https://example.com/5.15.0-lowlatency.json\n"},
+		{[]string{"lookup", "-q", "freebsd"}, exitNoMatch, ""},
+	}
+	for _, tt := range tests {
+		stdout.Reset()
+		stderr.Reset()
+		if code := run(tt.args, &stdout, &stderr); code != tt.code || stdout.String() != tt.stdout || stderr.Len() != 0 {
+			t.Errorf("run(%q) = %d, stdout %q, stderr %q; expected %d, %q", tt.args, code, stdout.String(), stderr.String(), tt.code, tt.stdout)
+		}
+	}
+}
+
+func TestRunLookupMatchOptions(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	source := `{"version":1,"linux":{"Linux version 5.15.0-91-generic (buildd@lcy02)":["https://example.com/91.json"],` +
+		`"Linux version 5.15.0-91-generic-64k (buildd@lcy02)":["https://example.com/91-64k.json"]}}`
+	if err := os.WriteFile(env.sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"lookup", "-q", "--release", "5.15.0-91-generic"}, exitOK, "https://example.com/91.json\n"},
+		{[]string{"lookup", "-q", "-i", "GENERIC-64K"}, exitFuzzy, "https://example.com/91-64k.json\n"},
+		{[]string{"lookup", "-q", "-E", `generic-\d+k`}, exitFuzzy, "https://example.com/91-64k.json\n"},
+		{[]string{"lookup", "-q", "-E", "-F", `generic-\d+k`}, exitNoMatch, ""},
+	}
+	for _, tt := range tests {
+		stdout.Reset()
+		if code := run(tt.args, &stdout, &stderr); code != tt.code || stdout.String() != tt.stdout {
+			t.Errorf("run(%q) = %d, %q; expected %d, %q", tt.args, code, stdout.String(), tt.code, tt.stdout)
+		}
+	}
+
+	stderr.Reset()
+	if code := run([]string{"lookup", "-E", "("}, &stdout, &stderr); code != exitError || !strings.Contains(stderr.String(), "regexp") {
+		t.Errorf("run(lookup -E \"(\") = %d, stderr %q", code, stderr.String())
+	}
+}
+
+func TestRunLookupNoMatch(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lookup", "freebsd"}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(lookup freebsd) without a cache = %d, expected %d", code, exitInvalid)
+	}
+
+	env.createCache(t)
+	code := run([]string{"lookup", "freebsd"}, &stdout, &stderr)
+	if code != exitNoMatch {
+		t.Errorf("run(lookup freebsd) = %d, expected %d", code, exitNoMatch)
+	}
+}
+
+func TestRunLookupMissingQuery(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lookup"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(lookup) = %d, expected %d", code, exitError)
+	}
+}
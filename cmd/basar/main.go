@@ -6,29 +6,130 @@
 // Usage:
 //
 //	basar [flags]
+//	basar <command> [args]
+//
+// Commands:
+//
+//	airgap keygen|pack|verify|unpack [--key F] [--pubkey F] [-o FILE] [ARCHIVE]  move the cache and mirrored symbol files as one signed archive
+//	browse [--symbols-dir DIR]       search the cache interactively and prefetch symbols
+//	capabilities [--json]            list features available in this build
+//	doctor [--json]                  diagnose the installation (exit 1 on problems)
+//	export [--format html|bundle] [-o FILE]  render the cache as a static web page, or pack it for import
+//	filter [--include P] [--min-kernel V] [-o FILE]  apply banner and URL filters to the cache
+//	gen-fixture [--entries N] [-o FILE]  write a synthetic cache of N banners for benchmarks
+//	help [--json]                    show help, or describe the commands and flags as JSON
+//	import BUNDLE                    replace the cache with an exported bundle, after checking it
+//	lookup [-i] [-E|-F] [--release] [--provenance] [--metadata] [--trust] [-q] <banner>  print symbol URLs for matching banners
+//	merge [-o FILE] SOURCE...        merge banner indexes (- for stdin) without touching the cache
+//	mirror [--dest DIR] [--match TEXT]  download symbol files and index them as file:// URLs
+//	prefetch [--symbols-dir DIR] [banner...]  download the running kernel's symbol files for volatility3
+//	prune --check-urls [--sample N] [--dry-run]  check symbol URLs and remove the dead ones from the cache
+//	publish --oci oci://REGISTRY/REPO:TAG  push the cache to an OCI registry as an artifact
+//	report [--since 7d] [--format F] summarize recent updates (markdown, html)
+//	resolve [--fetch] DUMP           find kernel banners in a memory image and print their symbol URLs
+//	serve [--listen A] [--interval D] [--splay D] [--verify-interval D] [--serve-profile P] [--low-priority]  keep the cache fresh and checked; serve /metrics, /healthz, /config, /lookup, /hooks/update
+//	sync-symbols [--mirror DIR] [--symbols-dir DIR] [--match TEXT]  place mirrored symbol files in volatility3's symbols directory
+//	verify-urls [--json] [--time-format F] [banner]  check symbol URLs and record their liveness
 //
 // Flags:
 //
 //	-p, --path           print cache file path
 //	-u, --uri            print file:// URI (default output)
 //	-s, --stats          print cache statistics as JSON
+//	    --time-format F  JSON timestamps and durations: both (default), rfc3339, or unix
 //	-c, --check          check if cache is valid (exit 0=valid, 2=invalid)
+//	    --stale-while-revalidate  print an expired cache at once; smart-update it in the background
 //	    --update         force cache update
 //	    --smart-update   update only if sources changed (uses ETag/Last-Modified)
-//	    --clear          remove cache file
+//	    --clear[=TARGET] remove cache|meta|snapshots|mirror|liveness|history|all (asks first)
+//	    --all            with --clear, same as --clear=all
+//	    --force          skip confirmations; allow an update to shrink the cache or a source
+//	    --fail-fast      abort an update on the first configuration error
+//	    --low-priority   run an update at the lowest CPU and IO priority (also for serve)
+//	    --jobs N         fetch at most N sources at once (default 8)
+//	    --strict         fail an update if any source fails
+//	    --min-sources N  fail an update if fewer than N sources succeed
+//	    --demote-dead    list chronically dead symbol URLs last when merging
+//	    --vol3-compat V  write only what volatility3 release V accepts (e.g. 2.4.0)
+//	    --disk-index     write a binary sidecar index for large caches
+//	    --only SEL       with --update/--smart-update: refresh only sources
+//	                     tagged tag=NAME or given by URL (repeatable)
+//	    --refresh-source URL  refetch one source, reusing snapshots for the rest
 //	    --init           create default config file
-//	    --setup          complete setup (config, update, vol3 config, systemd)
-//	    --install-service install systemd timer for auto-updates
+//	    --preset P       with --init: minimal, full (default), internal-template
+//	    --setup          complete setup (config, update, vol3 config, service)
+//	    --install-service install auto-updates (systemd, cron, launchd, or schtasks)
+//	    --splay D        with --install-service/--setup: shift the schedule by
+//	                     this host's offset within D (at most 24h)
+//	    --scheduler S    with --install-service/--setup: systemd, cron, launchd,
+//	                     schtasks, or daemon (leave updates to basar serve)
 //	    --configure-vol3  configure volatility3 to use basar
-//	-v, --verbose        enable verbose output
+//	    --replace        with --configure-vol3: make basar the only remote_isf_url
+//	    --dry-run        with an update, --setup, --init, --install-service,
+//	                     --configure-vol3, or --clear: print what it would fetch,
+//	                     write, remove, and run, changing nothing
+//	-v, --verbose        enable verbose output (same as --log-level info); updates on a
+//	                     terminal also show each source's progress
+//	-q, --quiet          print only the requested output (URI, path, stats, JSON) and errors;
+//	                     logs still reach --log-file
+//	    --log-format F   log format: text (default) or json
+//	    --log-level L    log level: debug, info, warn (default), error
+//	    --log-file[=P]   also append logs to P (default: $XDG_STATE_HOME/basar/basar.log)
+//	    --metrics-textfile P  write Prometheus metrics to P after the run
+//	    --profile NAME   use separate config, cache, and state dirs (also before a command)
+//	    --config FILE    use FILE instead of sources.conf (also before a command)
+//	    --cache-dir DIR  keep the cache and its state in DIR (also before a command)
+//	    --offline        no network access: local sources only, expired cache used as is (also before a command)
+//	    --fallback-cache-dir DIR  update into DIR (or tmpfs) when the cache dir is read-only (also before a command)
+//	    --timeout D      give up once the whole invocation has run for D, e.g. 2m (also before a command)
+//	    --json           print the outcome of --update, --smart-update, --check, --clear,
+//	                     --setup, and the other actions as JSON (also before a command
+//	                     taking --json, e.g. basar --json doctor)
+//	    --version        print version, commit, build date, and Go version (--json: as JSON)
 //	-h, --help           show help
 //
 // Environment:
 //
 //	BASAR_TTL       cache TTL in seconds (default: 86400)
+//	BASAR_SHRINK_THRESHOLD  min % of current entries an update must keep (default: 50)
+//	BASAR_SOURCE_SHRINK_THRESHOLD  min % of its typical entries a source must return (default: 50)
 //	BASAR_VERBOSE   set to "1" for verbose output
+//	BASAR_FAIL_FAST set to "1" to behave as --fail-fast
+//	BASAR_LOW_PRIORITY  set to "1" to behave as --low-priority
+//	BASAR_JOBS      sources fetched at once (default: 8)
+//	BASAR_STRICT    set to "1" to behave as --strict
+//	BASAR_MIN_SOURCES  default for --min-sources
+//	BASAR_DEMOTE_DEAD  set to "1" to behave as --demote-dead
+//	BASAR_VOL3_COMPAT  default for --vol3-compat
+//	BASAR_DISK_INDEX   set to "1" to behave as --disk-index
+//	BASAR_TOMBSTONE_TTL  keep banners dropped upstream this long (e.g. 168h)
+//	BASAR_SPLAY        default for --splay (install-service and serve)
+//	BASAR_SCHEDULER    default for --scheduler
+//	BASAR_STALE_WHILE_REVALIDATE  set to "1" to behave as --stale-while-revalidate
+//	BASAR_TRACK_USAGE  set to "1" to record serve's reads of the cache for --stats
+//	BASAR_ACCESS_LOG   web server access log to find reads of the cache in
+//	BASAR_PROFILE      default for --profile
+//	BASAR_CONFIG       default for --config
+//	BASAR_CACHE_DIR    default for --cache-dir
+//	BASAR_OFFLINE      set to "1" to behave as --offline
+//	BASAR_FALLBACK_CACHE_DIR  default for --fallback-cache-dir
+//	BASAR_SYSTEM_CACHE      when to print the system-wide cache: auto, prefer, never
+//	BASAR_SYSTEM_CACHE_DIR  system-wide cache (default: /var/cache/basar)
+//	BASAR_PROXY_PAC    proxy auto-config file URL or path (over proxy.conf)
+//	BASAR_NEGOTIATE_CMD  command printing Kerberos tokens for Negotiate authentication
+//	NETRC              netrc file for auth=netrc (default: ~/.netrc)
+//	GITHUB_TOKEN       token for github:// sources without a token_ option
+//	NO_COLOR           set to turn off the colors of -v progress
+//	BASAR_GITHUB_API   GitHub Enterprise API URL for github:// sources
+//	BASAR_WEBHOOK_SECRET  secret for serve's /hooks/update endpoint
 //	XDG_CACHE_HOME     cache directory base (default: ~/.cache)
 //	XDG_CONFIG_HOME    config directory base (default: ~/.config)
+//	XDG_STATE_HOME     state directory base (default: ~/.local/state)
+//
+// Exit status is 0 on success, 1 on error, 2 for an invalid cache (-c), and
+// 3 when an update succeeded but some sources failed, with or without
+// --quiet. lookup exits 0 for an exact match, 4 when banners only contain
+// the text, and 5 for no match.
 //
 // Examples:
 //
@@ -39,40 +140,103 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/calilkhalil/basar/internal/cache"
 	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/logging"
+	"github.com/calilkhalil/basar/internal/metrics"
 )
 
 const (
 	exitOK      = 0
 	exitError   = 1
 	exitInvalid = 2
+	exitPartial = 3
+	exitFuzzy   = 4
+	exitNoMatch = 5
 )
 
 // Flags holds parsed command-line flags.
 type Flags struct {
-	Path           bool
-	URI            bool
-	Stats          bool
-	Check          bool
-	Update         bool
-	SmartUpdate    bool
-	Clear          bool
-	Init           bool
-	Setup          bool
-	InstallService bool
-	ConfigureVol3  bool
-	Verbose        bool
-	Help           bool
+	Path            bool
+	URI             bool
+	Stats           bool
+	Check           bool
+	Update          bool
+	SmartUpdate     bool
+	Clear           string
+	All             bool
+	Force           bool
+	FailFast        bool
+	LowPriority     bool
+	Jobs            int
+	Strict          bool
+	DemoteDead      bool
+	DiskIndex       bool
+	Revalidate      bool
+	Only            []string
+	RefreshSource   string
+	MinSources      int
+	Splay           time.Duration
+	Scheduler       string
+	TimeFormat      string
+	Vol3Compat      string
+	Init            bool
+	Preset          string
+	Setup           bool
+	InstallService  bool
+	ConfigureVol3   bool
+	Replace         bool
+	DryRun          bool
+	Verbose         bool
+	Quiet           bool
+	LogFormat       string
+	LogLevel        string
+	LogFile         string
+	MetricsTextfile string
+	Help            bool
+	Version         bool
+	JSON            bool
+
+	// Overrides relocates the config file and cache directory.
+	Overrides config.Overrides
+}
+
+// commands maps subcommand names to their implementations. Each receives
+// the --config and --cache-dir overrides given before the command name.
+var commands = map[string]func(args []string, o config.Overrides, stdout, stderr io.Writer) int{
+	"airgap":       runAirgap,
+	"browse":       runBrowse,
+	"capabilities": runCapabilities,
+	"doctor":       runDoctor,
+	"export":       runExport,
+	"filter":       runFilter,
+	"gen-fixture":  runGenFixture,
+	"import":       runImport,
+	"lookup":       runLookup,
+	"merge":        runMerge,
+	"mirror":       runMirror,
+	"prune":        runPrune,
+	"publish":      runPublish,
+	"prefetch":     runPrefetch,
+	"report":       runReport,
+	"resolve":      runResolve,
+	"serve":        runServe,
+	"sync-symbols": runSyncSymbols,
+	"verify-urls":  runVerifyURLs,
 }
 
 func main() {
@@ -80,6 +244,12 @@ func main() {
 }
 
 func run(args []string, stdout, stderr io.Writer) int {
+	if o, rest, ok := leadingOverrides(args); ok && len(rest) > 0 {
+		if cmd, ok := commands[rest[0]]; ok {
+			return cmd(rest[1:], o, stdout, stderr)
+		}
+	}
+
 	flags, err := parseFlags(args)
 	if err != nil {
 		fmt.Fprintf(stderr, "basar: %v\n", err)
@@ -90,151 +260,392 @@ func run(args []string, stdout, stderr io.Writer) int {
 		printUsage(stdout)
 		return exitOK
 	}
+	if flags.Version {
+		if err := printVersion(stdout, flags.JSON); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	if flags.Jobs < 0 {
+		fmt.Fprintf(stderr, "basar: invalid --jobs %d\n", flags.Jobs)
+		return exitError
+	}
+	if flags.MinSources < 0 {
+		fmt.Fprintf(stderr, "basar: invalid --min-sources %d\n", flags.MinSources)
+		return exitError
+	}
+	if flags.Splay < 0 || flags.Splay > config.MaxSplay {
+		fmt.Fprintf(stderr, "basar: invalid --splay %s\n", flags.Splay)
+		return exitError
+	}
+	if err := checkTimeFormat(flags.TimeFormat); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if flags.Preset != "" && !flags.Init {
+		fmt.Fprintln(stderr, "basar: --preset requires --init")
+		return exitError
+	}
+	if flags.Replace && !flags.ConfigureVol3 {
+		fmt.Fprintln(stderr, "basar: --replace requires --configure-vol3")
+		return exitError
+	}
+	if len(flags.Only) > 0 && !flags.Update && !flags.SmartUpdate {
+		fmt.Fprintln(stderr, "basar: --only requires --update or --smart-update")
+		return exitError
+	}
+	if flags.DryRun && !flags.mutates() {
+		fmt.Fprintln(stderr, "basar: --dry-run requires --update, --smart-update, --refresh-source, --setup, --init, --configure-vol3, --install-service, or --clear")
+		return exitError
+	}
 
-	// Setup context with signal handling
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	// Setup context with signal handling and --timeout
+	ctx, cancel := commandContext(flags.Overrides, stderr)
 	defer cancel()
 
-	cfg := config.New()
+	// A dry run must not migrate older layouts either, only list them
+	flags.Overrides.DryRun = flags.DryRun
+	cfg := config.NewWith(flags.Overrides)
+	if flags.Force {
+		cfg.ShrinkThreshold = 0
+		cfg.AcceptShrink = true
+	}
+	if flags.FailFast {
+		cfg.FailFast = true
+	}
+	if flags.LowPriority {
+		cfg.LowPriority = true
+	}
+	if flags.Jobs > 0 {
+		cfg.Jobs = flags.Jobs
+	}
+	if flags.Strict {
+		cfg.Strict = true
+	}
+	if flags.DemoteDead {
+		cfg.DemoteDeadURLs = true
+	}
+	if flags.DiskIndex {
+		cfg.DiskIndex = true
+	}
+	if flags.Revalidate {
+		cfg.StaleWhileRevalidate = true
+	}
+	cfg.Only = flags.Only
+	if flags.MinSources > 0 {
+		cfg.MinSources = flags.MinSources
+	}
+	if flags.Splay > 0 {
+		cfg.Splay = flags.Splay
+	}
+	if flags.Vol3Compat != "" {
+		cfg.Vol3Compat = flags.Vol3Compat
+	}
+	if flags.Scheduler != "" {
+		cfg.Scheduler = flags.Scheduler
+	}
+	if cfg.Scheduler != "" {
+		if err := cache.CheckScheduler(cfg.Scheduler); err != nil {
+			fmt.Fprintf(stderr, "basar: --scheduler: %v\n", err)
+			return exitError
+		}
+	}
+	if cfg.Vol3Compat != "" {
+		if err := cache.CheckVol3Compat(cfg.Vol3Compat); err != nil {
+			fmt.Fprintf(stderr, "basar: --vol3-compat: %v\n", err)
+			return exitError
+		}
+	}
 	c := cache.New(cfg)
 
 	// Handle verbose from env if not set via flag
-	verbose := flags.Verbose || os.Getenv("BASAR_VERBOSE") == "1"
+	if os.Getenv("BASAR_VERBOSE") == "1" {
+		flags.Verbose = true
+	}
+
+	// -v on a terminal: show the sources as they are fetched, with the
+	// log going above the status line
+	logOut := stderr
+	if showProgress(flags, stderr) && !flags.DryRun && (flags.Update || flags.SmartUpdate || flags.RefreshSource != "" || flags.Setup) {
+		progress := newProgressDisplay(stderr)
+		defer progress.finish()
+		c.SetProgress(progress.report)
+		logOut = progress
+	}
+
+	logger, closeLog, err := newLogger(flags, cfg, logOut)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	defer closeLog()
+	c.SetLogger(logger)
+	for _, w := range cfg.Warnings {
+		logger.Warn(w)
+	}
+	for _, m := range cfg.Migrations {
+		logger.Info("migrated legacy layout", "change", m)
+	}
+	if cfg.LowPriority && (flags.Update || flags.SmartUpdate) {
+		enterLowPriority(logger)
+	}
+
+	// Confirmations of what an action did are left out with --quiet, and
+	// with --json, which describes the outcome instead
+	notes := stdout
+	if flags.Quiet || flags.JSON {
+		notes = io.Discard
+	}
+
+	// --json: each action describes its outcome on stdout
+	done := func(action string, code int, err error, result any) int {
+		if !flags.JSON {
+			return code
+		}
+		return writeAction(stdout, stderr, flags.TimeFormat, action, code, err, result)
+	}
+
+	// --dry-run: describe what the action would change, changing nothing
+	if flags.DryRun {
+		action, plan, err := planAction(c, cfg, flags)
+		var result any
+		if plan != nil {
+			planMigrations(plan, cfg.PendingMigrations)
+			result = plan
+			if !flags.JSON {
+				printPlan(stdout, plan)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return done(action, exitError, err, result)
+		}
+		return done(action, exitOK, nil, result)
+	}
+
+	// --metrics-textfile: export metrics once the action has run
+	if flags.MetricsTextfile != "" {
+		defer func() {
+			if err := metrics.WriteFile(flags.MetricsTextfile, c.Stats()); err != nil {
+				logger.Warn("writing metrics failed", "error", err)
+			}
+		}()
+	}
 
 	// --setup: complete setup
 	if flags.Setup {
-		if err := c.Setup(ctx, verbose); err != nil {
+		if err := c.Setup(ctx); err != nil {
 			fmt.Fprintf(stderr, "basar: %v\n", err)
-			return exitError
+			return done("setup", exitError, err, nil)
 		}
-		fmt.Fprintln(stdout, "setup complete")
-		return exitOK
+		fmt.Fprintln(notes, "setup complete")
+		uri, _ := c.URI()
+		return done("setup", exitOK, nil, SetupOutput{Config: cfg.ConfigFile, URI: uri})
 	}
 
 	// --init: create config file
 	if flags.Init {
-		if err := cfg.InitConfig(); err != nil {
+		if err := cfg.InitConfig(flags.Preset); err != nil {
 			fmt.Fprintf(stderr, "basar: %v\n", err)
-			return exitError
+			return done("init", exitError, err, nil)
 		}
-		fmt.Fprintln(stdout, cfg.ConfigFile)
-		return exitOK
+		fmt.Fprintln(notes, cfg.ConfigFile)
+		return done("init", exitOK, nil, SetupOutput{Config: cfg.ConfigFile})
 	}
 
-	// --install-service: install systemd timer
+	// --install-service: install scheduled updates
 	if flags.InstallService {
-		if err := c.InstallService(); err != nil {
+		what, err := c.InstallService()
+		if err != nil {
 			fmt.Fprintf(stderr, "basar: %v\n", err)
-			return exitError
+			return done("install-service", exitError, err, nil)
 		}
-		fmt.Fprintln(stdout, "systemd timer installed")
-		return exitOK
+		if what == "" {
+			fmt.Fprintln(notes, "nothing installed; run basar serve to keep the cache fresh")
+		} else {
+			fmt.Fprintln(notes, what+" installed")
+		}
+		return done("install-service", exitOK, nil, ServiceOutput{Installed: what})
 	}
 
 	// --configure-vol3: configure volatility3
 	if flags.ConfigureVol3 {
-		if err := c.ConfigureVolatility3(); err != nil {
+		report, err := c.ConfigureVolatility3(flags.Replace)
+		if flags.JSON {
+			var result any
+			if report != nil {
+				result = report
+			}
+			if errors.Is(err, cache.ErrVol3AlreadyConfigured) {
+				err = nil
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "basar: %v\n", err)
+				return done("configure-vol3", exitError, err, result)
+			}
+			return done("configure-vol3", exitOK, nil, result)
+		}
+		printVol3Report(notes, report)
+		if errors.Is(err, cache.ErrVol3AlreadyConfigured) {
+			fmt.Fprintln(notes, "volatility3 already configured")
+			return exitOK
+		}
+		if err != nil {
 			fmt.Fprintf(stderr, "basar: %v\n", err)
 			return exitError
 		}
-		fmt.Fprintln(stdout, "volatility3 configured")
+		fmt.Fprintln(notes, "volatility3 configured")
 		return exitOK
 	}
 
-	// --clear: remove cache
-	if flags.Clear {
-		if err := c.Clear(); err != nil {
-			fmt.Fprintf(stderr, "basar: %v\n", err)
+	// --clear: remove cache artifacts
+	if flags.Clear != "" {
+		what, ok := clearDescriptions[flags.Clear]
+		if !ok {
+			fmt.Fprintf(stderr, "basar: unknown clear target %q (want %s)\n",
+				flags.Clear, strings.Join(cache.ClearTargets, ", "))
 			return exitError
 		}
-		return exitOK
+		if !flags.Force {
+			if !stdinIsTerminal() {
+				fmt.Fprintln(stderr, "basar: refusing to clear without confirmation; rerun with --force")
+				return done("clear", exitError, errors.New("refusing to clear without confirmation"), nil)
+			}
+			if !confirm(stderr, fmt.Sprintf("remove %s?", what)) {
+				fmt.Fprintln(stderr, "aborted")
+				return done("clear", exitError, errors.New("aborted"), nil)
+			}
+		}
+
+		if err := c.ClearTarget(flags.Clear); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return done("clear", exitError, err, nil)
+		}
+		return done("clear", exitOK, nil, ClearOutput{Target: flags.Clear, Removed: what})
 	}
 
 	// --smart-update: update only if changed
 	if flags.SmartUpdate {
-		if verbose {
-			fmt.Fprintf(stderr, "checking %d sources for updates\n", len(cfg.Sources))
-		}
-		updated, err := c.SmartUpdate(ctx, verbose)
+		logger.Info("checking sources for updates", "sources", len(cfg.Sources))
+		res, err := c.SmartUpdate(ctx)
 		if err != nil {
-			fmt.Fprintf(stderr, "basar: %v\n", err)
-			return exitError
+			printUpdateError(stderr, err)
+			return done("smart-update", exitError, err, res)
 		}
-		if verbose {
-			if updated {
-				stats := c.Stats()
-				fmt.Fprintf(stderr, "updated: %d banners cached\n", stats.Entries)
-			} else {
-				fmt.Fprintln(stderr, "no changes")
-			}
+		if res.Updated {
+			logUpdate(logger, res)
+		} else {
+			logger.Info("no changes", "duration", res.Duration)
 		}
-		return exitOK
+		return done("smart-update", updateExitCode(res), nil, res)
+	}
+
+	// --refresh-source: refetch one source, reusing snapshots for the rest
+	if flags.RefreshSource != "" {
+		logger.Info("refreshing source", "source", flags.RefreshSource)
+		res, err := c.RefreshSource(ctx, flags.RefreshSource)
+		if err != nil {
+			printUpdateError(stderr, err)
+			return done("refresh-source", exitError, err, res)
+		}
+		logUpdate(logger, res)
+		return done("refresh-source", updateExitCode(res), nil, res)
 	}
 
 	// --update: force update
 	if flags.Update {
-		if verbose {
-			fmt.Fprintf(stderr, "updating from %d sources\n", len(cfg.Sources))
-		}
-		if err := c.Update(ctx, true); err != nil {
-			fmt.Fprintf(stderr, "basar: %v\n", err)
-			return exitError
-		}
-		if verbose {
-			stats := c.Stats()
-			fmt.Fprintf(stderr, "cached %d banners\n", stats.Entries)
+		logger.Info("updating from sources", "sources", len(cfg.Sources))
+		res, err := c.Update(ctx, true)
+		if err != nil {
+			printUpdateError(stderr, err)
+			return done("update", exitError, err, res)
 		}
-		return exitOK
+		logUpdate(logger, res)
+		return done("update", updateExitCode(res), nil, res)
 	}
 
 	// --check: verify cache validity
 	if flags.Check {
+		path, _ := c.Path()
 		if c.IsValid() {
-			return exitOK
+			return done("check", exitOK, nil, CheckOutput{Valid: true, Path: path})
 		}
-		return exitInvalid
+		return done("check", exitInvalid, nil, CheckOutput{Path: path})
 	}
 
 	// --stats: print statistics
 	if flags.Stats {
-		stats := c.Stats()
-		enc := json.NewEncoder(stdout)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(stats); err != nil {
+		if err := writeJSON(stdout, c.Stats(), flags.TimeFormat); err != nil {
 			fmt.Fprintf(stderr, "basar: encoding stats: %v\n", err)
 			return exitError
 		}
 		return exitOK
 	}
 
-	// Ensure cache is valid for path/uri output
-	if err := c.Ensure(ctx); err != nil {
+	action := "uri"
+	if flags.Path {
+		action = "path"
+	}
+
+	// Ensure cache is valid for path/uri output. With
+	// --stale-while-revalidate an expired cache is printed as is and
+	// refreshed in the background, so callers never wait on the network.
+	// Users without a cache of their own share a valid system-wide one
+	// read-only rather than each downloading a copy
+	served := c
+	if sys := c.SystemCache(); sys != nil {
+		logger.Info("using the system-wide cache", "dir", cfg.SystemCacheDir)
+		served = sys
+	} else if _, exists := c.Path(); cfg.StaleWhileRevalidate && !cfg.Offline && exists && !c.IsValid() {
+		how, err := startBackgroundUpdate(flags.Overrides, forwardedEnv(cfg))
+		if err != nil {
+			logger.Warn("cache expired; background update failed", "error", err)
+		} else {
+			logger.Info("cache expired; updating in the background", "via", how)
+		}
+	} else if err := c.Ensure(ctx); err != nil {
 		fmt.Fprintf(stderr, "basar: %v\n", err)
-		return exitError
+		return done(action, exitError, err, nil)
+	}
+
+	// The user's overlay goes over whichever cache is served
+	served, err = c.Layered(served)
+	if errors.Is(err, cache.ErrNoCache) {
+		return done(action, exitInvalid, err, nil)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return done(action, exitError, err, nil)
 	}
 
 	// --path: print file path
 	if flags.Path {
-		path, ok := c.Path()
+		path, ok := served.Path()
 		if !ok {
-			return exitInvalid
+			return done(action, exitInvalid, nil, nil)
 		}
-		fmt.Fprintln(stdout, path)
-		return exitOK
+		if !flags.JSON {
+			fmt.Fprintln(stdout, path)
+		}
+		return done(action, exitOK, nil, LocationOutput{Path: path})
 	}
 
 	// Default (or --uri): print file:// URI
-	uri, ok := c.URI()
+	uri, ok := served.URI()
 	if !ok {
-		return exitInvalid
+		return done(action, exitInvalid, nil, nil)
 	}
-	fmt.Fprintln(stdout, uri)
-	return exitOK
+	if !flags.JSON {
+		fmt.Fprintln(stdout, uri)
+	}
+	return done(action, exitOK, nil, LocationOutput{URI: uri})
 }
 
 func parseFlags(args []string) (*Flags, error) {
-	fs := flag.NewFlagSet("basar", flag.ContinueOnError)
-	fs.SetOutput(io.Discard) // Handle errors manually
+	fs := newFlagSet("basar")
 
 	flags := &Flags{}
 
@@ -248,47 +659,564 @@ func parseFlags(args []string) (*Flags, error) {
 	fs.BoolVar(&flags.Check, "check", false, "")
 	fs.BoolVar(&flags.Update, "update", false, "")
 	fs.BoolVar(&flags.SmartUpdate, "smart-update", false, "")
-	fs.BoolVar(&flags.Clear, "clear", false, "")
+	fs.Var(optionalString{&flags.Clear}, "clear", "")
+	fs.BoolVar(&flags.All, "all", false, "")
+	fs.BoolVar(&flags.Force, "force", false, "")
+	fs.BoolVar(&flags.FailFast, "fail-fast", false, "")
+	fs.BoolVar(&flags.LowPriority, "low-priority", false, "")
+	fs.IntVar(&flags.Jobs, "jobs", 0, "")
+	fs.BoolVar(&flags.Strict, "strict", false, "")
+	fs.BoolVar(&flags.DemoteDead, "demote-dead", false, "")
+	fs.BoolVar(&flags.DiskIndex, "disk-index", false, "")
+	fs.Var(stringList{&flags.Only}, "only", "")
+	fs.StringVar(&flags.RefreshSource, "refresh-source", "", "")
+	fs.IntVar(&flags.MinSources, "min-sources", 0, "")
+	fs.DurationVar(&flags.Splay, "splay", 0, "")
+	fs.StringVar(&flags.Scheduler, "scheduler", "", "")
+	fs.StringVar(&flags.Vol3Compat, "vol3-compat", "", "")
+	fs.StringVar(&flags.TimeFormat, "time-format", "both", "")
+	fs.BoolVar(&flags.Revalidate, "stale-while-revalidate", false, "")
 	fs.BoolVar(&flags.Init, "init", false, "")
 	fs.BoolVar(&flags.Init, "init-config", false, "")
+	fs.StringVar(&flags.Preset, "preset", "", "")
 	fs.BoolVar(&flags.Setup, "setup", false, "")
 	fs.BoolVar(&flags.InstallService, "install-service", false, "")
 	fs.BoolVar(&flags.ConfigureVol3, "configure-vol3", false, "")
+	fs.BoolVar(&flags.Replace, "replace", false, "")
+	fs.BoolVar(&flags.DryRun, "dry-run", false, "")
 	fs.BoolVar(&flags.Verbose, "v", false, "")
 	fs.BoolVar(&flags.Verbose, "verbose", false, "")
+	fs.BoolVar(&flags.Quiet, "q", false, "")
+	fs.BoolVar(&flags.Quiet, "quiet", false, "")
+	fs.StringVar(&flags.LogFormat, "log-format", "", "")
+	fs.StringVar(&flags.LogLevel, "log-level", "", "")
+	fs.Var(optionalString{&flags.LogFile}, "log-file", "")
+	fs.StringVar(&flags.MetricsTextfile, "metrics-textfile", "", "")
+	overrideFlags(fs, &flags.Overrides)
 	fs.BoolVar(&flags.Help, "h", false, "")
 	fs.BoolVar(&flags.Help, "help", false, "")
+	fs.BoolVar(&flags.Version, "version", false, "")
+	fs.BoolVar(&flags.JSON, "json", false, "")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
 
+	// A bare --clear may be followed by its target as a separate argument.
+	if flags.Clear == "true" {
+		flags.Clear = cache.ClearCache
+		if flags.All {
+			flags.Clear = cache.ClearAllTarget
+		} else if fs.NArg() > 0 && slices.Contains(cache.ClearTargets, fs.Arg(0)) {
+			flags.Clear = fs.Arg(0)
+			if err := fs.Parse(fs.Args()[1:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return flags, nil
 }
 
+// clearDescriptions names what each --clear target removes, for the
+// confirmation prompt.
+var clearDescriptions = map[string]string{
+	cache.ClearCache:     "the banner cache",
+	cache.ClearMeta:      "source metadata",
+	cache.ClearSnapshots: "per-source snapshots",
+	cache.ClearMirror:    "mirrored symbol files",
+	cache.ClearLiveness:  "the symbol URL check history",
+	cache.ClearHistory:   "the update history",
+	cache.ClearAllTarget: "the banner cache, snapshots, source metadata, mirrored files, and URL and update history",
+}
+
+// newFlagSet returns the flag set of a command, which reports errors to its
+// caller rather than printing them.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if recordFlagSet != nil {
+		recordFlagSet(fs)
+	}
+	return fs
+}
+
+// recordFlagSet, when set, receives every flag set newFlagSet returns, so
+// "help --json" describes the flags commands actually parse.
+var recordFlagSet func(*flag.FlagSet)
+
+// overrideFlags registers --profile, --config, --cache-dir, --offline,
+// --fallback-cache-dir, and --timeout on fs, storing them in o.
+func overrideFlags(fs *flag.FlagSet, o *config.Overrides) {
+	fs.Func("profile", "", func(name string) error {
+		if err := config.CheckProfile(name); err != nil {
+			return err
+		}
+		o.Profile = name
+		return nil
+	})
+	fs.StringVar(&o.ConfigFile, "config", o.ConfigFile, "")
+	fs.StringVar(&o.CacheDir, "cache-dir", o.CacheDir, "")
+	fs.BoolVar(&o.Offline, "offline", o.Offline, "")
+	fs.StringVar(&o.FallbackCacheDir, "fallback-cache-dir", o.FallbackCacheDir, "")
+	fs.Func("timeout", "", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		o.Timeout = d
+		return nil
+	})
+}
+
+// commandContext returns a context canceled on SIGINT or SIGTERM, or once
+// the --timeout in o elapses. The returned function releases it, reporting
+// on stderr if the timeout cut the invocation short.
+func commandContext(o config.Overrides, stderr io.Writer) (context.Context, func()) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	if o.Timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	return ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fmt.Fprintf(stderr, "basar: timed out after %s\n", o.Timeout)
+		}
+		cancel()
+		stop()
+	}
+}
+
+// leadingOverrides parses the overrideFlags at the start of args, as in
+// "basar --profile work lookup ...", returning the remaining args. A
+// leading --json is passed on to the command, as in "basar --json
+// doctor". It reports false if args do not start that way and should be
+// parsed as options instead.
+func leadingOverrides(args []string) (config.Overrides, []string, bool) {
+	var o config.Overrides
+	fs := newFlagSet("basar")
+	overrideFlags(fs, &o)
+	asJSON := fs.Bool("json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		return o, nil, false
+	}
+	rest := fs.Args()
+	if *asJSON && len(rest) > 0 {
+		rest = append([]string{rest[0], "--json"}, rest[1:]...)
+	}
+	return o, rest, true
+}
+
+// optionalString is a string flag that may also be given bare, in which
+// case its value is "true".
+type optionalString struct {
+	value *string
+}
+
+func (o optionalString) String() string {
+	if o.value == nil {
+		return ""
+	}
+	return *o.value
+}
+
+func (o optionalString) Set(s string) error {
+	*o.value = s
+	return nil
+}
+
+func (o optionalString) IsBoolFlag() bool { return true }
+
+// stringList is a string flag that may be repeated, collecting each value.
+type stringList struct {
+	values *[]string
+}
+
+func (l stringList) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l stringList) Set(s string) error {
+	*l.values = append(*l.values, s)
+	return nil
+}
+
+// newLogger builds the logger from the logging flags. Warnings are shown by
+// default; --verbose lowers the level to info unless --log-level is given.
+func newLogger(flags *Flags, cfg *config.Config, stderr io.Writer) (*slog.Logger, func() error, error) {
+	level := slog.LevelWarn
+	if flags.Verbose {
+		level = slog.LevelInfo
+	}
+	if flags.LogLevel != "" {
+		var err error
+		if level, err = logging.ParseLevel(flags.LogLevel); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	logFile := flags.LogFile
+	if logFile == "true" {
+		logFile = cfg.LogFile
+	}
+
+	// --quiet keeps logs off the terminal, not out of the log file
+	if flags.Quiet {
+		stderr = io.Discard
+	}
+	return logging.New(stderr, logging.Options{
+		Format: flags.LogFormat,
+		Level:  level,
+		File:   logFile,
+	})
+}
+
+// stdin is where confirmations are read from; replaced in tests.
+var stdin io.Reader = os.Stdin
+
+// stdinIsTerminal reports whether confirmations can be asked interactively.
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question on w and reads the answer from stdin.
+func confirm(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printVol3Report lists the volatility3 installs --configure-vol3 found
+// and what it did to each config.
+func printVol3Report(w io.Writer, report *cache.Vol3Report) {
+	if report == nil {
+		return
+	}
+	for _, install := range report.Installs {
+		version := install.Version
+		if version == "" {
+			version = "(unknown version)"
+		}
+		fmt.Fprintf(w, "found volatility3 %s (%s): %s\n", version, install.Via, install.Dir)
+	}
+	for _, config := range report.Configs {
+		switch {
+		case config.Created:
+			fmt.Fprintf(w, "created %s\n", config.Path)
+		case config.Changed:
+			fmt.Fprintf(w, "updated %s\n", config.Path)
+		default:
+			fmt.Fprintf(w, "%s already uses the cache\n", config.Path)
+		}
+	}
+}
+
+// logUpdate logs a successful update that changed the cache.
+func logUpdate(logger *slog.Logger, res *cache.UpdateResult) {
+	logger.Info("updated: banners cached",
+		"entries", res.EntriesAfter,
+		"before", res.EntriesBefore,
+		"generation", res.Generation,
+		"duration", res.Duration)
+}
+
+// updateExitCode distinguishes a degraded update, in which some sources
+// failed, from a complete one.
+func updateExitCode(res *cache.UpdateResult) int {
+	if res.Partial() {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// printUpdateError reports an update failure, hinting at --force when the
+// shrink guard refused the new data.
+func printUpdateError(w io.Writer, err error) {
+	fmt.Fprintf(w, "basar: %v\n", err)
+	if errors.Is(err, cache.ErrShrink) {
+		fmt.Fprintln(w, "basar: rerun with --force to accept the smaller cache")
+	}
+}
+
 func printUsage(w io.Writer) {
 	fmt.Fprint(w, `basar - Volatility3 ISF symbol cache manager
 
 Usage: basar [options]
+       basar <command> [args]
+
+Commands:
+  airgap keygen|pack|verify|unpack [--key FILE] [--pubkey FILE] [-o FILE]
+         [--mirror DIR] [ARCHIVE]
+                        move the cache, metadata, and mirrored symbol files
+                        across a data diode as one archive signed with an
+                        ed25519 key: keygen writes FILE and FILE.pub, pack
+                        signs with --key, verify and unpack check with
+                        --pubkey before installing anything
+  browse [--symbols-dir DIR]
+                        search banners interactively, show their URLs,
+                        trust, sources, and metadata, and prefetch the
+                        symbol files of those marked into DIR/linux
+  capabilities [--json] list features available in this build and platform
+  doctor [--json]       check config, sources, cache, volatility3 wiring,
+                        lock, and auto-update service; exit 1 on problems
+  export [--format html|bundle] [-o FILE]
+                        write a searchable page of banners, sources, and
+                        the last update to FILE (default stdout); bundle
+                        (the default for FILE.tar.gz) packs the cache,
+                        metadata, and snapshots with checksums
+  filter [--include PATTERN] [--exclude PATTERN] [--min-kernel VERSION]
+         [--max-kernel VERSION] [--dry-run] [-o FILE]
+                        apply banner-filters.conf and url-filters.conf to
+                        the cache in place, or write the result to FILE
+                        (- for stdout); flags replace the banner filters
+  gen-fixture [--entries N] [--seed N] [--jobs N] [-o FILE]
+                        write a synthetic cache of N banners (default
+                        100000) with realistic distributions and URLs, for
+                        benchmarks; the same seed gives the same file
+  help [--json]         show this help; --json describes every command and
+                        flag (name, aliases, type, default) for wrappers
+                        and completion generators
+  import BUNDLE         replace the cache with an exported bundle (- for
+                        stdin) after verifying every file; for moving the
+                        cache to air-gapped machines
+  lookup [-i] [-E|-F] [--release] [--provenance] [--metadata] [--trust] [-q] <banner>
+                        print symbol URLs for banners matching the text;
+                        -i (--ignore-case) ignores case, -E (--regexp)
+                        takes a regular expression rather than fixed
+                        text (-F, --fixed-strings), --release matches the
+                        whole kernel release (5.15.0-91-generic);
+                        --provenance also lists the contributing sources,
+                        --metadata the fields sources list per banner,
+                        --trust the trust level of each URL,
+                        -q (--quiet) only the first URL
+  merge [-o FILE] SOURCE...
+                        merge the banner indexes of the given sources (-
+                        for stdin) and write the result to stdout or FILE,
+                        without reading sources.conf or the cache
+  mirror [--dest DIR] [--match TEXT]... [--json]
+                        download the symbol files of banners matching any
+                        TEXT (default all) into DIR (default the cache's
+                        mirror dir) and write DIR/banners.json listing
+                        them as file:// URLs, for offline volatility3
+  prefetch [--symbols-dir DIR] [--json] [BANNER]...
+                        download the symbol files of the running kernel
+                        and of any BANNER into DIR/linux (default the
+                        volatility3 symbols directory)
+  prune --check-urls [--sample N] [--jobs N] [--dry-run] [--report FILE]
+        [--json]
+                        HEAD-check the symbol URLs of the cache (or N
+                        picked at random) and remove those that are gone
+                        (404, 410, or a missing file), or only list them
+                        with --dry-run; FILE receives the JSON report
+  publish --oci oci://REGISTRY/REPOSITORY:TAG
+                        push the cache to an OCI registry as an artifact,
+                        for oci:// sources elsewhere; logs in with the
+                        credentials of docker login or oras login
+  report [--since PERIOD] [--format markdown|html]
+                        summarize banners added and removed and source
+                        health over PERIOD (default 7d; also 2w, 36h,
+                        or a date like 2024-01-31)
+  resolve [--fetch] [--symbols-dir DIR] [--json] DUMP
+                        scan a raw or LiME memory image (- for stdin) for
+                        kernel banners and print their symbol URLs; with
+                        --fetch, download the files like prefetch
+  serve [--listen ADDR] [--interval DURATION] [--splay DURATION]
+        [--verify-interval DURATION] [--webhook-secret-file FILE]
+        [--serve-profile NAME[,interval=D][,path=/P]]... [--low-priority]
+                        refresh the cache every DURATION (default 1h) and
+                        serve /metrics, /healthz, /config, /banners.json,
+                        and /lookup?q=TEXT (or ?prefix=TEXT) on ADDR
+                        (default localhost:9464); with a webhook secret,
+                        POST /hooks/update triggers a refresh; --splay
+                        delays refreshes by this host's offset within it;
+                        --verify-interval checks the cache's integrity
+                        that often (default 15m, 0 to disable);
+                        --serve-profile also serves profile NAME under
+                        /NAME (or path), refreshed every interval;
+                        --low-priority runs the daemon at low priority
+  sync-symbols [--mirror DIR] [--symbols-dir DIR] [--match TEXT]... [--json]
+                        link or copy the files basar mirror downloaded
+                        (from --mirror DIR, default the cache's mirror)
+                        into DIR/linux (default volatility3's symbols
+                        directory), for volatility3 without -u
+  verify-urls [--json] [--time-format F] [banner]
+                        check the symbol URLs of matching banners (all by
+                        default) and record the results for --demote-dead
 
 Options:
   -p, --path            print cache file path
   -u, --uri             print file:// URI (default output)
   -s, --stats           print cache statistics as JSON
+      --time-format F   give JSON timestamps and durations as both RFC 3339
+                        strings and numbers (KEY_unix, KEY_seconds; the
+                        default), or only as rfc3339 or unix
   -c, --check           check if cache is valid (exit 0=valid, 2=invalid)
+      --stale-while-revalidate
+                        print an expired cache's URI or path right away and
+                        run --smart-update in the background (systemd-run
+                        or a detached process) instead of waiting for it
       --update          force cache update
       --smart-update    update only if sources changed
-      --clear           remove cache file
+      --clear[=TARGET]  remove cache|meta|snapshots|mirror|liveness|history|all
+                        (default cache; asks for confirmation)
+      --all             with --clear, same as --clear=all
+      --force           skip confirmations; allow an update to shrink
+                        the cache or a source drastically
+      --fail-fast       abort an update as soon as a source fails with a
+                        configuration error (e.g. rejected credentials)
+      --low-priority    run an update at nice 19 and idle IO priority
+                        (background mode on macOS and Windows), as the
+                        cron and Scheduled Task entries do
+      --jobs N          fetch at most N sources at once (default 8)
+      --strict          fail an update if any source fails
+      --min-sources N   fail an update if fewer than N sources succeed
+      --demote-dead     list symbol URLs that failed their last 3 checks
+                        (see verify-urls) last when merging
+      --vol3-compat VERSION
+                        write a cache volatility3 VERSION (2.0.0 or later)
+                        accepts, dropping symbol URLs it cannot use
+      --disk-index      also write a binary index of the cache, so lookups
+                        and serve read single entries instead of loading it
+      --only SELECTOR   with --update or --smart-update, refresh only the
+                        sources tagged tag=NAME or given by URL, keeping
+                        the others' last data (repeatable)
+      --refresh-source URL
+                        refetch the configured source URL (or path) and
+                        merge it with the others' last fetched data
       --init            create default config file
+      --preset NAME     with --init, choose the starter sources: minimal,
+                        full (default), or internal-template
       --setup           complete setup (recommended for first use)
-      --install-service install systemd timer for auto-updates
+      --install-service install auto-updates (systemd timer on Linux, or a
+                        crontab entry without systemd; launchd agent on
+                        macOS; Scheduled Task on Windows)
+      --splay DURATION  with --install-service or --setup, move the
+                        schedule from 06:00 by an offset within DURATION
+                        (at most 24h) derived from the hostname
+      --scheduler NAME  with --install-service or --setup, install with
+                        systemd, cron, launchd, or schtasks instead of the
+                        platform's default, or daemon to install nothing
+                        and leave updates to basar serve
       --configure-vol3  configure volatility3 to use basar
-  -v, --verbose         enable verbose output
+      --replace         with --configure-vol3, make the cache the only
+                        remote_isf_url instead of adding it to the list
+      --dry-run         with --update, --smart-update, --refresh-source,
+                        --setup, --init, --install-service, --configure-vol3,
+                        or --clear, print the sources it would fetch, the
+                        files it would write or remove, and the commands
+                        it would run, without changing anything
+  -v, --verbose         enable verbose output (same as --log-level info); on a
+                        terminal, updates also show each source's progress
+  -q, --quiet           print only what was asked for (the URI, path, stats,
+                        or JSON) and errors, for vol -u $(basar -q); logs
+                        still go to --log-file
+      --log-format F    log format: text (default) or json
+      --log-level L     log level: debug, info, warn (default), error
+      --log-file[=PATH] also append logs to PATH
+                        (default: $XDG_STATE_HOME/basar/basar.log)
+      --metrics-textfile PATH
+                        write Prometheus metrics to PATH after the run
+                        (for node_exporter's textfile collector)
+      --profile NAME    keep config, cache, and state in NAME subdirectories
+                        (e.g. ~/.cache/basar/NAME), apart from the default
+      --config FILE     read sources from FILE instead of sources.conf;
+                        hooks.d, overrides.json, overlay.json, notify.conf,
+                        proxy.conf, and the filters files are looked up
+                        next to it
+      --cache-dir DIR   keep the cache in DIR and its state in DIR/state,
+                        isolated from the default installation
+      --offline         forbid network access: update only local sources,
+                        keeping the others' last data, and use an expired
+                        cache as is
+      --fallback-cache-dir DIR
+                        when the cache dir is on a read-only filesystem,
+                        update into DIR ("tmpfs": a per-user dir in memory)
+                        and serve the read-only cache until then
+      --timeout DURATION
+                        give up once the whole invocation has run for
+                        DURATION (e.g. 2m), whatever it is waiting on
+                        (these six also work before a command: basar
+                        --profile NAME lookup ...)
+      --json            print the outcome of the action as JSON: its name,
+                        exit code, error, and result, e.g. per-source
+                        status and entry counts of an update; before a
+                        command, passes --json to it (basar --json doctor)
+      --version [--json]
+                        print the version, commit, build date, Go version,
+                        and platform; --json prints them as JSON
   -h, --help            show this help
 
 Environment:
   BASAR_TTL      cache TTL in seconds (default: 86400)
+  BASAR_SHRINK_THRESHOLD
+                 min % of current entries an update must keep (default: 50)
+  BASAR_SOURCE_SHRINK_THRESHOLD
+                 min % of its typical entries a source must return
+                 before it is flagged (default: 50)
   BASAR_VERBOSE  set to "1" for verbose output
+  BASAR_FAIL_FAST
+                 set to "1" to behave as --fail-fast
+  BASAR_LOW_PRIORITY
+                 set to "1" to behave as --low-priority
+  BASAR_JOBS     sources fetched at once (default: 8)
+  BASAR_STRICT   set to "1" to behave as --strict
+  BASAR_MIN_SOURCES
+                 default for --min-sources
+  BASAR_DEMOTE_DEAD
+                 set to "1" to behave as --demote-dead
+  BASAR_VOL3_COMPAT
+                 default for --vol3-compat
+  BASAR_DISK_INDEX
+                 set to "1" to behave as --disk-index
+  BASAR_TOMBSTONE_TTL
+                 keep banners dropped upstream this long (e.g. 168h)
+  BASAR_SPLAY    default for --splay (install-service and serve)
+  BASAR_SCHEDULER
+                 default for --scheduler
+  BASAR_STALE_WHILE_REVALIDATE
+                 set to "1" to behave as --stale-while-revalidate
+  BASAR_TRACK_USAGE
+                 set to "1" to record serve's reads of the cache, shown
+                 as last_used in --stats
+  BASAR_ACCESS_LOG
+                 web server access log (common or combined format) to
+                 find the last read of the cache in
+  BASAR_PROFILE  default for --profile
+  BASAR_CONFIG   default for --config
+  BASAR_CACHE_DIR
+                 default for --cache-dir
+  BASAR_OFFLINE  set to "1" to behave as --offline
+  BASAR_FALLBACK_CACHE_DIR
+                 default for --fallback-cache-dir
+  BASAR_SYSTEM_CACHE
+                 when to print the system-wide cache: auto (without a
+                 cache of your own, the default), prefer, or never
+  BASAR_SYSTEM_CACHE_DIR
+                 system-wide cache (default: /var/cache/basar)
+  BASAR_PROXY_PAC
+                 proxy auto-config file URL or path (over proxy.conf)
+  BASAR_NEGOTIATE_CMD
+                 command printing Kerberos tokens for auth=negotiate
+                 sources and proxies (built in on Windows)
+  NETRC          netrc file for auth=netrc sources and proxies
+                 (default: ~/.netrc)
+  GITHUB_TOKEN   token for github:// sources without a token_ option
+  NO_COLOR       set to turn off the colors of -v progress
+  BASAR_GITHUB_API
+                 GitHub Enterprise API URL for github:// sources
+  BASAR_WEBHOOK_SECRET
+                 secret for serve's /hooks/update (or --webhook-secret-file)
+
+Exit status: 0 success, 1 error, 2 invalid cache (-c), 3 updated but
+some sources failed. lookup: 0 exact match, 4 substring matches only,
+5 no match, 2 no cache.
 
 First time? Run:
   basar --setup
@@ -297,11 +1225,12 @@ This will:
   1. Create config file with default sources
   2. Download and cache ISF banners
   3. Configure volatility3 to use basar automatically
-  4. Install systemd timer for auto-updates (Linux)
+  4. Install auto-updates (systemd, launchd, or Scheduled Task)
 
 After setup, just run:
   volatility3 -f dump.raw linux.pslist
 
-Config: ~/.config/basar/sources.conf (one URL/path per line)
+Config: ~/.config/basar/sources.conf (one URL/path per line), plus
+        sources.conf.d/*.conf and /etc/basar beneath it
 `)
 }
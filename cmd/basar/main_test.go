@@ -3,10 +3,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/calilkhalil/basar/internal/cache"
 	"github.com/calilkhalil/basar/internal/fetcher"
@@ -17,11 +21,15 @@ type testEnv struct {
 	tmpDir     string
 	cacheDir   string
 	configDir  string
+	stateDir   string
 	cacheFile  string
 	configFile string
 	sourceFile string
+	systemDir  string
 	origCache  string
 	origConfig string
+	origState  string
+	origSystem string
 }
 
 // setup creates temporary directories and sets environment variables.
@@ -31,17 +39,23 @@ func (e *testEnv) setup(t *testing.T) {
 	e.tmpDir = t.TempDir()
 	e.cacheDir = filepath.Join(e.tmpDir, "cache")
 	e.configDir = filepath.Join(e.tmpDir, "config")
+	e.stateDir = filepath.Join(e.tmpDir, "state")
 	e.cacheFile = filepath.Join(e.cacheDir, "basar", "banners.json")
 	e.configFile = filepath.Join(e.configDir, "basar", "sources.conf")
 	e.sourceFile = filepath.Join(e.tmpDir, "source.json")
+	e.systemDir = filepath.Join(e.tmpDir, "system")
 
 	// Save original env
 	e.origCache = os.Getenv("XDG_CACHE_HOME")
 	e.origConfig = os.Getenv("XDG_CONFIG_HOME")
+	e.origState = os.Getenv("XDG_STATE_HOME")
+	e.origSystem = os.Getenv("BASAR_SYSTEM_CACHE_DIR")
 
 	// Set test env
 	os.Setenv("XDG_CACHE_HOME", e.cacheDir)
 	os.Setenv("XDG_CONFIG_HOME", e.configDir)
+	os.Setenv("XDG_STATE_HOME", e.stateDir)
+	os.Setenv("BASAR_SYSTEM_CACHE_DIR", e.systemDir)
 }
 
 // teardown restores environment variables.
@@ -57,6 +71,18 @@ func (e *testEnv) teardown() {
 	} else {
 		os.Unsetenv("XDG_CONFIG_HOME")
 	}
+
+	if e.origState != "" {
+		os.Setenv("XDG_STATE_HOME", e.origState)
+	} else {
+		os.Unsetenv("XDG_STATE_HOME")
+	}
+
+	if e.origSystem != "" {
+		os.Setenv("BASAR_SYSTEM_CACHE_DIR", e.origSystem)
+	} else {
+		os.Unsetenv("BASAR_SYSTEM_CACHE_DIR")
+	}
 }
 
 // createSource creates a test source file with sample banner data.
@@ -135,10 +161,57 @@ func TestParseFlags(t *testing.T) {
 			args: []string{},
 			check: func(f *Flags) bool {
 				return !f.Path && !f.URI && !f.Stats && !f.Check &&
-					!f.Update && !f.Clear && !f.Init && !f.Verbose && !f.Help &&
+					!f.Update && f.Clear == "" && !f.Init && !f.Verbose && !f.Help &&
 					!f.SmartUpdate && !f.Setup && !f.InstallService && !f.ConfigureVol3
 			},
 		},
+		{
+			name:  "fail fast",
+			args:  []string{"--update", "--fail-fast"},
+			check: func(f *Flags) bool { return f.Update && f.FailFast },
+		},
+		{
+			name:  "jobs",
+			args:  []string{"--update", "--jobs", "3"},
+			check: func(f *Flags) bool { return f.Update && f.Jobs == 3 },
+		},
+		{
+			name:  "strict and min sources",
+			args:  []string{"--update", "--strict", "--min-sources", "2"},
+			check: func(f *Flags) bool { return f.Strict && f.MinSources == 2 },
+		},
+		{
+			name:  "only repeated",
+			args:  []string{"--update", "--only", "tag=ubuntu", "--only=https://example.com/b.json"},
+			check: func(f *Flags) bool { return slices.Equal(f.Only, []string{"tag=ubuntu", "https://example.com/b.json"}) },
+		},
+		{
+			name:  "splay",
+			args:  []string{"--install-service", "--splay", "4h"},
+			check: func(f *Flags) bool { return f.InstallService && f.Splay == 4*time.Hour },
+		},
+		{
+			name: "config overrides",
+			args: []string{"--update", "--config", "/tmp/case/sources.conf", "--cache-dir", "/tmp/case/cache"},
+			check: func(f *Flags) bool {
+				return f.Overrides.ConfigFile == "/tmp/case/sources.conf" && f.Overrides.CacheDir == "/tmp/case/cache"
+			},
+		},
+		{
+			name:  "offline",
+			args:  []string{"--offline", "--update"},
+			check: func(f *Flags) bool { return f.Update && f.Overrides.Offline },
+		},
+		{
+			name:  "fallback cache dir",
+			args:  []string{"--update", "--fallback-cache-dir", "tmpfs"},
+			check: func(f *Flags) bool { return f.Update && f.Overrides.FallbackCacheDir == "tmpfs" },
+		},
+		{
+			name:  "profile",
+			args:  []string{"--update", "--profile", "work"},
+			check: func(f *Flags) bool { return f.Update && f.Overrides.Profile == "work" },
+		},
 		{
 			name:  "help short",
 			args:  []string{"-h"},
@@ -202,7 +275,27 @@ func TestParseFlags(t *testing.T) {
 		{
 			name:  "clear",
 			args:  []string{"--clear"},
-			check: func(f *Flags) bool { return f.Clear },
+			check: func(f *Flags) bool { return f.Clear == "cache" },
+		},
+		{
+			name:  "clear target",
+			args:  []string{"--clear=meta"},
+			check: func(f *Flags) bool { return f.Clear == "meta" },
+		},
+		{
+			name:  "clear separate target",
+			args:  []string{"--clear", "snapshots"},
+			check: func(f *Flags) bool { return f.Clear == "snapshots" },
+		},
+		{
+			name:  "force",
+			args:  []string{"--force"},
+			check: func(f *Flags) bool { return f.Force },
+		},
+		{
+			name:  "clear all",
+			args:  []string{"--clear", "--all"},
+			check: func(f *Flags) bool { return f.Clear == "all" },
 		},
 		{
 			name:  "init",
@@ -246,6 +339,21 @@ func TestParseFlags(t *testing.T) {
 				return f.Verbose && f.Stats
 			},
 		},
+		{
+			name:  "log flags",
+			args:  []string{"--log-format", "json", "--log-level", "debug"},
+			check: func(f *Flags) bool { return f.LogFormat == "json" && f.LogLevel == "debug" },
+		},
+		{
+			name:  "bare log-file",
+			args:  []string{"--log-file"},
+			check: func(f *Flags) bool { return f.LogFile == "true" },
+		},
+		{
+			name:  "log-file with path",
+			args:  []string{"--log-file=/tmp/basar.log"},
+			check: func(f *Flags) bool { return f.LogFile == "/tmp/basar.log" },
+		},
 		{
 			name:    "unknown flag",
 			args:    []string{"--unknown"},
@@ -341,10 +449,10 @@ func TestRunClear(t *testing.T) {
 	env.createCache(t)
 
 	var stdout, stderr bytes.Buffer
-	code := run([]string{"--clear"}, &stdout, &stderr)
+	code := run([]string{"--clear", "--force"}, &stdout, &stderr)
 
 	if code != exitOK {
-		t.Errorf("run(--clear) = %d, expected %d; stderr: %s", code, exitOK, stderr.String())
+		t.Errorf("run(--clear --force) = %d, expected %d; stderr: %s", code, exitOK, stderr.String())
 	}
 
 	// Verify cache was removed
@@ -353,6 +461,187 @@ func TestRunClear(t *testing.T) {
 	}
 }
 
+// withStdin simulates a terminal (or not) answering confirmations.
+func withStdin(t *testing.T, terminal bool, input string) {
+	t.Helper()
+
+	origStdin, origIsTerminal := stdin, stdinIsTerminal
+	stdin = strings.NewReader(input)
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() {
+		stdin, stdinIsTerminal = origStdin, origIsTerminal
+	})
+}
+
+func TestRunClearRequiresForce(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createCache(t)
+	withStdin(t, false, "")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--clear"}, &stdout, &stderr)
+
+	if code != exitError {
+		t.Errorf("run(--clear) without a terminal = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "--force") {
+		t.Errorf("stderr should suggest --force, got: %s", stderr.String())
+	}
+	if _, err := os.Stat(env.cacheFile); err != nil {
+		t.Error("cache file should be kept")
+	}
+}
+
+func TestRunClearConfirm(t *testing.T) {
+	tests := []struct {
+		name       string
+		answer     string
+		wantCode   int
+		wantExists bool
+	}{
+		{"confirmed", "y\n", exitOK, false},
+		{"declined", "n\n", exitError, true},
+		{"empty answer", "\n", exitError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &testEnv{}
+			env.setup(t)
+			defer env.teardown()
+
+			env.createCache(t)
+			withStdin(t, true, tt.answer)
+
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"--clear"}, &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Errorf("run(--clear) = %d, expected %d", code, tt.wantCode)
+			}
+			if _, err := os.Stat(env.cacheFile); (err == nil) != tt.wantExists {
+				t.Errorf("cache exists = %v, expected %v", err == nil, tt.wantExists)
+			}
+		})
+	}
+}
+
+func TestRunClearAll(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	cacheDir := filepath.Dir(env.cacheFile)
+	stateDir := filepath.Join(env.stateDir, "basar")
+	if code := run([]string{"--clear", "--all", "--force"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--clear --all --force) = %d; stderr: %s", code, stderr.String())
+	}
+
+	for _, path := range []string{
+		filepath.Join(cacheDir, "banners.json"),
+		filepath.Join(cacheDir, "provenance.json"),
+		filepath.Join(stateDir, "meta.json"),
+		filepath.Join(stateDir, "snapshots"),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+}
+
+func TestRunClearMeta(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stateDir := filepath.Join(env.stateDir, "basar")
+	if code := run([]string{"--clear", "meta", "--force"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--clear meta --force) = %d; stderr: %s", code, stderr.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(stateDir, "meta.json")); !os.IsNotExist(err) {
+		t.Error("meta.json should be removed")
+	}
+	for _, path := range []string{env.cacheFile, filepath.Join(stateDir, "snapshots")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should be kept: %v", path, err)
+		}
+	}
+}
+
+func TestRunClearUnknownTarget(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--clear=everything", "--force"}, &stdout, &stderr)
+
+	if code != exitError {
+		t.Errorf("run(--clear=everything) = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "unknown clear target") {
+		t.Errorf("stderr should name the bad target, got: %s", stderr.String())
+	}
+}
+
+func TestRunPresetRequiresInit(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--preset", "minimal"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--preset minimal) = %d, expected %d", code, exitError)
+	}
+}
+
+func TestRunReplaceRequiresConfigureVol3(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--replace"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--replace) = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "--configure-vol3") {
+		t.Errorf("stderr should name --configure-vol3, got: %s", stderr.String())
+	}
+}
+
+func TestRunMetricsTextfile(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	path := filepath.Join(t.TempDir(), "basar.prom")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--smart-update", "--metrics-textfile", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--smart-update --metrics-textfile) = %d; stderr: %s", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file not written: %v", err)
+	}
+	for _, want := range []string{"basar_cache_entries ", "basar_last_update_success 1", "basar_source_up{source="} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("metrics file missing %q, got:\n%s", want, data)
+		}
+	}
+}
+
 func TestRunCheckValid(t *testing.T) {
 	env := &testEnv{}
 	env.setup(t)
@@ -478,6 +767,96 @@ func TestRunUpdateVerbose(t *testing.T) {
 	}
 }
 
+func TestRunUpdateJSONLogs(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	logFile := filepath.Join(env.tmpDir, "state", "basar.log")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--update", "--log-format", "json", "--log-level", "info", "--log-file=" + logFile}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run(--update --log-format json) = %d; stderr: %s", code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if len(lines) == 0 {
+		t.Fatal("expected log records on stderr")
+	}
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("log line is not JSON: %q", line)
+		}
+	}
+
+	if _, err := os.Stat(logFile); err != nil {
+		t.Errorf("log file should be written: %v", err)
+	}
+}
+
+func TestRunInvalidLogFormat(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-c", "--log-format", "xml"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--log-format xml) = %d, expected %d", code, exitError)
+	}
+}
+
+func TestRunInvalidVol3Compat(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update", "--vol3-compat", "1.2.0"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--vol3-compat 1.2.0) = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "predates remote ISF") {
+		t.Errorf("stderr = %q, expected the reason", stderr.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // a mirror that never answers
+	}))
+	defer server.Close()
+	_ = os.MkdirAll(filepath.Dir(env.configFile), 0755)
+	if err := os.WriteFile(env.configFile, []byte(server.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	if code := run([]string{"--timeout", "200ms", "--update"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--timeout 200ms --update) = %d, expected %d", code, exitError)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run() took %s despite --timeout", elapsed)
+	}
+	if !strings.Contains(stderr.String(), "timed out after 200ms") {
+		t.Errorf("stderr = %q, expected the timeout", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"--timeout", "-1s", "--update"}, &stdout, &stderr); code != exitError ||
+		!strings.Contains(stderr.String(), "invalid duration") {
+		t.Errorf("run(--timeout -1s) = %d, stderr %q", code, stderr.String())
+	}
+}
+
 func TestRunUpdateNoSources(t *testing.T) {
 	env := &testEnv{}
 	env.setup(t)
@@ -496,6 +875,120 @@ func TestRunUpdateNoSources(t *testing.T) {
 	}
 }
 
+func TestRunUpdatePartial(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	configDir := filepath.Dir(env.configFile)
+	_ = os.MkdirAll(configDir, 0755)
+	_ = os.WriteFile(env.configFile, []byte(env.sourceFile+"\n/nonexistent/file.json\n"), 0644)
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"--update"}, exitPartial},
+		{[]string{"--smart-update"}, exitPartial},
+		{[]string{"--update", "--min-sources", "2"}, exitError},
+		{[]string{"--update", "--strict"}, exitError},
+		{[]string{"--update", "--only", env.sourceFile}, exitOK},
+		{[]string{"--update", "--only", "tag=none"}, exitError},
+		{[]string{"--only", env.sourceFile}, exitError},
+		{[]string{"--refresh-source", env.sourceFile}, exitOK},
+		{[]string{"--refresh-source", "/not/configured.json"}, exitError},
+		{[]string{"--install-service", "--splay", "25h"}, exitError},
+		{[]string{"--stats", "--time-format", "unix"}, exitOK},
+		{[]string{"--stats", "--time-format", "iso"}, exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.want {
+				t.Errorf("run(%v) = %d, expected %d; stderr: %s", tt.args, code, tt.want, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunConfigOverrides(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	confFile := filepath.Join(env.tmpDir, "case", "sources.conf")
+	cacheDir := filepath.Join(env.tmpDir, "case", "cache")
+	_ = os.MkdirAll(filepath.Dir(confFile), 0755)
+	_ = os.WriteFile(confFile, []byte(env.sourceFile+"\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update", "--config", confFile, "--cache-dir", cacheDir}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update --config --cache-dir) = %d; stderr: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "banners.json")); err != nil {
+		t.Errorf("cache should be written to --cache-dir: %v", err)
+	}
+	if _, err := os.Stat(env.cacheFile); !os.IsNotExist(err) {
+		t.Errorf("default cache should be untouched, got %v", err)
+	}
+
+	// Before a command, after it, and from the environment
+	for _, args := range [][]string{
+		{"--cache-dir", cacheDir, "lookup", "6.1.0"},
+		{"lookup", "6.1.0", "--cache-dir", cacheDir},
+	} {
+		stdout.Reset()
+		if code := run(args, &stdout, &stderr); code != exitFuzzy {
+			t.Errorf("run(%v) = %d; stderr: %s", args, code, stderr.String())
+		}
+	}
+
+	t.Setenv("BASAR_CACHE_DIR", cacheDir)
+	stdout.Reset()
+	if code := run([]string{"-p"}, &stdout, &stderr); code != exitOK || strings.TrimSpace(stdout.String()) != filepath.Join(cacheDir, "banners.json") {
+		t.Errorf("run(-p) with BASAR_CACHE_DIR = %d, %q", code, stdout.String())
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	profileConfig := filepath.Join(env.configDir, "basar", "work", "sources.conf")
+	_ = os.MkdirAll(filepath.Dir(profileConfig), 0755)
+	_ = os.WriteFile(profileConfig, []byte(env.sourceFile+"\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update", "--profile", "work"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update --profile work) = %d; stderr: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(env.cacheDir, "basar", "work", "banners.json")); err != nil {
+		t.Errorf("profile cache should be written: %v", err)
+	}
+	if _, err := os.Stat(env.cacheFile); !os.IsNotExist(err) {
+		t.Errorf("default cache should be untouched, got %v", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--profile", "work", "lookup", "6.1.0"}, &stdout, &stderr); code != exitFuzzy {
+		t.Errorf("run(--profile work lookup) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-s", "--profile", "work"}, &stdout, &stderr); code != exitOK || !strings.Contains(stdout.String(), `"profile": "work"`) {
+		t.Errorf("run(-s --profile work) = %d, output: %s", code, stdout.String())
+	}
+
+	if code := run([]string{"--update", "--profile", "../x"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--profile ../x) = %d, expected %d", code, exitError)
+	}
+}
+
 func TestRunPath(t *testing.T) {
 	env := &testEnv{}
 	env.setup(t)
@@ -537,6 +1030,66 @@ func TestRunPathNoCache(t *testing.T) {
 	}
 }
 
+func TestRunSystemCache(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	// The source is missing, so printing anything but the system cache
+	// would have to fail updating the user's
+	configDir := filepath.Dir(env.configFile)
+	_ = os.MkdirAll(configDir, 0755)
+	_ = os.WriteFile(env.configFile, []byte("/nonexistent/file.json\n"), 0644)
+	system := filepath.Join(env.systemDir, "banners.json")
+	if err := os.MkdirAll(env.systemDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(system, []byte(`{"version": 1, "linux": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-p"}, &stdout, &stderr); code != exitOK || strings.TrimSpace(stdout.String()) != system {
+		t.Errorf("run(-p) = %d, %q; expected the system cache %s; stderr: %s", code, stdout.String(), system, stderr.String())
+	}
+	if _, err := os.Stat(env.cacheFile); !os.IsNotExist(err) {
+		t.Errorf("the user's cache should not be created, got %v", err)
+	}
+
+	t.Setenv("BASAR_SYSTEM_CACHE", "never")
+	stdout.Reset()
+	if code := run([]string{"-p"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(-p) with BASAR_SYSTEM_CACHE=never = %d, expected %d", code, exitError)
+	}
+}
+
+func TestRunOverlay(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+	env.createCache(t)
+	overlay := filepath.Join(filepath.Dir(env.configFile), "overlay.json")
+	if err := os.WriteFile(overlay, []byte(`{"version": 1, "linux": {"Linux version 6.9.0-custom": ["file:///srv/isf/6.9.0.json.xz"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-p"}, &stdout, &stderr); code != exitOK || !strings.HasSuffix(strings.TrimSpace(stdout.String()), "layered.json") {
+		t.Errorf("run(-p) with an overlay = %d, %q; expected the layered view; stderr: %s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"lookup", "-q", "Linux version 6.9.0-custom"}, &stdout, &stderr); code != exitOK ||
+		strings.TrimSpace(stdout.String()) != "file:///srv/isf/6.9.0.json.xz" {
+		t.Errorf("run(lookup) of an overlay banner = %d, %q; stderr: %s", code, stdout.String(), stderr.String())
+	}
+	if raw, err := os.ReadFile(env.cacheFile); err != nil || strings.Contains(string(raw), "6.9.0-custom") {
+		t.Errorf("the overlay must not be written into the cache: %v", err)
+	}
+}
+
 func TestRunURI(t *testing.T) {
 	env := &testEnv{}
 	env.setup(t)
@@ -617,6 +1170,72 @@ func TestRunVerboseFromEnv(t *testing.T) {
 	}
 }
 
+func TestRunQuiet(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	configDir := filepath.Dir(env.configFile)
+	_ = os.MkdirAll(configDir, 0755)
+	_ = os.WriteFile(env.configFile, []byte(env.sourceFile+"\n/nonexistent/file.json\n"), 0644)
+
+	tests := []struct {
+		args   []string
+		want   int
+		stdout string
+	}{
+		{[]string{"-q", "-v", "--update"}, exitPartial, ""},
+		{[]string{"-q"}, exitOK, "file://"},
+		{[]string{"--quiet", "--path"}, exitOK, env.cacheFile},
+		{[]string{"-q", "--check"}, exitOK, ""},
+		{[]string{"-q", "--init"}, exitError, ""},
+		{[]string{"-q", "--clear", "--force"}, exitOK, ""},
+		{[]string{"-q", "--check"}, exitInvalid, ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, &stdout, &stderr)
+		if code != tt.want {
+			t.Errorf("run(%v) = %d, expected %d; stderr: %s", tt.args, code, tt.want, stderr.String())
+		}
+		if out := strings.TrimSpace(stdout.String()); !strings.HasPrefix(out, tt.stdout) || (tt.stdout == "" && out != "") {
+			t.Errorf("run(%v) stdout = %q, expected %q", tt.args, out, tt.stdout)
+		}
+		if code != exitError && stderr.Len() > 0 {
+			t.Errorf("run(%v) stderr = %q, expected nothing", tt.args, stderr.String())
+		}
+		if code == exitError && !strings.HasPrefix(stderr.String(), "basar: ") {
+			t.Errorf("run(%v) stderr = %q, expected the error", tt.args, stderr.String())
+		}
+	}
+}
+
+func TestRunScheduler(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--install-service", "--scheduler", "daemon"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--install-service --scheduler daemon) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "nothing installed; run basar serve") {
+		t.Errorf("stdout = %q, expected a note about basar serve", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	t.Setenv("BASAR_SCHEDULER", "at")
+	if code := run([]string{"--install-service"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(--install-service) with BASAR_SCHEDULER=at = %d, expected %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), `unknown scheduler "at"`) {
+		t.Errorf("stderr = %q, expected the unknown scheduler", stderr.String())
+	}
+}
+
 func TestRunInvalidFlag(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	code := run([]string{"--invalid-flag"}, &stdout, &stderr)
@@ -646,11 +1265,82 @@ func TestPrintUsage(t *testing.T) {
 		"--update",
 		"--smart-update",
 		"--clear",
+		"--all",
+		"--force",
+		"--fail-fast",
+		"--low-priority",
+		"--jobs",
+		"--strict",
+		"--min-sources",
 		"--init",
+		"--preset",
 		"--setup",
 		"--install-service",
 		"--configure-vol3",
+		"--replace",
+		"--dry-run         with --update",
 		"--verbose",
+		"-q, --quiet",
+		"--log-format",
+		"--log-level",
+		"--log-file",
+		"--metrics-textfile",
+		"--profile",
+		"--config",
+		"--cache-dir",
+		"BASAR_CACHE_DIR",
+		"serve",
+		"--serve-profile NAME[,interval=D][,path=/P]",
+		"airgap keygen|pack|verify|unpack",
+		"merge [-o FILE] SOURCE...",
+		"--version",
+		"--json            print the outcome of the action as JSON",
+		"help [--json]",
+		"capabilities",
+		"doctor",
+		"verify-urls",
+		"prune --check-urls",
+		"publish --oci",
+		"export",
+		"report",
+		"--demote-dead",
+		"--disk-index",
+		"--only SELECTOR",
+		"--refresh-source URL",
+		"--splay DURATION",
+		"--scheduler NAME",
+		"--time-format F",
+		"--stale-while-revalidate",
+		"BASAR_STALE_WHILE_REVALIDATE",
+		"--offline",
+		"BASAR_OFFLINE",
+		"BASAR_PROXY_PAC",
+		"BASAR_NEGOTIATE_CMD",
+		"NETRC",
+		"GITHUB_TOKEN",
+		"NO_COLOR",
+		"BASAR_GITHUB_API",
+		"--fallback-cache-dir DIR",
+		"BASAR_FALLBACK_CACHE_DIR",
+		"import BUNDLE",
+		"--format html|bundle",
+		"mirror [--dest DIR]",
+		"prefetch [--symbols-dir DIR]",
+		"sync-symbols [--mirror DIR] [--symbols-dir DIR]",
+		"lookup [-i] [-E|-F] [--release] [--provenance] [--metadata] [--trust] [-q]",
+		"lookup: 0 exact match",
+		"resolve [--fetch]",
+		"--vol3-compat VERSION",
+		"BASAR_VOL3_COMPAT",
+		"gen-fixture [--entries N]",
+		"filter [--include PATTERN]",
+		"--timeout DURATION",
+		"BASAR_TOMBSTONE_TTL",
+		"BASAR_SPLAY",
+		"BASAR_SCHEDULER",
+		"--webhook-secret-file",
+		"BASAR_WEBHOOK_SECRET",
+		"BASAR_DISK_INDEX",
 		"--help",
 		"BASAR_TTL",
 		"BASAR_VERBOSE",
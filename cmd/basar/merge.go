@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// runMerge implements "basar merge [-o FILE] SOURCE...": it merges the
+// banner indexes of the given sources, - for stdin, and writes the result
+// to stdout or FILE, without reading the configured sources or the cache.
+func runMerge(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("merge")
+	overrideFlags(fs, &o)
+
+	var output string
+	fs.StringVar(&output, "o", "", "")
+	fs.StringVar(&output, "output", "", "")
+
+	sources, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if len(sources) == 0 {
+		fmt.Fprintln(stderr, "basar: merge takes one or more sources (or - for stdin)")
+		return exitError
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	f := fetcher.New()
+	f.SetOffline(config.NewWith(o).Offline)
+	results := f.FetchAll(ctx, sources)
+	datasets := make([]*fetcher.BannerData, len(results))
+	failed := false
+	for i, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(stderr, "basar: %s: %v\n", r.Source, r.Err)
+			failed = true
+		}
+		datasets[i] = r.Data
+	}
+	if failed {
+		return exitError
+	}
+
+	merged := fetcher.Merge(datasets)
+	merged.Metadata = fetcher.MergeMetadata(datasets)
+
+	// Render fully before touching FILE so a failure leaves it intact
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(merged); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if output == "" {
+		if _, err := buf.WriteTo(stdout); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// pipeStdin replaces os.Stdin with a file holding content until the test
+// ends.
+func pipeStdin(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = orig
+		f.Close()
+	})
+}
+
+func TestRunMerge(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	env.createSource(t)
+
+	pipeStdin(t, `{"version": 1, "linux": {"Linux version 6.1.0-generic": ["https://ci.example.com/6.1.0.json"], "Linux version 6.8.0-custom": ["https://ci.example.com/6.8.0.json"]}}`)
+	out := filepath.Join(t.TempDir(), "merged.json")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"merge", "-", env.sourceFile, "-o", out}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(merge) = %d; stderr: %s", code, stderr.String())
+	}
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var merged fetcher.BannerData
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		t.Fatalf("merged output is not an index: %v\n%s", err, raw)
+	}
+	if len(merged.Linux) != 3 || len(merged.Linux["Linux version 6.1.0-generic"]) != 2 {
+		t.Errorf("merged index = %v, expected three banners, 6.1.0 from both sources", merged.Linux)
+	}
+
+	// A failing source fails the merge, leaving the output alone
+	stderr.Reset()
+	if code := run([]string{"merge", env.sourceFile, filepath.Join(t.TempDir(), "missing.json"), "-o", out}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(merge) with a missing source = %d, expected %d", code, exitError)
+	}
+	if after, _ := os.ReadFile(out); !bytes.Equal(after, raw) {
+		t.Error("failed merge changed the output file")
+	}
+
+	if code := run([]string{"merge"}, &stdout, &stderr); code != exitError || !strings.Contains(stderr.String(), "one or more sources") {
+		t.Errorf("run(merge) without sources = %d; stderr: %s", code, stderr.String())
+	}
+}
+
+func TestRunUpdateStdinSource(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	env.createSource(t)
+
+	if err := os.MkdirAll(filepath.Dir(env.configFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env.configFile, []byte(env.sourceFile+"\n-\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pipeStdin(t, `{"version": 1, "linux": {"Linux version 6.8.0-custom": ["https://ci.example.com/6.8.0.json"]}}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+	raw, err := os.ReadFile(env.cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "6.8.0-custom") || !strings.Contains(string(raw), "5.15.0-generic") {
+		t.Errorf("cache = %s, expected the piped banner merged with the source's", raw)
+	}
+}
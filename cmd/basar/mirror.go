@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runMirror implements "basar mirror [--dest DIR] [--match TEXT]...
+// [--json]": it downloads the symbol files of the cached banners matching
+// any TEXT (all banners by default) and writes a banners.json pointing at
+// them, for volatility3 without network access.
+func runMirror(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("mirror")
+	overrideFlags(fs, &o)
+
+	var dest string
+	var matches []string
+	var asJSON bool
+	fs.StringVar(&dest, "dest", "", "")
+	fs.Var(stringList{&matches}, "match", "")
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: mirror takes no arguments, got %q (filter with --match)\n", fs.Arg(0))
+		return exitError
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	res, err := cache.New(config.NewWith(o)).Mirror(ctx, dest, matches)
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	if asJSON {
+		if err := writeJSON(stdout, res, "both"); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, f := range res.Failed {
+			fmt.Fprintf(stderr, "basar: %s: %s\n", f.Banner, f.Error)
+		}
+		fmt.Fprintf(stdout, "%d banners mirrored in %s (%d files, %d bytes downloaded)\n",
+			res.Banners, res.Dest, res.Downloaded, res.Bytes)
+		fmt.Fprintf(stdout, "use it with: volatility3 -u %s\n", res.IndexURI)
+	}
+
+	switch {
+	case len(res.Failed) > 0 && res.Banners == 0:
+		return exitError
+	case len(res.Failed) > 0:
+		return exitPartial
+	}
+	return exitOK
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunMirror(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "missing") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cache := `{"version":1,"linux":{"Linux version 5.15.0-generic":["` + server.URL + `/5.15.json.xz"],` +
+		`"Linux version 6.1.0-generic":["` + server.URL + `/missing.json.xz"]}}`
+	if err := os.WriteFile(env.cacheFile, []byte(cache), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(env.tmpDir, "mirror")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"mirror", "--dest", dest, "--match", "5.15.0"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(mirror --match) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 banners mirrored") || !strings.Contains(stdout.String(), "banners.json") {
+		t.Errorf("mirror output = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"mirror", "--dest", dest}, &stdout, &stderr); code != exitPartial {
+		t.Errorf("run(mirror) with a failing banner = %d, expected %d", code, exitPartial)
+	}
+	if !strings.Contains(stderr.String(), "6.1.0-generic") {
+		t.Errorf("the failing banner should be reported, got %q", stderr.String())
+	}
+
+	if code := run([]string{"mirror", "5.15"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(mirror 5.15) = %d, expected %d", code, exitError)
+	}
+}
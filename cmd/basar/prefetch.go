@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// kernelBanner returns the running kernel's banner; tests replace it.
+var kernelBanner = cache.KernelBanner
+
+// runPrefetch implements "basar prefetch [--symbols-dir DIR] [--json]
+// [BANNER]...": it downloads the symbol files of the running kernel and of
+// any BANNER into the volatility3 symbols directory, so volatility3 can
+// analyse those kernels' memory without network access.
+func runPrefetch(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("prefetch")
+	overrideFlags(fs, &o)
+
+	var dir string
+	var asJSON bool
+	fs.StringVar(&dir, "symbols-dir", "", "")
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	queries, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	banner, err := kernelBanner()
+	switch {
+	case err == nil:
+		queries = append([]string{banner}, queries...)
+	case len(queries) == 0:
+		fmt.Fprintf(stderr, "basar: %v; give the banners to prefetch\n", err)
+		return exitError
+	default:
+		fmt.Fprintf(stderr, "basar: %v; prefetching the given banners only\n", err)
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	res, err := cache.New(config.NewWith(o)).Prefetch(ctx, dir, queries)
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		if dir == "" {
+			fmt.Fprintln(stderr, "basar: pass --symbols-dir to choose where the symbol files go")
+		}
+		return exitError
+	}
+
+	if asJSON {
+		if err := writeJSON(stdout, res, "both"); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, q := range res.Unmatched {
+			fmt.Fprintf(stderr, "basar: no cached banner matches %q\n", q)
+		}
+		for _, f := range res.Failed {
+			fmt.Fprintf(stderr, "basar: %s: %s\n", f.Banner, f.Error)
+		}
+		for _, f := range res.Files {
+			fmt.Fprintln(stdout, f.Path)
+		}
+		fmt.Fprintf(stdout, "%d symbol files in %s (%d downloaded, %d bytes)\n",
+			len(res.Files), res.Dir, res.Downloaded, res.Bytes)
+		if dir != "" {
+			fmt.Fprintf(stdout, "use them with: volatility3 -s %s\n", res.Dir)
+		}
+	}
+
+	switch {
+	case len(res.Files) == 0:
+		return exitError
+	case len(res.Failed) > 0 || len(res.Unmatched) > 0:
+		return exitPartial
+	}
+	return exitOK
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestRunPrefetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	old := kernelBanner
+	defer func() { kernelBanner = old }()
+	kernelBanner = func() (string, error) { return "Linux version 5.15.0-generic (buildd) #1 SMP", nil }
+
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"version":1,"linux":{"Linux version 5.15.0-generic (buildd) #1 SMP\n\u0000":["` + server.URL + `/5.15.json.xz"],` +
+		`"Linux version 6.1.0-generic (debian) #1 SMP\n\u0000":["` + server.URL + `/6.1.json.xz"]}}`
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(env.tmpDir, "symbols")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"prefetch", "--symbols-dir", dir}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(prefetch) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), filepath.Join(dir, "linux", "5.15.json.xz")) ||
+		!strings.Contains(stdout.String(), "volatility3 -s "+dir) {
+		t.Errorf("prefetch output = %q", stdout.String())
+	}
+
+	// Given banners are prefetched too; unknown ones make it partial
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"prefetch", "6.1.0-generic", "--symbols-dir", dir, "4.19.0"}, &stdout, &stderr); code != exitPartial {
+		t.Errorf("run(prefetch 6.1.0 4.19.0) = %d, expected %d", code, exitPartial)
+	}
+	if !strings.Contains(stdout.String(), "2 symbol files") || !strings.Contains(stderr.String(), `"4.19.0"`) {
+		t.Errorf("prefetch output = %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	// Without the running kernel's banner, only the given banners
+	kernelBanner = func() (string, error) { return "", cache.ErrNoKernelBanner }
+	stderr.Reset()
+	if code := run([]string{"prefetch", "--symbols-dir", dir}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(prefetch) without a kernel banner = %d, expected %d", code, exitError)
+	}
+	if code := run([]string{"prefetch", "--symbols-dir", dir, "6.1.0"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run(prefetch 6.1.0) without a kernel banner = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "given banners only") {
+		t.Errorf("stderr = %q, expected a note about the kernel banner", stderr.String())
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "log/slog"
+
+// priorityLoweredEnv marks a process started at low priority by
+// lowerPriority, so the process it re-executes does not do it again.
+const priorityLoweredEnv = "BASAR_PRIORITY_LOWERED"
+
+// enterLowPriority moves the process to the lowest CPU and IO priority
+// the platform offers, so a background update or serve does not compete
+// with analysis running on the same machine. Schedulers with their own
+// settings (the systemd unit, the launchd agent) need not ask for it; it
+// is for cron, Scheduled Tasks, and other supervisors. A failure is
+// logged and the process carries on at its priority.
+func enterLowPriority(logger *slog.Logger) {
+	if err := lowerPriority(); err != nil {
+		logger.Warn("lowering priority failed; running at normal priority", "error", err)
+		return
+	}
+	logger.Debug("running at low priority")
+}
@@ -0,0 +1,24 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// The setpriority(2) arguments marking the process as background, which
+// also throttles its disk and network IO.
+const (
+	prioDarwinProcess = 4
+	prioDarwinBG      = 0x1000
+)
+
+// lowerPriority marks the process as background, as the launchd agent's
+// ProcessType does.
+func lowerPriority() error {
+	if err := syscall.Setpriority(prioDarwinProcess, 0, prioDarwinBG); err != nil {
+		return fmt.Errorf("entering background mode: %w", err)
+	}
+	return nil
+}
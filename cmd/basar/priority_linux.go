@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// The arguments of ioprio_set(2) putting the caller in the idle IO
+// scheduling class.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// lowerPriority sets a nice value of 19 and the idle IO scheduling class.
+// Linux keeps both per thread and the Go runtime has started several
+// already, so they are set on the calling thread and the binary is
+// re-executed from it with the same arguments: the new process inherits
+// them on every thread. It only returns if that fails.
+func lowerPriority() error {
+	if os.Getenv(priorityLoweredEnv) == "1" {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating basar: %w", err)
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19); err != nil {
+		return fmt.Errorf("setting nice value: %w", err)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, ioprioClassIdle<<ioprioClassShift); errno != 0 {
+		return fmt.Errorf("setting IO class: %w", errno)
+	}
+	err = syscall.Exec(exe, os.Args, append(os.Environ(), priorityLoweredEnv+"=1"))
+	return fmt.Errorf("re-executing at low priority: %w", err)
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+// lowerPriority is not supported where processes have no priority to
+// lower.
+func lowerPriority() error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestEnterLowPriority(t *testing.T) {
+	// Marked as lowered already, so Linux does not re-execute the test
+	t.Setenv(priorityLoweredEnv, "1")
+
+	var buf bytes.Buffer
+	enterLowPriority(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if strings.Contains(buf.String(), "failed") {
+		t.Errorf("enterLowPriority() logged %q", buf.String())
+	}
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--smart-update", "--low-priority"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run(--smart-update --low-priority) = %d; stderr: %s", code, stderr.String())
+	}
+}
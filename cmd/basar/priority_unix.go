@@ -0,0 +1,17 @@
+//go:build unix && !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// lowerPriority sets a nice value of 19 for the process. These systems
+// have no IO priority to lower.
+func lowerPriority() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19); err != nil {
+		return fmt.Errorf("setting nice value: %w", err)
+	}
+	return nil
+}
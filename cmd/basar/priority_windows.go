@@ -0,0 +1,26 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// processModeBackgroundBegin has SetPriorityClass lower the CPU, IO, and
+// memory priority of the whole process.
+const processModeBackgroundBegin = 0x00100000
+
+var setPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+
+// lowerPriority puts the process in background processing mode.
+func lowerPriority() error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	if ok, _, err := setPriorityClass.Call(uintptr(process), processModeBackgroundBegin); ok == 0 {
+		return fmt.Errorf("entering background mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ANSI sequences of the progress display.
+const (
+	ansiClearLine = "\r\x1b[K"
+	ansiReset     = "\x1b[0m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiDim       = "\x1b[2m"
+)
+
+// maxStatusSource bounds the source named on the status line.
+const maxStatusSource = 40
+
+// spinnerFrames animate the status line.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// isTerminal reports whether w is a terminal that understands ANSI
+// sequences.
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && enableANSI(f)
+}
+
+// showProgress reports whether verbose updates draw progress on stderr:
+// only on a terminal, with text logs, and not with --quiet.
+func showProgress(flags *Flags, stderr io.Writer) bool {
+	return flags.Verbose && !flags.Quiet && flags.LogFormat != "json" && isTerminal(stderr)
+}
+
+// progressDisplay draws a status line of the sources being fetched under
+// the log, and a status line per source once it is done, colored unless
+// NO_COLOR is set. Logs written through it go above the status line.
+type progressDisplay struct {
+	w     io.Writer
+	color bool
+
+	mu      sync.Mutex
+	running map[string]fetcher.Progress
+	started time.Time
+	fetched int
+	bytes   int64
+	drawn   bool
+}
+
+// newProgressDisplay returns a display drawing on w.
+func newProgressDisplay(w io.Writer) *progressDisplay {
+	return &progressDisplay{
+		w:       w,
+		color:   os.Getenv("NO_COLOR") == "",
+		running: make(map[string]fetcher.Progress),
+	}
+}
+
+// Write writes log output above the status line.
+func (d *progressDisplay) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	n, err := d.w.Write(p)
+	d.draw()
+	return n, err
+}
+
+// report is the fetcher.ProgressFunc of the display.
+func (d *progressDisplay) report(p fetcher.Progress) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started.IsZero() {
+		d.started = time.Now()
+	}
+	d.clear()
+	if !p.Done {
+		d.running[p.Source] = p
+		d.draw()
+		return
+	}
+
+	delete(d.running, p.Source)
+	d.fetched++
+	d.bytes += p.Bytes
+	switch p.Status {
+	case fetcher.StatusOK:
+		fmt.Fprintf(d.w, "%s %s (%s, %s)\n", d.paint(ansiGreen, "ok          "), p.Source,
+			formatBytes(p.Bytes), p.Elapsed.Round(10*time.Millisecond))
+	case fetcher.StatusNotModified:
+		fmt.Fprintf(d.w, "%s %s (%s)\n", d.paint(ansiDim, "not modified"), p.Source,
+			p.Elapsed.Round(10*time.Millisecond))
+	default:
+		fmt.Fprintf(d.w, "%s %s: %v\n", d.paint(ansiRed, "failed      "), p.Source, p.Err)
+	}
+	d.draw()
+}
+
+// finish removes the status line.
+func (d *progressDisplay) finish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+}
+
+// draw writes the status line: a spinner, the sources being fetched and
+// those done, the bytes downloaded, the time taken, and the slowest
+// source still running.
+func (d *progressDisplay) draw() {
+	if len(d.running) == 0 {
+		return
+	}
+	var bytes int64
+	var slowest fetcher.Progress
+	sources := make([]string, 0, len(d.running))
+	for source, p := range d.running {
+		bytes += p.Bytes
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		if p := d.running[source]; p.Elapsed > slowest.Elapsed || slowest.Source == "" {
+			slowest = p
+		}
+	}
+	// Keep the line from wrapping, which would defeat clearing it
+	if len(slowest.Source) > maxStatusSource {
+		slowest.Source = "..." + slowest.Source[len(slowest.Source)-maxStatusSource+3:]
+	}
+
+	elapsed := time.Since(d.started)
+	line := fmt.Sprintf("%s fetching %d, %d done, %s, %s  %s",
+		spinnerFrames[int(elapsed/fetcher.ProgressInterval)%len(spinnerFrames)], len(d.running), d.fetched,
+		formatBytes(d.bytes+bytes), elapsed.Round(100*time.Millisecond), slowest.Source)
+	fmt.Fprint(d.w, d.paint(ansiYellow, line))
+	d.drawn = true
+}
+
+// clear erases the status line, if drawn.
+func (d *progressDisplay) clear() {
+	if d.drawn {
+		fmt.Fprint(d.w, ansiClearLine)
+		d.drawn = false
+	}
+}
+
+// paint colors s, unless colors are off.
+func (d *progressDisplay) paint(color, s string) string {
+	if !d.color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// formatBytes gives n in B, kB, MB, or GB.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefixes := float64(n)/unit, "kMG"
+	for i := range prefixes {
+		if value < unit || i == len(prefixes)-1 {
+			return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + prefixes[i:i+1] + "B"
+		}
+		value /= unit
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestProgressDisplay(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	var out bytes.Buffer
+	d := newProgressDisplay(&out)
+
+	d.report(fetcher.Progress{Source: "https://example.com/a.json"})
+	d.report(fetcher.Progress{Source: "https://example.com/b.json", Bytes: 1500, Elapsed: time.Second})
+	if s := out.String(); !strings.Contains(s, "fetching 2, 0 done, 1.5 kB") || !strings.Contains(s, ansiYellow) {
+		t.Errorf("status line = %q", s)
+	}
+
+	out.Reset()
+	_, _ = d.Write([]byte("level=WARN msg=slow\n"))
+	if s := out.String(); !strings.HasPrefix(s, ansiClearLine+"level=WARN msg=slow\n") || !strings.Contains(s, "fetching 2") {
+		t.Errorf("log through the display = %q, expected it above a redrawn status line", s)
+	}
+
+	out.Reset()
+	d.report(fetcher.Progress{Source: "https://example.com/b.json", Bytes: 2_500_000, Elapsed: 1200 * time.Millisecond,
+		Done: true, Status: fetcher.StatusOK})
+	d.report(fetcher.Progress{Source: "https://example.com/a.json", Done: true, Status: fetcher.StatusError,
+		Err: errors.New("503 Service Unavailable")})
+	s := out.String()
+	if !strings.Contains(s, ansiGreen+"ok") || !strings.Contains(s, "https://example.com/b.json (2.5 MB, 1.2s)") {
+		t.Errorf("done line = %q", s)
+	}
+	if !strings.Contains(s, ansiRed+"failed") || !strings.Contains(s, "a.json: 503 Service Unavailable\n") {
+		t.Errorf("failed line = %q", s)
+	}
+	if !strings.HasSuffix(s, "\n") {
+		t.Errorf("output = %q, expected no status line once nothing runs", s)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	out.Reset()
+	d = newProgressDisplay(&out)
+	d.report(fetcher.Progress{Source: "src"})
+	d.report(fetcher.Progress{Source: "src", Done: true, Status: fetcher.StatusNotModified})
+	d.finish()
+	if s := out.String(); strings.Contains(s, "\x1b[3") || !strings.Contains(s, "not modified src") {
+		t.Errorf("NO_COLOR output = %q, expected no colors", s)
+	}
+}
+
+func TestShowProgress(t *testing.T) {
+	origTerminal := isTerminal
+	defer func() { isTerminal = origTerminal }()
+
+	var stderr bytes.Buffer
+	if showProgress(&Flags{Verbose: true}, &stderr) {
+		t.Error("showProgress() on a buffer = true")
+	}
+
+	isTerminal = func(w io.Writer) bool { return true }
+	tests := []struct {
+		flags Flags
+		want  bool
+	}{
+		{Flags{Verbose: true}, true},
+		{Flags{}, false},
+		{Flags{Verbose: true, Quiet: true}, false},
+		{Flags{Verbose: true, LogFormat: "json"}, false},
+	}
+	for _, tt := range tests {
+		if got := showProgress(&tt.flags, &stderr); got != tt.want {
+			t.Errorf("showProgress(%+v) = %v, expected %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0 B",
+		999:           "999 B",
+		1000:          "1 kB",
+		1500:          "1.5 kB",
+		2_500_000:     "2.5 MB",
+		3_000_000_000: "3 GB",
+		5e12:          "5000 GB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, expected %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runPrune implements "basar prune --check-urls [--sample N] [--jobs N]
+// [--dry-run] [--report FILE] [--json] [--time-format F]": it checks the
+// symbol URLs of the cache and removes the dead ones, or only flags them
+// with --dry-run.
+func runPrune(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("prune")
+	overrideFlags(fs, &o)
+
+	var opts cache.PruneOptions
+	var checkURLs, asJSON bool
+	var reportFile string
+	fs.BoolVar(&checkURLs, "check-urls", false, "")
+	fs.IntVar(&opts.Sample, "sample", 0, "")
+	fs.IntVar(&opts.Jobs, "jobs", 0, "")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "")
+	fs.StringVar(&reportFile, "report", "", "")
+	fs.BoolVar(&asJSON, "json", false, "")
+	timeFormat := fs.String("time-format", "both", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	switch {
+	case fs.NArg() > 0:
+		fmt.Fprintf(stderr, "basar: prune takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	case !checkURLs:
+		fmt.Fprintln(stderr, "basar: prune needs --check-urls")
+		return exitError
+	case opts.Sample < 0 || opts.Jobs < 0:
+		fmt.Fprintln(stderr, "basar: --sample and --jobs take a positive count")
+		return exitError
+	}
+	if err := checkTimeFormat(*timeFormat); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	report, err := cache.New(config.NewWith(o)).PruneURLs(ctx, opts)
+	if errors.Is(err, cache.ErrNoCache) {
+		fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+		return exitInvalid
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		if report == nil {
+			return exitError
+		}
+	}
+
+	if reportFile != "" {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, report, *timeFormat); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding report: %v\n", err)
+			return exitError
+		}
+		if err := os.WriteFile(reportFile, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+	}
+
+	if asJSON {
+		if err := writeJSON(stdout, report, *timeFormat); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding report: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, p := range report.Dead {
+			fmt.Fprintf(stdout, "dead  %s: %s (%d banners)\n", p.URL, p.Error, len(p.Banners))
+		}
+		for _, p := range report.Failed {
+			fmt.Fprintf(stdout, "fail  %s: %s (kept)\n", p.URL, p.Error)
+		}
+		fmt.Fprintf(stdout, "checked %d of %d URLs: %d dead, %d failed\n",
+			report.Checked, report.URLs, len(report.Dead), len(report.Failed))
+		if opts.DryRun {
+			fmt.Fprintln(stdout, "dry run; nothing removed")
+		} else {
+			fmt.Fprintf(stdout, "removed %d URLs and %d banners left without one\n",
+				report.Removed, report.RemovedBanners)
+		}
+	}
+
+	if err != nil {
+		return exitError
+	}
+	return exitOK
+}
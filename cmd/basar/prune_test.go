@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestRunPrune(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.json" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	index := `{"version":1,"linux":{` +
+		`"Linux version 5.15.0":["` + server.URL + `/live.json","` + server.URL + `/gone.json"],` +
+		`"Linux version 6.1.0":["` + server.URL + `/gone.json"]}}`
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"prune"}, &stdout, &stderr); code != exitError ||
+		!strings.Contains(stderr.String(), "--check-urls") {
+		t.Errorf("run(prune) = %d (stderr: %s), expected --check-urls required", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"prune", "--check-urls", "--dry-run"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(prune --dry-run) = %d, expected %d (stderr: %s)", code, exitOK, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "1 dead, 0 failed") || !strings.Contains(out, "nothing removed") {
+		t.Errorf("unexpected dry run output:\n%s", out)
+	}
+
+	stdout.Reset()
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	if code := run([]string{"prune", "--check-urls", "--report", reportFile}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(prune) = %d, expected %d (stderr: %s)", code, exitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "removed 2 URLs and 1 banners") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+	var report cache.PruneReport
+	raw, err := os.ReadFile(reportFile)
+	if err != nil || json.Unmarshal(raw, &report) != nil || len(report.Dead) != 1 || report.Removed != 2 {
+		t.Errorf("report = %s (%v), expected 1 dead URL removed twice", raw, err)
+	}
+	raw, _ = os.ReadFile(env.cacheFile)
+	if strings.Contains(string(raw), "gone.json") || strings.Contains(string(raw), "6.1.0") {
+		t.Errorf("cache still lists the dead URL: %s", raw)
+	}
+}
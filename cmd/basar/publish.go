@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// runPublish implements "basar publish --oci oci://REGISTRY/REPO:TAG": it
+// pushes the cache to an OCI registry as an artifact, which other
+// installations list as an oci:// source.
+func runPublish(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("publish")
+	overrideFlags(fs, &o)
+
+	var ref string
+	fs.StringVar(&ref, "oci", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	switch {
+	case fs.NArg() > 0:
+		fmt.Fprintf(stderr, "basar: publish takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	case ref == "":
+		fmt.Fprintln(stderr, "basar: publish needs --oci oci://REGISTRY/REPOSITORY:TAG")
+		return exitError
+	case !fetcher.IsOCI(ref):
+		ref = "oci://" + ref
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	c := cache.New(config.NewWith(o))
+	digest, err := c.PublishOCI(ctx, ref)
+	if errors.Is(err, cache.ErrNoCache) {
+		fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+		return exitInvalid
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: publishing to %s: %v\n", ref, err)
+		return exitError
+	}
+	path, _ := c.Path()
+	fmt.Fprintf(stdout, "published %s to %s (%s)\n", path, ref, digest)
+	return exitOK
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunPublish(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	// An anonymous registry keeping what is pushed, by path
+	var mu sync.Mutex
+	stored := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			key := r.URL.Path
+			if digest := r.URL.Query().Get("digest"); digest != "" {
+				key = "/v2/banners/blobs/" + digest
+			}
+			stored[key] = body
+			w.WriteHeader(http.StatusCreated)
+		case stored[r.URL.Path] != nil:
+			w.Write(stored[r.URL.Path])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ref := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/banners:v1"
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"publish", "--oci", ref}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(publish) without a cache = %d, expected %d", code, exitInvalid)
+	}
+	if code := run([]string{"publish"}, &stdout, &stderr); code != exitError || !strings.Contains(stderr.String(), "--oci") {
+		t.Errorf("run(publish) without --oci = %d (stderr: %s), expected --oci required", code, stderr.String())
+	}
+
+	env.createCache(t)
+	stdout.Reset()
+	if code := run([]string{"publish", "--oci", ref}, &stdout, &stderr); code != exitOK || !strings.Contains(stdout.String(), "sha256:") {
+		t.Fatalf("run(publish) = %d, %q; stderr: %s", code, stdout.String(), stderr.String())
+	}
+
+	// Another installation pulls it as a source
+	conf := filepath.Join(env.tmpDir, "pull.conf")
+	if err := os.WriteFile(conf, []byte(ref+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := filepath.Join(env.tmpDir, "pulled")
+	if code := run([]string{"--config", conf, "--cache-dir", cacheDir, "--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) from the registry = %d; stderr: %s", code, stderr.String())
+	}
+	if raw, err := os.ReadFile(filepath.Join(cacheDir, "banners.json")); err != nil || !strings.Contains(string(raw), "5.15.0-generic") {
+		t.Errorf("the pulled cache should hold the published banners: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw is not supported here, browse falling back to its prompt.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.ErrUnsupported
+}
+
+// terminalSize is not supported here.
+func terminalSize(f *os.File) (width, height int, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// resizeSignal is nil, resizes not being signaled here.
+func resizeSignal() os.Signal {
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The console input modes makeRaw changes.
+const (
+	enableProcessedInput       = 0x0001
+	enableLineInput            = 0x0002
+	enableEchoInput            = 0x0004
+	enableVirtualTerminalInput = 0x0200
+)
+
+var getConsoleScreenBufferInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleScreenBufferInfo")
+
+// makeRaw has the console f pass keys as they are typed, unechoed and
+// without Ctrl-C handling, and encode special keys as ANSI sequences.
+// The returned function restores its previous mode.
+func makeRaw(f *os.File) (func(), error) {
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return nil, err
+	}
+	raw := mode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if ok, _, err := setConsoleMode.Call(uintptr(handle), uintptr(raw)); ok == 0 {
+		return nil, err
+	}
+	return func() { _, _, _ = setConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
+
+// terminalSize returns the columns and rows of the window of the console
+// f.
+func terminalSize(f *os.File) (width, height int, err error) {
+	var info struct {
+		size, cursor             struct{ x, y int16 }
+		attributes               uint16
+		left, top, right, bottom int16
+		maximumSize              struct{ x, y int16 }
+	}
+	if ok, _, err := getConsoleScreenBufferInfo.Call(f.Fd(), uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return 0, 0, err
+	}
+	return int(info.right-info.left) + 1, int(info.bottom-info.top) + 1, nil
+}
+
+// resizeSignal is nil, consoles not signaling resizes: the browser picks
+// up the new size on the next key.
+func resizeSignal() os.Signal {
+	return nil
+}
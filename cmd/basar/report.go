@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runReport implements "basar report [--since PERIOD] [--format F]": it
+// summarizes the update history over PERIOD for status updates.
+func runReport(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("report")
+	overrideFlags(fs, &o)
+
+	var since, format string
+	fs.StringVar(&since, "since", "7d", "")
+	fs.StringVar(&format, "format", cache.ReportMarkdown, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: report takes no arguments, got %q\n", fs.Arg(0))
+		return exitError
+	}
+
+	start, err := parseSince(since, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: --since: %v\n", err)
+		return exitError
+	}
+
+	report, err := cache.New(config.NewWith(o)).Report(start)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if err := report.Write(stdout, format); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// parseSince resolves a report start: a period before now such as "7d",
+// "2w", or a Go duration like "36h", or a date like "2024-01-31".
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return time.Time{}, fmt.Errorf("invalid period %q", s)
+			}
+			return now.Add(-time.Duration(count) * unit), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid period %q (want e.g. 7d, 2w, 36h, or 2024-01-31)", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReport(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"report", "--since", "7d"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(report) = %d; stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"**+2** added", "`Linux version 6.1.0-generic`", "| " + env.sourceFile + " | 1 | 0 | ok |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report should contain %q:\n%s", want, out)
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"report", "--format", "html"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(report --format html) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "<h2>") {
+		t.Errorf("expected an HTML report, got:\n%s", stdout.String())
+	}
+
+	if code := run([]string{"report", "--since", "last week"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(report --since 'last week') = %d, expected %d", code, exitError)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "7d", want: now.Add(-7 * 24 * time.Hour)},
+		{in: "2w", want: now.Add(-14 * 24 * time.Hour)},
+		{in: "36h", want: now.Add(-36 * time.Hour)},
+		{in: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{in: "xd", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, expected %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/memscan"
+)
+
+// resolvedBanner is a banner found in a memory image with its cached
+// symbol URLs, for resolve --json.
+type resolvedBanner struct {
+	memscan.Banner
+	Matches []cache.Match `json:"matches"`
+}
+
+// runResolve implements "basar resolve [--fetch] [--symbols-dir DIR]
+// [--json] DUMP": it scans a raw or LiME memory image for kernel banners
+// and prints the symbol URLs the cache lists for them, or with --fetch
+// downloads the symbol files like prefetch.
+func runResolve(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("resolve")
+	overrideFlags(fs, &o)
+
+	var fetch, asJSON bool
+	var dir string
+	fs.BoolVar(&fetch, "fetch", false, "")
+	fs.StringVar(&dir, "symbols-dir", "", "")
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if len(rest) != 1 {
+		fmt.Fprintln(stderr, "basar: resolve takes one memory image (or - for stdin)")
+		return exitError
+	}
+	dump := rest[0]
+
+	var r io.Reader = os.Stdin
+	if dump != "-" {
+		f, err := os.Open(dump)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		r = f
+	}
+	found, err := memscan.Banners(r)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: reading %s: %v\n", dump, err)
+		return exitError
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(stderr, "basar: no Linux kernel banner found in %s\n", dump)
+		return exitInvalid
+	}
+
+	c := cache.New(config.NewWith(o))
+	resolved := make([]resolvedBanner, 0, len(found))
+	var queries []string
+	for _, b := range found {
+		matches, err := c.Lookup(b.Text)
+		if err != nil {
+			if errors.Is(err, cache.ErrNoCache) {
+				fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+				return exitInvalid
+			}
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		resolved = append(resolved, resolvedBanner{b, matches})
+		if len(matches) > 0 {
+			queries = append(queries, b.Text)
+		}
+	}
+
+	if !fetch {
+		if asJSON {
+			if err := writeJSON(stdout, resolved, "both"); err != nil {
+				fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+				return exitError
+			}
+		} else {
+			for _, rb := range resolved {
+				if len(rb.Matches) == 0 {
+					fmt.Fprintf(stderr, "basar: not in the cache: %s\n", rb.Text)
+					continue
+				}
+				for _, m := range rb.Matches {
+					fmt.Fprintln(stdout, m.Banner)
+					for _, u := range m.URLs {
+						fmt.Fprintf(stdout, "  %s\n", u)
+					}
+				}
+			}
+		}
+		if len(queries) == 0 {
+			return exitInvalid
+		}
+		return exitOK
+	}
+
+	if len(queries) == 0 {
+		for _, rb := range resolved {
+			fmt.Fprintf(stderr, "basar: not in the cache: %s\n", rb.Text)
+		}
+		return exitInvalid
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	res, err := c.Prefetch(ctx, dir, queries)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		if dir == "" {
+			fmt.Fprintln(stderr, "basar: pass --symbols-dir to choose where the symbol files go")
+		}
+		return exitError
+	}
+	if asJSON {
+		if err := writeJSON(stdout, res, "both"); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, f := range res.Failed {
+			fmt.Fprintf(stderr, "basar: %s: %s\n", f.Banner, f.Error)
+		}
+		for _, f := range res.Files {
+			fmt.Fprintln(stdout, f.Path)
+		}
+		if dir != "" && len(res.Files) > 0 {
+			fmt.Fprintf(stdout, "use them with: volatility3 -s %s -f %s\n", res.Dir, dump)
+		}
+	}
+
+	switch {
+	case len(res.Files) == 0:
+		return exitError
+	case len(res.Failed) > 0:
+		return exitPartial
+	}
+	return exitOK
+}
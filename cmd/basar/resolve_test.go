@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	banner := "Linux version 5.15.0-generic (buildd) #1 SMP"
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"version":1,"linux":{"` + banner + `\n\u0000":["` + server.URL + `/5.15.json.xz"]}}`
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := filepath.Join(env.tmpDir, "memory.lime")
+	image := append(make([]byte, 4096), banner+"\n\x00"...)
+	image = append(image, "Linux version 4.19.0-unknown #1\n"...)
+	if err := os.WriteFile(dump, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"resolve", dump}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(resolve) = %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), server.URL+"/5.15.json.xz") {
+		t.Errorf("resolve output = %q, expected the symbol URL", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "not in the cache: Linux version 4.19.0-unknown #1") {
+		t.Errorf("resolve stderr = %q, expected the unknown banner", stderr.String())
+	}
+
+	dir := filepath.Join(env.tmpDir, "symbols")
+	stdout.Reset()
+	if code := run([]string{"resolve", "--fetch", "--symbols-dir", dir, dump}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(resolve --fetch) = %d; stderr: %s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "linux", "5.15.json.xz")); err != nil || string(data) != "isf" {
+		t.Errorf("fetched symbol file = %q, %v", data, err)
+	}
+
+	empty := filepath.Join(env.tmpDir, "empty.raw")
+	if err := os.WriteFile(empty, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"resolve", empty}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(resolve) of an image without a banner = %d, expected %d", code, exitInvalid)
+	}
+	if code := run([]string{"resolve"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(resolve) without an image = %d, expected %d", code, exitError)
+	}
+}
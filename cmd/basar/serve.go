@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/credentials"
+	"github.com/calilkhalil/basar/internal/metrics"
+)
+
+// Serve defaults.
+const (
+	defaultListen         = "localhost:9464"
+	defaultInterval       = time.Hour
+	defaultVerifyInterval = 15 * time.Minute
+)
+
+// runServe implements "basar serve [--listen ADDR] [--interval DURATION]
+// [--splay DURATION] [--verify-interval DURATION] [--webhook-secret-file
+// FILE] [--serve-profile NAME[,interval=D][,path=/P]]...": a daemon that
+// keeps the cache fresh, checks its integrity between updates, and serves
+// it over HTTP, along with the cache of each --serve-profile under its
+// own path and on its own schedule.
+func runServe(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("serve")
+	overrideFlags(fs, &o)
+
+	flags := &Flags{}
+	listen := fs.String("listen", defaultListen, "")
+	interval := fs.Duration("interval", defaultInterval, "")
+	splay := fs.Duration("splay", 0, "")
+	verifyInterval := fs.Duration("verify-interval", defaultVerifyInterval, "")
+	secretFile := fs.String("webhook-secret-file", "", "")
+	var profileSpecs []string
+	fs.Var(stringList{&profileSpecs}, "serve-profile", "")
+	lowPriority := fs.Bool("low-priority", false, "")
+	fs.BoolVar(&flags.Verbose, "v", false, "")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "")
+	fs.StringVar(&flags.LogFormat, "log-format", "", "")
+	fs.StringVar(&flags.LogLevel, "log-level", "", "")
+	fs.Var(optionalString{&flags.LogFile}, "log-file", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(stderr, "basar: invalid --interval %s\n", *interval)
+		return exitError
+	}
+	if *splay < 0 || *splay > config.MaxSplay {
+		fmt.Fprintf(stderr, "basar: invalid --splay %s\n", *splay)
+		return exitError
+	}
+	if *verifyInterval < 0 {
+		fmt.Fprintf(stderr, "basar: invalid --verify-interval %s\n", *verifyInterval)
+		return exitError
+	}
+
+	profiles := []*servedProfile{{name: o.Profile, interval: *interval}}
+	for _, spec := range profileSpecs {
+		p, err := parseServeProfile(spec, *interval)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: %v\n", err)
+			return exitError
+		}
+		profiles = append(profiles, p)
+	}
+	if err := checkServedProfiles(profiles); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	// Explicit directories would have every profile share one cache
+	if len(profiles) > 1 && (o.ConfigFile != "" || o.CacheDir != "") {
+		fmt.Fprintln(stderr, "basar: --serve-profile cannot be combined with --config or --cache-dir")
+		return exitError
+	}
+
+	for _, p := range profiles {
+		po := o
+		po.Profile = p.name
+		p.cfg = config.NewWith(po)
+		if *splay > 0 {
+			p.cfg.Splay = *splay
+		}
+		p.cache = cache.New(p.cfg)
+		p.trigger = make(chan struct{}, 1)
+		p.integrity = &integrityStatus{}
+	}
+
+	logger, closeLog, err := newLogger(flags, profiles[0].cfg, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	defer closeLog()
+	for i, p := range profiles {
+		p.logger = logger
+		if i > 0 {
+			p.logger = logger.With("profile", p.name)
+		}
+		p.cache.SetLogger(p.logger)
+		for _, w := range p.cfg.Warnings {
+			p.logger.Warn(w)
+		}
+		for _, m := range p.cfg.Migrations {
+			p.logger.Info("migrated legacy layout", "change", m)
+		}
+	}
+	if *lowPriority || profiles[0].cfg.LowPriority {
+		enterLowPriority(logger)
+	}
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	// The update webhook is only served with a secret to check requests
+	var secret []byte
+	spec := credentials.Spec{File: *secretFile}
+	if spec.File == "" && os.Getenv("BASAR_WEBHOOK_SECRET") != "" {
+		spec.Env = "BASAR_WEBHOOK_SECRET"
+	}
+	if !spec.IsZero() {
+		resolved, err := credentials.Resolve(ctx, spec)
+		if err != nil {
+			fmt.Fprintf(stderr, "basar: webhook secret: %v\n", err)
+			return exitError
+		}
+		secret = []byte(resolved)
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           serveHandler(profiles, secret),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	schedule(ctx, profiles, *verifyInterval)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	logger.Info("serving", "listen", *listen, "interval", *interval, "splay", profiles[0].cfg.Splay,
+		"verify_interval", *verifyInterval, "webhook", secret != nil)
+	for _, p := range profiles[1:] {
+		p.logger.Info("serving profile", "path", p.path, "interval", p.interval)
+	}
+
+	select {
+	case err := <-errc:
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// integrityStatus holds the report of the last integrity check, shared
+// between verifyLoop and the HTTP handlers.
+type integrityStatus struct {
+	mu     sync.Mutex
+	report *cache.IntegrityReport
+}
+
+func (s *integrityStatus) set(r cache.IntegrityReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = &r
+}
+
+// last returns the last report, nil before the first check.
+func (s *integrityStatus) last() *cache.IntegrityReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
+
+// healthz is the body of /healthz.
+type healthz struct {
+	Status    string                 `json:"status"`
+	Cache     bool                   `json:"cache"`
+	Integrity *cache.IntegrityReport `json:"integrity,omitempty"`
+}
+
+// newServeMux returns the HTTP handlers of serve mode, with /hooks/update
+// when hook is not nil.
+func newServeMux(c *cache.Cache, cfg *config.Config, hook *updateWebhook, integrity *integrityStatus) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	if hook != nil {
+		mux.Handle("/hooks/update", hook)
+	}
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		_ = metrics.Write(w, c.Stats())
+		if report := integrity.last(); report != nil {
+			_ = metrics.WriteIntegrity(w, *report)
+		}
+	})
+
+	// Unhealthy without a cache, or when the last integrity check found
+	// it corrupt
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := healthz{Status: "ok", Cache: c.Stats().Valid, Integrity: integrity.last()}
+		code := http.StatusOK
+		if !h.Cache || h.Integrity != nil && !h.Integrity.OK {
+			h.Status = "failing"
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(h)
+	})
+
+	// The sources and filters the cache is built from, secrets redacted,
+	// for endpoint operators to check what the aggregator does
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(servedConfig(cfg))
+	})
+
+	// Reads are recorded for stats with BASAR_TRACK_USAGE=1, to tell
+	// idle machines from those where volatility3 uses the cache
+	mux.HandleFunc("/banners.json", func(w http.ResponseWriter, r *http.Request) {
+		if !c.Stats().Valid {
+			http.Error(w, "no cache", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_ = c.RecordRead(r.UserAgent())
+		}
+		http.ServeFile(w, r, cfg.CacheFile)
+	})
+
+	// Lookups use the disk index when there is one, and otherwise the
+	// in-memory index, rebuilt only when the cache changes
+	mux.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
+		query, prefix := r.URL.Query().Get("q"), r.URL.Query().Get("prefix")
+		if query == "" && prefix == "" {
+			http.Error(w, "missing q or prefix parameter", http.StatusBadRequest)
+			return
+		}
+
+		matches, err := serveLookup(c, query, prefix)
+		if errors.Is(err, cache.ErrNoCache) {
+			http.Error(w, "no cache", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if matches == nil {
+			matches = []cache.Match{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(matches)
+	})
+
+	return mux
+}
+
+// serveLookup answers a /lookup query, or a prefix query when query is
+// empty.
+func serveLookup(c *cache.Cache, query, prefix string) ([]cache.Match, error) {
+	if dx, err := c.OpenDiskIndex(); err == nil {
+		defer dx.Close()
+		if query != "" {
+			return dx.Lookup(query)
+		}
+		return dx.Prefix(prefix)
+	}
+
+	ix, err := c.Index()
+	if err != nil {
+		return nil, err
+	}
+	if query != "" {
+		return ix.Lookup(query), nil
+	}
+	return ix.Prefix(prefix), nil
+}
+
+// refreshLoop updates the cache after delay, then every interval and
+// whenever trigger fires, until ctx ends. A missing or expired cache is
+// updated right away instead of waiting out the delay.
+func refreshLoop(ctx context.Context, c *cache.Cache, interval, delay time.Duration, trigger <-chan struct{}, logger *slog.Logger) {
+	if delay > 0 && c.IsValid() {
+		logger.Info("delaying updates by splay", "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-trigger:
+			timer.Stop()
+			logger.Info("update requested by webhook")
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if res, err := c.SmartUpdate(ctx); err != nil {
+			logger.Warn("update failed", "error", err)
+		} else if res.Updated {
+			logUpdate(logger, res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+			logger.Info("update requested by webhook")
+		}
+	}
+}
+
+// verifyLoop checks the integrity of the cache every interval until ctx
+// ends, logging checks that fail.
+func verifyLoop(ctx context.Context, c *cache.Cache, interval time.Duration, status *integrityStatus, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report := c.CheckIntegrity(ctx)
+		status.set(report)
+		for _, ch := range report.Checks {
+			if !ch.OK {
+				logger.Warn("integrity check failed", "check", ch.Name, "detail", ch.Detail)
+			}
+		}
+	}
+}
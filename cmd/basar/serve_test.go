@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestServeMux(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	cfg := config.New()
+	srv := httptest.NewServer(newServeMux(cache.New(cfg), cfg, nil, &integrityStatus{}))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/metrics", want: "basar_last_update_success 1"},
+		{path: "/banners.json", want: "Linux version 5.15.0-generic"},
+		{path: "/lookup?q=6.1.0", want: `"banner":"Linux version 6.1.0-generic"`},
+		{path: "/lookup?prefix=Linux+version+5", want: "https://example.com/5.15.0.json"},
+		{path: "/lookup?q=freebsd", want: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			var body bytes.Buffer
+			_, _ = body.ReadFrom(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s = %d", tt.path, resp.StatusCode)
+			}
+			if !strings.Contains(body.String(), tt.want) {
+				t.Errorf("GET %s missing %q, got: %s", tt.path, tt.want, body.String())
+			}
+		})
+	}
+}
+
+func TestServeMuxTracksUsage(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	cfg := config.New()
+	cfg.TrackUsage = true
+	c := cache.New(cfg)
+	srv := httptest.NewServer(newServeMux(c, cfg, nil, &integrityStatus{}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/banners.json", nil)
+	req.Header.Set("User-Agent", "Python-urllib/3.11")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	u := c.Stats().LastUsed
+	if u == nil || u.Reads != 1 || u.Client != "Python-urllib/3.11" {
+		t.Errorf("LastUsed = %+v, expected the read recorded", u)
+	}
+}
+
+func TestServeMuxNoCache(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	cfg := config.New()
+	srv := httptest.NewServer(newServeMux(cache.New(cfg), cfg, nil, &integrityStatus{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/banners.json")
+	if err != nil {
+		t.Fatalf("GET /banners.json: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /banners.json without cache = %d, expected %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	for path, want := range map[string]int{
+		"/lookup?q=5.15": http.StatusServiceUnavailable,
+		"/lookup":        http.StatusBadRequest,
+		"/healthz":       http.StatusServiceUnavailable,
+		"/hooks/update":  http.StatusNotFound, // no webhook secret
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s without cache = %d, expected %d", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestRunServeWebhookSecret(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"serve", "--webhook-secret-file", "/nonexistent/secret"}
+	if code := run(args, &stdout, &stderr); code != exitError || !strings.Contains(stderr.String(), "webhook secret") {
+		t.Errorf("run(%v) = %d, expected %d; stderr: %s", args, code, exitError, stderr.String())
+	}
+}
+
+func TestRunServeInvalidInterval(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"serve", "--interval", "0s"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(serve --interval 0s) = %d, expected %d", code, exitError)
+	}
+}
+
+func TestServeMuxIntegrity(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--update"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--update) = %d; stderr: %s", code, stderr.String())
+	}
+
+	cfg := config.New()
+	c := cache.New(cfg)
+	integrity := &integrityStatus{}
+	srv := httptest.NewServer(newServeMux(c, cfg, nil, integrity))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Healthy before the first check
+	if code, body := get("/healthz"); code != http.StatusOK || strings.Contains(body, "integrity") {
+		t.Errorf("GET /healthz before a check = %d %s", code, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		verifyLoop(ctx, c, 10*time.Millisecond, integrity, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for integrity.last() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("no integrity check within the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if code, body := get("/healthz"); code != http.StatusOK || !strings.Contains(body, `"name":"checksum","ok":true`) {
+		t.Errorf("GET /healthz after a check = %d %s", code, body)
+	}
+	if _, body := get("/metrics"); !strings.Contains(body, "basar_integrity_ok 1") {
+		t.Errorf("GET /metrics missing basar_integrity_ok 1, got: %s", body)
+	}
+
+	// Corruption between updates
+	if err := os.WriteFile(cfg.CacheFile, []byte(`{"version": 1, "linux": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	integrity.set(c.CheckIntegrity(context.Background()))
+	if code, body := get("/healthz"); code != http.StatusServiceUnavailable || !strings.Contains(body, `"status":"failing"`) {
+		t.Errorf("GET /healthz after corruption = %d %s", code, body)
+	}
+}
+
+func TestRunServeInvalidVerifyInterval(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"serve", "--verify-interval", "-1m"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(serve --verify-interval -1m) = %d, expected %d", code, exitError)
+	}
+}
+
+func TestRunServeInvalidSplay(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, splay := range []string{"-1m", "25h"} {
+		if code := run([]string{"serve", "--splay", splay}, &stdout, &stderr); code != exitError {
+			t.Errorf("run(serve --splay %s) = %d, expected %d", splay, code, exitError)
+		}
+	}
+}
+
+// TestRefreshLoopSplayWithoutCache checks a missing cache is fetched right
+// away instead of waiting out the splay.
+func TestRefreshLoopSplayWithoutCache(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+
+	c := cache.New(config.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		refreshLoop(ctx, c, time.Hour, time.Hour, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	defer func() { cancel(); <-done }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.IsValid() {
+		if time.Now().After(deadline) {
+			t.Fatal("cache not updated within the splay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// redacted stands in for the secrets /config leaves out.
+const redacted = "REDACTED"
+
+// secretWords mark query parameters, and key=value arguments, whose values
+// /config redacts.
+var secretWords = []string{"token", "secret", "pass", "key", "sig", "auth", "cred"}
+
+// ServedConfig is the body of /config in serve mode: the sources and
+// filters the served cache is built from, as this instance resolved them,
+// with secrets redacted so endpoint operators can check what the
+// aggregator does without access to it.
+type ServedConfig struct {
+	Profile     string         `json:"profile,omitempty"`
+	SourceFiles []string       `json:"source_files"`
+	Sources     []ServedSource `json:"sources"`
+	// Only lists the selectors updates are restricted to.
+	Only         []string            `json:"only,omitempty"`
+	URLFilter    *ServedURLFilter    `json:"url_filter,omitempty"`
+	BannerFilter *ServedBannerFilter `json:"banner_filter,omitempty"`
+	Offline      bool                `json:"offline"`
+	TTL          string              `json:"ttl"`
+	TTLSeconds   float64             `json:"ttl_seconds"`
+	MinSources   int                 `json:"min_sources"`
+	Strict       bool                `json:"strict"`
+	Vol3Compat   string              `json:"vol3_compat,omitempty"`
+}
+
+// ServedSource is a source of /config with its options. Token is how its
+// bearer token is found, "env", "file", "cmd", or "keyring", never the
+// token itself.
+type ServedSource struct {
+	Source         string   `json:"source"`
+	Token          string   `json:"token,omitempty"`
+	Auth           string   `json:"auth,omitempty"`
+	Format         string   `json:"format,omitempty"`
+	Trust          string   `json:"trust"`
+	Priority       int      `json:"priority,omitempty"`
+	Required       bool     `json:"required,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Timeout        string   `json:"timeout,omitempty"`
+	TimeoutSeconds float64  `json:"timeout_seconds,omitempty"`
+}
+
+// ServedURLFilter is url-filters.conf as /config shows it.
+type ServedURLFilter struct {
+	Allow     []string          `json:"allow,omitempty"`
+	Deny      []string          `json:"deny,omitempty"`
+	MinTrust  string            `json:"min_trust,omitempty"`
+	Redirects map[string]string `json:"redirects,omitempty"`
+}
+
+// ServedBannerFilter is the banner filters file as /config shows it.
+type ServedBannerFilter struct {
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	MinKernel string   `json:"min_kernel,omitempty"`
+	MaxKernel string   `json:"max_kernel,omitempty"`
+}
+
+// servedConfig describes cfg for /config.
+func servedConfig(cfg *config.Config) ServedConfig {
+	out := ServedConfig{
+		Profile:     cfg.Profile,
+		SourceFiles: cfg.SourceFiles,
+		Sources:     make([]ServedSource, 0, len(cfg.Sources)),
+		Only:        cfg.Only,
+		Offline:     cfg.Offline,
+		TTL:         cfg.TTL.String(),
+		TTLSeconds:  cfg.TTL.Seconds(),
+		MinSources:  cfg.MinSources,
+		Strict:      cfg.Strict,
+		Vol3Compat:  cfg.Vol3Compat,
+	}
+	if out.SourceFiles == nil {
+		out.SourceFiles = []string{}
+	}
+
+	for _, source := range cfg.Sources {
+		opts := cfg.SourceOptions(source)
+		s := ServedSource{
+			Source:   redactSource(source),
+			Auth:     opts.Auth,
+			Format:   opts.Format,
+			Trust:    cfg.SourceTrust(source),
+			Priority: opts.Priority,
+			Required: opts.Required,
+			Tags:     opts.Tags,
+		}
+		switch {
+		case opts.TokenEnv != "":
+			s.Token = "env"
+		case opts.TokenFile != "":
+			s.Token = "file"
+		case opts.TokenCmd != "":
+			s.Token = "cmd"
+		case opts.TokenKeyring != "":
+			s.Token = "keyring"
+		}
+		if opts.Timeout > 0 {
+			s.Timeout, s.TimeoutSeconds = opts.Timeout.String(), opts.Timeout.Seconds()
+		}
+		out.Sources = append(out.Sources, s)
+	}
+
+	if f := cfg.URLFilter; f != nil || len(cfg.URLRedirects) > 0 {
+		out.URLFilter = &ServedURLFilter{Redirects: cfg.URLRedirects}
+		if f != nil {
+			out.URLFilter.Allow, out.URLFilter.Deny, out.URLFilter.MinTrust = patterns(f.Allow), patterns(f.Deny), f.MinTrust
+		}
+	}
+	if f := cfg.BannerFilter; !f.Empty() {
+		out.BannerFilter = &ServedBannerFilter{
+			Include:   patterns(f.Include),
+			Exclude:   patterns(f.Exclude),
+			MinKernel: kernelVersion(f.MinKernel),
+			MaxKernel: kernelVersion(f.MaxKernel),
+		}
+	}
+	return out
+}
+
+// redactSource returns source without the secrets it may carry: the
+// password of a URL, or its user when alone, as tokens often are, and the
+// values of query parameters, or of key=value command arguments, named
+// like a secret. A source it cannot take apart, which may hold a secret
+// anywhere, is redacted whole but for its scheme.
+func redactSource(source string) string {
+	if filepath.VolumeName(source) != "" {
+		return source
+	}
+	u, err := url.Parse(source)
+	switch {
+	case err != nil:
+		return redacted
+	case u.Opaque != "":
+		return u.Scheme + ":" + redacted
+	case u.Scheme == "":
+		return source
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	} else if u.User != nil {
+		u.User = url.User(redacted)
+	}
+
+	// Parameters keep their order, which command arguments depend on
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if !ok || err != nil {
+			continue
+		}
+		if isSecretName(name) {
+			params[i] = key + "=" + redacted
+			continue
+		}
+		arg, err := url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		if name, _, ok := strings.Cut(strings.TrimLeft(arg, "-"), "="); ok && isSecretName(name) {
+			params[i] = key + "=" + url.QueryEscape(arg[:strings.Index(arg, "=")+1]) + redacted
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.String()
+}
+
+// isSecretName reports whether a parameter name looks like it holds a
+// secret.
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// patterns returns the sources of res.
+func patterns(res []*regexp.Regexp) []string {
+	out := make([]string, len(res))
+	for i, re := range res {
+		out[i] = re.String()
+	}
+	return out
+}
+
+// kernelVersion formats a kernel version bound, e.g. "6.1"; nil is "".
+func kernelVersion(v []int) string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
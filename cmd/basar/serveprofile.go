@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// servedProfile is a profile serve keeps fresh: its cache, refreshed every
+// interval, and its handlers under path ("" for the root).
+type servedProfile struct {
+	name      string
+	path      string
+	interval  time.Duration
+	cfg       *config.Config
+	cache     *cache.Cache
+	logger    *slog.Logger
+	trigger   chan struct{}
+	integrity *integrityStatus
+}
+
+// parseServeProfile parses a --serve-profile value,
+// NAME[,interval=DURATION][,path=/PREFIX], into a profile refreshed every
+// interval unless it sets its own, and served under /NAME unless it sets a
+// path.
+func parseServeProfile(spec string, interval time.Duration) (*servedProfile, error) {
+	name, rest, _ := strings.Cut(spec, ",")
+	if err := config.CheckProfile(name); err != nil {
+		return nil, fmt.Errorf("--serve-profile %q: %w", spec, err)
+	}
+	p := &servedProfile{name: name, path: "/" + name, interval: interval}
+	if rest == "" {
+		return p, nil
+	}
+	for _, opt := range strings.Split(rest, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "interval":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("--serve-profile %q: invalid interval %q", spec, value)
+			}
+			p.interval = d
+		case "path":
+			clean := path.Clean("/" + value)
+			if value == "" || clean == "/" {
+				return nil, fmt.Errorf("--serve-profile %q: invalid path %q", spec, value)
+			}
+			p.path = clean
+		default:
+			return nil, fmt.Errorf("--serve-profile %q: unknown option %q (want interval or path)", spec, key)
+		}
+	}
+	return p, nil
+}
+
+// checkServedProfiles rejects profiles served twice, or under the same
+// path.
+func checkServedProfiles(profiles []*servedProfile) error {
+	names := make(map[string]bool)
+	paths := make(map[string]string)
+	for _, p := range profiles {
+		if names[p.name] {
+			return fmt.Errorf("profile %q is served twice", p.name)
+		}
+		names[p.name] = true
+		if other, ok := paths[p.path]; ok {
+			return fmt.Errorf("profiles %q and %q are both served under %s", other, p.name, p.path)
+		}
+		paths[p.path] = p.name
+	}
+	return nil
+}
+
+// serveHandler mounts the handlers of each profile under its path, with
+// /hooks/update when secret is set.
+func serveHandler(profiles []*servedProfile, secret []byte) http.Handler {
+	if len(profiles) == 1 && profiles[0].path == "" {
+		return profiles[0].mux(secret)
+	}
+	mux := http.NewServeMux()
+	for _, p := range profiles {
+		if p.path == "" {
+			mux.Handle("/", p.mux(secret))
+			continue
+		}
+		mux.Handle(p.path+"/", http.StripPrefix(p.path, p.mux(secret)))
+	}
+	return mux
+}
+
+// mux returns the handlers of p.
+func (p *servedProfile) mux(secret []byte) *http.ServeMux {
+	var hook *updateWebhook
+	if secret != nil {
+		hook = &updateWebhook{secret: secret, trigger: p.trigger}
+	}
+	return newServeMux(p.cache, p.cfg, hook, p.integrity)
+}
+
+// schedule refreshes each profile on its own schedule and, every
+// verifyInterval when positive, checks its integrity, until ctx ends.
+// Profiles have their own cache directories and so their own update
+// locks: a slow source in one does not hold back the others.
+func schedule(ctx context.Context, profiles []*servedProfile, verifyInterval time.Duration) {
+	for _, p := range profiles {
+		go refreshLoop(ctx, p.cache, p.interval, p.cache.SplayOffset(), p.trigger, p.logger)
+		if verifyInterval > 0 {
+			go verifyLoop(ctx, p.cache, verifyInterval, p.integrity, p.logger)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestParseServeProfile(t *testing.T) {
+	tests := []struct {
+		spec         string
+		wantPath     string
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{spec: "work", wantPath: "/work", wantInterval: time.Hour},
+		{spec: "work,interval=15m", wantPath: "/work", wantInterval: 15 * time.Minute},
+		{spec: "work,path=lab/isf/,interval=2h", wantPath: "/lab/isf", wantInterval: 2 * time.Hour},
+		{spec: "state", wantErr: true},
+		{spec: "work,interval=0s", wantErr: true},
+		{spec: "work,path=/", wantErr: true},
+		{spec: "work,ttl=1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := parseServeProfile(tt.spec, time.Hour)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseServeProfile(%q) succeeded, expected an error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseServeProfile(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if p.name != "work" || p.path != tt.wantPath || p.interval != tt.wantInterval {
+			t.Errorf("parseServeProfile(%q) = %+v, expected path %s and interval %s", tt.spec, p, tt.wantPath, tt.wantInterval)
+		}
+	}
+
+	dup := []*servedProfile{{name: "", path: ""}, {name: "a", path: "/x"}, {name: "b", path: "/x"}}
+	if err := checkServedProfiles(dup); err == nil {
+		t.Error("checkServedProfiles() accepted two profiles under one path")
+	}
+}
+
+func TestServeHandlerProfiles(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	env.createSource(t)
+	env.createConfig(t)
+	workSource := filepath.Join(t.TempDir(), "work.json")
+	if err := os.WriteFile(workSource, []byte(`{"version":1,"linux":{"Linux version 4.19.0-work":["https://example.com/work.json"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workConfig := filepath.Join(env.configDir, "basar", "work", "sources.conf")
+	_ = os.MkdirAll(filepath.Dir(workConfig), 0755)
+	if err := os.WriteFile(workConfig, []byte(workSource+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{{"--update"}, {"--update", "--profile", "work"}} {
+		if code := run(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("run(%v) = %d; stderr: %s", args, code, stderr.String())
+		}
+	}
+
+	var profiles []*servedProfile
+	for _, p := range []*servedProfile{{name: ""}, {name: "work", path: "/lab"}} {
+		p.cfg = config.NewWith(config.Overrides{Profile: p.name})
+		p.cache = cache.New(p.cfg)
+		p.trigger = make(chan struct{}, 1)
+		p.integrity = &integrityStatus{}
+		profiles = append(profiles, p)
+	}
+	srv := httptest.NewServer(serveHandler(profiles, []byte("secret")))
+	defer srv.Close()
+
+	tests := []struct {
+		path, want, absent string
+	}{
+		{path: "/banners.json", want: "5.15.0-generic", absent: "4.19.0-work"},
+		{path: "/lab/banners.json", want: "4.19.0-work", absent: "5.15.0-generic"},
+		{path: "/lab/lookup?q=4.19", want: "https://example.com/work.json"},
+		{path: "/lab/metrics", want: "basar_cache_entries 1"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), tt.want) ||
+			tt.absent != "" && strings.Contains(string(body), tt.absent) {
+			t.Errorf("GET %s = %d %q, expected %q without %q", tt.path, resp.StatusCode, body, tt.want, tt.absent)
+		}
+	}
+
+	// Each profile's webhook queues its own refresh
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/lab/hooks/update", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(profiles[1].trigger) != 1 || len(profiles[0].trigger) != 0 {
+		t.Errorf("webhook of /lab queued %d and %d refreshes, expected only the work profile's",
+			len(profiles[0].trigger), len(profiles[1].trigger))
+	}
+}
+
+func TestRunServeProfileConflicts(t *testing.T) {
+	for _, args := range [][]string{
+		{"serve", "--serve-profile", "work", "--serve-profile", "work,path=/other"},
+		{"serve", "--serve-profile", "a", "--serve-profile", "b,path=/a"},
+		{"serve", "--serve-profile", "work", "--cache-dir", t.TempDir()},
+		{"serve", "--serve-profile", "work,interval=soon"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(args, io.Discard, &stderr); code != exitError {
+			t.Errorf("run(%v) = %d, expected %d", args, code, exitError)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runSyncSymbols implements "basar sync-symbols [--mirror DIR]
+// [--symbols-dir DIR] [--match TEXT]... [--json]": it places the symbol
+// files basar mirror downloaded into volatility3's symbols directory, for
+// users running volatility3 on its local symbols rather than with -u.
+func runSyncSymbols(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("sync-symbols")
+	overrideFlags(fs, &o)
+
+	var mirror, dir string
+	var matches []string
+	var asJSON bool
+	fs.StringVar(&mirror, "mirror", "", "")
+	fs.StringVar(&dir, "symbols-dir", "", "")
+	fs.Var(stringList{&matches}, "match", "")
+	fs.BoolVar(&asJSON, "json", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "basar: sync-symbols takes no arguments, got %q (filter with --match)\n", fs.Arg(0))
+		return exitError
+	}
+
+	res, err := cache.New(config.NewWith(o)).SyncSymbols(mirror, dir, matches)
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		if dir == "" && !errors.Is(err, cache.ErrNoMirror) {
+			fmt.Fprintln(stderr, "basar: pass --symbols-dir to choose where the symbol files go")
+		}
+		return exitError
+	}
+
+	if asJSON {
+		if err := writeJSON(stdout, res, "both"); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding result: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, banner := range res.Missing {
+			fmt.Fprintf(stderr, "basar: not mirrored: %s\n", banner)
+		}
+		fmt.Fprintf(stdout, "%d symbol files in %s (%d synced from %s)\n",
+			len(res.Files), res.Dir, res.Synced, res.Mirror)
+		if dir != "" {
+			fmt.Fprintf(stdout, "use them with: volatility3 -s %s\n", res.Dir)
+		}
+	}
+
+	switch {
+	case len(res.Files) == 0:
+		return exitError
+	case len(res.Missing) > 0:
+		return exitPartial
+	}
+	return exitOK
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSyncSymbols(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"version":1,"linux":{"Linux version 5.15.0-generic":["https://isf.example.org/5.15.json.xz"],` +
+		`"Linux version 6.1.0-generic":["https://isf.example.org/6.1.json.xz"]}}`
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := filepath.Join(env.tmpDir, "mirror")
+	dir := filepath.Join(env.tmpDir, "symbols")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sync-symbols", "--mirror", mirror, "--symbols-dir", dir}, &stdout, &stderr); code != exitError {
+		t.Errorf("run(sync-symbols) without a mirror = %d, expected %d", code, exitError)
+	}
+
+	if err := os.MkdirAll(filepath.Join(mirror, "isf.example.org"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mirror, "isf.example.org", "5.15.json.xz"), []byte("isf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"sync-symbols", "--mirror", mirror, "--symbols-dir", dir}, &stdout, &stderr); code != exitPartial {
+		t.Fatalf("run(sync-symbols) = %d, expected %d; stderr: %s", code, exitPartial, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 symbol files in "+dir+" (1 synced") ||
+		!strings.Contains(stdout.String(), "volatility3 -s "+dir) ||
+		!strings.Contains(stderr.String(), "not mirrored: Linux version 6.1.0-generic") {
+		t.Errorf("sync-symbols output = %q, stderr %q", stdout.String(), stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "linux", "isf.example.org", "5.15.json.xz")); err != nil {
+		t.Errorf("synced file missing: %v", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"sync-symbols", "--mirror", mirror, "--symbols-dir", dir, "--match", "5.15"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run(sync-symbols --match 5.15) = %d, expected %d", code, exitOK)
+	}
+}
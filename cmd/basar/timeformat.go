@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// timeFormats lists the --time-format values: JSON outputs carry each
+// timestamp as RFC 3339 (KEY) and Unix seconds (KEY_unix), and each
+// duration as a Go duration string (KEY) and seconds (KEY_seconds);
+// "rfc3339" keeps only the string forms and "unix" only the numbers.
+var timeFormats = []string{"both", "rfc3339", "unix"}
+
+// checkTimeFormat validates a --time-format value.
+func checkTimeFormat(format string) error {
+	if !slices.Contains(timeFormats, format) {
+		return fmt.Errorf("unknown time format %q (want %s)", format, strings.Join(timeFormats, ", "))
+	}
+	return nil
+}
+
+// writeJSON writes v as indented JSON with the timestamps and durations
+// of format.
+func writeJSON(w io.Writer, v any, format string) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if format != "both" {
+		if raw, err = filterTimes(raw, format); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// filterTimes drops the forms of each timestamp and duration in raw that
+// format leaves out, keeping the order of object keys.
+func filterTimes(raw json.RawMessage, format string) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch tok {
+	case json.Delim('{'):
+		var keys []string
+		values := make(map[string]json.RawMessage)
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values[key] = v
+		}
+
+		buf.WriteByte('{')
+		for _, key := range keys {
+			if !keepTimeField(values, key, format) {
+				continue
+			}
+			v, err := filterTimes(values[key], format)
+			if err != nil {
+				return nil, err
+			}
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			buf.Write(k)
+			buf.WriteByte(':')
+			buf.Write(v)
+		}
+		buf.WriteByte('}')
+	case json.Delim('['):
+		buf.WriteByte('[')
+		for dec.More() {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			if v, err = filterTimes(v, format); err != nil {
+				return nil, err
+			}
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			buf.Write(v)
+		}
+		buf.WriteByte(']')
+	default:
+		return raw, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// keepTimeField reports whether key of an object with values survives
+// format: "unix" drops a string KEY with a KEY_unix or KEY_seconds
+// sibling, and "rfc3339" drops those siblings.
+func keepTimeField(values map[string]json.RawMessage, key, format string) bool {
+	for _, suffix := range []string{"_unix", "_seconds"} {
+		if base, ok := strings.CutSuffix(key, suffix); ok && isJSONString(values[base]) {
+			return format != "rfc3339"
+		}
+		if _, ok := values[key+suffix]; ok && isJSONString(values[key]) {
+			return format != "unix"
+		}
+	}
+	return true
+}
+
+// isJSONString reports whether v encodes a string.
+func isJSONString(v json.RawMessage) bool {
+	return len(v) > 0 && v[0] == '"'
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestWriteJSONTimeFormats(t *testing.T) {
+	at := time.Date(2024, 1, 31, 6, 0, 0, 0, time.UTC)
+	stats := cache.Stats{
+		Valid:      true,
+		AgeSeconds: 90,
+		UpdatedAt:  at,
+		Sources:    []cache.SourceStats{{Source: "https://example.com/a.json", LastFetch: at}},
+	}
+
+	tests := []struct {
+		format  string
+		want    []string
+		without []string
+	}{
+		{"both", []string{`"updated_at": "2024-01-31T06:00:00Z"`, `"updated_at_unix": 1706680800`, `"age": "1m30s"`, `"age_seconds": 90`, `"last_fetch_unix": 1706680800`}, nil},
+		{"rfc3339", []string{`"updated_at": "2024-01-31T06:00:00Z"`, `"age": "1m30s"`, `"last_fetch": "2024-01-31T06:00:00Z"`}, []string{"_unix", "age_seconds"}},
+		{"unix", []string{`"updated_at_unix": 1706680800`, `"age_seconds": 90`, `"last_fetch_unix": 1706680800`}, []string{`"updated_at":`, `"age":`, `"last_fetch":`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSON(&buf, stats, tt.format); err != nil {
+				t.Fatalf("writeJSON() failed: %v", err)
+			}
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %s:\n%s", want, out)
+				}
+			}
+			for _, unwanted := range tt.without {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("output should not contain %s:\n%s", unwanted, out)
+				}
+			}
+
+			// Key order follows the struct, not the alphabet
+			if strings.Index(out, `"valid"`) > strings.Index(out, `"sources"`) {
+				t.Errorf("key order changed:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCheckTimeFormat(t *testing.T) {
+	for _, format := range timeFormats {
+		if err := checkTimeFormat(format); err != nil {
+			t.Errorf("checkTimeFormat(%q) = %v", format, err)
+		}
+	}
+	if err := checkTimeFormat("iso"); err == nil {
+		t.Error("checkTimeFormat(iso) should fail")
+	}
+}
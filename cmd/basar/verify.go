@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// runVerifyURLs implements "basar verify-urls [--json] [--time-format F]
+// [banner]": it checks the symbol URLs of matching banners (all banners by
+// default) and records the outcome in the URL history.
+func runVerifyURLs(args []string, o config.Overrides, stdout, stderr io.Writer) int {
+	fs := newFlagSet("verify-urls")
+	overrideFlags(fs, &o)
+
+	var asJSON bool
+	fs.BoolVar(&asJSON, "json", false, "")
+	timeFormat := fs.String("time-format", "both", "")
+
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	if err := checkTimeFormat(*timeFormat); err != nil {
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		return exitError
+	}
+	query := strings.Join(rest, " ")
+
+	ctx, cancel := commandContext(o, stderr)
+	defer cancel()
+
+	checks, err := cache.New(config.NewWith(o)).VerifyURLs(ctx, query)
+	if err != nil {
+		if errors.Is(err, cache.ErrNoCache) {
+			fmt.Fprintln(stderr, "basar: no cache; run basar --update first")
+			return exitInvalid
+		}
+		fmt.Fprintf(stderr, "basar: %v\n", err)
+		if checks == nil {
+			return exitError
+		}
+	}
+
+	failed := 0
+	for _, ch := range checks {
+		if !ch.OK {
+			failed++
+		}
+	}
+
+	if asJSON {
+		if err := writeJSON(stdout, checks, *timeFormat); err != nil {
+			fmt.Fprintf(stderr, "basar: encoding checks: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, ch := range checks {
+			switch {
+			case ch.OK:
+				fmt.Fprintf(stdout, "ok    %s\n", ch.URL)
+			case ch.Dead:
+				fmt.Fprintf(stdout, "dead  %s: %s (%d failures in a row)\n",
+					ch.URL, ch.Error, ch.Health.ConsecutiveFailures)
+			default:
+				fmt.Fprintf(stdout, "fail  %s: %s\n", ch.URL, ch.Error)
+			}
+		}
+		fmt.Fprintf(stdout, "%d of %d URLs reachable\n", len(checks)-failed, len(checks))
+	}
+
+	if failed > 0 || err != nil {
+		return exitError
+	}
+	return exitOK
+}
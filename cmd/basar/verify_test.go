@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/cache"
+)
+
+func TestRunVerifyURLs(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.json" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	index := `{"version":1,"linux":{` +
+		`"Linux version 5.15.0":["` + server.URL + `/live.json"],` +
+		`"Linux version 6.1.0":["` + server.URL + `/gone.json"]}}`
+	if err := os.MkdirAll(filepath.Dir(env.cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(env.cacheFile, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"verify-urls", "5.15"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(verify-urls 5.15) = %d, expected %d (stderr: %s)", code, exitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 of 1 URLs reachable") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"verify-urls", "--json"}, &stdout, &stderr); code != exitError {
+		t.Fatalf("run(verify-urls --json) = %d, expected %d", code, exitError)
+	}
+	var checks []cache.URLCheck
+	if err := json.Unmarshal(stdout.Bytes(), &checks); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %+v", checks)
+	}
+	for _, ch := range checks {
+		if strings.HasSuffix(ch.URL, "/live.json") && ch.Health.Checks != 2 {
+			t.Errorf("history should accumulate across runs, got %+v", ch)
+		}
+		if strings.HasSuffix(ch.URL, "/gone.json") && ch.OK {
+			t.Errorf("missing URL should fail, got %+v", ch)
+		}
+	}
+}
+
+func TestRunVerifyURLsNoCache(t *testing.T) {
+	env := &testEnv{}
+	env.setup(t)
+	defer env.teardown()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"verify-urls"}, &stdout, &stderr); code != exitInvalid {
+		t.Errorf("run(verify-urls) = %d, expected %d", code, exitInvalid)
+	}
+}
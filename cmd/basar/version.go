@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// Set at build time, e.g. with -ldflags "-X main.version=v1.2.3"; see the
+// Makefile. Builds without them fall back on what the Go toolchain
+// embeds: the module version for go install, the VCS revision and time
+// for builds from a checkout.
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Identify this build in HTTP requests, so source operators can tell
+// releases apart
+func init() {
+	fetcher.UserAgent = "basar/" + strings.TrimPrefix(currentBuild().Version, "v")
+}
+
+// currentBuild returns the build info of the running binary. The version
+// is "dev" when neither the linker nor the toolchain set one, as for
+// builds from a checkout without a tag.
+func currentBuild() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" &&
+			!strings.HasPrefix(bi.Main.Version, "v0.0.0-") {
+			info.Version = bi.Main.Version
+		}
+		modified := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+					if len(info.Commit) > 12 {
+						info.Commit = info.Commit[:12]
+					}
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// printVersion writes the build info as one line, or as JSON.
+func printVersion(w io.Writer, asJSON bool) error {
+	info := currentBuild()
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	line := "basar " + info.Version
+	var details []string
+	if info.Commit != "" {
+		details = append(details, "commit "+info.Commit)
+	}
+	if info.BuildDate != "" {
+		details = append(details, "built "+info.BuildDate)
+	}
+	details = append(details, info.GoVersion, info.Platform)
+	_, err := fmt.Fprintf(w, "%s (%s)\n", line, strings.Join(details, ", "))
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestRunVersion(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.4.0", "abc1234", "2024-05-01T12:00:00Z"
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--version"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--version) = %d; stderr: %s", code, stderr.String())
+	}
+	want := "basar v1.4.0 (commit abc1234, built 2024-05-01T12:00:00Z, " + runtime.Version()
+	if !strings.HasPrefix(stdout.String(), want) {
+		t.Errorf("--version = %q, expected it to start with %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--version", "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run(--version --json) = %d; stderr: %s", code, stderr.String())
+	}
+	var info BuildInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		t.Fatalf("--version --json is not JSON: %v", err)
+	}
+	if info.Version != "v1.4.0" || info.Commit != "abc1234" || info.GoVersion != runtime.Version() ||
+		info.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("--version --json = %+v", info)
+	}
+}
+
+func TestCurrentBuildDefaults(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = ""
+	if got := currentBuild().Version; got == "" {
+		t.Error("currentBuild() has no version, expected a fallback")
+	}
+	if !strings.HasPrefix(fetcher.UserAgent, "basar/") || fetcher.UserAgent == "basar/1.0" {
+		t.Errorf("UserAgent = %q, expected the build's version", fetcher.UserAgent)
+	}
+}
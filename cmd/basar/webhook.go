@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxWebhookBody bounds the request bodies read for signature checks.
+const maxWebhookBody = 1 << 20
+
+// updateWebhook serves /hooks/update: an authenticated request queues an
+// immediate refresh in the serve loop. Requests carry the secret as a
+// bearer token, or sign their body with it like GitHub webhooks do
+// (X-Hub-Signature-256).
+type updateWebhook struct {
+	secret  []byte
+	trigger chan<- struct{}
+}
+
+func (h *updateWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !h.authorized(r, body) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// GitHub sends a ping when the webhook is created
+	if r.Header.Get("X-GitHub-Event") == "ping" {
+		_, _ = io.WriteString(w, "pong\n")
+		return
+	}
+
+	// Requests arriving while an update is queued share it
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = io.WriteString(w, "update queued\n")
+}
+
+// authorized reports whether r carries a valid signature of body or the
+// secret as a bearer token.
+func (h *updateWebhook) authorized(r *http.Request, body []byte) bool {
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, h.secret)
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), h.secret) == 1
+}
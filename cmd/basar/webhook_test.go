@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdateWebhook(t *testing.T) {
+	trigger := make(chan struct{}, 1)
+	hook := &updateWebhook{secret: []byte("s3cret"), trigger: trigger}
+
+	body := `{"ref":"refs/heads/master"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		method  string
+		header  map[string]string
+		want    int
+		trigger bool
+	}{
+		{"bearer token", http.MethodPost, map[string]string{"Authorization": "Bearer s3cret"}, http.StatusAccepted, true},
+		{"github signature", http.MethodPost, map[string]string{"X-Hub-Signature-256": signature, "X-GitHub-Event": "push"}, http.StatusAccepted, true},
+		{"github ping", http.MethodPost, map[string]string{"X-Hub-Signature-256": signature, "X-GitHub-Event": "ping"}, http.StatusOK, false},
+		{"wrong token", http.MethodPost, map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized, false},
+		{"bad signature", http.MethodPost, map[string]string{"X-Hub-Signature-256": "sha256=00ff", "Authorization": "Bearer s3cret"}, http.StatusUnauthorized, false},
+		{"no credentials", http.MethodPost, nil, http.StatusUnauthorized, false},
+		{"get", http.MethodGet, map[string]string{"Authorization": "Bearer s3cret"}, http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/hooks/update", strings.NewReader(body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			hook.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, expected %d", rec.Code, tt.want)
+			}
+			select {
+			case <-trigger:
+				if !tt.trigger {
+					t.Error("update queued without a valid request")
+				}
+			default:
+				if tt.trigger {
+					t.Error("update not queued")
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateWebhookCoalesces(t *testing.T) {
+	trigger := make(chan struct{}, 1)
+	hook := &updateWebhook{secret: []byte("s3cret"), trigger: trigger}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/update", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		hook.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d = %d, expected 202 while an update is queued", i, rec.Code)
+		}
+	}
+	if len(trigger) != 1 {
+		t.Errorf("queued updates = %d, expected 1", len(trigger))
+	}
+}
@@ -0,0 +1,376 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// AirgapVersion is the archive layout PackAirgap writes and UnpackAirgap
+// reads.
+const AirgapVersion = 1
+
+// The entries of an air-gap archive: the cache as a bundle, the mirrored
+// symbol files under airgapMirrorDir, and last the manifest listing them
+// and its signature.
+const (
+	airgapBundleName    = "bundle.tar.gz"
+	airgapMirrorDir     = "mirror/"
+	airgapManifestName  = "airgap.json"
+	airgapSignatureName = "airgap.sig"
+)
+
+// maxAirgapManifest bounds the manifest and signature read from an
+// archive, before either is verified.
+const maxAirgapManifest = 64 << 20
+
+// AirgapManifest describes the files of an air-gap archive. It is signed
+// with the packing side's key, so the receiving side can tell the archive
+// comes from it and arrived intact.
+type AirgapManifest struct {
+	Version    int       `json:"version"`
+	Created    time.Time `json:"created"`
+	Generation uint64    `json:"generation,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+	// Key is the AirgapKeyID of the key the manifest is signed with.
+	Key   string       `json:"key"`
+	Files []BundleFile `json:"files"`
+}
+
+// MirrorFiles counts the mirrored symbol files in the archive.
+func (m *AirgapManifest) MirrorFiles() int {
+	n := 0
+	for _, f := range m.Files {
+		if strings.HasPrefix(f.Name, airgapMirrorDir) {
+			n++
+		}
+	}
+	return n
+}
+
+// AirgapKeyID returns a short fingerprint of a public key, to tell keys
+// apart in manifests and messages.
+func AirgapKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PackAirgap writes to w an uncompressed tar holding the cache as written
+// by ExportBundle and the symbol files in mirror (the cache's mirror
+// directory when empty; none when it does not exist), followed by a
+// manifest with the checksum of each and the manifest's ed25519 signature
+// with key. The symbol files and the bundle are compressed already, so the
+// tar is not.
+func (c *Cache) PackAirgap(w io.Writer, key ed25519.PrivateKey, mirror string) (*AirgapManifest, error) {
+	var bundle bytes.Buffer
+	if err := c.ExportBundle(&bundle); err != nil {
+		return nil, err
+	}
+	if mirror == "" {
+		mirror = c.mirrorDir()
+	}
+	files, err := mirrorFiles(mirror)
+	if err != nil {
+		return nil, fmt.Errorf("listing mirror: %w", err)
+	}
+
+	meta := c.loadMeta()
+	manifest := &AirgapManifest{
+		Version:    AirgapVersion,
+		Created:    time.Now().UTC(),
+		Generation: meta.Generation,
+		UpdatedAt:  meta.UpdatedAt,
+		Key:        AirgapKeyID(key.Public().(ed25519.PublicKey)),
+	}
+
+	tw := tar.NewWriter(w)
+	add := func(name string, r io.Reader, size int64, modTime time.Time) error {
+		hdr := &tar.Header{Name: name, Mode: int64(FileMode), Size: size, ModTime: modTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(tw, h), r, size); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, BundleFile{name, size, hex.EncodeToString(h.Sum(nil))})
+		return nil
+	}
+	if err := add(airgapBundleName, &bundle, int64(bundle.Len()), manifest.Created); err != nil {
+		return nil, err
+	}
+	for _, rel := range files {
+		if err := addFile(add, airgapMirrorDir+rel, filepath.Join(mirror, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw)) + "\n")
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{airgapManifestName, raw}, {airgapSignatureName, sig}} {
+		hdr := &tar.Header{Name: entry.name, Mode: int64(FileMode), Size: int64(len(entry.data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, tw.Close()
+}
+
+// addFile passes the file at local to add under name.
+func addFile(add func(string, io.Reader, int64, time.Time) error, name, local string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return add(name, f, info.Size(), info.ModTime())
+}
+
+// mirrorFiles lists the symbol files under a mirror directory as
+// slash-separated paths, leaving out its index, which points at this
+// machine's paths, and interrupted downloads. A missing directory has none.
+func mirrorFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == dir {
+			return fs.SkipAll
+		}
+		if err != nil || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "banners.json" {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// VerifyAirgap checks the archive read from r as UnpackAirgap does, the
+// bundle in it included, without installing anything.
+func (c *Cache) VerifyAirgap(r io.Reader, pub ed25519.PublicKey) (*AirgapManifest, error) {
+	staging, err := os.MkdirTemp("", "basar-airgap-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := unpackAirgap(r, pub, staging)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(staging, airgapBundleName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := c.unpackBundle(f, filepath.Join(staging, "bundle")); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// UnpackAirgap installs the archive read from r, as written by PackAirgap:
+// once its signature verifies with pub and every file matches the
+// manifest, the cache is imported from the bundle as ImportBundle does,
+// the symbol files are placed in mirror (the cache's mirror directory when
+// empty), and the mirror's banners.json is rewritten to point at them. An
+// archive failing the checks fails with ErrInvalidBundle and changes
+// nothing.
+func (c *Cache) UnpackAirgap(r io.Reader, pub ed25519.PublicKey, mirror string) (*AirgapManifest, error) {
+	if err := os.MkdirAll(c.cfg.CacheDir, DirMode); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(c.cfg.CacheDir, ".airgap-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := unpackAirgap(r, pub, staging)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(staging, airgapBundleName))
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.ImportBundle(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if mirror == "" {
+		mirror = c.mirrorDir()
+	}
+	files := 0
+	for _, file := range manifest.Files {
+		rel, ok := strings.CutPrefix(file.Name, airgapMirrorDir)
+		if !ok {
+			continue
+		}
+		from := filepath.Join(staging, filepath.FromSlash(file.Name))
+		if err := installFile(from, filepath.Join(mirror, filepath.FromSlash(rel))); err != nil {
+			return nil, fmt.Errorf("installing %s: %w", file.Name, err)
+		}
+		files++
+	}
+	if files > 0 {
+		if err := c.indexMirror(mirror); err != nil {
+			return nil, fmt.Errorf("indexing mirror: %w", err)
+		}
+	}
+
+	c.log.Info("unpacked air-gap archive", "files", len(manifest.Files), "mirrored", files,
+		"generation", manifest.Generation, "key", manifest.Key)
+	return manifest, nil
+}
+
+// unpackAirgap extracts the archive read from r into dir and verifies its
+// manifest's signature with pub and every file against the manifest,
+// which it returns.
+func unpackAirgap(r io.Reader, pub ed25519.PublicKey, dir string) (*AirgapManifest, error) {
+	var raw, sig []byte
+	got := make(map[string]BundleFile)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, hdr.Name)
+		}
+
+		switch hdr.Name {
+		case airgapManifestName, airgapSignatureName:
+			data, err := io.ReadAll(io.LimitReader(tr, maxAirgapManifest))
+			if err != nil {
+				return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidBundle, hdr.Name, err)
+			}
+			if hdr.Name == airgapManifestName {
+				raw = data
+			} else {
+				sig = data
+			}
+			continue
+		}
+		if !validAirgapName(hdr.Name) {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrInvalidBundle, hdr.Name)
+		}
+		if _, ok := got[hdr.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate file %s", ErrInvalidBundle, hdr.Name)
+		}
+		file, err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(hdr.Name)))
+		if err != nil {
+			return nil, err
+		}
+		file.Name = hdr.Name
+		got[hdr.Name] = file
+	}
+
+	if raw == nil || sig == nil {
+		return nil, fmt.Errorf("%w: no signed %s", ErrInvalidBundle, airgapManifestName)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(pub, raw, decoded) {
+		return nil, fmt.Errorf("%w: the manifest is not signed by key %s", ErrInvalidBundle, AirgapKeyID(pub))
+	}
+	manifest := new(AirgapManifest)
+	if err := json.Unmarshal(raw, manifest); err != nil {
+		return nil, fmt.Errorf("%w: reading manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest.Version > AirgapVersion {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, newerFormat("the archive", manifest.Version, AirgapVersion))
+	}
+	if manifest.Version != AirgapVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, manifest.Version)
+	}
+	if len(got) != len(manifest.Files) {
+		return nil, fmt.Errorf("%w: manifest lists %d files, archive has %d", ErrInvalidBundle, len(manifest.Files), len(got))
+	}
+	for _, want := range manifest.Files {
+		if got[want.Name] != want {
+			return nil, fmt.Errorf("%w: %s does not match its checksum", ErrInvalidBundle, want.Name)
+		}
+	}
+	if _, ok := got[airgapBundleName]; !ok {
+		return nil, fmt.Errorf("%w: no %s", ErrInvalidBundle, airgapBundleName)
+	}
+	return manifest, nil
+}
+
+// validAirgapName reports whether name is the bundle or a file under the
+// mirror directory that stays within it.
+func validAirgapName(name string) bool {
+	if name == airgapBundleName {
+		return true
+	}
+	rel, ok := strings.CutPrefix(name, airgapMirrorDir)
+	return ok && rel != "" && rel != "banners.json" && path.Clean(rel) == rel &&
+		!strings.HasPrefix(rel, "/") && !strings.HasPrefix(rel, "../") && rel != ".." && !strings.Contains(rel, `\`)
+}
+
+// indexMirror writes dir/banners.json as Mirror does, listing the symbol
+// files already in dir ahead of their banners' remote URLs, without
+// downloading anything.
+func (c *Cache) indexMirror(dir string) error {
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return ErrNoCache
+	}
+	index := &fetcher.BannerData{Version: banners.Version, Linux: make(map[string][]string, len(banners.Linux))}
+	for banner, urls := range banners.Linux {
+		index.Linux[banner] = urls
+		for _, u := range urls {
+			file, err := mirrorPath(dir, u)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(file); err == nil {
+				index.Linux[banner] = append([]string{fileURI(file)}, urls...)
+				break
+			}
+		}
+	}
+	index, _, err := c.forVol3(index)
+	if err != nil {
+		return err
+	}
+	return writeBanners(filepath.Join(dir, "banners.json"), index)
+}
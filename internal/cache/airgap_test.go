@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// airgapKey returns a fixed ed25519 key, so test failures reproduce.
+func airgapKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+func TestAirgapRoundTrip(t *testing.T) {
+	src := testConfig(t)
+	banners := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0-ubuntu": {"https://isf.example.org/ubuntu/5.15.json.xz"},
+		"Linux version 6.1.0-debian":  {"https://isf.example.org/debian/6.1.json.xz"},
+	}}
+	if err := writeBanners(src.CacheFile, banners); err != nil {
+		t.Fatal(err)
+	}
+	packer := New(src)
+	mirrored := filepath.Join(packer.mirrorDir(), "isf.example.org", "ubuntu", "5.15.json.xz")
+	if err := os.MkdirAll(filepath.Dir(mirrored), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string]string{
+		mirrored: "isf:5.15",
+		filepath.Join(packer.mirrorDir(), "banners.json"):    "{}",
+		filepath.Join(filepath.Dir(mirrored), ".download-1"): "partial",
+	} {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := airgapKey(1)
+	var archive bytes.Buffer
+	manifest, err := packer.PackAirgap(&archive, key, "")
+	if err != nil {
+		t.Fatalf("PackAirgap() failed: %v", err)
+	}
+	if len(manifest.Files) != 2 || manifest.MirrorFiles() != 1 || manifest.Key != AirgapKeyID(key.Public().(ed25519.PublicKey)) {
+		t.Errorf("manifest = %+v, expected the bundle and one mirrored file, signed by the key", manifest)
+	}
+
+	pub := key.Public().(ed25519.PublicKey)
+	if _, err := New(testConfig(t)).VerifyAirgap(bytes.NewReader(archive.Bytes()), pub); err != nil {
+		t.Errorf("VerifyAirgap() failed: %v", err)
+	}
+
+	dst := testConfig(t)
+	c := New(dst)
+	if _, err := c.UnpackAirgap(bytes.NewReader(archive.Bytes()), pub, ""); err != nil {
+		t.Fatalf("UnpackAirgap() failed: %v", err)
+	}
+	if got := c.Stats().Entries; got != 2 {
+		t.Errorf("unpacked cache has %d entries, expected 2", got)
+	}
+	file := filepath.Join(c.mirrorDir(), "isf.example.org", "ubuntu", "5.15.json.xz")
+	if data, err := os.ReadFile(file); err != nil || string(data) != "isf:5.15" {
+		t.Errorf("unpacked symbol file = %q, %v", data, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(c.mirrorDir(), "banners.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index fetcher.BannerData
+	if err := json.Unmarshal(raw, &index); err != nil {
+		t.Fatal(err)
+	}
+	if urls := index.Linux["Linux version 5.15.0-ubuntu"]; len(urls) != 2 || urls[0] != fileURI(file) {
+		t.Errorf("mirror index URLs = %v, expected the unpacked file first", urls)
+	}
+	if urls := index.Linux["Linux version 6.1.0-debian"]; len(urls) != 1 {
+		t.Errorf("unmirrored banner URLs = %v, expected them unchanged", urls)
+	}
+}
+
+func TestUnpackAirgapRejects(t *testing.T) {
+	src := testConfig(t)
+	writeBannerSource(t, src.CacheFile, bannerURLs("Linux version 5.15.0"))
+	key := airgapKey(1)
+	var archive bytes.Buffer
+	if _, err := New(src).PackAirgap(&archive, key, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// retar copies the archive, passing each entry's contents through edit
+	retar := func(edit func(name string, data []byte) []byte) []byte {
+		var out bytes.Buffer
+		tw := tar.NewWriter(&out)
+		tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(tr)
+			data = edit(hdr.Name, data)
+			hdr.Size = int64(len(data))
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = tw.Write(data)
+		}
+		_ = tw.Close()
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		archive []byte
+		pub     ed25519.PublicKey
+	}{
+		{"other key", archive.Bytes(), airgapKey(2).Public().(ed25519.PublicKey)},
+		{"tampered bundle", retar(func(name string, data []byte) []byte {
+			if name == airgapBundleName {
+				data[len(data)-1] ^= 1
+			}
+			return data
+		}), key.Public().(ed25519.PublicKey)},
+		{"tampered manifest", retar(func(name string, data []byte) []byte {
+			if name == airgapManifestName {
+				return bytes.Replace(data, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+			}
+			return data
+		}), key.Public().(ed25519.PublicKey)},
+		{"unsigned", retar(func(name string, data []byte) []byte {
+			if name == airgapSignatureName {
+				return nil
+			}
+			return data
+		}), key.Public().(ed25519.PublicKey)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			if _, err := New(cfg).UnpackAirgap(bytes.NewReader(tt.archive), tt.pub, ""); !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("UnpackAirgap() = %v, expected ErrInvalidBundle", err)
+			}
+			if _, err := os.Stat(cfg.CacheFile); !os.IsNotExist(err) {
+				t.Errorf("rejected archive left a cache: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidAirgapName(t *testing.T) {
+	tests := map[string]bool{
+		airgapBundleName:                      true,
+		"mirror/isf.example.org/5.15.json.xz": true,
+		"mirror/banners.json":                 false,
+		"mirror/../banners.json":              false,
+		"mirror//etc/passwd":                  false,
+		"mirror/a/../../x":                    false,
+		"banners.json":                        false,
+	}
+	for name, want := range tests {
+		if got := validAirgapName(name); got != want {
+			t.Errorf("validAirgapName(%q) = %v, expected %v", name, got, want)
+		}
+	}
+}
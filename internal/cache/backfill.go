@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// fileChecksum returns the SHA-256 of the file at path in hex.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stampMeta records the write time and checksum of the cache file in meta.
+func (c *Cache) stampMeta(meta *fetcher.MetaCache) error {
+	info, err := os.Stat(c.cfg.CacheFile)
+	if err != nil {
+		return err
+	}
+	sum, err := fileChecksum(c.cfg.CacheFile)
+	if err != nil {
+		return err
+	}
+	meta.UpdatedAt = info.ModTime()
+	meta.Checksum = sum
+	return nil
+}
+
+// backfillMeta completes metadata written by older versions, which did not
+// record the cache's write time, checksum, or generation: they are taken
+// from the cache file, which counts as the first generation. It reports
+// whether meta changed.
+func (c *Cache) backfillMeta(meta *fetcher.MetaCache) bool {
+	if !meta.UpdatedAt.IsZero() && meta.Checksum != "" && meta.Generation > 0 {
+		return false
+	}
+	if _, ok := c.Path(); !ok {
+		return false
+	}
+
+	if meta.UpdatedAt.IsZero() || meta.Checksum == "" {
+		if err := c.stampMeta(meta); err != nil {
+			c.log.Warn("reading cache for metadata failed", "error", err)
+			return false
+		}
+	}
+	if meta.Generation == 0 {
+		meta.Generation = 1
+	}
+	return true
+}
+
+// loadBackfilledMeta loads the metadata, backfilling it for a cache
+// written by an older version. The result is persisted, so this happens
+// once, unless an update holds the lock; that update records it anyway.
+func (c *Cache) loadBackfilledMeta() *fetcher.MetaCache {
+	meta := c.loadMeta()
+	if !c.backfillMeta(meta) {
+		return meta
+	}
+	if err := c.acquireLock(); err != nil {
+		return meta
+	}
+	defer c.releaseLock()
+
+	// Reload under the lock, in case an update finished in between
+	meta = c.loadMeta()
+	if c.backfillMeta(meta) {
+		if err := c.saveMeta(meta); err != nil {
+			c.log.Warn("saving backfilled metadata failed", "error", err)
+		} else {
+			c.log.Info("backfilled metadata of a cache from an older version",
+				"generation", meta.Generation, "updated_at", meta.UpdatedAt)
+		}
+	}
+	return meta
+}
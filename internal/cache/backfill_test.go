@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLegacyCache writes a cache file with metadata lacking the fields
+// older versions did not record.
+func writeLegacyCache(t *testing.T, c *Cache) string {
+	t.Helper()
+	raw := []byte(`{"version":1,"linux":{"Linux version 5.15.0":["https://example.com/a.json"]}}` + "\n")
+	if err := os.WriteFile(c.cfg.CacheFile, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.cfg.MetaFile, []byte(`{"sources":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBackfillMeta(t *testing.T) {
+	c := New(testConfig(t))
+	sum := writeLegacyCache(t, c)
+	info, _ := os.Stat(c.cfg.CacheFile)
+
+	stats := c.Stats()
+	if stats.Generation != 1 || stats.Checksum != sum || !stats.UpdatedAt.Equal(info.ModTime()) {
+		t.Errorf("Stats() = generation %d, checksum %s, updated %v; expected 1, %s, %v",
+			stats.Generation, stats.Checksum, stats.UpdatedAt, sum, info.ModTime())
+	}
+
+	// The backfilled metadata is persisted
+	meta := c.loadMeta()
+	if meta.Generation != 1 || meta.Checksum != sum || meta.UpdatedAt.IsZero() {
+		t.Errorf("persisted metadata = %+v", meta)
+	}
+}
+
+func TestBackfillMetaLocked(t *testing.T) {
+	c := New(testConfig(t))
+	sum := writeLegacyCache(t, c)
+	if err := c.acquireLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.releaseLock()
+
+	// A running update owns the metadata; the backfill is only reported
+	if got := c.Stats().Checksum; got != sum {
+		t.Errorf("Stats().Checksum = %q, expected %s", got, sum)
+	}
+	if meta := c.loadMeta(); meta.Checksum != "" || meta.Generation != 0 {
+		t.Errorf("metadata written under another's lock: %+v", meta)
+	}
+}
+
+func TestUpdateStampsMeta(t *testing.T) {
+	cfg := testConfig(t)
+	src := filepath.Join(cfg.ConfigDir, "source.json")
+	writeBannerSource(t, src, bannerURLs("a"))
+	cfg.Sources = []string{src}
+	c := New(cfg)
+
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := fileChecksum(cfg.CacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := c.loadMeta(); meta.Generation != 1 || meta.Checksum != sum || meta.UpdatedAt.IsZero() {
+		t.Errorf("metadata after update = generation %d, checksum %q, updated %v", meta.Generation, meta.Checksum, meta.UpdatedAt)
+	}
+}
@@ -0,0 +1,339 @@
+package cache
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrInvalidBundle indicates a bundle that is malformed or fails its
+// integrity checks.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// BundleVersion is the bundle layout ExportBundle writes and ImportBundle
+// reads.
+const BundleVersion = 1
+
+// bundleManifestName is the last entry of a bundle, listing the others.
+const bundleManifestName = "manifest.json"
+
+// BundleManifest describes the files of a bundle, so an import can verify
+// it arrived intact.
+type BundleManifest struct {
+	Version    int          `json:"version"`
+	Created    time.Time    `json:"created"`
+	Generation uint64       `json:"generation,omitempty"`
+	UpdatedAt  time.Time    `json:"updated_at,omitempty"`
+	Files      []BundleFile `json:"files"`
+}
+
+// BundleFile is one file of a bundle with its size and SHA-256 in hex.
+type BundleFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// bundlePath maps a file name within a bundle to where it lives locally:
+// the cache, provenance, banner metadata, tombstones, URL trust, and source
+// metadata files, and the snapshots by name.
+// It reports false for any other name.
+func (c *Cache) bundlePath(name string) (string, bool) {
+	switch name {
+	case "banners.json":
+		return c.cfg.CacheFile, true
+	case "provenance.json":
+		return c.provenancePath(), true
+	case "banner-metadata.json":
+		return c.metadataPath(), true
+	case "tombstones.json":
+		return c.tombstonesPath(), true
+	case "url-trust.json":
+		return c.urlTrustPath(), true
+	case "meta.json":
+		return c.cfg.MetaFile, true
+	}
+	dir, file := path.Split(name)
+	if dir != "snapshots/" || !strings.HasSuffix(file, ".json") || file != filepath.Base(file) {
+		return "", false
+	}
+	return filepath.Join(c.snapshotDir(), file), true
+}
+
+// bundleFiles lists the local files ExportBundle packs, by bundle name.
+func (c *Cache) bundleFiles() []string {
+	names := []string{"banners.json", "provenance.json", "banner-metadata.json", "tombstones.json", "url-trust.json", "meta.json"}
+	entries, _ := os.ReadDir(c.snapshotDir())
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, "snapshots/"+e.Name())
+		}
+	}
+	return names
+}
+
+// ExportBundle writes the cache, its provenance and metadata, and the
+// per-source snapshots to w as a gzip-compressed tar, followed by a
+// manifest with the checksum of each. The files are opened before any is
+// read, so an update finishing meanwhile cannot mix two versions.
+func (c *Cache) ExportBundle(w io.Writer) error {
+	type source struct {
+		name string
+		f    *os.File
+	}
+	var sources []source
+	defer func() {
+		for _, s := range sources {
+			s.f.Close()
+		}
+	}()
+	for _, name := range c.bundleFiles() {
+		local, _ := c.bundlePath(name)
+		f, err := os.Open(local)
+		if os.IsNotExist(err) && name != "banners.json" {
+			continue
+		}
+		if os.IsNotExist(err) {
+			return ErrNoCache
+		}
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source{name, f})
+	}
+
+	meta := c.loadMeta()
+	manifest := BundleManifest{
+		Version:    BundleVersion,
+		Created:    time.Now().UTC(),
+		Generation: meta.Generation,
+		UpdatedAt:  meta.UpdatedAt,
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, s := range sources {
+		info, err := s.f.Stat()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: s.name, Mode: int64(FileMode), Size: info.Size(), ModTime: info.ModTime()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(tw, h), s.f, info.Size()); err != nil {
+			return fmt.Errorf("writing %s: %w", s.name, err)
+		}
+		manifest.Files = append(manifest.Files, BundleFile{s.name, info.Size(), hex.EncodeToString(h.Sum(nil))})
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: bundleManifestName, Mode: int64(FileMode), Size: int64(len(raw)), ModTime: manifest.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(raw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ImportBundle replaces the cache with the one in the bundle read from r,
+// as written by ExportBundle; plain tars are accepted too. Every file is
+// checked against the manifest and the cache must parse before anything is
+// replaced; an invalid bundle leaves the cache untouched and fails with
+// ErrInvalidBundle. The cache keeps the write time recorded in the bundle,
+// so its age carries over.
+func (c *Cache) ImportBundle(r io.Reader) (*BundleManifest, error) {
+	if err := c.lockForUpdate(); err != nil {
+		return nil, err
+	}
+	defer c.releaseLock()
+
+	staging, err := os.MkdirTemp(c.cfg.CacheDir, ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := c.unpackBundle(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	// Install the cache last, so it never refers to metadata or snapshots
+	// that are not there yet
+	names := make([]string, 0, len(manifest.Files))
+	present := make(map[string]bool, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if file.Name != "banners.json" {
+			names = append(names, file.Name)
+		}
+		present[file.Name] = true
+	}
+	for _, sidecar := range []string{"banner-metadata.json", "tombstones.json"} {
+		// Sidecars left from the replaced cache would describe other banners
+		if local, _ := c.bundlePath(sidecar); !present[sidecar] {
+			if err := os.Remove(local); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range append(names, "banners.json") {
+		local, _ := c.bundlePath(name)
+		if err := installFile(filepath.Join(staging, filepath.FromSlash(name)), local); err != nil {
+			return nil, fmt.Errorf("installing %s: %w", name, err)
+		}
+	}
+	if !manifest.UpdatedAt.IsZero() {
+		_ = os.Chtimes(c.cfg.CacheFile, time.Time{}, manifest.UpdatedAt)
+	}
+	if err := c.saveDiskIndex(c.loadProvenance()); err != nil {
+		c.log.Warn("saving disk index failed", "error", err)
+	}
+
+	c.log.Info("imported bundle", "files", len(manifest.Files),
+		"generation", manifest.Generation, "created", manifest.Created)
+	return manifest, nil
+}
+
+// unpackBundle extracts the bundle read from r into dir and verifies it
+// against its manifest, which it returns.
+func (c *Cache) unpackBundle(r io.Reader, dir string) (*BundleManifest, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var manifest *BundleManifest
+	got := make(map[string]BundleFile)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, hdr.Name)
+		}
+
+		if hdr.Name == bundleManifestName {
+			manifest = new(BundleManifest)
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: reading manifest: %v", ErrInvalidBundle, err)
+			}
+			continue
+		}
+		if _, ok := c.bundlePath(hdr.Name); !ok {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrInvalidBundle, hdr.Name)
+		}
+		if _, ok := got[hdr.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate file %s", ErrInvalidBundle, hdr.Name)
+		}
+		file, err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(hdr.Name)))
+		if err != nil {
+			return nil, err
+		}
+		file.Name = hdr.Name
+		got[hdr.Name] = file
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no %s", ErrInvalidBundle, bundleManifestName)
+	}
+	if manifest.Version > BundleVersion {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, newerFormat("the bundle", manifest.Version, BundleVersion))
+	}
+	if manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, manifest.Version)
+	}
+	if len(got) != len(manifest.Files) {
+		return nil, fmt.Errorf("%w: manifest lists %d files, bundle has %d", ErrInvalidBundle, len(manifest.Files), len(got))
+	}
+	hasCache := false
+	for _, want := range manifest.Files {
+		if got[want.Name] != want {
+			return nil, fmt.Errorf("%w: %s does not match its checksum", ErrInvalidBundle, want.Name)
+		}
+		hasCache = hasCache || want.Name == "banners.json"
+	}
+	if !hasCache {
+		return nil, fmt.Errorf("%w: no banners.json", ErrInvalidBundle)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "banners.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := fetcher.CountBanners(f); err != nil {
+		return nil, fmt.Errorf("%w: banners.json: %v", ErrInvalidBundle, err)
+	}
+	return manifest, nil
+}
+
+// extractFile writes the contents of r to path, returning their size and
+// checksum.
+func extractFile(r io.Reader, path string) (BundleFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		return BundleFile{}, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FileMode)
+	if err != nil {
+		return BundleFile{}, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		f.Close()
+		return BundleFile{}, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return BundleFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, f.Close()
+}
+
+// installFile moves from to to, copying when they are on different
+// filesystems (the state directory need not share the cache's).
+func installFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), DirMode); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	writeBannerSource(t, local, bannerURLs("a", "b"))
+	cfg.Sources = []string{local}
+	src := New(cfg)
+	if _, err := src.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle bytes.Buffer
+	if err := src.Export(&bundle, ExportBundle); err != nil {
+		t.Fatalf("Export(bundle) failed: %v", err)
+	}
+
+	dst := testConfig(t)
+	dst.Sources = cfg.Sources
+	c := New(dst)
+	manifest, err := c.ImportBundle(&bundle)
+	if err != nil {
+		t.Fatalf("ImportBundle() failed: %v", err)
+	}
+	if manifest.Generation != 1 || len(manifest.Files) != 4 {
+		t.Errorf("manifest = %+v, expected generation 1 and 4 files", manifest)
+	}
+
+	matches, _ := c.Lookup("")
+	if got := banners(matches); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("imported banners = %v, expected [a b]", got)
+	}
+	if c.loadSnapshot(local) == nil {
+		t.Error("the source's snapshot should be imported")
+	}
+	stats := c.Stats()
+	if stats.Generation != 1 || stats.Checksum == "" || stats.Sources[0].Entries != 2 {
+		t.Errorf("imported stats = %+v", stats)
+	}
+	info, _ := os.Stat(dst.CacheFile)
+	if d := info.ModTime().Sub(manifest.UpdatedAt); d < -time.Second || d > time.Second {
+		t.Errorf("imported cache written at %v, expected its original time %v", info.ModTime(), manifest.UpdatedAt)
+	}
+}
+
+func TestExportBundleWithoutCache(t *testing.T) {
+	if err := New(testConfig(t)).ExportBundle(new(bytes.Buffer)); !errors.Is(err, ErrNoCache) {
+		t.Errorf("ExportBundle() without a cache error = %v, expected ErrNoCache", err)
+	}
+}
+
+// tarBundle builds an uncompressed bundle of files, with a manifest
+// listing want (or the files themselves when want is nil).
+func tarBundle(t *testing.T, files map[string]string, want []BundleFile) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	manifest := BundleManifest{Version: BundleVersion, Files: want}
+	for _, name := range names {
+		body := files[name]
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))})
+		_, _ = tw.Write([]byte(body))
+		if want == nil {
+			sum := sha256.Sum256([]byte(body))
+			manifest.Files = append(manifest.Files, BundleFile{name, int64(len(body)), hex.EncodeToString(sum[:])})
+		}
+	}
+	raw, _ := json.Marshal(manifest)
+	_ = tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(raw))})
+	_, _ = tw.Write(raw)
+	_ = tw.Close()
+	return &buf
+}
+
+func TestImportBundleInvalid(t *testing.T) {
+	const cache = `{"version":1,"linux":{"x":["https://example.com/x"]}}`
+	tests := []struct {
+		name   string
+		bundle func(t *testing.T) *bytes.Buffer
+	}{
+		{"not a tar", func(t *testing.T) *bytes.Buffer { return bytes.NewBufferString("garbage") }},
+		{"no manifest", func(t *testing.T) *bytes.Buffer {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			_ = tw.WriteHeader(&tar.Header{Name: "banners.json", Mode: 0644, Size: int64(len(cache))})
+			_, _ = tw.Write([]byte(cache))
+			_ = tw.Close()
+			return &buf
+		}},
+		{"checksum mismatch", func(t *testing.T) *bytes.Buffer {
+			return tarBundle(t, map[string]string{"banners.json": cache},
+				[]BundleFile{{"banners.json", int64(len(cache)), "00"}})
+		}},
+		{"file missing", func(t *testing.T) *bytes.Buffer {
+			return tarBundle(t, map[string]string{"banners.json": cache},
+				[]BundleFile{{"banners.json", 0, ""}, {"meta.json", 0, ""}})
+		}},
+		{"path escape", func(t *testing.T) *bytes.Buffer {
+			return tarBundle(t, map[string]string{"banners.json": cache, "snapshots/../../x.json": "{}"}, nil)
+		}},
+		{"no cache", func(t *testing.T) *bytes.Buffer {
+			return tarBundle(t, map[string]string{"meta.json": "{}"}, nil)
+		}},
+		{"invalid cache", func(t *testing.T) *bytes.Buffer {
+			return tarBundle(t, map[string]string{"banners.json": "{"}, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			createTestBannerFile(t, cfg.CacheFile)
+			before, _ := os.ReadFile(cfg.CacheFile)
+
+			if _, err := New(cfg).ImportBundle(tt.bundle(t)); !errors.Is(err, ErrInvalidBundle) {
+				t.Fatalf("ImportBundle() error = %v, expected ErrInvalidBundle", err)
+			}
+			if after, _ := os.ReadFile(cfg.CacheFile); !bytes.Equal(before, after) {
+				t.Error("an invalid bundle should leave the cache unchanged")
+			}
+			if entries, _ := filepath.Glob(filepath.Join(cfg.CacheDir, ".import-*")); len(entries) > 0 {
+				t.Errorf("staging left behind: %v", entries)
+			}
+		})
+	}
+
+	// A valid plain tar is accepted
+	cfg := testConfig(t)
+	if _, err := New(cfg).ImportBundle(tarBundle(t, map[string]string{"banners.json": cache}, nil)); err != nil {
+		t.Errorf("ImportBundle() of a plain tar failed: %v", err)
+	}
+}
@@ -2,19 +2,30 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/credentials"
 	"github.com/calilkhalil/basar/internal/fetcher"
+	"github.com/calilkhalil/basar/internal/hooks"
+	"github.com/calilkhalil/basar/internal/logging"
+	"github.com/calilkhalil/basar/internal/negotiate"
+	"github.com/calilkhalil/basar/internal/pac"
 )
 
 const (
@@ -31,30 +42,264 @@ const (
 // ErrLocked indicates another process holds the lock.
 var ErrLocked = errors.New("cache is locked by another process")
 
+// ErrShrink indicates a merge would replace the cache with far fewer entries.
+var ErrShrink = errors.New("refusing to shrink cache")
+
+// ErrRequiredSource indicates a source marked required=true failed.
+var ErrRequiredSource = errors.New("required source failed")
+
+// ErrTooFewSources indicates fewer sources succeeded than MinSources (or,
+// in strict mode, that any source failed).
+var ErrTooFewSources = errors.New("too few sources succeeded")
+
+// ErrAllSourcesFailed indicates an update fetched nothing usable.
+var ErrAllSourcesFailed = errors.New("all sources failed")
+
+// ErrVol3AlreadyConfigured indicates the volatility3 config already lists
+// the cache in remote_isf_url, so there is nothing to change.
+var ErrVol3AlreadyConfigured = errors.New("volatility3 config already uses the basar cache")
+
 // Stats contains cache statistics.
 type Stats struct {
+	// Profile is the named profile the cache belongs to, if any.
+	Profile string `json:"profile,omitempty"`
+
 	Valid      bool      `json:"valid"`
 	Path       string    `json:"path,omitempty"`
 	Entries    int       `json:"entries,omitempty"`
 	Size       int64     `json:"size,omitempty"`
 	AgeSeconds int       `json:"age_seconds,omitempty"`
 	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+
+	// Checksum is the SHA-256 of the cache file recorded when it was
+	// written, in hex.
+	Checksum string `json:"checksum,omitempty"`
+
+	// Provenance counts the banners each source contributed.
+	Provenance map[string]int `json:"provenance,omitempty"`
+
+	// Metadata counts the banners carrying each metadata field, for caches
+	// built from sources that list per-banner metadata.
+	Metadata map[string]int `json:"metadata,omitempty"`
+
+	// Filtered counts what URL and banner filters removed in the update
+	// that wrote the cache.
+	Filtered *fetcher.FilterCounts `json:"filtered,omitempty"`
+
+	// Tombstones counts the banners kept after their sources dropped them.
+	Tombstones int `json:"tombstones,omitempty"`
+
+	// Sources reports the last fetch of each configured source.
+	Sources []SourceStats `json:"sources,omitempty"`
+
+	// LastUpdate is the outcome of the most recent update attempt.
+	LastUpdate *fetcher.UpdateStatus `json:"last_update,omitempty"`
+
+	// Generation counts updates that changed the cache.
+	Generation uint64 `json:"generation,omitempty"`
+
+	// ReadOnlyDir is the read-only cache directory the fallback directory
+	// holding Path stands in for.
+	ReadOnlyDir string `json:"read_only_dir,omitempty"`
+
+	// LastUsed is the last read of the cache by a client, when usage is
+	// tracked (BASAR_TRACK_USAGE or BASAR_ACCESS_LOG).
+	LastUsed *Usage `json:"last_used,omitempty"`
+}
+
+// SourceStats describes the last fetch of a single source.
+type SourceStats struct {
+	Source     string    `json:"source"`
+	Status     string    `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Entries    int       `json:"entries"`
+	Bytes      int64     `json:"bytes"`
+	Failures   int       `json:"failures"`
+	Required   bool      `json:"required,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Priority   int       `json:"priority,omitempty"`
+	LastFetch  time.Time `json:"last_fetch,omitempty"`
+	LastChange time.Time `json:"last_change,omitempty"`
+
+	// Quarantined counts the banners the last fetch dropped for straying
+	// from the schema.
+	Quarantined int `json:"quarantined,omitempty"`
+
+	// Typical is the median entry count of the source's recent changes,
+	// 0 until it has changed a few times.
+	Typical int `json:"typical,omitempty"`
 }
 
 // Cache manages the ISF banner cache.
 type Cache struct {
 	cfg     *config.Config
 	fetcher *fetcher.Fetcher
+	log     *slog.Logger
+
+	// proxy picks proxies with the configured PAC file, nil without one.
+	proxy *pac.Resolver
+	// negotiate makes the Kerberos tokens of sources and proxies using
+	// Negotiate authentication.
+	negotiate *negotiate.Client
+
+	// readOnlyDir is the configured cache directory while cfg points at
+	// the fallback directory instead (see lockForUpdate).
+	readOnlyDir string
+
+	// indexMu guards the lookup index built by Index.
+	indexMu      sync.Mutex
+	index        *Index
+	indexVersion indexVersion
 }
 
 // New creates a new Cache instance.
 func New(cfg *config.Config) *Cache {
-	return &Cache{
-		cfg:     cfg,
-		fetcher: fetcher.New(),
+	c := &Cache{
+		cfg:       cfg,
+		fetcher:   fetcher.New(),
+		log:       logging.Discard(),
+		negotiate: &negotiate.Client{Cmd: cfg.NegotiateCmd},
+	}
+	c.fetcher.SetTokenFunc(c.sourceToken)
+	c.fetcher.SetAuthFunc(c.sourceAuth)
+	c.fetcher.SetTimeoutFunc(func(source string) time.Duration {
+		return cfg.SourceOptions(source).Timeout
+	})
+	c.fetcher.SetPagingFunc(func(source string) fetcher.Paging {
+		opts := cfg.SourceOptions(source)
+		return fetcher.Paging{CursorField: opts.CursorField, CursorParam: opts.CursorParam}
+	})
+	c.fetcher.SetFormatFunc(func(source string) string {
+		return cfg.SourceOptions(source).Format
+	})
+	c.fetcher.SetSchemaFunc(func(source string) string {
+		if mode := cfg.SourceOptions(source).Schema; mode != "" {
+			return mode
+		}
+		return fetcher.SchemaReject
+	})
+	c.fetcher.SetGitHubAPI(cfg.GitHubAPI)
+	c.fetcher.SetJobs(cfg.Jobs)
+	c.fetcher.SetFailFast(cfg.FailFast)
+	c.fetcher.SetOffline(cfg.Offline)
+	if cfg.ProxyPAC != "" {
+		c.proxy = pac.NewResolver(cfg.ProxyPAC)
+		c.proxy.OnError = func(err error) {
+			c.log.Warn("proxy auto-config failed; using the proxy environment variables", "pac", cfg.ProxyPAC, "error", err)
+		}
+		c.fetcher.SetProxy(c.proxy.Proxy)
+	}
+	if cfg.ProxyAuth != "" {
+		c.fetcher.SetProxyAuth(c.proxyAuth)
+	}
+	if c.fallbackIsNewer() {
+		c.useFallback()
+	}
+	return c
+}
+
+// SetLogger sets the logger for progress and warnings.
+func (c *Cache) SetLogger(l *slog.Logger) {
+	c.log = l
+}
+
+// SetProgress sets where updates report on each source while fetching it.
+func (c *Cache) SetProgress(fn fetcher.ProgressFunc) {
+	c.fetcher.SetProgressFunc(fn)
+}
+
+// sourceToken resolves the configured token for a source, falling back on
+// GITHUB_TOKEN for github:// sources without one.
+func (c *Cache) sourceToken(ctx context.Context, source string) (string, error) {
+	spec := tokenSpec(c.cfg.SourceOptions(source))
+	if spec.IsZero() && fetcher.IsGitHub(source) {
+		// Raises the API quota from 60 requests an hour to 5,000
+		return strings.TrimSpace(os.Getenv("GITHUB_TOKEN")), nil
+	}
+	return credentials.Resolve(ctx, spec)
+}
+
+// tokenSpec returns where the token of a source with opts is read from.
+func tokenSpec(opts config.SourceOptions) credentials.Spec {
+	return credentials.Spec{
+		Env:     opts.TokenEnv,
+		File:    opts.TokenFile,
+		Cmd:     opts.TokenCmd,
+		Keyring: opts.TokenKeyring,
 	}
 }
 
+// sourceAuth returns the Authorization header of a source configured with
+// auth=negotiate or auth=netrc for its server on host, "" for other
+// sources. oci:// sources without credentials of their own use the login
+// docker login or oras login stored for their registry.
+func (c *Cache) sourceAuth(ctx context.Context, source, host string) (string, error) {
+	opts := c.cfg.SourceOptions(source)
+	switch auth := opts.Auth; auth {
+	case "":
+		if fetcher.IsOCI(source) && tokenSpec(opts).IsZero() {
+			login, password, err := credentials.Docker(ctx, host)
+			if errors.Is(err, credentials.ErrNoDockerLogin) {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			return basicAuth(login, password), nil
+		}
+		return "", nil
+	case config.AuthNegotiate:
+		return c.negotiateAuth(ctx, host)
+	case config.AuthNetrc:
+		return netrcAuth(host)
+	default:
+		return "", fmt.Errorf("unsupported auth %q", auth)
+	}
+}
+
+// proxyAuth returns the Proxy-Authorization header for the proxy on host,
+// as proxy.conf says.
+func (c *Cache) proxyAuth(ctx context.Context, host string) (string, error) {
+	switch c.cfg.ProxyAuth {
+	case config.AuthNegotiate:
+		return c.negotiateAuth(ctx, host)
+	case config.AuthNetrc:
+		return netrcAuth(host)
+	case config.AuthKeyring:
+		secret, err := credentials.Keyring(ctx, c.cfg.ProxyKeyring)
+		if err != nil {
+			return "", err
+		}
+		login, password, ok := strings.Cut(secret, ":")
+		if !ok {
+			return "", fmt.Errorf("credential store secret %s is not user:password", c.cfg.ProxyKeyring)
+		}
+		return basicAuth(login, password), nil
+	default:
+		return "", fmt.Errorf("unsupported proxy auth %q", c.cfg.ProxyAuth)
+	}
+}
+
+func (c *Cache) negotiateAuth(ctx context.Context, host string) (string, error) {
+	token, err := c.negotiate.Token(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	return "Negotiate " + token, nil
+}
+
+func netrcAuth(host string) (string, error) {
+	login, password, err := credentials.Netrc(host)
+	if err != nil {
+		return "", err
+	}
+	return basicAuth(login, password), nil
+}
+
+func basicAuth(login, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login+":"+password))
+}
+
 // IsValid checks if cache exists and is within TTL.
 func (c *Cache) IsValid() bool {
 	info, err := os.Stat(c.cfg.CacheFile)
@@ -80,112 +325,252 @@ func (c *Cache) URI() (string, bool) {
 	if !ok {
 		return "", false
 	}
-	return "file://" + path, true
+	return fileURI(path), true
+}
+
+// fileURI returns the file:// URI of an absolute path, with a drive letter
+// on Windows (file:///C:/Users/...).
+func fileURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
 }
 
 // Stats returns cache statistics.
 func (c *Cache) Stats() Stats {
-	info, err := os.Stat(c.cfg.CacheFile)
+	meta := c.loadBackfilledMeta()
+	invalid := Stats{Profile: c.cfg.Profile, Valid: false, Sources: c.sourceStats(meta), LastUpdate: meta.LastUpdate, Generation: meta.Generation, ReadOnlyDir: c.readOnlyDir}
+
+	f, err := os.Open(c.cfg.CacheFile)
 	if err != nil {
-		return Stats{Valid: false}
+		return invalid
 	}
+	defer f.Close()
 
-	data, err := os.ReadFile(c.cfg.CacheFile)
+	info, err := f.Stat()
 	if err != nil {
-		return Stats{Valid: false}
+		return invalid
 	}
 
-	var banners fetcher.BannerData
-	if err := json.Unmarshal(data, &banners); err != nil {
-		return Stats{Valid: false}
+	// Stream the count; multi-hundred-MB indexes are not worth decoding
+	entries, err := fetcher.CountBanners(f)
+	if err != nil {
+		return invalid
 	}
 
 	return Stats{
-		Valid:      true,
-		Path:       c.cfg.CacheFile,
-		Entries:    len(banners.Linux),
-		Size:       info.Size(),
-		AgeSeconds: int(time.Since(info.ModTime()).Seconds()),
-		UpdatedAt:  info.ModTime(),
+		Profile:     c.cfg.Profile,
+		Valid:       true,
+		Path:        c.cfg.CacheFile,
+		Entries:     entries,
+		Size:        info.Size(),
+		AgeSeconds:  int(time.Since(info.ModTime()).Seconds()),
+		UpdatedAt:   meta.UpdatedAt,
+		Checksum:    meta.Checksum,
+		Provenance:  provenanceCounts(c.loadProvenance()),
+		Metadata:    metadataCounts(c.loadMetadata()),
+		Filtered:    meta.Filtered,
+		Tombstones:  len(c.loadTombstones()),
+		Sources:     c.sourceStats(meta),
+		LastUpdate:  meta.LastUpdate,
+		Generation:  meta.Generation,
+		ReadOnlyDir: c.readOnlyDir,
+		LastUsed:    c.usage(info.ModTime()),
 	}
 }
 
+// sourceStats reports per-source fetch metadata for configured sources that
+// have been fetched at least once.
+func (c *Cache) sourceStats(meta *fetcher.MetaCache) []SourceStats {
+	var stats []SourceStats
+	for _, src := range c.cfg.Sources {
+		m, ok := meta.Sources[src]
+		if !ok {
+			continue
+		}
+		stats = append(stats, SourceStats{
+			Source:      src,
+			Status:      m.Status,
+			Error:       m.Error,
+			Entries:     m.Entries,
+			Bytes:       m.Bytes,
+			Failures:    m.Failures,
+			Quarantined: m.Quarantined,
+			Typical:     typicalEntries(m.EntryHistory),
+			Required:    c.cfg.SourceOptions(src).Required,
+			Tags:        c.cfg.SourceOptions(src).Tags,
+			Priority:    c.cfg.SourceOptions(src).Priority,
+			LastFetch:   m.FetchedAt,
+			LastChange:  m.UpdatedAt,
+		})
+	}
+
+	return stats
+}
+
+// metadataCounts returns the number of banners carrying each metadata
+// field, or nil without metadata.
+func metadataCounts(md fetcher.Metadata) map[string]int {
+	if len(md) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, fields := range md {
+		for name := range fields {
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+// provenanceCounts returns the number of banners attributed to each source.
+func provenanceCounts(prov fetcher.Provenance) map[string]int {
+	if len(prov) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, sources := range prov {
+		for _, src := range sources {
+			counts[src]++
+		}
+	}
+
+	return counts
+}
+
 // loadMeta loads source metadata from cache.
 func (c *Cache) loadMeta() *fetcher.MetaCache {
-	metaFile := filepath.Join(c.cfg.CacheDir, "meta.json")
-	data, err := os.ReadFile(metaFile)
+	data, err := os.ReadFile(c.cfg.MetaFile)
 	if err != nil {
-		return &fetcher.MetaCache{Sources: make(map[string]fetcher.SourceMeta)}
+		return &fetcher.MetaCache{Sources: make(map[string]fetcher.SourceMeta), API: fetcher.NewAPICache()}
 	}
 
+	data, _, err = metaFormat.upgrade(data)
+	if errors.Is(err, ErrNewerFormat) {
+		c.log.Warn("ignoring state file", "file", c.cfg.MetaFile, "error", err)
+	}
 	var meta fetcher.MetaCache
-	if err := json.Unmarshal(data, &meta); err != nil {
-		return &fetcher.MetaCache{Sources: make(map[string]fetcher.SourceMeta)}
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		return &fetcher.MetaCache{Sources: make(map[string]fetcher.SourceMeta), API: fetcher.NewAPICache()}
 	}
 
 	if meta.Sources == nil {
 		meta.Sources = make(map[string]fetcher.SourceMeta)
 	}
+	if meta.API == nil {
+		meta.API = fetcher.NewAPICache()
+	}
 
 	return &meta
 }
 
-// saveMeta saves source metadata to cache.
+// saveMeta saves source metadata to cache, stamped with MetaFormat.
 func (c *Cache) saveMeta(meta *fetcher.MetaCache) error {
-	metaFile := filepath.Join(c.cfg.CacheDir, "meta.json")
-
-	data, err := json.MarshalIndent(meta, "", "  ")
+	raw, err := json.Marshal(meta)
 	if err != nil {
 		return err
 	}
+	return c.writeMeta(stampFormat(raw, MetaFormat))
+}
+
+// writeMeta writes the encoded metadata raw, indented.
+func (c *Cache) writeMeta(raw []byte) error {
+	var data bytes.Buffer
+	if err := json.Indent(&data, raw, "", "  "); err != nil {
+		return err
+	}
 
-	return os.WriteFile(metaFile, data, FileMode)
+	if err := os.MkdirAll(filepath.Dir(c.cfg.MetaFile), DirMode); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.cfg.MetaFile, data.Bytes(), FileMode)
 }
 
-// SmartUpdate updates cache only if sources have changed.
-// Returns: updated (bool), error
-func (c *Cache) SmartUpdate(ctx context.Context, verbose bool) (bool, error) {
-	if err := c.acquireLock(); err != nil {
-		return false, err
+// SmartUpdate updates cache only if sources have changed; res.Updated
+// reports whether it did.
+func (c *Cache) SmartUpdate(ctx context.Context) (res *UpdateResult, err error) {
+	res = c.newResult()
+	res.unattended = true
+	fetch, keep, err := c.selectSources(c.cfg.Only)
+	if err != nil {
+		return res, err
+	}
+
+	if err := c.lockForUpdate(); err != nil {
+		return res, err
 	}
 	defer c.releaseLock()
 
+	if err := c.runHook(ctx, hooks.PreUpdate, nil); err != nil {
+		return res, err
+	}
+	defer func() { c.finishUpdate(ctx, res, err) }()
+
 	meta := c.loadMeta()
-	results := c.fetcher.FetchAllWithMeta(ctx, c.cfg.Sources, meta)
+	results := c.fetcher.FetchAllWithMeta(ctx, fetch, meta)
+	res.Sources = sourceResults(results)
+	c.logFailures(results)
+	c.recordQuarantines(results)
+	held := c.checkSourceShrink(results, meta, res.Sources)
 
 	var datasets []*fetcher.BannerData
+	var sources []string
 	anyModified := false
-	newMeta := &fetcher.MetaCache{Sources: make(map[string]fetcher.SourceMeta)}
+	newMeta := &fetcher.MetaCache{
+		Sources:    make(map[string]fetcher.SourceMeta),
+		API:        meta.API,
+		Generation: meta.Generation,
+		Vol3Compat: meta.Vol3Compat,
+		Overrides:  meta.Overrides,
+		Filters:    meta.Filters,
+		Filtered:   meta.Filtered,
+	}
+	for _, source := range keep {
+		if m, ok := meta.Sources[source]; ok {
+			newMeta.Sources[source] = m
+		}
+	}
 
-	for _, r := range results {
+	for i, r := range results {
 		if r.Err != nil {
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stderr, "source %s: %v\n", r.Source, r.Err)
+			// Keep old validators for failed sources, recording the failure
+			newMeta.Sources[r.Source] = failedMeta(meta.Sources[r.Source], r.Err)
+			if data := c.staleSnapshot(ctx, r, &res.Sources[i]); data != nil {
+				datasets = append(datasets, data)
+				sources = append(sources, r.Source)
 			}
-			// Keep old metadata for failed sources
-			if old, ok := meta.Sources[r.Source]; ok {
-				newMeta.Sources[r.Source] = old
+			continue
+		}
+		if held[r.Source] {
+			newMeta.Sources[r.Source] = heldMeta(meta.Sources[r.Source], r.Meta)
+			if data := c.loadSnapshot(r.Source); data != nil {
+				datasets = append(datasets, data)
+				sources = append(sources, r.Source)
 			}
 			continue
 		}
 
 		if r.Meta != nil {
-			newMeta.Sources[r.Source] = *r.Meta
+			newMeta.Sources[r.Source] = succeededMeta(meta.Sources[r.Source], *r.Meta)
 		}
 
 		if r.Modified && r.Data != nil {
 			datasets = append(datasets, r.Data)
+			sources = append(sources, r.Source)
 			anyModified = true
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stderr, "source %s: updated\n", r.Source)
+			if err := c.saveSnapshot(r.Source, r.Data); err != nil {
+				c.log.Warn("saving snapshot failed", "source", r.Source, "error", err)
 			}
+			c.log.Info("source updated", "source", r.Source, "entries", len(r.Data.Linux))
 		} else if !r.Modified {
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stderr, "source %s: not modified\n", r.Source)
-			}
-			// Load existing data for unmodified sources
-			if existing := c.loadExistingBanners(); existing != nil {
-				datasets = append(datasets, existing)
+			c.log.Info("source not modified", "source", r.Source)
+			if data := c.lastData(r.Source); data != nil {
+				datasets = append(datasets, data)
+				sources = append(sources, r.Source)
 			}
 		}
 	}
@@ -193,25 +578,245 @@ func (c *Cache) SmartUpdate(ctx context.Context, verbose bool) (bool, error) {
 	// Save metadata regardless
 	if err := c.saveMeta(newMeta); err != nil {
 		// Log error but don't fail - metadata is best-effort
-		if verbose {
-			fmt.Fprintf(os.Stderr, "warning: failed to save metadata: %v\n", err)
-		}
+		c.log.Warn("saving metadata failed", "error", err)
 	}
 
-	if !anyModified && c.IsValid() {
-		return false, nil
+	if err := c.failFastError(results); err != nil {
+		return res, err
+	}
+	if err := c.requiredSourceError(results); err != nil {
+		return res, err
+	}
+	if err := c.minSourcesError(results); err != nil {
+		return res, err
 	}
 
-	if len(datasets) == 0 {
-		return false, errors.New("all sources failed")
+	rewrite := c.needsRewrite(meta)
+	if !anyModified && !rewrite && c.IsValid() {
+		return res, nil
 	}
 
-	merged := fetcher.Merge(datasets)
+	if len(datasets) == 0 {
+		return res, ErrAllSourcesFailed
+	}
+	sources, datasets = c.byPriority(c.withKept(keep, sources, datasets))
+
+	merged, prov := fetcher.MergeSources(sources, datasets, c.cfg.URLRedirects)
+	existing := c.loadExistingBanners()
+	tombs := c.keepRemoved(existing, merged, prov)
+	trust := c.urlTrust(merged, sources, datasets)
+	res.Filtered = c.filter(merged, prov, trust, c.cfg.BannerFilter, c.cfg.URLFilter)
+	c.demoteDeadURLs(merged.Linux)
+	if err := c.applyOverrides(merged, prov); err != nil {
+		return res, err
+	}
+	if err := c.checkShrink(existing, merged); err != nil {
+		return res, err
+	}
 	if err := c.write(merged); err != nil {
-		return false, err
+		return res, err
+	}
+	res.Updated = anyModified || rewrite
+	res.added, res.removed = bannerChanges(existing, merged)
+	if err := c.saveProvenance(prov); err != nil {
+		c.log.Warn("saving provenance failed", "error", err)
+	}
+	if err := c.saveMetadata(fetcher.MergeMetadata(datasets)); err != nil {
+		c.log.Warn("saving metadata sidecar failed", "error", err)
+	}
+	if err := c.saveURLTrust(trust); err != nil {
+		c.log.Warn("saving URL trust failed", "error", err)
+	}
+	if err := c.saveTombstones(tombs); err != nil {
+		c.log.Warn("saving tombstones failed", "error", err)
+	}
+	if err := c.saveDiskIndex(prov); err != nil {
+		c.log.Warn("saving disk index failed", "error", err)
+	}
+
+	return res, nil
+}
+
+// failFastError returns the first configuration error among results when
+// fail-fast is enabled, so the update stops instead of merging the rest.
+func (c *Cache) failFastError(results []fetcher.Result) error {
+	if !c.cfg.FailFast {
+		return nil
+	}
+	for _, r := range results {
+		if errors.Is(r.Err, fetcher.ErrConfiguration) {
+			return fmt.Errorf("%s: %w", r.Source, r.Err)
+		}
+	}
+	return nil
+}
+
+// requiredSourceError reports every failed source marked required, so the
+// update fails rather than publish an index missing them.
+func (c *Cache) requiredSourceError(results []fetcher.Result) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil && c.cfg.SourceOptions(r.Source).Required {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrRequiredSource, r.Source, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// minSourcesError fails an update in which fewer sources succeeded than
+// configured, or in strict mode any source failed.
+func (c *Cache) minSourcesError(results []fetcher.Result) error {
+	need := c.cfg.MinSources
+	if c.cfg.Strict {
+		need = len(results)
+	}
+
+	ok := 0
+	for _, r := range results {
+		if r.Err == nil {
+			ok++
+		}
+	}
+	if ok < need {
+		return fmt.Errorf("%w: %d of %d, need %d", ErrTooFewSources, ok, len(results), need)
+	}
+	return nil
+}
+
+// failedMeta returns old with the outcome of a failed fetch recorded.
+func failedMeta(old fetcher.SourceMeta, err error) fetcher.SourceMeta {
+	old.FetchedAt = time.Now()
+	old.Status = fetcher.StatusError
+	old.Error = err.Error()
+	old.Bytes = 0
+	old.Failures++
+	return old
+}
+
+// staleSnapshot returns the last snapshot of a source whose fetch ran past
+// its deadline, and marks its result, so the update merges the source's
+// previous banners rather than dropping them from this generation. It
+// returns nil for other failures, for sources without a snapshot, and when
+// the update as a whole was canceled or timed out.
+func (c *Cache) staleSnapshot(ctx context.Context, r fetcher.Result, sr *SourceResult) *fetcher.BannerData {
+	if ctx.Err() != nil || !fetcher.IsTimeout(r.Err) {
+		return nil
+	}
+	data := c.loadSnapshot(r.Source)
+	if data == nil {
+		return nil
 	}
+	c.log.Warn("source timed out; merging its last snapshot", "source", r.Source, "entries", len(data.Linux))
+	sr.Stale = true
+	sr.Error = "timed out (using stale snapshot): " + sr.Error
+	return data
+}
 
-	return anyModified, nil
+// succeededMeta returns the metadata of a successful fetch, carrying over
+// the failure count and entry history from old, with the entry count of a
+// changed source added to the history.
+func succeededMeta(old, fetched fetcher.SourceMeta) fetcher.SourceMeta {
+	fetched.Failures = old.Failures
+	fetched.EntryHistory = old.EntryHistory
+	if fetched.Status == fetcher.StatusOK {
+		fetched.EntryHistory = recordEntries(old.EntryHistory, fetched.Entries)
+	}
+	return fetched
+}
+
+// Update results passed to the post-update hook in BASAR_UPDATE_STATUS.
+const (
+	UpdateUpdated   = "updated"
+	UpdateUnchanged = "unchanged"
+	UpdateFailed    = "failed"
+)
+
+// finishUpdate stores the outcome of an update attempt in the metadata,
+// completes res, and runs the post-update hook with it.
+func (c *Cache) finishUpdate(ctx context.Context, res *UpdateResult, err error) {
+	meta := c.loadMeta()
+	meta.LastUpdate = &fetcher.UpdateStatus{At: time.Now(), Success: err == nil}
+	if err != nil {
+		meta.LastUpdate.Error = err.Error()
+	}
+	if res.Updated {
+		meta.Generation++
+		meta.Vol3Compat = c.cfg.Vol3Compat
+		meta.Overrides = c.overridesChecksum()
+		meta.Filters = c.filtersChecksum()
+		meta.Filtered = res.Filtered
+		if err := c.stampMeta(meta); err != nil {
+			c.log.Warn("reading cache for metadata failed", "error", err)
+		}
+	} else {
+		c.backfillMeta(meta)
+	}
+	if err := c.saveMeta(meta); err != nil {
+		c.log.Warn("saving metadata failed", "error", err)
+	}
+
+	res.EntriesAfter = c.cachedEntries()
+	res.Generation = meta.Generation
+	res.Duration = time.Since(res.started)
+
+	status := UpdateUnchanged
+	switch {
+	case err != nil:
+		status = UpdateFailed
+	case res.Updated:
+		status = UpdateUpdated
+	}
+
+	failed := 0
+	for _, src := range c.cfg.Sources {
+		if meta.Sources[src].Status == fetcher.StatusError {
+			failed++
+		}
+	}
+
+	if err := c.appendHistory(HistoryEntry{
+		At:         meta.LastUpdate.At,
+		Status:     status,
+		Error:      meta.LastUpdate.Error,
+		Generation: res.Generation,
+		Entries:    res.EntriesAfter,
+		Sources:    res.Sources,
+		Added:      res.added,
+		Removed:    res.removed,
+	}); err != nil {
+		c.log.Warn("saving update history failed", "error", err)
+	}
+
+	env := []string{
+		"BASAR_UPDATE_STATUS=" + status,
+		"BASAR_UPDATE_ERROR=" + meta.LastUpdate.Error,
+		fmt.Sprintf("BASAR_ENTRIES=%d", res.EntriesAfter),
+		fmt.Sprintf("BASAR_FAILED_SOURCES=%d", failed),
+	}
+	if err := c.runHook(ctx, hooks.PostUpdate, env); err != nil {
+		c.log.Warn("hook failed", "hook", hooks.PostUpdate, "error", err)
+	}
+	c.notify(ctx, updateEvents(res, err, status, meta.LastUpdate.At))
+}
+
+// runHook runs a hook from the configured hooks directory with env and the
+// cache location added to its environment.
+func (c *Cache) runHook(ctx context.Context, name string, env []string) error {
+	if c.cfg.HooksDir == "" {
+		return nil
+	}
+
+	env = append([]string{
+		"BASAR_HOOK=" + name,
+		"BASAR_CACHE_FILE=" + c.cfg.CacheFile,
+		"BASAR_CONFIG_FILE=" + c.cfg.ConfigFile,
+	}, env...)
+
+	ran, output, err := hooks.Run(ctx, c.cfg.HooksDir, name, env)
+	if ran && err == nil && output != "" {
+		c.log.Info("hook output", "hook", name, "output", output)
+	}
+	return err
 }
 
 // loadExistingBanners loads current cached banners.
@@ -231,41 +836,163 @@ func (c *Cache) loadExistingBanners() *fetcher.BannerData {
 
 // Update refreshes the cache from configured sources.
 // If force is false, skips update if cache is valid.
-func (c *Cache) Update(ctx context.Context, force bool) error {
+func (c *Cache) Update(ctx context.Context, force bool) (res *UpdateResult, err error) {
+	res = c.newResult()
 	if !force && c.IsValid() {
-		return nil
+		res.EntriesAfter = res.EntriesBefore
+		res.Generation = c.loadMeta().Generation
+		res.Duration = time.Since(res.started)
+		return res, nil
 	}
+	return c.update(ctx, res, c.cfg.Only)
+}
 
-	if err := c.acquireLock(); err != nil {
-		return err
+// RefreshSource refetches a single configured source unconditionally and
+// merges it with the last fetched data of the others, e.g. to pick up a
+// hotfixed upstream file without fetching every mirror.
+func (c *Cache) RefreshSource(ctx context.Context, source string) (*UpdateResult, error) {
+	res := c.newResult()
+	if !slices.Contains(c.cfg.Sources, source) {
+		return res, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+	return c.update(ctx, res, []string{source})
+}
+
+// update fetches the sources matching selectors (all when empty) and
+// merges them with the last fetched data of the rest.
+func (c *Cache) update(ctx context.Context, res *UpdateResult, selectors []string) (_ *UpdateResult, err error) {
+	fetch, keep, err := c.selectSources(selectors)
+	if err != nil {
+		return res, err
+	}
+
+	if err := c.lockForUpdate(); err != nil {
+		return res, err
 	}
 	defer c.releaseLock()
 
-	results := c.fetcher.FetchAll(ctx, c.cfg.Sources)
+	if err := c.runHook(ctx, hooks.PreUpdate, nil); err != nil {
+		return res, err
+	}
+	defer func() { c.finishUpdate(ctx, res, err) }()
+
+	// Fetch unconditionally, but share the persisted API response cache so
+	// API-based sources still revalidate instead of spending quota
+	meta := c.loadMeta()
+	results := c.fetcher.FetchAllWithMeta(ctx, fetch, &fetcher.MetaCache{API: meta.API})
+	res.Sources = sourceResults(results)
+	c.logFailures(results)
+	c.recordQuarantines(results)
+	held := c.checkSourceShrink(results, meta, res.Sources)
 
 	var datasets []*fetcher.BannerData
-	for _, r := range results {
+	var sources []string
+	for i, r := range results {
 		if r.Err != nil {
+			meta.Sources[r.Source] = failedMeta(meta.Sources[r.Source], r.Err)
+			if data := c.staleSnapshot(ctx, r, &res.Sources[i]); data != nil {
+				datasets = append(datasets, data)
+				sources = append(sources, r.Source)
+			}
+			continue
+		}
+		if held[r.Source] {
+			meta.Sources[r.Source] = heldMeta(meta.Sources[r.Source], r.Meta)
+			if data := c.loadSnapshot(r.Source); data != nil {
+				datasets = append(datasets, data)
+				sources = append(sources, r.Source)
+			}
 			continue
 		}
 		datasets = append(datasets, r.Data)
+		sources = append(sources, r.Source)
+		if r.Meta != nil {
+			meta.Sources[r.Source] = succeededMeta(meta.Sources[r.Source], *r.Meta)
+		}
+		_ = c.saveSnapshot(r.Source, r.Data) // Best-effort, used by SmartUpdate
+	}
+
+	_ = c.saveMeta(meta) // Best-effort, metadata only speeds up later runs
+
+	if err := c.failFastError(results); err != nil {
+		return res, err
+	}
+	if err := c.requiredSourceError(results); err != nil {
+		return res, err
+	}
+	if err := c.minSourcesError(results); err != nil {
+		return res, err
 	}
 
 	if len(datasets) == 0 {
-		return errors.New("all sources failed")
+		return res, ErrAllSourcesFailed
+	}
+	sources, datasets = c.byPriority(c.withKept(keep, sources, datasets))
+
+	merged, prov := fetcher.MergeSources(sources, datasets, c.cfg.URLRedirects)
+	existing := c.loadExistingBanners()
+	tombs := c.keepRemoved(existing, merged, prov)
+	trust := c.urlTrust(merged, sources, datasets)
+	res.Filtered = c.filter(merged, prov, trust, c.cfg.BannerFilter, c.cfg.URLFilter)
+	c.demoteDeadURLs(merged.Linux)
+	if err := c.applyOverrides(merged, prov); err != nil {
+		return res, err
+	}
+	if err := c.checkShrink(existing, merged); err != nil {
+		return res, err
 	}
 
-	merged := fetcher.Merge(datasets)
+	if err := c.write(merged); err != nil {
+		return res, err
+	}
+	res.Updated = true
+	res.added, res.removed = bannerChanges(existing, merged)
 
-	return c.write(merged)
+	if err := c.saveProvenance(prov); err != nil {
+		return res, err
+	}
+	if err := c.saveMetadata(fetcher.MergeMetadata(datasets)); err != nil {
+		c.log.Warn("saving metadata sidecar failed", "error", err)
+	}
+	if err := c.saveURLTrust(trust); err != nil {
+		c.log.Warn("saving URL trust failed", "error", err)
+	}
+	if err := c.saveTombstones(tombs); err != nil {
+		c.log.Warn("saving tombstones failed", "error", err)
+	}
+	if err := c.saveDiskIndex(prov); err != nil {
+		c.log.Warn("saving disk index failed", "error", err)
+	}
+	return res, nil
 }
 
-// Ensure guarantees a valid cache exists, updating if necessary.
+// checkShrink refuses merged data that drops below the configured fraction
+// of the existing cache's entry count.
+func (c *Cache) checkShrink(existing, merged *fetcher.BannerData) error {
+	if c.cfg.ShrinkThreshold <= 0 {
+		return nil
+	}
+
+	if existing == nil || len(existing.Linux) == 0 {
+		return nil
+	}
+
+	if float64(len(merged.Linux)) < float64(len(existing.Linux))*c.cfg.ShrinkThreshold {
+		return fmt.Errorf("%w: %d entries, current cache has %d", ErrShrink, len(merged.Linux), len(existing.Linux))
+	}
+
+	return nil
+}
+
+// Ensure guarantees a valid cache exists, updating if necessary. Offline,
+// an expired cache is used as is.
 func (c *Cache) Ensure(ctx context.Context) error {
-	if c.IsValid() {
+	if _, exists := c.Path(); c.IsValid() || (c.cfg.Offline && exists) {
+		c.loadBackfilledMeta()
 		return nil
 	}
-	return c.Update(ctx, false)
+	_, err := c.Update(ctx, false)
+	return err
 }
 
 // acquireLock attempts to acquire an exclusive lock.
@@ -273,6 +1000,9 @@ func (c *Cache) acquireLock() error {
 	if err := os.MkdirAll(c.cfg.CacheDir, DirMode); err != nil {
 		return fmt.Errorf("creating cache dir: %w", err)
 	}
+	if err := os.MkdirAll(filepath.Dir(c.cfg.LockFile), DirMode); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
 
 	info, err := os.Stat(c.cfg.LockFile)
 	if err == nil {
@@ -299,11 +1029,22 @@ func (c *Cache) releaseLock() {
 
 // write atomically writes banner data to cache file.
 func (c *Cache) write(data *fetcher.BannerData) error {
+	data, dropped, err := c.forVol3(data)
+	if err != nil {
+		return err
+	}
+	if dropped > 0 {
+		c.log.Info("dropped symbol URLs volatility3 cannot use", "vol3_compat", c.cfg.Vol3Compat, "urls", dropped)
+	}
 	if err := os.MkdirAll(c.cfg.CacheDir, DirMode); err != nil {
 		return fmt.Errorf("creating cache dir: %w", err)
 	}
+	return writeBanners(c.cfg.CacheFile, data)
+}
 
-	tmp := c.cfg.CacheFile + ".tmp"
+// writeBanners atomically writes banner data to path.
+func writeBanners(path string, data *fetcher.BannerData) error {
+	tmp := path + ".tmp"
 
 	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
 	if err != nil {
@@ -331,209 +1072,174 @@ func (c *Cache) write(data *fetcher.BannerData) error {
 	}
 
 	// Atomic rename
-	if err := os.Rename(tmp, c.cfg.CacheFile); err != nil {
+	if err := os.Rename(tmp, path); err != nil {
 		_ = os.Remove(tmp)
-		return fmt.Errorf("renaming cache file: %w", err)
+		return fmt.Errorf("renaming %s: %w", filepath.Base(path), err)
 	}
 
 	return nil
 }
 
-// Clear removes the cache file.
-func (c *Cache) Clear() error {
-	if err := os.Remove(c.cfg.CacheFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing cache: %w", err)
-	}
-	return nil
+// ConfigureVolatility3 adds basar to the volatility3 configs found here:
+// the YAML ones vol3ConfigPaths picks, creating the platform's default
+// when there is none, and the JSON ones in the package directories of
+// volatility3 installs. In each, remote_isf_url is set to the cache, or
+// the cache is appended to the URLs it lists, unless it lists the cache
+// already; with replace, the cache becomes its only URL. The report lists
+// the installs found and the configs changed. ErrVol3AlreadyConfigured is
+// returned when every config already used the cache.
+func (c *Cache) ConfigureVolatility3(replace bool) (*Vol3Report, error) {
+	return c.configureVol3(replace, true)
 }
 
-// ConfigureVolatility3 adds basar to volatility3 config.
-func (c *Cache) ConfigureVolatility3() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("getting home dir: %w", err)
-	}
-
-	vol3Config := filepath.Join(home, ".volatility3.yaml")
+// configureVol3 is ConfigureVolatility3, which only writes the configs it
+// would change with write.
+func (c *Cache) configureVol3(replace, write bool) (*Vol3Report, error) {
 	uri, ok := c.URI()
 	if !ok {
 		// Cache doesn't exist yet, use the expected path
-		uri = "file://" + c.cfg.CacheFile
+		uri = fileURI(c.cfg.CacheFile)
+	}
+	paths, err := vol3ConfigPaths()
+	if err != nil {
+		return nil, err
 	}
 
-	content := fmt.Sprintf("# Added by basar\nremote_isf_url: %s\n", uri)
-
-	// Check if file exists
-	if _, err := os.Stat(vol3Config); err == nil {
-		// File exists, check if already configured
-		existing, err := os.ReadFile(vol3Config)
+	report := &Vol3Report{Installs: findVol3Installs()}
+	var errs []error
+	for _, path := range paths {
+		_, statErr := os.Stat(path)
+		changed, err := configureVol3YAML(path, uri, replace, write)
 		if err != nil {
-			return fmt.Errorf("reading volatility3 config: %w", err)
-		}
-
-		if contains(string(existing), "remote_isf_url") {
-			// Already has remote_isf_url, update it
-			// For simplicity, just append a comment
-			return fmt.Errorf("volatility3 config already has remote_isf_url, please update manually: %s", vol3Config)
+			errs = append(errs, err)
+			continue
 		}
-
-		// Append to existing file
-		f, err := os.OpenFile(vol3Config, os.O_APPEND|os.O_WRONLY, FileMode)
+		report.Configs = append(report.Configs, Vol3Config{Path: path, Changed: changed, Created: changed && statErr != nil})
+	}
+	for _, path := range vol3JSONConfigs(report.Installs) {
+		changed, err := configureVol3JSON(path, uri, replace, write)
 		if err != nil {
-			return fmt.Errorf("opening volatility3 config: %w", err)
-		}
-		defer f.Close()
-
-		if _, err := f.WriteString("\n" + content); err != nil {
-			return fmt.Errorf("writing volatility3 config: %w", err)
-		}
-	} else {
-		// Create new file
-		if err := os.WriteFile(vol3Config, []byte(content), FileMode); err != nil {
-			return fmt.Errorf("creating volatility3 config: %w", err)
+			errs = append(errs, err)
+			continue
 		}
+		report.Configs = append(report.Configs, Vol3Config{Path: path, Changed: changed})
 	}
 
-	return nil
+	if err := errors.Join(errs...); err != nil {
+		return report, err
+	}
+	if !report.Changed() {
+		return report, fmt.Errorf("%w: %s", ErrVol3AlreadyConfigured, strings.Join(paths, ", "))
+	}
+	return report, nil
 }
 
-// InstallService installs systemd user timer for automatic updates.
-func (c *Cache) InstallService() error {
-	if runtime.GOOS != "linux" {
-		return fmt.Errorf("systemd service only supported on Linux")
+// configureVol3YAML adds url to remote_isf_url in a volatility3 YAML
+// config, creating it if needed, and reports whether it changed it, or
+// would have without write. Only
+// the lines of remote_isf_url are edited, so the rest of the config,
+// comments included, is kept as written. The config is only replaced once
+// the result parses as YAML with the URL, and then atomically, so a bad
+// edit or an interruption never corrupts it.
+func configureVol3YAML(path, url string, replace, write bool) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("reading volatility3 config: %w", err)
 	}
-
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("getting home dir: %w", err)
+	if _, err := parseVol3Config(string(existing)); err != nil {
+		return false, fmt.Errorf("volatility3 config %s is not valid YAML, or uses YAML basar does not edit, leaving it unchanged: %w", path, err)
 	}
-
-	systemdDir := filepath.Join(home, ".config", "systemd", "user")
-	if err := os.MkdirAll(systemdDir, DirMode); err != nil {
-		return fmt.Errorf("creating systemd dir: %w", err)
+	lines := strings.Split(strings.TrimSuffix(string(existing), "\n"), "\n")
+	if len(existing) == 0 {
+		lines = nil
 	}
-
-	// Find basar binary
-	basarPath, err := exec.LookPath("basar")
+	current, err := parseISFURLs(lines)
 	if err != nil {
-		// Try common locations
-		basarPath = filepath.Join(home, ".local", "bin", "basar")
-		if _, err := os.Stat(basarPath); err != nil {
-			basarPath = "/usr/local/bin/basar"
-		}
+		return false, fmt.Errorf("volatility3 config %s: %w, leaving it unchanged", path, err)
 	}
-
-	// Service file
-	serviceContent := fmt.Sprintf(`[Unit]
-Description=Update basar ISF symbol cache
-After=network-online.target
-Wants=network-online.target
-
-[Service]
-Type=oneshot
-ExecStart=%s --smart-update
-Nice=19
-IOSchedulingClass=idle
-
-[Install]
-WantedBy=default.target
-`, basarPath)
-
-	servicePath := filepath.Join(systemdDir, "basar.service")
-	if err := os.WriteFile(servicePath, []byte(serviceContent), FileMode); err != nil {
-		return fmt.Errorf("writing service file: %w", err)
+	if current.has(url) && (!replace || len(current.values) == 1) {
+		return false, nil
 	}
-
-	// Timer file - runs on 1st and 15th of each month
-	timerContent := `[Unit]
-Description=Update basar ISF symbol cache periodically
-
-[Timer]
-OnCalendar=*-*-01,15 06:00:00
-RandomizedDelaySec=3600
-Persistent=true
-
-[Install]
-WantedBy=timers.target
-`
-
-	timerPath := filepath.Join(systemdDir, "basar.timer")
-	if err := os.WriteFile(timerPath, []byte(timerContent), FileMode); err != nil {
-		return fmt.Errorf("writing timer file: %w", err)
+	content := strings.Join(current.withURL(lines, url, replace), "\n") + "\n"
+	want := []string{url}
+	if !replace {
+		want = append(slices.Clone(current.values), url)
 	}
 
-	// Enable and start timer
-	if err := exec.Command("systemctl", "--user", "daemon-reload").Run(); err != nil {
-		return fmt.Errorf("daemon-reload failed: %w", err)
+	// Check the result, as volatility3 will read it: the URLs it lists must
+	// be exactly those intended, not merely include basar's
+	_, err = parseVol3Config(content)
+	if err == nil {
+		var result isfURLs
+		result, err = parseISFURLs(strings.Split(content, "\n"))
+		if err == nil && !slices.Equal(result.values, want) {
+			err = fmt.Errorf("it would list %q", result.values)
+		}
 	}
-
-	if err := exec.Command("systemctl", "--user", "enable", "basar.timer").Run(); err != nil {
-		return fmt.Errorf("enabling timer failed: %w", err)
+	if err != nil {
+		return false, fmt.Errorf("adding %s to %s would not set it to %s, leaving it unchanged", vol3ISFKey, path, url)
 	}
-
-	if err := exec.Command("systemctl", "--user", "start", "basar.timer").Run(); err != nil {
-		return fmt.Errorf("starting timer failed: %w", err)
+	if !write {
+		return true, nil
 	}
-
-	return nil
+	if err := replaceFile(path, []byte(content)); err != nil {
+		return false, fmt.Errorf("writing volatility3 config: %w", err)
+	}
+	return true, nil
 }
 
+// StorageBackends lists where the merged index can be stored.
+var StorageBackends = []string{"file"}
+
 // Setup performs complete setup: config, update, vol3 config, service.
-func (c *Cache) Setup(ctx context.Context, verbose bool) error {
+func (c *Cache) Setup(ctx context.Context) error {
 	// 1. Initialize config if needed
-	if _, err := os.Stat(c.cfg.ConfigFile); os.IsNotExist(err) {
-		if err := c.cfg.InitConfig(); err != nil {
-			return fmt.Errorf("creating config: %w", err)
-		}
-		if verbose {
-			_, _ = fmt.Fprintf(os.Stderr, "created config: %s\n", c.cfg.ConfigFile)
-		}
+	switch err := c.cfg.InitConfig(config.PresetFull); {
+	case err == nil:
+		c.log.Info("created config", "path", c.cfg.ConfigFile)
+	case !errors.Is(err, config.ErrConfigExists):
+		return fmt.Errorf("creating config: %w", err)
 	}
 
 	// 2. Initial update
-	if verbose {
-		_, _ = fmt.Fprintf(os.Stderr, "updating cache from %d sources...\n", len(c.cfg.Sources))
-	}
-	if err := c.Update(ctx, true); err != nil {
+	c.log.Info("updating cache", "sources", len(c.cfg.Sources))
+	res, err := c.Update(ctx, true)
+	if err != nil {
 		return fmt.Errorf("updating cache: %w", err)
 	}
-	if verbose {
-		stats := c.Stats()
-		_, _ = fmt.Fprintf(os.Stderr, "cached %d banners\n", stats.Entries)
-	}
+	c.log.Info("cached banners", "entries", res.EntriesAfter)
 
 	// 3. Configure volatility3
-	if err := c.ConfigureVolatility3(); err != nil {
-		if verbose {
-			_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	report, err := c.ConfigureVolatility3(false)
+	if report != nil {
+		for _, install := range report.Installs {
+			c.log.Info("found volatility3", "dir", install.Dir, "version", install.Version, "via", install.Via)
 		}
-	} else if verbose {
-		_, _ = fmt.Fprintf(os.Stderr, "configured volatility3\n")
 	}
-
-	// 4. Install systemd service (Linux only)
-	if runtime.GOOS == "linux" {
-		if err := c.InstallService(); err != nil {
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stderr, "warning: service install failed: %v\n", err)
+	switch {
+	case errors.Is(err, ErrVol3AlreadyConfigured):
+		c.log.Info("volatility3 already configured; leaving it unchanged")
+	case err != nil:
+		c.log.Warn("configuring volatility3 failed", "error", err)
+	default:
+		for _, config := range report.Configs {
+			if config.Changed {
+				c.log.Info("configured volatility3", "config", config.Path)
 			}
-		} else if verbose {
-			_, _ = fmt.Fprintf(os.Stderr, "installed systemd timer (runs twice monthly)\n")
 		}
 	}
 
-	return nil
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsImpl(s, substr))
-}
-
-func containsImpl(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+	// 4. Install auto-update service where supported
+	if s, err := c.Scheduler(); err == nil {
+		switch what, err := s.Install(c); {
+		case err != nil:
+			c.log.Warn("service install failed", "scheduler", s.Name(), "error", err)
+		case what == "":
+			c.log.Info("auto-updates left to basar serve", "scheduler", s.Name())
+		default:
+			c.log.Info("installed auto-updates", "service", what, "schedule", "twice monthly")
 		}
 	}
-	return false
+
+	return nil
 }
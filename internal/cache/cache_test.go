@@ -2,9 +2,16 @@ package cache
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -19,13 +26,23 @@ func testConfig(t *testing.T) *config.Config {
 	tmpDir := t.TempDir()
 
 	return &config.Config{
-		CacheDir:   tmpDir,
-		ConfigDir:  tmpDir,
-		CacheFile:  filepath.Join(tmpDir, "banners.json"),
-		ConfigFile: filepath.Join(tmpDir, "sources.conf"),
-		LockFile:   filepath.Join(tmpDir, ".lock"),
-		TTL:        24 * time.Hour,
-		Sources:    []string{},
+		CacheDir:        tmpDir,
+		ConfigDir:       tmpDir,
+		StateDir:        tmpDir,
+		CacheFile:       filepath.Join(tmpDir, "banners.json"),
+		ConfigFile:      filepath.Join(tmpDir, "sources.conf"),
+		LockFile:        filepath.Join(tmpDir, ".lock"),
+		MetaFile:        filepath.Join(tmpDir, "meta.json"),
+		LivenessFile:    filepath.Join(tmpDir, "liveness.json"),
+		HistoryFile:     filepath.Join(tmpDir, "history.jsonl"),
+		UsageFile:       filepath.Join(tmpDir, "usage.json"),
+		PruneReportFile: filepath.Join(tmpDir, "prune-report.json"),
+		SnapshotDir:     filepath.Join(tmpDir, "snapshots"),
+		QuarantineDir:   filepath.Join(tmpDir, "quarantine"),
+		TTL:             24 * time.Hour,
+		Sources:         []string{},
+
+		OverridesFile: filepath.Join(tmpDir, "overrides.json"),
 	}
 }
 
@@ -205,6 +222,30 @@ func TestURI(t *testing.T) {
 	}
 }
 
+func TestFileURI(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/home/u/.cache/basar/banners.json", want: "file:///home/u/.cache/basar/banners.json"},
+		{path: "/tmp/with space/banners.json", want: "file:///tmp/with%20space/banners.json"},
+	}
+	if runtime.GOOS == "windows" {
+		tests = []struct {
+			path string
+			want string
+		}{
+			{path: `C:\Users\u\AppData\Local\basar\cache\banners.json`, want: "file:///C:/Users/u/AppData/Local/basar/cache/banners.json"},
+		}
+	}
+
+	for _, tt := range tests {
+		if got := fileURI(tt.path); got != tt.want {
+			t.Errorf("fileURI(%q) = %q, expected %q", tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestStats(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -303,6 +344,34 @@ func TestClear(t *testing.T) {
 	}
 }
 
+func TestClearAll(t *testing.T) {
+	cfg := testConfig(t)
+
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	if err := c.ClearAll(); err != nil {
+		t.Fatalf("ClearAll() failed: %v", err)
+	}
+
+	for _, path := range []string{cfg.CacheFile, cfg.SnapshotDir, cfg.MetaFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+
+	// Clearing again is a no-op
+	if err := c.ClearAll(); err != nil {
+		t.Errorf("ClearAll() on empty cache failed: %v", err)
+	}
+}
+
 func TestAcquireLock(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -433,7 +502,7 @@ func TestUpdateWithLocalSource(t *testing.T) {
 	c := New(cfg)
 	ctx := context.Background()
 
-	err := c.Update(ctx, true)
+	_, err := c.Update(ctx, true)
 	if err != nil {
 		t.Fatalf("Update() failed: %v", err)
 	}
@@ -462,7 +531,7 @@ func TestUpdateSkipsWhenValid(t *testing.T) {
 	ctx := context.Background()
 
 	// Non-forced update should skip
-	err := c.Update(ctx, false)
+	_, err := c.Update(ctx, false)
 	if err != nil {
 		t.Errorf("Update(force=false) should skip when cache is valid: %v", err)
 	}
@@ -477,14 +546,61 @@ func TestUpdateAllSourcesFailed(t *testing.T) {
 	c := New(cfg)
 	ctx := context.Background()
 
-	err := c.Update(ctx, true)
-	if err == nil {
-		t.Error("Update() should fail when all sources fail")
+	_, err := c.Update(ctx, true)
+	if !errors.Is(err, ErrAllSourcesFailed) {
+		t.Errorf("Update() error = %v, expected ErrAllSourcesFailed", err)
+	}
+}
+
+func TestUpdateRefusesShrink(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ShrinkThreshold = 0.5
+
+	// Existing cache has two banners; the source only provides one
+	createTestBannerFile(t, cfg.CacheFile)
+
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	data := &fetcher.BannerData{
+		Version: 1,
+		Linux: map[string][]string{
+			"Linux version 5.15.0-generic": {"https://example.com/symbols/5.15.0.json"},
+		},
+	}
+	raw, _ := json.Marshal(data)
+	if err := os.WriteFile(sourceFile, raw, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	cfg.Sources = []string{sourceFile}
+
+	c := New(cfg)
+	ctx := context.Background()
+
+	// One of two entries is exactly 50%, which the guard accepts
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatalf("Update() at threshold should succeed: %v", err)
+	}
+
+	// Restore the larger cache and raise the bar
+	createTestBannerFile(t, cfg.CacheFile)
+	cfg.ShrinkThreshold = 0.75
+
+	_, err := c.Update(ctx, true)
+	if !errors.Is(err, ErrShrink) {
+		t.Fatalf("Update() error = %v, expected ErrShrink", err)
+	}
+
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Errorf("cache should be untouched, got %d entries", stats.Entries)
+	}
+
+	// Disabling the guard (as --force does) lets the update through
+	cfg.ShrinkThreshold = 0
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatalf("Update() with guard disabled failed: %v", err)
 	}
 
-	expectedMsg := "all sources failed"
-	if err.Error() != expectedMsg {
-		t.Errorf("Update() error = %q, expected %q", err.Error(), expectedMsg)
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Errorf("Stats().Entries = %d, expected 1", stats.Entries)
 	}
 }
 
@@ -551,7 +667,7 @@ func TestUpdateWithContextCancellation(t *testing.T) {
 	cancel() // Cancel immediately
 
 	// Update should still work for local files (context mainly affects HTTP)
-	err := c.Update(ctx, true)
+	_, err := c.Update(ctx, true)
 
 	// Local file fetching doesn't use context, so this should succeed
 	if err != nil {
@@ -570,7 +686,7 @@ func TestUpdateMergesMultipleSources(t *testing.T) {
 	data1 := &fetcher.BannerData{
 		Version: 1,
 		Linux: map[string][]string{
-			"banner1": {"url1"},
+			"banner1": {"https://example.com/url1"},
 		},
 	}
 	f1, _ := os.Create(source1)
@@ -581,7 +697,7 @@ func TestUpdateMergesMultipleSources(t *testing.T) {
 	data2 := &fetcher.BannerData{
 		Version: 1,
 		Linux: map[string][]string{
-			"banner2": {"url2"},
+			"banner2": {"https://example.com/url2"},
 		},
 	}
 	f2, _ := os.Create(source2)
@@ -593,7 +709,7 @@ func TestUpdateMergesMultipleSources(t *testing.T) {
 	c := New(cfg)
 	ctx := context.Background()
 
-	err := c.Update(ctx, true)
+	_, err := c.Update(ctx, true)
 	if err != nil {
 		t.Fatalf("Update() failed: %v", err)
 	}
@@ -604,6 +720,52 @@ func TestUpdateMergesMultipleSources(t *testing.T) {
 	}
 }
 
+func TestUpdateKeepsBannerMetadata(t *testing.T) {
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	raw := `{"version":1,"linux":{"banner1":["https://example.com/url1"],"banner2":["https://example.com/url2"]},` +
+		`"metadata":{"banner1":{"size":1024,"build_id":"abc"},"banner2":{"size":2048}}}`
+	if err := os.WriteFile(source, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{source}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.CacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "metadata") {
+		t.Errorf("the cache volatility3 reads should not carry metadata: %s", data)
+	}
+
+	matches, err := c.Lookup("banner1")
+	if err != nil || len(matches) != 1 || string(matches[0].Metadata["build_id"]) != `"abc"` {
+		t.Errorf("Lookup() = %+v, %v; expected banner1 with its build id", matches, err)
+	}
+	if got := c.Stats().Metadata; got["size"] != 2 || got["build_id"] != 1 {
+		t.Errorf("Stats().Metadata = %v, expected size on 2 banners and build_id on 1", got)
+	}
+
+	// A source dropping its metadata removes the sidecar
+	if err := os.WriteFile(source, []byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.metadataPath()); !os.IsNotExist(err) {
+		t.Errorf("metadata sidecar should be removed, stat error = %v", err)
+	}
+	if got := c.Stats().Metadata; got != nil {
+		t.Errorf("Stats().Metadata without metadata = %v", got)
+	}
+}
+
 func TestSmartUpdate(t *testing.T) {
 	cfg := testConfig(t)
 
@@ -616,11 +778,11 @@ func TestSmartUpdate(t *testing.T) {
 	ctx := context.Background()
 
 	// First smart update - should update
-	updated, err := c.SmartUpdate(ctx, false)
+	res, err := c.SmartUpdate(ctx)
 	if err != nil {
 		t.Fatalf("SmartUpdate() failed: %v", err)
 	}
-	if !updated {
+	if !res.Updated {
 		t.Error("first SmartUpdate should return updated=true")
 	}
 
@@ -630,6 +792,56 @@ func TestSmartUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	missing := filepath.Join(cfg.ConfigDir, "missing.json")
+	cfg.Sources = []string{server.URL, missing}
+	c := New(cfg)
+	ctx := context.Background()
+
+	res, err := c.Update(ctx, true)
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if !res.Updated || res.EntriesBefore != 0 || res.EntriesAfter != 1 || res.Generation != 1 || res.Duration <= 0 {
+		t.Errorf("unexpected first result: %+v", res)
+	}
+	if len(res.Sources) != 2 || res.Sources[0].Status != fetcher.StatusOK || res.Sources[0].Entries != 1 ||
+		res.Sources[1].Status != fetcher.StatusError || res.Sources[1].Error == "" {
+		t.Errorf("unexpected source results: %+v", res.Sources)
+	}
+
+	// Unchanged sources leave the generation alone
+	res, err = c.SmartUpdate(ctx)
+	if err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+	if res.Updated || res.Generation != 1 || res.Sources[0].Status != fetcher.StatusNotModified {
+		t.Errorf("unexpected unchanged result: %+v", res)
+	}
+
+	res, err = c.Update(ctx, true)
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if res.EntriesBefore != 1 || res.Generation != 2 {
+		t.Errorf("unexpected second result: %+v", res)
+	}
+	if got := c.Stats().Generation; got != 2 {
+		t.Errorf("Stats().Generation = %d, expected 2", got)
+	}
+}
+
 func TestSmartUpdateNoChange(t *testing.T) {
 	cfg := testConfig(t)
 
@@ -642,21 +854,74 @@ func TestSmartUpdateNoChange(t *testing.T) {
 	ctx := context.Background()
 
 	// First update
-	_ = c.Update(ctx, true)
+	_, _ = c.Update(ctx, true)
 
 	// Second smart update - local files always report modified
 	// (conditional requests only work with HTTP)
-	updated, err := c.SmartUpdate(ctx, false)
+	res, err := c.SmartUpdate(ctx)
 	if err != nil {
 		t.Fatalf("SmartUpdate() failed: %v", err)
 	}
 
 	// Local files always appear modified since there's no ETag/Last-Modified
-	if !updated {
+	if !res.Updated {
 		t.Log("SmartUpdate with local files always reports updated")
 	}
 }
 
+func TestSmartUpdateMergesTimedOutSnapshot(t *testing.T) {
+	slow := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slow {
+			_, _ = w.Write([]byte(`{"version":1,"linux":{"Linux version 5.15.0-slow":["https://isf.example.org/5.15.json.xz"]}}`))
+			return
+		}
+		// Stall mid-download until the client gives up
+		_, _ = w.Write([]byte(`{"version":1,"linux":{`))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	writeBannerSource(t, local, bannerURLs("local-6.1"))
+	cfg.Sources = []string{server.URL + "/banners.json", local}
+	cfg.Options = map[string]config.SourceOptions{cfg.Sources[0]: {Timeout: 200 * time.Millisecond}}
+	c := New(cfg)
+	ctx := context.Background()
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	slow = true
+	writeBannerSource(t, local, bannerURLs("local-6.1", "local-6.2"))
+	res, err := c.SmartUpdate(ctx)
+	if err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+	if s := res.Sources[0]; !s.Stale || s.Status != fetcher.StatusError || !strings.HasPrefix(s.Error, "timed out (using stale snapshot)") {
+		t.Errorf("slow source result = %+v, expected it stale", s)
+	}
+	if !res.Partial() {
+		t.Error("an update with a timed-out source should be partial")
+	}
+	if got := c.Stats().Entries; got != 3 {
+		t.Errorf("cache has %d entries, expected the slow source's snapshot kept alongside 2", got)
+	}
+
+	// A source failing otherwise is not replaced by its snapshot
+	server.Close()
+	writeBannerSource(t, local, bannerURLs("local-6.1"))
+	res, err = c.SmartUpdate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sources[0].Stale || c.Stats().Entries != 1 {
+		t.Errorf("unreachable source = %+v with %d entries, expected its banners dropped", res.Sources[0], c.Stats().Entries)
+	}
+}
+
 func TestLoadAndSaveMeta(t *testing.T) {
 	cfg := testConfig(t)
 	c := New(cfg)
@@ -686,6 +951,417 @@ func TestLoadAndSaveMeta(t *testing.T) {
 	if loaded.Sources["http://example.com"].ETag != `"abc123"` {
 		t.Error("ETag not preserved")
 	}
+
+	if loaded.API == nil {
+		t.Error("loaded meta should always carry an API cache")
+	}
+}
+
+func TestStatsPerSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"],"banner2":["https://example.com/url2"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	missing := filepath.Join(cfg.ConfigDir, "missing.json")
+	cfg.Sources = []string{server.URL, missing}
+
+	c := New(cfg)
+	ctx := context.Background()
+
+	if _, err := c.SmartUpdate(ctx); err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+
+	stats := c.Stats()
+	if len(stats.Sources) != 2 {
+		t.Fatalf("expected 2 source stats, got %d", len(stats.Sources))
+	}
+
+	ok := stats.Sources[0]
+	if ok.Status != fetcher.StatusOK || ok.Entries != 2 || ok.Bytes == 0 || ok.LastFetch.IsZero() {
+		t.Errorf("unexpected stats for fetched source: %+v", ok)
+	}
+
+	failed := stats.Sources[1]
+	if failed.Status != fetcher.StatusError || failed.Error == "" {
+		t.Errorf("unexpected stats for failed source: %+v", failed)
+	}
+
+	// Second run gets 304 and keeps the entry count
+	if _, err := c.SmartUpdate(ctx); err != nil {
+		t.Fatalf("second SmartUpdate() failed: %v", err)
+	}
+
+	stats = c.Stats()
+	notModified := stats.Sources[0]
+	if notModified.Status != fetcher.StatusNotModified || notModified.Entries != 2 || notModified.Bytes != 0 {
+		t.Errorf("unexpected stats after 304: %+v", notModified)
+	}
+	if notModified.Failures != 0 || stats.Sources[1].Failures != 2 {
+		t.Errorf("failures = %d, %d; expected 0, 2", notModified.Failures, stats.Sources[1].Failures)
+	}
+	if stats.LastUpdate == nil || !stats.LastUpdate.Success {
+		t.Errorf("expected a successful last update, got %+v", stats.LastUpdate)
+	}
+}
+
+func TestStatsLastUpdateFailure(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Sources = []string{filepath.Join(cfg.ConfigDir, "missing.json")}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err == nil {
+		t.Fatal("Update() should fail when all sources fail")
+	}
+
+	last := c.Stats().LastUpdate
+	if last == nil || last.Success || last.Error == "" || last.At.IsZero() {
+		t.Errorf("expected a recorded failure, got %+v", last)
+	}
+}
+
+func TestUpdateUsesSourceToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer from-env" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("BASAR_TEST_SOURCE_TOKEN", "from-env")
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL}
+	cfg.Options = map[string]config.SourceOptions{
+		server.URL: {TokenEnv: "BASAR_TEST_SOURCE_TOKEN"},
+	}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() with token failed: %v", err)
+	}
+}
+
+func TestUpdateThroughPACProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() != "http://isf.example.invalid/banners.json" {
+			t.Errorf("proxy got a request for %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+	}))
+	defer proxy.Close()
+
+	cfg := testConfig(t)
+	cfg.ProxyPAC = filepath.Join(cfg.ConfigDir, "proxy.pac")
+	pac := `function FindProxyForURL(url, host) {
+		return dnsDomainIs(host, ".invalid") ? "PROXY ` + strings.TrimPrefix(proxy.URL, "http://") + `" : "DIRECT";
+	}`
+	if err := os.WriteFile(cfg.ProxyPAC, []byte(pac), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{"http://isf.example.invalid/banners.json"}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() through the PAC proxy failed: %v", err)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 {
+		t.Errorf("Lookup() = %+v", matches)
+	}
+}
+
+func TestUpdateNegotiateSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Header.Get("Authorization"), "Negotiate "))
+		if string(token) != "HTTP@127.0.0.1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.NegotiateCmd = `printf '%s' "$BASAR_NEGOTIATE_SPN" | base64`
+	cfg.Sources = []string{server.URL + "/a.json", server.URL + "/b.json"}
+	cfg.Options = map[string]config.SourceOptions{
+		server.URL + "/a.json": {Auth: config.AuthNegotiate},
+		server.URL + "/b.json": {Auth: "ntlm"},
+	}
+
+	c := New(cfg)
+	res, err := c.Update(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Update() with a Negotiate source failed: %v", err)
+	}
+	if !res.Partial() || len(res.Sources) != 2 || !strings.Contains(res.Sources[1].Error, `unsupported auth "ntlm"`) {
+		t.Errorf("Sources = %+v, expected the ntlm source to fail", res.Sources)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 {
+		t.Errorf("Lookup() = %+v", matches)
+	}
+}
+
+func TestUpdateNetrcSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if login, password, ok := r.BasicAuth(); !ok || login != "alice" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+	}))
+	defer server.Close()
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL + "/banners.json"}
+	cfg.Options = map[string]config.SourceOptions{server.URL + "/banners.json": {Auth: config.AuthNetrc}}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() with a netrc source failed: %v", err)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 {
+		t.Errorf("Lookup() = %+v", matches)
+	}
+}
+
+func TestUpdateGitHubSource(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/owner/symbols/contents/banners":
+			fmt.Fprintf(w, `[{"type":"file","path":"banners/banners.json","sha":"a1","download_url":"%s/raw/banners.json"}]`, server.URL)
+		case "/raw/banners.json":
+			_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{"github://owner/symbols/banners"}
+	cfg.GitHubAPI = server.URL
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() with a github:// source failed: %v", err)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 {
+		t.Errorf("Lookup() = %+v", matches)
+	}
+}
+
+func TestUpdateGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "banners.json"), []byte(`{"version":1,"linux":{"banner1":["https://example.com/url1"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "banners.json"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "--message", "banners"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := testConfig(t)
+	source := "git+file://" + filepath.ToSlash(repo) + "#path=banners.json"
+	cfg.Sources = []string{source}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() with a git source failed: %v", err)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 {
+		t.Errorf("Lookup() = %+v", matches)
+	}
+	if meta := c.loadMeta(); len(meta.Sources[source].Commit) != 40 {
+		t.Errorf("meta = %+v, expected the commit read", meta.Sources[source])
+	}
+}
+
+func TestProxyAuth(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine proxy.corp.example login bob password hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	cfg := testConfig(t)
+	cfg.ProxyAuth = config.AuthNetrc
+	auth, err := New(cfg).proxyAuth(context.Background(), "proxy.corp.example:3128")
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("bob:hunter2")); err != nil || auth != want {
+		t.Errorf("proxyAuth() = %q, %v; expected %q", auth, err, want)
+	}
+	if _, err := New(cfg).proxyAuth(context.Background(), "other.example:3128"); err == nil {
+		t.Error("proxyAuth() should fail for a proxy without a netrc entry")
+	}
+}
+
+func TestUpdateFailFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{server.URL, sourceFile}
+
+	// Without fail-fast the working source is still cached
+	if _, err := New(cfg).Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	cfg.FailFast = true
+	_, err := New(cfg).Update(context.Background(), true)
+	if !errors.Is(err, fetcher.ErrConfiguration) {
+		t.Errorf("Update() with fail-fast should return the configuration error, got %v", err)
+	}
+}
+
+func TestUpdateRequiredSource(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	missing := filepath.Join(cfg.ConfigDir, "internal.json")
+	cfg.Sources = []string{sourceFile, missing}
+	cfg.Options = map[string]config.SourceOptions{missing: {Required: true}}
+
+	c := New(cfg)
+	for name, update := range map[string]func() error{
+		"Update":      func() error { _, err := c.Update(context.Background(), true); return err },
+		"SmartUpdate": func() error { _, err := c.SmartUpdate(context.Background()); return err },
+	} {
+		if err := update(); !errors.Is(err, ErrRequiredSource) {
+			t.Errorf("%s() should fail on the required source, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(cfg.CacheFile); !os.IsNotExist(err) {
+		t.Error("cache should not be written without the required source")
+	}
+
+	stats := c.Stats()
+	if len(stats.Sources) != 2 || !stats.Sources[1].Required {
+		t.Errorf("stats should mark the required source, got %+v", stats.Sources)
+	}
+}
+
+func TestUpdateMinSources(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile, filepath.Join(cfg.ConfigDir, "missing.json")}
+
+	res, err := New(cfg).Update(context.Background(), true)
+	if err != nil || !res.Partial() {
+		t.Fatalf("Update() = %+v, %v; expected a partial success", res, err)
+	}
+
+	cfg.MinSources = 2
+	if _, err := New(cfg).Update(context.Background(), true); !errors.Is(err, ErrTooFewSources) {
+		t.Errorf("Update() with MinSources=2 should fail, got %v", err)
+	}
+
+	cfg.MinSources = 0
+	cfg.Strict = true
+	if _, err := New(cfg).Update(context.Background(), true); !errors.Is(err, ErrTooFewSources) {
+		t.Errorf("Update() in strict mode should fail, got %v", err)
+	}
+}
+
+func TestUpdateSavesMeta(t *testing.T) {
+	cfg := testConfig(t)
+
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	meta := c.loadMeta()
+	if _, ok := meta.Sources[sourceFile]; !ok {
+		t.Error("Update() should record metadata for fetched sources")
+	}
+}
+
+func TestUpdateRunsHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell hooks are not supported on windows")
+	}
+
+	cfg := testConfig(t)
+	cfg.HooksDir = filepath.Join(cfg.ConfigDir, "hooks.d")
+	if err := os.MkdirAll(cfg.HooksDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile, filepath.Join(cfg.ConfigDir, "missing.json")}
+
+	record := filepath.Join(cfg.ConfigDir, "post.env")
+	post := "#!/bin/sh\necho \"$BASAR_HOOK $BASAR_UPDATE_STATUS $BASAR_ENTRIES $BASAR_FAILED_SOURCES\" > " + record + "\n"
+	if err := os.WriteFile(filepath.Join(cfg.HooksDir, "post-update"), []byte(post), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(cfg)
+	if _, err := c.SmartUpdate(context.Background()); err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("post-update hook did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "post-update updated 2 1" {
+		t.Errorf("post-update hook saw %q, expected %q", got, "post-update updated 2 1")
+	}
+
+	// A failing pre-update hook vetoes the update
+	pre := "#!/bin/sh\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(cfg.HooksDir, "pre-update"), []byte(pre), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(context.Background(), true); err == nil {
+		t.Error("Update() should fail when the pre-update hook fails")
+	}
 }
 
 func TestConfigureVolatility3(t *testing.T) {
@@ -705,7 +1381,7 @@ func TestConfigureVolatility3(t *testing.T) {
 
 	c := New(cfg)
 
-	err := c.ConfigureVolatility3()
+	_, err := c.ConfigureVolatility3(false)
 	if err != nil {
 		t.Fatalf("ConfigureVolatility3 failed: %v", err)
 	}
@@ -740,14 +1416,18 @@ func TestConfigureVolatility3AlreadyExists(t *testing.T) {
 		os.Setenv("USERPROFILE", origUserProfile)
 	}()
 
-	// Create existing config with remote_isf_url
+	// Create existing config already using the cache
 	vol3Config := filepath.Join(home, ".volatility3.yaml")
-	_ = os.WriteFile(vol3Config, []byte("remote_isf_url: http://other.com\n"), 0644)
 
 	c := New(cfg)
+	uri := fileURI(cfg.CacheFile)
+	_ = os.WriteFile(vol3Config, []byte("remote_isf_url: "+uri+"\n"), 0644)
 
-	err := c.ConfigureVolatility3()
-	if err == nil {
-		t.Error("should error when remote_isf_url already exists")
+	_, err := c.ConfigureVolatility3(false)
+	if !errors.Is(err, ErrVol3AlreadyConfigured) {
+		t.Errorf("ConfigureVolatility3(false) = %v, expected ErrVol3AlreadyConfigured", err)
+	}
+	if _, err := c.ConfigureVolatility3(true); !errors.Is(err, ErrVol3AlreadyConfigured) {
+		t.Errorf("ConfigureVolatility3(true) = %v, expected ErrVol3AlreadyConfigured", err)
 	}
 }
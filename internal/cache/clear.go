@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Clear targets accepted by ClearTarget.
+const (
+	ClearCache     = "cache"
+	ClearMeta      = "meta"
+	ClearSnapshots = "snapshots"
+	ClearMirror    = "mirror"
+	ClearLiveness  = "liveness"
+	ClearHistory   = "history"
+	ClearAllTarget = "all"
+)
+
+// ClearTargets lists the valid ClearTarget arguments.
+var ClearTargets = []string{ClearCache, ClearMeta, ClearSnapshots, ClearMirror, ClearLiveness, ClearHistory, ClearAllTarget}
+
+// mirrorDir returns the default directory for mirrored symbol files.
+func (c *Cache) mirrorDir() string {
+	return filepath.Join(c.cfg.CacheDir, "mirror")
+}
+
+// Clear removes the cache file and its provenance, metadata, tombstones,
+// URL trust, index sidecars, and layered view.
+func (c *Cache) Clear() error {
+	if err := os.Remove(c.cfg.CacheFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing cache: %w", err)
+	}
+	if err := os.Remove(c.provenancePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing provenance: %w", err)
+	}
+	if err := os.Remove(c.metadataPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing banner metadata: %w", err)
+	}
+	if err := os.Remove(c.tombstonesPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing tombstones: %w", err)
+	}
+	if err := os.Remove(c.urlTrustPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing URL trust: %w", err)
+	}
+	if err := os.Remove(c.diskIndexPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing disk index: %w", err)
+	}
+	if err := os.Remove(c.cfg.LayeredFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing layered view: %w", err)
+	}
+	return nil
+}
+
+// ClearMeta removes source metadata, so the next update refetches every
+// source without conditional requests.
+func (c *Cache) ClearMeta() error {
+	if err := os.Remove(c.cfg.MetaFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing metadata: %w", err)
+	}
+	return nil
+}
+
+// ClearSnapshots removes the per-source snapshots.
+func (c *Cache) ClearSnapshots() error {
+	if err := os.RemoveAll(c.snapshotDir()); err != nil {
+		return fmt.Errorf("removing snapshots: %w", err)
+	}
+	return nil
+}
+
+// ClearMirror removes mirrored symbol files from the default mirror directory.
+func (c *Cache) ClearMirror() error {
+	if err := os.RemoveAll(c.mirrorDir()); err != nil {
+		return fmt.Errorf("removing mirror: %w", err)
+	}
+	return nil
+}
+
+// ClearLiveness removes the symbol URL check history.
+func (c *Cache) ClearLiveness() error {
+	if err := os.Remove(c.cfg.LivenessFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing liveness history: %w", err)
+	}
+	return nil
+}
+
+// ClearHistory removes the update history used by Report.
+func (c *Cache) ClearHistory() error {
+	if err := os.Remove(c.cfg.HistoryFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing update history: %w", err)
+	}
+	return nil
+}
+
+// ClearAll removes the cache along with snapshots, source metadata,
+// mirrored files, and URL and update history, so the next update starts
+// from scratch.
+func (c *Cache) ClearAll() error {
+	for _, clear := range []func() error{c.Clear, c.ClearSnapshots, c.ClearMeta, c.ClearMirror, c.ClearLiveness, c.ClearHistory} {
+		if err := clear(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearPaths returns the files and directories ClearTarget(target)
+// removes.
+func (c *Cache) clearPaths(target string) ([]string, error) {
+	switch target {
+	case ClearCache:
+		return []string{c.cfg.CacheFile, c.provenancePath(), c.metadataPath(), c.tombstonesPath(),
+			c.urlTrustPath(), c.diskIndexPath(), c.cfg.LayeredFile}, nil
+	case ClearMeta:
+		return []string{c.cfg.MetaFile}, nil
+	case ClearSnapshots:
+		return []string{c.snapshotDir()}, nil
+	case ClearMirror:
+		return []string{c.mirrorDir()}, nil
+	case ClearLiveness:
+		return []string{c.cfg.LivenessFile}, nil
+	case ClearHistory:
+		return []string{c.cfg.HistoryFile}, nil
+	case ClearAllTarget:
+		var paths []string
+		for _, target := range []string{ClearCache, ClearSnapshots, ClearMeta, ClearMirror, ClearLiveness, ClearHistory} {
+			more, _ := c.clearPaths(target)
+			paths = append(paths, more...)
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("unknown clear target %q (want %s)", target, strings.Join(ClearTargets, ", "))
+}
+
+// ClearTarget removes the artifacts named by target, one of ClearTargets.
+func (c *Cache) ClearTarget(target string) error {
+	switch target {
+	case ClearCache:
+		return c.Clear()
+	case ClearMeta:
+		return c.ClearMeta()
+	case ClearSnapshots:
+		return c.ClearSnapshots()
+	case ClearMirror:
+		return c.ClearMirror()
+	case ClearLiveness:
+		return c.ClearLiveness()
+	case ClearHistory:
+		return c.ClearHistory()
+	case ClearAllTarget:
+		return c.ClearAll()
+	}
+	return fmt.Errorf("unknown clear target %q (want %s)", target, strings.Join(ClearTargets, ", "))
+}
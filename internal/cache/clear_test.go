@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClearTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		removed []string
+		kept    []string
+	}{
+		{
+			target:  ClearCache,
+			removed: []string{"banners.json", "provenance.json"},
+			kept:    []string{"meta.json", "snapshots", "mirror"},
+		},
+		{
+			target:  ClearMeta,
+			removed: []string{"meta.json"},
+			kept:    []string{"banners.json", "snapshots", "mirror"},
+		},
+		{
+			target:  ClearSnapshots,
+			removed: []string{"snapshots"},
+			kept:    []string{"banners.json", "meta.json", "mirror"},
+		},
+		{
+			target:  ClearMirror,
+			removed: []string{"mirror"},
+			kept:    []string{"banners.json", "meta.json", "snapshots"},
+		},
+		{
+			target:  ClearLiveness,
+			removed: []string{"liveness.json"},
+			kept:    []string{"banners.json", "meta.json", "snapshots", "mirror"},
+		},
+		{
+			target:  ClearHistory,
+			removed: []string{"history.jsonl"},
+			kept:    []string{"banners.json", "meta.json", "snapshots", "liveness.json"},
+		},
+		{
+			target:  ClearAllTarget,
+			removed: []string{"banners.json", "provenance.json", "meta.json", "snapshots", "mirror", "liveness.json", "history.jsonl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			cfg := testConfig(t)
+
+			sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+			createTestBannerFile(t, sourceFile)
+			cfg.Sources = []string{sourceFile}
+
+			c := New(cfg)
+			if _, err := c.SmartUpdate(context.Background()); err != nil {
+				t.Fatalf("SmartUpdate() failed: %v", err)
+			}
+			if err := os.MkdirAll(c.mirrorDir(), DirMode); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.saveLiveness(Liveness{"https://example.com/a.json": {Checks: 1}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.ClearTarget(tt.target); err != nil {
+				t.Fatalf("ClearTarget(%q) failed: %v", tt.target, err)
+			}
+
+			for _, name := range tt.removed {
+				if _, err := os.Stat(filepath.Join(cfg.CacheDir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s should be removed", name)
+				}
+			}
+			for _, name := range tt.kept {
+				if _, err := os.Stat(filepath.Join(cfg.CacheDir, name)); err != nil {
+					t.Errorf("%s should be kept: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestClearTargetUnknown(t *testing.T) {
+	c := New(testConfig(t))
+	if err := c.ClearTarget("everything"); err == nil {
+		t.Error("ClearTarget() should reject unknown targets")
+	}
+}
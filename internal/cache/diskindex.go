@@ -0,0 +1,380 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrNoDiskIndex indicates the binary sidecar index is missing, corrupt, or
+// was built from a different cache file.
+var ErrNoDiskIndex = errors.New("no usable disk index")
+
+// The disk index is a little-endian file of three sections:
+//
+//	header   magic, cache size, cache mtime (ns), banner count
+//	records  per banner, sorted: string offset, key length, sources
+//	         length, and the offset and length of its URL list in the
+//	         cache file
+//	strings  per banner: the key, then its sources as a JSON array
+//
+// Records have a fixed size, so exact and prefix queries binary search
+// them with a few reads, and substring queries stream the strings section
+// without touching the cache file until a banner matches.
+const (
+	diskIndexMagic      = "BASARIX1"
+	diskIndexHeaderSize = 8 + 8 + 8 + 8
+	diskIndexRecordSize = 8 + 4 + 4 + 8 + 4
+)
+
+// diskIndexRecord locates one banner in the index and cache files.
+type diskIndexRecord struct {
+	strOff         int64
+	keyLen, srcLen uint32
+	valOff         int64
+	valLen         uint32
+}
+
+// diskIndexPath returns the binary sidecar index next to the cache file.
+func (c *Cache) diskIndexPath() string {
+	return filepath.Join(c.cfg.CacheDir, "banners.idx")
+}
+
+// saveDiskIndex rebuilds the disk index for the cache file when enabled,
+// and otherwise removes any index left behind.
+func (c *Cache) saveDiskIndex(prov fetcher.Provenance) error {
+	if !c.cfg.DiskIndex {
+		if err := os.Remove(c.diskIndexPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeDiskIndex(c.diskIndexPath(), c.cfg.CacheFile, prov)
+}
+
+// writeDiskIndex indexes the cache file at cachePath into path, attributing
+// banners with prov. Only the keys are held in memory.
+func writeDiskIndex(path, cachePath string, prov fetcher.Provenance) error {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		banner         string
+		valOff, valLen int64
+	}
+	var entries []entry
+	err = fetcher.ScanBanners(f, func(banner string, offset, length int64) error {
+		entries = append(entries, entry{banner, offset, length})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scanning cache: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].banner < entries[j].banner })
+
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
+	if err != nil {
+		return fmt.Errorf("creating disk index: %w", err)
+	}
+	w := bufio.NewWriter(out)
+
+	header := make([]byte, diskIndexHeaderSize)
+	copy(header, diskIndexMagic)
+	binary.LittleEndian.PutUint64(header[8:], uint64(info.Size()))
+	binary.LittleEndian.PutUint64(header[16:], uint64(info.ModTime().UnixNano()))
+	binary.LittleEndian.PutUint64(header[24:], uint64(len(entries)))
+	_, _ = w.Write(header)
+
+	sources := make([][]byte, len(entries))
+	strOff := int64(diskIndexHeaderSize + diskIndexRecordSize*len(entries))
+	record := make([]byte, diskIndexRecordSize)
+	for i, e := range entries {
+		if src := prov[e.banner]; len(src) > 0 {
+			sources[i], _ = json.Marshal(src)
+		}
+		binary.LittleEndian.PutUint64(record[0:], uint64(strOff))
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(e.banner)))
+		binary.LittleEndian.PutUint32(record[12:], uint32(len(sources[i])))
+		binary.LittleEndian.PutUint64(record[16:], uint64(e.valOff))
+		binary.LittleEndian.PutUint32(record[24:], uint32(e.valLen))
+		_, _ = w.Write(record)
+		strOff += int64(len(e.banner) + len(sources[i]))
+	}
+	for i, e := range entries {
+		_, _ = w.WriteString(e.banner)
+		_, _ = w.Write(sources[i])
+	}
+
+	// bufio.Writer keeps the first write error, so checking Flush covers all
+	if err := w.Flush(); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing disk index: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("closing disk index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming disk index: %w", err)
+	}
+	return nil
+}
+
+// DiskIndex answers queries like Index from the binary sidecar index,
+// reading only the records and banners a query touches, so very large
+// caches are never loaded into memory. Close it when done.
+type DiskIndex struct {
+	idx, data *os.File
+	count     int
+}
+
+// OpenDiskIndex opens the cache's disk index, or returns ErrNoDiskIndex
+// when there is none or it was built from another version of the cache.
+func (c *Cache) OpenDiskIndex() (*DiskIndex, error) {
+	return openDiskIndex(c.diskIndexPath(), c.cfg.CacheFile)
+}
+
+// openDiskIndex opens the index at path for the cache file at cachePath.
+func openDiskIndex(path, cachePath string) (*DiskIndex, error) {
+	idx, err := os.Open(path)
+	if err != nil {
+		return nil, ErrNoDiskIndex
+	}
+	data, err := os.Open(cachePath)
+	if err != nil {
+		_ = idx.Close()
+		return nil, ErrNoDiskIndex
+	}
+	dx := &DiskIndex{idx: idx, data: data}
+
+	header := make([]byte, diskIndexHeaderSize)
+	info, err := data.Stat()
+	if err != nil || !dx.readAt(header, 0) || string(header[:8]) != diskIndexMagic ||
+		int64(binary.LittleEndian.Uint64(header[8:])) != info.Size() ||
+		int64(binary.LittleEndian.Uint64(header[16:])) != info.ModTime().UnixNano() {
+		_ = dx.Close()
+		return nil, ErrNoDiskIndex
+	}
+	dx.count = int(binary.LittleEndian.Uint64(header[24:]))
+	return dx, nil
+}
+
+// readAt fills buf from the index file at off, reporting success.
+func (dx *DiskIndex) readAt(buf []byte, off int64) bool {
+	_, err := dx.idx.ReadAt(buf, off)
+	return err == nil
+}
+
+// Close releases the index and cache files.
+func (dx *DiskIndex) Close() error {
+	return errors.Join(dx.idx.Close(), dx.data.Close())
+}
+
+// Len returns the number of indexed banners.
+func (dx *DiskIndex) Len() int {
+	return dx.count
+}
+
+// Lookup finds banners like Cache.Lookup: an exact match on its own,
+// otherwise every banner containing query, sorted.
+func (dx *DiskIndex) Lookup(query string) ([]Match, error) {
+	i, err := dx.search(query)
+	if err != nil {
+		return nil, err
+	}
+	if i < dx.count {
+		rec, key, err := dx.entry(i)
+		if err != nil {
+			return nil, err
+		}
+		if key == query {
+			m, err := dx.match(rec, key)
+			m.Exact = true
+			return []Match{m}, err
+		}
+	}
+
+	return dx.scan(func(key string) (bool, bool) {
+		return strings.Contains(key, query), false
+	})
+}
+
+// LookupWith finds banners like Cache.LookupWith.
+func (dx *DiskIndex) LookupWith(query string, opts LookupOptions) ([]Match, error) {
+	if opts == (LookupOptions{}) {
+		return dx.Lookup(query)
+	}
+	match, err := opts.matcher(query)
+	if err != nil {
+		return nil, err
+	}
+	return dx.scan(match)
+}
+
+// scan returns the exact matches of match if there are any and the partial
+// ones otherwise, in banner order.
+func (dx *DiskIndex) scan(match bannerMatcher) ([]Match, error) {
+	// Records and strings are both in banner order, so one sequential pass
+	// over each finds every match
+	tableSize := int64(dx.count) * diskIndexRecordSize
+	records := bufio.NewReader(io.NewSectionReader(dx.idx, diskIndexHeaderSize, tableSize))
+	strs := bufio.NewReader(io.NewSectionReader(dx.idx, diskIndexHeaderSize+tableSize, math.MaxInt64-diskIndexHeaderSize-tableSize))
+
+	var exact, partial []Match
+	buf := make([]byte, diskIndexRecordSize)
+	for i := 0; i < dx.count; i++ {
+		if _, err := io.ReadFull(records, buf); err != nil {
+			return nil, dx.corrupt(err)
+		}
+		rec := decodeDiskIndexRecord(buf)
+
+		str := make([]byte, rec.keyLen+rec.srcLen)
+		if _, err := io.ReadFull(strs, str); err != nil {
+			return nil, dx.corrupt(err)
+		}
+		key := string(str[:rec.keyLen])
+		ok, isExact := match(key)
+		if !ok || !isExact && len(exact) > 0 {
+			continue
+		}
+		m, err := dx.matchWith(rec, key, str[rec.keyLen:])
+		if err != nil {
+			return nil, err
+		}
+		if isExact {
+			m.Exact = true
+			exact = append(exact, m)
+		} else {
+			partial = append(partial, m)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, nil
+	}
+	return partial, nil
+}
+
+// Prefix returns the banners starting with prefix, sorted.
+func (dx *DiskIndex) Prefix(prefix string) ([]Match, error) {
+	i, err := dx.search(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for ; i < dx.count; i++ {
+		rec, key, err := dx.entry(i)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		m, err := dx.match(rec, key)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// search returns the position of the first banner not less than s.
+func (dx *DiskIndex) search(s string) (int, error) {
+	var err error
+	i := sort.Search(dx.count, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var key string
+		_, key, err = dx.entry(i)
+		return key >= s
+	})
+	return i, err
+}
+
+// entry reads the record and key of the banner at position i.
+func (dx *DiskIndex) entry(i int) (diskIndexRecord, string, error) {
+	buf := make([]byte, diskIndexRecordSize)
+	if !dx.readAt(buf, diskIndexHeaderSize+int64(i)*diskIndexRecordSize) {
+		return diskIndexRecord{}, "", dx.corrupt(nil)
+	}
+	rec := decodeDiskIndexRecord(buf)
+
+	key := make([]byte, rec.keyLen)
+	if !dx.readAt(key, rec.strOff) {
+		return diskIndexRecord{}, "", dx.corrupt(nil)
+	}
+	return rec, string(key), nil
+}
+
+// match builds the Match for rec, reading its sources from the index.
+func (dx *DiskIndex) match(rec diskIndexRecord, key string) (Match, error) {
+	src := make([]byte, rec.srcLen)
+	if !dx.readAt(src, rec.strOff+int64(rec.keyLen)) {
+		return Match{}, dx.corrupt(nil)
+	}
+	return dx.matchWith(rec, key, src)
+}
+
+// matchWith builds the Match for rec from its encoded sources, reading its
+// URLs from the cache file.
+func (dx *DiskIndex) matchWith(rec diskIndexRecord, key string, src []byte) (Match, error) {
+	m := Match{Banner: key}
+	if len(src) > 0 {
+		if err := json.Unmarshal(src, &m.Sources); err != nil {
+			return Match{}, dx.corrupt(err)
+		}
+	}
+
+	val := make([]byte, rec.valLen)
+	if _, err := dx.data.ReadAt(val, rec.valOff); err != nil {
+		return Match{}, dx.corrupt(err)
+	}
+	urls, err := fetcher.DecodeURLs(val)
+	if err != nil {
+		return Match{}, dx.corrupt(err)
+	}
+	m.URLs = urls
+	return m, nil
+}
+
+// corrupt wraps a read or decode failure as ErrNoDiskIndex.
+func (dx *DiskIndex) corrupt(err error) error {
+	if err == nil {
+		return fmt.Errorf("%w: %s is truncated", ErrNoDiskIndex, dx.idx.Name())
+	}
+	return fmt.Errorf("%w: %s: %v", ErrNoDiskIndex, dx.idx.Name(), err)
+}
+
+// decodeDiskIndexRecord decodes a record read from the index.
+func decodeDiskIndexRecord(buf []byte) diskIndexRecord {
+	return diskIndexRecord{
+		strOff: int64(binary.LittleEndian.Uint64(buf[0:])),
+		keyLen: binary.LittleEndian.Uint32(buf[8:]),
+		srcLen: binary.LittleEndian.Uint32(buf[12:]),
+		valOff: int64(binary.LittleEndian.Uint64(buf[16:])),
+		valLen: binary.LittleEndian.Uint32(buf[24:]),
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// writeDiskIndexCache writes a small cache with provenance and a disk
+// index.
+func writeDiskIndexCache(t *testing.T) *Cache {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.DiskIndex = true
+	c := New(cfg)
+
+	data := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0-91-generic": {"https://example.com/91.json", "https://mirror.example/91.json"},
+		"Linux version 5.15.0-92-generic": {"https://example.com/92.json"},
+		"Linux version 6.1.0-13-amd64":    {"https://example.com/13.json"},
+		"Linux version 5.15.0":            {"https://example.com/base.json"},
+	}}
+	if err := c.write(data); err != nil {
+		t.Fatal(err)
+	}
+	prov := fetcher.Provenance{"Linux version 6.1.0-13-amd64": {"debian"}}
+	if err := c.saveProvenance(prov); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.saveDiskIndex(prov); err != nil {
+		t.Fatalf("saveDiskIndex() failed: %v", err)
+	}
+	return c
+}
+
+// TestDiskIndexMatchesIndex checks the disk index answers like the
+// in-memory index.
+func TestDiskIndexMatchesIndex(t *testing.T) {
+	c := writeDiskIndexCache(t)
+	dx, err := c.OpenDiskIndex()
+	if err != nil {
+		t.Fatalf("OpenDiskIndex() failed: %v", err)
+	}
+	defer dx.Close()
+
+	ix, err := c.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dx.Len() != ix.Len() {
+		t.Errorf("Len() = %d, expected %d", dx.Len(), ix.Len())
+	}
+
+	for _, q := range []string{"Linux version 5.15.0", "Linux version 6.1.0-13-amd64", "generic", "-9", "amd", "a", "", "freebsd", "zzz"} {
+		got, err := dx.Lookup(q)
+		if err != nil {
+			t.Fatalf("Lookup(%q) failed: %v", q, err)
+		}
+		if want := ix.Lookup(q); !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup(%q) = %+v, expected %+v", q, got, want)
+		}
+	}
+
+	for _, p := range []string{"Linux version 5.15.0-", "Linux", "Linux version 7", "M"} {
+		got, err := dx.Prefix(p)
+		if err != nil {
+			t.Fatalf("Prefix(%q) failed: %v", p, err)
+		}
+		if want := ix.Prefix(p); !reflect.DeepEqual(got, want) {
+			t.Errorf("Prefix(%q) = %+v, expected %+v", p, got, want)
+		}
+	}
+}
+
+func TestDiskIndexStale(t *testing.T) {
+	c := writeDiskIndexCache(t)
+
+	// Replacing the cache without rebuilding the index invalidates it
+	raw, _ := json.Marshal(&fetcher.BannerData{Linux: map[string][]string{"only": {"u"}}})
+	if err := os.WriteFile(c.cfg.CacheFile, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	_ = os.Chtimes(c.cfg.CacheFile, later, later)
+
+	if _, err := c.OpenDiskIndex(); !errors.Is(err, ErrNoDiskIndex) {
+		t.Errorf("OpenDiskIndex() on a stale index = %v, expected ErrNoDiskIndex", err)
+	}
+	matches, err := c.Lookup("only")
+	if err != nil || len(matches) != 1 {
+		t.Errorf("Lookup() should fall back to the cache, got %v, %v", matches, err)
+	}
+}
+
+func TestSaveDiskIndexDisabled(t *testing.T) {
+	c := writeDiskIndexCache(t)
+	c.cfg.DiskIndex = false
+
+	if err := c.saveDiskIndex(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.diskIndexPath()); !os.IsNotExist(err) {
+		t.Error("disabling the disk index should remove it")
+	}
+}
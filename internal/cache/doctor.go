@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// Finding severities reported by Doctor.
+const (
+	FindingOK    = "ok"
+	FindingWarn  = "warn"
+	FindingError = "error"
+)
+
+// Finding is the outcome of one Doctor check.
+type Finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Fix suggests how to resolve a problem.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Doctor checks the installation: config readability, the proxy
+// auto-config file if any, source reachability, cache validity, cache directory writability, volatility3 wiring, lock
+// staleness, and the auto-update service. Findings are returned in that
+// order.
+func (c *Cache) Doctor(ctx context.Context) []Finding {
+	var findings []Finding
+	findings = append(findings, c.checkConfig())
+	findings = append(findings, c.checkProxy(ctx)...)
+	findings = append(findings, c.checkSources(ctx)...)
+	findings = append(findings, c.checkCache())
+	findings = append(findings, c.checkStorage())
+	findings = append(findings, c.checkVol3())
+	findings = append(findings, c.checkLock())
+	findings = append(findings, c.checkService())
+	return findings
+}
+
+// checkConfig verifies sources.conf can be read.
+func (c *Cache) checkConfig() Finding {
+	f, err := os.Open(c.cfg.ConfigFile)
+	if os.IsNotExist(err) && len(c.cfg.SourceFiles) == 0 {
+		return Finding{"config", FindingWarn,
+			fmt.Sprintf("%s not found; using %d default sources", c.cfg.ConfigFile, len(c.cfg.Sources)),
+			"run `basar --init` to create it"}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return Finding{"config", FindingError, err.Error(),
+			fmt.Sprintf("make %s readable", c.cfg.ConfigFile)}
+	}
+	if f != nil {
+		defer f.Close()
+	}
+
+	if len(c.cfg.SourceFiles) > 1 || err != nil {
+		return Finding{"config", FindingOK,
+			fmt.Sprintf("%d sources from %s", len(c.cfg.Sources), strings.Join(c.cfg.SourceFiles, ", ")), ""}
+	}
+	return Finding{"config", FindingOK,
+		fmt.Sprintf("%s (%d sources)", c.cfg.ConfigFile, len(c.cfg.Sources)), ""}
+}
+
+// checkProxy loads the proxy auto-config file, if one is configured, and
+// reports the proxy it picks for the first network source.
+func (c *Cache) checkProxy(ctx context.Context) []Finding {
+	if c.proxy == nil {
+		return nil
+	}
+	fix := fmt.Sprintf("check BASAR_PROXY_PAC or %s", c.cfg.ProxyFile)
+	script, err := c.proxy.Script(ctx)
+	if err != nil {
+		return []Finding{{"proxy", FindingError, fmt.Sprintf("PAC file %s: %v", c.proxy.Location(), err), fix}}
+	}
+
+	for _, source := range c.cfg.Sources {
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		result, err := script.FindProxyForURL(ctx, source, u.Hostname())
+		if err != nil {
+			return []Finding{{"proxy", FindingError, fmt.Sprintf("PAC file %s: %v", c.proxy.Location(), err), fix}}
+		}
+		return []Finding{{"proxy", FindingOK,
+			fmt.Sprintf("PAC file %s picks %q for %s", c.proxy.Location(), result, u.Host), ""}}
+	}
+	return []Finding{{"proxy", FindingOK, "PAC file " + c.proxy.Location() + " loaded", ""}}
+}
+
+// checkSources probes the configured sources, at most cfg.Jobs at a time.
+func (c *Cache) checkSources(ctx context.Context) []Finding {
+	findings := make([]Finding, len(c.cfg.Sources))
+	parallel(len(c.cfg.Sources), c.cfg.Jobs, func(i int) {
+		findings[i] = c.checkSource(ctx, c.cfg.Sources[i])
+	})
+	return findings
+}
+
+// checkSource probes a single source.
+func (c *Cache) checkSource(ctx context.Context, source string) Finding {
+	err := c.fetcher.Probe(ctx, source)
+	switch {
+	case err == nil:
+		return Finding{"source", FindingOK, source + ": reachable", ""}
+	case errors.Is(err, fetcher.ErrOffline):
+		return Finding{"source", FindingOK, source + ": not checked in offline mode", ""}
+	case errors.Is(err, fetcher.ErrConfiguration):
+		return Finding{"source", FindingError, fmt.Sprintf("%s: %v", source, err),
+			fmt.Sprintf("check the source's URL and token options in %s", c.cfg.ConfigFile)}
+	default:
+		return Finding{"source", FindingError, fmt.Sprintf("%s: %v", source, err),
+			fmt.Sprintf("check network access to the source, or remove it from %s", c.cfg.ConfigFile)}
+	}
+}
+
+// checkCache verifies the cache exists, parses, and is within its TTL.
+func (c *Cache) checkCache() Finding {
+	f, err := os.Open(c.cfg.CacheFile)
+	if os.IsNotExist(err) {
+		return Finding{"cache", FindingError, c.cfg.CacheFile + " does not exist",
+			"run `basar --update`"}
+	}
+	if err != nil {
+		return Finding{"cache", FindingError, err.Error(), "run `basar --update`"}
+	}
+	defer f.Close()
+
+	entries, err := fetcher.CountBanners(f)
+	if err != nil {
+		return Finding{"cache", FindingError,
+			fmt.Sprintf("%s is not valid JSON: %v", c.cfg.CacheFile, err),
+			"run `basar --update` to rebuild it"}
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return Finding{"cache", FindingError, err.Error(), "run `basar --update`"}
+	}
+	age := time.Since(info.ModTime()).Round(time.Second)
+	if age > c.cfg.TTL {
+		return Finding{"cache", FindingWarn,
+			fmt.Sprintf("%d banners, expired %s ago", entries, age-c.cfg.TTL),
+			"run `basar --smart-update`, or install auto-updates with `basar --install-service`"}
+	}
+
+	return Finding{"cache", FindingOK,
+		fmt.Sprintf("%d banners, updated %s ago", entries, age), ""}
+}
+
+// checkStorage verifies updates can write to the cache directory, or to
+// the fallback directory when it is read-only.
+func (c *Cache) checkStorage() Finding {
+	if c.readOnlyDir != "" {
+		return Finding{"storage", FindingOK,
+			fmt.Sprintf("%s is read-only; using the fallback %s", c.readOnlyDir, c.cfg.CacheDir), ""}
+	}
+
+	err := probeWritable(c.cfg.CacheDir)
+	switch {
+	case err == nil || os.IsNotExist(err):
+		return Finding{"storage", FindingOK, c.cfg.CacheDir + " is writable", ""}
+	case isReadOnlyFS(err) && c.cfg.FallbackCacheDir != "":
+		return Finding{"storage", FindingOK,
+			fmt.Sprintf("%s is read-only; updates go to the fallback %s", c.cfg.CacheDir, c.cfg.FallbackCacheDir), ""}
+	case isReadOnlyFS(err):
+		return Finding{"storage", FindingError, c.cfg.CacheDir + " is on a read-only filesystem",
+			"set BASAR_FALLBACK_CACHE_DIR to a writable directory, or to tmpfs"}
+	default:
+		return Finding{"storage", FindingWarn, err.Error(),
+			fmt.Sprintf("make %s writable", c.cfg.CacheDir)}
+	}
+}
+
+// checkVol3 verifies volatility3 is configured to use the cache, in one of
+// the configs basar would configure.
+func (c *Cache) checkVol3() Finding {
+	paths, err := vol3ConfigPaths()
+	if err != nil {
+		return Finding{"vol3", FindingError, err.Error(), ""}
+	}
+
+	var first Finding
+	for i, path := range paths {
+		f := c.checkVol3Config(path)
+		if f.Severity == FindingOK {
+			return f
+		}
+		if i == 0 {
+			first = f
+		}
+	}
+	return first
+}
+
+// checkVol3Config verifies a volatility3 config lists the cache in
+// remote_isf_url.
+func (c *Cache) checkVol3Config(path string) Finding {
+	want := fileURI(c.cfg.CacheFile)
+	got, err := vol3ISFURLs(path)
+	switch {
+	case os.IsNotExist(err):
+		return Finding{"vol3", FindingWarn, path + " not found",
+			"run `basar --configure-vol3`"}
+	case err != nil:
+		return Finding{"vol3", FindingError, err.Error(), ""}
+	case got.start < 0:
+		return Finding{"vol3", FindingWarn, path + " has no remote_isf_url",
+			"run `basar --configure-vol3`"}
+	case !got.has(want):
+		return Finding{"vol3", FindingWarn,
+			fmt.Sprintf("remote_isf_url in %s is %s, not the basar cache", path, strings.Join(got.values, ", ")),
+			"run `basar --configure-vol3` to add it, or `basar --configure-vol3 --replace`"}
+	}
+
+	return Finding{"vol3", FindingOK, "remote_isf_url points to the basar cache", ""}
+}
+
+// vol3ISFURLs reads the remote_isf_url setting of a volatility3 config.
+func vol3ISFURLs(path string) (isfURLs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return isfURLs{}, err
+	}
+	if _, err := parseVol3Config(string(data)); err != nil {
+		return isfURLs{}, fmt.Errorf("%s: %w", path, err)
+	}
+	urls, err := parseISFURLs(strings.Split(string(data), "\n"))
+	if err != nil {
+		return isfURLs{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return urls, nil
+}
+
+// checkLock reports a lock file left behind by a dead update.
+func (c *Cache) checkLock() Finding {
+	info, err := os.Stat(c.cfg.LockFile)
+	if os.IsNotExist(err) {
+		return Finding{"lock", FindingOK, "not held", ""}
+	}
+	if err != nil {
+		return Finding{"lock", FindingError, err.Error(), ""}
+	}
+
+	pid, _ := os.ReadFile(c.cfg.LockFile)
+	age := time.Since(info.ModTime()).Round(time.Second)
+	if age < LockTimeout {
+		return Finding{"lock", FindingOK,
+			fmt.Sprintf("held by pid %s for %s (update in progress)", strings.TrimSpace(string(pid)), age), ""}
+	}
+
+	return Finding{"lock", FindingWarn,
+		fmt.Sprintf("stale lock from pid %s, %s old", strings.TrimSpace(string(pid)), age),
+		fmt.Sprintf("the next update removes it; or delete %s", c.cfg.LockFile)}
+}
+
+// serviceFix returns the fix of a missing auto-update schedule, installing
+// it for c's instance.
+func (c *Cache) serviceFix() string {
+	return "run `" + commandLine("basar", append(c.cfg.Overrides.Args(), "--install-service")...) + "`"
+}
+
+// checkService verifies the auto-update schedule InstallService sets up
+// with this machine's scheduler is installed.
+func (c *Cache) checkService() Finding {
+	s, err := c.Scheduler()
+	if err != nil {
+		return Finding{"service", FindingOK, "auto-updates not supported on " + runtime.GOOS, ""}
+	}
+	return s.Check(c)
+}
+
+// checkCron verifies the crontab entry of c's instance is installed.
+func (c *Cache) checkCron() Finding {
+	marker := cronMarkerOf(c.serviceID())
+	out, _ := exec.Command("crontab", "-l").Output()
+	if !slices.ContainsFunc(strings.Split(string(out), "\n"), func(line string) bool {
+		return hasCronMarker(line, marker)
+	}) {
+		return Finding{"service", FindingWarn, "no basar crontab entry", c.serviceFix()}
+	}
+	return Finding{"service", FindingOK, "crontab entry installed", ""}
+}
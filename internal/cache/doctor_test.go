@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckConfig(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+
+	if f := c.checkConfig(); f.Severity != FindingWarn || f.Fix == "" {
+		t.Errorf("missing config should warn with a fix, got %+v", f)
+	}
+
+	cfg.SourceFiles = []string{"/etc/basar/sources.conf.d/team.conf"}
+	if f := c.checkConfig(); f.Severity != FindingOK || !strings.Contains(f.Message, "team.conf") {
+		t.Errorf("sources from drop-ins only should be ok, got %+v", f)
+	}
+	cfg.SourceFiles = nil
+
+	if err := os.WriteFile(cfg.ConfigFile, []byte("/tmp/x.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkConfig(); f.Severity != FindingOK {
+		t.Errorf("readable config should be ok, got %+v", f)
+	}
+}
+
+func TestCheckSources(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer denied.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("probe should use HEAD, got %s", r.Method)
+		}
+	}))
+	defer ok.Close()
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{ok.URL, denied.URL, filepath.Join(cfg.ConfigDir, "missing.json")}
+
+	findings := New(cfg).checkSources(context.Background())
+	want := []string{FindingOK, FindingError, FindingError}
+	for i, f := range findings {
+		if f.Severity != want[i] {
+			t.Errorf("source %d: severity = %s, expected %s (%+v)", i, f.Severity, want[i], f)
+		}
+	}
+	if !strings.Contains(findings[1].Fix, "token") {
+		t.Errorf("denied source should point at token options, got %q", findings[1].Fix)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+
+	if f := c.checkCache(); f.Severity != FindingError {
+		t.Errorf("missing cache should be an error, got %+v", f)
+	}
+
+	createTestBannerFile(t, cfg.CacheFile)
+	if f := c.checkCache(); f.Severity != FindingOK || !strings.Contains(f.Message, "2 banners") {
+		t.Errorf("fresh cache should be ok, got %+v", f)
+	}
+
+	old := time.Now().Add(-2 * cfg.TTL)
+	if err := os.Chtimes(cfg.CacheFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkCache(); f.Severity != FindingWarn {
+		t.Errorf("expired cache should warn, got %+v", f)
+	}
+
+	if err := os.WriteFile(cfg.CacheFile, []byte("{truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkCache(); f.Severity != FindingError || !strings.Contains(f.Message, "not valid JSON") {
+		t.Errorf("corrupt cache should be an error, got %+v", f)
+	}
+}
+
+func TestCheckVol3(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	cfg := testConfig(t)
+	c := New(cfg)
+	vol3Config := filepath.Join(home, ".volatility3.yaml")
+
+	if f := c.checkVol3(); f.Severity != FindingWarn {
+		t.Errorf("missing vol3 config should warn, got %+v", f)
+	}
+
+	if err := os.WriteFile(vol3Config, []byte("remote_isf_url: file:///elsewhere.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkVol3(); f.Severity != FindingWarn || !strings.Contains(f.Message, "elsewhere") {
+		t.Errorf("foreign remote_isf_url should warn, got %+v", f)
+	}
+
+	if err := os.Remove(vol3Config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ConfigureVolatility3(false); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkVol3(); f.Severity != FindingOK {
+		t.Errorf("configured vol3 should be ok, got %+v", f)
+	}
+}
+
+func TestCheckLock(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+
+	if f := c.checkLock(); f.Severity != FindingOK {
+		t.Errorf("no lock should be ok, got %+v", f)
+	}
+
+	if err := c.acquireLock(); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkLock(); f.Severity != FindingOK || !strings.Contains(f.Message, "in progress") {
+		t.Errorf("fresh lock should be ok, got %+v", f)
+	}
+
+	old := time.Now().Add(-2 * LockTimeout)
+	if err := os.Chtimes(cfg.LockFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.checkLock(); f.Severity != FindingWarn || !strings.Contains(f.Message, "stale") {
+		t.Errorf("stale lock should warn, got %+v", f)
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	cfg := testConfig(t)
+	if findings := New(cfg).checkProxy(context.Background()); findings != nil {
+		t.Errorf("checkProxy() without a PAC file = %+v", findings)
+	}
+
+	cfg.ProxyPAC = filepath.Join(cfg.ConfigDir, "proxy.pac")
+	cfg.Sources = []string{"/srv/isf/banners.json", "https://isf.example.com/banners.json"}
+	if f := New(cfg).checkProxy(context.Background()); len(f) != 1 || f[0].Severity != FindingError || f[0].Fix == "" {
+		t.Errorf("checkProxy() of a missing PAC file = %+v, expected an error", f)
+	}
+
+	pac := `function FindProxyForURL(url, host) { return "PROXY proxy.example.com:3128"; }`
+	if err := os.WriteFile(cfg.ProxyPAC, []byte(pac), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := New(cfg).checkProxy(context.Background())
+	if len(f) != 1 || f[0].Severity != FindingOK || !strings.Contains(f[0].Message, `"PROXY proxy.example.com:3128" for isf.example.com`) {
+		t.Errorf("checkProxy() = %+v", f)
+	}
+}
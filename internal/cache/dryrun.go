@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/hooks"
+)
+
+// A Plan is what a mutating operation would do, as --dry-run shows it
+// without changing anything: the sources it would fetch, the files it
+// would write or remove, and the commands it would run. Notes qualify it,
+// e.g. when files are only written if a source changed.
+type Plan struct {
+	Fetch  []string `json:"fetch,omitempty"`
+	Write  []string `json:"write,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+	Run    []string `json:"run,omitempty"`
+	Notes  []string `json:"notes,omitempty"`
+}
+
+// Empty reports whether the plan changes nothing.
+func (p *Plan) Empty() bool {
+	return len(p.Fetch) == 0 && len(p.Write) == 0 && len(p.Remove) == 0 && len(p.Run) == 0
+}
+
+// add appends what q would do to p.
+func (p *Plan) add(q *Plan) {
+	p.Fetch = append(p.Fetch, q.Fetch...)
+	p.Write = append(p.Write, q.Write...)
+	p.Remove = append(p.Remove, q.Remove...)
+	p.Run = append(p.Run, q.Run...)
+	p.Notes = append(p.Notes, q.Notes...)
+}
+
+// hook adds hook name from the hooks directory dir to the commands p runs,
+// if there is one.
+func (p *Plan) hook(dir, name string) {
+	if dir == "" {
+		return
+	}
+	if path, ok := hooks.Path(dir, name); ok {
+		p.Run = append(p.Run, path)
+	}
+}
+
+// PlanUpdate describes what a forced Update would do.
+func (c *Cache) PlanUpdate() (*Plan, error) {
+	return c.planUpdate(c.cfg.Only)
+}
+
+// PlanSmartUpdate describes what SmartUpdate would do if a source changed.
+func (c *Cache) PlanSmartUpdate() (*Plan, error) {
+	p, err := c.planUpdate(c.cfg.Only)
+	if err != nil {
+		return nil, err
+	}
+	p.Notes = append(p.Notes, "sources are fetched with conditional requests; the cache is only rewritten if one changed")
+	return p, nil
+}
+
+// PlanRefreshSource describes what RefreshSource would do.
+func (c *Cache) PlanRefreshSource(source string) (*Plan, error) {
+	if !slices.Contains(c.cfg.Sources, source) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+	return c.planUpdate([]string{source})
+}
+
+// planUpdate describes an update fetching the sources matching selectors:
+// their snapshots, the cache and its sidecars, source metadata, and the
+// update history are written, between the update hooks.
+func (c *Cache) planUpdate(selectors []string) (*Plan, error) {
+	fetch, _, err := c.selectSources(selectors)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{Fetch: fetch}
+	p.hook(c.cfg.HooksDir, hooks.PreUpdate)
+	for _, source := range fetch {
+		p.Write = append(p.Write, c.snapshotPath(source))
+	}
+	p.Write = append(p.Write, c.cfg.CacheFile, c.provenancePath(), c.metadataPath(), c.urlTrustPath(), c.tombstonesPath())
+	if c.cfg.DiskIndex {
+		p.Write = append(p.Write, c.diskIndexPath())
+	} else if _, err := os.Stat(c.diskIndexPath()); err == nil {
+		p.Remove = append(p.Remove, c.diskIndexPath())
+	}
+	p.Write = append(p.Write, c.cfg.MetaFile, c.cfg.HistoryFile)
+	p.hook(c.cfg.HooksDir, hooks.PostUpdate)
+	return p, nil
+}
+
+// PlanConfigureVolatility3 describes what ConfigureVolatility3 would do:
+// the configs it would write. Like it, it returns the plan with the errors
+// of the configs it would leave unchanged.
+func (c *Cache) PlanConfigureVolatility3(replace bool) (*Plan, error) {
+	report, err := c.configureVol3(replace, false)
+	if errors.Is(err, ErrVol3AlreadyConfigured) {
+		return &Plan{Notes: []string{"volatility3 already configured"}}, nil
+	}
+	if report == nil {
+		return nil, err
+	}
+	p := &Plan{}
+	for _, config := range report.Configs {
+		if config.Changed {
+			p.Write = append(p.Write, config.Path)
+		}
+	}
+	return p, err
+}
+
+// PlanInstallService describes what InstallService would do.
+func (c *Cache) PlanInstallService() (*Plan, error) {
+	s, err := c.Scheduler()
+	if err != nil {
+		return nil, err
+	}
+	return s.Plan(c)
+}
+
+// PlanClear describes what ClearTarget(target) would do: the files and
+// directories it would remove, of those that exist.
+func (c *Cache) PlanClear(target string) (*Plan, error) {
+	paths, err := c.clearPaths(target)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			p.Remove = append(p.Remove, path)
+		}
+	}
+	return p, nil
+}
+
+// PlanSetup describes what Setup would do. Its steps are planned against
+// the current state, and those Setup only warns about when they fail are
+// noted rather than failing the plan.
+func (c *Cache) PlanSetup() (*Plan, error) {
+	p := &Plan{}
+	if _, err := os.Stat(c.cfg.ConfigFile); err != nil {
+		p.Write = append(p.Write, c.cfg.ConfigFile)
+	}
+
+	update, err := c.PlanUpdate()
+	if err != nil {
+		return nil, fmt.Errorf("updating cache: %w", err)
+	}
+	p.add(update)
+
+	vol3, err := c.PlanConfigureVolatility3(false)
+	if vol3 != nil {
+		p.add(vol3)
+	}
+	if err != nil {
+		p.Notes = append(p.Notes, "configuring volatility3 would fail: "+err.Error())
+	}
+
+	if s, err := c.Scheduler(); err == nil {
+		service, err := s.Plan(c)
+		if err != nil {
+			p.Notes = append(p.Notes, "service install would fail: "+err.Error())
+		} else {
+			p.add(service)
+		}
+	}
+	return p, nil
+}
+
+// commandLine formats a command and its arguments as a shell would take
+// them, quoting the arguments that need it.
+func commandLine(name string, args ...string) string {
+	words := []string{name}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\$") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestPlanUpdate(t *testing.T) {
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	cfg.Sources = []string{"https://example.com/banners.json", local}
+	c := New(cfg)
+
+	p, err := c.PlanUpdate()
+	if err != nil {
+		t.Fatalf("PlanUpdate() failed: %v", err)
+	}
+	if !slices.Equal(p.Fetch, cfg.Sources) {
+		t.Errorf("fetch = %v, expected %v", p.Fetch, cfg.Sources)
+	}
+	for _, path := range []string{cfg.CacheFile, cfg.MetaFile, cfg.HistoryFile, c.provenancePath(), c.snapshotPath(local)} {
+		if !slices.Contains(p.Write, path) {
+			t.Errorf("write = %v, lacks %s", p.Write, path)
+		}
+	}
+	if len(p.Remove) != 0 || len(p.Run) != 0 {
+		t.Errorf("plan = %+v, expected nothing removed or run", p)
+	}
+	if entries, _ := os.ReadDir(cfg.CacheDir); len(entries) != 0 {
+		t.Errorf("PlanUpdate() wrote %d files", len(entries))
+	}
+
+	cfg.Offline = true
+	if p, err := c.PlanUpdate(); err != nil || !slices.Equal(p.Fetch, []string{local}) {
+		t.Errorf("PlanUpdate() offline = %+v, %v; expected only the local source", p, err)
+	}
+	if _, err := c.PlanRefreshSource("https://example.com/other.json"); err == nil {
+		t.Error("PlanRefreshSource() of an unknown source succeeded")
+	}
+}
+
+func TestPlanUpdateHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell hooks are not supported on windows")
+	}
+	cfg := testConfig(t)
+	cfg.Sources = []string{filepath.Join(cfg.ConfigDir, "local.json")}
+	cfg.HooksDir = t.TempDir()
+	hook := filepath.Join(cfg.HooksDir, "post-update")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(cfg).PlanSmartUpdate()
+	if err != nil {
+		t.Fatalf("PlanSmartUpdate() failed: %v", err)
+	}
+	if !slices.Equal(p.Run, []string{hook}) {
+		t.Errorf("run = %v, expected the post-update hook", p.Run)
+	}
+	if len(p.Notes) == 0 {
+		t.Error("PlanSmartUpdate() should note the cache is only rewritten on changes")
+	}
+}
+
+func TestPlanClear(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+	createTestBannerFile(t, cfg.CacheFile)
+	if err := os.WriteFile(cfg.HistoryFile, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.PlanClear(ClearCache)
+	if err != nil || !slices.Equal(p.Remove, []string{cfg.CacheFile}) {
+		t.Errorf("PlanClear(cache) = %+v, %v; expected the cache file only", p, err)
+	}
+	p, err = c.PlanClear(ClearAllTarget)
+	if err != nil || !slices.Equal(p.Remove, []string{cfg.CacheFile, cfg.HistoryFile}) {
+		t.Errorf("PlanClear(all) = %+v, %v", p, err)
+	}
+	if _, err := os.Stat(cfg.CacheFile); err != nil {
+		t.Error("PlanClear() removed the cache")
+	}
+	if _, err := c.PlanClear("everything"); err == nil {
+		t.Error("PlanClear() of an unknown target succeeded")
+	}
+}
+
+func TestPlanConfigureVolatility3(t *testing.T) {
+	cfg := testConfig(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("VIRTUAL_ENV", "")
+	c := New(cfg)
+
+	vol3Config := filepath.Join(home, ".volatility3.yaml")
+	p, err := c.PlanConfigureVolatility3(false)
+	if err != nil || !slices.Equal(p.Write, []string{vol3Config}) {
+		t.Fatalf("PlanConfigureVolatility3() = %+v, %v; expected %s written", p, err, vol3Config)
+	}
+	if _, err := os.Stat(vol3Config); err == nil {
+		t.Fatal("PlanConfigureVolatility3() wrote the config")
+	}
+
+	if _, err := c.ConfigureVolatility3(false); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := c.PlanConfigureVolatility3(false); err != nil || !p.Empty() || len(p.Notes) == 0 {
+		t.Errorf("PlanConfigureVolatility3() once configured = %+v, %v; expected nothing to do", p, err)
+	}
+}
+
+func TestSchedulerPlans(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	c := New(testConfig(t))
+
+	p, err := systemdScheduler{}.Plan(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := filepath.Join(home, ".config", "systemd", "user", "basar.timer")
+	if !slices.Contains(p.Write, unit) || len(p.Run) != len(systemdCommands("")) {
+		t.Errorf("systemd plan = %+v", p)
+	}
+	if p, err := (launchdScheduler{}).Plan(c); err != nil || !slices.Equal(p.Write, []string{launchdPlistPath(home, "")}) {
+		t.Errorf("launchd plan = %+v, %v", p, err)
+	}
+	if p, err := (daemonScheduler{}).Plan(c); err != nil || !p.Empty() {
+		t.Errorf("daemon plan = %+v, %v; expected nothing", p, err)
+	}
+	if entries, _ := os.ReadDir(home); len(entries) != 0 {
+		t.Errorf("scheduler plans wrote %d files", len(entries))
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	got := commandLine("schtasks", "/TR", `"C:\basar.exe" --smart-update`, "/F")
+	expected := `schtasks /TR '"C:\basar.exe" --smart-update' /F`
+	if got != expected {
+		t.Errorf("commandLine() = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Export formats accepted by Export.
+const (
+	ExportHTML   = "html"
+	ExportBundle = "bundle"
+)
+
+// ExportFormats lists the formats Export can produce.
+var ExportFormats = []string{ExportHTML, ExportBundle}
+
+// exportPage is the data rendered by the HTML export.
+type exportPage struct {
+	Generated  time.Time
+	UpdatedAt  time.Time
+	Generation uint64
+	Sources    []SourceStats
+	Banners    []Match
+}
+
+// Export writes the cache in format: as a human-facing standalone page
+// listing every banner with its symbol URLs and sources, the sources with
+// their last fetch, and when the cache was last updated; or as a bundle
+// for ImportBundle (see ExportBundle).
+func (c *Cache) Export(w io.Writer, format string) error {
+	if format == ExportBundle {
+		return c.ExportBundle(w)
+	}
+	if format != ExportHTML {
+		return fmt.Errorf("unknown export format %q (expected one of %v)", format, ExportFormats)
+	}
+
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return ErrNoCache
+	}
+
+	prov := c.loadProvenance()
+	meta := c.loadMeta()
+
+	page := exportPage{
+		Generated:  time.Now(),
+		Generation: meta.Generation,
+		Sources:    c.sourceStats(meta),
+		Banners:    make([]Match, 0, len(banners.Linux)),
+	}
+	if info, err := os.Stat(c.cfg.CacheFile); err == nil {
+		page.UpdatedAt = info.ModTime()
+	}
+	for banner, urls := range banners.Linux {
+		page.Banners = append(page.Banners, Match{Banner: banner, URLs: urls, Sources: prov[banner]})
+	}
+	sort.Slice(page.Banners, func(i, j int) bool {
+		return page.Banners[i].Banner < page.Banners[j].Banner
+	})
+
+	return exportTemplate.Execute(w, page)
+}
+
+// exportTemplate is self-contained so the page can be dropped on any web
+// server; the search box filters rows client-side.
+var exportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>basar symbol coverage</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.banner, td.urls { font-family: ui-monospace, monospace; font-size: .9em; word-break: break-all; }
+.error { color: #b00; }
+#search { width: 100%; padding: .5em; font-size: 1em; margin-bottom: 1em; box-sizing: border-box; }
+</style>
+</head>
+<body>
+<h1>basar symbol coverage</h1>
+<p>{{len .Banners}} banners &middot; cache updated {{date .UpdatedAt}} &middot; generation {{.Generation}} &middot; exported {{date .Generated}}</p>
+
+<h2>Sources</h2>
+<table>
+<thead><tr><th>Source</th><th>Status</th><th>Banners</th><th>Last fetch</th><th>Last change</th></tr></thead>
+<tbody>
+{{- range .Sources}}
+<tr><td>{{.Source}}{{if .Required}} (required){{end}}</td><td{{if .Error}} class="error" title="{{.Error}}"{{end}}>{{.Status}}</td><td>{{.Entries}}</td><td>{{date .LastFetch}}</td><td>{{date .LastChange}}</td></tr>
+{{- else}}
+<tr><td colspan="5">No sources fetched yet.</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Banners</h2>
+<input id="search" type="search" placeholder="Filter banners, URLs, or sources" autofocus>
+<table id="banners">
+<thead><tr><th>Banner</th><th>Symbol URLs</th><th>Sources</th></tr></thead>
+<tbody>
+{{- range .Banners}}
+<tr><td class="banner">{{.Banner}}</td><td class="urls">{{range .URLs}}<a href="{{.}}">{{.}}</a><br>{{end}}</td><td>{{range .Sources}}{{.}}<br>{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<script>
+document.getElementById("search").addEventListener("input", function () {
+  var q = this.value.toLowerCase();
+  document.querySelectorAll("#banners tbody tr").forEach(function (row) {
+    row.hidden = q !== "" && row.textContent.toLowerCase().indexOf(q) < 0;
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestExportHTML(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Export(&buf, ExportHTML); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	page := buf.String()
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"Linux version 5.15.0-generic",
+		`<a href="https://example.com/symbols/5.15.0.json">`,
+		sourceFile,
+		`id="search"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("export should contain %q", want)
+		}
+	}
+}
+
+func TestExportEscapes(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+	if err := c.write(&fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"<script>alert(1)</script>": {"javascript:alert(1)"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Export(&buf, ExportHTML); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "<script>alert") || strings.Contains(buf.String(), `href="javascript:`) {
+		t.Errorf("banners and URLs should be escaped:\n%s", buf.String())
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	c := New(testConfig(t))
+	if err := c.Export(&bytes.Buffer{}, ExportHTML); !errors.Is(err, ErrNoCache) {
+		t.Errorf("Export() without a cache = %v, expected ErrNoCache", err)
+	}
+	if err := c.Export(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Error("Export() should reject unknown formats")
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrNoFilters indicates filtering without any filter to apply.
+var ErrNoFilters = errors.New("no filters configured")
+
+// Filtered returns the cache filtered with bf and uf, leaving it unchanged.
+func (c *Cache) Filtered(bf *config.BannerFilter, uf *config.URLFilter) (*fetcher.BannerData, *fetcher.FilterCounts, error) {
+	if bf.Empty() && uf == nil {
+		return nil, nil, ErrNoFilters
+	}
+	data := c.loadExistingBanners()
+	if data == nil {
+		return nil, nil, ErrNoCache
+	}
+	return data, c.filter(data, fetcher.Provenance{}, c.loadURLTrust(), bf, uf), nil
+}
+
+// ApplyFilters filters the cache in place with bf and uf, pruning its
+// provenance, metadata, and tombstones along, and rebuilding an existing
+// disk index. The cache keeps its write time, so its age and TTL carry
+// over; the next update applies the configured filters again.
+func (c *Cache) ApplyFilters(bf *config.BannerFilter, uf *config.URLFilter) (*fetcher.FilterCounts, error) {
+	if bf.Empty() && uf == nil {
+		return nil, ErrNoFilters
+	}
+	if err := c.lockForUpdate(); err != nil {
+		return nil, err
+	}
+	defer c.releaseLock()
+
+	info, err := os.Stat(c.cfg.CacheFile)
+	data := c.loadExistingBanners()
+	if err != nil || data == nil {
+		return nil, ErrNoCache
+	}
+	prov := c.loadProvenance()
+	counts := c.filter(data, prov, c.loadURLTrust(), bf, uf)
+	if *counts == (fetcher.FilterCounts{}) {
+		return counts, nil
+	}
+
+	if err := c.rewriteInPlace(data, prov, info.ModTime()); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// rewriteInPlace replaces the cache with data, a pruned copy of it, keeping
+// its write time modTime. It prunes the metadata and tombstones of the
+// banners data dropped, saves prov if set, rebuilds an existing disk index,
+// and bumps the generation. The caller holds the update lock.
+func (c *Cache) rewriteInPlace(data *fetcher.BannerData, prov fetcher.Provenance, modTime time.Time) error {
+	if err := c.write(data); err != nil {
+		return err
+	}
+	_ = os.Chtimes(c.cfg.CacheFile, time.Time{}, modTime)
+	if prov != nil {
+		if err := c.saveProvenance(prov); err != nil {
+			return err
+		}
+	}
+	if md := c.loadMetadata(); md != nil {
+		for banner := range md {
+			if _, ok := data.Linux[banner]; !ok {
+				delete(md, banner)
+			}
+		}
+		if err := c.saveMetadata(md); err != nil {
+			c.log.Warn("saving metadata sidecar failed", "error", err)
+		}
+	}
+	if tombs := c.loadTombstones(); tombs != nil {
+		for banner := range tombs {
+			if _, ok := data.Linux[banner]; !ok {
+				delete(tombs, banner)
+			}
+		}
+		if err := c.saveTombstones(tombs); err != nil {
+			c.log.Warn("saving tombstones failed", "error", err)
+		}
+	}
+	if _, err := os.Stat(c.diskIndexPath()); err == nil || c.cfg.DiskIndex {
+		if err := writeDiskIndex(c.diskIndexPath(), c.cfg.CacheFile, prov); err != nil {
+			c.log.Warn("saving disk index failed", "error", err)
+		}
+	}
+
+	meta := c.loadMeta()
+	meta.Generation++
+	if err := c.stampMeta(meta); err != nil {
+		c.log.Warn("reading cache for metadata failed", "error", err)
+	}
+	if err := c.saveMeta(meta); err != nil {
+		c.log.Warn("saving metadata failed", "error", err)
+	}
+	return nil
+}
+
+// filter drops the banners bf rejects and the symbol URLs uf rejects from
+// data, by pattern or by their level in trust, then the banners left
+// without a URL, pruning prov along. It returns what it removed, nil when
+// neither filter is set.
+func (c *Cache) filter(data *fetcher.BannerData, prov fetcher.Provenance, trust map[string]string, bf *config.BannerFilter, uf *config.URLFilter) *fetcher.FilterCounts {
+	if bf.Empty() && uf == nil {
+		return nil
+	}
+
+	counts := &fetcher.FilterCounts{}
+	for banner, urls := range data.Linux {
+		if !bf.Keeps(banner) {
+			delete(data.Linux, banner)
+			delete(prov, banner)
+			counts.Excluded++
+			continue
+		}
+
+		kept := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if uf.Allows(u) && uf.AllowsTrust(trustOf(trust, u)) {
+				kept = append(kept, u)
+			} else {
+				counts.URLs++
+			}
+		}
+		switch {
+		case len(kept) == 0:
+			delete(data.Linux, banner)
+			delete(prov, banner)
+			counts.Banners++
+		case len(kept) < len(urls):
+			data.Linux[banner] = kept
+		}
+	}
+
+	c.log.Info("filtered banners and URLs", "excluded", counts.Excluded, "urls", counts.URLs, "banners", counts.Banners)
+	return counts
+}
+
+// filtersChecksum returns the SHA-256 of the URL and banner filters files
+// in hex, empty when neither exists.
+func (c *Cache) filtersChecksum() string {
+	h := sha256.New()
+	found := false
+	for _, path := range []string{c.cfg.URLFilterFile, c.cfg.BannerFilterFile} {
+		raw, err := os.ReadFile(path)
+		if err == nil {
+			found = true
+		}
+		h.Write(raw)
+		h.Write([]byte{0})
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestUpdateFiltersURLs(t *testing.T) {
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	data := `{"version":1,"linux":{
+		"banner1":["https://isf.example.org/b1.json.xz","https://broken.example.org/b1.json.xz"],
+		"banner2":["https://elsewhere.example/b2.json.xz"],
+		"banner3":["https://isf.example.org/b3.json.xz"]}}`
+	if err := os.WriteFile(source, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{source}
+	cfg.ShrinkThreshold = 0
+
+	cfg.URLFilter = &config.URLFilter{
+		Allow: []*regexp.Regexp{regexp.MustCompile(`^https://[^/]+\.example\.org/`)},
+		Deny:  []*regexp.Regexp{regexp.MustCompile(`^https://broken\.example\.org/`)},
+	}
+
+	c := New(cfg)
+	res, err := c.Update(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Filtered == nil || res.Filtered.URLs != 2 || res.Filtered.Banners != 1 {
+		t.Errorf("Filtered = %+v, expected 2 URLs and 1 banner", res.Filtered)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 ||
+		!reflect.DeepEqual(matches[0].URLs, []string{"https://isf.example.org/b1.json.xz"}) {
+		t.Errorf("Lookup(banner1) = %+v", matches)
+	}
+	if matches, _ := c.Lookup("banner2"); len(matches) != 0 {
+		t.Errorf("Lookup(banner2) = %+v, expected the banner filtered out", matches)
+	}
+	if stats := c.Stats(); stats.Filtered == nil || stats.Filtered.URLs != 2 {
+		t.Errorf("Stats().Filtered = %+v", stats.Filtered)
+	}
+}
+
+func TestUpdateRedirectsURLs(t *testing.T) {
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	data := `{"version":1,"linux":{
+		"banner1":["http://old.example.org/b1.json.xz","https://isf.example.org/b1.json.xz"]}}`
+	if err := os.WriteFile(source, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{source}
+	cfg.URLRedirects = map[string]string{"old.example.org": "isf.example.org"}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 ||
+		!reflect.DeepEqual(matches[0].URLs, []string{"https://isf.example.org/b1.json.xz"}) {
+		t.Errorf("Lookup(banner1) = %+v, expected the redirected URL once, over https", matches)
+	}
+}
+
+func TestApplyFilters(t *testing.T) {
+	cfg := testConfig(t)
+	createTestBannerFile(t, cfg.CacheFile)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(cfg.CacheFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	c := New(cfg)
+
+	bf := &config.BannerFilter{}
+	if err := bf.Add("min-kernel", "6"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Filtered leaves the cache alone
+	data, counts, err := c.Filtered(bf, nil)
+	if err != nil || len(data.Linux) != 1 || counts.Excluded != 1 {
+		t.Fatalf("Filtered() = %v, %+v, %v; expected one banner left", data, counts, err)
+	}
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Errorf("Filtered() changed the cache to %d entries", stats.Entries)
+	}
+
+	counts, err = c.ApplyFilters(bf, nil)
+	if err != nil || counts.Excluded != 1 {
+		t.Fatalf("ApplyFilters() = %+v, %v", counts, err)
+	}
+	if matches, _ := c.Lookup("Linux version"); len(matches) != 1 || matches[0].Banner != "Linux version 6.1.0-generic" {
+		t.Errorf("Lookup() after ApplyFilters = %+v", matches)
+	}
+	if info, err := os.Stat(cfg.CacheFile); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("ApplyFilters() did not keep the cache's write time: %v", err)
+	}
+
+	if _, err := c.ApplyFilters(nil, nil); !errors.Is(err, ErrNoFilters) {
+		t.Errorf("ApplyFilters(nil, nil) error = %v, expected ErrNoFilters", err)
+	}
+}
+
+func TestFiltersChecksum(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.URLFilterFile = filepath.Join(cfg.ConfigDir, "url-filters.conf")
+	cfg.BannerFilterFile = filepath.Join(cfg.ConfigDir, "banner-filters.conf")
+	c := New(cfg)
+	if sum := c.filtersChecksum(); sum != "" {
+		t.Errorf("filtersChecksum() without files = %q", sum)
+	}
+
+	if err := os.WriteFile(cfg.BannerFilterFile, []byte("include ubuntu\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	banner := c.filtersChecksum()
+	if err := os.Rename(cfg.BannerFilterFile, cfg.URLFilterFile); err != nil {
+		t.Fatal(err)
+	}
+	if url := c.filtersChecksum(); banner == "" || url == "" || banner == url {
+		t.Errorf("filtersChecksum() = %q and %q, expected distinct sums", banner, url)
+	}
+}
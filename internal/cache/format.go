@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Format versions of basar's own state files, stamped into each JSON
+// object as its first field, "format". An object without one predates
+// stamping and is format 0. Changing what a file holds bumps its version
+// and adds the migration from the previous one to formatMigrations, so
+// files are upgraded when read and rewritten in place by the next update,
+// while older binaries refuse the new format instead of misreading it.
+const (
+	MetaFormat     = 1
+	HistoryFormat  = 1
+	SnapshotFormat = 1
+)
+
+// ErrNewerFormat indicates a state file or manifest written by a newer
+// basar, in a format this one cannot read.
+var ErrNewerFormat = errors.New("written by a newer basar")
+
+// A migration upgrades the fields of a JSON object from one format to the
+// next.
+type migration func(fields map[string]json.RawMessage) error
+
+// stateFormat describes one kind of state file.
+type stateFormat struct {
+	name    string
+	current int
+	// migrations[v] upgrades format v to v+1. A nil one only restamps.
+	migrations []migration
+}
+
+var (
+	metaFormat     = stateFormat{name: "meta.json", current: MetaFormat, migrations: []migration{nil}}
+	historyFormat  = stateFormat{name: "history.jsonl", current: HistoryFormat, migrations: []migration{nil}}
+	snapshotFormat = stateFormat{name: "snapshot", current: SnapshotFormat, migrations: []migration{nil}}
+)
+
+// newerFormat is the error for a file named name in format version when
+// this basar reads up to current.
+func newerFormat(name string, version, current int) error {
+	return fmt.Errorf("%s is format %d, %w (this one reads up to %d)", name, version, ErrNewerFormat, current)
+}
+
+// formatOf returns the format stamped in the JSON object raw, 0 if it has
+// none or is not an object.
+func formatOf(raw []byte) int {
+	var stamped struct {
+		Format int `json:"format"`
+	}
+	if json.Unmarshal(raw, &stamped) != nil {
+		return 0
+	}
+	return stamped.Format
+}
+
+// upgrade returns the JSON object raw in the current format, stamped with
+// it, and whether it had to be migrated. A newer format is ErrNewerFormat.
+func (f stateFormat) upgrade(raw []byte) ([]byte, bool, error) {
+	version := formatOf(raw)
+	switch {
+	case version == f.current:
+		return raw, false, nil
+	case version > f.current:
+		return nil, false, newerFormat(f.name, version, f.current)
+	case version < 0:
+		return nil, false, fmt.Errorf("%s: invalid format %d", f.name, version)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", f.name, err)
+	}
+	for ; version < f.current; version++ {
+		if m := f.migrations[version]; m != nil {
+			if err := m(fields); err != nil {
+				return nil, false, fmt.Errorf("%s: migrating format %d: %w", f.name, version, err)
+			}
+		}
+	}
+	delete(fields, "format")
+	out, err := marshalFields(fields)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", f.name, err)
+	}
+	return stampFormat(out, f.current), true, nil
+}
+
+// stampFormat returns the encoded JSON object raw with version as its
+// first field. raw must not have a format field already.
+func stampFormat(raw []byte, version int) []byte {
+	rest := bytes.TrimLeft(raw, " \t\r\n")
+	if len(rest) == 0 || rest[0] != '{' {
+		return raw
+	}
+	rest = rest[1:]
+	out := make([]byte, 0, len(raw)+16)
+	out = append(out, `{"format":`...)
+	out = strconv.AppendInt(out, int64(version), 10)
+	if trimmed := bytes.TrimLeft(rest, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, rest...)
+}
+
+// marshalFields encodes fields without escaping HTML characters, leaving
+// URLs as they were.
+func marshalFields(fields map[string]json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// upgradeState upgrades meta.json and the update history in place when an
+// older basar wrote them, logging each, and fails when a newer one did so
+// an update does not overwrite what it cannot read. Snapshots are upgraded
+// when read and rewritten as sources are fetched.
+func (c *Cache) upgradeState() error {
+	raw, err := os.ReadFile(c.cfg.MetaFile)
+	if err == nil {
+		out, upgraded, err := metaFormat.upgrade(raw)
+		if errors.Is(err, ErrNewerFormat) {
+			return err
+		}
+		if upgraded {
+			if err := c.writeMeta(out); err != nil {
+				return fmt.Errorf("upgrading %s: %w", metaFormat.name, err)
+			}
+			c.log.Info("upgraded state file format", "file", c.cfg.MetaFile, "format", MetaFormat)
+		}
+	}
+
+	entries, upgraded, err := c.readHistory()
+	if err != nil {
+		return err
+	}
+	if upgraded {
+		if err := c.writeHistory(entries); err != nil {
+			return fmt.Errorf("upgrading %s: %w", historyFormat.name, err)
+		}
+		c.log.Info("upgraded state file format", "file", c.cfg.HistoryFile, "format", HistoryFormat)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestStampFormat(t *testing.T) {
+	tests := []struct {
+		in, expected string
+	}{
+		{`{"a":1}`, `{"format":3,"a":1}`},
+		{`{}`, `{"format":3}`},
+		{` { }`, `{"format":3 }`},
+		{`[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		if got := string(stampFormat([]byte(tt.in), 3)); got != tt.expected {
+			t.Errorf("stampFormat(%s) = %s, expected %s", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestStateFormatUpgrade(t *testing.T) {
+	f := stateFormat{name: "test", current: 2, migrations: []migration{
+		nil,
+		func(fields map[string]json.RawMessage) error {
+			fields["renamed"] = fields["old"]
+			delete(fields, "old")
+			return nil
+		},
+	}}
+
+	out, upgraded, err := f.upgrade([]byte(`{"old":"a&b"}`))
+	if err != nil || !upgraded || string(out) != `{"format":2,"renamed":"a&b"}` {
+		t.Errorf("upgrade(format 0) = %s, %v, %v", out, upgraded, err)
+	}
+	out, upgraded, err = f.upgrade([]byte(`{"old":1,"format":1}`))
+	if err != nil || !upgraded || string(out) != `{"format":2,"renamed":1}` {
+		t.Errorf("upgrade(format 1) = %s, %v, %v", out, upgraded, err)
+	}
+	current := []byte(`{"format":2,"renamed":1}`)
+	if out, upgraded, err = f.upgrade(current); err != nil || upgraded || string(out) != string(current) {
+		t.Errorf("upgrade(format 2) = %s, %v, %v; expected it unchanged", out, upgraded, err)
+	}
+	if _, _, err = f.upgrade([]byte(`{"format":3}`)); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("upgrade(format 3) error = %v, expected ErrNewerFormat", err)
+	}
+}
+
+func TestStateFilesAreStamped(t *testing.T) {
+	c := New(testConfig(t))
+	if err := c.saveMeta(&fetcher.MetaCache{Generation: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.appendHistory(HistoryEntry{Status: UpdateUpdated}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.saveSnapshot("source", &fetcher.BannerData{Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{c.cfg.MetaFile, c.cfg.HistoryFile, c.snapshotPath("source")} {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The history is a line per entry; the others are one object
+		if line, _, _ := strings.Cut(string(raw), "\n"); formatOf([]byte(line)) != 1 && formatOf(raw) != 1 {
+			t.Errorf("%s is not stamped: %s", filepath.Base(path), raw)
+		}
+	}
+	if meta := c.loadMeta(); meta.Generation != 4 {
+		t.Errorf("loadMeta().Generation = %d, expected 4", meta.Generation)
+	}
+	if data := c.loadSnapshot("source"); data == nil || data.Version != 1 {
+		t.Errorf("loadSnapshot() = %+v", data)
+	}
+}
+
+func TestUpdateUpgradesLegacyState(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile}
+	c := New(cfg)
+
+	if err := os.MkdirAll(filepath.Dir(cfg.HistoryFile), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	legacyHistory := `{"at":"2026-01-02T03:04:05Z","status":"updated","generation":1,"entries":2}` + "\n"
+	if err := os.WriteFile(cfg.HistoryFile, []byte(legacyHistory), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.MetaFile, []byte(`{"sources":{},"generation":7}`), FileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.lockForUpdate(); err != nil {
+		t.Fatalf("lockForUpdate() failed: %v", err)
+	}
+	c.releaseLock()
+
+	raw, _ := os.ReadFile(cfg.MetaFile)
+	if formatOf(raw) != MetaFormat || c.loadMeta().Generation != 7 {
+		t.Errorf("meta.json was not upgraded in place: %s", raw)
+	}
+	raw, _ = os.ReadFile(cfg.HistoryFile)
+	entries, upgraded, err := c.readHistory()
+	if !strings.HasPrefix(string(raw), `{"format":1`) || upgraded || err != nil || len(entries) != 1 {
+		t.Errorf("history was not upgraded in place: %s", raw)
+	}
+}
+
+func TestUpdateRefusesNewerState(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile}
+	c := New(cfg)
+
+	newer := []byte(`{"format":99,"sources":{"x":{}}}`)
+	if err := os.WriteFile(cfg.MetaFile, newer, FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(context.Background(), true); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("Update() error = %v, expected ErrNewerFormat", err)
+	}
+	if raw, _ := os.ReadFile(cfg.MetaFile); string(raw) != string(newer) {
+		t.Errorf("meta.json of a newer basar was overwritten: %s", raw)
+	}
+	if meta := c.loadMeta(); len(meta.Sources) != 0 {
+		t.Errorf("loadMeta() read a newer format: %+v", meta)
+	}
+
+	if err := os.MkdirAll(c.snapshotDir(), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.snapshotPath("source"), []byte(`{"format":99,"linux":{}}`), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if data := c.loadSnapshot("source"); data != nil {
+		t.Errorf("loadSnapshot() read a newer format: %+v", data)
+	}
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// HistoryRetention is how long update history is kept for reports.
+const HistoryRetention = 90 * 24 * time.Hour
+
+// HistoryEntry records one update attempt in the update history.
+type HistoryEntry struct {
+	At time.Time `json:"at"`
+	// Status is UpdateUpdated, UpdateUnchanged, or UpdateFailed.
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Generation uint64         `json:"generation"`
+	Entries    int            `json:"entries"`
+	Sources    []SourceResult `json:"sources,omitempty"`
+	Added      []string       `json:"added,omitempty"`
+	Removed    []string       `json:"removed,omitempty"`
+}
+
+// bannerChanges returns the banners in merged but not in existing, and
+// those in existing but not in merged, sorted.
+func bannerChanges(existing, merged *fetcher.BannerData) (added, removed []string) {
+	var old map[string][]string
+	if existing != nil {
+		old = existing.Linux
+	}
+	for banner := range merged.Linux {
+		if _, ok := old[banner]; !ok {
+			added = append(added, banner)
+		}
+	}
+	for banner := range old {
+		if _, ok := merged.Linux[banner]; !ok {
+			removed = append(removed, banner)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// loadHistory reads the update history, oldest first, skipping lines that
+// do not parse.
+func (c *Cache) loadHistory() ([]HistoryEntry, error) {
+	entries, _, err := c.readHistory()
+	return entries, err
+}
+
+// readHistory reads the update history like loadHistory, upgrading each
+// entry to HistoryFormat and reporting whether any needed it. An entry in
+// a newer format fails it.
+func (c *Cache) readHistory() (entries []HistoryEntry, upgraded bool, err error) {
+	raw, err := os.ReadFile(c.cfg.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("reading update history: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, len(raw)+1)
+	for scanner.Scan() {
+		line, migrated, err := historyFormat.upgrade(scanner.Bytes())
+		if errors.Is(err, ErrNewerFormat) {
+			return nil, false, err
+		}
+		var e HistoryEntry
+		if err == nil && json.Unmarshal(line, &e) == nil {
+			entries = append(entries, e)
+			upgraded = upgraded || migrated
+		}
+	}
+	return entries, upgraded, scanner.Err()
+}
+
+// appendHistory adds e to the update history, dropping entries older than
+// HistoryRetention.
+func (c *Cache) appendHistory(e HistoryEntry) error {
+	entries, err := c.loadHistory()
+	if err != nil {
+		return err
+	}
+
+	cutoff := e.At.Add(-HistoryRetention)
+	kept := entries[:0]
+	for _, old := range append(entries, e) {
+		if !old.At.Before(cutoff) {
+			kept = append(kept, old)
+		}
+	}
+	return c.writeHistory(kept)
+}
+
+// writeHistory replaces the update history with entries, each stamped
+// with HistoryFormat.
+func (c *Cache) writeHistory(entries []HistoryEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encoding update history: %w", err)
+		}
+		buf.Write(stampFormat(line, HistoryFormat))
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.cfg.HistoryFile), DirMode); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
+	return writeFileAtomic(c.cfg.HistoryFile, buf.Bytes())
+}
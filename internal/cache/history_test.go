@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestBannerChanges(t *testing.T) {
+	existing := &fetcher.BannerData{Linux: map[string][]string{"a": nil, "b": nil}}
+	merged := &fetcher.BannerData{Linux: map[string][]string{"b": nil, "d": nil, "c": nil}}
+
+	added, removed := bannerChanges(existing, merged)
+	if !slices.Equal(added, []string{"c", "d"}) || !slices.Equal(removed, []string{"a"}) {
+		t.Errorf("bannerChanges() = %v, %v; expected [c d], [a]", added, removed)
+	}
+
+	added, removed = bannerChanges(nil, merged)
+	if len(added) != 3 || removed != nil {
+		t.Errorf("bannerChanges(nil) = %v, %v; expected every banner added", added, removed)
+	}
+}
+
+func TestUpdateRecordsHistory(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	createTestBannerFile(t, sourceFile)
+	cfg.Sources = []string{sourceFile, "/nonexistent/source.json"}
+
+	c := New(cfg)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Update(context.Background(), true); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+	}
+
+	entries, err := c.loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(entries))
+	}
+	first := entries[0]
+	if first.Status != UpdateUpdated || len(first.Added) != 2 || first.Entries != 2 || len(first.Sources) != 2 {
+		t.Errorf("unexpected first entry: %+v", first)
+	}
+	if len(entries[1].Added) != 0 || len(entries[1].Removed) != 0 {
+		t.Errorf("an identical update should change no banners: %+v", entries[1])
+	}
+}
+
+func TestAppendHistoryRetention(t *testing.T) {
+	c := New(testConfig(t))
+	now := time.Now()
+
+	if err := c.appendHistory(HistoryEntry{At: now.Add(-HistoryRetention - time.Hour), Status: UpdateUpdated}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.appendHistory(HistoryEntry{At: now, Status: UpdateUnchanged}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Status != UpdateUnchanged {
+		t.Errorf("expected only the recent entry to be kept, got %+v", entries)
+	}
+}
+
+func TestLoadHistorySkipsCorruptLines(t *testing.T) {
+	cfg := testConfig(t)
+	if err := os.WriteFile(cfg.HistoryFile, []byte("{not json\n{\"status\":\"failed\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := New(cfg).loadHistory()
+	if err != nil || len(entries) != 1 || entries[0].Status != UpdateFailed {
+		t.Errorf("loadHistory() = %+v, %v; expected the one valid entry", entries, err)
+	}
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// Index is an in-memory view of the cache for repeated lookups: banners are
+// kept sorted for exact and prefix queries in O(log n), with a trigram index
+// narrowing substring queries to the banners that can contain them.
+type Index struct {
+	banners []string
+	urls    [][]string
+	sources [][]string
+
+	// trigrams maps each three-byte sequence to the ascending positions
+	// of the banners containing it.
+	trigrams map[string][]int32
+}
+
+// NewIndex builds an Index of data, attributing banners with prov.
+func NewIndex(data *fetcher.BannerData, prov fetcher.Provenance) *Index {
+	ix := &Index{
+		banners:  make([]string, 0, len(data.Linux)),
+		trigrams: make(map[string][]int32),
+	}
+	for banner := range data.Linux {
+		ix.banners = append(ix.banners, banner)
+	}
+	sort.Strings(ix.banners)
+
+	ix.urls = make([][]string, len(ix.banners))
+	ix.sources = make([][]string, len(ix.banners))
+	for i, banner := range ix.banners {
+		ix.urls[i] = data.Linux[banner]
+		ix.sources[i] = prov[banner]
+
+		for _, t := range trigrams(banner) {
+			ix.trigrams[t] = append(ix.trigrams[t], int32(i))
+		}
+	}
+
+	return ix
+}
+
+// Len returns the number of indexed banners.
+func (ix *Index) Len() int {
+	return len(ix.banners)
+}
+
+// Lookup finds banners like Cache.Lookup: an exact match on its own,
+// otherwise every banner containing query, sorted.
+func (ix *Index) Lookup(query string) []Match {
+	if i, ok := ix.find(query); ok {
+		m := ix.match(i)
+		m.Exact = true
+		return []Match{m}
+	}
+
+	var matches []Match
+	for _, i := range ix.candidates(query) {
+		if strings.Contains(ix.banners[i], query) {
+			matches = append(matches, ix.match(int(i)))
+		}
+	}
+	return matches
+}
+
+// Prefix returns the banners starting with prefix, sorted.
+func (ix *Index) Prefix(prefix string) []Match {
+	var matches []Match
+	for i := sort.SearchStrings(ix.banners, prefix); i < len(ix.banners) && strings.HasPrefix(ix.banners[i], prefix); i++ {
+		matches = append(matches, ix.match(i))
+	}
+	return matches
+}
+
+// find returns the position of banner, if indexed.
+func (ix *Index) find(banner string) (int, bool) {
+	i := sort.SearchStrings(ix.banners, banner)
+	return i, i < len(ix.banners) && ix.banners[i] == banner
+}
+
+// match returns the Match for the banner at position i.
+func (ix *Index) match(i int) Match {
+	return Match{Banner: ix.banners[i], URLs: ix.urls[i], Sources: ix.sources[i]}
+}
+
+// candidates returns, in ascending order, the positions of banners that may
+// contain query: those holding all of its trigrams, or every banner when
+// query is too short to have any.
+func (ix *Index) candidates(query string) []int32 {
+	grams := trigrams(query)
+	if len(grams) == 0 {
+		all := make([]int32, len(ix.banners))
+		for i := range all {
+			all[i] = int32(i)
+		}
+		return all
+	}
+
+	// Intersect starting from the rarest trigram to keep the set small
+	lists := make([][]int32, 0, len(grams))
+	for _, t := range grams {
+		postings, ok := ix.trigrams[t]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, postings)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersect(result, list)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// trigrams returns the distinct three-byte substrings of s.
+func trigrams(s string) []string {
+	seen := make(map[string]bool)
+	var grams []string
+	for i := 0; i+3 <= len(s); i++ {
+		if t := s[i : i+3]; !seen[t] {
+			seen[t] = true
+			grams = append(grams, t)
+		}
+	}
+	return grams
+}
+
+// intersect returns the values present in both ascending lists.
+func intersect(a, b []int32) []int32 {
+	var out []int32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// indexVersion identifies the cache and provenance files an Index was
+// built from, so it is rebuilt only when either changes.
+type indexVersion struct {
+	cacheMod, provMod   time.Time
+	cacheSize, provSize int64
+}
+
+// currentIndexVersion stats the files an Index is built from.
+func (c *Cache) currentIndexVersion() (indexVersion, error) {
+	info, err := os.Stat(c.cfg.CacheFile)
+	if err != nil {
+		return indexVersion{}, err
+	}
+	v := indexVersion{cacheMod: info.ModTime(), cacheSize: info.Size()}
+	if info, err := os.Stat(c.provenancePath()); err == nil {
+		v.provMod, v.provSize = info.ModTime(), info.Size()
+	}
+	return v, nil
+}
+
+// Index returns an Index of the cache, reusing the previous one until the
+// cache file or its provenance changes. It is safe for concurrent use, for
+// long-running servers answering many lookups.
+func (c *Cache) Index() (*Index, error) {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+
+	v, err := c.currentIndexVersion()
+	if err != nil {
+		return nil, ErrNoCache
+	}
+	if c.index != nil && v == c.indexVersion {
+		return c.index, nil
+	}
+
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return nil, ErrNoCache
+	}
+	c.index = NewIndex(banners, c.loadProvenance())
+	c.indexVersion = v
+	c.log.Debug("rebuilt lookup index", "banners", c.index.Len())
+	return c.index, nil
+}
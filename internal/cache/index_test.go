@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func testIndex() *Index {
+	return NewIndex(&fetcher.BannerData{Linux: map[string][]string{
+		"Linux version 5.15.0-91-generic": {"https://example.com/91.json"},
+		"Linux version 5.15.0-92-generic": {"https://example.com/92.json"},
+		"Linux version 6.1.0-13-amd64":    {"https://example.com/13.json"},
+		"Linux version 5.15.0":            {"https://example.com/base.json"},
+	}}, fetcher.Provenance{
+		"Linux version 6.1.0-13-amd64": {"debian"},
+	})
+}
+
+func banners(matches []Match) []string {
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.Banner)
+	}
+	return out
+}
+
+func TestIndexLookup(t *testing.T) {
+	ix := testIndex()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Linux version 5.15.0", []string{"Linux version 5.15.0"}},
+		{"generic", []string{"Linux version 5.15.0-91-generic", "Linux version 5.15.0-92-generic"}},
+		{"-9", []string{"Linux version 5.15.0-91-generic", "Linux version 5.15.0-92-generic"}},
+		{"amd64", []string{"Linux version 6.1.0-13-amd64"}},
+		{"freebsd", nil},
+		{"genericx", nil},
+	}
+
+	for _, tt := range tests {
+		if got := banners(ix.Lookup(tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("Lookup(%q) = %v, expected %v", tt.query, got, tt.want)
+		}
+	}
+
+	m := ix.Lookup("Linux version 6.1.0-13-amd64")
+	if !m[0].Exact || len(m[0].Sources) != 1 || m[0].URLs[0] != "https://example.com/13.json" {
+		t.Errorf("exact match should carry URLs and provenance, got %+v", m[0])
+	}
+}
+
+func TestIndexPrefix(t *testing.T) {
+	ix := testIndex()
+
+	got := banners(ix.Prefix("Linux version 5.15.0-"))
+	want := []string{"Linux version 5.15.0-91-generic", "Linux version 5.15.0-92-generic"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Prefix() = %v, expected %v", got, want)
+	}
+	if got := ix.Prefix("Linux version 7"); got != nil {
+		t.Errorf("Prefix() with no match = %v", got)
+	}
+}
+
+// TestIndexMatchesLookup checks the index agrees with Cache.Lookup.
+func TestIndexMatchesLookup(t *testing.T) {
+	cfg := testConfig(t)
+	createTestBannerFile(t, cfg.CacheFile)
+	c := New(cfg)
+
+	ix, err := c.Index()
+	if err != nil {
+		t.Fatalf("Index() failed: %v", err)
+	}
+	for _, q := range []string{"Linux version 5.15.0-generic", "generic", "6.", "x", "none"} {
+		want, _ := c.Lookup(q)
+		if got := ix.Lookup(q); !slices.Equal(banners(got), banners(want)) {
+			t.Errorf("Index.Lookup(%q) = %v, Cache.Lookup = %v", q, banners(got), banners(want))
+		}
+	}
+}
+
+func TestCacheIndexRebuild(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+	if _, err := c.Index(); !errors.Is(err, ErrNoCache) {
+		t.Errorf("Index() without a cache = %v, expected ErrNoCache", err)
+	}
+
+	createTestBannerFile(t, cfg.CacheFile)
+	first, err := c.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Index(); again != first {
+		t.Error("Index() should be reused while the cache is unchanged")
+	}
+
+	if err := os.WriteFile(cfg.CacheFile, []byte(`{"version":1,"linux":{"only":["u"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	_ = os.Chtimes(cfg.CacheFile, later, later)
+
+	rebuilt, err := c.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == first || rebuilt.Len() != 1 {
+		t.Errorf("Index() should be rebuilt after the cache changes, got %d banners", rebuilt.Len())
+	}
+}
+
+func BenchmarkIndexLookup(b *testing.B) {
+	data := &fetcher.BannerData{Linux: make(map[string][]string)}
+	for i := 0; i < 20000; i++ {
+		banner := fmt.Sprintf("Linux version 5.%d.0-%d-generic (buildd@lcy02-amd64-%03d)", i%20, i, i%997)
+		data.Linux[banner] = []string{"https://example.com/" + banner}
+	}
+	ix := NewIndex(data, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix.Lookup("-1234-generic")
+	}
+}
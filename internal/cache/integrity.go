@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// How much of the cache and its sources an integrity check samples.
+const (
+	integrityBanners = 64
+	integritySources = 2
+)
+
+// IntegrityCheck is the outcome of one check of CheckIntegrity.
+type IntegrityCheck struct {
+	// Name is "checksum", "schema", or "sources".
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// IntegrityReport is the outcome of CheckIntegrity. OK reflects the checks
+// of the cache file alone: an unreachable source does not stop the cache
+// from being served.
+type IntegrityReport struct {
+	At     time.Time        `json:"at"`
+	OK     bool             `json:"ok"`
+	Checks []IntegrityCheck `json:"checks"`
+}
+
+// CheckIntegrity runs lightweight checks meant to catch corruption between
+// updates: the cache file still has the checksum recorded when it was
+// written, a random sample of its banners follows the schema, and a random
+// sample of the sources is reachable.
+func (c *Cache) CheckIntegrity(ctx context.Context) IntegrityReport {
+	cacheChecks := []IntegrityCheck{c.checkChecksum(), c.checkSchemaSample()}
+	return IntegrityReport{
+		At:     time.Now(),
+		OK:     cacheChecks[0].OK && cacheChecks[1].OK,
+		Checks: append(cacheChecks, c.checkSourceSample(ctx)),
+	}
+}
+
+// checkChecksum compares the cache file with the checksum in the metadata.
+// An update may replace the file between the hash and the metadata read,
+// so a mismatch is confirmed once before it is reported.
+func (c *Cache) checkChecksum() IntegrityCheck {
+	check := IntegrityCheck{Name: "checksum"}
+	var sum, want string
+	for attempt := 0; attempt < 2; attempt++ {
+		var err error
+		sum, err = fileChecksum(c.cfg.CacheFile)
+		if errors.Is(err, os.ErrNotExist) {
+			check.Detail = "no cache"
+			return check
+		}
+		if err != nil {
+			check.Detail = err.Error()
+			return check
+		}
+		want = c.loadMeta().Checksum
+		if want == "" {
+			check.OK = true
+			check.Detail = "no checksum recorded"
+			return check
+		}
+		if sum == want {
+			check.OK = true
+			return check
+		}
+	}
+	check.Detail = fmt.Sprintf("cache file has checksum %.12s, expected %.12s", sum, want)
+	return check
+}
+
+// checkSchemaSample decodes the cache file and checks a random sample of
+// its banners against the schema.
+func (c *Cache) checkSchemaSample() IntegrityCheck {
+	check := IntegrityCheck{Name: "schema"}
+	raw, err := os.ReadFile(c.cfg.CacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		check.Detail = "no cache"
+		return check
+	}
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	var data fetcher.BannerData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		check.Detail = fmt.Sprintf("cache file is not valid JSON: %v", err)
+		return check
+	}
+
+	banners := make([]string, 0, len(data.Linux))
+	for banner := range data.Linux {
+		banners = append(banners, banner)
+	}
+	sort.Strings(banners)
+	sample := &fetcher.BannerData{Version: data.Version, Linux: make(map[string][]string)}
+	for _, i := range sampleIndexes(len(banners), integrityBanners) {
+		sample.Linux[banners[i]] = data.Linux[banners[i]]
+	}
+	if err := fetcher.CheckSchema(sample); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("%d of %d banners checked", len(sample.Linux), len(banners))
+	return check
+}
+
+// checkSourceSample probes a random sample of the configured sources.
+func (c *Cache) checkSourceSample(ctx context.Context) IntegrityCheck {
+	check := IntegrityCheck{Name: "sources", OK: true}
+	indexes := sampleIndexes(len(c.cfg.Sources), integritySources)
+	for _, i := range indexes {
+		source := c.cfg.Sources[i]
+		err := c.fetcher.Probe(ctx, source)
+		switch {
+		case err == nil:
+		case errors.Is(err, fetcher.ErrOffline):
+			check.Detail = "offline; sources not probed"
+			return check
+		default:
+			check.OK = false
+			check.Detail = fmt.Sprintf("%s: %v", source, err)
+			return check
+		}
+	}
+	check.Detail = fmt.Sprintf("%d of %d sources probed", len(indexes), len(c.cfg.Sources))
+	return check
+}
+
+// sampleIndexes returns up to k distinct random indexes below n.
+func sampleIndexes(n, k int) []int {
+	perm := rand.Perm(n)
+	if len(perm) > k {
+		perm = perm[:k]
+	}
+	return perm
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	cfg := testConfig(t)
+	src := filepath.Join(cfg.ConfigDir, "source.json")
+	writeBannerSource(t, src, bannerURLs("a", "b"))
+	cfg.Sources = []string{src}
+	c := New(cfg)
+	ctx := context.Background()
+
+	if r := c.CheckIntegrity(ctx); r.OK {
+		t.Errorf("CheckIntegrity() without a cache = %+v, expected a failure", r)
+	}
+
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	r := c.CheckIntegrity(ctx)
+	if !r.OK || len(r.Checks) != 3 {
+		t.Fatalf("CheckIntegrity() = %+v, expected three passing checks", r)
+	}
+	for _, ch := range r.Checks {
+		if !ch.OK {
+			t.Errorf("check %s failed: %s", ch.Name, ch.Detail)
+		}
+	}
+
+	// A vanished source is reported without failing the cache
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.CheckIntegrity(ctx); !r.OK || r.Checks[2].OK {
+		t.Errorf("CheckIntegrity() with a missing source = %+v, expected only the sources check to fail", r)
+	}
+
+	// A cache file changed behind the metadata's back
+	if err := os.WriteFile(cfg.CacheFile, []byte(`{"version": 1, "linux": {"b": ["relative.json"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = c.CheckIntegrity(ctx)
+	if r.OK || r.Checks[0].OK || !strings.Contains(r.Checks[0].Detail, "checksum") {
+		t.Errorf("CheckIntegrity() after tampering = %+v, expected a checksum mismatch", r)
+	}
+	if r.Checks[1].OK {
+		t.Errorf("schema check passed a relative symbol URL: %+v", r.Checks[1])
+	}
+}
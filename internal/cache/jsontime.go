@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The JSON encodings below give every timestamp both in RFC 3339 (KEY) and
+// in Unix seconds (KEY_unix), and every duration both as a Go duration
+// string (KEY) and in seconds (KEY_seconds), so consumers can read either
+// without parsing the other. Decoding ignores the extra fields.
+
+// unixTime returns t in Unix seconds, or 0 for the zero time so omitempty
+// drops it.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// MarshalJSON adds age and updated_at_unix to the encoded stats.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+	var age string
+	if s.AgeSeconds > 0 {
+		age = (time.Duration(s.AgeSeconds) * time.Second).String()
+	}
+	return json.Marshal(struct {
+		stats
+		Age           string `json:"age,omitempty"`
+		UpdatedAtUnix int64  `json:"updated_at_unix,omitempty"`
+	}{stats(s), age, unixTime(s.UpdatedAt)})
+}
+
+// MarshalJSON adds last_fetch_unix and last_change_unix to the encoded
+// source stats.
+func (s SourceStats) MarshalJSON() ([]byte, error) {
+	type sourceStats SourceStats
+	return json.Marshal(struct {
+		sourceStats
+		LastFetchUnix  int64 `json:"last_fetch_unix,omitempty"`
+		LastChangeUnix int64 `json:"last_change_unix,omitempty"`
+	}{sourceStats(s), unixTime(s.LastFetch), unixTime(s.LastChange)})
+}
+
+// MarshalJSON adds duration and duration_seconds to the encoded result,
+// next to duration_ns.
+func (r UpdateResult) MarshalJSON() ([]byte, error) {
+	type updateResult UpdateResult
+	return json.Marshal(struct {
+		updateResult
+		DurationString  string  `json:"duration"`
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{updateResult(r), r.Duration.String(), r.Duration.Seconds()})
+}
+
+// MarshalJSON adds at_unix to the encoded history entry.
+func (e HistoryEntry) MarshalJSON() ([]byte, error) {
+	type historyEntry HistoryEntry
+	return json.Marshal(struct {
+		historyEntry
+		AtUnix int64 `json:"at_unix,omitempty"`
+	}{historyEntry(e), unixTime(e.At)})
+}
+
+// MarshalJSON adds last_check_unix and last_ok_unix to the encoded URL
+// history.
+func (h URLHealth) MarshalJSON() ([]byte, error) {
+	type urlHealth URLHealth
+	return json.Marshal(struct {
+		urlHealth
+		LastCheckUnix int64 `json:"last_check_unix,omitempty"`
+		LastOKUnix    int64 `json:"last_ok_unix,omitempty"`
+	}{urlHealth(h), unixTime(h.LastCheck), unixTime(h.LastOK)})
+}
+
+// MarshalJSON adds since_unix and until_unix to the encoded report.
+func (r Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	return json.Marshal(struct {
+		report
+		SinceUnix int64 `json:"since_unix,omitempty"`
+		UntilUnix int64 `json:"until_unix,omitempty"`
+	}{report(r), unixTime(r.Since), unixTime(r.Until)})
+}
+
+// MarshalJSON adds removed_unix and expires_unix to the encoded tombstone.
+func (t Tombstone) MarshalJSON() ([]byte, error) {
+	type tombstone Tombstone
+	return json.Marshal(struct {
+		tombstone
+		RemovedUnix int64 `json:"removed_unix,omitempty"`
+		ExpiresUnix int64 `json:"expires_unix,omitempty"`
+	}{tombstone(t), unixTime(t.Removed), unixTime(t.Expires)})
+}
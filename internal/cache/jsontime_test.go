@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONTimes(t *testing.T) {
+	at := time.Date(2024, 1, 31, 6, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    any
+		want []string
+	}{
+		{"stats", Stats{AgeSeconds: 3600, UpdatedAt: at}, []string{`"age_seconds":3600`, `"age":"1h0m0s"`, `"updated_at_unix":1706680800`}},
+		{"source stats", SourceStats{LastFetch: at, LastChange: at}, []string{`"last_fetch_unix":1706680800`, `"last_change_unix":1706680800`}},
+		{"result", &UpdateResult{Duration: 1500 * time.Millisecond}, []string{`"duration_ns":1500000000`, `"duration":"1.5s"`, `"duration_seconds":1.5`}},
+		{"history", HistoryEntry{At: at}, []string{`"at":"2024-01-31T06:00:00Z"`, `"at_unix":1706680800`}},
+		{"url health", URLHealth{LastCheck: at}, []string{`"last_check_unix":1706680800`}},
+		{"report", Report{Since: at, Until: at}, []string{`"since_unix":1706680800`, `"until_unix":1706680800`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(raw), want) {
+					t.Errorf("%s missing %s", raw, want)
+				}
+			}
+		})
+	}
+
+	// Zero times have no Unix form, and the extra fields decode away
+	raw, _ := json.Marshal(URLHealth{Checks: 1})
+	if strings.Contains(string(raw), "_unix") {
+		t.Errorf("zero times should omit their Unix form: %s", raw)
+	}
+	var e HistoryEntry
+	raw, _ = json.Marshal(HistoryEntry{At: at, Status: UpdateUpdated})
+	if err := json.Unmarshal(raw, &e); err != nil || !e.At.Equal(at) || e.Status != UpdateUpdated {
+		t.Errorf("round trip = %+v, %v", e, err)
+	}
+}
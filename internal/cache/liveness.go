@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// DeadAfter is how many consecutive failed checks mark a symbol URL as
+// chronically dead.
+const DeadAfter = 3
+
+// URLHealth is the check history of a single symbol URL.
+type URLHealth struct {
+	Checks              int       `json:"checks"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check"`
+	LastOK              time.Time `json:"last_ok,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Score is the fraction of checks that succeeded, 1 for unchecked URLs.
+func (h URLHealth) Score() float64 {
+	if h.Checks == 0 {
+		return 1
+	}
+	return float64(h.Checks-h.Failures) / float64(h.Checks)
+}
+
+// Dead reports whether the URL failed its last DeadAfter checks.
+func (h URLHealth) Dead() bool {
+	return h.ConsecutiveFailures >= DeadAfter
+}
+
+// record adds the outcome of a check at t.
+func (h *URLHealth) record(t time.Time, err error) {
+	h.Checks++
+	h.LastCheck = t
+	if err != nil {
+		h.Failures++
+		h.ConsecutiveFailures++
+		h.LastError = err.Error()
+		return
+	}
+	h.ConsecutiveFailures = 0
+	h.LastOK = t
+	h.LastError = ""
+}
+
+// Liveness maps symbol URLs to their check history.
+type Liveness map[string]URLHealth
+
+// loadLiveness reads the persisted URL history, returning an empty map if
+// there is none.
+func (c *Cache) loadLiveness() Liveness {
+	live := make(Liveness)
+	raw, err := os.ReadFile(c.cfg.LivenessFile)
+	if err != nil {
+		return live
+	}
+	_ = json.Unmarshal(raw, &live) // A corrupt history just starts over
+	return live
+}
+
+// saveLiveness persists the URL history.
+func (c *Cache) saveLiveness(live Liveness) error {
+	if err := os.MkdirAll(filepath.Dir(c.cfg.LivenessFile), DirMode); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
+
+	raw, err := json.Marshal(live)
+	if err != nil {
+		return fmt.Errorf("encoding liveness: %w", err)
+	}
+
+	return writeFileAtomic(c.cfg.LivenessFile, raw)
+}
+
+// URLCheck is the outcome of checking one symbol URL with VerifyURLs.
+type URLCheck struct {
+	URL    string    `json:"url"`
+	OK     bool      `json:"ok"`
+	Error  string    `json:"error,omitempty"`
+	Health URLHealth `json:"health"`
+	Score  float64   `json:"score"`
+	Dead   bool      `json:"dead"`
+}
+
+// VerifyURLs checks that the symbol URLs of banners matching query (every
+// banner if query is empty) are reachable, and records the outcome in the
+// persisted URL history used to demote dead URLs during merge.
+func (c *Cache) VerifyURLs(ctx context.Context, query string) ([]URLCheck, error) {
+	if c.cfg.Offline {
+		// Failed probes would count against every URL's history
+		return nil, fetcher.ErrOffline
+	}
+
+	matches, err := c.Lookup(query)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, m := range matches {
+		for _, u := range m.URLs {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	sort.Strings(urls)
+
+	errs := make([]error, len(urls))
+	parallel(len(urls), c.cfg.Jobs, func(i int) {
+		errs[i] = c.fetcher.Probe(ctx, urls[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	live := c.loadLiveness()
+	now := time.Now()
+	checks := make([]URLCheck, len(urls))
+	for i, u := range urls {
+		h := live[u]
+		h.record(now, errs[i])
+		live[u] = h
+
+		checks[i] = URLCheck{URL: u, OK: errs[i] == nil, Health: h, Score: h.Score(), Dead: h.Dead()}
+		if errs[i] != nil {
+			checks[i].Error = errs[i].Error()
+		}
+	}
+
+	if err := c.saveLiveness(live); err != nil {
+		return checks, err
+	}
+	return checks, nil
+}
+
+// demoteDeadURLs moves chronically dead URLs to the end of each banner's
+// list, keeping the order of the rest, so volatility3 tries live URLs first.
+func (c *Cache) demoteDeadURLs(linux map[string][]string) {
+	if !c.cfg.DemoteDeadURLs {
+		return
+	}
+	live := c.loadLiveness()
+	if len(live) == 0 {
+		return
+	}
+
+	for banner, urls := range linux {
+		sort.SliceStable(urls, func(i, j int) bool {
+			return !live[urls[i]].Dead() && live[urls[j]].Dead()
+		})
+		linux[banner] = urls
+	}
+}
+
+// parallel calls fn for 0..n-1, at most jobs at a time (unbounded if jobs
+// is zero or less), and waits for all calls to return.
+func parallel(n, jobs int, fn func(i int)) {
+	if jobs <= 0 || jobs > n {
+		jobs = n
+	}
+	sem := make(chan struct{}, max(jobs, 1))
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(idx)
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestURLHealth(t *testing.T) {
+	var h URLHealth
+	if h.Score() != 1 || h.Dead() {
+		t.Errorf("unchecked URL should score 1 and be alive, got %+v", h)
+	}
+
+	now := time.Now()
+	h.record(now, nil)
+	for i := 0; i < DeadAfter; i++ {
+		h.record(now, errors.New("unexpected status: 404"))
+	}
+	if !h.Dead() || h.Score() != 0.25 || h.LastError == "" {
+		t.Errorf("expected a dead URL scoring 0.25, got %+v (score %v)", h, h.Score())
+	}
+
+	h.record(now, nil)
+	if h.Dead() || h.ConsecutiveFailures != 0 || h.LastError != "" {
+		t.Errorf("a successful check should revive the URL, got %+v", h)
+	}
+}
+
+func TestVerifyURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.json" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	c := New(cfg)
+	live, gone := server.URL+"/live.json", server.URL+"/gone.json"
+	if err := c.write(&fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0": {gone, live},
+		"Linux version 6.1.0":  {live},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var checks []URLCheck
+	for i := 0; i < DeadAfter; i++ {
+		var err error
+		if checks, err = c.VerifyURLs(context.Background(), ""); err != nil {
+			t.Fatalf("VerifyURLs() failed: %v", err)
+		}
+	}
+
+	if len(checks) != 2 {
+		t.Fatalf("expected each URL checked once per run, got %+v", checks)
+	}
+	for _, ch := range checks {
+		switch ch.URL {
+		case live:
+			if !ch.OK || ch.Dead || ch.Health.Checks != DeadAfter {
+				t.Errorf("unexpected live check: %+v", ch)
+			}
+		case gone:
+			if ch.OK || !ch.Dead || ch.Error == "" {
+				t.Errorf("unexpected dead check: %+v", ch)
+			}
+		}
+	}
+
+	if _, err := os.Stat(cfg.LivenessFile); err != nil {
+		t.Errorf("history should be persisted: %v", err)
+	}
+}
+
+func TestUpdateDemotesDeadURLs(t *testing.T) {
+	cfg := testConfig(t)
+	sourceFile := filepath.Join(cfg.ConfigDir, "source.json")
+	dead, alive := "https://dead.example/5.15.json", "https://alive.example/5.15.json"
+	if err := os.WriteFile(sourceFile, []byte(`{"version":1,"linux":{"b":["`+dead+`","`+alive+`"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{sourceFile}
+
+	c := New(cfg)
+	if err := c.saveLiveness(Liveness{dead: {Checks: DeadAfter, Failures: DeadAfter, ConsecutiveFailures: DeadAfter}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, demote := range []bool{false, true} {
+		cfg.DemoteDeadURLs = demote
+		if _, err := c.Update(context.Background(), true); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+		urls := c.loadExistingBanners().Linux["b"]
+		want := []string{dead, alive}
+		if demote {
+			want = []string{alive, dead}
+		}
+		if !slices.Equal(urls, want) {
+			t.Errorf("demote=%v: URLs = %v, expected %v", demote, urls, want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// ErrNoCache indicates the cache file does not exist or cannot be parsed.
+var ErrNoCache = errors.New("no usable cache")
+
+// Match is a single banner returned by Lookup.
+type Match struct {
+	Banner  string   `json:"banner"`
+	URLs    []string `json:"urls"`
+	Sources []string `json:"sources,omitempty"`
+	Exact   bool     `json:"exact"`
+
+	// Metadata holds the extra fields sources list for the banner, such
+	// as its symbol file size or build id.
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+
+	// Tombstone is set for a banner its sources dropped, served until the
+	// tombstone expires. Its URLs are left out, being those of the match.
+	Tombstone *Tombstone `json:"tombstone,omitempty"`
+
+	// Trust maps each URL to the highest trust level of the sources
+	// listing it, when any source sets one.
+	Trust map[string]string `json:"trust,omitempty"`
+}
+
+// LookupOptions change how LookupWith matches banners.
+type LookupOptions struct {
+	// IgnoreCase matches regardless of case.
+	IgnoreCase bool
+
+	// Regexp takes the query as a regular expression rather than text.
+	Regexp bool
+
+	// Release matches the query against the whole kernel release of each
+	// banner, such as 5.15.0-91-generic, rather than anywhere in it.
+	Release bool
+}
+
+// bannerMatcher reports whether banner matches a query, and whether it
+// matches exactly: as a whole, or with LookupOptions.Release its whole
+// release.
+type bannerMatcher func(banner string) (match, exact bool)
+
+// matcher compiles query as opts say.
+func (opts LookupOptions) matcher(query string) (bannerMatcher, error) {
+	expr := query
+	if !opts.Regexp {
+		expr = regexp.QuoteMeta(query)
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	partial, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	whole := regexp.MustCompile(`^(?:` + expr + `)$`)
+
+	if opts.Release {
+		return func(banner string) (bool, bool) {
+			release, ok := config.BannerRelease(banner)
+			match := ok && whole.MatchString(release)
+			return match, match
+		}, nil
+	}
+	return func(banner string) (bool, bool) {
+		if !partial.MatchString(banner) {
+			return false, false
+		}
+		return true, whole.MatchString(banner)
+	}, nil
+}
+
+// Lookup finds banners matching query. An exact banner match is returned on
+// its own; otherwise every banner containing query is returned, sorted.
+// Sources, Metadata, Tombstone, and Trust are filled from the provenance,
+// metadata, tombstones, and URL trust sidecars when available. A current
+// disk index is used instead of loading the cache when there is one.
+func (c *Cache) Lookup(query string) ([]Match, error) {
+	return c.LookupWith(query, LookupOptions{})
+}
+
+// LookupWith finds banners matching query like Lookup, matched as opts
+// say. Exact matches are returned on their own, and an invalid regular
+// expression is an error.
+func (c *Cache) LookupWith(query string, opts LookupOptions) ([]Match, error) {
+	matches, err := c.lookup(query, opts)
+	if err != nil || len(matches) == 0 {
+		return matches, err
+	}
+	if md := c.loadMetadata(); md != nil {
+		for i := range matches {
+			matches[i].Metadata = md[matches[i].Banner]
+		}
+	}
+	if tombs := c.loadTombstones(); tombs != nil {
+		for i := range matches {
+			if t := tombs[matches[i].Banner]; t != nil {
+				matches[i].Tombstone = &Tombstone{Removed: t.Removed, Expires: t.Expires}
+			}
+		}
+	}
+	if trust := c.loadURLTrust(); trust != nil {
+		for i := range matches {
+			matches[i].Trust = make(map[string]string, len(matches[i].URLs))
+			for _, u := range matches[i].URLs {
+				matches[i].Trust[u] = trustOf(trust, u)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// lookup finds the banners matching query for LookupWith.
+func (c *Cache) lookup(query string, opts LookupOptions) ([]Match, error) {
+	if dx, err := c.OpenDiskIndex(); err == nil {
+		defer dx.Close()
+		return dx.LookupWith(query, opts)
+	}
+
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return nil, ErrNoCache
+	}
+
+	prov := c.loadProvenance()
+
+	if opts != (LookupOptions{}) {
+		match, err := opts.matcher(query)
+		if err != nil {
+			return nil, err
+		}
+		var exact, partial []Match
+		for banner, urls := range banners.Linux {
+			switch ok, isExact := match(banner); {
+			case isExact:
+				exact = append(exact, Match{Banner: banner, URLs: urls, Sources: prov[banner], Exact: true})
+			case ok:
+				partial = append(partial, Match{Banner: banner, URLs: urls, Sources: prov[banner]})
+			}
+		}
+		return bestMatches(exact, partial), nil
+	}
+
+	if urls, ok := banners.Linux[query]; ok {
+		return []Match{{Banner: query, URLs: urls, Sources: prov[query], Exact: true}}, nil
+	}
+
+	var matches []Match
+	for banner, urls := range banners.Linux {
+		if strings.Contains(banner, query) {
+			matches = append(matches, Match{Banner: banner, URLs: urls, Sources: prov[banner]})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Banner < matches[j].Banner
+	})
+
+	return matches, nil
+}
+
+// bestMatches returns the exact matches if there are any and the partial
+// ones otherwise, sorted.
+func bestMatches(exact, partial []Match) []Match {
+	matches := partial
+	if len(exact) > 0 {
+		matches = exact
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Banner < matches[j].Banner
+	})
+	return matches
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestLookup(t *testing.T) {
+	cfg := testConfig(t)
+	createTestBannerFile(t, cfg.CacheFile)
+
+	c := New(cfg)
+	if err := c.saveProvenance(fetcher.Provenance{
+		"Linux version 5.15.0-generic": {"https://example.com/a.json"},
+	}); err != nil {
+		t.Fatalf("saveProvenance() failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+		wantExact bool
+	}{
+		{"exact match", "Linux version 5.15.0-generic", 1, true},
+		{"substring match", "generic", 2, false},
+		{"no match", "freebsd", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := c.Lookup(tt.query)
+			if err != nil {
+				t.Fatalf("Lookup(%q) failed: %v", tt.query, err)
+			}
+			if len(matches) != tt.wantCount {
+				t.Fatalf("Lookup(%q) returned %d matches, expected %d", tt.query, len(matches), tt.wantCount)
+			}
+			if tt.wantCount > 0 && matches[0].Exact != tt.wantExact {
+				t.Errorf("Lookup(%q) Exact = %v, expected %v", tt.query, matches[0].Exact, tt.wantExact)
+			}
+		})
+	}
+
+	matches, _ := c.Lookup("Linux version 5.15.0-generic")
+	if len(matches[0].Sources) != 1 {
+		t.Errorf("exact match should carry provenance, got %v", matches[0].Sources)
+	}
+}
+
+func TestLookupNoCache(t *testing.T) {
+	c := New(testConfig(t))
+
+	if _, err := c.Lookup("anything"); !errors.Is(err, ErrNoCache) {
+		t.Errorf("Lookup() error = %v, expected ErrNoCache", err)
+	}
+}
+
+func TestLookupWith(t *testing.T) {
+	tests := []struct {
+		query string
+		opts  LookupOptions
+		want  []string
+		exact bool
+	}{
+		{"LINUX VERSION 6.1", LookupOptions{IgnoreCase: true}, []string{"Linux version 6.1.0-13-amd64"}, false},
+		{"linux version 5.15.0", LookupOptions{IgnoreCase: true}, []string{"Linux version 5.15.0"}, true},
+		{`5\.15\.0-9[12]`, LookupOptions{Regexp: true}, []string{"Linux version 5.15.0-91-generic", "Linux version 5.15.0-92-generic"}, false},
+		{`Linux version \d+\.\d+\.\d+`, LookupOptions{Regexp: true}, []string{"Linux version 5.15.0"}, true},
+		{"5.15.0", LookupOptions{Release: true}, []string{"Linux version 5.15.0"}, true},
+		{"5.15.0-9.-GENERIC", LookupOptions{Release: true, Regexp: true, IgnoreCase: true},
+			[]string{"Linux version 5.15.0-91-generic", "Linux version 5.15.0-92-generic"}, true},
+		{"5.15", LookupOptions{Release: true}, nil, false},
+		{"5.15.0-9.", LookupOptions{}, nil, false}, // literal by default
+	}
+
+	c := writeDiskIndexCache(t)
+	for _, index := range []string{"disk index", "cache"} {
+		if index == "cache" {
+			if err := os.Remove(c.diskIndexPath()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tt := range tests {
+			matches, err := c.LookupWith(tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("%s: LookupWith(%q, %+v) failed: %v", index, tt.query, tt.opts, err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.Banner)
+				if m.Exact != tt.exact {
+					t.Errorf("%s: LookupWith(%q, %+v) Exact = %v for %s", index, tt.query, tt.opts, m.Exact, m.Banner)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: LookupWith(%q, %+v) = %q, expected %q", index, tt.query, tt.opts, got, tt.want)
+			}
+		}
+		if _, err := c.LookupWith("(", LookupOptions{Regexp: true}); err == nil {
+			t.Errorf("%s: LookupWith() of an invalid regexp should fail", index)
+		}
+	}
+}
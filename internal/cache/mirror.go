@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// MirrorResult describes a Mirror run.
+type MirrorResult struct {
+	// Dest is the mirror directory and Index the copy of the cache in it
+	// pointing at the mirrored files, with IndexURI its file:// URI.
+	Dest     string `json:"dest"`
+	Index    string `json:"index"`
+	IndexURI string `json:"index_uri"`
+
+	// Banners counts the selected banners with a symbol file available
+	// offline, Downloaded the files fetched by this run and Bytes their
+	// size.
+	Banners    int   `json:"banners"`
+	Downloaded int   `json:"downloaded"`
+	Bytes      int64 `json:"bytes"`
+
+	// Failed lists the selected banners none of whose URLs could be
+	// downloaded, with the last error.
+	Failed []MirrorFailure `json:"failed,omitempty"`
+}
+
+// MirrorFailure is a banner Mirror could not fetch a symbol file for.
+type MirrorFailure struct {
+	Banner string `json:"banner"`
+	URL    string `json:"url"`
+	Error  string `json:"error"`
+}
+
+// Mirror downloads the symbol files of the banners matching any of
+// queries (every banner when there are none) into dest, the mirror
+// directory of the cache when empty, and writes dest/banners.json: a copy
+// of the cache listing each mirrored file as a file:// URL ahead of the
+// banner's remote URLs, for volatility3 on machines without network
+// access. For each banner the first URL that downloads is kept; files
+// already in the mirror are not fetched again.
+func (c *Cache) Mirror(ctx context.Context, dest string, queries []string) (*MirrorResult, error) {
+	if c.cfg.Offline {
+		return nil, fetcher.ErrOffline
+	}
+	if dest == "" {
+		dest = c.mirrorDir()
+	}
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return nil, ErrNoCache
+	}
+
+	selected, err := c.selectBanners(banners, queries)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dest, DirMode); err != nil {
+		return nil, fmt.Errorf("creating mirror dir: %w", err)
+	}
+
+	res := &MirrorResult{Dest: dest, Index: filepath.Join(dest, "banners.json")}
+	res.IndexURI = fileURI(res.Index)
+	local := make([]string, len(selected))
+	isLocal := make([]bool, len(selected))
+	failures := make([]*MirrorFailure, len(selected))
+	var downloaded, size atomic.Int64
+	parallel(len(selected), c.cfg.Jobs, func(i int) {
+		banner := selected[i]
+		var last MirrorFailure
+		for _, u := range banners.Linux[banner] {
+			if fetcher.IsLocal(u) {
+				// Already available without network access
+				isLocal[i] = true
+				return
+			}
+			file, err := mirrorPath(dest, u)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(file); err == nil {
+				local[i] = file
+				return
+			}
+			n, err := c.download(ctx, u, file)
+			if err != nil {
+				last = MirrorFailure{banner, u, err.Error()}
+				continue
+			}
+			downloaded.Add(1)
+			size.Add(n)
+			local[i] = file
+			return
+		}
+		if last.URL == "" {
+			last = MirrorFailure{Banner: banner, Error: "no downloadable URL"}
+		}
+		failures[i] = &last
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	res.Downloaded, res.Bytes = int(downloaded.Load()), size.Load()
+
+	index := &fetcher.BannerData{Version: banners.Version, Linux: make(map[string][]string, len(banners.Linux))}
+	for banner, urls := range banners.Linux {
+		index.Linux[banner] = urls
+	}
+	for i, banner := range selected {
+		switch {
+		case local[i] != "":
+			index.Linux[banner] = append([]string{fileURI(local[i])}, banners.Linux[banner]...)
+		case !isLocal[i]:
+			res.Failed = append(res.Failed, *failures[i])
+			continue
+		}
+		res.Banners++
+	}
+	index, _, err = c.forVol3(index)
+	if err != nil {
+		return res, err
+	}
+	if err := writeBanners(res.Index, index); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// selectBanners returns the banners matching any of queries, or all of
+// them when there are none, sorted.
+func (c *Cache) selectBanners(banners *fetcher.BannerData, queries []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var selected []string
+	if len(queries) == 0 {
+		for banner := range banners.Linux {
+			selected = append(selected, banner)
+		}
+	}
+	for _, q := range queries {
+		matches, err := c.Lookup(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !seen[m.Banner] {
+				seen[m.Banner] = true
+				selected = append(selected, m.Banner)
+			}
+		}
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// download fetches rawURL into file via a temp file, so an interrupted
+// download never passes for a mirrored one. It authenticates with the
+// credentials of the first source on the same server, if any.
+func (c *Cache) download(ctx context.Context, rawURL, file string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(file), DirMode); err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(file), ".download-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.fetcher.Download(ctx, c.serverSource(rawURL), rawURL, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// errNotMirrorable indicates a symbol URL Mirror does not download.
+var errNotMirrorable = errors.New("not an HTTP URL")
+
+// mirrorPath returns where the file at rawURL is mirrored in dest: under
+// the host, at the URL's path.
+func mirrorPath(dest, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errNotMirrorable
+	}
+	p := path.Clean("/" + u.Path)
+	if p == "/" {
+		return "", errNotMirrorable
+	}
+	host := strings.ReplaceAll(u.Host, ":", "_")
+	return filepath.Join(dest, host, filepath.FromSlash(p)), nil
+}
+
+// serverSource returns the first source on the same server as rawURL,
+// whose credentials its download uses, or "" for none.
+func (c *Cache) serverSource(rawURL string) string {
+	for _, source := range c.cfg.Sources {
+		if fetcher.SameHost(source, rawURL) {
+			return source
+		}
+	}
+	return ""
+}
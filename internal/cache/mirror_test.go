@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestMirror(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/missing.json.xz" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("isf:" + r.URL.Path))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cache := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0-ubuntu": {server.URL + "/missing.json.xz", server.URL + "/ubuntu/5.15.json.xz"},
+		"Linux version 6.1.0-debian":  {server.URL + "/debian/6.1.json.xz"},
+		"Linux version 4.19.0-gone":   {server.URL + "/missing.json.xz"},
+		"Linux version 3.10.0-local":  {"file:///srv/symbols/3.10.json"},
+	}}
+	if err := writeBanners(cfg.CacheFile, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(cfg)
+	res, err := c.Mirror(context.Background(), "", nil)
+	if err != nil {
+		t.Fatalf("Mirror() failed: %v", err)
+	}
+	if res.Banners != 3 || res.Downloaded != 2 || len(res.Failed) != 1 || res.Failed[0].Banner != "Linux version 4.19.0-gone" {
+		t.Errorf("Mirror() = %+v, expected 3 banners, 2 downloads, and 4.19.0 failing", res)
+	}
+	if res.Dest != c.mirrorDir() {
+		t.Errorf("Dest = %q, expected the cache's mirror dir", res.Dest)
+	}
+
+	host := strings.ReplaceAll(strings.TrimPrefix(server.URL, "http://"), ":", "_")
+	file := filepath.Join(res.Dest, host, "ubuntu", "5.15.json.xz")
+	if data, err := os.ReadFile(file); err != nil || string(data) != "isf:/ubuntu/5.15.json.xz" {
+		t.Errorf("mirrored file = %q, %v", data, err)
+	}
+
+	raw, err := os.ReadFile(res.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index fetcher.BannerData
+	if err := json.Unmarshal(raw, &index); err != nil {
+		t.Fatal(err)
+	}
+	urls := index.Linux["Linux version 5.15.0-ubuntu"]
+	if len(urls) != 3 || urls[0] != fileURI(file) {
+		t.Errorf("rewritten URLs = %v, expected the local file first", urls)
+	}
+	if got := index.Linux["Linux version 4.19.0-gone"]; len(got) != 1 {
+		t.Errorf("a banner that failed should keep its URLs, got %v", got)
+	}
+
+	// Mirrored files are not downloaded again, and matches restrict the
+	// banners
+	before := hits
+	res, err = c.Mirror(context.Background(), "", []string{"debian"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Banners != 1 || res.Downloaded != 0 || hits != before {
+		t.Errorf("second Mirror() = %+v with %d requests, expected 1 banner and none", res, hits-before)
+	}
+}
+
+func TestMirrorAuthenticated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("isf"))
+	}))
+	defer server.Close()
+
+	t.Setenv("ISF_TOKEN", "s3cret")
+	cfg := testConfig(t)
+	source := server.URL + "/banners.json"
+	cfg.Sources = []string{"https://other.example/banners.json", source}
+	cfg.Options = map[string]config.SourceOptions{source: {TokenEnv: "ISF_TOKEN"}}
+	cache := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 6.1.0-internal": {server.URL + "/internal/6.1.json.xz"},
+	}}
+	if err := writeBanners(cfg.CacheFile, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New(cfg).Mirror(context.Background(), "", nil)
+	if err != nil || res.Downloaded != 1 || len(res.Failed) != 0 {
+		t.Errorf("Mirror() from a server requiring the source's token = %+v, %v", res, err)
+	}
+}
+
+func TestMirrorErrors(t *testing.T) {
+	cfg := testConfig(t)
+	if _, err := New(cfg).Mirror(context.Background(), "", nil); !errors.Is(err, ErrNoCache) {
+		t.Errorf("Mirror() without a cache error = %v, expected ErrNoCache", err)
+	}
+
+	cfg.Offline = true
+	if _, err := New(cfg).Mirror(context.Background(), "", nil); !errors.Is(err, fetcher.ErrOffline) {
+		t.Errorf("Mirror() offline error = %v, expected ErrOffline", err)
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://example.com/linux/5.15.json.xz", filepath.Join("m", "example.com", "linux", "5.15.json.xz")},
+		{"http://example.com:8080/a/../../b.json", filepath.Join("m", "example.com_8080", "b.json")},
+		{"https://example.com/", ""},
+		{"ftp://example.com/a.json", ""},
+	}
+	for _, tt := range tests {
+		got, err := mirrorPath("m", tt.url)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("mirrorPath(%q) = %q, expected an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("mirrorPath(%q) = %q, %v; expected %q", tt.url, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+	"github.com/calilkhalil/basar/internal/notify"
+)
+
+// updateEvents returns the notification events of a finished update with
+// the given status.
+func updateEvents(res *UpdateResult, err error, status string, at time.Time) []notify.Event {
+	host, _ := os.Hostname()
+	base := notify.Event{At: at, Host: host, Status: status, Generation: res.Generation, Entries: res.EntriesAfter}
+
+	var events []notify.Event
+	if err != nil {
+		e := base
+		e.Kind = config.EventUpdateFailure
+		e.Error = err.Error()
+		e.Summary = "basar update failed: " + e.Error
+		events = append(events, e)
+	} else if res.Updated {
+		e := base
+		e.Kind = config.EventUpdateSuccess
+		e.Summary = fmt.Sprintf("basar cache updated: %d banners (%d added, %d removed)",
+			res.EntriesAfter, len(res.added), len(res.removed))
+		events = append(events, e)
+	}
+	if len(res.added) > 0 {
+		e := base
+		e.Kind = config.EventNewBanners
+		e.Added = res.added
+		e.Summary = fmt.Sprintf("basar found %d new banners", len(res.added))
+		events = append(events, e)
+	}
+	if digest := updateDigest(base, res, err); digest != nil {
+		events = append(events, *digest)
+	}
+	return events
+}
+
+// updateDigest returns the digest of an unattended update, nil if it was
+// interactive or changed nothing without a failure.
+func updateDigest(e notify.Event, res *UpdateResult, err error) *notify.Event {
+	if !res.unattended || (err == nil && !res.Updated && !res.Partial()) {
+		return nil
+	}
+	e.Kind = config.EventUpdateDigest
+	e.Added = res.added
+	e.Removed = res.removed
+	for _, s := range res.Sources {
+		if s.Status == fetcher.StatusError {
+			e.FailedSources = append(e.FailedSources, s.Source+": "+s.Error)
+		}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	e.Summary = fmt.Sprintf("basar update on %s: %s, %d banners", e.Host, e.Status, e.Entries)
+	if len(e.Added)+len(e.Removed) > 0 {
+		e.Summary += fmt.Sprintf(" (%d added, %d removed)", len(e.Added), len(e.Removed))
+	}
+	if n := len(e.FailedSources); n > 0 {
+		e.Summary += fmt.Sprintf(", %d of %d sources failed", n, len(res.Sources))
+	}
+	return &e
+}
+
+// notify sends events to the configured targets that route them. A failed
+// notification is only logged: it must not fail the update it reports.
+func (c *Cache) notify(ctx context.Context, events []notify.Event) {
+	if len(c.cfg.Notify) == 0 {
+		return
+	}
+	// An interrupted update is worth reporting, so a cancelled ctx only
+	// passes on its values
+	ctx = context.WithoutCancel(ctx)
+
+	for _, e := range events {
+		for _, t := range c.cfg.Notify {
+			if !t.Routes(e.Kind) {
+				continue
+			}
+			if err := c.sendNotification(ctx, t, e); err != nil {
+				c.log.Warn("notification failed", "backend", t.Backend, "event", e.Kind, "error", err)
+			}
+		}
+	}
+}
+
+// sendNotification sends e to one target, within notify.Timeout.
+func (c *Cache) sendNotification(ctx context.Context, t config.NotifyTarget, e notify.Event) error {
+	n, err := notify.New(t.Backend, t.Target, t.Options)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notify.Timeout)
+	defer cancel()
+	return n.Notify(ctx, e)
+}
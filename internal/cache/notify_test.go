@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/notify"
+)
+
+func TestUpdateNotifies(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][]string) // target path -> events
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e struct{ Event string }
+		json.NewDecoder(r.Body).Decode(&e)
+		mu.Lock()
+		got[r.URL.Path] = append(got[r.URL.Path], e.Event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	if err := os.WriteFile(source, []byte(`{"version":1,"linux":{"banner1":["https://example.com/b1.json"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{source}
+	cfg.ShrinkThreshold = 0
+	cfg.Notify = []config.NotifyTarget{
+		{Backend: "webhook", Target: srv.URL + "/all"},
+		{Backend: "webhook", Target: srv.URL + "/failures", Events: []string{config.EventUpdateFailure}},
+		{Backend: "webhook", Target: srv.URL + "/new", Events: []string{config.EventNewBanners}},
+	}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if len(got["/all"]) != 2 || got["/all"][0] != config.EventUpdateSuccess || got["/all"][1] != config.EventNewBanners {
+		t.Errorf("/all got %q, expected update-success and new-banners", got["/all"])
+	}
+	if len(got["/failures"]) != 0 || len(got["/new"]) != 1 {
+		t.Errorf("got %q", got)
+	}
+
+	// A failed update goes to the failure targets only
+	clear(got)
+	cfg.Sources = []string{filepath.Join(cfg.ConfigDir, "missing.json")}
+	if _, err := New(cfg).Update(context.Background(), true); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	if len(got["/failures"]) != 1 || len(got["/all"]) != 1 || len(got["/new"]) != 0 {
+		t.Errorf("after a failure got %q", got)
+	}
+}
+
+func TestSmartUpdateDigest(t *testing.T) {
+	var mu sync.Mutex
+	var got []notify.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e notify.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	if err := os.WriteFile(source, []byte(`{"version":1,"linux":{"banner1":["https://example.com/b1.json"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(cfg.ConfigDir, "missing.json")
+	cfg.Sources = []string{source, missing}
+	cfg.ShrinkThreshold = 0
+	cfg.Notify = []config.NotifyTarget{
+		{Backend: "webhook", Target: srv.URL},
+		{Backend: "webhook", Target: srv.URL, Events: []string{config.EventUpdateDigest}},
+	}
+
+	// An interactive update sends no digest
+	if _, err := New(cfg).Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range got {
+		if e.Kind == config.EventUpdateDigest {
+			t.Errorf("Update() sent a digest: %+v", e)
+		}
+	}
+
+	got = nil
+	if err := os.WriteFile(source, []byte(`{"version":1,"linux":{"banner2":["https://example.com/b2.json"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg).SmartUpdate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var digests []notify.Event
+	for _, e := range got {
+		if e.Kind == config.EventUpdateDigest {
+			digests = append(digests, e)
+		}
+	}
+	if len(digests) != 1 {
+		t.Fatalf("SmartUpdate() sent %d digests, expected one to the target listing them", len(digests))
+	}
+	d := digests[0]
+	if d.Status != UpdateUpdated || len(d.Added) != 1 || d.Added[0] != "banner2" ||
+		len(d.Removed) != 1 || len(d.FailedSources) != 1 || !strings.HasPrefix(d.FailedSources[0], missing+": ") {
+		t.Errorf("digest = %+v", d)
+	}
+}
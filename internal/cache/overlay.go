@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrInvalidOverlay indicates an overlay file that cannot be parsed.
+var ErrInvalidOverlay = errors.New("invalid overlay file")
+
+// Layered returns the cache to read in place of base, this cache or the
+// system-wide one: base itself without an overlay file, or else a view
+// listing the overlay's banners and URLs ahead of base's. The view is
+// written to this cache's directory, never base's, and rebuilt when either
+// layer changes: it takes the modification time of the newer one, so its
+// age is that of its data. State such as provenance is read from base.
+func (c *Cache) Layered(base *Cache) (*Cache, error) {
+	overlay, err := os.Stat(c.cfg.OverlayFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return base, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	below, err := os.Stat(base.cfg.CacheFile)
+	if err != nil {
+		return nil, ErrNoCache
+	}
+
+	cfg := *base.cfg
+	cfg.CacheFile = c.cfg.LayeredFile
+	view := New(&cfg)
+	view.SetLogger(c.log)
+
+	stamp := below.ModTime()
+	if overlay.ModTime().After(stamp) {
+		stamp = overlay.ModTime()
+	}
+	if info, err := os.Stat(c.cfg.LayeredFile); err == nil && info.ModTime().Equal(stamp) {
+		return view, nil
+	}
+
+	top, err := c.loadOverlay()
+	if err != nil {
+		return nil, err
+	}
+	bottom := base.loadExistingBanners()
+	if bottom == nil {
+		return nil, ErrNoCache
+	}
+	merged := fetcher.Merge([]*fetcher.BannerData{top, bottom})
+	merged.Version = bottom.Version
+	merged, _, err = c.forVol3(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(c.cfg.CacheDir, DirMode); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+	if err := writeBanners(c.cfg.LayeredFile, merged); err != nil {
+		return nil, err
+	}
+	if err := os.Chtimes(c.cfg.LayeredFile, time.Now(), stamp); err != nil {
+		return nil, err
+	}
+	c.log.Info("layered the overlay over the cache", "overlay", c.cfg.OverlayFile,
+		"base", base.cfg.CacheFile, "banners", len(top.Linux))
+	return view, nil
+}
+
+// loadOverlay reads the overlay file, in the format of banners.json.
+func (c *Cache) loadOverlay() (*fetcher.BannerData, error) {
+	raw, err := os.ReadFile(c.cfg.OverlayFile)
+	if err != nil {
+		return nil, err
+	}
+	var data fetcher.BannerData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidOverlay, c.cfg.OverlayFile, err)
+	}
+	return &data, nil
+}
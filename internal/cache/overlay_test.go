@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLayered(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.OverlayFile = filepath.Join(cfg.ConfigDir, "overlay.json")
+	cfg.LayeredFile = filepath.Join(cfg.CacheDir, "layered.json")
+	c := New(cfg)
+
+	// A base in another directory, as the system-wide cache is
+	baseCfg := testConfig(t)
+	base := New(baseCfg)
+	createTestBannerFile(t, baseCfg.CacheFile)
+	baseRaw, _ := os.ReadFile(baseCfg.CacheFile)
+
+	if got, err := c.Layered(base); err != nil || got != base {
+		t.Fatalf("Layered() without an overlay = %v, %v; expected the base", got, err)
+	}
+
+	overlay := `{"version": 1, "linux": {
+		"Linux version 6.1.0-generic": ["file:///home/me/isf/6.1.0.json.xz"],
+		"Linux version 6.9.0-custom": ["file:///home/me/isf/6.9.0.json.xz"]}}`
+	if err := os.WriteFile(cfg.OverlayFile, []byte(overlay), 0644); err != nil {
+		t.Fatal(err)
+	}
+	view, err := c.Layered(base)
+	if err != nil {
+		t.Fatalf("Layered() failed: %v", err)
+	}
+	if path, ok := view.Path(); !ok || path != cfg.LayeredFile {
+		t.Errorf("view path = %q, %v; expected %s", path, ok, cfg.LayeredFile)
+	}
+	banners := view.loadExistingBanners()
+	if banners == nil || len(banners.Linux) != 3 {
+		t.Fatalf("view = %v, expected the base's 2 banners and the custom one", banners)
+	}
+	if urls := banners.Linux["Linux version 6.1.0-generic"]; len(urls) != 2 || urls[0] != "file:///home/me/isf/6.1.0.json.xz" {
+		t.Errorf("overlaid banner URLs = %v, expected the overlay's first", urls)
+	}
+	if raw, _ := os.ReadFile(baseCfg.CacheFile); string(raw) != string(baseRaw) {
+		t.Error("Layered() must not modify the base")
+	}
+
+	// Unchanged layers reuse the view
+	stamp, _ := os.Stat(cfg.LayeredFile)
+	if err := os.WriteFile(cfg.LayeredFile, []byte(`{"version": 1, "linux": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cfg.LayeredFile, time.Now(), stamp.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := c.Layered(base); len(view.loadExistingBanners().Linux) != 0 {
+		t.Error("Layered() should reuse a view as recent as its layers")
+	}
+
+	// A newer base is layered again
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(baseCfg.CacheFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	view, err = c.Layered(base)
+	if err != nil || len(view.loadExistingBanners().Linux) != 3 {
+		t.Errorf("Layered() after the base changed = %v, expected a rebuilt view", err)
+	}
+	if info, _ := os.Stat(cfg.LayeredFile); !info.ModTime().Equal(later) {
+		t.Errorf("view modified %v, expected the newer layer's time %v", info.ModTime(), later)
+	}
+	if matches, err := view.Lookup("6.9.0-custom"); err != nil || len(matches) != 1 || !slices.Contains(matches[0].URLs, "file:///home/me/isf/6.9.0.json.xz") {
+		t.Errorf("Lookup() in the view = %v, %v", matches, err)
+	}
+
+	if err := os.WriteFile(cfg.OverlayFile, []byte(`{"linux": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cfg.OverlayFile, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Layered(base); !errors.Is(err, ErrInvalidOverlay) {
+		t.Errorf("Layered() with an invalid overlay error = %v, expected ErrInvalidOverlay", err)
+	}
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrInvalidOverrides indicates an overrides file that cannot be parsed.
+var ErrInvalidOverrides = errors.New("invalid overrides file")
+
+// BannerOverride is a local change to the URLs of one banner, applied
+// after merging the sources. URLs are listed ahead of the merged ones;
+// with Pin they replace them. Remove drops merged URLs, for example a
+// broken upstream file. An override that leaves no URL removes the banner.
+type BannerOverride struct {
+	URLs   []string `json:"urls,omitempty"`
+	Pin    bool     `json:"pin,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// UnmarshalJSON accepts a plain list of URLs as well as an object, so
+// the common case reads like banners.json.
+func (o *BannerOverride) UnmarshalJSON(raw []byte) error {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		*o = BannerOverride{}
+		return json.Unmarshal(trimmed, &o.URLs)
+	}
+	type plain BannerOverride
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(o))
+}
+
+// overridesFile is the format of overrides.json: banners mapped to their
+// override, or to null to remove them.
+type overridesFile struct {
+	Linux map[string]*BannerOverride `json:"linux"`
+}
+
+// loadOverrides reads the overrides file, returning nil if there is none.
+func (c *Cache) loadOverrides() (map[string]*BannerOverride, error) {
+	raw, err := os.ReadFile(c.cfg.OverridesFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file overridesFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidOverrides, c.cfg.OverridesFile, err)
+	}
+	return file.Linux, nil
+}
+
+// applyOverrides applies the overrides file to merged data, attributing
+// the URLs it adds to the file in prov. It fails on an invalid file rather
+// than publishing a cache without the analyst's fixes.
+func (c *Cache) applyOverrides(data *fetcher.BannerData, prov fetcher.Provenance) error {
+	overrides, err := c.loadOverrides()
+	if err != nil || len(overrides) == 0 {
+		return err
+	}
+
+	for banner, o := range overrides {
+		if o == nil {
+			delete(data.Linux, banner)
+			delete(prov, banner)
+			continue
+		}
+
+		removed := make(map[string]bool, len(o.Remove))
+		for _, u := range o.Remove {
+			removed[u] = true
+		}
+		var urls []string
+		seen := make(map[string]bool)
+		for _, u := range o.URLs {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		if !o.Pin {
+			for _, u := range data.Linux[banner] {
+				if !seen[u] && !removed[u] {
+					seen[u] = true
+					urls = append(urls, u)
+				}
+			}
+		}
+
+		if len(urls) == 0 {
+			delete(data.Linux, banner)
+			delete(prov, banner)
+			continue
+		}
+		data.Linux[banner] = urls
+		if len(o.URLs) > 0 {
+			prov[banner] = append(prov[banner], c.cfg.OverridesFile)
+		}
+	}
+
+	c.log.Info("applied overrides", "path", c.cfg.OverridesFile, "banners", len(overrides))
+	return nil
+}
+
+// overridesChecksum returns the checksum of the overrides file, empty if
+// there is none.
+func (c *Cache) overridesChecksum() string {
+	sum, _ := fileChecksum(c.cfg.OverridesFile)
+	return sum
+}
+
+// needsRewrite reports whether the cache must be rewritten although no
+// source changed: it was tailored to another volatility3 release, the
+// overrides or filters files changed since it was written, or a tombstone
+// expired.
+func (c *Cache) needsRewrite(meta *fetcher.MetaCache) bool {
+	return meta.Vol3Compat != c.cfg.Vol3Compat || meta.Overrides != c.overridesChecksum() ||
+		meta.Filters != c.filtersChecksum() || c.tombstonesExpired()
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	cfg := testConfig(t)
+	overrides := `{"linux": {
+		"banner1": ["https://isf.example/b1.json.xz", "https://up.example/b1.json.xz"],
+		"banner2": {"urls": ["file:///srv/isf/b2.json"], "pin": true},
+		"banner3": {"remove": ["https://up.example/b3-broken.json.xz"]},
+		"banner4": null,
+		"banner5": {"remove": ["https://up.example/b5.json.xz"]},
+		"banner6": ["https://isf.example/b6.json.xz"]
+	}}`
+	if err := os.WriteFile(cfg.OverridesFile, []byte(overrides), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"banner1": {"https://up.example/b1.json.xz", "https://other.example/b1.json.xz"},
+		"banner2": {"https://up.example/b2.json.xz"},
+		"banner3": {"https://up.example/b3-broken.json.xz", "https://up.example/b3.json.xz"},
+		"banner4": {"https://up.example/b4.json.xz"},
+		"banner5": {"https://up.example/b5.json.xz"},
+	}}
+	prov := fetcher.Provenance{"banner1": {"upstream"}, "banner4": {"upstream"}}
+	if err := New(cfg).applyOverrides(data, prov); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"banner1": {"https://isf.example/b1.json.xz", "https://up.example/b1.json.xz", "https://other.example/b1.json.xz"},
+		"banner2": {"file:///srv/isf/b2.json"},
+		"banner3": {"https://up.example/b3.json.xz"},
+		"banner6": {"https://isf.example/b6.json.xz"},
+	}
+	if len(data.Linux) != len(want) {
+		t.Errorf("banners = %v, expected %v", data.Linux, want)
+	}
+	for banner, urls := range want {
+		if got := data.Linux[banner]; !reflect.DeepEqual(got, urls) {
+			t.Errorf("%s URLs = %v, expected %v", banner, got, urls)
+		}
+	}
+	if got := prov["banner1"]; !reflect.DeepEqual(got, []string{"upstream", cfg.OverridesFile}) {
+		t.Errorf("banner1 provenance = %v", got)
+	}
+	if _, ok := prov["banner4"]; ok {
+		t.Error("provenance kept a removed banner")
+	}
+}
+
+func TestApplyOverridesInvalid(t *testing.T) {
+	cfg := testConfig(t)
+	data := &fetcher.BannerData{Version: 1, Linux: map[string][]string{"banner1": {"u"}}}
+	for _, content := range []string{`{"linux": `, `{"linux": {"banner1": {"url": ["typo"]}}}`} {
+		if err := os.WriteFile(cfg.OverridesFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := New(cfg).applyOverrides(data, fetcher.Provenance{}); !errors.Is(err, ErrInvalidOverrides) {
+			t.Errorf("applyOverrides(%s) error = %v, expected ErrInvalidOverrides", content, err)
+		}
+	}
+}
+
+func TestSmartUpdateAppliesOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://up.example/b1.json.xz"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL}
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source is unchanged, but the new overrides file is applied
+	if err := os.WriteFile(cfg.OverridesFile, []byte(`{"linux":{"banner1":{"urls":["https://isf.example/b1.json"],"pin":true}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.SmartUpdate(context.Background())
+	if err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+	matches, _ := c.Lookup("banner1")
+	if !res.Updated || len(matches) != 1 || !reflect.DeepEqual(matches[0].URLs, []string{"https://isf.example/b1.json"}) {
+		t.Errorf("SmartUpdate() with overrides: updated %v, matches %+v", res.Updated, matches)
+	}
+
+	if res, err := c.SmartUpdate(context.Background()); err != nil || res.Updated {
+		t.Errorf("second SmartUpdate() = %+v, %v; expected no change", res, err)
+	}
+
+	// Removing the file restores the upstream URLs
+	if err := os.Remove(cfg.OverridesFile); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := c.SmartUpdate(context.Background()); err != nil || !res.Updated {
+		t.Fatalf("SmartUpdate() after removing overrides = %+v, %v", res, err)
+	}
+	matches, _ = c.Lookup("banner1")
+	if len(matches) != 1 || !reflect.DeepEqual(matches[0].URLs, []string{"https://up.example/b1.json.xz"}) {
+		t.Errorf("matches after removing overrides = %+v", matches)
+	}
+}
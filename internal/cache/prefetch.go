@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrNoKernelBanner indicates the banner of the running kernel could not
+// be determined.
+var ErrNoKernelBanner = errors.New("cannot determine the running kernel's banner")
+
+// procVersion holds the banner of the running Linux kernel.
+var procVersion = "/proc/version"
+
+// uname returns the output of uname with args; tests replace it.
+var uname = func(args ...string) (string, error) {
+	out, err := exec.Command("uname", args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// KernelBanner returns a query for the banner of the running kernel: the
+// banner itself from /proc/version, or, where that cannot be read, the
+// "Linux version RELEASE (" prefix of it built from uname, which matches
+// every build of that release.
+func KernelBanner() (string, error) {
+	if data, err := os.ReadFile(procVersion); err == nil {
+		if banner := strings.TrimSpace(string(data)); strings.HasPrefix(banner, "Linux version ") {
+			return banner, nil
+		}
+	}
+	sys, err := uname("-s")
+	if err != nil {
+		return "", fmt.Errorf("%w: uname: %v", ErrNoKernelBanner, err)
+	}
+	if sys != "Linux" {
+		return "", fmt.Errorf("%w: not running Linux (%s)", ErrNoKernelBanner, sys)
+	}
+	release, err := uname("-r")
+	if err != nil || release == "" {
+		return "", fmt.Errorf("%w: uname -r: %v", ErrNoKernelBanner, err)
+	}
+	return "Linux version " + release + " (", nil
+}
+
+// vol3SymbolsDir asks python for the directory of the volatility3.symbols
+// package, where volatility3 looks for symbol files without -s; tests
+// replace it.
+var vol3SymbolsDir = func() (string, error) {
+	const script = "import volatility3.symbols as s; print(list(s.__path__)[0])"
+	pythons := []string{"python3", "python"}
+	if runtime.GOOS == "windows" {
+		pythons = []string{"python", "py"}
+	}
+	var err error
+	for _, python := range pythons {
+		var out []byte
+		if out, err = exec.Command(python, "-c", script).Output(); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", fmt.Errorf("volatility3 not found: %w", err)
+}
+
+// Volatility3SymbolsDir returns the symbols directory of the volatility3
+// installed for the default python.
+func Volatility3SymbolsDir() (string, error) {
+	return vol3SymbolsDir()
+}
+
+// PrefetchResult describes a Prefetch run.
+type PrefetchResult struct {
+	// Dir is the symbols directory; the files go in its linux
+	// subdirectory, where volatility3 looks for Linux symbols.
+	Dir string `json:"dir"`
+
+	// Files lists a symbol file for each matched banner, Downloaded counts
+	// those fetched by this run and Bytes their size.
+	Files      []PrefetchFile `json:"files"`
+	Downloaded int            `json:"downloaded"`
+	Bytes      int64          `json:"bytes"`
+
+	// Unmatched lists the queries no cached banner matches, and Failed the
+	// matched banners none of whose URLs could be downloaded.
+	Unmatched []string        `json:"unmatched,omitempty"`
+	Failed    []MirrorFailure `json:"failed,omitempty"`
+}
+
+// PrefetchFile is the symbol file of a banner in the symbols directory.
+type PrefetchFile struct {
+	Banner string `json:"banner"`
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+}
+
+// Prefetch downloads the symbol files of the cached banners matching
+// queries into dir/linux, dir being the volatility3 symbols directory when
+// empty, so volatility3 finds them without network access or a remote
+// ISF. A query matches like Lookup, ignoring the trailing newline and NUL
+// a banner read from memory ends with. For each banner the first URL that
+// downloads is kept; files already in the directory are not fetched again.
+func (c *Cache) Prefetch(ctx context.Context, dir string, queries []string) (*PrefetchResult, error) {
+	if c.cfg.Offline {
+		return nil, fetcher.ErrOffline
+	}
+	if c.loadExistingBanners() == nil {
+		return nil, ErrNoCache
+	}
+	if dir == "" {
+		var err error
+		if dir, err = Volatility3SymbolsDir(); err != nil {
+			return nil, fmt.Errorf("locating the volatility3 symbols directory: %w", err)
+		}
+	}
+
+	res := &PrefetchResult{Dir: dir}
+	var selected []Match
+	seen := make(map[string]bool)
+	for _, q := range queries {
+		matches, err := c.Lookup(strings.TrimRight(q, "\x00\r\n "))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			res.Unmatched = append(res.Unmatched, q)
+		}
+		for _, m := range matches {
+			if !seen[m.Banner] {
+				seen[m.Banner] = true
+				selected = append(selected, m)
+			}
+		}
+	}
+
+	linux := filepath.Join(dir, "linux")
+	files := make([]*PrefetchFile, len(selected))
+	failures := make([]MirrorFailure, len(selected))
+	var downloaded, size atomic.Int64
+	parallel(len(selected), c.cfg.Jobs, func(i int) {
+		m := selected[i]
+		failures[i] = MirrorFailure{Banner: m.Banner, Error: "no downloadable URL"}
+		paths := make([]string, len(m.URLs))
+		for j, u := range m.URLs {
+			if file, err := mirrorPath(linux, u); err == nil {
+				paths[j] = filepath.Join(linux, filepath.Base(file))
+			}
+		}
+		// A file from any of the URLs will do, so look for one before
+		// downloading
+		for j, file := range paths {
+			if _, err := os.Stat(file); file != "" && err == nil {
+				files[i] = &PrefetchFile{m.Banner, m.URLs[j], file}
+				return
+			}
+		}
+		for j, file := range paths {
+			if file == "" {
+				continue
+			}
+			n, err := c.download(ctx, m.URLs[j], file)
+			if err != nil {
+				failures[i] = MirrorFailure{m.Banner, m.URLs[j], err.Error()}
+				continue
+			}
+			downloaded.Add(1)
+			size.Add(n)
+			files[i] = &PrefetchFile{m.Banner, m.URLs[j], file}
+			return
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	res.Downloaded, res.Bytes = int(downloaded.Load()), size.Load()
+
+	for i, f := range files {
+		if f == nil {
+			res.Failed = append(res.Failed, failures[i])
+			continue
+		}
+		res.Files = append(res.Files, *f)
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestKernelBanner(t *testing.T) {
+	oldProc, oldUname := procVersion, uname
+	defer func() { procVersion, uname = oldProc, oldUname }()
+
+	dir := t.TempDir()
+	procVersion = filepath.Join(dir, "version")
+	banner := "Linux version 6.1.0-18-amd64 (debian-kernel@lists.debian.org) #1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1 (2024-02-01)"
+	if err := os.WriteFile(procVersion, []byte(banner+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := KernelBanner(); err != nil || got != banner {
+		t.Errorf("KernelBanner() = %q, %v; expected the /proc/version banner", got, err)
+	}
+
+	// Without /proc, the release from uname
+	procVersion = filepath.Join(dir, "missing")
+	out := map[string]string{"-s": "Linux", "-r": "5.15.0-91-generic"}
+	uname = func(args ...string) (string, error) { return out[args[0]], nil }
+	if got, err := KernelBanner(); err != nil || got != "Linux version 5.15.0-91-generic (" {
+		t.Errorf("KernelBanner() from uname = %q, %v", got, err)
+	}
+
+	out["-s"] = "Darwin"
+	if _, err := KernelBanner(); !errors.Is(err, ErrNoKernelBanner) {
+		t.Errorf("KernelBanner() on Darwin error = %v, expected ErrNoKernelBanner", err)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/missing.json.xz" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("isf:" + r.URL.Path))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cache := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0-91-generic (buildd@lcy02) #101-Ubuntu SMP\n\x00": {server.URL + "/missing.json.xz", server.URL + "/ubuntu/5.15.json.xz"},
+		"Linux version 6.1.0-18-amd64 (debian-kernel) #1 SMP Debian\n\x00":     {server.URL + "/debian/6.1.json.xz"},
+		"Linux version 4.19.0-gone #1\n\x00":                                   {server.URL + "/missing.json.xz"},
+	}}
+	if err := writeBanners(cfg.CacheFile, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := New(cfg)
+	queries := []string{
+		"Linux version 5.15.0-91-generic (buildd@lcy02) #101-Ubuntu SMP\n",
+		"Linux version 4.19.0-gone",
+		"Linux version 3.10.0",
+	}
+	res, err := c.Prefetch(context.Background(), dir, queries)
+	if err != nil {
+		t.Fatalf("Prefetch() failed: %v", err)
+	}
+	if len(res.Files) != 1 || res.Downloaded != 1 || len(res.Failed) != 1 || len(res.Unmatched) != 1 {
+		t.Fatalf("Prefetch() = %+v, expected 1 file, 1 failure, and 1 unmatched query", res)
+	}
+	file := filepath.Join(dir, "linux", "5.15.json.xz")
+	if res.Files[0].Path != file {
+		t.Errorf("Path = %q, expected %q", res.Files[0].Path, file)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "isf:/ubuntu/5.15.json.xz" {
+		t.Errorf("prefetched file = %q, %v", data, err)
+	}
+
+	// Files already present are not downloaded again
+	before := hits
+	res, err = c.Prefetch(context.Background(), dir, queries[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || res.Downloaded != 0 || hits != before {
+		t.Errorf("second Prefetch() = %+v with %d requests, expected the existing file", res, hits-before)
+	}
+
+	// The volatility3 symbols directory by default
+	old := vol3SymbolsDir
+	defer func() { vol3SymbolsDir = old }()
+	vol3SymbolsDir = func() (string, error) { return dir, nil }
+	res, err = c.Prefetch(context.Background(), "", []string{"6.1.0-18"})
+	if err != nil || res.Dir != dir || len(res.Files) != 1 {
+		t.Errorf("Prefetch() into the volatility3 dir = %+v, %v", res, err)
+	}
+
+	vol3SymbolsDir = func() (string, error) { return "", errors.New("no python") }
+	if _, err := c.Prefetch(context.Background(), "", queries); err == nil {
+		t.Error("Prefetch() without volatility3 or a directory should fail")
+	}
+}
+
+func TestPrefetchErrors(t *testing.T) {
+	cfg := testConfig(t)
+	if _, err := New(cfg).Prefetch(context.Background(), t.TempDir(), []string{"5.15"}); !errors.Is(err, ErrNoCache) {
+		t.Errorf("Prefetch() without a cache error = %v, expected ErrNoCache", err)
+	}
+
+	cfg.Offline = true
+	if _, err := New(cfg).Prefetch(context.Background(), t.TempDir(), []string{"5.15"}); !errors.Is(err, fetcher.ErrOffline) {
+		t.Errorf("Prefetch() offline error = %v, expected ErrOffline", err)
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// PruneOptions configures PruneURLs.
+type PruneOptions struct {
+	// Sample checks that many URLs picked at random, every URL if zero.
+	Sample int
+	// DryRun flags the dead URLs without removing them.
+	DryRun bool
+	// Jobs bounds the concurrent checks, the configured jobs if zero.
+	Jobs int
+}
+
+// PruneReport is the outcome of PruneURLs, also saved as the last prune
+// report in the state directory.
+type PruneReport struct {
+	At      time.Time `json:"at"`
+	DryRun  bool      `json:"dry_run,omitempty"`
+	URLs    int       `json:"urls"`
+	Checked int       `json:"checked"`
+	// Dead lists the URLs found gone (404 or 410, or a missing file),
+	// removed unless DryRun; Failed the ones that failed otherwise, which
+	// are kept since the failure may be temporary.
+	Dead   []PrunedURL `json:"dead,omitempty"`
+	Failed []PrunedURL `json:"failed,omitempty"`
+	// Removed counts the URLs removed and the banners left without one,
+	// which are removed too.
+	Removed        int `json:"removed"`
+	RemovedBanners int `json:"removed_banners"`
+}
+
+// PrunedURL is a symbol URL that failed its check, with the banners
+// listing it.
+type PrunedURL struct {
+	URL     string   `json:"url"`
+	Banners []string `json:"banners"`
+	Error   string   `json:"error"`
+}
+
+// PruneURLs checks the symbol URLs of the cache, all of them or a random
+// sample, and removes the dead ones, at most opts.Jobs checks at a time.
+// Outcomes are recorded in the URL history like VerifyURLs. The cache keeps
+// its write time, and the next update brings back dead URLs its sources
+// still list.
+func (c *Cache) PruneURLs(ctx context.Context, opts PruneOptions) (*PruneReport, error) {
+	if c.cfg.Offline {
+		return nil, fetcher.ErrOffline
+	}
+	data := c.loadExistingBanners()
+	if data == nil {
+		return nil, ErrNoCache
+	}
+
+	banners := make(map[string][]string)
+	for banner, urls := range data.Linux {
+		for _, u := range urls {
+			banners[u] = append(banners[u], banner)
+		}
+	}
+	urls := make([]string, 0, len(banners))
+	for u := range banners {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	report := &PruneReport{At: time.Now(), DryRun: opts.DryRun, URLs: len(urls)}
+	if opts.Sample > 0 && opts.Sample < len(urls) {
+		rand.Shuffle(len(urls), func(i, j int) { urls[i], urls[j] = urls[j], urls[i] })
+		urls = urls[:opts.Sample]
+		sort.Strings(urls)
+	}
+	report.Checked = len(urls)
+
+	jobs := opts.Jobs
+	if jobs == 0 {
+		jobs = c.cfg.Jobs
+	}
+	errs := make([]error, len(urls))
+	parallel(len(urls), jobs, func(i int) {
+		errs[i] = c.fetcher.Probe(ctx, urls[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	live := c.loadLiveness()
+	dead := make(map[string]bool)
+	for i, u := range urls {
+		h := live[u]
+		h.record(report.At, errs[i])
+		live[u] = h
+		if errs[i] == nil {
+			continue
+		}
+		sort.Strings(banners[u])
+		p := PrunedURL{URL: u, Banners: banners[u], Error: errs[i].Error()}
+		if gone(errs[i]) {
+			dead[u] = true
+			report.Dead = append(report.Dead, p)
+		} else {
+			report.Failed = append(report.Failed, p)
+		}
+	}
+	if err := c.saveLiveness(live); err != nil {
+		c.log.Warn("saving liveness failed", "error", err)
+	}
+
+	var err error
+	if len(dead) > 0 && !opts.DryRun {
+		err = c.removeURLs(dead, report)
+	}
+	if err == nil {
+		c.log.Info("pruned symbol URLs", "checked", report.Checked, "dead", len(report.Dead),
+			"failed", len(report.Failed), "removed", report.Removed)
+	}
+	if serr := c.savePruneReport(report); serr != nil {
+		c.log.Warn("saving prune report failed", "error", serr)
+	}
+	return report, err
+}
+
+// gone reports whether a check failed because the URL no longer exists,
+// rather than for a reason that may pass.
+func gone(err error) bool {
+	var se *fetcher.SourceError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusNotFound || se.StatusCode == http.StatusGone
+	}
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// removeURLs removes the dead URLs from the cache, and the banners left
+// without a URL, counting them in report. The cache is reloaded under the
+// update lock, since it may have changed during the checks.
+func (c *Cache) removeURLs(dead map[string]bool, report *PruneReport) error {
+	if err := c.lockForUpdate(); err != nil {
+		return err
+	}
+	defer c.releaseLock()
+
+	info, err := os.Stat(c.cfg.CacheFile)
+	data := c.loadExistingBanners()
+	if err != nil || data == nil {
+		return ErrNoCache
+	}
+	prov := c.loadProvenance()
+	for banner, urls := range data.Linux {
+		kept := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if dead[u] {
+				report.Removed++
+			} else {
+				kept = append(kept, u)
+			}
+		}
+		switch {
+		case len(kept) == 0:
+			delete(data.Linux, banner)
+			delete(prov, banner)
+			report.RemovedBanners++
+		case len(kept) < len(urls):
+			data.Linux[banner] = kept
+		}
+	}
+	if report.Removed == 0 {
+		return nil
+	}
+	return c.rewriteInPlace(data, prov, info.ModTime())
+}
+
+// savePruneReport saves report as the last prune report.
+func (c *Cache) savePruneReport(report *PruneReport) error {
+	if err := os.MkdirAll(filepath.Dir(c.cfg.PruneReportFile), DirMode); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding prune report: %w", err)
+	}
+	return writeFileAtomic(c.cfg.PruneReportFile, raw)
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestPruneURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone.json":
+			w.WriteHeader(http.StatusNotFound)
+		case "/busy.json":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	c := New(cfg)
+	live, gone, busy := server.URL+"/live.json", server.URL+"/gone.json", server.URL+"/busy.json"
+	missing := "file://" + filepath.Join(t.TempDir(), "missing.json")
+	if err := c.write(&fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0": {gone, live},
+		"Linux version 6.1.0":  {busy},
+		"Linux version 6.8.0":  {gone, missing},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(cfg.CacheFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	dry, err := c.PruneURLs(context.Background(), PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dry.Checked != 4 || len(dry.Dead) != 2 || dry.Removed != 0 {
+		t.Errorf("dry run report = %+v, expected 4 checked, 2 dead, none removed", dry)
+	}
+	if data := c.loadExistingBanners(); len(data.Linux["Linux version 5.15.0"]) != 2 {
+		t.Errorf("dry run changed the cache: %v", data.Linux)
+	}
+
+	report, err := c.PruneURLs(context.Background(), PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Dead) != 2 || report.Dead[0].URL != missing || report.Dead[1].URL != gone ||
+		!reflect.DeepEqual(report.Dead[1].Banners, []string{"Linux version 5.15.0", "Linux version 6.8.0"}) {
+		t.Errorf("Dead = %+v, expected the missing file and the 404", report.Dead)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].URL != busy {
+		t.Errorf("Failed = %+v, expected the 503 kept", report.Failed)
+	}
+	if report.Removed != 3 || report.RemovedBanners != 1 {
+		t.Errorf("removed %d URLs and %d banners, expected 3 and 1", report.Removed, report.RemovedBanners)
+	}
+
+	data := c.loadExistingBanners()
+	want := map[string][]string{"Linux version 5.15.0": {live}, "Linux version 6.1.0": {busy}}
+	if !reflect.DeepEqual(data.Linux, want) {
+		t.Errorf("pruned cache = %v, expected %v", data.Linux, want)
+	}
+	if info, err := os.Stat(cfg.CacheFile); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("pruning should keep the cache's write time")
+	}
+	if h := c.loadLiveness()[gone]; h.Checks != 2 || h.ConsecutiveFailures != 2 {
+		t.Errorf("liveness of %s = %+v, expected 2 failed checks", gone, h)
+	}
+
+	var saved PruneReport
+	raw, err := os.ReadFile(cfg.PruneReportFile)
+	if err != nil || json.Unmarshal(raw, &saved) != nil || saved.Removed != 3 {
+		t.Errorf("saved report = %s (%v), expected the last prune's", raw, err)
+	}
+}
+
+func TestPruneURLsSample(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	c := New(cfg)
+	if err := c.write(&fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"b1": {server.URL + "/1.json", server.URL + "/2.json"},
+		"b2": {server.URL + "/3.json"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.PruneURLs(context.Background(), PruneOptions{Sample: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.URLs != 3 || report.Checked != 2 || requests.Load() != 2 {
+		t.Errorf("report = %+v after %d requests, expected 2 of 3 URLs checked", report, requests.Load())
+	}
+}
+
+func TestPruneURLsOffline(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Offline = true
+	if _, err := New(cfg).PruneURLs(context.Background(), PruneOptions{}); err != fetcher.ErrOffline {
+		t.Errorf("PruneURLs() offline = %v, expected ErrOffline", err)
+	}
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PublishOCI pushes the cache to an OCI registry as an artifact tagged as
+// ref says (oci://REGISTRY/REPOSITORY:TAG), for oci:// sources of other
+// installations to pull, and returns the manifest digest. The artifact is
+// annotated with its creation time and the cache generation.
+func (c *Cache) PublishOCI(ctx context.Context, ref string) (string, error) {
+	index, err := os.ReadFile(c.cfg.CacheFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNoCache
+	}
+	if err != nil {
+		return "", err
+	}
+
+	annotations := map[string]string{
+		"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+	}
+	if generation := c.loadMeta().Generation; generation > 0 {
+		annotations["io.basar.generation"] = strconv.FormatUint(generation, 10)
+	}
+	return c.fetcher.PushOCI(ctx, ref, "banners.json", index, annotations)
+}
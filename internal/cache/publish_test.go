@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestPublishOCI(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	if _, err := New(cfg).PublishOCI(ctx, "oci://localhost:5000/banners:v1"); !errors.Is(err, ErrNoCache) {
+		t.Errorf("PublishOCI() without a cache error = %v, expected ErrNoCache", err)
+	}
+
+	createTestBannerFile(t, cfg.CacheFile)
+	cfg.Offline = true
+	if _, err := New(cfg).PublishOCI(ctx, "oci://localhost:5000/banners:v1"); !errors.Is(err, fetcher.ErrOffline) {
+		t.Errorf("PublishOCI() offline error = %v, expected ErrOffline", err)
+	}
+}
+
+func TestOCIDockerLogin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths": {"ghcr.io": {"auth": "YWxpY2U6czNjcmV0"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t)
+	cfg.Options = map[string]config.SourceOptions{"oci://ghcr.io/org/token:v1": {TokenEnv: "REGISTRY_TOKEN"}}
+	c := New(cfg)
+	ctx := context.Background()
+
+	if auth, err := c.sourceAuth(ctx, "oci://ghcr.io/org/banners:v1", "ghcr.io"); err != nil || auth != basicAuth("alice", "s3cret") {
+		t.Errorf("sourceAuth() = %q, %v; expected the docker login", auth, err)
+	}
+	if auth, err := c.sourceAuth(ctx, "oci://quay.io/org/banners:v1", "quay.io"); err != nil || auth != "" {
+		t.Errorf("sourceAuth() without a login = %q, %v; expected none", auth, err)
+	}
+	if auth, err := c.sourceAuth(ctx, "oci://ghcr.io/org/token:v1", "ghcr.io"); err != nil || auth != "" {
+		t.Errorf("sourceAuth() of a source with a token = %q, %v; expected the token to be used", auth, err)
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// QuarantineRecord keeps the banners the last fetch of a source dropped for
+// straying from the schema, so they can be inspected and reported upstream.
+type QuarantineRecord struct {
+	Source string    `json:"source"`
+	At     time.Time `json:"at"`
+	fetcher.Quarantine
+}
+
+// quarantinePath returns the quarantine file for a source.
+func (c *Cache) quarantinePath(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(c.cfg.QuarantineDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// recordQuarantines warns about the banners quarantined from each fetched
+// source and keeps them in its quarantine file, removing the file of
+// sources fetched clean. Failures only cost the record, so they are logged.
+func (c *Cache) recordQuarantines(results []fetcher.Result) {
+	for _, r := range results {
+		if r.Err != nil || !r.Modified {
+			continue
+		}
+		if r.Quarantine == nil {
+			if err := os.Remove(c.quarantinePath(r.Source)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				c.log.Warn("removing quarantine file failed", "source", r.Source, "error", err)
+			}
+			continue
+		}
+		c.log.Warn("source has malformed banners; quarantined",
+			"source", r.Source, "banners", len(r.Quarantine.Linux), "problem", r.Quarantine.Problems[0])
+		for _, p := range r.Quarantine.Problems[1:] {
+			c.log.Debug("quarantined banner", "source", r.Source, "problem", p)
+		}
+		if err := c.saveQuarantine(r.Source, r.Quarantine); err != nil {
+			c.log.Warn("saving quarantine file failed", "source", r.Source, "error", err)
+		}
+	}
+}
+
+// saveQuarantine writes the quarantine file of source.
+func (c *Cache) saveQuarantine(source string, q *fetcher.Quarantine) error {
+	if err := os.MkdirAll(c.cfg.QuarantineDir, DirMode); err != nil {
+		return fmt.Errorf("creating quarantine dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(QuarantineRecord{Source: source, At: time.Now(), Quarantine: *q}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding quarantine: %w", err)
+	}
+	return writeFileAtomic(c.quarantinePath(source), raw)
+}
+
+// LoadQuarantine returns what the last fetch of source quarantined, or nil
+// if nothing was.
+func (c *Cache) LoadQuarantine(source string) (*QuarantineRecord, error) {
+	raw, err := os.ReadFile(c.quarantinePath(source))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec QuarantineRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", c.quarantinePath(source), err)
+	}
+	return &rec, nil
+}
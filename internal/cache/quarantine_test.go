@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestUpdateQuarantinesMalformedBanners(t *testing.T) {
+	cfg := testConfig(t)
+	source := filepath.Join(cfg.ConfigDir, "source.json")
+	writeBannerSource(t, source, map[string][]string{
+		"good": {"https://example.com/good.json.xz"},
+		"bad":  {"symbols/bad.json.xz"},
+	})
+	cfg.Sources = []string{source}
+	cfg.Options = map[string]config.SourceOptions{source: {Schema: "quarantine"}}
+
+	c := New(cfg)
+	res, err := c.Update(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if res.Sources[0].Quarantined != 1 {
+		t.Errorf("SourceResult.Quarantined = %d, want 1", res.Sources[0].Quarantined)
+	}
+	if stats := c.Stats(); stats.Entries != 1 || stats.Sources[0].Quarantined != 1 {
+		t.Errorf("Stats() = %d entries, sources %+v; want the good banner only", stats.Entries, stats.Sources)
+	}
+
+	rec, err := c.LoadQuarantine(source)
+	if err != nil || rec == nil {
+		t.Fatalf("LoadQuarantine() = %v, %v", rec, err)
+	}
+	if rec.Source != source || len(rec.Linux["bad"]) != 1 || !strings.Contains(rec.Problems[0], "not an absolute URL") {
+		t.Errorf("quarantine record = %+v", rec)
+	}
+
+	// A clean fetch clears the record
+	writeBannerSource(t, source, map[string][]string{"good": {"https://example.com/good.json.xz"}})
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("second Update() failed: %v", err)
+	}
+	if rec, err := c.LoadQuarantine(source); err != nil || rec != nil {
+		t.Errorf("LoadQuarantine() after a clean fetch = %+v, %v; want nil", rec, err)
+	}
+}
+
+func TestUpdateRejectsMalformedSource(t *testing.T) {
+	cfg := testConfig(t)
+	good := filepath.Join(cfg.ConfigDir, "good.json")
+	bad := filepath.Join(cfg.ConfigDir, "bad.json")
+	writeBannerSource(t, good, map[string][]string{"good": {"https://example.com/good.json.xz"}})
+	if err := os.WriteFile(bad, []byte(`{"linux":{"bad":["https://example.com/bad.json.xz"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sources = []string{good, bad}
+
+	c := New(cfg)
+	res, err := c.Update(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if !res.Partial() || !strings.Contains(res.Sources[1].Error, "invalid banner index: missing version") {
+		t.Errorf("malformed source should fail, got %+v", res.Sources[1])
+	}
+	if entries := c.Stats().Entries; entries != 1 {
+		t.Errorf("Stats().Entries = %d, want only the good source's banner", entries)
+	}
+}
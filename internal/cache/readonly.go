@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrReadOnly indicates the cache directory is on a read-only filesystem
+// and no fallback directory is configured.
+var ErrReadOnly = errors.New("cache directory is on a read-only filesystem")
+
+// isReadOnlyFS reports whether err is a write to a read-only filesystem.
+func isReadOnlyFS(err error) bool {
+	return errReadOnlyFS != nil && errors.Is(err, errReadOnlyFS)
+}
+
+// probeWritable creates and removes a file in dir, to find out whether
+// writes there succeed before an update depends on it.
+var probeWritable = func(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// ReadOnlyDir returns the read-only cache directory the fallback directory
+// stands in for, or "" when the configured one is in use.
+func (c *Cache) ReadOnlyDir() string {
+	return c.readOnlyDir
+}
+
+// useFallback moves the cache and its state to the fallback directory.
+func (c *Cache) useFallback() {
+	c.readOnlyDir = c.cfg.CacheDir
+	c.cfg = c.cfg.Relocated(c.cfg.FallbackCacheDir)
+}
+
+// fallbackIsNewer reports whether the fallback directory holds a cache
+// written after the one in the cache directory, or the only one.
+func (c *Cache) fallbackIsNewer() bool {
+	if c.cfg.FallbackCacheDir == "" {
+		return false
+	}
+	fallback, err := os.Stat(c.cfg.Relocated(c.cfg.FallbackCacheDir).CacheFile)
+	if err != nil {
+		return false
+	}
+	primary, err := os.Stat(c.cfg.CacheFile)
+	return err != nil || fallback.ModTime().After(primary.ModTime())
+}
+
+// lockForUpdate acquires the lock for an update after checking the cache
+// directory takes writes. When it or the state directory is on a
+// read-only filesystem, the update moves to the fallback directory, seeded
+// with the read-only cache and metadata so kept sources and conditional
+// requests carry over. State files an older basar wrote are then upgraded,
+// and those a newer one wrote fail the update.
+func (c *Cache) lockForUpdate() error {
+	err := c.acquireLock()
+	if err == nil {
+		if err = probeWritable(c.cfg.CacheDir); err != nil {
+			c.releaseLock()
+		}
+	}
+	if err == nil {
+		return c.upgradeLocked()
+	}
+	if !isReadOnlyFS(err) || c.readOnlyDir != "" {
+		return err
+	}
+	if c.cfg.FallbackCacheDir == "" {
+		return fmt.Errorf("%w (set BASAR_FALLBACK_CACHE_DIR to update elsewhere): %v", ErrReadOnly, err)
+	}
+
+	primary := c.cfg
+	c.useFallback()
+	c.log.Warn("cache directory is read-only; updating the fallback directory instead",
+		"dir", c.readOnlyDir, "fallback", c.cfg.CacheDir, "error", err)
+	if err := c.acquireLock(); err != nil {
+		return err
+	}
+	for from, to := range map[string]string{primary.CacheFile: c.cfg.CacheFile, primary.MetaFile: c.cfg.MetaFile} {
+		if err := seedFile(from, to); err != nil {
+			c.log.Warn("seeding the fallback directory failed", "file", from, "error", err)
+		}
+	}
+	return c.upgradeLocked()
+}
+
+// upgradeLocked upgrades the state files under the lock just acquired,
+// releasing it if they cannot be.
+func (c *Cache) upgradeLocked() error {
+	if err := c.upgradeState(); err != nil {
+		c.releaseLock()
+		return err
+	}
+	return nil
+}
+
+// seedFile copies from to to unless to exists or from does not, keeping
+// the modification time so the copy is no newer than its original.
+func seedFile(from, to string) error {
+	if _, err := os.Stat(to); err == nil {
+		return nil
+	}
+	info, err := os.Stat(from)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), DirMode); err != nil {
+		return err
+	}
+	tmp := to + ".tmp"
+	if err := os.WriteFile(tmp, data, FileMode); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp, time.Time{}, info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, to)
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package cache
+
+// errReadOnlyFS is nil where read-only filesystems cannot be told apart
+// from other write failures.
+var errReadOnlyFS error
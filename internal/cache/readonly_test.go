@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readOnlyDir makes probeWritable fail for dir as on a read-only
+// filesystem.
+func readOnlyDir(t *testing.T, dir string) {
+	t.Helper()
+	if errReadOnlyFS == nil {
+		t.Skip("read-only filesystems are not detected on this platform")
+	}
+	probe := probeWritable
+	probeWritable = func(d string) error {
+		if d == dir {
+			return &os.PathError{Op: "open", Path: d, Err: errReadOnlyFS}
+		}
+		return probe(d)
+	}
+	t.Cleanup(func() { probeWritable = probe })
+}
+
+func TestReadOnlyCacheDir(t *testing.T) {
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	writeBannerSource(t, local, bannerURLs("a"))
+	cfg.Sources = []string{local}
+	ctx := context.Background()
+
+	if _, err := New(cfg).Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(cfg.CacheFile, past, past)
+	readOnlyDir(t, cfg.CacheDir)
+
+	if _, err := New(cfg).Update(ctx, true); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Update() without a fallback error = %v, expected ErrReadOnly", err)
+	}
+	if f := New(cfg).checkStorage(); f.Severity != FindingError {
+		t.Errorf("checkStorage() without a fallback = %+v, expected an error", f)
+	}
+
+	cfg.FallbackCacheDir = t.TempDir()
+	if f := New(cfg).checkStorage(); f.Severity != FindingOK || !strings.Contains(f.Message, cfg.FallbackCacheDir) {
+		t.Errorf("checkStorage() with a fallback = %+v, expected it named", f)
+	}
+
+	// The read-only cache is served until an update fills the fallback
+	c := New(cfg)
+	if c.ReadOnlyDir() != "" {
+		t.Errorf("ReadOnlyDir() = %q before an update, expected none", c.ReadOnlyDir())
+	}
+	writeBannerSource(t, local, bannerURLs("b"))
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatalf("Update() with a fallback failed: %v", err)
+	}
+	if c.ReadOnlyDir() != cfg.CacheDir {
+		t.Errorf("ReadOnlyDir() = %q, expected %q", c.ReadOnlyDir(), cfg.CacheDir)
+	}
+	matches, _ := c.Lookup("")
+	if got := banners(matches); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("banners = %v, expected [b]", got)
+	}
+
+	// A new instance prefers the newer fallback cache, and the metadata
+	// seeded from the read-only one carries on
+	stats := New(cfg).Stats()
+	if stats.ReadOnlyDir != cfg.CacheDir || !strings.HasPrefix(stats.Path, cfg.FallbackCacheDir) {
+		t.Errorf("Stats() = path %q, read-only dir %q; expected the fallback", stats.Path, stats.ReadOnlyDir)
+	}
+	if stats.Generation != 2 {
+		t.Errorf("Generation = %d, expected 2", stats.Generation)
+	}
+	matches, _ = New(cfg).Lookup("")
+	if got := banners(matches); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("banners from a new instance = %v, expected [b]", got)
+	}
+}
+
+func TestSeedFile(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "from")
+	to := filepath.Join(dir, "sub", "to")
+
+	if err := seedFile(from, to); err != nil {
+		t.Fatalf("seedFile() of a missing file failed: %v", err)
+	}
+	if _, err := os.Stat(to); !os.IsNotExist(err) {
+		t.Error("seedFile() of a missing file should not create it")
+	}
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	_ = os.WriteFile(from, []byte("a"), 0644)
+	_ = os.Chtimes(from, past, past)
+	if err := seedFile(from, to); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("seeded mtime = %v, expected %v", info.ModTime(), past)
+	}
+
+	_ = os.WriteFile(from, []byte("b"), 0644)
+	_ = seedFile(from, to)
+	if data, _ := os.ReadFile(to); string(data) != "a" {
+		t.Errorf("seedFile() replaced an existing file with %q", data)
+	}
+}
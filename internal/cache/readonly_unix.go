@@ -0,0 +1,8 @@
+//go:build unix
+
+package cache
+
+import "syscall"
+
+// errReadOnlyFS is the error writes to a read-only filesystem fail with.
+var errReadOnlyFS error = syscall.EROFS
@@ -0,0 +1,9 @@
+//go:build windows
+
+package cache
+
+import "syscall"
+
+// errReadOnlyFS is the error writes to a read-only filesystem fail with:
+// ERROR_WRITE_PROTECT.
+var errReadOnlyFS error = syscall.Errno(19)
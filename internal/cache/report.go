@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// Report formats accepted by Report.Write.
+const (
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// ReportFormats lists the formats Report.Write can produce.
+var ReportFormats = []string{ReportMarkdown, ReportHTML}
+
+// reportListLimit caps the banners listed per section; the rest are
+// counted, keeping reports short enough to paste.
+const reportListLimit = 50
+
+// Report summarizes the update history over a period.
+type Report struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	Updates       int `json:"updates"`
+	Changed       int `json:"changed"`
+	FailedUpdates int `json:"failed_updates"`
+	// Entries is the banner count after the last update in the period.
+	Entries    int    `json:"entries"`
+	Generation uint64 `json:"generation"`
+
+	// Added and Removed are the net banner changes over the period.
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+
+	Sources []SourceHealth `json:"sources"`
+}
+
+// SourceHealth summarizes the fetches of one source over a report period.
+type SourceHealth struct {
+	Source     string `json:"source"`
+	Fetches    int    `json:"fetches"`
+	Failures   int    `json:"failures"`
+	LastStatus string `json:"last_status"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+// Report summarizes the updates recorded since the given time: how many
+// ran and failed, the banners added and removed, and each source's health.
+func (c *Cache) Report(since time.Time) (*Report, error) {
+	entries, err := c.loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Since: since, Until: time.Now(), Added: []string{}, Removed: []string{}, Sources: []SourceHealth{}}
+	added := make(map[string]bool)
+	removed := make(map[string]bool)
+	health := make(map[string]*SourceHealth)
+
+	for _, e := range entries {
+		if e.At.Before(since) {
+			continue
+		}
+
+		r.Updates++
+		switch e.Status {
+		case UpdateUpdated:
+			r.Changed++
+		case UpdateFailed:
+			r.FailedUpdates++
+		}
+		r.Entries = e.Entries
+		r.Generation = e.Generation
+
+		// Net out banners that came and went within the period
+		for _, b := range e.Added {
+			if removed[b] {
+				delete(removed, b)
+			} else {
+				added[b] = true
+			}
+		}
+		for _, b := range e.Removed {
+			if added[b] {
+				delete(added, b)
+			} else {
+				removed[b] = true
+			}
+		}
+
+		for _, s := range e.Sources {
+			h := health[s.Source]
+			if h == nil {
+				h = &SourceHealth{Source: s.Source}
+				health[s.Source] = h
+			}
+			h.Fetches++
+			h.LastStatus = s.Status
+			h.LastError = s.Error
+			if s.Status == fetcher.StatusError {
+				h.Failures++
+			}
+		}
+	}
+
+	for b := range added {
+		r.Added = append(r.Added, b)
+	}
+	for b := range removed {
+		r.Removed = append(r.Removed, b)
+	}
+	sort.Strings(r.Added)
+	sort.Strings(r.Removed)
+
+	for _, h := range health {
+		r.Sources = append(r.Sources, *h)
+	}
+	sort.Slice(r.Sources, func(i, j int) bool {
+		return r.Sources[i].Source < r.Sources[j].Source
+	})
+
+	return r, nil
+}
+
+// Write renders the report in format, one of ReportFormats.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportMarkdown:
+		return markdownReport.Execute(w, r)
+	case ReportHTML:
+		return htmlReport.Execute(w, r)
+	}
+	return fmt.Errorf("unknown report format %q (expected one of %v)", format, ReportFormats)
+}
+
+// reportFuncs are shared by the Markdown and HTML report templates.
+var reportFuncs = map[string]any{
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02")
+	},
+	"head": func(banners []string) []string {
+		return banners[:min(len(banners), reportListLimit)]
+	},
+	"more": func(banners []string) int {
+		return max(len(banners)-reportListLimit, 0)
+	},
+}
+
+var markdownReport = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(
+	`## basar coverage report, {{date .Since}} to {{date .Until}}
+
+- **{{.Entries}}** banners (generation {{.Generation}})
+- **+{{len .Added}}** added, **-{{len .Removed}}** removed
+- {{.Updates}} updates: {{.Changed}} changed the cache, {{.FailedUpdates}} failed
+{{- if .Added}}
+
+### Added banners
+{{range head .Added}}
+- ` + "`{{.}}`" + `
+{{- end}}
+{{- with more .Added}}
+- …and {{.}} more
+{{- end}}
+{{- end}}
+{{- if .Removed}}
+
+### Removed banners
+{{range head .Removed}}
+- ` + "`{{.}}`" + `
+{{- end}}
+{{- with more .Removed}}
+- …and {{.}} more
+{{- end}}
+{{- end}}
+{{- if .Sources}}
+
+### Source health
+
+| Source | Fetches | Failures | Last status |
+|--------|---------|----------|-------------|
+{{- range .Sources}}
+| {{.Source}} | {{.Fetches}} | {{.Failures}} | {{.LastStatus}}{{if .LastError}}: {{.LastError}}{{end}} |
+{{- end}}
+{{- end}}
+`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(
+	`<h2>basar coverage report, {{date .Since}} to {{date .Until}}</h2>
+<ul>
+<li><strong>{{.Entries}}</strong> banners (generation {{.Generation}})</li>
+<li><strong>+{{len .Added}}</strong> added, <strong>-{{len .Removed}}</strong> removed</li>
+<li>{{.Updates}} updates: {{.Changed}} changed the cache, {{.FailedUpdates}} failed</li>
+</ul>
+{{- if .Added}}
+<h3>Added banners</h3>
+<ul>
+{{- range head .Added}}
+<li><code>{{.}}</code></li>
+{{- end}}
+{{- with more .Added}}
+<li>…and {{.}} more</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Removed}}
+<h3>Removed banners</h3>
+<ul>
+{{- range head .Removed}}
+<li><code>{{.}}</code></li>
+{{- end}}
+{{- with more .Removed}}
+<li>…and {{.}} more</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Sources}}
+<h3>Source health</h3>
+<table>
+<tr><th>Source</th><th>Fetches</th><th>Failures</th><th>Last status</th></tr>
+{{- range .Sources}}
+<tr><td>{{.Source}}</td><td>{{.Fetches}}</td><td>{{.Failures}}</td><td>{{.LastStatus}}{{if .LastError}}: {{.LastError}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+`))
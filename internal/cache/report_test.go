@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestReport(t *testing.T) {
+	c := New(testConfig(t))
+	now := time.Now()
+
+	for _, e := range []HistoryEntry{
+		// Before the period: ignored
+		{At: now.Add(-10 * 24 * time.Hour), Status: UpdateUpdated, Added: []string{"old"}},
+		{At: now.Add(-3 * 24 * time.Hour), Status: UpdateUpdated, Entries: 3, Generation: 4,
+			Added: []string{"a", "b", "flap"}, Removed: []string{"gone"},
+			Sources: []SourceResult{{Source: "s1", Status: fetcher.StatusOK}, {Source: "s2", Status: fetcher.StatusError, Error: "timeout"}}},
+		{At: now.Add(-2 * 24 * time.Hour), Status: UpdateFailed, Error: "all sources failed",
+			Sources: []SourceResult{{Source: "s1", Status: fetcher.StatusError, Error: "unexpected status: 500"}}},
+		{At: now.Add(-1 * 24 * time.Hour), Status: UpdateUpdated, Entries: 2, Generation: 5,
+			Removed: []string{"flap"},
+			Sources: []SourceResult{{Source: "s1", Status: fetcher.StatusOK}, {Source: "s2", Status: fetcher.StatusNotModified}}},
+	} {
+		if err := c.appendHistory(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := c.Report(now.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Report() failed: %v", err)
+	}
+
+	if r.Updates != 3 || r.Changed != 2 || r.FailedUpdates != 1 || r.Entries != 2 || r.Generation != 5 {
+		t.Errorf("unexpected totals: %+v", r)
+	}
+	if !slices.Equal(r.Added, []string{"a", "b"}) || !slices.Equal(r.Removed, []string{"gone"}) {
+		t.Errorf("net changes = +%v -%v, expected +[a b] -[gone]", r.Added, r.Removed)
+	}
+	want := []SourceHealth{
+		{Source: "s1", Fetches: 3, Failures: 1, LastStatus: fetcher.StatusOK},
+		{Source: "s2", Fetches: 2, Failures: 1, LastStatus: fetcher.StatusNotModified},
+	}
+	if !slices.Equal(r.Sources, want) {
+		t.Errorf("Sources = %+v, expected %+v", r.Sources, want)
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	r := &Report{
+		Since:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:   time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+		Entries: 10,
+		Added:   make([]string, reportListLimit+2),
+		Removed: []string{"<b>gone</b>"},
+		Sources: []SourceHealth{{Source: "s1", Fetches: 7, LastStatus: fetcher.StatusOK}},
+	}
+	for i := range r.Added {
+		r.Added[i] = "banner"
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{ReportMarkdown, []string{"2024-01-01 to 2024-01-08", "**+52** added", "…and 2 more", "`<b>gone</b>`", "| s1 | 7 | 0 | ok |"}},
+		{ReportHTML, []string{"<h2>", "…and 2 more", "&lt;b&gt;gone&lt;/b&gt;", "<td>s1</td>"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := r.Write(&buf, tt.format); err != nil {
+			t.Fatalf("Write(%s) failed: %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s report should contain %q:\n%s", tt.format, want, buf.String())
+			}
+		}
+	}
+
+	if err := r.Write(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Error("Write() should reject unknown formats")
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// UpdateResult describes the outcome of Update or SmartUpdate. It is
+// returned even when the update fails, with whatever was learned so far.
+type UpdateResult struct {
+	// Updated reports whether the update changed the cache.
+	Updated bool `json:"updated"`
+
+	// Sources holds the outcome of each fetched source, in config order.
+	Sources []SourceResult `json:"sources,omitempty"`
+
+	EntriesBefore int           `json:"entries_before"`
+	EntriesAfter  int           `json:"entries_after"`
+	Duration      time.Duration `json:"duration_ns"`
+
+	// Generation counts cache changes; it increases by one with every
+	// update that reports Updated.
+	Generation uint64 `json:"generation"`
+
+	// Filtered counts what URL and banner filters removed from the merge,
+	// nil without filters.
+	Filtered *fetcher.FilterCounts `json:"filtered,omitempty"`
+
+	started time.Time
+	// unattended marks a smart update, the kind scheduled runs make.
+	unattended bool
+	// added and removed list the banners a write changed, for the history.
+	added, removed []string
+}
+
+// Partial reports whether the update succeeded with some sources failing.
+func (r *UpdateResult) Partial() bool {
+	for _, s := range r.Sources {
+		if s.Status == fetcher.StatusError {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceResult is the outcome of fetching one source during an update.
+type SourceResult struct {
+	Source string `json:"source"`
+	// Status is fetcher.StatusOK, StatusNotModified, or StatusError.
+	Status  string `json:"status"`
+	Entries int    `json:"entries,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Quarantined counts the banners dropped for straying from the schema.
+	Quarantined int `json:"quarantined,omitempty"`
+	// Typical is the source's usual entry count when Entries fell far
+	// below it; Held reports that its previous data was merged instead.
+	Typical int  `json:"typical,omitempty"`
+	Held    bool `json:"held,omitempty"`
+	// Stale reports that the source ran past its deadline and its last
+	// snapshot was merged instead, so its banners stay in the cache.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// newResult starts an UpdateResult with the current cache size.
+func (c *Cache) newResult() *UpdateResult {
+	return &UpdateResult{EntriesBefore: c.cachedEntries(), started: time.Now()}
+}
+
+// cachedEntries returns the number of banners in the cache, or 0 if there
+// is no readable cache.
+func (c *Cache) cachedEntries() int {
+	f, err := os.Open(c.cfg.CacheFile)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	n, _ := fetcher.CountBanners(f)
+	return n
+}
+
+// sourceResults summarizes fetch results for an UpdateResult.
+func sourceResults(results []fetcher.Result) []SourceResult {
+	out := make([]SourceResult, 0, len(results))
+	for _, r := range results {
+		sr := SourceResult{Source: r.Source}
+		switch {
+		case r.Err != nil:
+			sr.Status = fetcher.StatusError
+			sr.Error = r.Err.Error()
+		case !r.Modified:
+			sr.Status = fetcher.StatusNotModified
+		default:
+			sr.Status = fetcher.StatusOK
+		}
+		if r.Data != nil {
+			sr.Entries = len(r.Data.Linux)
+		}
+		if r.Quarantine != nil {
+			sr.Quarantined = len(r.Quarantine.Linux)
+		}
+		out = append(out, sr)
+	}
+	return out
+}
+
+// logFailures warns about the sources that failed. Sources failing with
+// the same error, as all do behind a broken proxy, are collapsed after the
+// first into one warning listing them, keeping logs of large source lists
+// readable; each is still logged at debug level.
+func (c *Cache) logFailures(results []fetcher.Result) {
+	var reasons []string
+	bySource := make(map[string][]string)
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		reason := failureReason(r.Err)
+		if len(bySource[reason]) == 0 {
+			reasons = append(reasons, reason)
+			c.log.Warn("source failed", "source", r.Source, "error", r.Err)
+		} else {
+			c.log.Debug("source failed", "source", r.Source, "error", r.Err)
+		}
+		bySource[reason] = append(bySource[reason], r.Source)
+	}
+
+	for _, reason := range reasons {
+		more := bySource[reason][1:]
+		if len(more) == 0 {
+			continue
+		}
+		noun := "sources"
+		if len(more) == 1 {
+			noun = "source"
+		}
+		c.log.Warn(fmt.Sprintf("and %d more %s failed with: %s", len(more), noun, reason),
+			slog.String("sources", strings.Join(more, ", ")))
+	}
+}
+
+// failureReason returns the message of a fetch error without the URL that
+// failed, so sources failing alike compare equal.
+func failureReason(err error) string {
+	msg := err.Error()
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		msg = strings.Replace(msg, uerr.Error(), uerr.Err.Error(), 1)
+	}
+	return msg
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestFailureReason(t *testing.T) {
+	proxy := errors.New("proxyconnect tcp: dial tcp 10.0.0.1:3128: connect: connection refused")
+	a := fmt.Errorf("executing request: %w", &url.Error{Op: "Get", URL: "https://a.example/x.json", Err: proxy})
+	b := fmt.Errorf("executing request: %w", &url.Error{Op: "Get", URL: "https://b.example/y.json", Err: proxy})
+
+	if failureReason(a) != failureReason(b) {
+		t.Errorf("failureReason() differs by URL: %q vs %q", failureReason(a), failureReason(b))
+	}
+	if want := "executing request: " + proxy.Error(); failureReason(a) != want {
+		t.Errorf("failureReason() = %q, expected %q", failureReason(a), want)
+	}
+	if got := failureReason(errors.New("HTTP 404")); got != "HTTP 404" {
+		t.Errorf("failureReason() of a plain error = %q", got)
+	}
+}
+
+func TestLogFailures(t *testing.T) {
+	proxy := errors.New("proxyconnect tcp: connection refused")
+	var results []fetcher.Result
+	for i := 0; i < 15; i++ {
+		u := fmt.Sprintf("https://%d.example/banners.json", i)
+		results = append(results, fetcher.Result{Source: u, Err: &url.Error{Op: "Get", URL: u, Err: proxy}})
+	}
+	results = append(results,
+		fetcher.Result{Source: "https://ok.example/banners.json"},
+		fetcher.Result{Source: "https://gone.example/banners.json", Err: errors.New("HTTP 404")})
+
+	var buf bytes.Buffer
+	c := New(testConfig(t))
+	c.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	c.logFailures(results)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logFailures() logged %d lines, expected 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "0.example") || !strings.Contains(lines[1], "gone.example") {
+		t.Errorf("the first failure of each kind should be logged in full:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[2], "and 14 more sources failed with: "+proxy.Error()) {
+		t.Errorf("the rest should be collapsed, got %q", lines[2])
+	}
+	if !strings.Contains(lines[2], "14.example") {
+		t.Errorf("the collapsed warning should list the sources, got %q", lines[2])
+	}
+
+	// Each is still logged at debug level
+	buf.Reset()
+	c.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	c.logFailures(results)
+	if n := strings.Count(buf.String(), `msg="source failed"`); n != 16 {
+		t.Errorf("debug logging shows %d failures, expected 16", n)
+	}
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Schedulers InstallService can install updates with, as --scheduler and
+// BASAR_SCHEDULER name them.
+const (
+	SchedulerSystemd  = "systemd"
+	SchedulerCron     = "cron"
+	SchedulerLaunchd  = "launchd"
+	SchedulerSchtasks = "schtasks"
+	// SchedulerDaemon installs nothing: updates are left to basar serve,
+	// which refreshes the cache on its own schedule while it runs, e.g.
+	// in containers or under a process supervisor.
+	SchedulerDaemon = "daemon"
+)
+
+// A Scheduler installs the twice-monthly `basar --smart-update` with one
+// scheduling system.
+type Scheduler interface {
+	// Name is the scheduler's name, one of the Scheduler constants.
+	Name() string
+	// Available reports whether the scheduler can be used on this machine.
+	Available() bool
+	// Install installs the schedule for the current user, replacing one
+	// installed before, and describes it; "" when it installs nothing.
+	Install(c *Cache) (string, error)
+	// Check reports whether the schedule is installed, for doctor.
+	Check(c *Cache) Finding
+	// Plan describes what Install would write and run, for --dry-run.
+	Plan(c *Cache) (*Plan, error)
+}
+
+// schedulers holds each Scheduler by name.
+var schedulers = map[string]Scheduler{
+	SchedulerSystemd:  systemdScheduler{},
+	SchedulerCron:     cronScheduler{},
+	SchedulerLaunchd:  launchdScheduler{},
+	SchedulerSchtasks: schtasksScheduler{},
+	SchedulerDaemon:   daemonScheduler{},
+}
+
+// platformSchedulers returns the system schedulers of this platform,
+// preferred first.
+func platformSchedulers() []string {
+	switch runtime.GOOS {
+	case "linux":
+		return []string{SchedulerSystemd, SchedulerCron}
+	case "freebsd", "openbsd", "netbsd", "dragonfly", "solaris", "illumos":
+		return []string{SchedulerCron}
+	case "darwin":
+		return []string{SchedulerLaunchd}
+	case "windows":
+		return []string{SchedulerSchtasks}
+	}
+	return nil
+}
+
+// ServiceInstallers returns the schedulers InstallService supports on this
+// platform: its system schedulers, preferred first, then SchedulerDaemon.
+func ServiceInstallers() []string {
+	return append(platformSchedulers(), SchedulerDaemon)
+}
+
+// CheckScheduler reports whether name is a scheduler of this platform.
+func CheckScheduler(name string) error {
+	if !slices.Contains(ServiceInstallers(), name) {
+		return fmt.Errorf("unknown scheduler %q on %s (want %s)", name, runtime.GOOS, strings.Join(ServiceInstallers(), ", "))
+	}
+	return nil
+}
+
+// Scheduler returns the scheduler InstallService uses: the one the config
+// names, else the first of this platform's that is available, else its
+// last, whose installation then reports what is missing.
+func (c *Cache) Scheduler() (Scheduler, error) {
+	if name := c.cfg.Scheduler; name != "" {
+		if err := CheckScheduler(name); err != nil {
+			return nil, err
+		}
+		return schedulers[name], nil
+	}
+
+	names := platformSchedulers()
+	if len(names) == 0 {
+		return nil, fmt.Errorf("auto-update service not supported on %s; run basar serve (--scheduler %s) instead",
+			runtime.GOOS, SchedulerDaemon)
+	}
+	for i, name := range names {
+		if s := schedulers[name]; s.Available() {
+			if i > 0 {
+				c.log.Info("preferred scheduler unavailable", "preferred", names[0], "scheduler", name)
+			}
+			return s, nil
+		}
+	}
+	return schedulers[names[len(names)-1]], nil
+}
+
+// systemdScheduler installs a systemd user timer.
+type systemdScheduler struct{}
+
+func (systemdScheduler) Name() string { return SchedulerSystemd }
+
+// Available is false where the systemd user manager cannot be reached,
+// e.g. in WSL and containers.
+func (systemdScheduler) Available() bool { return systemdUserAvailable() }
+
+func (systemdScheduler) Install(c *Cache) (string, error) {
+	return "systemd timer", c.installSystemd()
+}
+
+func (systemdScheduler) Plan(c *Cache) (*Plan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	dir, id := systemdUnitDir(home), c.serviceID()
+	p := &Plan{Write: []string{filepath.Join(dir, systemdUnit(id, "service")), filepath.Join(dir, systemdUnit(id, "timer"))}}
+	for _, cmd := range systemdCommands(id) {
+		p.Run = append(p.Run, commandLine("systemctl", cmd.args...))
+	}
+	return p, nil
+}
+
+func (systemdScheduler) Check(c *Cache) Finding {
+	timer := systemdUnit(c.serviceID(), "timer")
+	if exec.Command("systemctl", "--user", "is-enabled", "--quiet", timer).Run() != nil {
+		return Finding{"service", FindingWarn, timer + " is not enabled", c.serviceFix()}
+	}
+	if exec.Command("systemctl", "--user", "is-active", "--quiet", timer).Run() != nil {
+		return Finding{"service", FindingWarn, timer + " is enabled but not active",
+			"run `systemctl --user start " + timer + "`"}
+	}
+	return Finding{"service", FindingOK, timer + " is active", ""}
+}
+
+// cronScheduler installs a line in the user's crontab.
+type cronScheduler struct{}
+
+func (cronScheduler) Name() string { return SchedulerCron }
+
+func (cronScheduler) Available() bool {
+	_, err := exec.LookPath("crontab")
+	return err == nil
+}
+
+func (cronScheduler) Install(c *Cache) (string, error) {
+	return "crontab entry", c.installCron()
+}
+
+// Plan shows the entry, as the crontab is not a file basar writes.
+func (cronScheduler) Plan(c *Cache) (*Plan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	return &Plan{Run: []string{"crontab -, setting the entry: " + cronLine(c.job(home))}}, nil
+}
+
+func (cronScheduler) Check(c *Cache) Finding { return c.checkCron() }
+
+// launchdScheduler installs a launchd user agent on macOS.
+type launchdScheduler struct{}
+
+func (launchdScheduler) Name() string { return SchedulerLaunchd }
+
+func (launchdScheduler) Available() bool { return runtime.GOOS == "darwin" }
+
+func (launchdScheduler) Install(c *Cache) (string, error) {
+	return "launchd agent", c.installLaunchd()
+}
+
+func (launchdScheduler) Plan(c *Cache) (*Plan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	plist := launchdPlistPath(home, c.serviceID())
+	return &Plan{
+		Write: []string{plist},
+		Run:   []string{commandLine("launchctl", "unload", plist), commandLine("launchctl", "load", "-w", plist)},
+	}, nil
+}
+
+func (launchdScheduler) Check(c *Cache) Finding {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Finding{"service", FindingError, err.Error(), ""}
+	}
+	if _, err := os.Stat(launchdPlistPath(home, c.serviceID())); err != nil {
+		return Finding{"service", FindingWarn, "launchd agent not installed", c.serviceFix()}
+	}
+	return Finding{"service", FindingOK, "launchd agent installed", ""}
+}
+
+// schtasksScheduler installs a Windows Scheduled Task.
+type schtasksScheduler struct{}
+
+func (schtasksScheduler) Name() string { return SchedulerSchtasks }
+
+func (schtasksScheduler) Available() bool { return runtime.GOOS == "windows" }
+
+func (schtasksScheduler) Install(c *Cache) (string, error) {
+	return "scheduled task", c.installSchtasks()
+}
+
+func (schtasksScheduler) Plan(c *Cache) (*Plan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	return &Plan{Run: []string{commandLine("schtasks", schtasksArgs(c.job(home))...)}}, nil
+}
+
+func (schtasksScheduler) Check(c *Cache) Finding {
+	task := scheduledTaskName(c.serviceID())
+	if exec.Command("schtasks", "/Query", "/TN", task).Run() != nil {
+		return Finding{"service", FindingWarn, "scheduled task " + task + " not found", c.serviceFix()}
+	}
+	return Finding{"service", FindingOK, "scheduled task " + task + " installed", ""}
+}
+
+// daemonScheduler leaves updates to basar serve.
+type daemonScheduler struct{}
+
+func (daemonScheduler) Name() string { return SchedulerDaemon }
+
+func (daemonScheduler) Available() bool { return true }
+
+func (daemonScheduler) Install(c *Cache) (string, error) { return "", nil }
+
+func (daemonScheduler) Plan(c *Cache) (*Plan, error) {
+	return &Plan{Notes: []string{"nothing installed; run basar serve to keep the cache fresh"}}, nil
+}
+
+func (daemonScheduler) Check(c *Cache) Finding {
+	return Finding{"service", FindingOK, "updates are left to basar serve", ""}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestCheckScheduler(t *testing.T) {
+	for _, name := range ServiceInstallers() {
+		if err := CheckScheduler(name); err != nil {
+			t.Errorf("CheckScheduler(%q) = %v", name, err)
+		}
+		if s := schedulers[name]; s == nil || s.Name() != name {
+			t.Errorf("scheduler %q is registered as %v", name, s)
+		}
+	}
+	if err := CheckScheduler("at"); err == nil {
+		t.Error("CheckScheduler(at) succeeded")
+	}
+	if runtime.GOOS != "darwin" && CheckScheduler(SchedulerLaunchd) == nil {
+		t.Errorf("CheckScheduler(launchd) succeeded on %s", runtime.GOOS)
+	}
+}
+
+func TestCacheScheduler(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+
+	cfg.Scheduler = SchedulerDaemon
+	s, err := c.Scheduler()
+	if err != nil || s.Name() != SchedulerDaemon {
+		t.Fatalf("Scheduler() = %v, %v; expected the daemon scheduler", s, err)
+	}
+	if what, err := c.InstallService(); what != "" || err != nil {
+		t.Errorf("InstallService() with the daemon scheduler = %q, %v; expected nothing installed", what, err)
+	}
+	if f := c.checkService(); f.Severity != FindingOK {
+		t.Errorf("checkService() with the daemon scheduler = %+v", f)
+	}
+
+	cfg.Scheduler = "at"
+	if _, err := c.Scheduler(); err == nil {
+		t.Error("Scheduler() accepted an unknown scheduler")
+	}
+
+	cfg.Scheduler = ""
+	s, err = c.Scheduler()
+	if platform := platformSchedulers(); len(platform) == 0 {
+		if err == nil {
+			t.Errorf("Scheduler() = %v on %s, expected none", s, runtime.GOOS)
+		}
+	} else if err != nil || !slices.Contains(platform, s.Name()) {
+		t.Errorf("Scheduler() = %v, %v; expected one of %v", s, err, platform)
+	}
+}
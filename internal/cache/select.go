@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrNoSourcesSelected indicates a selective update matched none of the
+// configured sources.
+var ErrNoSourcesSelected = errors.New("no configured source matches")
+
+// ErrUnknownSource indicates a source that is not configured, or disabled.
+var ErrUnknownSource = errors.New("not a configured source")
+
+// selectSources splits the configured sources into those an update fetches,
+// the ones matching any of selectors (all when there are none), and those
+// it keeps, reusing their last fetched data. Offline, network sources are
+// always kept.
+func (c *Cache) selectSources(selectors []string) (fetch, keep []string, err error) {
+	if len(selectors) == 0 && !c.cfg.Offline {
+		return c.cfg.Sources, nil, nil
+	}
+
+	matched := 0
+	for _, source := range c.cfg.Sources {
+		if len(selectors) > 0 && !slices.ContainsFunc(selectors, func(sel string) bool { return c.cfg.Selects(sel, source) }) {
+			keep = append(keep, source)
+			continue
+		}
+		matched++
+		if c.cfg.Offline && !fetcher.IsLocal(source) {
+			keep = append(keep, source)
+		} else {
+			fetch = append(fetch, source)
+		}
+	}
+	switch {
+	case len(selectors) > 0 && matched == 0:
+		return nil, nil, fmt.Errorf("%w %s", ErrNoSourcesSelected, strings.Join(selectors, ", "))
+	case len(fetch) == 0 && len(c.cfg.Sources) > 0:
+		return nil, nil, fmt.Errorf("%w: no local sources to update", fetcher.ErrOffline)
+	}
+	return fetch, keep, nil
+}
+
+// lastData returns the data last fetched from source: its snapshot, or the
+// merged cache for sources fetched before snapshots existed. It returns nil
+// if there is neither.
+func (c *Cache) lastData(source string) *fetcher.BannerData {
+	if snap := c.loadSnapshot(source); snap != nil {
+		return snap
+	}
+	return c.loadExistingBanners()
+}
+
+// byPriority orders merge inputs by source priority, highest first, keeping
+// the configured order among sources of equal priority. Earlier sources'
+// URLs come first in the merge, and volatility3 tries them in order.
+func (c *Cache) byPriority(sources []string, datasets []*fetcher.BannerData) ([]string, []*fetcher.BannerData) {
+	order := make([]int, len(sources))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return c.cfg.SourceOptions(sources[order[a]]).Priority > c.cfg.SourceOptions(sources[order[b]]).Priority
+	})
+
+	sorted := make([]string, len(sources))
+	sortedData := make([]*fetcher.BannerData, len(datasets))
+	for i, j := range order {
+		sorted[i], sortedData[i] = sources[j], datasets[j]
+	}
+	return sorted, sortedData
+}
+
+// withKept adds the last fetched data of the kept sources to the merge
+// inputs, ordering them all as configured so source priority is unchanged.
+func (c *Cache) withKept(keep, sources []string, datasets []*fetcher.BannerData) ([]string, []*fetcher.BannerData) {
+	if len(keep) == 0 {
+		return sources, datasets
+	}
+
+	bySource := make(map[string]*fetcher.BannerData, len(sources)+len(keep))
+	for i, source := range sources {
+		bySource[source] = datasets[i]
+	}
+	for _, source := range keep {
+		if data := c.lastData(source); data != nil {
+			bySource[source] = data
+		} else {
+			c.log.Warn("no previous data for source kept out of the update", "source", source)
+		}
+	}
+
+	sources, datasets = nil, nil
+	for _, source := range c.cfg.Sources {
+		if data, ok := bySource[source]; ok {
+			sources = append(sources, source)
+			datasets = append(datasets, data)
+		}
+	}
+	return sources, datasets
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestSelectiveUpdate(t *testing.T) {
+	cfg := testConfig(t)
+	ubuntu := filepath.Join(cfg.ConfigDir, "ubuntu.json")
+	debian := filepath.Join(cfg.ConfigDir, "debian.json")
+	writeBannerSource(t, ubuntu, bannerURLs("ubuntu-1"))
+	writeBannerSource(t, debian, bannerURLs("debian-1"))
+	cfg.Sources = []string{debian, ubuntu}
+	cfg.Options = map[string]config.SourceOptions{ubuntu: {Tags: []string{"ubuntu"}}}
+	c := New(cfg)
+	ctx := context.Background()
+
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the ubuntu source is refetched; debian keeps its snapshot even
+	// though its file changed
+	writeBannerSource(t, ubuntu, bannerURLs("ubuntu-1", "ubuntu-2"))
+	writeBannerSource(t, debian, bannerURLs("debian-2"))
+	cfg.Only = []string{"tag=ubuntu"}
+
+	res, err := c.Update(ctx, true)
+	if err != nil {
+		t.Fatalf("selective Update() failed: %v", err)
+	}
+	if len(res.Sources) != 1 || res.Sources[0].Source != ubuntu {
+		t.Errorf("only the selected source should be fetched, got %+v", res.Sources)
+	}
+
+	matches, _ := c.Lookup("-")
+	if got, want := banners(matches), []string{"debian-1", "ubuntu-1", "ubuntu-2"}; !slices.Equal(got, want) {
+		t.Errorf("banners = %v, expected %v", got, want)
+	}
+	if prov := c.loadProvenance(); !slices.Equal(prov["debian-1"], []string{debian}) {
+		t.Errorf("kept source should keep its provenance, got %v", prov["debian-1"])
+	}
+	if _, ok := c.loadMeta().Sources[debian]; !ok {
+		t.Error("kept source should keep its metadata")
+	}
+
+	// SmartUpdate selects the same way
+	cfg.Only = []string{debian}
+	if res, err := c.SmartUpdate(ctx); err != nil || len(res.Sources) != 1 || res.Sources[0].Source != debian {
+		t.Errorf("selective SmartUpdate() = %+v, %v", res, err)
+	}
+	matches, _ = c.Lookup("-")
+	if got, want := banners(matches), []string{"debian-2", "ubuntu-1", "ubuntu-2"}; !slices.Equal(got, want) {
+		t.Errorf("banners = %v, expected %v", got, want)
+	}
+}
+
+func TestSelectiveUpdateNoMatch(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Only = []string{"tag=missing"}
+
+	if _, err := New(cfg).Update(context.Background(), true); !errors.Is(err, ErrNoSourcesSelected) {
+		t.Errorf("Update() = %v, expected ErrNoSourcesSelected", err)
+	}
+	if _, err := os.Stat(cfg.HistoryFile); !os.IsNotExist(err) {
+		t.Error("a selection matching nothing should not be recorded as an update")
+	}
+}
+
+func TestRefreshSource(t *testing.T) {
+	cfg := testConfig(t)
+	first := filepath.Join(cfg.ConfigDir, "first.json")
+	second := filepath.Join(cfg.ConfigDir, "second.json")
+	writeBannerSource(t, first, bannerURLs("a"))
+	writeBannerSource(t, second, bannerURLs("b"))
+	cfg.Sources = []string{first, second}
+	c := New(cfg)
+	ctx := context.Background()
+
+	if _, err := c.Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	writeBannerSource(t, first, bannerURLs("a2"))
+	writeBannerSource(t, second, bannerURLs("b2"))
+	res, err := c.RefreshSource(ctx, second)
+	if err != nil {
+		t.Fatalf("RefreshSource() failed: %v", err)
+	}
+	if !res.Updated || len(res.Sources) != 1 {
+		t.Errorf("RefreshSource() = %+v, expected one source fetched", res)
+	}
+	matches, _ := c.Lookup("")
+	if got, want := banners(matches), []string{"a", "b2"}; !slices.Equal(got, want) {
+		t.Errorf("banners = %v, expected %v", got, want)
+	}
+
+	if _, err := c.RefreshSource(ctx, "tag=x"); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("RefreshSource() of an unknown source = %v, expected ErrUnknownSource", err)
+	}
+}
+
+func TestOfflineUpdate(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"remote":["https://example.com/remote"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	writeBannerSource(t, local, bannerURLs("a"))
+	cfg.Sources = []string{server.URL, local}
+	ctx := context.Background()
+
+	if _, err := New(cfg).Update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Offline, the network source keeps its last data and the local one
+	// is refetched
+	cfg.Offline = true
+	c := New(cfg)
+	writeBannerSource(t, local, bannerURLs("a2"))
+	res, err := c.Update(ctx, true)
+	if err != nil {
+		t.Fatalf("Update() offline failed: %v", err)
+	}
+	if len(res.Sources) != 1 || res.Sources[0].Source != local {
+		t.Errorf("Update() offline fetched %+v, expected only the local source", res.Sources)
+	}
+	if hits != 1 {
+		t.Errorf("offline update made %d requests, expected none", hits-1)
+	}
+	matches, _ := c.Lookup("")
+	if got, want := banners(matches), []string{"a2", "remote"}; !slices.Equal(got, want) {
+		t.Errorf("banners = %v, expected %v", got, want)
+	}
+
+	// An expired cache is used as is
+	expired := time.Now().Add(-2 * cfg.TTL)
+	_ = os.Chtimes(cfg.CacheFile, expired, expired)
+	if err := c.Ensure(ctx); err != nil {
+		t.Errorf("Ensure() offline with an expired cache = %v", err)
+	}
+	if info, _ := os.Stat(cfg.CacheFile); !info.ModTime().Equal(expired) {
+		t.Error("Ensure() offline should not update an expired cache")
+	}
+
+	// Without local sources there is nothing to update
+	cfg.Sources = []string{server.URL}
+	if _, err := New(cfg).Update(ctx, true); !errors.Is(err, fetcher.ErrOffline) {
+		t.Errorf("Update() offline without local sources = %v, expected ErrOffline", err)
+	}
+}
+
+func TestUpdateSourcePriority(t *testing.T) {
+	cfg := testConfig(t)
+	public := filepath.Join(cfg.ConfigDir, "public.json")
+	mirror := filepath.Join(cfg.ConfigDir, "mirror.json")
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	for path, host := range map[string]string{public: "public.example", mirror: "mirror.internal", local: "local.internal"} {
+		raw := `{"version":1,"linux":{"banner1":["https://` + host + `/b1.json"]}}`
+		if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg.Sources = []string{public, local, mirror}
+	cfg.Options = map[string]config.SourceOptions{mirror: {Priority: 10}, local: {Priority: -1}}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := c.Lookup("banner1")
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("Lookup() = %v, %v", matches, err)
+	}
+	want := []string{"https://mirror.internal/b1.json", "https://public.example/b1.json", "https://local.internal/b1.json"}
+	if !slices.Equal(matches[0].URLs, want) {
+		t.Errorf("URLs = %q, expected %q", matches[0].URLs, want)
+	}
+}
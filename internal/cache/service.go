@@ -0,0 +1,455 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LaunchdLabel identifies the launchd agent installed on macOS. Like the
+// other names of a schedule, it is suffixed with the instance's ID for
+// profiles and other isolated instances (see serviceID).
+const LaunchdLabel = "com.github.calilkhalil.basar"
+
+// ScheduledTaskName names the Scheduled Task installed on Windows.
+const ScheduledTaskName = "basar-update"
+
+// cronMarker tags the crontab line managed by basar.
+const cronMarker = "# basar auto-update"
+
+// A job is the scheduled update of one basar instance: the binary, the
+// flags selecting the instance's files, the time of day it runs at, and
+// the ID telling its schedule from other instances'.
+type job struct {
+	binary string
+	args   []string
+	at     time.Duration
+	id     string
+}
+
+// job returns the scheduled update of c's instance, with the overrides
+// it was selected with. Paths given relative are made absolute, as
+// schedulers run jobs from another directory, and --offline, which is
+// about the installing run, is left out.
+func (c *Cache) job(home string) job {
+	o := c.cfg.Overrides
+	for _, path := range []*string{&o.ConfigFile, &o.CacheDir, &o.FallbackCacheDir} {
+		if *path == "" || *path == "tmpfs" {
+			continue
+		}
+		if abs, err := filepath.Abs(*path); err == nil {
+			*path = abs
+		}
+	}
+	o.Offline = false
+	return job{binary: basarBinary(home), args: slices.Clip(o.Args()), at: c.scheduleTime(), id: c.serviceID()}
+}
+
+// serviceID returns the ID the schedule of c's instance is named with: ""
+// for the default instance, the profile for a profile, and a short hash
+// of the overridden paths, after the profile if any, for instances given
+// their own config or cache, so installing one never replaces another's.
+func (c *Cache) serviceID() string {
+	o := c.cfg.Overrides
+	if o.ConfigFile == "" && o.CacheDir == "" {
+		return o.Profile
+	}
+	sum := sha256.Sum256([]byte(c.cfg.ConfigFile + "\x00" + c.cfg.CacheDir))
+	id := hex.EncodeToString(sum[:4])
+	if o.Profile != "" {
+		id = o.Profile + "-" + id
+	}
+	return id
+}
+
+// instanceName returns base for the default instance and base followed by
+// sep and id for others.
+func instanceName(base, sep, id string) string {
+	if id == "" {
+		return base
+	}
+	return base + sep + id
+}
+
+// InstallService installs a scheduled `basar --smart-update` for the
+// current user with the scheduler Scheduler picks: a systemd user timer on
+// Linux (or a crontab entry where the systemd user manager is unavailable,
+// e.g. WSL and containers), a launchd agent on macOS, a Scheduled Task on
+// Windows, or a crontab entry on other Unixes. It returns a description of
+// what was installed, "" for SchedulerDaemon, which installs nothing.
+func (c *Cache) InstallService() (string, error) {
+	s, err := c.Scheduler()
+	if err != nil {
+		return "", err
+	}
+	return s.Install(c)
+}
+
+// basarBinary locates the installed basar binary for scheduled runs.
+func basarBinary(home string) string {
+	if path, err := exec.LookPath("basar"); err == nil {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		if path, err := os.Executable(); err == nil {
+			return path
+		}
+	}
+
+	// Try common locations
+	path := filepath.Join(home, ".local", "bin", "basar")
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return "/usr/local/bin/basar"
+}
+
+// installSystemd installs and starts a systemd user timer.
+func (c *Cache) installSystemd() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+
+	systemdDir := systemdUnitDir(home)
+	if err := os.MkdirAll(systemdDir, DirMode); err != nil {
+		return fmt.Errorf("creating systemd dir: %w", err)
+	}
+
+	j := c.job(home)
+	servicePath := filepath.Join(systemdDir, systemdUnit(j.id, "service"))
+	if err := os.WriteFile(servicePath, []byte(systemdService(j)), FileMode); err != nil {
+		return fmt.Errorf("writing service file: %w", err)
+	}
+
+	// Timer file - runs on 1st and 15th of each month
+	timerPath := filepath.Join(systemdDir, systemdUnit(j.id, "timer"))
+	if err := os.WriteFile(timerPath, []byte(systemdTimer(j.at)), FileMode); err != nil {
+		return fmt.Errorf("writing timer file: %w", err)
+	}
+
+	// Enable and start timer
+	for _, cmd := range systemdCommands(j.id) {
+		if err := exec.Command("systemctl", cmd.args...).Run(); err != nil {
+			return fmt.Errorf("%s failed: %w", cmd.step, err)
+		}
+	}
+
+	return nil
+}
+
+// A systemctlStep is a systemctl run, with the step it is for errors.
+type systemctlStep struct {
+	step string
+	args []string
+}
+
+// systemdCommands returns the systemctl runs enabling and starting the
+// timer of instance id once its units are written.
+func systemdCommands(id string) []systemctlStep {
+	timer := systemdUnit(id, "timer")
+	return []systemctlStep{
+		{"daemon-reload", []string{"--user", "daemon-reload"}},
+		{"enabling timer", []string{"--user", "enable", timer}},
+		{"starting timer", []string{"--user", "start", timer}},
+	}
+}
+
+// systemdUnit returns the name of the systemd unit of instance id with
+// extension ext, e.g. basar-work.timer; a timer starts the service of the
+// same name.
+func systemdUnit(id, ext string) string {
+	return instanceName("basar", "-", id) + "." + ext
+}
+
+// systemdService returns the service unit running j.
+func systemdService(j job) string {
+	words := []string{systemdQuote(j.binary)}
+	for _, arg := range append(j.args, "--smart-update") {
+		words = append(words, systemdQuote(arg))
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Update basar ISF symbol cache
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=%s
+# Exit status 3: updated, but some sources failed
+SuccessExitStatus=3
+Nice=19
+IOSchedulingClass=idle
+
+[Install]
+WantedBy=default.target
+`, strings.Join(words, " "))
+}
+
+// systemdQuote quotes s as a word of an ExecStart= line where it needs
+// it, doubling the % systemd takes for specifiers.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// systemdUnitDir returns the directory of the systemd user units in home.
+func systemdUnitDir(home string) string {
+	return filepath.Join(home, ".config", "systemd", "user")
+}
+
+// systemdTimer returns a timer unit running basar.service on the 1st and
+// 15th of each month at time of day at. RandomizedDelaySec adds a fresh
+// random delay on every run on top of the host's fixed splay offset.
+func systemdTimer(at time.Duration) string {
+	h, m, s := clock(at)
+	return fmt.Sprintf(`[Unit]
+Description=Update basar ISF symbol cache periodically
+
+[Timer]
+OnCalendar=*-*-01,15 %02d:%02d:%02d
+RandomizedDelaySec=3600
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, h, m, s)
+}
+
+// clock splits a time of day into hours, minutes, and seconds.
+func clock(at time.Duration) (h, m, s int) {
+	secs := int(at / time.Second)
+	return secs / 3600, secs / 60 % 60, secs % 60
+}
+
+// systemdUserAvailable reports whether `systemctl --user` can reach a user
+// service manager.
+func systemdUserAvailable() bool {
+	return exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+// installCron adds (or replaces) basar's line in the user's crontab.
+func (c *Cache) installCron() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+
+	// crontab -l fails when the user has no crontab yet
+	existing, _ := exec.Command("crontab", "-l").Output()
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(cronTable(string(existing), c.job(home)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("installing crontab failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// cronTable returns existing with the entry of j replaced, in place, or
+// appended; the user's other lines, blank ones and comments included, and
+// the entries of other instances are kept as they were. The entry runs
+// `basar --smart-update --low-priority` on the 1st and 15th of each month
+// at the job's time of day (to the minute), matching the systemd timer,
+// which sets the priority itself.
+func cronTable(existing string, j job) string {
+	entry, marker := cronLine(j), cronMarkerOf(j.id)
+	var lines []string
+	if existing != "" {
+		lines = strings.Split(strings.TrimSuffix(existing, "\n"), "\n")
+	}
+
+	var b strings.Builder
+	replaced := false
+	for _, line := range lines {
+		if hasCronMarker(line, marker) {
+			if replaced {
+				continue
+			}
+			line, replaced = entry, true
+		}
+		b.WriteString(line + "\n")
+	}
+	if !replaced {
+		b.WriteString(entry + "\n")
+	}
+	return b.String()
+}
+
+// cronLine returns the crontab entry of j, as cronTable adds it. cron
+// ends the command at a bare %, so those are escaped.
+func cronLine(j job) string {
+	h, m, _ := clock(j.at)
+	binary := "'" + strings.ReplaceAll(j.binary, "'", `'\''`) + "'"
+	command := commandLine(binary, append(j.args, "--smart-update", "--low-priority")...)
+	return fmt.Sprintf("%d %d 1,15 * * %s >/dev/null 2>&1 %s",
+		m, h, strings.ReplaceAll(command, "%", `\%`), cronMarkerOf(j.id))
+}
+
+// cronMarkerOf returns the marker of the crontab entry of instance id.
+func cronMarkerOf(id string) string {
+	return instanceName(cronMarker, " ", id)
+}
+
+// hasCronMarker reports whether line is the entry marker tags, and not
+// that of another instance.
+func hasCronMarker(line, marker string) bool {
+	i := strings.LastIndex(line, cronMarker)
+	return i >= 0 && line[i:] == marker
+}
+
+// installLaunchd writes and loads a launchd user agent.
+func (c *Cache) installLaunchd() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+
+	j := c.job(home)
+	plistPath := launchdPlistPath(home, j.id)
+	if err := os.MkdirAll(filepath.Dir(plistPath), DirMode); err != nil {
+		return fmt.Errorf("creating LaunchAgents dir: %w", err)
+	}
+	if err := os.MkdirAll(c.cfg.StateDir, DirMode); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
+
+	plist := launchdPlist(j, filepath.Join(c.cfg.StateDir, "launchd.log"))
+	if err := os.WriteFile(plistPath, []byte(plist), FileMode); err != nil {
+		return fmt.Errorf("writing launchd agent: %w", err)
+	}
+
+	// Reload so a reinstall picks up changes; unloading fails harmlessly
+	// when the agent was not loaded yet
+	_ = exec.Command("launchctl", "unload", plistPath).Run()
+	if err := exec.Command("launchctl", "load", "-w", plistPath).Run(); err != nil {
+		return fmt.Errorf("loading launchd agent failed: %w", err)
+	}
+
+	return nil
+}
+
+// launchdLabel returns the label of the launchd agent of instance id.
+func launchdLabel(id string) string {
+	return instanceName(LaunchdLabel, ".", id)
+}
+
+// launchdPlistPath returns where the launchd agent of instance id is
+// installed in home.
+func launchdPlistPath(home, id string) string {
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel(id)+".plist")
+}
+
+// launchdPlist returns a launchd agent running j, `basar --smart-update`,
+// on the 1st and 15th of each month at the job's time of day, matching
+// the systemd timer.
+func launchdPlist(j job, logPath string) string {
+	h, m, _ := clock(j.at)
+	var program strings.Builder
+	for _, arg := range append([]string{j.binary}, append(j.args, "--smart-update")...) {
+		program.WriteString("\t\t<string>" + xmlEscape(arg) + "</string>\n")
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartCalendarInterval</key>
+	<array>
+		<dict>
+			<key>Day</key>
+			<integer>1</integer>
+			<key>Hour</key>
+			<integer>%d</integer>
+			<key>Minute</key>
+			<integer>%d</integer>
+		</dict>
+		<dict>
+			<key>Day</key>
+			<integer>15</integer>
+			<key>Hour</key>
+			<integer>%d</integer>
+			<key>Minute</key>
+			<integer>%d</integer>
+		</dict>
+	</array>
+	<key>ProcessType</key>
+	<string>Background</string>
+	<key>LowPriorityIO</key>
+	<true/>
+	<key>Nice</key>
+	<integer>19</integer>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, xmlEscape(launchdLabel(j.id)), program.String(), h, m, h, m, xmlEscape(logPath), xmlEscape(logPath))
+}
+
+// xmlEscape escapes s for use as XML character data.
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// installSchtasks creates or replaces a Windows Scheduled Task.
+func (c *Cache) installSchtasks() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+
+	out, err := exec.Command("schtasks", schtasksArgs(c.job(home))...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("creating scheduled task failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// scheduledTaskName returns the name of the Scheduled Task of instance id.
+func scheduledTaskName(id string) string {
+	return instanceName(ScheduledTaskName, "-", id)
+}
+
+// schtasksArgs returns the schtasks arguments creating a task that runs j,
+// `basar --smart-update --low-priority`, on the 1st and 15th of each month
+// at the job's time of day, matching the systemd timer.
+func schtasksArgs(j job) []string {
+	h, m, _ := clock(j.at)
+	command := []string{`"` + j.binary + `"`}
+	for _, arg := range append(j.args, "--smart-update", "--low-priority") {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		command = append(command, arg)
+	}
+	return []string{
+		"/Create", "/F",
+		"/TN", scheduledTaskName(j.id),
+		"/SC", "MONTHLY",
+		"/D", "1,15",
+		"/ST", fmt.Sprintf("%02d:%02d", h, m),
+		"/TR", strings.Join(command, " "),
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestServiceInstallers(t *testing.T) {
+	installers := ServiceInstallers()
+	if len(installers) == 0 || installers[len(installers)-1] != SchedulerDaemon {
+		t.Errorf("installers = %v, expected %s last", installers, SchedulerDaemon)
+	}
+
+	switch runtime.GOOS {
+	case "linux", "darwin", "windows", "freebsd", "openbsd", "netbsd", "dragonfly", "solaris", "illumos":
+		if len(installers) < 2 {
+			t.Errorf("expected a system scheduler on %s", runtime.GOOS)
+		}
+	default:
+		if len(installers) != 1 {
+			t.Errorf("unexpected installers on %s: %v", runtime.GOOS, installers)
+		}
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := launchdPlist(job{binary: "/Users/a&b/bin/basar", at: 6*time.Hour + 17*time.Minute}, "/Users/a&b/Library/basar/launchd.log")
+
+	// The plist must be well-formed XML with the path escaped
+	dec := xml.NewDecoder(strings.NewReader(plist))
+	dec.Strict = false
+	var strs []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			if s := strings.TrimSpace(string(cd)); s != "" {
+				strs = append(strs, s)
+			}
+		}
+	}
+
+	for _, want := range []string{LaunchdLabel, "/Users/a&b/bin/basar", "--smart-update", "15", "17"} {
+		if !slices.Contains(strs, want) {
+			t.Errorf("plist missing %q", want)
+		}
+	}
+
+	if strings.Contains(plist, "a&b") {
+		t.Error("paths should be XML-escaped")
+	}
+}
+
+func TestSchtasksArgs(t *testing.T) {
+	args := schtasksArgs(job{binary: `C:\Program Files\basar\basar.exe`, at: 7*time.Hour + 5*time.Minute + 30*time.Second})
+
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "/TN "+ScheduledTaskName) {
+		t.Errorf("task name missing: %v", args)
+	}
+	if !strings.Contains(joined, "/ST 07:05") {
+		t.Errorf("start time missing: %v", args)
+	}
+	if tr := args[len(args)-1]; tr != `"C:\Program Files\basar\basar.exe" --smart-update --low-priority` {
+		t.Errorf("/TR should quote the binary path, got %s", tr)
+	}
+}
+
+func TestCronTable(t *testing.T) {
+	existing := "MAILTO=me@example.com\n30 2 * * * backup.sh\n0 6 1,15 * * '/old/basar' --smart-update >/dev/null 2>&1 " + cronMarker + "\n"
+
+	j := job{binary: "/j/u/.local/bin/basar", at: 6 * time.Hour}
+	table := cronTable(existing, j)
+
+	if !strings.Contains(table, "30 2 * * * backup.sh\n") || !strings.HasPrefix(table, "MAILTO=") {
+		t.Errorf("existing entries should be kept:\n%s", table)
+	}
+	if strings.Contains(table, "/old/basar") {
+		t.Errorf("previous basar entry should be replaced:\n%s", table)
+	}
+	if strings.Count(table, cronMarker) != 1 || !strings.Contains(table, "'/j/u/.local/bin/basar' --smart-update --low-priority") {
+		t.Errorf("expected exactly one new basar entry:\n%s", table)
+	}
+
+	// Installing again is idempotent
+	if again := cronTable(table, j); again != table {
+		t.Errorf("reinstall changed the crontab:\n%s", again)
+	}
+
+	// A splay offset moves the entry, replacing the old one
+	moved := cronTable(table, job{binary: j.binary, at: 9*time.Hour + 42*time.Minute})
+	if strings.Count(moved, cronMarker) != 1 || !strings.Contains(moved, "42 9 1,15 * * ") {
+		t.Errorf("expected the entry at 09:42:\n%s", moved)
+	}
+
+	// The user's lines stay exactly as they were, blank ones included,
+	// with the entry replaced where it was
+	spaced := "MAILTO=me@example.com\n\n# backups\n30 2 * * * backup.sh\n\n0 6 1,15 * * '/old/basar' --smart-update " + cronMarker + "\n\n5 4 * * * other.sh\n"
+	expected := strings.Replace(spaced, "0 6 1,15 * * '/old/basar' --smart-update "+cronMarker,
+		cronLine(j), 1)
+	if got := cronTable(spaced, j); got != expected {
+		t.Errorf("cronTable() = %q, expected %q", got, expected)
+	}
+	if got := cronTable("30 2 * * * backup.sh", job{binary: "/b"}); got != "30 2 * * * backup.sh\n"+cronLine(job{binary: "/b"})+"\n" {
+		t.Errorf("cronTable() without a final newline = %q", got)
+	}
+}
+
+func TestSystemdTimer(t *testing.T) {
+	timer := systemdTimer(6*time.Hour + 3*time.Minute + 9*time.Second)
+
+	for _, want := range []string{"OnCalendar=*-*-01,15 06:03:09\n", "RandomizedDelaySec=3600\n", "Persistent=true\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer missing %q:\n%s", want, timer)
+		}
+	}
+}
+
+func TestScheduledJobInstances(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	cfg := testConfig(t)
+	cfg.Profile = "work"
+	cfg.Overrides = config.Overrides{Profile: "work", Offline: true}
+	c := New(cfg)
+
+	j := c.job(home)
+	if j.id != "work" || !slices.Equal(j.args, []string{"--profile", "work"}) {
+		t.Fatalf("job() = %+v, expected the work profile without --offline", j)
+	}
+	if service := systemdService(j); !strings.Contains(service, " --profile work --smart-update\n") {
+		t.Errorf("service does not update the profile:\n%s", service)
+	}
+	if entry := cronLine(j); !strings.Contains(entry, "' --profile work --smart-update --low-priority ") || !strings.HasSuffix(entry, cronMarker+" work") {
+		t.Errorf("cron entry = %q", entry)
+	}
+	if plist := launchdPlist(j, "/tmp/launchd.log"); !strings.Contains(plist, "<string>--profile</string>\n\t\t<string>work</string>") ||
+		!strings.Contains(plist, "<string>"+LaunchdLabel+".work</string>") {
+		t.Errorf("plist does not update the profile:\n%s", plist)
+	}
+	if args := strings.Join(schtasksArgs(j), " "); !strings.Contains(args, "/TN "+ScheduledTaskName+"-work ") || !strings.HasSuffix(args, " --profile work --smart-update --low-priority") {
+		t.Errorf("schtasks args = %s", args)
+	}
+
+	// The dry run shows the job installed
+	p, err := cronScheduler{}.Plan(c)
+	if err != nil || len(p.Run) != 1 || !strings.Contains(p.Run[0], "--profile work --smart-update") {
+		t.Errorf("cron plan = %+v, %v; expected the profile's entry", p, err)
+	}
+	p, err = systemdScheduler{}.Plan(c)
+	if err != nil || !slices.Contains(p.Write, filepath.Join(systemdUnitDir(home), "basar-work.timer")) {
+		t.Errorf("systemd plan = %+v, %v; expected basar-work.timer", p, err)
+	}
+
+	// Each instance keeps its own entry, and its own paths are absolute
+	def := job{binary: "/b", at: 6 * time.Hour}
+	table := cronTable(cronTable("", def), j)
+	if again := cronTable(table, def); again != table || strings.Count(table, cronMarker) != 2 {
+		t.Errorf("installing one instance replaced another's entry:\n%s", table)
+	}
+	cfg.Overrides = config.Overrides{CacheDir: "case"}
+	isolated := c.job(home)
+	abs, _ := filepath.Abs("case")
+	if isolated.id == "" || isolated.id == j.id || !slices.Equal(isolated.args, []string{"--cache-dir", abs}) {
+		t.Errorf("job() with a cache dir = %+v", isolated)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	for s, expected := range map[string]string{
+		"/usr/bin/basar":     "/usr/bin/basar",
+		"/home/a b/basar":    `"/home/a b/basar"`,
+		"/tmp/100%":          "/tmp/100%%",
+		`/tmp/"quoted"\path`: `"/tmp/\"quoted\"\\path"`,
+	} {
+		if got := systemdQuote(s); got != expected {
+			t.Errorf("systemdQuote(%q) = %q, expected %q", s, got, expected)
+		}
+	}
+}
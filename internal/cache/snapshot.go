@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// snapshotDir returns the directory holding per-source snapshots.
+func (c *Cache) snapshotDir() string {
+	return c.cfg.SnapshotDir
+}
+
+// snapshotPath returns the snapshot file for a source.
+func (c *Cache) snapshotPath(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(c.snapshotDir(), hex.EncodeToString(sum[:8])+".json")
+}
+
+// saveSnapshot stores the last successfully fetched data for a source, so
+// later merges can reuse it when the source reports no changes.
+func (c *Cache) saveSnapshot(source string, data *fetcher.BannerData) error {
+	if err := os.MkdirAll(c.snapshotDir(), DirMode); err != nil {
+		return fmt.Errorf("creating snapshot dir: %w", err)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	return writeFileAtomic(c.snapshotPath(source), stampFormat(raw, SnapshotFormat))
+}
+
+// loadSnapshot returns the stored data for a source, or nil if none exists
+// or a newer basar wrote it.
+func (c *Cache) loadSnapshot(source string) *fetcher.BannerData {
+	raw, err := os.ReadFile(c.snapshotPath(source))
+	if err != nil {
+		return nil
+	}
+
+	raw, _, err = snapshotFormat.upgrade(raw)
+	if errors.Is(err, ErrNewerFormat) {
+		c.log.Warn("ignoring snapshot", "source", source, "error", err)
+	}
+	var data fetcher.BannerData
+	if err != nil || json.Unmarshal(raw, &data) != nil {
+		return nil
+	}
+
+	return &data
+}
+
+// provenancePath returns the sidecar file recording banner provenance.
+func (c *Cache) provenancePath() string {
+	return filepath.Join(c.cfg.CacheDir, "provenance.json")
+}
+
+// saveProvenance writes the provenance sidecar next to the cache file.
+func (c *Cache) saveProvenance(prov fetcher.Provenance) error {
+	raw, err := json.Marshal(prov)
+	if err != nil {
+		return fmt.Errorf("encoding provenance: %w", err)
+	}
+
+	return writeFileAtomic(c.provenancePath(), raw)
+}
+
+// loadProvenance reads the provenance sidecar, returning nil if missing.
+func (c *Cache) loadProvenance() fetcher.Provenance {
+	raw, err := os.ReadFile(c.provenancePath())
+	if err != nil {
+		return nil
+	}
+
+	var prov fetcher.Provenance
+	if err := json.Unmarshal(raw, &prov); err != nil {
+		return nil
+	}
+
+	return prov
+}
+
+// metadataPath returns the sidecar file holding per-banner metadata.
+func (c *Cache) metadataPath() string {
+	return filepath.Join(c.cfg.CacheDir, "banner-metadata.json")
+}
+
+// saveMetadata writes the per-banner metadata sidecar next to the cache
+// file, removing it when no source provides metadata.
+func (c *Cache) saveMetadata(md fetcher.Metadata) error {
+	if len(md) == 0 {
+		if err := os.Remove(c.metadataPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("encoding metadata: %w", err)
+	}
+
+	return writeFileAtomic(c.metadataPath(), raw)
+}
+
+// loadMetadata reads the per-banner metadata sidecar, returning nil if
+// missing.
+func (c *Cache) loadMetadata() fetcher.Metadata {
+	raw, err := os.ReadFile(c.metadataPath())
+	if err != nil {
+		return nil
+	}
+
+	var md fetcher.Metadata
+	if err := json.Unmarshal(raw, &md); err != nil {
+		return nil
+	}
+
+	return md
+}
+
+// writeFileAtomic writes data to path via a temp file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, FileMode); err != nil {
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// writeBannerSource writes banner data to a source file for testing.
+func writeBannerSource(t *testing.T, path string, linux map[string][]string) {
+	t.Helper()
+
+	raw, err := json.Marshal(&fetcher.BannerData{Version: 1, Linux: linux})
+	if err != nil {
+		t.Fatalf("failed to encode source: %v", err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+}
+
+// bannerURLs gives each of banners one URL, for writeBannerSource.
+func bannerURLs(banners ...string) map[string][]string {
+	linux := make(map[string][]string, len(banners))
+	for _, b := range banners {
+		linux[b] = []string{"https://example.com/" + b}
+	}
+	return linux
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+
+	if snap := c.loadSnapshot("https://example.com/a.json"); snap != nil {
+		t.Fatal("loadSnapshot() should return nil before any save")
+	}
+
+	data := &fetcher.BannerData{Version: 1, Linux: map[string][]string{"banner1": {"url1"}}}
+	if err := c.saveSnapshot("https://example.com/a.json", data); err != nil {
+		t.Fatalf("saveSnapshot() failed: %v", err)
+	}
+
+	snap := c.loadSnapshot("https://example.com/a.json")
+	if snap == nil || len(snap.Linux["banner1"]) != 1 {
+		t.Fatalf("loadSnapshot() = %+v, expected saved data", snap)
+	}
+
+	if c.snapshotPath("https://example.com/a.json") == c.snapshotPath("https://example.com/b.json") {
+		t.Error("different sources should map to different snapshot files")
+	}
+}
+
+func TestUpdateWritesProvenance(t *testing.T) {
+	cfg := testConfig(t)
+
+	srcA := filepath.Join(cfg.ConfigDir, "a.json")
+	srcB := filepath.Join(cfg.ConfigDir, "b.json")
+	writeBannerSource(t, srcA, map[string][]string{"shared": {"https://example.com/url1"}, "only-a": {"https://example.com/url2"}})
+	writeBannerSource(t, srcB, map[string][]string{"shared": {"https://example.com/url3"}})
+	cfg.Sources = []string{srcA, srcB}
+
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	prov := c.loadProvenance()
+	if got := prov["shared"]; len(got) != 2 {
+		t.Errorf("shared provenance = %v, expected both sources", got)
+	}
+	if got := prov["only-a"]; len(got) != 1 || got[0] != srcA {
+		t.Errorf("only-a provenance = %v, expected [%s]", got, srcA)
+	}
+
+	stats := c.Stats()
+	if stats.Provenance[srcA] != 2 || stats.Provenance[srcB] != 1 {
+		t.Errorf("Stats().Provenance = %v, expected %s=2 %s=1", stats.Provenance, srcA, srcB)
+	}
+}
+
+func TestSmartUpdateUsesSnapshotForUnmodified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(&fetcher.BannerData{
+			Version: 1,
+			Linux:   map[string][]string{"from-server": {"https://example.com/url1"}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	local := filepath.Join(cfg.ConfigDir, "local.json")
+	writeBannerSource(t, local, map[string][]string{"from-local": {"https://example.com/url2"}})
+	cfg.Sources = []string{server.URL, local}
+
+	c := New(cfg)
+	ctx := context.Background()
+
+	if _, err := c.SmartUpdate(ctx); err != nil {
+		t.Fatalf("first SmartUpdate() failed: %v", err)
+	}
+
+	// Drop the local source's banner; the server now answers 304, so its
+	// banners must come from the snapshot rather than the merged cache
+	writeBannerSource(t, local, map[string][]string{"replacement": {"https://example.com/url3"}})
+
+	if _, err := c.SmartUpdate(ctx); err != nil {
+		t.Fatalf("second SmartUpdate() failed: %v", err)
+	}
+
+	banners := c.loadExistingBanners()
+	if _, ok := banners.Linux["from-local"]; ok {
+		t.Error("stale banner from the merged cache should not survive")
+	}
+	if _, ok := banners.Linux["from-server"]; !ok {
+		t.Error("unmodified source's banner should come from its snapshot")
+	}
+
+	prov := c.loadProvenance()
+	if got := prov["from-server"]; len(got) != 1 || got[0] != server.URL {
+		t.Errorf("from-server provenance = %v, expected [%s]", got, server.URL)
+	}
+}
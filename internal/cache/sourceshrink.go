@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"sort"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// How many entry counts a source's history keeps, and how many it needs
+// before a fetch is compared with it.
+const (
+	entryHistoryLen = 10
+	entryHistoryMin = 3
+)
+
+// typicalEntries returns the median of an entry history, or 0 when it is
+// too short to tell.
+func typicalEntries(history []int) int {
+	if len(history) < entryHistoryMin {
+		return 0
+	}
+	sorted := append([]int(nil), history...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// recordEntries appends an entry count to a history, keeping the last
+// entryHistoryLen.
+func recordEntries(history []int, entries int) []int {
+	history = append(append([]int(nil), history...), entries)
+	if len(history) > entryHistoryLen {
+		history = history[len(history)-entryHistoryLen:]
+	}
+	return history
+}
+
+// checkSourceShrink compares the entry count of each changed source with
+// its history in meta, to catch a truncated upstream file before it
+// reaches the merge. A source falling below the threshold is logged and
+// marked in sources; with shrink=quarantine it is returned in the held
+// set, for the caller to merge its previous data instead, unless the
+// config accepts drops. A drop that is merged becomes the source's new
+// baseline, so it is reported once.
+func (c *Cache) checkSourceShrink(results []fetcher.Result, meta *fetcher.MetaCache, sources []SourceResult) map[string]bool {
+	held := make(map[string]bool)
+	if c.cfg.SourceShrinkThreshold <= 0 {
+		return held
+	}
+	for i, r := range results {
+		if r.Err != nil || !r.Modified || r.Data == nil {
+			continue
+		}
+		mode := c.cfg.SourceOptions(r.Source).Shrink
+		old := meta.Sources[r.Source]
+		typical := typicalEntries(old.EntryHistory)
+		if mode == config.ShrinkOff || typical == 0 ||
+			float64(len(r.Data.Linux)) >= float64(typical)*c.cfg.SourceShrinkThreshold {
+			continue
+		}
+
+		sources[i].Typical = typical
+		if mode == config.ShrinkQuarantine && !c.cfg.AcceptShrink {
+			c.log.Warn("source returned far fewer entries than usual; merging its previous data",
+				"source", r.Source, "entries", len(r.Data.Linux), "typical", typical)
+			sources[i].Held = true
+			held[r.Source] = true
+			continue
+		}
+		c.log.Warn("source returned far fewer entries than usual",
+			"source", r.Source, "entries", len(r.Data.Linux), "typical", typical)
+		old.EntryHistory = nil
+		meta.Sources[r.Source] = old
+	}
+	return held
+}
+
+// heldMeta returns old with the time of a fetch whose data was held back
+// recorded. The validators stay those of the data merged, so the source
+// is fetched in full and checked again next time.
+func heldMeta(old fetcher.SourceMeta, fetched *fetcher.SourceMeta) fetcher.SourceMeta {
+	if fetched != nil {
+		old.FetchedAt = fetched.FetchedAt
+	}
+	return old
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestTypicalEntries(t *testing.T) {
+	tests := []struct {
+		history []int
+		want    int
+	}{
+		{nil, 0},
+		{[]int{100, 100}, 0},
+		{[]int{100, 5, 120}, 100},
+		{[]int{90, 100, 110, 2000}, 110},
+	}
+	for _, tt := range tests {
+		if got := typicalEntries(tt.history); got != tt.want {
+			t.Errorf("typicalEntries(%v) = %d, expected %d", tt.history, got, tt.want)
+		}
+	}
+
+	var history []int
+	for i := 1; i <= entryHistoryLen+2; i++ {
+		history = recordEntries(history, i)
+	}
+	if len(history) != entryHistoryLen || history[0] != 3 || history[len(history)-1] != entryHistoryLen+2 {
+		t.Errorf("recordEntries() kept %v, expected the last %d counts", history, entryHistoryLen)
+	}
+}
+
+// bannerNames returns n distinct banner names.
+func bannerNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("banner-%d", i)
+	}
+	return names
+}
+
+func TestUpdateChecksSourceShrink(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SourceShrinkThreshold = 0.5
+	held := filepath.Join(cfg.ConfigDir, "held.json")
+	warned := filepath.Join(cfg.ConfigDir, "warned.json")
+	cfg.Sources = []string{held, warned}
+	cfg.Options = map[string]config.SourceOptions{held: {Shrink: config.ShrinkQuarantine}}
+	c := New(cfg)
+	ctx := context.Background()
+
+	writeBannerSource(t, held, bannerURLs(bannerNames(10)...))
+	writeBannerSource(t, warned, bannerURLs("warned-1", "warned-2", "warned-3", "warned-4"))
+	for i := 0; i < entryHistoryMin; i++ {
+		if _, err := c.Update(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := c.Stats(); stats.Sources[0].Typical != 10 || stats.Sources[1].Typical != 4 {
+		t.Errorf("Stats() sources = %+v, expected typical counts of 10 and 4", stats.Sources)
+	}
+
+	// Both sources are truncated; only the quarantined one is held back
+	writeBannerSource(t, held, bannerURLs(bannerNames(2)...))
+	writeBannerSource(t, warned, bannerURLs("warned-1"))
+	res, err := c.Update(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.Sources[0]; !s.Held || s.Typical != 10 || s.Entries != 2 {
+		t.Errorf("held source result = %+v, expected held with a typical count of 10", s)
+	}
+	if s := res.Sources[1]; s.Held || s.Typical != 4 {
+		t.Errorf("warned source result = %+v, expected merged with a typical count of 4", s)
+	}
+	if got := c.Stats().Entries; got != 11 {
+		t.Errorf("cache has %d entries, expected the held source's previous 10 and 1", got)
+	}
+
+	// The merged drop is the new baseline; the held source is checked again
+	meta := c.loadMeta()
+	if got := meta.Sources[warned].EntryHistory; !slices.Equal(got, []int{1}) {
+		t.Errorf("warned source history = %v, expected it to restart from 1", got)
+	}
+	if got := meta.Sources[held].EntryHistory; len(got) != entryHistoryMin || got[len(got)-1] != 10 {
+		t.Errorf("held source history = %v, expected the drop left out", got)
+	}
+
+	// Accepted drops are merged
+	cfg.AcceptShrink = true
+	res, err = c.Update(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sources[0].Held || c.Stats().Entries != 3 {
+		t.Errorf("accepted update held %+v, cache has %d entries; expected both sources merged", res.Sources[0], c.Stats().Entries)
+	}
+}
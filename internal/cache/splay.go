@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// SplayOffset returns this host's delay within the configured splay. It is
+// derived from a hash of the hostname rather than drawn at random, so a
+// host keeps its slot across restarts and reinstalls while a fleet spreads
+// evenly over the window.
+func (c *Cache) SplayOffset() time.Duration {
+	host, _ := os.Hostname()
+	return splayOffset(host, c.cfg.Splay)
+}
+
+// splayOffset maps host to a whole number of seconds below splay.
+func splayOffset(host string, splay time.Duration) time.Duration {
+	slots := uint64(splay / time.Second)
+	if slots == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(host))
+	return time.Duration(h.Sum64()%slots) * time.Second
+}
+
+// scheduleTime returns the time of day scheduled updates start at: 06:00
+// plus this host's splay offset.
+func (c *Cache) scheduleTime() time.Duration {
+	return (6*time.Hour + c.SplayOffset()) % (24 * time.Hour)
+}
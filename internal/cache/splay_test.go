@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSplayOffset(t *testing.T) {
+	if got := splayOffset("host-1", 0); got != 0 {
+		t.Errorf("splayOffset() without a splay = %v, expected 0", got)
+	}
+
+	// Offsets are stable per host, whole seconds, and spread over the window
+	splay := time.Hour
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		host := fmt.Sprintf("host-%d", i)
+		off := splayOffset(host, splay)
+		if off < 0 || off >= splay || off%time.Second != 0 {
+			t.Fatalf("splayOffset(%q) = %v, expected whole seconds below %v", host, off, splay)
+		}
+		if again := splayOffset(host, splay); again != off {
+			t.Errorf("splayOffset(%q) changed from %v to %v", host, off, again)
+		}
+		seen[off] = true
+	}
+	if len(seen) < 90 {
+		t.Errorf("100 hosts share %d offsets, expected them spread", len(seen))
+	}
+}
+
+func TestScheduleTime(t *testing.T) {
+	cfg := testConfig(t)
+	c := New(cfg)
+	if got := c.scheduleTime(); got != 6*time.Hour {
+		t.Errorf("scheduleTime() without a splay = %v, expected 6h", got)
+	}
+
+	cfg.Splay = 24 * time.Hour
+	if got := c.scheduleTime(); got < 0 || got >= 24*time.Hour {
+		t.Errorf("scheduleTime() = %v, expected a time of day", got)
+	}
+}
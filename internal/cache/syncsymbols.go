@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoMirror indicates a mirror directory that does not exist.
+var ErrNoMirror = errors.New("no mirror")
+
+// SyncResult describes a SyncSymbols run.
+type SyncResult struct {
+	// Dir is the symbols directory; the files go in its linux
+	// subdirectory, where volatility3 looks for Linux symbols, and Mirror
+	// the directory they were taken from.
+	Dir    string `json:"dir"`
+	Mirror string `json:"mirror"`
+
+	// Files lists the symbol file of each banner with one in the mirror,
+	// and Synced counts those placed by this run.
+	Files  []PrefetchFile `json:"files"`
+	Synced int            `json:"synced"`
+
+	// Missing lists the selected banners without a mirrored file.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// SyncSymbols populates dir/linux, dir being the volatility3 symbols
+// directory when empty, with the symbol files basar mirror downloaded into
+// mirror (the cache's mirror directory when empty), for the banners
+// matching any of queries or every banner when there are none. Files keep
+// their host and path from the mirror, so files of the same name from
+// different URLs do not collide; volatility3 searches the directory tree.
+// Files are hard-linked where possible and copied otherwise, and those
+// already in place are left alone. Nothing is downloaded, so it works
+// offline.
+func (c *Cache) SyncSymbols(mirror, dir string, queries []string) (*SyncResult, error) {
+	banners := c.loadExistingBanners()
+	if banners == nil {
+		return nil, ErrNoCache
+	}
+	if mirror == "" {
+		mirror = c.mirrorDir()
+	}
+	if info, err := os.Stat(mirror); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w in %s; run basar mirror first", ErrNoMirror, mirror)
+	}
+	if dir == "" {
+		var err error
+		if dir, err = Volatility3SymbolsDir(); err != nil {
+			return nil, fmt.Errorf("locating the volatility3 symbols directory: %w", err)
+		}
+	}
+	selected, err := c.selectBanners(banners, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SyncResult{Dir: dir, Mirror: mirror, Files: []PrefetchFile{}}
+	linux := filepath.Join(dir, "linux")
+	for _, banner := range selected {
+		file, u := mirroredFile(mirror, banners.Linux[banner])
+		if file == "" {
+			res.Missing = append(res.Missing, banner)
+			continue
+		}
+		rel, err := filepath.Rel(mirror, file)
+		if err != nil {
+			return res, err
+		}
+		target := filepath.Join(linux, rel)
+		placed, err := placeSymbolFile(file, target)
+		if err != nil {
+			return res, fmt.Errorf("syncing %s: %w", rel, err)
+		}
+		if placed {
+			res.Synced++
+		}
+		res.Files = append(res.Files, PrefetchFile{Banner: banner, URL: u, Path: target})
+	}
+	return res, nil
+}
+
+// mirroredFile returns the first of urls mirrored in mirror, with its URL,
+// or "" when none is.
+func mirroredFile(mirror string, urls []string) (file, rawURL string) {
+	for _, u := range urls {
+		file, err := mirrorPath(mirror, u)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			return file, u
+		}
+	}
+	return "", ""
+}
+
+// placeSymbolFile hard-links src at target, or copies it where it cannot,
+// unless target is already a file of the same size. It reports whether it
+// placed the file.
+func placeSymbolFile(src, target string) (bool, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if t, err := os.Stat(target); err == nil && t.Mode().IsRegular() && t.Size() == info.Size() {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), DirMode); err != nil {
+		return false, err
+	}
+
+	// Link or copy next to target, then rename over it
+	tmp := filepath.Join(filepath.Dir(target), ".sync-"+filepath.Base(target))
+	_ = os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		if err := copyFile(src, tmp); err != nil {
+			_ = os.Remove(tmp)
+			return false, err
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// copyFile copies the contents of src to a new file dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
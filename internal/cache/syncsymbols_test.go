@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestSyncSymbols(t *testing.T) {
+	cfg := testConfig(t)
+	cache := &fetcher.BannerData{Version: 1, Linux: map[string][]string{
+		"Linux version 5.15.0-ubuntu": {"https://gone.example.org/5.15.json.xz", "https://isf.example.org/ubuntu/5.15.json.xz"},
+		"Linux version 6.1.0-debian":  {"https://isf.example.org/debian/6.1.json.xz"},
+		"Linux version 4.19.0-gone":   {"https://isf.example.org/missing.json.xz"},
+	}}
+	if err := writeBanners(cfg.CacheFile, cache); err != nil {
+		t.Fatal(err)
+	}
+	c := New(cfg)
+	dir := t.TempDir()
+
+	if _, err := c.SyncSymbols("", dir, nil); !errors.Is(err, ErrNoMirror) {
+		t.Fatalf("SyncSymbols() without a mirror = %v, expected ErrNoMirror", err)
+	}
+
+	for _, name := range []string{"ubuntu/5.15.json.xz", "debian/6.1.json.xz"} {
+		file := filepath.Join(c.mirrorDir(), "isf.example.org", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("isf:"+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := c.SyncSymbols("", dir, nil)
+	if err != nil {
+		t.Fatalf("SyncSymbols() failed: %v", err)
+	}
+	if len(res.Files) != 2 || res.Synced != 2 || len(res.Missing) != 1 || res.Missing[0] != "Linux version 4.19.0-gone" {
+		t.Fatalf("SyncSymbols() = %+v, expected 2 files synced and 4.19.0 missing", res)
+	}
+	file := filepath.Join(dir, "linux", "isf.example.org", "ubuntu", "5.15.json.xz")
+	if data, err := os.ReadFile(file); err != nil || string(data) != "isf:ubuntu/5.15.json.xz" {
+		t.Errorf("synced file = %q, %v", data, err)
+	}
+	if res.Files[0].URL != "https://isf.example.org/ubuntu/5.15.json.xz" {
+		t.Errorf("synced URL = %q, expected the mirrored one", res.Files[0].URL)
+	}
+
+	// Files in place are left alone, and matches restrict the banners
+	res, err = c.SyncSymbols("", dir, []string{"debian"})
+	if err != nil || len(res.Files) != 1 || res.Synced != 0 {
+		t.Errorf("second SyncSymbols() = %+v, %v, expected the debian file already in place", res, err)
+	}
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"os"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+// SystemCache returns the system-wide cache to print instead of this one,
+// or nil when this one should be used: without a system cache directory,
+// with BASAR_SYSTEM_CACHE=never, in auto mode once the user has a cache
+// of their own, and whenever the system cache is missing, expired, or
+// unreadable. The returned cache is only ever read; it never updates.
+func (c *Cache) SystemCache() *Cache {
+	if c.cfg.SystemCacheDir == "" || c.cfg.SystemCache == config.SystemCacheNever {
+		return nil
+	}
+	if _, exists := c.Path(); exists && c.cfg.SystemCache != config.SystemCachePrefer {
+		return nil
+	}
+
+	sys := New(c.cfg.Relocated(c.cfg.SystemCacheDir))
+	sys.SetLogger(c.log)
+	if sys.cfg.CacheFile == c.cfg.CacheFile || !sys.IsValid() {
+		return nil
+	}
+	f, err := os.Open(sys.cfg.CacheFile)
+	if err != nil {
+		c.log.Debug("system cache unreadable", "path", sys.cfg.CacheFile, "error", err)
+		return nil
+	}
+	f.Close()
+	return sys
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestSystemCache(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SystemCacheDir = t.TempDir()
+	cfg.SystemCache = config.SystemCacheAuto
+	c := New(cfg)
+	system := filepath.Join(cfg.SystemCacheDir, "banners.json")
+
+	if c.SystemCache() != nil {
+		t.Error("SystemCache() without a system cache should be nil")
+	}
+
+	createTestBannerFile(t, system)
+	sys := c.SystemCache()
+	if sys == nil {
+		t.Fatal("SystemCache() = nil, expected the system cache while the user has none")
+	}
+	if path, ok := sys.Path(); !ok || path != system {
+		t.Errorf("system cache path = %q, %v; expected %s", path, ok, system)
+	}
+
+	// Expired: the user's cache gets updated instead
+	old := time.Now().Add(-2 * cfg.TTL)
+	if err := os.Chtimes(system, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if c.SystemCache() != nil {
+		t.Error("SystemCache() should skip an expired system cache")
+	}
+	if err := os.Chtimes(system, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the user has a cache of their own, only prefer mode uses it
+	createTestBannerFile(t, cfg.CacheFile)
+	if c.SystemCache() != nil {
+		t.Error("SystemCache() in auto mode should defer to the user's cache")
+	}
+	cfg.SystemCache = config.SystemCachePrefer
+	if c.SystemCache() == nil {
+		t.Error("SystemCache() in prefer mode should use a valid system cache")
+	}
+	cfg.SystemCache = config.SystemCacheNever
+	if c.SystemCache() != nil {
+		t.Error("SystemCache() in never mode should be nil")
+	}
+}
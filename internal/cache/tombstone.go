@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// Tombstone marks a banner its sources no longer list, kept in the cache
+// with its last known URLs until Expires so analysts relying on it are not
+// cut off mid-investigation.
+type Tombstone struct {
+	Removed time.Time `json:"removed"`
+	Expires time.Time `json:"expires"`
+
+	// URLs are the banner's URLs when it was removed, restored on every
+	// update until it expires.
+	URLs []string `json:"urls,omitempty"`
+}
+
+// tombstonesPath returns the sidecar file recording tombstoned banners.
+func (c *Cache) tombstonesPath() string {
+	return filepath.Join(c.cfg.CacheDir, "tombstones.json")
+}
+
+// saveTombstones writes the tombstones sidecar next to the cache file,
+// removing it when there are none.
+func (c *Cache) saveTombstones(tombs map[string]*Tombstone) error {
+	if len(tombs) == 0 {
+		if err := os.Remove(c.tombstonesPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(tombs)
+	if err != nil {
+		return fmt.Errorf("encoding tombstones: %w", err)
+	}
+
+	return writeFileAtomic(c.tombstonesPath(), raw)
+}
+
+// loadTombstones reads the tombstones sidecar, returning nil if missing.
+func (c *Cache) loadTombstones() map[string]*Tombstone {
+	raw, err := os.ReadFile(c.tombstonesPath())
+	if err != nil {
+		return nil
+	}
+
+	var tombs map[string]*Tombstone
+	if err := json.Unmarshal(raw, &tombs); err != nil {
+		return nil
+	}
+
+	return tombs
+}
+
+// keepRemoved puts the banners of the existing cache missing from merged
+// back into it while their tombstones last, with their previous sources in
+// prov, and returns the tombstones to save. A banner is tombstoned on the
+// first update that drops it and expires TombstoneTTL later; one listed
+// again loses its tombstone.
+func (c *Cache) keepRemoved(existing, merged *fetcher.BannerData, prov fetcher.Provenance) map[string]*Tombstone {
+	if c.cfg.TombstoneTTL <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	prev := c.loadTombstones()
+	tombs := make(map[string]*Tombstone)
+	for banner, t := range prev {
+		t.Expires = t.Removed.Add(c.cfg.TombstoneTTL) // follow the configured TTL
+		if _, listed := merged.Linux[banner]; !listed && now.Before(t.Expires) {
+			tombs[banner] = t
+		}
+	}
+	if existing != nil {
+		for banner, urls := range existing.Linux {
+			if _, listed := merged.Linux[banner]; listed || tombs[banner] != nil || prev[banner] != nil {
+				continue
+			}
+			tombs[banner] = &Tombstone{Removed: now, Expires: now.Add(c.cfg.TombstoneTTL), URLs: urls}
+		}
+	}
+	if len(tombs) == 0 {
+		return nil
+	}
+
+	oldProv := c.loadProvenance()
+	for banner, t := range tombs {
+		merged.Linux[banner] = t.URLs
+		if sources := oldProv[banner]; len(sources) > 0 {
+			prov[banner] = sources
+		}
+	}
+	c.log.Info("kept banners removed upstream", "tombstones", len(tombs))
+	return tombs
+}
+
+// tombstonesExpired reports whether a tombstone has expired, or tombstones
+// were disabled since, so the cache must be rewritten without them.
+func (c *Cache) tombstonesExpired() bool {
+	now := time.Now()
+	for _, t := range c.loadTombstones() {
+		if c.cfg.TombstoneTTL <= 0 || !now.Before(t.Removed.Add(c.cfg.TombstoneTTL)) {
+			return true
+		}
+	}
+	return false
+}
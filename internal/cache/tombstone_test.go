@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdateKeepsRemovedBanners(t *testing.T) {
+	var body atomic.Value
+	body.Store(`{"version":1,"linux":{"banner1":["https://up.example/b1.json.xz"],"banner2":["https://up.example/b2.json.xz"]}}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL}
+	cfg.TombstoneTTL = time.Hour
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upstream drops banner2, which is kept and marked
+	body.Store(`{"version":1,"linux":{"banner1":["https://up.example/b1.json.xz"]}}`)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := c.Lookup("banner2")
+	if err != nil || len(matches) != 1 || matches[0].Tombstone == nil {
+		t.Fatalf("Lookup(banner2) = %+v, %v; expected a tombstoned match", matches, err)
+	}
+	if m := matches[0]; len(m.URLs) != 1 || len(m.Sources) != 1 || m.Tombstone.URLs != nil ||
+		m.Tombstone.Expires.Sub(m.Tombstone.Removed) != time.Hour {
+		t.Errorf("tombstoned match = %+v, tombstone %+v", m, m.Tombstone)
+	}
+	if matches, _ := c.Lookup("banner1"); len(matches) != 1 || matches[0].Tombstone != nil {
+		t.Errorf("Lookup(banner1) = %+v, expected no tombstone", matches)
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Tombstones != 1 {
+		t.Errorf("Stats() = %d entries, %d tombstones; expected 2 and 1", stats.Entries, stats.Tombstones)
+	}
+
+	// A later update keeps the original removal time
+	removed := matches[0].Tombstone.Removed
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if tombs := c.loadTombstones(); tombs["banner2"] == nil || !tombs["banner2"].Removed.Equal(removed) {
+		t.Errorf("tombstones after another update = %+v", tombs)
+	}
+
+	// Once expired, the banner is dropped
+	tombs := c.loadTombstones()
+	tombs["banner2"].Removed = time.Now().Add(-2 * time.Hour)
+	raw, _ := json.Marshal(tombs)
+	if err := os.WriteFile(c.tombstonesPath(), raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !c.tombstonesExpired() {
+		t.Error("tombstonesExpired() = false for an expired tombstone")
+	}
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := c.Lookup("banner2"); len(matches) != 0 {
+		t.Errorf("Lookup(banner2) after expiry = %+v", matches)
+	}
+	if _, err := os.Stat(c.tombstonesPath()); !os.IsNotExist(err) {
+		t.Errorf("tombstones sidecar left after expiry: %v", err)
+	}
+}
+
+func TestUpdateWithoutTombstones(t *testing.T) {
+	cfg := testConfig(t)
+	createTestBannerFile(t, cfg.CacheFile)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"other":["https://up.example/o.json.xz"]}}`))
+	}))
+	defer server.Close()
+	cfg.Sources = []string{server.URL}
+	cfg.ShrinkThreshold = 0
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, banner := range []string{"Linux version 5.15.0-generic", "Linux version 6.1.0-generic"} {
+		if matches, _ := c.Lookup(banner); len(matches) != 0 {
+			t.Errorf("Lookup(%q) = %+v, expected the banner dropped", banner, matches)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/calilkhalil/basar/internal/config"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// urlTrustPath returns the sidecar file holding the trust level of each
+// symbol URL.
+func (c *Cache) urlTrustPath() string {
+	return filepath.Join(c.cfg.CacheDir, "url-trust.json")
+}
+
+// urlTrust returns the trust level of the URLs of merged, the highest
+// among the sources listing each, leaving out those that are
+// config.TrustCommunity. It returns nil when no source sets a level.
+func (c *Cache) urlTrust(merged *fetcher.BannerData, sources []string, datasets []*fetcher.BannerData) map[string]string {
+	if !slices.ContainsFunc(sources, func(src string) bool { return c.cfg.SourceOptions(src).Trust != "" }) {
+		return nil
+	}
+
+	trust := make(map[string]string)
+	for u, srcs := range fetcher.URLProvenance(merged, sources, datasets, c.cfg.URLRedirects) {
+		level := ""
+		for _, src := range srcs {
+			if l := c.cfg.SourceTrust(src); level == "" || config.TrustRank(l) > config.TrustRank(level) {
+				level = l
+			}
+		}
+		if level != config.TrustCommunity {
+			trust[u] = level
+		}
+	}
+	return trust
+}
+
+// trustOf returns the trust level of rawURL in trust.
+func trustOf(trust map[string]string, rawURL string) string {
+	if level, ok := trust[rawURL]; ok {
+		return level
+	}
+	return config.TrustCommunity
+}
+
+// saveURLTrust writes the URL trust sidecar next to the cache file,
+// removing it when every URL is config.TrustCommunity.
+func (c *Cache) saveURLTrust(trust map[string]string) error {
+	if len(trust) == 0 {
+		if err := os.Remove(c.urlTrustPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(trust)
+	if err != nil {
+		return fmt.Errorf("encoding URL trust: %w", err)
+	}
+
+	return writeFileAtomic(c.urlTrustPath(), raw)
+}
+
+// loadURLTrust reads the URL trust sidecar, returning nil if missing.
+func (c *Cache) loadURLTrust() map[string]string {
+	raw, err := os.ReadFile(c.urlTrustPath())
+	if err != nil {
+		return nil
+	}
+
+	var trust map[string]string
+	if err := json.Unmarshal(raw, &trust); err != nil {
+		return nil
+	}
+
+	return trust
+}
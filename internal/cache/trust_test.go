@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/config"
+)
+
+func TestUpdateRecordsURLTrust(t *testing.T) {
+	cfg := testConfig(t)
+	trusted := filepath.Join(cfg.ConfigDir, "trusted.json")
+	experimental := filepath.Join(cfg.ConfigDir, "experimental.json")
+	community := filepath.Join(cfg.ConfigDir, "community.json")
+	cfg.Sources = []string{trusted, experimental, community}
+	cfg.Options = map[string]config.SourceOptions{
+		trusted:      {Trust: config.TrustTrusted},
+		experimental: {Trust: config.TrustExperimental},
+	}
+	writeBannerSource(t, trusted, bannerURLs("shared"))
+	writeBannerSource(t, experimental, bannerURLs("shared", "nightly"))
+	writeBannerSource(t, community, bannerURLs("stable"))
+	c := New(cfg)
+
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"shared":  config.TrustTrusted,
+		"nightly": config.TrustExperimental,
+		"stable":  config.TrustCommunity,
+	}
+	for banner, level := range want {
+		matches, err := c.Lookup(banner)
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("Lookup(%q) = %v, %v", banner, matches, err)
+		}
+		if got := matches[0].Trust["https://example.com/"+banner]; got != level {
+			t.Errorf("%s URL trust = %q, expected %q", banner, got, level)
+		}
+	}
+
+	// The experimental-only URL falls below the minimum
+	counts, err := c.ApplyFilters(nil, &config.URLFilter{MinTrust: config.TrustCommunity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.URLs != 1 || counts.Banners != 1 {
+		t.Errorf("ApplyFilters() = %+v, expected the nightly URL and banner dropped", counts)
+	}
+	if matches, _ := c.Lookup("nightly"); len(matches) != 0 {
+		t.Errorf("Lookup(nightly) = %v after filtering, expected nothing", matches)
+	}
+
+	// Without trust levels there is no sidecar, and lookups carry none
+	cfg.Options = nil
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.urlTrustPath()); !os.IsNotExist(err) {
+		t.Errorf("URL trust sidecar kept without trust levels: %v", err)
+	}
+	if matches, _ := c.Lookup("shared"); len(matches) != 1 || matches[0].Trust != nil {
+		t.Errorf("Lookup(shared) = %+v, expected no trust", matches)
+	}
+}
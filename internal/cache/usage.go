@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where the last read of the cache was seen, as Usage.Via reports it.
+const (
+	UsageViaServe     = "serve"      // Recorded by basar serve
+	UsageViaAccessLog = "access-log" // Found in the BASAR_ACCESS_LOG file
+)
+
+// accessLogTail bounds how much of the end of an access log is scanned.
+const accessLogTail = 4 << 20
+
+// usageMu serializes the read-modify-write of the usage file by
+// concurrent requests.
+var usageMu sync.Mutex
+
+// Usage describes the last time a client such as volatility3 read the
+// cache, for telling machines that use it from idle ones still updating.
+type Usage struct {
+	LastUsed time.Time `json:"last_used"`
+	Via      string    `json:"via"`
+	// Client is the User-Agent of the last read, when known.
+	Client string `json:"client,omitempty"`
+	// Reads counts the reads basar serve recorded.
+	Reads int64 `json:"reads,omitempty"`
+	// UnusedSinceUpdate is set when the cache has not been read since it
+	// was last written.
+	UnusedSinceUpdate bool `json:"unused_since_update,omitempty"`
+}
+
+// RecordRead records a read of the cache by client, its User-Agent, when
+// usage tracking is on.
+func (c *Cache) RecordRead(client string) error {
+	if !c.cfg.TrackUsage {
+		return nil
+	}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	u := c.loadUsage()
+	if u == nil {
+		u = &Usage{}
+	}
+	u.LastUsed = time.Now().UTC()
+	u.Via = UsageViaServe
+	u.Client = client
+	u.Reads++
+
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("encoding usage: %w", err)
+	}
+	if err := os.MkdirAll(c.cfg.StateDir, DirMode); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.cfg.UsageFile, raw)
+}
+
+// loadUsage reads the usage file, returning nil if missing.
+func (c *Cache) loadUsage() *Usage {
+	raw, err := os.ReadFile(c.cfg.UsageFile)
+	if err != nil {
+		return nil
+	}
+	var u Usage
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return nil
+	}
+	return &u
+}
+
+// usage returns the last read of the cache recorded by basar serve or
+// found in the configured access log, whichever is later, relative to
+// updatedAt, the time the cache was written. It returns nil when usage is
+// not tracked or no read was seen.
+func (c *Cache) usage(updatedAt time.Time) *Usage {
+	if !c.cfg.TrackUsage && c.cfg.AccessLog == "" {
+		return nil
+	}
+	u := c.loadUsage()
+	if c.cfg.AccessLog != "" {
+		at, client, err := lastAccess(c.cfg.AccessLog, filepath.Base(c.cfg.CacheFile))
+		if err == nil && !at.IsZero() && (u == nil || at.After(u.LastUsed)) {
+			if u == nil {
+				u = &Usage{}
+			}
+			u.LastUsed, u.Via, u.Client = at.UTC(), UsageViaAccessLog, client
+		}
+	}
+	if u == nil {
+		return nil
+	}
+	u.UnusedSinceUpdate = !updatedAt.IsZero() && u.LastUsed.Before(updatedAt)
+	return u
+}
+
+// accessLogLine matches a request in the common or combined log format,
+// capturing its time, method, path, status, and, in the combined format,
+// User-Agent.
+var accessLogLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) \S+(?: "[^"]*" "([^"]*)")?`)
+
+// lastAccess returns the time and User-Agent of the last successful GET
+// of a file named name in an access log, looking at its last
+// accessLogTail bytes. It returns a zero time when there is none.
+func lastAccess(logPath, name string) (time.Time, string, error) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > accessLogTail {
+		if _, err := f.Seek(-accessLogTail, io.SeekEnd); err != nil {
+			return time.Time{}, "", err
+		}
+	}
+
+	var last time.Time
+	var client string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		m := accessLogLine.FindStringSubmatch(scanner.Text())
+		if m == nil || m[2] != "GET" || (m[4] != "200" && m[4] != "206" && m[4] != "304") {
+			continue
+		}
+		reqPath, _, _ := strings.Cut(m[3], "?")
+		if path.Base(reqPath) != name {
+			continue
+		}
+		at, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+		if err != nil || at.Before(last) {
+			continue
+		}
+		last, client = at, m[5]
+	}
+	return last, client, scanner.Err()
+}
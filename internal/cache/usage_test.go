@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestRecordRead(t *testing.T) {
+	cfg := testConfig(t)
+	if err := writeBanners(cfg.CacheFile, &fetcher.BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}}); err != nil {
+		t.Fatal(err)
+	}
+	c := New(cfg)
+
+	// Untracked, reads are not recorded
+	if err := c.RecordRead("Python-urllib/3.11"); err != nil {
+		t.Fatal(err)
+	}
+	if u := c.Stats().LastUsed; u != nil {
+		t.Errorf("LastUsed = %+v without tracking, expected nil", u)
+	}
+
+	cfg.TrackUsage = true
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(cfg.CacheFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.RecordRead("Python-urllib/3.11"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := c.Stats().LastUsed
+	if u == nil || u.Reads != 2 || u.Via != UsageViaServe || u.Client != "Python-urllib/3.11" || u.UnusedSinceUpdate {
+		t.Fatalf("LastUsed = %+v, expected 2 reads by serve since the update", u)
+	}
+
+	// Rewritten since, the cache has not been used
+	if err := writeBanners(cfg.CacheFile, &fetcher.BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cfg.CacheFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if u := c.Stats().LastUsed; u == nil || !u.UnusedSinceUpdate {
+		t.Errorf("LastUsed = %+v after an update, expected it unused since", u)
+	}
+}
+
+func TestLastAccess(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "access.log")
+	content := `10.0.0.5 - - [14/Oct/2026:09:12:44 +0000] "GET /isf/banners.json HTTP/1.1" 200 1024 "-" "Python-urllib/3.11"
+10.0.0.6 - - [14/Oct/2026:10:00:00 +0000] "GET /isf/banners.json HTTP/1.1" 404 12 "-" "curl/8.0"
+10.0.0.7 - - [14/Oct/2026:11:00:00 +0000] "HEAD /isf/banners.json HTTP/1.1" 200 0
+10.0.0.8 - - [14/Oct/2026:12:00:00 +0000] "GET /other.json HTTP/1.1" 200 10
+10.0.0.9 - - [14/Oct/2026:08:00:00 +0000] "GET /banners.json?v=2 HTTP/1.1" 304 0
+not a log line
+`
+	if err := os.WriteFile(log, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	at, client, err := lastAccess(log, "banners.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 10, 14, 9, 12, 44, 0, time.UTC)
+	if !at.Equal(want) || client != "Python-urllib/3.11" {
+		t.Errorf("lastAccess() = %s, %q, expected %s by Python-urllib/3.11", at, client, want)
+	}
+
+	cfg := testConfig(t)
+	cfg.AccessLog = log
+	if err := writeBanners(cfg.CacheFile, &fetcher.BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if u := New(cfg).Stats().LastUsed; u == nil || u.Via != UsageViaAccessLog || !u.LastUsed.Equal(want) {
+		t.Errorf("LastUsed = %+v, expected the access log's read", u)
+	}
+}
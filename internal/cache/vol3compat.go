@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ErrVol3Compat indicates a --vol3-compat version basar cannot write a
+// cache for: malformed, or older than remote ISF support.
+var ErrVol3Compat = errors.New("unsupported volatility3 version")
+
+// vol3Release lists what volatility3 releases from Since on accept in a
+// remote ISF index: the banners.json schema version, the URL schemes
+// symbol files are fetched over, and the symbol file names it can open,
+// compression included.
+type vol3Release struct {
+	Since    [3]int
+	Version  int
+	Schemes  []string
+	Suffixes []string
+}
+
+// vol3Releases holds the known constraints, oldest first. Remote ISF
+// indexes appeared in 2.0.0, and the version 2 list format, which basar
+// writes for releases reading it, in 2.26.0; a release newer than the last
+// entry is held to it.
+var vol3Releases = []vol3Release{
+	{
+		Since:    [3]int{2, 0, 0},
+		Version:  fetcher.IndexVersion,
+		Schemes:  []string{"http", "https", "file"},
+		Suffixes: []string{".json", ".json.xz", ".json.gz", ".json.bz2"},
+	},
+	{
+		Since:    [3]int{2, 26, 0},
+		Version:  fetcher.IndexVersionList,
+		Schemes:  []string{"http", "https", "file"},
+		Suffixes: []string{".json", ".json.xz", ".json.gz", ".json.bz2"},
+	},
+}
+
+// parseVol3Version parses a volatility3 version such as "2.4", "v2.5.2",
+// or "2.7.0-dev" into major, minor, and patch numbers.
+func parseVol3Version(s string) ([3]int, error) {
+	var v [3]int
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, fmt.Errorf("%w: %q is not a version like 2.4.0", ErrVol3Compat, s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("%w: %q is not a version like 2.4.0", ErrVol3Compat, s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// vol3ReleaseFor returns the constraints of volatility3 version.
+func vol3ReleaseFor(version string) (*vol3Release, error) {
+	v, err := parseVol3Version(version)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(vol3Releases) - 1; i >= 0; i-- {
+		if !versionLess(v, vol3Releases[i].Since) {
+			return &vol3Releases[i], nil
+		}
+	}
+	since := vol3Releases[0].Since
+	return nil, fmt.Errorf("%w: volatility3 %s predates remote ISF indexes (%d.%d.%d)",
+		ErrVol3Compat, version, since[0], since[1], since[2])
+}
+
+// versionLess reports whether version a precedes b.
+func versionLess(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// CheckVol3Compat validates a --vol3-compat version.
+func CheckVol3Compat(version string) error {
+	_, err := vol3ReleaseFor(version)
+	return err
+}
+
+// forVol3 tailors data to the volatility3 release configured with
+// Vol3Compat, if any: it sets the schema version and drops the symbol URLs
+// that release cannot fetch or open, and the banners left without one. It
+// returns the number of URLs dropped.
+func (c *Cache) forVol3(data *fetcher.BannerData) (*fetcher.BannerData, int, error) {
+	if c.cfg.Vol3Compat == "" {
+		return data, 0, nil
+	}
+	release, err := vol3ReleaseFor(c.cfg.Vol3Compat)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := &fetcher.BannerData{Version: release.Version, Linux: make(map[string][]string, len(data.Linux))}
+	dropped := 0
+	for banner, urls := range data.Linux {
+		kept := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if release.accepts(u) {
+				kept = append(kept, u)
+			} else {
+				dropped++
+			}
+		}
+		if len(kept) > 0 {
+			out.Linux[banner] = kept
+		}
+	}
+	return out, dropped, nil
+}
+
+// accepts reports whether the release can use the symbol file at rawURL.
+func (r *vol3Release) accepts(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || !hasString(r.Schemes, strings.ToLower(u.Scheme)) {
+		return false
+	}
+	name := strings.ToLower(path.Base(u.Path))
+	for _, suffix := range r.Suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasString reports whether list holds s.
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestParseVol3Version(t *testing.T) {
+	tests := []struct {
+		in   string
+		want [3]int
+		ok   bool
+	}{
+		{"2.4.0", [3]int{2, 4, 0}, true},
+		{"v2.5", [3]int{2, 5, 0}, true},
+		{"2.7.0-dev", [3]int{2, 7, 0}, true},
+		{"2", [3]int{2, 0, 0}, true},
+		{"", [3]int{}, false},
+		{"2.x", [3]int{}, false},
+		{"2.4.0.1", [3]int{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseVol3Version(tt.in)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("parseVol3Version(%q) = %v, %v; expected %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckVol3Compat(t *testing.T) {
+	for _, v := range []string{"2.0.0", "2.4.1", "3.1"} {
+		if err := CheckVol3Compat(v); err != nil {
+			t.Errorf("CheckVol3Compat(%q) = %v", v, err)
+		}
+	}
+	for _, v := range []string{"1.2.0", "latest"} {
+		if err := CheckVol3Compat(v); !errors.Is(err, ErrVol3Compat) {
+			t.Errorf("CheckVol3Compat(%q) = %v, expected ErrVol3Compat", v, err)
+		}
+	}
+}
+
+func TestForVol3(t *testing.T) {
+	cfg := testConfig(t)
+	data := &fetcher.BannerData{Version: 2, Linux: map[string][]string{
+		"banner1": {"https://example.com/a.json.xz", "s3://bucket/a.json.xz", "https://example.com/a.json.zst"},
+		"banner2": {"ftp://example.com/b.json"},
+		"banner3": {"file:///srv/isf/c.JSON.GZ", "http://example.com/c"},
+	}}
+
+	c := New(cfg)
+	if got, dropped, err := c.forVol3(data); err != nil || got != data || dropped != 0 {
+		t.Errorf("forVol3() without Vol3Compat = %v, %d, %v; expected data unchanged", got, dropped, err)
+	}
+
+	cfg.Vol3Compat = "2.4.0"
+	got, dropped, err := New(cfg).forVol3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 4 || got.Version != 1 || len(got.Linux) != 2 {
+		t.Fatalf("forVol3() = %+v, dropped %d; expected 2 banners, version 1, 4 URLs dropped", got, dropped)
+	}
+	if urls := got.Linux["banner1"]; len(urls) != 1 || urls[0] != "https://example.com/a.json.xz" {
+		t.Errorf("banner1 URLs = %v", urls)
+	}
+	if len(data.Linux["banner1"]) != 3 {
+		t.Error("forVol3() modified its input")
+	}
+
+	cfg.Vol3Compat = "1.0.0"
+	if _, _, err := New(cfg).forVol3(data); !errors.Is(err, ErrVol3Compat) {
+		t.Errorf("forVol3() for 1.0.0 error = %v, expected ErrVol3Compat", err)
+	}
+}
+
+func TestSmartUpdateAppliesVol3Compat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"banner1":["https://example.com/a.json.xz","s3://bucket/a.json.xz"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL}
+	if _, err := New(cfg).Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source is unchanged, but the cache is rewritten for the release
+	cfg.Vol3Compat = "2.4.0"
+	c := New(cfg)
+	res, err := c.SmartUpdate(context.Background())
+	if err != nil {
+		t.Fatalf("SmartUpdate() failed: %v", err)
+	}
+	data, _ := os.ReadFile(cfg.CacheFile)
+	if !res.Updated || strings.Contains(string(data), "s3://") {
+		t.Errorf("SmartUpdate() with a new Vol3Compat: updated %v, cache %s", res.Updated, data)
+	}
+	if meta := c.loadMeta(); meta.Vol3Compat != "2.4.0" {
+		t.Errorf("meta.Vol3Compat = %q, expected 2.4.0", meta.Vol3Compat)
+	}
+
+	if res, err := c.SmartUpdate(context.Background()); err != nil || res.Updated {
+		t.Errorf("second SmartUpdate() = %+v, %v; expected no change", res, err)
+	}
+}
+
+func TestUpdateWritesListIndexForVol3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":2,"linux":{"banner1":[{"url":"https://example.com/a.json.xz","size":10},"https://example.com/b.json"]}}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Sources = []string{server.URL}
+	cfg.Vol3Compat = "2.26.0"
+	cfg.DiskIndex = true
+	c := New(cfg)
+	if _, err := c.Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(cfg.CacheFile)
+	if !strings.Contains(string(data), `"version":2`) || !strings.Contains(string(data), `{"url":"https://example.com/a.json.xz"}`) {
+		t.Errorf("cache = %s, expected the version 2 list format", data)
+	}
+
+	dx, err := c.OpenDiskIndex()
+	if err != nil {
+		t.Fatalf("OpenDiskIndex() = %v", err)
+	}
+	dx.Close()
+	matches, err := c.Lookup("banner1")
+	if err != nil || len(matches) != 1 || len(matches[0].URLs) != 2 {
+		t.Fatalf("Lookup() = %+v, %v; expected banner1 with 2 URLs", matches, err)
+	}
+
+	// Back to a release reading only version 1
+	cfg.Vol3Compat = "2.4.0"
+	if _, err := New(cfg).Update(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(cfg.CacheFile)
+	if !strings.Contains(string(data), `"version":1`) || strings.Contains(string(data), `"url"`) {
+		t.Errorf("cache = %s, expected the version 1 format", data)
+	}
+}
@@ -0,0 +1,506 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseVol3Config checks that data is in the subset of YAML basar edits
+// and returns the scalar values of its top-level keys. The subset is what
+// volatility3 configs are written with: one document, optionally opened
+// with --- and closed with ..., holding a block mapping whose keys are
+// plain or quoted scalars and whose values are scalars, plain or quoted,
+// block scalars, flow collections, or indented blocks, with comments and
+// blank lines anywhere. Indented blocks are kept as written, not read.
+// Anything else, such as multiple documents, a flow collection or a list
+// at the top level, complex or merge keys, or anchors, aliases, and tags
+// on top-level keys and values, is reported as an error, so a config basar
+// cannot read the way YAML would is left alone rather than rewritten.
+func parseVol3Config(data string) (map[string]string, error) {
+	keys := make(map[string]string)
+	var (
+		haveKey bool // Indented lines belong to the last top-level key
+		listOK  bool // The last key's value may be a list at its own indent
+		scalar  bool // The last key has a complete value on its own line
+		block   bool // In a block scalar, whose lines are not YAML
+		ended   bool // After the document's closing ...
+		open    yamlScan
+	)
+	for i, line := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " ")
+		indented := len(trimmed) < len(line)
+
+		if block {
+			if trimmed == "" || indented {
+				continue
+			}
+			block = false
+		}
+		if open.pending() {
+			if !indented && trimmed != "" {
+				return nil, fmt.Errorf("line %d: %s", lineNo, open.unterminated())
+			}
+			open.scan(trimmed)
+			continue
+		}
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "\t"):
+			return nil, fmt.Errorf("line %d: tab in indentation", lineNo)
+		case indented && !haveKey:
+			return nil, fmt.Errorf("line %d: indented content before any key", lineNo)
+		case indented && scalar:
+			// A plain scalar continued on more lines, or an item after a
+			// value, is not something basar writes or can edit safely
+			return nil, fmt.Errorf("line %d: indented content after a value", lineNo)
+		case indented:
+			continue
+		case ended:
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", lineNo)
+		case line == "---" && haveKey:
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", lineNo)
+		case line == "---" || strings.HasPrefix(line, "%") && !haveKey:
+			continue
+		case line == "...":
+			ended = true
+			continue
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "... ") || strings.HasPrefix(line, "%"):
+			return nil, fmt.Errorf("line %d: content on a document marker or directive line is not supported", lineNo)
+		case strings.HasPrefix(line, "[") || strings.HasPrefix(line, "{"):
+			return nil, fmt.Errorf("line %d: a flow collection at the top level is not supported", lineNo)
+		case strings.HasPrefix(line, "?"):
+			return nil, fmt.Errorf("line %d: complex keys are not supported", lineNo)
+		case strings.ContainsAny(line[:1], "&*!"):
+			return nil, fmt.Errorf("line %d: anchors, aliases, and tags are not supported", lineNo)
+		case (line == "-" || strings.HasPrefix(line, "- ")) && listOK:
+			continue
+		case line == "-" || strings.HasPrefix(line, "- "):
+			return nil, fmt.Errorf("line %d: top level is a list, not a mapping", lineNo)
+		}
+
+		key, value, err := splitYAMLKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if _, dup := keys[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+		if key == "<<" {
+			return nil, fmt.Errorf("line %d: merge keys are not supported", lineNo)
+		}
+		if value != "" && strings.ContainsAny(value[:1], "&*!") {
+			return nil, fmt.Errorf("line %d: anchors, aliases, and tags are not supported", lineNo)
+		}
+		haveKey = true
+		listOK = stripYAMLComment(value) == ""
+		scalar = false
+
+		switch {
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			block = true
+			keys[key] = ""
+		case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") ||
+			strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+			open = yamlScan{}
+			rest := open.scan(value)
+			if open.pending() {
+				keys[key] = ""
+				continue
+			}
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected %q after value", lineNo, rest)
+			}
+			keys[key] = unquoteYAML(strings.TrimSpace(strings.TrimSuffix(value, rest)))
+			scalar = true
+		default:
+			value = stripYAMLComment(value)
+			if strings.Contains(value, ": ") || strings.HasSuffix(value, ":") {
+				return nil, fmt.Errorf("line %d: mapping value not allowed here", lineNo)
+			}
+			keys[key] = value
+			scalar = value != ""
+		}
+	}
+	if open.pending() {
+		return nil, errors.New("end of file: " + open.unterminated())
+	}
+	return keys, nil
+}
+
+// vol3ISFKey is the volatility3 config key naming where to find ISF files.
+const vol3ISFKey = "remote_isf_url"
+
+// Ways remote_isf_url may be written.
+const (
+	isfNone   = ""       // No value, or null
+	isfScalar = "scalar" // A single URL
+	isfBlock  = "block"  // A block list, an item a line
+	isfFlow   = "flow"   // A [flow, list]
+)
+
+// isfURLs is the remote_isf_url setting of a volatility3 config, with
+// where it is written, so it can be edited in place.
+type isfURLs struct {
+	start, end int      // Lines the key spans; start is -1 without it
+	style      string   // How the value is written: isfNone, isfScalar, ...
+	value      string   // A scalar as written, with any comment
+	values     []string // Entries unquoted
+	last       int      // Line of the last block list item, or of the flow list's "]"
+}
+
+func (u isfURLs) has(url string) bool {
+	return slices.Contains(u.values, url)
+}
+
+// parseISFURLs finds remote_isf_url in the lines of a config
+// parseVol3Config accepts, with its value as a scalar, a block list, or a
+// flow list. Entries it cannot tell to be plain URLs are reported as
+// errors.
+func parseISFURLs(lines []string) (isfURLs, error) {
+	u := isfURLs{start: -1}
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		key, value, err := splitYAMLKey(line)
+		if err != nil || key != vol3ISFKey {
+			continue
+		}
+
+		// The key's lines run until the next key at the top level. Blank
+		// lines and comments, even unindented ones, do not end them, but
+		// only belong to the key when more of its lines follow
+		u.start, u.end = i, i+1
+		for j := i + 1; j < len(lines); j++ {
+			next := strings.TrimSuffix(lines[j], "\r")
+			if strings.TrimSpace(next) == "" || strings.HasPrefix(next, "#") {
+				continue
+			}
+			if !strings.HasPrefix(next, " ") && !strings.HasPrefix(next, "- ") && next != "-" {
+				break
+			}
+			u.end = j + 1
+		}
+
+		entry := stripYAMLComment(value)
+		switch {
+		case entry == "" || entry == "~" || entry == "null":
+			for j := i + 1; j < u.end; j++ {
+				item := strings.TrimSpace(strings.TrimSuffix(lines[j], "\r"))
+				if item == "" || strings.HasPrefix(item, "#") {
+					continue
+				}
+				entry, ok := strings.CutPrefix(item, "-")
+				if !ok {
+					return u, fmt.Errorf("%s is neither a URL nor a list of URLs", vol3ISFKey)
+				}
+				if err := u.add(stripYAMLComment(entry)); err != nil {
+					return u, err
+				}
+				u.style, u.last = isfBlock, j
+			}
+		case strings.HasPrefix(entry, "["):
+			u.style, u.last = isfFlow, -1
+			flow := entry
+			if strings.Contains(entry, "]") {
+				u.last = i
+			}
+			for j := i + 1; j < u.end; j++ {
+				item := stripYAMLComment(strings.TrimSpace(strings.TrimSuffix(lines[j], "\r")))
+				if u.last < 0 && strings.Contains(item, "]") {
+					u.last = j
+				}
+				flow += " " + item
+			}
+			items, err := splitFlowList(flow)
+			if err != nil {
+				return u, err
+			}
+			for _, item := range items {
+				if err := u.add(item); err != nil {
+					return u, err
+				}
+			}
+		default:
+			if err := u.add(entry); err != nil {
+				return u, err
+			}
+			u.style, u.value = isfScalar, value
+		}
+		return u, nil
+	}
+	return u, nil
+}
+
+// splitFlowList splits a flow list of scalars into its entries as written,
+// leaving commas in quoted ones alone. Nested collections are reported as
+// errors.
+func splitFlowList(flow string) ([]string, error) {
+	var (
+		items []string
+		quote byte // Open quote, or 0
+		start = 1
+	)
+	for i := 1; i < len(flow); i++ {
+		c := flow[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0 // A doubled '' reopens the quote right away
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			return nil, fmt.Errorf("%s has a nested collection, which is not supported", vol3ISFKey)
+		case c == ',' || c == ']':
+			if item := strings.TrimSpace(flow[start:i]); item != "" {
+				items = append(items, item)
+			}
+			if c == ']' {
+				return items, nil
+			}
+			start = i + 1
+		}
+	}
+	return nil, fmt.Errorf("%s is an unterminated list", vol3ISFKey)
+}
+
+// add appends an entry as written.
+func (u *isfURLs) add(entry string) error {
+	if entry == "" || strings.ContainsAny(entry[:1], "[{|>&*!") || strings.Contains(entry, ": ") {
+		return fmt.Errorf("%s has an entry that is not a URL: %q", vol3ISFKey, entry)
+	}
+	u.values = append(u.values, unquoteYAMLString(entry))
+	return nil
+}
+
+// withURL returns the config lines with url added to remote_isf_url, or
+// with replace made its only value. It edits as little as it can, so the
+// rest of the file keeps its layout and comments: an item is added to a
+// list, a single URL becomes a list of two, and a config without the key
+// gets it at the end, before the document's closing ... if any.
+func (u isfURLs) withURL(lines []string, url string, replace bool) []string {
+	url = quoteYAMLString(url)
+	entry := vol3ISFKey + ": " + url
+	var insert []string
+	at, skip := u.start, 1
+
+	switch {
+	case u.start < 0:
+		at = len(lines)
+		for i, line := range lines {
+			if line == "..." {
+				at = i
+				break
+			}
+		}
+		end := at
+		for at > 0 && strings.TrimSpace(lines[at-1]) == "" {
+			at--
+		}
+		if at > 0 {
+			insert = append(insert, "")
+		}
+		insert = append(insert, "# Added by basar", entry)
+		skip = 0
+		if end == len(lines) {
+			skip = end - at // Blank lines ending the file
+		}
+	case replace:
+		insert, skip = []string{entry}, u.end-u.start
+	case u.style == isfNone:
+		insert = []string{entry}
+	case u.style == isfScalar:
+		insert = []string{vol3ISFKey + ":", "  - " + u.value, "  - " + url}
+	case u.style == isfBlock:
+		item := lines[u.last]
+		indent := item[:len(item)-len(strings.TrimLeft(item, " "))]
+		at, skip = u.last+1, 0
+		insert = []string{indent + "- " + url}
+	case u.style == isfFlow:
+		line := lines[u.last]
+		code := line
+		if i := strings.Index(line, " #"); i >= 0 {
+			code = line[:i]
+		}
+		close := strings.LastIndex(code, "]")
+		sep := ", "
+		if len(u.values) == 0 {
+			sep = ""
+		}
+		at = u.last
+		insert = []string{strings.TrimRight(line[:close], " ") + sep + url + line[close:]}
+	}
+
+	edited := make([]string, 0, len(lines)+len(insert))
+	edited = append(edited, lines[:at]...)
+	edited = append(edited, insert...)
+	return append(edited, lines[at+skip:]...)
+}
+
+// quoteYAMLString returns s as a YAML scalar: plain when YAML would read it
+// back as is, else single-quoted.
+func quoteYAMLString(s string) string {
+	plain := s != "" && !strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(s, ": ") && !strings.Contains(s, " #") &&
+		!strings.HasSuffix(s, ":") && strings.TrimSpace(s) == s
+	if plain {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// unquoteYAMLString decodes a scalar, undoing single quotes' doubled quotes
+// and double quotes' escapes.
+func unquoteYAMLString(s string) string {
+	switch {
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return unquoteYAML(s)
+}
+
+// splitYAMLKey splits a top-level "key: value" line, the key plain or
+// quoted.
+func splitYAMLKey(line string) (key, value string, err error) {
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		var s yamlScan
+		rest := s.scan(line)
+		if s.pending() {
+			return "", "", errors.New("unterminated quoted key")
+		}
+		after, ok := strings.CutPrefix(strings.TrimLeft(rest, " "), ":")
+		if !ok || (after != "" && after[0] != ' ') {
+			return "", "", errors.New("expected key: value")
+		}
+		return unquoteYAML(line[:len(line)-len(rest)]), strings.TrimSpace(after), nil
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ') {
+			return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), nil
+		}
+		if line[i] == '#' && i > 0 && line[i-1] == ' ' {
+			break
+		}
+	}
+	return "", "", errors.New("expected key: value")
+}
+
+// yamlScan follows quotes and flow brackets across lines.
+type yamlScan struct {
+	quote byte // Open quote, or 0
+	depth int  // Open [ and { brackets
+}
+
+func (s *yamlScan) pending() bool {
+	return s.quote != 0 || s.depth > 0
+}
+
+func (s *yamlScan) unterminated() string {
+	if s.quote != 0 {
+		return "unterminated quoted value"
+	}
+	return "unterminated flow collection"
+}
+
+// scan consumes text up to where the value it continues ends, returning
+// the rest; all of it is consumed while the value stays open.
+func (s *yamlScan) scan(text string) string {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case s.quote == '"' && c == '\\':
+			i++
+		case s.quote == '\'' && c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case s.quote != 0 && c == s.quote:
+			s.quote = 0
+			if s.depth == 0 {
+				return text[i+1:]
+			}
+		case s.quote != 0:
+		case c == '"' || c == '\'':
+			s.quote = c
+		case c == '[' || c == '{':
+			s.depth++
+		case c == ']' || c == '}':
+			s.depth--
+			if s.depth == 0 {
+				return text[i+1:]
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return ""
+		}
+	}
+	return ""
+}
+
+func stripYAMLComment(value string) string {
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// replaceFile replaces the file at path, or the file a symlink there
+// points at, with data: written to a temporary file beside it, synced, and
+// renamed over it with its permissions, so an interruption leaves either
+// the old file or the new one.
+func replaceFile(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	mode := os.FileMode(FileMode)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
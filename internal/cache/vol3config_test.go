@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseVol3Config(t *testing.T) {
+	valid := `# volatility3 settings
+---
+offline: false
+cache_path: "/srv/vol3 cache" # quoted, with a comment
+single: 'it''s'
+plugins:
+  - linux.pslist
+  - linux.bash
+symbol_dirs:
+- /srv/isf
+mirrors: # one a line
+- https://isf.example
+notes: |
+  free text: with "quotes" and [brackets
+  over lines
+filters: [linux.*,
+  "windows: #1"]
+nested:
+  depth: 2
+"quoted key": value
+`
+	keys, err := parseVol3Config(valid)
+	if err != nil {
+		t.Fatalf("parseVol3Config() failed: %v", err)
+	}
+	want := map[string]string{
+		"offline":    "false",
+		"cache_path": "/srv/vol3 cache",
+		"single":     "it''s",
+		"quoted key": "value",
+	}
+	for key, value := range want {
+		if keys[key] != value {
+			t.Errorf("keys[%q] = %q, expected %q", key, keys[key], value)
+		}
+	}
+	for _, key := range []string{"plugins", "symbol_dirs", "mirrors", "notes", "filters", "nested"} {
+		if _, ok := keys[key]; !ok {
+			t.Errorf("key %q missing", key)
+		}
+	}
+
+	invalid := []struct {
+		name, data, err string
+	}{
+		{"tab indentation", "nested:\n\tdepth: 2\n", "tab in indentation"},
+		{"indented first line", "  offline: false\n", "before any key"},
+		{"top-level list", "- a\n- b\n", "not a mapping"},
+		{"list after scalar", "offline: false\n- a\n", "not a mapping"},
+		{"no colon", "offline false\n", "expected key: value"},
+		{"duplicate key", "a: 1\na: 2\n", `duplicate key "a"`},
+		{"unterminated quote", "path: \"/srv\n", "unterminated quoted value"},
+		{"unterminated flow", "list: [a,\nnext: b\n", "unterminated flow collection"},
+		{"junk after quote", "path: \"/srv\" extra\n", "unexpected"},
+		{"nested mapping on one line", "a: b: c\n", "mapping value not allowed"},
+		{"item after scalar", "remote_isf_url: file:///cache\n  - https://b.example/y.json\n", "indented content after a value"},
+		{"continued scalar", "a: b\n  c\n", "indented content after a value"},
+		{"item after flow list", "a: [b]\n  - c\n", "indented content after a value"},
+		{"second document", "a: 1\n---\nb: 2\n", "multiple documents"},
+		{"content after the document", "a: 1\n...\nb: 2\n", "multiple documents"},
+		{"content on a marker", "--- a: 1\n", "document marker"},
+		{"directive after content", "a: 1\n%YAML 1.2\n", "directive"},
+		{"top-level flow mapping", "{a: 1}\n", "flow collection at the top level"},
+		{"complex key", "? a\n: 1\n", "complex keys"},
+		{"merge key", "<<: *base\n", "merge keys"},
+		{"anchored value", "a: &x 1\n", "anchors, aliases, and tags"},
+		{"alias value", "remote_isf_url: *urls\n", "anchors, aliases, and tags"},
+		{"tagged value", "a: !!str 1\n", "anchors, aliases, and tags"},
+		{"anchored key", "&k a: 1\n", "anchors, aliases, and tags"},
+	}
+	for _, tt := range invalid {
+		if _, err := parseVol3Config(tt.data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: parseVol3Config() = %v, expected %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// vol3Home points the home directory at a temp dir and returns the
+// volatility3 config path in it.
+func vol3Home(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("APPDATA", "")
+	t.Setenv("VIRTUAL_ENV", "")
+	t.Setenv("PIPX_HOME", filepath.Join(home, "pipx"))
+	return filepath.Join(home, ".volatility3.yaml")
+}
+
+func TestConfigureVolatility3Appends(t *testing.T) {
+	path := vol3Home(t)
+	existing := "offline: true\n# remote_isf_url: http://old.example\nnotes: |\n  kept"
+	if err := os.WriteFile(path, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	if _, err := c.ConfigureVolatility3(false); err != nil {
+		t.Fatalf("ConfigureVolatility3(false) failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(content), existing+"\n\n# Added by basar\nremote_isf_url: file://") {
+		t.Errorf("config = %q, expected the existing content followed by basar's", content)
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+			t.Errorf("mode = %v, expected the original 0600", info.Mode().Perm())
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".volatility3.yaml.*")); len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
+
+func TestConfigureVolatility3LeavesInvalidConfig(t *testing.T) {
+	path := vol3Home(t)
+	existing := "plugins: [linux.pslist,\n"
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	_, err := c.ConfigureVolatility3(false)
+	if err == nil || !strings.Contains(err.Error(), "not valid YAML") {
+		t.Errorf("ConfigureVolatility3(false) = %v, expected an invalid YAML error", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != existing {
+		t.Errorf("config changed to %q", content)
+	}
+}
+
+func TestConfigureVolatility3FollowsSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	path := vol3Home(t)
+	target := filepath.Join(t.TempDir(), "dotfiles-volatility3.yaml")
+	if err := os.WriteFile(target, []byte("offline: false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	if _, err := c.ConfigureVolatility3(false); err != nil {
+		t.Fatalf("ConfigureVolatility3(false) failed: %v", err)
+	}
+	if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s should still be a symlink", path)
+	}
+	if content, _ := os.ReadFile(target); !strings.Contains(string(content), "remote_isf_url: ") {
+		t.Errorf("symlink target = %q, expected basar's URL", content)
+	}
+}
+
+func TestParseISFURLs(t *testing.T) {
+	tests := []struct {
+		name, data string
+		want       []string
+		start, end int
+	}{
+		{"missing", "offline: true\n", nil, -1, 0},
+		{"scalar", "offline: true\nremote_isf_url: 'https://a.example/isf.json' # mirror\n", []string{"https://a.example/isf.json"}, 1, 2},
+		{"null", "remote_isf_url: ~\noffline: true\n", nil, 0, 1},
+		{"block list", "remote_isf_url:\n  - https://a.example\n  # off: https://b.example\n  - \"file:///srv/isf.json\"\n\noffline: true\n", []string{"https://a.example", "file:///srv/isf.json"}, 0, 4},
+		{"unindented list", "remote_isf_url:\n- https://a.example\n- https://b.example\n", []string{"https://a.example", "https://b.example"}, 0, 3},
+		{"flow list", "remote_isf_url: [https://a.example,\n  'https://b.example']\noffline: true\n", []string{"https://a.example", "https://b.example"}, 0, 2},
+		{"quoted comma in flow list", "remote_isf_url: ['https://a.example/x,y.json', \"b\"]\n", []string{"https://a.example/x,y.json", "b"}, 0, 1},
+		{"unindented comment in list", "remote_isf_url:\n  - https://a.example\n# note\n\n  - https://b.example\n# next\noffline: true\n", []string{"https://a.example", "https://b.example"}, 0, 5},
+	}
+	for _, tt := range tests {
+		got, err := parseISFURLs(strings.Split(tt.data, "\n"))
+		if err != nil {
+			t.Errorf("%s: parseISFURLs() failed: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got.values, tt.want) || got.start != tt.start || (tt.start >= 0 && got.end != tt.end) {
+			t.Errorf("%s: parseISFURLs() = %+v, expected %q at lines %d-%d", tt.name, got, tt.want, tt.start, tt.end)
+		}
+	}
+
+	for _, data := range []string{
+		"remote_isf_url:\n  nested: value\n",
+		"remote_isf_url:\n  - [a, b]\n",
+		"remote_isf_url: |\n  https://a.example\n",
+	} {
+		if _, err := parseISFURLs(strings.Split(data, "\n")); err == nil {
+			t.Errorf("parseISFURLs(%q) succeeded, expected an error", data)
+		}
+	}
+}
+
+func TestISFURLsWithURL(t *testing.T) {
+	const url = "file:///cache/banners.json"
+	tests := []struct {
+		name, data string
+		replace    bool
+		want       string
+	}{
+		{"no key", "offline: true\n\n", false,
+			"offline: true\n\n# Added by basar\nremote_isf_url: " + url},
+		{"empty config", "", false,
+			"# Added by basar\nremote_isf_url: " + url},
+		{"closed document", "---\noffline: true\n...", false,
+			"---\noffline: true\n\n# Added by basar\nremote_isf_url: " + url + "\n..."},
+		{"null", "remote_isf_url: ~\n# later\noffline: true", false,
+			"remote_isf_url: " + url + "\n# later\noffline: true"},
+		{"block list", "remote_isf_url:   # mirrors\n    - https://a.example  # primary\n    # - https://old.example\n    - https://b.example\n\n# next\noffline: true", false,
+			"remote_isf_url:   # mirrors\n    - https://a.example  # primary\n    # - https://old.example\n    - https://b.example\n    - " + url + "\n\n# next\noffline: true"},
+		{"flow list", "remote_isf_url: [https://a.example,\n  https://b.example] # both\noffline: true", false,
+			"remote_isf_url: [https://a.example,\n  https://b.example, " + url + "] # both\noffline: true"},
+		{"empty flow list", "remote_isf_url: []", false,
+			"remote_isf_url: [" + url + "]"},
+		{"replace list", "remote_isf_url:\n  - https://a.example\n  - https://b.example\noffline: true", true,
+			"remote_isf_url: " + url + "\noffline: true"},
+	}
+	for _, tt := range tests {
+		lines := strings.Split(tt.data, "\n")
+		if tt.data == "" {
+			lines = nil
+		}
+		u, err := parseISFURLs(lines)
+		if err != nil {
+			t.Errorf("%s: parseISFURLs() failed: %v", tt.name, err)
+			continue
+		}
+		got := strings.Join(u.withURL(lines, url, tt.replace), "\n")
+		if got != tt.want {
+			t.Errorf("%s: withURL() = %q, expected %q", tt.name, got, tt.want)
+		}
+		if _, err := parseVol3Config(got); err != nil {
+			t.Errorf("%s: result does not parse: %v", tt.name, err)
+		}
+	}
+}
+
+func TestQuoteYAMLString(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"file:///srv/isf/banners.json", "file:///srv/isf/banners.json"},
+		{"file:///C:/Users/me/banners.json", "file:///C:/Users/me/banners.json"},
+		{"file:///srv/it's #1", "'file:///srv/it''s #1'"},
+		{"-dash", "'-dash'"},
+	}
+	for _, tt := range tests {
+		got := quoteYAMLString(tt.in)
+		if got != tt.want {
+			t.Errorf("quoteYAMLString(%q) = %s, expected %s", tt.in, got, tt.want)
+		}
+		if back := unquoteYAMLString(got); back != tt.in {
+			t.Errorf("unquoteYAMLString(%s) = %q, expected %q", got, back, tt.in)
+		}
+	}
+	if got := unquoteYAMLString(`"file:///a\tb"`); got != "file:///a\tb" {
+		t.Errorf("unquoteYAMLString() = %q, expected the escape decoded", got)
+	}
+}
+
+func TestConfigureVolatility3AddsToList(t *testing.T) {
+	path := vol3Home(t)
+	if err := os.WriteFile(path, []byte("remote_isf_url: 'https://isf.example/banners.json' # team mirror\noffline: false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	uri := fileURI(c.cfg.CacheFile)
+	if _, err := c.ConfigureVolatility3(false); err != nil {
+		t.Fatalf("ConfigureVolatility3(false) failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	want := "remote_isf_url:\n  - 'https://isf.example/banners.json' # team mirror\n  - " + uri + "\noffline: false\n"
+	if string(content) != want {
+		t.Errorf("config = %q, expected %q", content, want)
+	}
+
+	// Running it again changes nothing
+	if _, err := c.ConfigureVolatility3(false); !errors.Is(err, ErrVol3AlreadyConfigured) {
+		t.Errorf("second ConfigureVolatility3(false) = %v, expected ErrVol3AlreadyConfigured", err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != want {
+		t.Errorf("config changed to %q", again)
+	}
+
+	// --replace leaves the cache alone in the list
+	if _, err := c.ConfigureVolatility3(true); err != nil {
+		t.Fatalf("ConfigureVolatility3(true) failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "remote_isf_url: "+uri+"\noffline: false\n" {
+		t.Errorf("replaced config = %q", content)
+	}
+}
+
+func TestConfigureVolatility3CommentInList(t *testing.T) {
+	path := vol3Home(t)
+	c := New(testConfig(t))
+	uri := fileURI(c.cfg.CacheFile)
+
+	// A comment between the items does not end the list: --replace
+	// replaces all of it
+	list := "remote_isf_url:\n  - https://a.example/x.json\n# note\n  - https://b.example/y.json\n\n# next\noffline: false\n"
+	if err := os.WriteFile(path, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ConfigureVolatility3(true); err != nil {
+		t.Fatalf("ConfigureVolatility3(true) failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "remote_isf_url: "+uri+"\n\n# next\noffline: false\n" {
+		t.Errorf("replaced config = %q", content)
+	}
+
+	// ...and the cache listed after the comment is found, so running
+	// again changes nothing
+	listed := "remote_isf_url:\n  - https://a.example/x.json\n# note\n  - " + uri + "\n"
+	if err := os.WriteFile(path, []byte(listed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ConfigureVolatility3(false); !errors.Is(err, ErrVol3AlreadyConfigured) {
+		t.Errorf("ConfigureVolatility3(false) = %v, expected ErrVol3AlreadyConfigured", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != listed {
+		t.Errorf("config changed to %q", content)
+	}
+}
+
+func TestConfigureVolatility3LeavesUnknownISFValue(t *testing.T) {
+	path := vol3Home(t)
+	existing := "remote_isf_url: &mirror https://isf.example\n"
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	if _, err := c.ConfigureVolatility3(false); err == nil || !strings.Contains(err.Error(), "anchors") {
+		t.Errorf("ConfigureVolatility3(false) = %v, expected an error about the anchor", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != existing {
+		t.Errorf("config changed to %q", content)
+	}
+
+	// A flow list's entries are split where YAML would split them
+	for existing, err := range map[string]string{
+		"remote_isf_url: ['https://a.example/x,y.json', [nested]]\n": "nested",
+		"remote_isf_url: [&first https://a.example/x.json]\n":        "not a URL",
+	} {
+		if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := c.ConfigureVolatility3(false); got == nil || !strings.Contains(got.Error(), err) {
+			t.Errorf("ConfigureVolatility3(false) of %q = %v, expected %q", existing, got, err)
+		}
+		if content, _ := os.ReadFile(path); string(content) != existing {
+			t.Errorf("config changed to %q", content)
+		}
+	}
+}
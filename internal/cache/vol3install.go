@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Ways volatility3 may be installed, as Vol3Install.Via reports them.
+const (
+	Vol3ViaVenv = "venv" // In the active virtual environment
+	Vol3ViaPipx = "pipx" // In a pipx environment
+	Vol3ViaPip  = "pip"  // In a user or system site-packages directory
+)
+
+// vol3JSONConfig is the JSON config some volatility3 releases read from
+// their package directory.
+const vol3JSONConfig = "vol.config.json"
+
+// Vol3Install is a volatility3 installation found on this machine.
+type Vol3Install struct {
+	// Dir is the volatility3 package directory.
+	Dir string `json:"dir"`
+	// Version is the installed release, empty when its metadata is
+	// missing.
+	Version string `json:"version,omitempty"`
+	Via     string `json:"via"`
+}
+
+// Vol3Config is a volatility3 config file ConfigureVolatility3 looked at.
+type Vol3Config struct {
+	Path string `json:"path"`
+	// Changed reports whether it was written, Created that it did not
+	// exist before.
+	Changed bool `json:"changed"`
+	Created bool `json:"created,omitempty"`
+}
+
+// Vol3Report describes what ConfigureVolatility3 found and changed.
+type Vol3Report struct {
+	Installs []Vol3Install `json:"installs"`
+	Configs  []Vol3Config  `json:"configs"`
+}
+
+// Changed reports whether any config was written.
+func (r *Vol3Report) Changed() bool {
+	for _, c := range r.Configs {
+		if c.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// findVol3Installs looks for volatility3 in the active virtual
+// environment, in pipx environments, and in the user and system
+// site-packages directories pip installs into. It does not run Python, so
+// installs elsewhere are missed.
+func findVol3Installs() []Vol3Install {
+	type site struct{ pattern, via string }
+	var sites []site
+	lib := filepath.Join("lib", "python3*", "site-packages")
+	if runtime.GOOS == "windows" {
+		lib = filepath.Join("Lib", "site-packages")
+	}
+	addEnv := func(root, via string) {
+		sites = append(sites, site{filepath.Join(root, lib), via})
+	}
+
+	if venv := os.Getenv("VIRTUAL_ENV"); venv != "" {
+		addEnv(venv, Vol3ViaVenv)
+	}
+	home, _ := os.UserHomeDir()
+	pipxHome := os.Getenv("PIPX_HOME")
+	if pipxHome == "" && home != "" {
+		pipxHome = filepath.Join(home, ".local", "share", "pipx")
+		if runtime.GOOS == "windows" {
+			pipxHome = filepath.Join(home, "pipx")
+		}
+	}
+	if pipxHome != "" {
+		addEnv(filepath.Join(pipxHome, "venvs", "volatility3"), Vol3ViaPipx)
+	}
+
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			sites = append(sites, site{filepath.Join(appData, "Python", "Python3*", "site-packages"), Vol3ViaPip})
+		}
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			sites = append(sites, site{filepath.Join(local, "Programs", "Python", "Python3*", "Lib", "site-packages"), Vol3ViaPip})
+		}
+	} else {
+		if home != "" {
+			sites = append(sites, site{filepath.Join(home, ".local", "lib", "python3*", "site-packages"), Vol3ViaPip})
+			sites = append(sites, site{filepath.Join(home, "Library", "Python", "3*", "lib", "python", "site-packages"), Vol3ViaPip})
+		}
+		for _, pattern := range []string{
+			"/usr/local/lib/python3*/site-packages",
+			"/usr/local/lib/python3*/dist-packages",
+			"/usr/lib/python3*/site-packages",
+			"/usr/lib/python3/dist-packages",
+		} {
+			sites = append(sites, site{pattern, Vol3ViaPip})
+		}
+	}
+
+	var installs []Vol3Install
+	seen := make(map[string]bool)
+	for _, s := range sites {
+		dirs, _ := filepath.Glob(s.pattern)
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			pkg := filepath.Join(dir, "volatility3")
+			if info, err := os.Stat(filepath.Join(pkg, "framework")); err != nil || !info.IsDir() || seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			installs = append(installs, Vol3Install{Dir: pkg, Version: distVersion(dir, "volatility3"), Via: s.via})
+		}
+	}
+	return installs
+}
+
+// distVersion returns the version of a distribution installed in a
+// site-packages directory, read from its dist-info directory's name.
+func distVersion(site, name string) string {
+	matches, _ := filepath.Glob(filepath.Join(site, name+"-*.dist-info"))
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	base := filepath.Base(matches[len(matches)-1])
+	return strings.TrimSuffix(strings.TrimPrefix(base, name+"-"), ".dist-info")
+}
+
+// vol3ConfigPaths returns the YAML config files basar configures: those
+// that exist among ~/.volatility3.yaml and, on Windows,
+// %APPDATA%\volatility3\volatility3.yaml, or the one this platform's
+// volatility3 reads when there are none.
+func vol3ConfigPaths() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	candidates := []string{filepath.Join(home, ".volatility3.yaml")}
+	fallback := candidates[0]
+	if appData := os.Getenv("APPDATA"); runtime.GOOS == "windows" && appData != "" {
+		dir := filepath.Join(appData, "volatility3")
+		candidates = append(candidates, filepath.Join(dir, "volatility3.yaml"))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			fallback = candidates[1]
+		}
+	}
+
+	var paths []string
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{fallback}
+	}
+	return paths, nil
+}
+
+// configureVol3JSON adds url to remote_isf_url in a volatility3 JSON
+// config, as configureVol3YAML does in a YAML one, writing it only with
+// write. The other keys are
+// kept, in sorted order.
+func configureVol3JSON(path, url string, replace, write bool) (bool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading volatility3 config: %w", err)
+	}
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return false, fmt.Errorf("volatility3 config %s is not a JSON object, leaving it unchanged: %w", path, err)
+	}
+
+	var urls []string
+	if value, ok := config[vol3ISFKey]; ok && string(value) != "null" {
+		var single string
+		if err := json.Unmarshal(value, &single); err == nil {
+			urls = []string{single}
+		} else if err := json.Unmarshal(value, &urls); err != nil {
+			return false, fmt.Errorf("volatility3 config %s: %s is neither a URL nor a list of URLs, leaving it unchanged", path, vol3ISFKey)
+		}
+	}
+	switch {
+	case len(urls) == 1 && urls[0] == url:
+		return false, nil
+	case replace:
+		urls = []string{url}
+	case slices.Contains(urls, url):
+		return false, nil
+	default:
+		urls = append(urls, url)
+	}
+
+	var value any = urls
+	if len(urls) == 1 {
+		value = urls[0]
+	}
+	if config[vol3ISFKey], err = json.Marshal(value); err != nil {
+		return false, err
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if !write {
+		return true, nil
+	}
+	if err := replaceFile(path, append(out, '\n')); err != nil {
+		return false, fmt.Errorf("writing volatility3 config: %w", err)
+	}
+	return true, nil
+}
+
+// vol3JSONConfigs returns the JSON configs in the package directories of
+// installs.
+func vol3JSONConfigs(installs []Vol3Install) []string {
+	var paths []string
+	for _, install := range installs {
+		path := filepath.Join(install.Dir, vol3JSONConfig)
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
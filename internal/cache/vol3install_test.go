@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeVol3Install lays out a volatility3 package of version in the
+// site-packages directory of the Python environment at root, returning the
+// package directory.
+func fakeVol3Install(t *testing.T, root, version string) string {
+	t.Helper()
+	site := filepath.Join(root, "lib", "python3.12", "site-packages")
+	if runtime.GOOS == "windows" {
+		site = filepath.Join(root, "Lib", "site-packages")
+	}
+	pkg := filepath.Join(site, "volatility3")
+	for _, dir := range []string{filepath.Join(pkg, "framework"), filepath.Join(site, "volatility3-"+version+".dist-info")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pkg
+}
+
+func TestFindVol3Installs(t *testing.T) {
+	vol3Home(t)
+	venv := t.TempDir()
+	t.Setenv("VIRTUAL_ENV", venv)
+	venvPkg := fakeVol3Install(t, venv, "2.7.0")
+	pipxPkg := fakeVol3Install(t, filepath.Join(os.Getenv("PIPX_HOME"), "venvs", "volatility3"), "2.5.2")
+
+	found := make(map[string]Vol3Install)
+	for _, install := range findVol3Installs() {
+		found[install.Dir] = install
+	}
+	if got := found[venvPkg]; got.Via != Vol3ViaVenv || got.Version != "2.7.0" {
+		t.Errorf("venv install = %+v, expected volatility3 2.7.0 via venv", got)
+	}
+	if got := found[pipxPkg]; got.Via != Vol3ViaPipx || got.Version != "2.5.2" {
+		t.Errorf("pipx install = %+v, expected volatility3 2.5.2 via pipx", got)
+	}
+}
+
+func TestConfigureVol3JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), vol3JSONConfig)
+	const url = "file:///cache/banners.json"
+	if err := os.WriteFile(path, []byte(`{"remote_isf_url": "https://isf.example", "plugins": ["linux.pslist"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() map[string]any {
+		var config map[string]any
+		raw, _ := os.ReadFile(path)
+		if err := json.Unmarshal(raw, &config); err != nil {
+			t.Fatalf("config is not JSON: %v", err)
+		}
+		return config
+	}
+
+	if changed, err := configureVol3JSON(path, url, false, true); err != nil || !changed {
+		t.Fatalf("configureVol3JSON() = %v, %v", changed, err)
+	}
+	config := read()
+	if urls, ok := config[vol3ISFKey].([]any); !ok || len(urls) != 2 || urls[1] != url || config["plugins"] == nil {
+		t.Errorf("config = %v, expected the cache added to remote_isf_url and plugins kept", config)
+	}
+	if changed, err := configureVol3JSON(path, url, false, true); err != nil || changed {
+		t.Errorf("second configureVol3JSON() = %v, %v; expected no change", changed, err)
+	}
+	if changed, err := configureVol3JSON(path, url, true, true); err != nil || !changed || read()[vol3ISFKey] != url {
+		t.Errorf("configureVol3JSON(replace) = %v, %v, config %v", changed, err, read())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"remote_isf_url": {"a": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configureVol3JSON(path, url, false, true); err == nil || !strings.Contains(err.Error(), "leaving it unchanged") {
+		t.Errorf("configureVol3JSON() on an object = %v, expected an error", err)
+	}
+}
+
+func TestConfigureVolatility3Report(t *testing.T) {
+	yamlPath := vol3Home(t)
+	venv := t.TempDir()
+	t.Setenv("VIRTUAL_ENV", venv)
+	pkg := fakeVol3Install(t, venv, "2.7.0")
+	jsonPath := filepath.Join(pkg, vol3JSONConfig)
+	if err := os.WriteFile(jsonPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(testConfig(t))
+	report, err := c.ConfigureVolatility3(false)
+	if err != nil {
+		t.Fatalf("ConfigureVolatility3() failed: %v", err)
+	}
+	want := []Vol3Config{{Path: yamlPath, Changed: true, Created: true}, {Path: jsonPath, Changed: true}}
+	if len(report.Installs) == 0 || report.Installs[0].Dir != pkg || len(report.Configs) != 2 ||
+		report.Configs[0] != want[0] || report.Configs[1] != want[1] {
+		t.Errorf("report = %+v, expected the venv install and %+v", report, want)
+	}
+
+	report, err = c.ConfigureVolatility3(false)
+	if !errors.Is(err, ErrVol3AlreadyConfigured) || report.Changed() {
+		t.Errorf("second ConfigureVolatility3() = %+v, %v; expected ErrVol3AlreadyConfigured", report, err)
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BannerFilter restricts the banners merged into the cache, e.g. to some
+// distributions or kernel versions. A banner is kept when it matches an
+// Include pattern (or there are none), matches no Exclude pattern, and its
+// kernel version lies within MinKernel and MaxKernel where set.
+type BannerFilter struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+
+	// MinKernel and MaxKernel bound the kernel version, compared on the
+	// components given: a MaxKernel of 6.1 keeps 6.1.90. Banners whose
+	// version cannot be read are dropped when either is set.
+	MinKernel []int
+	MaxKernel []int
+}
+
+// Keeps reports whether the filter keeps banner. A nil filter keeps
+// everything.
+func (f *BannerFilter) Keeps(banner string) bool {
+	if f == nil {
+		return true
+	}
+	if matchesAny(f.Exclude, banner) || len(f.Include) > 0 && !matchesAny(f.Include, banner) {
+		return false
+	}
+	if f.MinKernel == nil && f.MaxKernel == nil {
+		return true
+	}
+
+	version, ok := BannerKernel(banner)
+	if !ok {
+		return false
+	}
+	if f.MinKernel != nil && compareVersion(version, f.MinKernel) < 0 {
+		return false
+	}
+	return f.MaxKernel == nil || compareVersion(version, f.MaxKernel) <= 0
+}
+
+// Empty reports whether the filter keeps every banner.
+func (f *BannerFilter) Empty() bool {
+	return f == nil || len(f.Include) == 0 && len(f.Exclude) == 0 && f.MinKernel == nil && f.MaxKernel == nil
+}
+
+// Add adds a filters file directive to f: `include PATTERN`, `exclude
+// PATTERN`, `min-kernel VERSION`, or `max-kernel VERSION`.
+func (f *BannerFilter) Add(kind, arg string) error {
+	if arg == "" {
+		return errors.New("missing argument")
+	}
+	switch kind {
+	case "include", "exclude":
+		re, err := CompileBannerPattern(arg)
+		if err != nil {
+			return err
+		}
+		if kind == "include" {
+			f.Include = append(f.Include, re)
+		} else {
+			f.Exclude = append(f.Exclude, re)
+		}
+	case "min-kernel", "max-kernel":
+		v, err := ParseKernelVersion(arg)
+		if err != nil {
+			return err
+		}
+		if kind == "min-kernel" {
+			f.MinKernel = v
+		} else {
+			f.MaxKernel = v
+		}
+	default:
+		return errors.New("expected include, exclude, min-kernel, or max-kernel")
+	}
+	return nil
+}
+
+// loadBannerFilter reads a banner-filters.conf file of Add directives, with
+// blank lines and # comments ignored. It returns nil if the file is missing
+// or holds no directive, and a warning for each line it skips.
+func loadBannerFilter(path string) (*BannerFilter, []string) {
+	lines, err := readSourceLines(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	f := &BannerFilter{}
+	var warnings []string
+	for _, line := range lines {
+		if err := f.Add(splitFilterLine(line)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: %v", path, line, err))
+		}
+	}
+	if f.Empty() {
+		return nil, warnings
+	}
+	return f, warnings
+}
+
+// CompileBannerPattern compiles a banner filter pattern: a regular
+// expression after "re:", otherwise text the banner must contain, in any
+// case, such as "ubuntu" or ".el8".
+func CompileBannerPattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(expr)
+	}
+	return regexp.Compile("(?i)" + regexp.QuoteMeta(pattern))
+}
+
+// ParseKernelVersion parses a kernel version bound such as "5", "5.15", or
+// "v4.19.0".
+func ParseKernelVersion(s string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("%q is not a kernel version like 5.15", s)
+	}
+	v := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q is not a kernel version like 5.15", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// kernelRelease matches the version at the start of a banner's release.
+var kernelRelease = regexp.MustCompile(`^Linux version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// BannerKernel returns the kernel version of a banner, e.g. [5 15 0] for
+// "Linux version 5.15.0-91-generic ...".
+func BannerKernel(banner string) ([]int, bool) {
+	m := kernelRelease.FindStringSubmatch(banner)
+	if m == nil {
+		return nil, false
+	}
+	v := make([]int, 0, 3)
+	for _, s := range m[1:] {
+		if s == "" {
+			break
+		}
+		n, _ := strconv.Atoi(s)
+		v = append(v, n)
+	}
+	return v, true
+}
+
+// BannerRelease returns the kernel release of a banner, the field after
+// "Linux version", e.g. "5.15.0-91-generic".
+func BannerRelease(banner string) (string, bool) {
+	rest, ok := strings.CutPrefix(banner, "Linux version ")
+	if !ok {
+		return "", false
+	}
+	release, _, _ := strings.Cut(rest, " ")
+	return release, release != ""
+}
+
+// compareVersion compares version with bound on the components bound
+// gives, returning -1, 0, or 1. Missing components of version count as 0.
+func compareVersion(version, bound []int) int {
+	for i, b := range bound {
+		var v int
+		if i < len(version) {
+			v = version[i]
+		}
+		switch {
+		case v < b:
+			return -1
+		case v > b:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	ubuntuBanner = "Linux version 5.15.0-91-generic (buildd@lcy02-amd64-045) (gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0) #101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023"
+	debianBanner = "Linux version 6.1.0-18-amd64 (debian-kernel@lists.debian.org) (gcc-12 (Debian 12.2.0-14) 12.2.0) #1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1 (2024-02-01)"
+	rtBanner     = "Linux version 6.1.0-18-rt-amd64 (debian-kernel@lists.debian.org) (gcc-12 (Debian 12.2.0-14) 12.2.0) #1 SMP PREEMPT_RT Debian 6.1.76-1 (2024-02-01)"
+	rhelBanner   = "Linux version 4.18.0-513.el8.x86_64 (mockbuild@x86-vm-07.build.eng.bos.redhat.com) (gcc version 8.5.0 20210514 (Red Hat 8.5.0-20) (GCC)) #1 SMP Wed Oct 4 07:03:56 EDT 2023"
+)
+
+func TestLoadBannerFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banner-filters.conf")
+	content := `# Ubuntu and Debian from 5.x on
+include ubuntu
+include	Debian
+exclude re:-rt-
+min-kernel 5
+max-kernel 6.1
+min-kernel five
+keep all
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, warnings := loadBannerFilter(path)
+	if f == nil || len(f.Include) != 2 || len(f.Exclude) != 1 {
+		t.Fatalf("loadBannerFilter() = %+v", f)
+	}
+	if !reflect.DeepEqual(f.MinKernel, []int{5}) || !reflect.DeepEqual(f.MaxKernel, []int{6, 1}) {
+		t.Errorf("kernel bounds = %v, %v", f.MinKernel, f.MaxKernel)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q, expected 2", warnings)
+	}
+
+	for banner, want := range map[string]bool{
+		ubuntuBanner:                     true,
+		debianBanner:                     true, // 6.1.0 is within max-kernel 6.1
+		rtBanner:                         false,
+		rhelBanner:                       false,
+		"Linux version unknown (Ubuntu)": false,
+	} {
+		if got := f.Keeps(banner); got != want {
+			t.Errorf("Keeps(%.40q) = %v, expected %v", banner, got, want)
+		}
+	}
+
+	if f, _ := loadBannerFilter(filepath.Join(t.TempDir(), "missing.conf")); f != nil || !f.Keeps(rhelBanner) || !f.Empty() {
+		t.Errorf("loadBannerFilter() of a missing file = %+v, expected nil keeping everything", f)
+	}
+}
+
+func TestBannerKernel(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   []int
+	}{
+		{ubuntuBanner, []int{5, 15, 0}},
+		{"Linux version 6.8-rc1 (x@y) #1", []int{6, 8}},
+		{"Linux version 2.6.32-754.el6.x86_64", []int{2, 6, 32}},
+		{"FreeBSD 13.2-RELEASE", nil},
+	}
+	for _, tt := range tests {
+		if got, ok := BannerKernel(tt.banner); !reflect.DeepEqual(got, tt.want) || ok != (tt.want != nil) {
+			t.Errorf("BannerKernel(%q) = %v, %v; expected %v", tt.banner, got, ok, tt.want)
+		}
+	}
+}
+
+func TestBannerRelease(t *testing.T) {
+	tests := []struct {
+		banner, want string
+	}{
+		{ubuntuBanner, "5.15.0-91-generic"},
+		{"Linux version 6.8-rc1", "6.8-rc1"},
+		{"Linux version ", ""},
+		{"FreeBSD 13.2-RELEASE", ""},
+	}
+	for _, tt := range tests {
+		if got, ok := BannerRelease(tt.banner); got != tt.want || ok != (tt.want != "") {
+			t.Errorf("BannerRelease(%q) = %q, %v; expected %q", tt.banner, got, ok, tt.want)
+		}
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	if v, err := ParseKernelVersion("v5.15"); err != nil || !reflect.DeepEqual(v, []int{5, 15}) {
+		t.Errorf("ParseKernelVersion(v5.15) = %v, %v", v, err)
+	}
+	for _, s := range []string{"", "5.x", "5.15.0.1", "-1"} {
+		if _, err := ParseKernelVersion(s); err == nil {
+			t.Errorf("ParseKernelVersion(%q) succeeded", s)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,54 +24,609 @@ const (
 	// DefaultTTL is the default cache validity duration.
 	DefaultTTL = 24 * time.Hour
 
+	// DefaultShrinkThreshold is the minimum fraction of the current entry
+	// count a new merge must keep before it may replace the cache.
+	DefaultShrinkThreshold = 0.5
+
+	// DefaultSourceShrinkThreshold is the minimum fraction of its typical
+	// entry count a source's fetch must return to be taken as usual.
+	DefaultSourceShrinkThreshold = 0.5
+
+	// DefaultJobs is how many sources are fetched at once by default.
+	DefaultJobs = 8
+
+	// MaxSplay bounds Splay to a day, so scheduled runs keep their date.
+	MaxSplay = 24 * time.Hour
+
 	// AppName is used for XDG directory names.
 	AppName = "basar"
 )
 
+// When the system-wide cache is printed instead of the user's, set by
+// BASAR_SYSTEM_CACHE.
+const (
+	SystemCacheAuto   = "auto"   // when the user has no cache yet
+	SystemCachePrefer = "prefer" // whenever it is valid
+	SystemCacheNever  = "never"
+)
+
 // Config holds application configuration.
 type Config struct {
-	CacheDir   string
-	ConfigDir  string
-	CacheFile  string
+	CacheDir     string
+	ConfigDir    string
+	StateDir     string
+	CacheFile    string
+	ConfigFile   string
+	LockFile     string
+	LogFile      string
+	MetaFile     string
+	LivenessFile string
+	HistoryFile  string
+	SnapshotDir  string
+	HooksDir     string
+	TTL          time.Duration
+	Sources      []string
+
+	// PruneReportFile keeps the report of the last basar prune.
+	PruneReportFile string
+	// QuarantineDir keeps the banners dropped from each source for
+	// straying from the schema.
+	QuarantineDir string
+
+	// OverridesFile holds local banner→URL changes applied after merging
+	// the sources, overriding them.
+	OverridesFile string
+
+	// OverlayFile holds the user's own banners, e.g. from dwarf2json,
+	// layered over the served cache at read time without modifying it;
+	// LayeredFile receives the merged view.
+	OverlayFile string
+	LayeredFile string
+
+	// URLFilterFile holds the allow and deny patterns of URLFilter, which
+	// is nil when it has none, and URLRedirects, the hosts whose symbol
+	// URLs are rewritten to the host they redirect to.
+	URLFilterFile string
+	URLFilter     *URLFilter
+	URLRedirects  map[string]string
+
+	// BannerFilterFile holds the directives of BannerFilter, which is nil
+	// when it has none.
+	BannerFilterFile string
+	BannerFilter     *BannerFilter
+
+	// NotifyFile routes update events to the Notify targets.
+	NotifyFile string
+	Notify     []NotifyTarget
+
+	// ProxyFile names ProxyPAC, the proxy auto-config file choosing the
+	// proxy of each request (a URL or path), unless BASAR_PROXY_PAC does.
+	// Empty uses the http_proxy and https_proxy variables.
+	ProxyFile string
+	ProxyPAC  string
+	// ProxyAuth is how proxy.conf authenticates to proxies: AuthNegotiate
+	// with Kerberos, AuthNetrc with the netrc file's credentials for the
+	// proxy, or AuthKeyring with the user:password credential store secret
+	// ProxyKeyring names. Empty sends no credentials.
+	ProxyAuth    string
+	ProxyKeyring string
+
+	// NegotiateCmd, from BASAR_NEGOTIATE_CMD, prints the Kerberos tokens of
+	// Negotiate authentication where the system provides none (anywhere
+	// but Windows).
+	NegotiateCmd string
+
+	// GitHubAPI, from BASAR_GITHUB_API, is the GitHub Enterprise API
+	// github:// sources are listed through; empty uses api.github.com.
+	GitHubAPI string
+
+	// ShrinkThreshold guards against replacing the cache with a merge that
+	// lost most of its entries (e.g. an upstream outage). Zero disables it.
+	ShrinkThreshold float64
+
+	// SourceShrinkThreshold flags a source whose fetch returned less than
+	// this fraction of its typical entry count, as a truncated upstream
+	// file would. Zero disables it.
+	SourceShrinkThreshold float64
+	// AcceptShrink merges the sources SourceShrinkThreshold flags even
+	// with shrink=quarantine, making their new entry counts the baseline.
+	AcceptShrink bool
+
+	// Jobs limits how many sources are fetched at once.
+	Jobs int
+
+	// MinSources fails an update in which fewer sources succeeded (zero
+	// only requires one); Strict requires every source to succeed.
+	MinSources int
+	Strict     bool
+
+	// DemoteDeadURLs moves symbol URLs that failed their recent checks to
+	// the end of each banner's list when merging.
+	DemoteDeadURLs bool
+
+	// DiskIndex writes a binary sidecar index of the cache on update, so
+	// lookups read single entries instead of loading the whole cache.
+	DiskIndex bool
+
+	// Splay spreads scheduled updates of a fleet over this window: each
+	// host waits a fixed offset within it derived from its hostname, so
+	// endpoints syncing from one mirror don't hit it at the same minute.
+	Splay time.Duration
+
+	// Scheduler, from BASAR_SCHEDULER, names the scheduler auto-updates
+	// are installed with (e.g. "cron"); empty picks this platform's.
+	Scheduler string
+
+	// StaleWhileRevalidate prints an expired cache right away and refreshes
+	// it in the background instead of updating before printing.
+	StaleWhileRevalidate bool
+
+	// TrackUsage has basar serve record the reads of the cache it serves
+	// in UsageFile. AccessLog names the access log, in common or combined
+	// format, of a web server serving the cache instead.
+	TrackUsage bool
+	UsageFile  string
+	AccessLog  string
+
+	// Offline forbids network access: only local sources are fetched, and
+	// an expired cache is used as is.
+	Offline bool
+
+	// FallbackCacheDir receives updates when CacheDir or StateDir is on a
+	// read-only filesystem (e.g. baked into a golden image); the read-only
+	// cache is still served until the fallback holds a newer one. Empty
+	// fails such updates.
+	FallbackCacheDir string
+
+	// TombstoneTTL keeps banners the sources stop listing in the cache for
+	// this long, marked as tombstoned. Zero drops them right away.
+	TombstoneTTL time.Duration
+
+	// Vol3Compat tailors the written cache to what this volatility3
+	// release accepts (e.g. "2.4.0"); empty writes everything.
+	Vol3Compat string
+
+	// Only restricts updates to the sources matching any of these
+	// selectors (see Selects); the others keep their last fetched data.
+	Only []string
+
+	// FailFast aborts an update on the first configuration error (e.g.
+	// rejected credentials) instead of merging the remaining sources.
+	FailFast bool
+
+	// LowPriority runs updates and serve at the lowest CPU and IO
+	// priority, for schedulers without their own priority settings.
+	LowPriority bool
+
+	// Options holds per-source settings keyed by source.
+	Options map[string]SourceOptions
+
+	// SystemConfigDir holds the system-wide sources.conf and
+	// sources.conf.d, layered beneath the user's. Empty disables it.
+	SystemConfigDir string
+
+	// SystemCacheDir holds a cache kept up to date for all users, e.g. by
+	// a system timer running basar --cache-dir /var/cache/basar. Users
+	// are pointed at it read-only, as SystemCache (BASAR_SYSTEM_CACHE)
+	// says, instead of each downloading their own. Empty disables it.
+	SystemCacheDir string
+	SystemCache    string
+
+	// SourceFiles lists the files Sources were read from, lowest layer
+	// first.
+	SourceFiles []string
+
+	// Profile is the named profile in use, empty for the default.
+	Profile string
+
+	// Warnings describes problems found while resolving paths, for the
+	// caller to report once a logger is available.
+	Warnings []string
+
+	// Migrations describes files moved from older layouts by New.
+	Migrations []string
+
+	// PendingMigrations lists, with Overrides.DryRun, the files from
+	// older layouts New left in place instead of migrating them.
+	PendingMigrations []Migration
+
+	// Overrides are those New applied, the BASAR_PROFILE, BASAR_CONFIG,
+	// and BASAR_CACHE_DIR variables included, for scheduled runs of basar
+	// to work on the same files.
+	Overrides Overrides
+}
+
+// SourceOptions holds per-source settings given after the source on its
+// sources.conf line, e.g. `https://... token_env=INTERNAL_TOKEN`.
+type SourceOptions struct {
+	// TokenEnv names an environment variable holding a bearer token.
+	TokenEnv string
+	// TokenFile is a file holding a bearer token; it must not be readable
+	// by group or others.
+	TokenFile string
+	// TokenCmd is a command whose first output line is a bearer token.
+	TokenCmd string
+	// TokenKeyring names a bearer token in the OS credential store.
+	TokenKeyring string
+	// Timeout overrides the HTTP timeout for this source.
+	Timeout time.Duration
+	// Required fails the update when this source fails, even if others
+	// succeeded, so the index is never published without it.
+	Required bool
+	// CursorField names the response field holding the next page's cursor,
+	// for sources paginated by cursor; CursorParam is the query parameter
+	// it is sent back in ("cursor" if empty).
+	CursorField string
+	CursorParam string
+	// Disabled keeps the source in the config without fetching it.
+	Disabled bool
+	// Tags group sources for selective updates, e.g. tag=ubuntu.
+	Tags []string
+	// Priority orders sources in the merge, highest first, so their URLs
+	// come first for banners several sources list. Sources of equal
+	// priority keep their configured order.
+	Priority int
+	// Auth is AuthNegotiate for sources authenticating with Kerberos, or
+	// AuthNetrc for those logging in with the netrc file's credentials,
+	// instead of a token; other values fail the source.
+	Auth string
+	// Format is the format the source is published in, one of
+	// fetcher.Formats; empty detects it.
+	Format string
+	// Schema is how the source is handled when it strays from the banner
+	// index schema: "reject" (the default, for ""), "quarantine", or
+	// "off"; other values are ignored.
+	Schema string
+	// Shrink is what happens when a fetch returns far fewer entries than
+	// the source's history: ShrinkWarn (the default, for "") merges it
+	// with a warning, ShrinkQuarantine merges the source's previous data
+	// instead, and ShrinkOff skips the check.
+	Shrink string
+	// Trust is the source's trust level, one of TrustLevels; empty is
+	// TrustCommunity.
+	Trust string
+}
+
+// Values of SourceOptions.Shrink.
+const (
+	ShrinkWarn       = "warn"
+	ShrinkQuarantine = "quarantine"
+	ShrinkOff        = "off"
+)
+
+// SourceOptions returns the options configured for source.
+func (c *Config) SourceOptions(source string) SourceOptions {
+	return c.Options[source]
+}
+
+// Selects reports whether selector picks source: "tag=NAME" matches the
+// sources tagged NAME, and anything else the source itself.
+func (c *Config) Selects(selector, source string) bool {
+	if tag, ok := strings.CutPrefix(selector, "tag="); ok {
+		return slices.Contains(c.SourceOptions(source).Tags, tag)
+	}
+	return selector == source
+}
+
+// Overrides relocates basar's files, for running isolated instances such
+// as CI jobs or per-case caches. Empty fields fall back to the
+// BASAR_PROFILE, BASAR_CONFIG, and BASAR_CACHE_DIR environment variables,
+// then to the XDG defaults.
+type Overrides struct {
+	// Profile namespaces the config, cache, and state directories under
+	// a subdirectory of each, e.g. ~/.cache/basar/work. ConfigFile and
+	// CacheDir still take precedence.
+	Profile string
+	// ConfigFile replaces sources.conf; hooks.d is looked up next to it.
 	ConfigFile string
-	LockFile   string
-	TTL        time.Duration
-	Sources    []string
+	// CacheDir holds the cache, with the state (metadata, lock,
+	// snapshots, history) in its state subdirectory so instances never
+	// share a lock.
+	CacheDir string
+	// Offline forbids network access, as BASAR_OFFLINE=1 does.
+	Offline bool
+	// FallbackCacheDir receives updates when the cache directory is
+	// read-only, as BASAR_FALLBACK_CACHE_DIR does; "tmpfs" picks a
+	// per-user directory in memory.
+	FallbackCacheDir string
+	// Timeout bounds the whole invocation when positive. It is not
+	// forwarded to background refreshes, which outlive the caller.
+	Timeout time.Duration
+	// DryRun leaves files from older layouts where they are, listing
+	// them in PendingMigrations rather than moving or removing them.
+	DryRun bool
+}
+
+// Args returns the flags reproducing o in another basar process.
+func (o Overrides) Args() []string {
+	var args []string
+	if o.Profile != "" {
+		args = append(args, "--profile", o.Profile)
+	}
+	if o.ConfigFile != "" {
+		args = append(args, "--config", o.ConfigFile)
+	}
+	if o.CacheDir != "" {
+		args = append(args, "--cache-dir", o.CacheDir)
+	}
+	if o.Offline {
+		args = append(args, "--offline")
+	}
+	if o.FallbackCacheDir != "" {
+		args = append(args, "--fallback-cache-dir", o.FallbackCacheDir)
+	}
+	return args
+}
+
+// reservedProfiles are names basar already uses inside its directories.
+var reservedProfiles = []string{"hooks.d", "mirror", "snapshots", "state"}
+
+// CheckProfile reports whether name can be used as a profile: letters,
+// digits, '.', '_', and '-', not starting with '.', and not a name basar
+// uses for its own files.
+func CheckProfile(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || slices.Contains(reservedProfiles, name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("invalid profile name %q: only letters, digits, '.', '_', and '-' are allowed", name)
+		}
+	}
+	return nil
 }
 
 // New creates a Config with XDG-compliant paths.
 func New() *Config {
-	cacheDir := xdgPath("XDG_CACHE_HOME", ".cache")
-	configDir := xdgPath("XDG_CONFIG_HOME", ".config")
+	return NewWith(Overrides{})
+}
+
+// NewWith creates a Config like New with the given overrides applied.
+func NewWith(o Overrides) *Config {
+	var warnings []string
+	if o.Profile == "" {
+		o.Profile = os.Getenv("BASAR_PROFILE")
+		if err := CheckProfile(o.Profile); o.Profile != "" && err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignoring BASAR_PROFILE: %v", err))
+			o.Profile = ""
+		}
+	}
+	if o.ConfigFile == "" {
+		o.ConfigFile = os.Getenv("BASAR_CONFIG")
+	}
+	if o.CacheDir == "" {
+		o.CacheDir = os.Getenv("BASAR_CACHE_DIR")
+	}
+	if o.FallbackCacheDir == "" {
+		o.FallbackCacheDir = os.Getenv("BASAR_FALLBACK_CACHE_DIR")
+	}
 
 	cfg := &Config{
-		CacheDir:  filepath.Join(cacheDir, AppName),
-		ConfigDir: filepath.Join(configDir, AppName),
+		CacheDir:  appDir("XDG_CACHE_HOME", ".cache", "LOCALAPPDATA", "cache"),
+		ConfigDir: appDir("XDG_CONFIG_HOME", ".config", "APPDATA", ""),
+		StateDir:  appDir("XDG_STATE_HOME", filepath.Join(".local", "state"), "LOCALAPPDATA", "state"),
 		TTL:       parseTTL(os.Getenv("BASAR_TTL"), DefaultTTL),
+
+		ShrinkThreshold:       parsePercent(os.Getenv("BASAR_SHRINK_THRESHOLD"), DefaultShrinkThreshold),
+		SourceShrinkThreshold: parsePercent(os.Getenv("BASAR_SOURCE_SHRINK_THRESHOLD"), DefaultSourceShrinkThreshold),
+		Jobs:                  parseJobs(os.Getenv("BASAR_JOBS"), DefaultJobs),
+		MinSources:            parseJobs(os.Getenv("BASAR_MIN_SOURCES"), 0),
+		Strict:                os.Getenv("BASAR_STRICT") == "1",
+		FailFast:              os.Getenv("BASAR_FAIL_FAST") == "1",
+		LowPriority:           os.Getenv("BASAR_LOW_PRIORITY") == "1",
+		DemoteDeadURLs:        os.Getenv("BASAR_DEMOTE_DEAD") == "1",
+		DiskIndex:             os.Getenv("BASAR_DISK_INDEX") == "1",
+		Splay:                 parseSplay(os.Getenv("BASAR_SPLAY"), 0),
+		Scheduler:             os.Getenv("BASAR_SCHEDULER"),
+
+		StaleWhileRevalidate: os.Getenv("BASAR_STALE_WHILE_REVALIDATE") == "1",
+		TrackUsage:           os.Getenv("BASAR_TRACK_USAGE") == "1",
+		AccessLog:            os.Getenv("BASAR_ACCESS_LOG"),
+		Offline:              o.Offline || os.Getenv("BASAR_OFFLINE") == "1",
+		FallbackCacheDir:     fallbackCacheDir(o.FallbackCacheDir, o.Profile),
+		Vol3Compat:           os.Getenv("BASAR_VOL3_COMPAT"),
+		TombstoneTTL:         parseDuration(os.Getenv("BASAR_TOMBSTONE_TTL"), 0),
+
+		SystemConfigDir: systemConfigDir(),
+		SystemCacheDir:  systemCacheDir(),
+		SystemCache:     SystemCacheAuto,
+
+		Profile:   o.Profile,
+		Warnings:  warnings,
+		Overrides: o,
+	}
+
+	if dir := os.Getenv("BASAR_SYSTEM_CACHE_DIR"); dir != "" {
+		cfg.SystemCacheDir = dir
+	}
+	switch mode := os.Getenv("BASAR_SYSTEM_CACHE"); mode {
+	case "":
+	case SystemCacheAuto, SystemCachePrefer, SystemCacheNever:
+		cfg.SystemCache = mode
+	default:
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+			"ignoring BASAR_SYSTEM_CACHE=%q: expected auto, prefer, or never", mode))
+	}
+	for _, name := range []string{"BASAR_SHRINK_THRESHOLD", "BASAR_SOURCE_SHRINK_THRESHOLD"} {
+		if s := os.Getenv(name); s != "" {
+			if _, ok := percent(s); !ok {
+				cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+					"ignoring %s=%q: expected a whole percentage from 0 to 100", name, s))
+			}
+		}
+	}
+	if o.Profile != "" || o.ConfigFile != "" || o.CacheDir != "" {
+		// The system cache stands in for the default installation only
+		cfg.SystemCacheDir = ""
+	}
+
+	if o.Profile != "" {
+		cfg.CacheDir = filepath.Join(cfg.CacheDir, o.Profile)
+		cfg.ConfigDir = filepath.Join(cfg.ConfigDir, o.Profile)
+		cfg.StateDir = filepath.Join(cfg.StateDir, o.Profile)
+	}
+	if o.CacheDir != "" {
+		cfg.CacheDir = o.CacheDir
+		cfg.StateDir = filepath.Join(o.CacheDir, "state")
+	}
+	if o.ConfigFile != "" {
+		// An explicit config file is the whole configuration
+		cfg.ConfigDir = filepath.Dir(o.ConfigFile)
+		cfg.SystemConfigDir = ""
 	}
 
-	cfg.CacheFile = filepath.Join(cfg.CacheDir, "banners.json")
+	cfg.setDataFiles()
 	cfg.ConfigFile = filepath.Join(cfg.ConfigDir, "sources.conf")
-	cfg.LockFile = filepath.Join(cfg.CacheDir, ".lock")
-	cfg.Sources = cfg.loadSources()
+	if o.ConfigFile != "" {
+		cfg.ConfigFile = o.ConfigFile
+	}
+	cfg.HooksDir = filepath.Join(cfg.ConfigDir, "hooks.d")
+	cfg.OverridesFile = filepath.Join(cfg.ConfigDir, "overrides.json")
+	cfg.OverlayFile = filepath.Join(cfg.ConfigDir, "overlay.json")
+
+	// Relocate files from older layouts before reading any of them; an
+	// isolated instance must not take over the default installation's,
+	// and a dry run only lists them
+	switch {
+	case o.Profile != "" || o.ConfigFile != "" || o.CacheDir != "":
+	case o.DryRun:
+		cfg.PendingMigrations = cfg.pendingMigrations()
+	default:
+		migrated, err := cfg.migrate()
+		cfg.Migrations = migrated
+		if err != nil {
+			cfg.Warnings = append(cfg.Warnings, err.Error())
+		}
+	}
+
+	cfg.Sources, cfg.Options, cfg.SourceFiles = cfg.loadSources()
+
+	// Files next to sources.conf, whose bad lines are skipped with a warning
+	var skipped []string
+	cfg.URLFilterFile = filepath.Join(cfg.ConfigDir, "url-filters.conf")
+	cfg.URLFilter, cfg.URLRedirects, skipped = loadURLFilter(cfg.URLFilterFile)
+	cfg.Warnings = append(cfg.Warnings, skipped...)
+	cfg.BannerFilterFile = filepath.Join(cfg.ConfigDir, "banner-filters.conf")
+	cfg.BannerFilter, skipped = loadBannerFilter(cfg.BannerFilterFile)
+	cfg.Warnings = append(cfg.Warnings, skipped...)
+	cfg.NotifyFile = filepath.Join(cfg.ConfigDir, "notify.conf")
+	cfg.Notify, skipped = loadNotifyTargets(cfg.NotifyFile)
+	cfg.Warnings = append(cfg.Warnings, skipped...)
+	cfg.ProxyFile = filepath.Join(cfg.ConfigDir, "proxy.conf")
+	cfg.ProxyPAC, cfg.ProxyAuth, cfg.ProxyKeyring, skipped = loadProxyConf(cfg.ProxyFile)
+	cfg.Warnings = append(cfg.Warnings, skipped...)
+	if pac := os.Getenv("BASAR_PROXY_PAC"); pac != "" {
+		cfg.ProxyPAC = pac
+	}
+	cfg.NegotiateCmd = os.Getenv("BASAR_NEGOTIATE_CMD")
+	cfg.GitHubAPI = os.Getenv("BASAR_GITHUB_API")
+
+	if home, ok := homeDir(); !ok && runtime.GOOS != "windows" && (os.Getenv("XDG_CACHE_HOME") == "" ||
+		os.Getenv("XDG_CONFIG_HOME") == "" || os.Getenv("XDG_STATE_HOME") == "") {
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+			"home directory unknown (is HOME set?); using %s, which may not persist", home))
+	}
 
 	return cfg
 }
 
+// setDataFiles derives the cache and state files from CacheDir and
+// StateDir.
+func (c *Config) setDataFiles() {
+	c.CacheFile = filepath.Join(c.CacheDir, "banners.json")
+	c.LayeredFile = filepath.Join(c.CacheDir, "layered.json")
+	c.LockFile = filepath.Join(c.StateDir, ".lock")
+	c.LogFile = filepath.Join(c.StateDir, "basar.log")
+	c.MetaFile = filepath.Join(c.StateDir, "meta.json")
+	c.LivenessFile = filepath.Join(c.StateDir, "liveness.json")
+	c.HistoryFile = filepath.Join(c.StateDir, "history.jsonl")
+	c.UsageFile = filepath.Join(c.StateDir, "usage.json")
+	c.PruneReportFile = filepath.Join(c.StateDir, "prune-report.json")
+	c.SnapshotDir = filepath.Join(c.StateDir, "snapshots")
+	c.QuarantineDir = filepath.Join(c.StateDir, "quarantine")
+}
+
+// Relocated returns a copy of c keeping its cache in dir and its state in
+// dir/state, as --cache-dir does.
+func (c *Config) Relocated(dir string) *Config {
+	relocated := *c
+	relocated.CacheDir = dir
+	relocated.StateDir = filepath.Join(dir, "state")
+	relocated.setDataFiles()
+	return &relocated
+}
+
+// fallbackCacheDir resolves BASAR_FALLBACK_CACHE_DIR: "tmpfs" names a
+// per-user directory under $XDG_RUNTIME_DIR, or the system temp dir when
+// that is unset, separate for each profile.
+func fallbackCacheDir(s, profile string) string {
+	if s != "tmpfs" {
+		return s
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", AppName, os.Getuid()))
+	if base := os.Getenv("XDG_RUNTIME_DIR"); base != "" {
+		dir = filepath.Join(base, AppName)
+	}
+	return filepath.Join(dir, profile)
+}
+
+// systemConfigDir returns the directory of the system-wide configuration:
+// /etc/basar, or %ProgramData%\basar on Windows.
+func systemConfigDir() string {
+	if runtime.GOOS == "windows" {
+		if base := os.Getenv("ProgramData"); base != "" {
+			return filepath.Join(base, AppName)
+		}
+		return ""
+	}
+	return filepath.Join("/etc", AppName)
+}
+
+// systemCacheDir returns the directory of the system-wide cache:
+// /var/cache/basar, or %ProgramData%\basar\cache on Windows.
+func systemCacheDir() string {
+	if runtime.GOOS == "windows" {
+		if base := os.Getenv("ProgramData"); base != "" {
+			return filepath.Join(base, AppName, "cache")
+		}
+		return ""
+	}
+	return filepath.Join("/var/cache", AppName)
+}
+
+// appDir returns basar's directory under the XDG base directory named by
+// envVar. When it is unset, Windows uses winSub under the directory named by
+// winEnv (%LOCALAPPDATA% or %APPDATA%) and other platforms use
+// home/fallback.
+func appDir(envVar, fallback, winEnv, winSub string) string {
+	if runtime.GOOS == "windows" && os.Getenv(envVar) == "" {
+		if base := os.Getenv(winEnv); base != "" {
+			return filepath.Join(base, AppName, winSub)
+		}
+	}
+	return filepath.Join(xdgPath(envVar, fallback), AppName)
+}
+
 // xdgPath returns the XDG base directory or falls back to home + fallback.
 func xdgPath(envVar, fallback string) string {
 	if dir := os.Getenv(envVar); dir != "" {
 		return dir
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "/"
-	}
-
+	home, _ := homeDir()
 	return filepath.Join(home, fallback)
 }
 
+// homeDir returns the user's home directory. When it cannot be determined,
+// e.g. HOME unset under systemd DynamicUser, it returns a per-user directory
+// under the system temp dir and false.
+func homeDir() (string, bool) {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return home, true
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", AppName, os.Getuid())), false
+}
+
 // parseTTL parses a TTL string as seconds, returning defaultVal on failure.
 func parseTTL(s string, defaultVal time.Duration) time.Duration {
 	if s == "" {
@@ -83,63 +642,292 @@ func parseTTL(s string, defaultVal time.Duration) time.Duration {
 	return defaultVal
 }
 
-// loadSources reads sources from config file or returns defaults.
-func (c *Config) loadSources() []string {
-	f, err := os.Open(c.ConfigFile)
+// parseSplay parses a splay duration of at most MaxSplay, returning
+// defaultVal on failure.
+func parseSplay(s string, defaultVal time.Duration) time.Duration {
+	if s == "" {
+		return defaultVal
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 && d <= MaxSplay {
+		return d
+	}
+
+	return defaultVal
+}
+
+// parseDuration parses a non-negative Go duration such as "168h",
+// returning defaultVal on failure.
+func parseDuration(s string, defaultVal time.Duration) time.Duration {
+	if s == "" {
+		return defaultVal
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d
+	}
+
+	return defaultVal
+}
+
+// parsePercent parses an integer percentage (0-100) as a fraction,
+// returning defaultVal on failure.
+func parsePercent(s string, defaultVal float64) float64 {
+	if pct, ok := percent(s); ok {
+		return pct
+	}
+	return defaultVal
+}
+
+// percent parses a whole percentage (0-100) as a fraction, rejecting
+// anything else, such as "5.5" or "50abc".
+func percent(s string) (float64, bool) {
+	pct, err := strconv.Atoi(s)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, false
+	}
+	return float64(pct) / 100, true
+}
+
+// parseJobs parses a positive count such as a concurrency limit, returning
+// defaultVal on failure.
+func parseJobs(s string, defaultVal int) int {
+	if s == "" {
+		return defaultVal
+	}
+
+	var jobs int
+	if _, err := fmt.Sscanf(s, "%d", &jobs); err == nil && jobs > 0 {
+		return jobs
+	}
+
+	return defaultVal
+}
+
+// loadSources reads sources and their options from the config layers, lowest
+// first: the system sources.conf and sources.conf.d/*.conf, then the user's.
+// Sources from the sources.conf files come before those from drop-ins; one
+// listed in several files keeps its first position and takes its options
+// from the highest layer. When neither sources.conf lists a source, the
+// defaults stand in for them, so drop-ins add to the defaults. Sources
+// disabled by their final options are left out. It also returns the files
+// read.
+func (c *Config) loadSources() ([]string, map[string]SourceOptions, []string) {
+	type layer struct{ main, dropins string }
+	var layers []layer
+	if c.SystemConfigDir != "" {
+		layers = append(layers, layer{filepath.Join(c.SystemConfigDir, "sources.conf"), filepath.Join(c.SystemConfigDir, "sources.conf.d")})
+	}
+	layers = append(layers, layer{c.ConfigFile, filepath.Join(filepath.Dir(c.ConfigFile), "sources.conf.d")})
+
+	options := make(map[string]SourceOptions)
+	var sources, dropins, files []string
+	add := func(file string, into *[]string) {
+		lines, err := readSourceLines(file)
+		if err != nil {
+			return
+		}
+		files = append(files, file)
+		for _, line := range lines {
+			source, opts := parseSourceLine(line)
+			*into = append(*into, source)
+			options[source] = opts
+		}
+	}
+
+	// The defaults only stand in for main files, whatever the drop-ins add
+	var mains []string
+	for _, l := range layers {
+		add(l.main, &mains)
+		matches, _ := filepath.Glob(filepath.Join(l.dropins, "*.conf"))
+		for _, m := range matches {
+			add(m, &dropins)
+		}
+	}
+	if len(mains) == 0 {
+		mains = DefaultSources
+	}
+
+	seen := make(map[string]bool)
+	for _, source := range append(mains[:len(mains):len(mains)], dropins...) {
+		if !seen[source] && !options[source].Disabled {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	return sources, options, files
+}
+
+// readSourceLines returns the non-blank, non-comment lines of a sources
+// file.
+func readSourceLines(path string) ([]string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return DefaultSources
+		return nil, err
 	}
 	defer f.Close()
 
-	var sources []string
+	var lines []string
 	scanner := bufio.NewScanner(f)
-
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		sources = append(sources, line)
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseSourceLine splits a sources.conf line into the source and its
+// options. Options are `key=value` (or `key = value`) pairs; values may be
+// double-quoted to include spaces. Fields before the first option form the
+// source, so unquoted local paths containing spaces keep working. Unknown
+// keys are ignored.
+func parseSourceLine(line string) (string, SourceOptions) {
+	fields := splitFields(line)
+	var opts SourceOptions
+
+	start := len(fields)
+	for i := 1; i < len(fields); i++ {
+		if strings.Contains(fields[i], "=") || (i+1 < len(fields) && fields[i+1] == "=") {
+			start = i
+			break
+		}
 	}
+	source := strings.Join(fields[:start], " ")
+
+	for i := start; i < len(fields); i++ {
+		key, value, ok := strings.Cut(fields[i], "=")
+		switch {
+		case ok && value == "" && i+1 < len(fields):
+			// key= value
+			i++
+			value = fields[i]
+		case !ok && i+2 < len(fields) && fields[i+1] == "=":
+			// key = value
+			value = fields[i+2]
+			i += 2
+		}
 
-	if len(sources) == 0 {
-		return DefaultSources
+		switch key {
+		case "token_env":
+			opts.TokenEnv = value
+		case "token_file":
+			opts.TokenFile = value
+		case "token_cmd":
+			opts.TokenCmd = value
+		case "token_keyring":
+			opts.TokenKeyring = value
+		case "auth":
+			opts.Auth = strings.ToLower(value)
+		case "format":
+			switch format := strings.ToLower(value); format {
+			case "auto", "isf", "distro", "records", "csv", "text":
+				opts.Format = format
+			}
+		case "schema":
+			switch mode := strings.ToLower(value); mode {
+			case "reject", "quarantine", "off":
+				opts.Schema = mode
+			}
+		case "shrink":
+			switch mode := strings.ToLower(value); mode {
+			case ShrinkWarn, ShrinkQuarantine, ShrinkOff:
+				opts.Shrink = mode
+			}
+		case "trust":
+			if level, err := ParseTrust(value); err == nil {
+				opts.Trust = level
+			}
+		case "timeout":
+			if d, err := time.ParseDuration(value); err == nil && d > 0 {
+				opts.Timeout = d
+			}
+		case "required":
+			opts.Required, _ = strconv.ParseBool(value)
+		case "cursor_field":
+			opts.CursorField = value
+		case "cursor_param":
+			opts.CursorParam = value
+		case "enabled":
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				opts.Disabled = !enabled
+			}
+		case "priority":
+			if n, err := strconv.Atoi(value); err == nil {
+				opts.Priority = n
+			}
+		case "tag", "tags":
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(opts.Tags, tag) {
+					opts.Tags = append(opts.Tags, tag)
+				}
+			}
+		}
 	}
 
-	return sources
+	return source, opts
 }
 
-// InitConfig creates the default configuration file.
-// Returns error if file already exists.
-func (c *Config) InitConfig() error {
-	if _, err := os.Stat(c.ConfigFile); err == nil {
-		return fmt.Errorf("config already exists: %s", c.ConfigFile)
-	}
+// splitFields splits s on whitespace, keeping double-quoted runs together
+// and stripping the quotes. A backslash escapes the next character inside
+// quotes.
+func splitFields(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuotes, inField := false, false
 
-	if err := os.MkdirAll(c.ConfigDir, 0755); err != nil {
-		return fmt.Errorf("creating config dir: %w", err)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case inQuotes && ch == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case ch == '"':
+			inQuotes = !inQuotes
+			inField = true
+		case !inQuotes && (ch == ' ' || ch == '\t'):
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteByte(ch)
+			inField = true
+		}
 	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+
+	return fields
+}
 
-	f, err := os.Create(c.ConfigFile)
+// ErrConfigExists indicates InitConfig found an existing config file.
+var ErrConfigExists = errors.New("config already exists")
+
+// InitConfig creates the configuration file from a preset (one of
+// Presets; empty means PresetFull).
+// Returns an error matching ErrConfigExists if the file already exists.
+func (c *Config) InitConfig(preset string) error {
+	content, err := presetContent(preset)
 	if err != nil {
-		return fmt.Errorf("creating config file: %w", err)
+		return err
 	}
-	defer f.Close()
 
-	if _, err := f.WriteString("# basar sources configuration\n"); err != nil {
-		return fmt.Errorf("writing config: %w", err)
-	}
-	if _, err := f.WriteString("# One URL or local path per line\n"); err != nil {
-		return fmt.Errorf("writing config: %w", err)
+	if _, err := os.Stat(c.ConfigFile); err == nil {
+		return fmt.Errorf("%w: %s", ErrConfigExists, c.ConfigFile)
 	}
-	if _, err := f.WriteString("# Lines starting with # are comments\n\n"); err != nil {
-		return fmt.Errorf("writing config: %w", err)
+
+	if err := os.MkdirAll(c.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("creating config dir: %w", err)
 	}
 
-	for _, src := range DefaultSources {
-		if _, err := f.WriteString(src + "\n"); err != nil {
-			return fmt.Errorf("writing config: %w", err)
-		}
+	if err := os.WriteFile(c.ConfigFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
 	}
 
 	return nil
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -32,6 +37,98 @@ func TestParseTTL(t *testing.T) {
 	}
 }
 
+func TestParseSplay(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"30m", 30 * time.Minute},
+		{"4h", 4 * time.Hour},
+		{"0s", 0},
+		{"24h", 24 * time.Hour},
+		{"25h", 0},
+		{"-1m", 0},
+		{"600", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseSplay(tt.input, 0); got != tt.expected {
+			t.Errorf("parseSplay(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"", time.Hour},
+		{"168h", 168 * time.Hour},
+		{"0s", 0},
+		{"-1h", time.Hour},
+		{"7d", time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := parseDuration(tt.input, time.Hour); got != tt.expected {
+			t.Errorf("parseDuration(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected float64
+	}{
+		{"empty string", "", 0.5},
+		{"valid percent", "25", 0.25},
+		{"zero disables", "0", 0},
+		{"hundred", "100", 1},
+		{"over hundred", "150", 0.5},
+		{"negative", "-10", 0.5},
+		{"invalid", "abc", 0.5},
+		{"trailing garbage", "50abc", 0.5},
+		{"fraction", "5.5", 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parsePercent(tt.input, 0.5)
+			if result != tt.expected {
+				t.Errorf("parsePercent(%q) = %v, expected %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	t.Setenv("BASAR_SHRINK_THRESHOLD", "50abc")
+	if cfg := New(); cfg.ShrinkThreshold != DefaultShrinkThreshold || len(cfg.Warnings) == 0 {
+		t.Errorf("invalid BASAR_SHRINK_THRESHOLD should be ignored with a warning, got %v, %v", cfg.ShrinkThreshold, cfg.Warnings)
+	}
+}
+
+func TestParseJobs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 8},
+		{"2", 2},
+		{"0", 8},
+		{"-1", 8},
+		{"many", 8},
+	}
+
+	for _, tt := range tests {
+		if got := parseJobs(tt.input, 8); got != tt.expected {
+			t.Errorf("parseJobs(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
 func TestXDGPath(t *testing.T) {
 	// Save original environment
 	originalCacheHome := os.Getenv("XDG_CACHE_HOME")
@@ -97,7 +194,39 @@ func TestXDGPath(t *testing.T) {
 	}
 }
 
+func TestNewWithoutHome(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("home directory is not taken from HOME on this platform")
+	}
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	cfg := New()
+
+	if !strings.HasPrefix(cfg.CacheDir, os.TempDir()) {
+		t.Errorf("CacheDir should fall back to the temp dir, got %q", cfg.CacheDir)
+	}
+	if len(cfg.Warnings) != 1 {
+		t.Errorf("expected one warning, got %v", cfg.Warnings)
+	}
+
+	// No warning when every directory is set explicitly
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if cfg := New(); len(cfg.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", cfg.Warnings)
+	}
+}
+
 func TestNew(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "state"))
+
 	cfg := New()
 
 	if cfg == nil {
@@ -137,8 +266,265 @@ func TestNew(t *testing.T) {
 		t.Errorf("ConfigFile should be in ConfigDir, got %q", cfg.ConfigFile)
 	}
 
-	if cfg.LockFile != filepath.Join(cfg.CacheDir, ".lock") {
-		t.Errorf("LockFile should be in CacheDir, got %q", cfg.LockFile)
+	if cfg.LockFile != filepath.Join(cfg.StateDir, ".lock") {
+		t.Errorf("LockFile should be in StateDir, got %q", cfg.LockFile)
+	}
+
+	if cfg.MetaFile != filepath.Join(cfg.StateDir, "meta.json") {
+		t.Errorf("MetaFile should be in StateDir, got %q", cfg.MetaFile)
+	}
+	if cfg.HistoryFile != filepath.Join(cfg.StateDir, "history.jsonl") {
+		t.Errorf("HistoryFile should be in StateDir, got %q", cfg.HistoryFile)
+	}
+
+	if cfg.SnapshotDir != filepath.Join(cfg.StateDir, "snapshots") {
+		t.Errorf("SnapshotDir should be in StateDir, got %q", cfg.SnapshotDir)
+	}
+
+	if cfg.LogFile != filepath.Join(cfg.StateDir, "basar.log") {
+		t.Errorf("LogFile should be in StateDir, got %q", cfg.LogFile)
+	}
+}
+
+func TestNewWith(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "xdg-cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "xdg-config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "xdg-state"))
+
+	// A legacy file in the default layout must stay put for isolated instances
+	legacy := filepath.Join(tmpDir, "xdg-cache", AppName, "meta.json")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	confFile := filepath.Join(tmpDir, "case", "sources.conf")
+	if err := os.MkdirAll(filepath.Dir(confFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(confFile, []byte("https://example.com/banners.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := filepath.Join(tmpDir, "case", "cache")
+
+	check := func(t *testing.T, cfg *Config) {
+		t.Helper()
+		if cfg.ConfigFile != confFile || cfg.HooksDir != filepath.Join(tmpDir, "case", "hooks.d") {
+			t.Errorf("config paths not overridden: %s, %s", cfg.ConfigFile, cfg.HooksDir)
+		}
+		if cfg.CacheFile != filepath.Join(cacheDir, "banners.json") || cfg.LockFile != filepath.Join(cacheDir, "state", ".lock") {
+			t.Errorf("cache paths not overridden: %s, %s", cfg.CacheFile, cfg.LockFile)
+		}
+		if len(cfg.Sources) != 1 || cfg.Sources[0] != "https://example.com/banners.json" {
+			t.Errorf("sources should come from the overridden config, got %v", cfg.Sources)
+		}
+		if cfg.SystemConfigDir != "" {
+			t.Errorf("an explicit config file should skip the system layer, got %q", cfg.SystemConfigDir)
+		}
+		if cfg.SystemCacheDir != "" {
+			t.Errorf("isolated instances must not use the system cache, got %q", cfg.SystemCacheDir)
+		}
+		if _, err := os.Stat(legacy); err != nil || len(cfg.Migrations) != 0 {
+			t.Errorf("isolated instances must not migrate the default layout: %v, %v", err, cfg.Migrations)
+		}
+	}
+
+	t.Run("overrides", func(t *testing.T) {
+		check(t, NewWith(Overrides{ConfigFile: confFile, CacheDir: cacheDir}))
+	})
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("BASAR_CONFIG", confFile)
+		t.Setenv("BASAR_CACHE_DIR", cacheDir)
+		check(t, New())
+	})
+}
+
+func TestNewWithOffline(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("BASAR_OFFLINE", "")
+	if New().Offline {
+		t.Error("Offline should default to false")
+	}
+	if !NewWith(Overrides{Offline: true}).Offline {
+		t.Error("Overrides.Offline should set Offline")
+	}
+	t.Setenv("BASAR_OFFLINE", "1")
+	if !New().Offline {
+		t.Error("BASAR_OFFLINE=1 should set Offline")
+	}
+}
+
+func TestFallbackCacheDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	tests := []struct {
+		value, profile, want string
+	}{
+		{"", "", ""},
+		{"/var/tmp/basar", "work", "/var/tmp/basar"},
+		{"tmpfs", "", filepath.Join("/run/user/1000", AppName)},
+		{"tmpfs", "work", filepath.Join("/run/user/1000", AppName, "work")},
+	}
+	for _, tt := range tests {
+		if got := fallbackCacheDir(tt.value, tt.profile); got != tt.want {
+			t.Errorf("fallbackCacheDir(%q, %q) = %q, expected %q", tt.value, tt.profile, got, tt.want)
+		}
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if got := fallbackCacheDir("tmpfs", ""); !strings.HasPrefix(got, os.TempDir()) {
+		t.Errorf("tmpfs without XDG_RUNTIME_DIR = %q, expected it under %s", got, os.TempDir())
+	}
+}
+
+func TestNewWithSystemCache(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("BASAR_SYSTEM_CACHE", "")
+	t.Setenv("BASAR_SYSTEM_CACHE_DIR", "/srv/basar")
+	cfg := New()
+	if cfg.SystemCacheDir != "/srv/basar" || cfg.SystemCache != SystemCacheAuto {
+		t.Errorf("system cache = %q, %q; expected /srv/basar in auto mode", cfg.SystemCacheDir, cfg.SystemCache)
+	}
+	if cfg := NewWith(Overrides{Profile: "work"}); cfg.SystemCacheDir != "" {
+		t.Errorf("a profile should not use the system cache, got %q", cfg.SystemCacheDir)
+	}
+
+	t.Setenv("BASAR_SYSTEM_CACHE", "prefer")
+	if cfg := New(); cfg.SystemCache != SystemCachePrefer {
+		t.Errorf("SystemCache = %q, expected prefer", cfg.SystemCache)
+	}
+	t.Setenv("BASAR_SYSTEM_CACHE", "always")
+	if cfg := New(); cfg.SystemCache != SystemCacheAuto || len(cfg.Warnings) == 0 {
+		t.Errorf("invalid BASAR_SYSTEM_CACHE should be ignored with a warning, got %q, %v", cfg.SystemCache, cfg.Warnings)
+	}
+}
+
+func TestRelocated(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := New()
+	dir := t.TempDir()
+
+	relocated := cfg.Relocated(dir)
+	if relocated.CacheFile != filepath.Join(dir, "banners.json") {
+		t.Errorf("CacheFile = %q", relocated.CacheFile)
+	}
+	if relocated.MetaFile != filepath.Join(dir, "state", "meta.json") {
+		t.Errorf("MetaFile = %q", relocated.MetaFile)
+	}
+	if relocated.ConfigFile != cfg.ConfigFile {
+		t.Errorf("ConfigFile = %q, expected it unchanged", relocated.ConfigFile)
+	}
+	if cfg.CacheDir == dir {
+		t.Error("Relocated should not modify the original")
+	}
+}
+
+func TestNewWithProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "state"))
+
+	check := func(t *testing.T, cfg *Config) {
+		t.Helper()
+		if cfg.Profile != "work" || cfg.Overrides.Profile != "work" {
+			t.Errorf("Profile = %q, overridden %q; expected work", cfg.Profile, cfg.Overrides.Profile)
+		}
+		for got, want := range map[string]string{
+			cfg.CacheFile:  filepath.Join(tmpDir, "cache", AppName, "work", "banners.json"),
+			cfg.ConfigFile: filepath.Join(tmpDir, "config", AppName, "work", "sources.conf"),
+			cfg.MetaFile:   filepath.Join(tmpDir, "state", AppName, "work", "meta.json"),
+			cfg.LockFile:   filepath.Join(tmpDir, "state", AppName, "work", ".lock"),
+		} {
+			if got != want {
+				t.Errorf("path = %q, expected %q", got, want)
+			}
+		}
+	}
+
+	t.Run("overrides", func(t *testing.T) {
+		check(t, NewWith(Overrides{Profile: "work"}))
+	})
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("BASAR_PROFILE", "work")
+		check(t, New())
+	})
+	t.Run("invalid environment", func(t *testing.T) {
+		t.Setenv("BASAR_PROFILE", "../escape")
+		cfg := New()
+		if cfg.Profile != "" || len(cfg.Warnings) == 0 {
+			t.Errorf("invalid BASAR_PROFILE should be ignored with a warning, got %q, %v", cfg.Profile, cfg.Warnings)
+		}
+	})
+}
+
+func TestOverridesArgs(t *testing.T) {
+	o := Overrides{Profile: "work", ConfigFile: "/tmp/s.conf", CacheDir: "/tmp/c", Offline: true, FallbackCacheDir: "tmpfs"}
+	want := []string{"--profile", "work", "--config", "/tmp/s.conf", "--cache-dir", "/tmp/c", "--offline", "--fallback-cache-dir", "tmpfs"}
+	if got := o.Args(); !slices.Equal(got, want) {
+		t.Errorf("Args() = %v, expected %v", got, want)
+	}
+	if got := (Overrides{}).Args(); len(got) != 0 {
+		t.Errorf("Args() without overrides = %v", got)
+	}
+}
+
+func TestCheckProfile(t *testing.T) {
+	for _, name := range []string{"work", "case-42", "acme_2024.q1"} {
+		if err := CheckProfile(name); err != nil {
+			t.Errorf("CheckProfile(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", ".hidden", "a/b", `a\b`, "with space", "mirror", "state"} {
+		if err := CheckProfile(name); err == nil {
+			t.Errorf("CheckProfile(%q) should fail", name)
+		}
+	}
+}
+
+func TestAppDirWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("windows-only fallback")
+	}
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("LOCALAPPDATA", `C:\Users\u\AppData\Local`)
+
+	want := `C:\Users\u\AppData\Local\basar\cache`
+	if got := appDir("XDG_CACHE_HOME", ".cache", "LOCALAPPDATA", "cache"); got != want {
+		t.Errorf("appDir() = %q, expected %q", got, want)
+	}
+}
+
+func TestNewMigratesState(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "state"))
+
+	oldDir := filepath.Join(tmpDir, "cache", AppName)
+	if err := os.MkdirAll(filepath.Join(oldDir, "snapshots"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "meta.json"), []byte(`{"sources":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New()
+
+	if len(cfg.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", cfg.Warnings)
+	}
+	for _, path := range []string{cfg.MetaFile, cfg.SnapshotDir} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should have been migrated: %v", path, err)
+		}
+	}
+	for _, name := range []string{"meta.json", "snapshots"} {
+		if _, err := os.Stat(filepath.Join(oldDir, name)); !os.IsNotExist(err) {
+			t.Errorf("old %s should be gone", name)
+		}
 	}
 }
 
@@ -151,7 +537,7 @@ func TestInitConfig(t *testing.T) {
 	}
 
 	// First call should succeed
-	err := cfg.InitConfig()
+	err := cfg.InitConfig("")
 	if err != nil {
 		t.Fatalf("InitConfig() failed: %v", err)
 	}
@@ -162,8 +548,276 @@ func TestInitConfig(t *testing.T) {
 	}
 
 	// Second call should fail (file already exists)
-	err = cfg.InitConfig()
-	if err == nil {
-		t.Error("InitConfig() should fail when file already exists")
+	err = cfg.InitConfig("")
+	if !errors.Is(err, ErrConfigExists) {
+		t.Errorf("InitConfig() on an existing file = %v, expected ErrConfigExists", err)
+	}
+}
+
+func TestParseSourceLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantSource string
+		wantOpts   SourceOptions
+	}{
+		{
+			name:       "plain URL",
+			line:       "https://example.com/banners.json",
+			wantSource: "https://example.com/banners.json",
+		},
+		{
+			name:       "token env",
+			line:       "https://example.com/b.json token_env=INTERNAL_TOKEN",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{TokenEnv: "INTERNAL_TOKEN"},
+		},
+		{
+			name:       "quoted command with spaced equals",
+			line:       `https://example.com/b.json token_cmd = "pass show basar/internal"`,
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{TokenCmd: "pass show basar/internal"},
+		},
+		{
+			name:       "token file",
+			line:       "https://example.com/b.json\ttoken_file=~/.config/basar/token",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{TokenFile: "~/.config/basar/token"},
+		},
+		{
+			name:       "local path with spaces",
+			line:       "/srv/my banners/banners.json",
+			wantSource: "/srv/my banners/banners.json",
+		},
+		{
+			name:       "required",
+			line:       "https://example.com/b.json required=true token_env=T",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{TokenEnv: "T", Required: true},
+		},
+		{
+			name:       "timeout",
+			line:       "https://example.com/b.json timeout=2m",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{Timeout: 2 * time.Minute},
+		},
+		{
+			name:       "cursor pagination",
+			line:       "https://api.example/banners cursor_field=next_cursor cursor_param=after",
+			wantSource: "https://api.example/banners",
+			wantOpts:   SourceOptions{CursorField: "next_cursor", CursorParam: "after"},
+		},
+		{
+			name:       "disabled and tagged",
+			line:       "https://example.com/b.json enabled=false tag=ubuntu,lts tag=lts",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{Disabled: true, Tags: []string{"ubuntu", "lts"}},
+		},
+		{
+			name:       "priority",
+			line:       "https://mirror.internal/b.json priority=10",
+			wantSource: "https://mirror.internal/b.json",
+			wantOpts:   SourceOptions{Priority: 10},
+		},
+		{
+			name:       "negotiate auth",
+			line:       "https://symbols.corp.example/b.json auth=Negotiate",
+			wantSource: "https://symbols.corp.example/b.json",
+			wantOpts:   SourceOptions{Auth: AuthNegotiate},
+		},
+		{
+			name:       "netrc auth and keyring token",
+			line:       "https://symbols.corp.example/b.json auth=netrc token_keyring=internal-symbols",
+			wantSource: "https://symbols.corp.example/b.json",
+			wantOpts:   SourceOptions{Auth: AuthNetrc, TokenKeyring: "internal-symbols"},
+		},
+		{
+			name:       "format",
+			line:       "https://example.com/banners.csv format=CSV",
+			wantSource: "https://example.com/banners.csv",
+			wantOpts:   SourceOptions{Format: "csv"},
+		},
+		{
+			name:       "unknown format ignored",
+			line:       "https://example.com/banners.xml format=xml",
+			wantSource: "https://example.com/banners.xml",
+		},
+		{
+			name:       "schema quarantine",
+			line:       "https://example.com/b.json schema=Quarantine",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{Schema: "quarantine"},
+		},
+		{
+			name:       "invalid schema ignored",
+			line:       "https://example.com/b.json schema=lenient",
+			wantSource: "https://example.com/b.json",
+		},
+		{
+			name:       "shrink quarantine",
+			line:       "https://example.com/b.json shrink=quarantine",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{Shrink: ShrinkQuarantine},
+		},
+		{
+			name:       "invalid shrink ignored",
+			line:       "https://example.com/b.json shrink=block",
+			wantSource: "https://example.com/b.json",
+		},
+		{
+			name:       "trust level",
+			line:       "https://example.com/b.json trust=Trusted",
+			wantSource: "https://example.com/b.json",
+			wantOpts:   SourceOptions{Trust: TrustTrusted},
+		},
+		{
+			name:       "invalid trust ignored",
+			line:       "https://example.com/b.json trust=verified",
+			wantSource: "https://example.com/b.json",
+		},
+		{
+			name:       "invalid timeout ignored",
+			line:       "https://example.com/b.json timeout=soon",
+			wantSource: "https://example.com/b.json",
+		},
+		{
+			name:       "unknown option ignored",
+			line:       "https://example.com/b.json future=1",
+			wantSource: "https://example.com/b.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, opts := parseSourceLine(tt.line)
+			if source != tt.wantSource {
+				t.Errorf("source = %q, expected %q", source, tt.wantSource)
+			}
+			if !reflect.DeepEqual(opts, tt.wantOpts) {
+				t.Errorf("opts = %+v, expected %+v", opts, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestLoadSourcesWithOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{ConfigFile: filepath.Join(tmpDir, "sources.conf")}
+
+	content := "# comment\nhttps://a.example/b.json token_env=A_TOKEN\n/local/file.json\n"
+	if err := os.WriteFile(cfg.ConfigFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	sources, options, _ := cfg.loadSources()
+	if len(sources) != 2 || sources[0] != "https://a.example/b.json" {
+		t.Fatalf("sources = %v", sources)
+	}
+
+	cfg.Options = options
+	if got := cfg.SourceOptions("https://a.example/b.json").TokenEnv; got != "A_TOKEN" {
+		t.Errorf("TokenEnv = %q, expected A_TOKEN", got)
+	}
+	if got := cfg.SourceOptions("/unknown"); !reflect.DeepEqual(got, SourceOptions{}) {
+		t.Errorf("unknown source should have zero options, got %+v", got)
+	}
+}
+
+func TestLoadSourcesLayers(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{
+		ConfigDir:       filepath.Join(tmpDir, "user"),
+		ConfigFile:      filepath.Join(tmpDir, "user", "sources.conf"),
+		SystemConfigDir: filepath.Join(tmpDir, "etc"),
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Drop-ins alone add to the defaults
+	write(filepath.Join(tmpDir, "etc", "sources.conf.d", "team.conf"), "/team.json\n")
+	write(filepath.Join(tmpDir, "etc", "sources.conf.d", "ignored.txt"), "/ignored.json\n")
+	sources, _, files := cfg.loadSources()
+	if want := append(append([]string{}, DefaultSources...), "/team.json"); !slices.Equal(sources, want) {
+		t.Errorf("sources = %v, expected %v", sources, want)
+	}
+	if len(files) != 1 {
+		t.Errorf("files = %v, expected only the drop-in", files)
+	}
+
+	// Main files replace the defaults; the user layer overrides options
+	write(filepath.Join(tmpDir, "etc", "sources.conf"), "/shared.json token_env=SYSTEM\n")
+	write(cfg.ConfigFile, "/mine.json\n")
+	write(filepath.Join(tmpDir, "user", "sources.conf.d", "b.conf"), "/shared.json token_env=USER\n")
+	write(filepath.Join(tmpDir, "user", "sources.conf.d", "a.conf"), "/team.json\n/extra.json\n")
+
+	sources, options, files := cfg.loadSources()
+	want := []string{"/shared.json", "/mine.json", "/team.json", "/extra.json"}
+	if !slices.Equal(sources, want) {
+		t.Errorf("sources = %v, expected %v", sources, want)
+	}
+	if got := options["/shared.json"].TokenEnv; got != "USER" {
+		t.Errorf("TokenEnv = %q, expected the user layer's USER", got)
+	}
+	wantFiles := []string{
+		filepath.Join(tmpDir, "etc", "sources.conf"),
+		filepath.Join(tmpDir, "etc", "sources.conf.d", "team.conf"),
+		cfg.ConfigFile,
+		filepath.Join(tmpDir, "user", "sources.conf.d", "a.conf"),
+		filepath.Join(tmpDir, "user", "sources.conf.d", "b.conf"),
+	}
+	if !slices.Equal(files, wantFiles) {
+		t.Errorf("files = %v, expected %v", files, wantFiles)
+	}
+}
+
+func TestLoadSourcesDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{ConfigFile: filepath.Join(tmpDir, "sources.conf")}
+
+	content := "/a.json enabled=false\n/b.json\n"
+	if err := os.WriteFile(cfg.ConfigFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sources, _, _ := cfg.loadSources(); !slices.Equal(sources, []string{"/b.json"}) {
+		t.Errorf("sources = %v, expected the disabled source left out", sources)
+	}
+
+	// Disabling every listed source must not bring back the defaults
+	if err := os.WriteFile(cfg.ConfigFile, []byte("/a.json enabled=false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sources, _, _ := cfg.loadSources(); len(sources) != 0 {
+		t.Errorf("sources = %v, expected none", sources)
+	}
+}
+
+func TestSelects(t *testing.T) {
+	cfg := &Config{Options: map[string]SourceOptions{
+		"https://a.example/b.json": {Tags: []string{"ubuntu", "lts"}},
+	}}
+
+	tests := []struct {
+		selector, source string
+		want             bool
+	}{
+		{"tag=ubuntu", "https://a.example/b.json", true},
+		{"tag=lts", "https://a.example/b.json", true},
+		{"tag=debian", "https://a.example/b.json", false},
+		{"tag=ubuntu", "/local.json", false},
+		{"https://a.example/b.json", "https://a.example/b.json", true},
+		{"https://a.example", "https://a.example/b.json", false},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.Selects(tt.selector, tt.source); got != tt.want {
+			t.Errorf("Selects(%q, %q) = %v, expected %v", tt.selector, tt.source, got, tt.want)
+		}
 	}
 }
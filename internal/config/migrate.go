@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// relocation moves one file or directory from an older layout.
+type relocation struct {
+	from, to string
+}
+
+// legacyRelocations lists where earlier versions kept each file: everything
+// lived in the XDG (or home) cache and config dirs, including metadata and
+// snapshots that now belong in the state dir.
+func (c *Config) legacyRelocations() []relocation {
+	oldCache := filepath.Join(xdgPath("XDG_CACHE_HOME", ".cache"), AppName)
+	oldConfig := filepath.Join(xdgPath("XDG_CONFIG_HOME", ".config"), AppName)
+
+	return []relocation{
+		{filepath.Join(oldCache, "banners.json"), c.CacheFile},
+		{filepath.Join(oldCache, "provenance.json"), filepath.Join(c.CacheDir, "provenance.json")},
+		{filepath.Join(oldCache, "mirror"), filepath.Join(c.CacheDir, "mirror")},
+		{filepath.Join(oldCache, "meta.json"), c.MetaFile},
+		{filepath.Join(oldCache, "snapshots"), c.SnapshotDir},
+		{filepath.Join(oldConfig, "sources.conf"), c.ConfigFile},
+		{filepath.Join(oldConfig, "hooks.d"), c.HooksDir},
+	}
+}
+
+// A Migration is a file or directory left by an older layout: New moves it
+// From its old path To the current one or, when Stale because a current
+// copy exists, removes it.
+type Migration struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Stale bool   `json:"stale,omitempty"`
+}
+
+// pendingMigrations lists the files from older layouts still to migrate.
+func (c *Config) pendingMigrations() []Migration {
+	var pending []Migration
+	for _, r := range c.legacyRelocations() {
+		if r.from == r.to {
+			continue
+		}
+		if _, err := os.Lstat(r.from); err != nil {
+			continue
+		}
+		_, err := os.Lstat(r.to)
+		pending = append(pending, Migration{From: r.from, To: r.to, Stale: err == nil})
+	}
+	return pending
+}
+
+// migrate relocates files left by older layouts to the current one,
+// returning a note for each move. When both copies exist the current one
+// wins and the stale copy is removed, so an upgrade never leaves a second
+// cache behind that basar would ignore.
+func (c *Config) migrate() ([]string, error) {
+	var notes []string
+	for _, m := range c.pendingMigrations() {
+		if m.Stale {
+			if err := os.RemoveAll(m.From); err != nil {
+				return notes, fmt.Errorf("removing stale %s: %w", m.From, err)
+			}
+			notes = append(notes, fmt.Sprintf("removed stale %s (superseded by %s)", m.From, m.To))
+			continue
+		}
+
+		if err := moveAtomic(m.From, m.To); err != nil {
+			return notes, fmt.Errorf("migrating %s: %w", m.From, err)
+		}
+		notes = append(notes, fmt.Sprintf("moved %s to %s", m.From, m.To))
+	}
+
+	return notes, nil
+}
+
+// moveAtomic moves from to to. A plain rename is atomic; when that fails,
+// e.g. across filesystems, the data is copied under a temporary name and
+// renamed into place before the original is removed, so to never exists
+// half-written.
+func moveAtomic(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	tmp := to + ".migrating"
+	_ = os.RemoveAll(tmp)
+	if err := copyTree(from, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+
+	return os.RemoveAll(from)
+}
+
+// copyTree copies a file or directory tree, preserving permissions.
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile copies a single regular file.
+func copyFile(from, to string, mode os.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMigrateRemovesStaleCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+
+	cfg := &Config{
+		CacheDir: filepath.Join(tmpDir, "cache", AppName),
+		MetaFile: filepath.Join(tmpDir, "state", AppName, "meta.json"),
+	}
+
+	oldMeta := filepath.Join(cfg.CacheDir, "meta.json")
+	for path, content := range map[string]string{oldMeta: "old", cfg.MetaFile: "new"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notes, err := cfg.migrate()
+	if err != nil {
+		t.Fatalf("migrate() failed: %v", err)
+	}
+	if len(notes) != 1 {
+		t.Errorf("expected one note, got %v", notes)
+	}
+
+	if _, err := os.Stat(oldMeta); !os.IsNotExist(err) {
+		t.Error("stale meta.json should be removed")
+	}
+	if data, _ := os.ReadFile(cfg.MetaFile); string(data) != "new" {
+		t.Errorf("current meta.json should be kept, got %q", data)
+	}
+}
+
+func TestMigrateNothingToDo(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+
+	cfg := &Config{
+		CacheDir:    filepath.Join(tmpDir, "cache", AppName),
+		MetaFile:    filepath.Join(tmpDir, "state", AppName, "meta.json"),
+		SnapshotDir: filepath.Join(tmpDir, "state", AppName, "snapshots"),
+	}
+
+	notes, err := cfg.migrate()
+	if err != nil || len(notes) != 0 {
+		t.Errorf("migrate() on a fresh install = %v, %v; expected no changes", notes, err)
+	}
+}
+
+func TestNewDryRunLeavesLegacyLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "state"))
+	t.Setenv("BASAR_PROFILE", "")
+	t.Setenv("BASAR_CONFIG", "")
+	t.Setenv("BASAR_CACHE_DIR", "")
+
+	oldMeta := filepath.Join(tmpDir, "cache", AppName, "meta.json")
+	if err := os.MkdirAll(filepath.Dir(oldMeta), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldMeta, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := NewWith(Overrides{DryRun: true})
+	expected := []Migration{{From: oldMeta, To: cfg.MetaFile}}
+	if !slices.Equal(cfg.PendingMigrations, expected) || len(cfg.Migrations) != 0 {
+		t.Errorf("NewWith(DryRun) = pending %v, migrated %v; expected pending %v", cfg.PendingMigrations, cfg.Migrations, expected)
+	}
+	if _, err := os.Stat(oldMeta); err != nil {
+		t.Errorf("NewWith(DryRun) moved %s: %v", oldMeta, err)
+	}
+
+	cfg = NewWith(Overrides{})
+	if len(cfg.Migrations) != 1 || len(cfg.PendingMigrations) != 0 {
+		t.Errorf("NewWith() = migrated %v, pending %v; expected the move done", cfg.Migrations, cfg.PendingMigrations)
+	}
+	if _, err := os.Stat(cfg.MetaFile); err != nil {
+		t.Errorf("NewWith() did not move meta.json: %v", err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "snapshots")
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "nested", "a.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "nested", "a.json"))
+	if err != nil || string(data) != "{}" {
+		t.Errorf("copied file = %q, %v", data, err)
+	}
+}
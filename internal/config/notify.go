@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Notification events, which notify.conf routes to targets.
+const (
+	// EventUpdateSuccess is an update that changed the cache.
+	EventUpdateSuccess = "update-success"
+	// EventUpdateFailure is a failed update.
+	EventUpdateFailure = "update-failure"
+	// EventNewBanners is an update that added banners.
+	EventNewBanners = "new-banners"
+	// EventUpdateDigest summarizes an unattended update that changed the
+	// cache or had failures. It repeats the other events, so only targets
+	// listing it receive it.
+	EventUpdateDigest = "update-digest"
+)
+
+// NotifyEvents lists the notification events.
+var NotifyEvents = []string{EventUpdateSuccess, EventUpdateFailure, EventNewBanners, EventUpdateDigest}
+
+// NotifyBackends lists the notification backends notify.conf accepts, and
+// whether each takes a target after its name.
+var NotifyBackends = map[string]bool{
+	"webhook": true,  // URL receiving the event as JSON
+	"email":   true,  // SMTP server host:port
+	"command": true,  // shell command receiving the event on stdin
+	"desktop": false, // the desktop's notification center
+}
+
+// notifyOptions are the options notify.conf lines accept after the target.
+var notifyOptions = []string{"events", "to", "from", "username_env", "password_env"}
+
+// NotifyTarget is one notify.conf line: where to send which events.
+type NotifyTarget struct {
+	Backend string
+	Target  string
+	// Events lists the events routed to the target, all but
+	// EventUpdateDigest when empty.
+	Events []string
+	// Options holds the backend's settings, e.g. "to" for email.
+	Options map[string]string
+}
+
+// Routes reports whether event goes to the target.
+func (t NotifyTarget) Routes(event string) bool {
+	if len(t.Events) == 0 {
+		return event != EventUpdateDigest
+	}
+	return slices.Contains(t.Events, event)
+}
+
+// loadNotifyTargets reads a notify.conf file: lines of a backend, its
+// target, and `key=value` options, e.g.
+//
+//	webhook https://hooks.example.com/basar events=update-failure
+//
+// Blank lines and # comments are ignored. It returns a warning for each
+// line it skips.
+func loadNotifyTargets(path string) ([]NotifyTarget, []string) {
+	lines, err := readSourceLines(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	var targets []NotifyTarget
+	var warnings []string
+	for _, line := range lines {
+		t, err := parseNotifyLine(line)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: %v", path, line, err))
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets, warnings
+}
+
+// parseNotifyLine parses a notify.conf line. A field is an option when it
+// starts with a known option key and "=", so URLs with query strings stay
+// targets.
+func parseNotifyLine(line string) (NotifyTarget, error) {
+	fields := splitFields(line)
+	t := NotifyTarget{Backend: fields[0], Options: make(map[string]string)}
+	takesTarget, ok := NotifyBackends[t.Backend]
+	if !ok {
+		return t, fmt.Errorf("unknown backend %q", t.Backend)
+	}
+
+	for _, field := range fields[1:] {
+		key, value, isOption := strings.Cut(field, "=")
+		switch {
+		case isOption && slices.Contains(notifyOptions, key):
+			t.Options[key] = value
+		case t.Target == "" && takesTarget:
+			t.Target = field
+		default:
+			return t, fmt.Errorf("unexpected %q", field)
+		}
+	}
+	if takesTarget && t.Target == "" {
+		return t, fmt.Errorf("%s needs a target", t.Backend)
+	}
+
+	if events := t.Options["events"]; events != "" {
+		for _, event := range strings.Split(events, ",") {
+			event = strings.TrimSpace(event)
+			if !slices.Contains(NotifyEvents, event) {
+				return t, fmt.Errorf("unknown event %q (expected %s)", event, strings.Join(NotifyEvents, ", "))
+			}
+			t.Events = append(t.Events, event)
+		}
+	}
+	if t.Backend == "email" && (t.Options["to"] == "" || t.Options["from"] == "") {
+		return t, fmt.Errorf("email needs to= and from=")
+	}
+	return t, nil
+}
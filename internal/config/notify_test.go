@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadNotifyTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.conf")
+	content := `# failures to chat, new banners to the team
+webhook https://hooks.example.com/basar?token=x events=update-failure
+email smtp.example.com:587 to=ir@example.com,soc@example.com from=basar@example.com password_env=SMTP_PASS events=new-banners
+desktop
+command "logger -t basar" events=update-success,update-failure
+pager https://pager.example.com
+webhook events=update-failure
+desktop events=update-started
+email smtp.example.com:25 to=ir@example.com
+desktop extra
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, warnings := loadNotifyTargets(path)
+	if len(targets) != 4 {
+		t.Fatalf("loadNotifyTargets() = %+v, expected 4 targets", targets)
+	}
+	if len(warnings) != 5 || !strings.Contains(warnings[0], `unknown backend "pager"`) {
+		t.Errorf("warnings = %q, expected 5", warnings)
+	}
+
+	webhook := targets[0]
+	if webhook.Target != "https://hooks.example.com/basar?token=x" ||
+		!reflect.DeepEqual(webhook.Events, []string{EventUpdateFailure}) {
+		t.Errorf("webhook = %+v", webhook)
+	}
+	if !webhook.Routes(EventUpdateFailure) || webhook.Routes(EventNewBanners) {
+		t.Error("webhook routes the wrong events")
+	}
+	if email := targets[1]; email.Options["to"] != "ir@example.com,soc@example.com" || email.Options["password_env"] != "SMTP_PASS" {
+		t.Errorf("email = %+v", email)
+	}
+	if desktop := targets[2]; desktop.Target != "" || !desktop.Routes(EventNewBanners) || desktop.Routes(EventUpdateDigest) {
+		t.Errorf("desktop = %+v, expected it to route every event but digests", desktop)
+	}
+	if command := targets[3]; command.Target != "logger -t basar" || len(command.Events) != 2 {
+		t.Errorf("command = %+v", command)
+	}
+}
+
+func TestLoadNotifyTargetsMissing(t *testing.T) {
+	targets, warnings := loadNotifyTargets(filepath.Join(t.TempDir(), "notify.conf"))
+	if targets != nil || warnings != nil {
+		t.Errorf("loadNotifyTargets() of a missing file = %v, %v", targets, warnings)
+	}
+}
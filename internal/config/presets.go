@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Presets accepted by InitConfig.
+const (
+	PresetMinimal          = "minimal"
+	PresetFull             = "full"
+	PresetInternalTemplate = "internal-template"
+)
+
+// Presets lists the valid InitConfig presets.
+var Presets = []string{PresetMinimal, PresetFull, PresetInternalTemplate}
+
+const configHeader = `# basar sources configuration
+# One URL or local path per line
+# Lines starting with # are comments
+`
+
+const optionsExample = `
+# Per-source options follow the source as key=value pairs, e.g. a bearer
+# token read from outside this file:
+#   https://symbols.example.com/banners.json token_env=SYMBOLS_TOKEN
+#   https://symbols.example.com/banners.json token_file=~/.config/basar/symbols.token
+#   https://symbols.example.com/banners.json token_cmd="pass show basar/symbols"
+#   https://symbols.example.com/banners.json token_keyring=symbols
+# a login from ~/.netrc:
+#   https://symbols.example.com/banners.json auth=netrc
+# or Kerberos, for servers in an Active Directory domain:
+#   https://symbols.example.com/banners.json auth=negotiate
+# Add required=true to fail the update, rather than publish an index without
+# the source, when it cannot be fetched. priority=N lists a source's symbol
+# URLs ahead of those of lower priority (default 0) for the same banner.
+# Banner files in a GitHub repository directory, found through the API
+# (GITHUB_TOKEN raises its rate limit):
+#   github://OWNER/REPO/PATH[@REF]
+# or a file in any git repository, fetched shallowly:
+#   git+https://git.example.com/isf.git#path=banners.json&ref=main
+# or an artifact in an OCI registry, as basar publish --oci pushes it:
+#   oci://ghcr.io/example/isf-banners:latest
+# or the output of a local command:
+#   command:///usr/local/bin/make-banners?arg=--all
+# or, in scripts piping an index into an update, standard input:
+#   -
+# Malformed sources fail; schema=quarantine drops only their bad banners.
+# A fetch with far fewer banners than usual is merged with a warning;
+# shrink=quarantine merges the source's previous banners instead.
+# trust=trusted (or community, experimental) rates a source; url-filters.conf
+# can require a min-trust for the URLs kept.
+# Plain text, CSV, and other index layouts are detected; format=csv (or isf,
+# distro, records, text) sets one.
+`
+
+const internalExample = `
+# Internal sources. Uncomment and adjust; until a source is active, basar
+# falls back to the built-in public sources.
+#
+# An internal mirror of the merged index, authenticated with a token from
+# the environment:
+#   https://symbols.internal.example.com/banners.json token_env=BASAR_INTERNAL_TOKEN
+#
+# Symbols built in-house, with the token kept in a private file (chmod 600);
+# updates fail rather than publish an index missing them:
+#   https://builds.internal.example.com/isf/banners.json token_file=~/.config/basar/builds.token required=true
+#
+# A REST symbol service returning banners a page at a time. Link headers
+# are followed; cursor_field names the response field with the next cursor:
+#   https://symbols.internal.example.com/api/v1/banners token_env=BASAR_INTERNAL_TOKEN cursor_field=next_cursor
+#
+# A local or network-mounted index:
+#   /srv/symbols/banners.json
+`
+
+// presetContent returns the sources.conf contents for a preset.
+func presetContent(preset string) (string, error) {
+	var b strings.Builder
+	b.WriteString(configHeader)
+
+	switch preset {
+	case PresetMinimal:
+		b.WriteString("\n" + DefaultSources[0] + "\n")
+		b.WriteString(optionsExample)
+	case PresetFull, "":
+		b.WriteString("\n" + strings.Join(DefaultSources, "\n") + "\n")
+		b.WriteString(optionsExample)
+	case PresetInternalTemplate:
+		b.WriteString(internalExample)
+		b.WriteString(optionsExample)
+	default:
+		return "", fmt.Errorf("unknown preset %q (want %s)", preset, strings.Join(Presets, ", "))
+	}
+
+	return b.String(), nil
+}
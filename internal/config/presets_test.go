@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitConfigPresets(t *testing.T) {
+	tests := []struct {
+		preset      string
+		wantSources []string
+	}{
+		{preset: "", wantSources: DefaultSources},
+		{preset: PresetFull, wantSources: DefaultSources},
+		{preset: PresetMinimal, wantSources: DefaultSources[:1]},
+		// Everything is commented out, so the defaults apply
+		{preset: PresetInternalTemplate, wantSources: DefaultSources},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := &Config{
+				ConfigDir:  tmpDir,
+				ConfigFile: filepath.Join(tmpDir, "sources.conf"),
+			}
+
+			if err := cfg.InitConfig(tt.preset); err != nil {
+				t.Fatalf("InitConfig(%q) failed: %v", tt.preset, err)
+			}
+
+			sources, _, _ := cfg.loadSources()
+			if strings.Join(sources, ",") != strings.Join(tt.wantSources, ",") {
+				t.Errorf("sources = %v, expected %v", sources, tt.wantSources)
+			}
+
+			data, err := os.ReadFile(cfg.ConfigFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), "token_env=") {
+				t.Error("config should include commented per-source option examples")
+			}
+		})
+	}
+}
+
+func TestInitConfigUnknownPreset(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{
+		ConfigDir:  tmpDir,
+		ConfigFile: filepath.Join(tmpDir, "sources.conf"),
+	}
+
+	if err := cfg.InitConfig("huge"); err == nil {
+		t.Error("InitConfig() should reject unknown presets")
+	}
+	if _, err := os.Stat(cfg.ConfigFile); !os.IsNotExist(err) {
+		t.Error("no config should be written for an unknown preset")
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Authentication methods, for sources with auth=METHOD and for proxies
+// with `auth METHOD` in proxy.conf.
+const (
+	// AuthNegotiate authenticates with Kerberos (Negotiate/SPNEGO).
+	AuthNegotiate = "negotiate"
+	// AuthNetrc logs in with the netrc file's credentials for the host.
+	AuthNetrc = "netrc"
+	// AuthKeyring logs in to proxies with a user:password secret from the
+	// OS credential store.
+	AuthKeyring = "keyring"
+)
+
+// loadProxyConf reads a proxy.conf file: a `pac LOCATION` line naming the
+// proxy auto-config file by URL or path, relative paths being relative to
+// the file, and an `auth negotiate`, `auth netrc`, or `auth keyring NAME`
+// line saying how to authenticate to proxies. Blank lines and # comments
+// are ignored. It returns "" for what is not given, and a warning for each
+// line it skips.
+func loadProxyConf(path string) (pac, auth, keyring string, warnings []string) {
+	lines, err := readSourceLines(path)
+	if err != nil {
+		return "", "", "", nil
+	}
+
+	for _, line := range lines {
+		kind, arg := splitFilterLine(line)
+		if kind == "auth" {
+			method, name := splitFilterLine(arg)
+			switch method = strings.ToLower(method); {
+			case method == AuthNegotiate && name == "", method == AuthNetrc && name == "":
+				auth, keyring = method, ""
+			case method == AuthKeyring && name != "" && !strings.ContainsAny(name, " \t"):
+				auth, keyring = method, name
+			case method == AuthKeyring:
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: expected auth keyring NAME", path, line))
+			default:
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: expected auth negotiate, netrc, or keyring NAME", path, line))
+			}
+			continue
+		}
+		switch {
+		case kind != "pac":
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: expected pac or auth", path, line))
+		case arg == "":
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: missing PAC file URL or path", path, line))
+		case pac != "":
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: pac given twice", path, line))
+		default:
+			pac = arg
+			if !strings.Contains(arg, "://") && !filepath.IsAbs(arg) {
+				pac = filepath.Join(filepath.Dir(path), arg)
+			}
+		}
+	}
+	return pac, auth, keyring, warnings
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProxyConf(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "proxy.conf")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("# corporate proxy\npac http://wpad.corp.example/wpad.dat\npac http://other.example/proxy.pac\nproxy http://p:3128\npac\nauth ntlm\n")
+	pac, auth, _, warnings := loadProxyConf(path)
+	if pac != "http://wpad.corp.example/wpad.dat" || auth != "" {
+		t.Errorf("pac, auth = %q, %q", pac, auth)
+	}
+	if len(warnings) != 4 {
+		t.Errorf("warnings = %q, expected 4", warnings)
+	}
+
+	write("pac proxy.pac\nauth Negotiate\n")
+	if pac, auth, _, _ := loadProxyConf(path); pac != filepath.Join(dir, "proxy.pac") || auth != AuthNegotiate {
+		t.Errorf("pac, auth = %q, %q; expected the PAC file next to proxy.conf and negotiate", pac, auth)
+	}
+
+	write("auth netrc\n")
+	if _, auth, keyring, warnings := loadProxyConf(path); auth != AuthNetrc || keyring != "" || warnings != nil {
+		t.Errorf("auth, keyring = %q, %q (warnings %q); expected netrc", auth, keyring, warnings)
+	}
+
+	write("auth keyring\nauth netrc extra\nauth keyring corp-proxy\n")
+	_, auth, keyring, warnings := loadProxyConf(path)
+	if auth != AuthKeyring || keyring != "corp-proxy" {
+		t.Errorf("auth, keyring = %q, %q; expected keyring corp-proxy", auth, keyring)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q, expected 2", warnings)
+	}
+
+	if pac, auth, _, warnings := loadProxyConf(filepath.Join(dir, "missing.conf")); pac != "" || auth != "" || warnings != nil {
+		t.Errorf("loadProxyConf() of a missing file = %q, %q, %v", pac, auth, warnings)
+	}
+}
+
+func TestProxyPACEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BASAR_PROXY_PAC", "")
+	if err := os.WriteFile(filepath.Join(dir, "proxy.conf"), []byte("pac /etc/proxy.pac\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := NewWith(Overrides{ConfigFile: filepath.Join(dir, "sources.conf")}); cfg.ProxyPAC != "/etc/proxy.pac" {
+		t.Errorf("ProxyPAC = %q, expected proxy.conf's", cfg.ProxyPAC)
+	}
+	t.Setenv("BASAR_PROXY_PAC", "http://wpad/wpad.dat")
+	if cfg := NewWith(Overrides{ConfigFile: filepath.Join(dir, "sources.conf")}); cfg.ProxyPAC != "http://wpad/wpad.dat" {
+		t.Errorf("ProxyPAC = %q, expected BASAR_PROXY_PAC", cfg.ProxyPAC)
+	}
+}
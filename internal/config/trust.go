@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Trust levels a source may be given with trust=, from the least trusted.
+// Sources without one are TrustCommunity.
+const (
+	TrustExperimental = "experimental"
+	TrustCommunity    = "community"
+	TrustTrusted      = "trusted"
+)
+
+// TrustLevels lists the trust levels from the least trusted.
+var TrustLevels = []string{TrustExperimental, TrustCommunity, TrustTrusted}
+
+// ParseTrust validates a trust level, in any case.
+func ParseTrust(s string) (string, error) {
+	level := strings.ToLower(s)
+	if !slices.Contains(TrustLevels, level) {
+		return "", fmt.Errorf("unknown trust level %q (want %s)", s, strings.Join(TrustLevels, ", "))
+	}
+	return level, nil
+}
+
+// TrustRank orders trust levels, higher for more trusted; "" ranks as
+// TrustCommunity.
+func TrustRank(level string) int {
+	if level == "" {
+		level = TrustCommunity
+	}
+	return slices.Index(TrustLevels, level)
+}
+
+// SourceTrust returns the trust level of source.
+func (c *Config) SourceTrust(source string) string {
+	if level := c.SourceOptions(source).Trust; level != "" {
+		return level
+	}
+	return TrustCommunity
+}
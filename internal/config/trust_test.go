@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestParseTrust(t *testing.T) {
+	for _, s := range []string{"trusted", "Community", "EXPERIMENTAL"} {
+		if _, err := ParseTrust(s); err != nil {
+			t.Errorf("ParseTrust(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := ParseTrust("vetted"); err == nil {
+		t.Error("ParseTrust(\"vetted\") succeeded, expected an error")
+	}
+
+	if !(TrustRank(TrustExperimental) < TrustRank("") && TrustRank("") == TrustRank(TrustCommunity) && TrustRank(TrustCommunity) < TrustRank(TrustTrusted)) {
+		t.Error("TrustRank() should order experimental, community (or unset), trusted")
+	}
+
+	cfg := &Config{Options: map[string]SourceOptions{"a": {Trust: TrustTrusted}}}
+	if got := cfg.SourceTrust("a"); got != TrustTrusted {
+		t.Errorf("SourceTrust(a) = %q, expected trusted", got)
+	}
+	if got := cfg.SourceTrust("b"); got != TrustCommunity {
+		t.Errorf("SourceTrust(b) = %q, expected community", got)
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// URLFilter restricts the symbol URLs merged into the cache: a URL
+// matching a Deny pattern is dropped, and when there are Allow patterns,
+// so is one matching none of them. With MinTrust, so is a URL no source
+// of at least that trust level lists.
+type URLFilter struct {
+	Allow    []*regexp.Regexp
+	Deny     []*regexp.Regexp
+	MinTrust string
+}
+
+// Allows reports whether the filter keeps rawURL. A nil filter keeps
+// everything.
+func (f *URLFilter) Allows(rawURL string) bool {
+	if f == nil {
+		return true
+	}
+	if matchesAny(f.Deny, rawURL) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchesAny(f.Allow, rawURL)
+}
+
+// AllowsTrust reports whether the filter keeps a URL of trust level, the
+// highest of the sources listing it. A nil filter keeps everything.
+func (f *URLFilter) AllowsTrust(level string) bool {
+	return f == nil || f.MinTrust == "" || TrustRank(level) >= TrustRank(f.MinTrust)
+}
+
+// matchesAny reports whether any of res matches s.
+func matchesAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadURLFilter reads a url-filters.conf file: `allow PATTERN` and `deny
+// PATTERN` lines, a `min-trust LEVEL` line, and `redirect HOST TARGET`
+// lines naming hosts known to redirect elsewhere, with blank lines and #
+// comments ignored. It returns a nil filter if the file is missing or
+// holds no pattern or trust level, nil redirects
+// if it holds none, and a warning for each line it skips.
+func loadURLFilter(path string) (*URLFilter, map[string]string, []string) {
+	lines, err := readSourceLines(path)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	var f URLFilter
+	var redirects map[string]string
+	var warnings []string
+	for _, line := range lines {
+		kind, pattern := splitFilterLine(line)
+		if kind == "min-trust" {
+			level, err := ParseTrust(pattern)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: %v", path, line, err))
+				continue
+			}
+			f.MinTrust = level
+			continue
+		}
+		if kind == "redirect" {
+			host, target, err := parseRedirect(pattern)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: %v", path, line, err))
+				continue
+			}
+			if redirects == nil {
+				redirects = make(map[string]string)
+			}
+			redirects[host] = target
+			continue
+		}
+		re, err := compileURLPattern(pattern)
+		if err == nil && pattern == "" {
+			err = errors.New("missing pattern")
+		}
+		switch {
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: %v", path, line, err))
+		case kind == "allow":
+			f.Allow = append(f.Allow, re)
+		case kind == "deny":
+			f.Deny = append(f.Deny, re)
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: expected allow, deny, redirect, or min-trust", path, line))
+		}
+	}
+	if len(f.Allow) == 0 && len(f.Deny) == 0 && f.MinTrust == "" {
+		return nil, redirects, warnings
+	}
+	return &f, redirects, warnings
+}
+
+// parseRedirect parses the argument of a redirect line, a host (with its
+// port, if not the default) and its target, a host or a scheme://host. Both
+// are returned in lowercase.
+func parseRedirect(arg string) (host, target string, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) != 2 {
+		return "", "", errors.New("expected a host and its target")
+	}
+	host, target = strings.ToLower(fields[0]), strings.ToLower(fields[1])
+	if strings.ContainsAny(host, "/?#") {
+		return "", "", errors.New("expected a host, not a URL")
+	}
+	name := target
+	if scheme, rest, ok := strings.Cut(target, "://"); ok {
+		if scheme != "http" && scheme != "https" {
+			return "", "", fmt.Errorf("unsupported target scheme %q", scheme)
+		}
+		name = rest
+	}
+	if name == "" || strings.ContainsAny(name, "/?#") {
+		return "", "", errors.New("expected a target host")
+	}
+	return host, target, nil
+}
+
+// splitFilterLine splits a filters file line into its keyword and the
+// argument after it.
+func splitFilterLine(line string) (kind, arg string) {
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		return line[:i], strings.TrimSpace(line[i+1:])
+	}
+	return line, ""
+}
+
+// compileURLPattern compiles a URL filter pattern: a regular expression
+// after "re:", otherwise a glob matching the whole URL, where * matches any
+// run of characters, "/" included, and ? any single one.
+func compileURLPattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(expr)
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadURLFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "url-filters.conf")
+	content := `# approved mirrors only
+allow https://*.example.org/*
+allow	re:^file:///srv/isf/
+deny https://broken.example.org/*
+deny re:[
+block https://other.example/*
+allow
+redirect Old.Example.org:443 https://isf.example.org
+redirect mirror.example.org
+redirect ftp.example.org ftp://isf.example.org
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, redirects, warnings := loadURLFilter(path)
+	if f == nil || len(f.Allow) != 2 || len(f.Deny) != 1 {
+		t.Fatalf("loadURLFilter() = %+v, expected 2 allow and 1 deny patterns", f)
+	}
+	if len(warnings) != 5 || !strings.Contains(warnings[1], "expected allow, deny, redirect, or min-trust") {
+		t.Errorf("warnings = %q, expected 5", warnings)
+	}
+	if len(redirects) != 1 || redirects["old.example.org:443"] != "https://isf.example.org" {
+		t.Errorf("redirects = %v, expected old.example.org:443 only", redirects)
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://isf.example.org/ubuntu/5.15.json.xz", true},
+		{"file:///srv/isf/custom.json", true},
+		{"https://broken.example.org/ubuntu/5.15.json.xz", false},
+		{"https://example.com/ubuntu/5.15.json.xz", false},
+		{"http://isf.example.org/ubuntu/5.15.json.xz", false},
+	}
+	for _, tt := range tests {
+		if got := f.Allows(tt.url); got != tt.want {
+			t.Errorf("Allows(%q) = %v, expected %v", tt.url, got, tt.want)
+		}
+	}
+
+	if f, _, _ := loadURLFilter(filepath.Join(t.TempDir(), "missing.conf")); f != nil || !f.Allows("anything") || !f.AllowsTrust(TrustExperimental) {
+		t.Errorf("loadURLFilter() of a missing file = %+v, expected nil allowing everything", f)
+	}
+}
+
+func TestLoadURLFilterMinTrust(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "url-filters.conf")
+	if err := os.WriteFile(path, []byte("min-trust vetted\nmin-trust Community\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _, warnings := loadURLFilter(path)
+	if f == nil || f.MinTrust != TrustCommunity {
+		t.Fatalf("loadURLFilter() = %+v, expected a filter with a minimum trust of community", f)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "unknown trust level") {
+		t.Errorf("warnings = %q, expected the unknown level", warnings)
+	}
+	if !f.Allows("https://example.com/a.json") {
+		t.Error("a trust-only filter should allow every URL by pattern")
+	}
+	for level, want := range map[string]bool{TrustTrusted: true, TrustCommunity: true, "": true, TrustExperimental: false} {
+		if got := f.AllowsTrust(level); got != want {
+			t.Errorf("AllowsTrust(%q) = %v, expected %v", level, got, want)
+		}
+	}
+}
+
+func TestCompileURLPattern(t *testing.T) {
+	re, err := compileURLPattern("https://a.example/?.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("https://a.example/x.json") || re.MatchString("https://a.example/xy.json") ||
+		re.MatchString("https://a.example/x.jsonp") {
+		t.Errorf("compileURLPattern() = %s", re)
+	}
+}
+
+func TestLoadURLFilterRedirectsOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "url-filters.conf")
+	if err := os.WriteFile(path, []byte("redirect old.example.org new.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, redirects, warnings := loadURLFilter(path)
+	if f != nil || len(warnings) != 0 {
+		t.Errorf("loadURLFilter() = %+v, %q; expected no filter or warning", f, warnings)
+	}
+	if redirects["old.example.org"] != "new.example.org" {
+		t.Errorf("redirects = %v, expected old.example.org to new.example.org", redirects)
+	}
+}
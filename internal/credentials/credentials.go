@@ -0,0 +1,132 @@
+// Package credentials resolves source tokens from the environment, files,
+// external commands, or the OS credential store, and logins from netrc
+// files, so secrets never live in basar's config file.
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds how long a token command may run.
+const CommandTimeout = 30 * time.Second
+
+// ErrInsecureFile indicates a token or netrc file is readable by group or
+// others.
+var ErrInsecureFile = errors.New("credentials file is accessible by group or others")
+
+// Spec describes where to read a token from. At most one field is expected
+// to be set; they are tried in the order Env, File, Cmd, Keyring.
+type Spec struct {
+	Env     string
+	File    string
+	Cmd     string
+	Keyring string
+}
+
+// IsZero reports whether no token source is configured.
+func (s Spec) IsZero() bool {
+	return s.Env == "" && s.File == "" && s.Cmd == "" && s.Keyring == ""
+}
+
+// Resolve returns the token described by spec, or "" if spec is empty.
+func Resolve(ctx context.Context, spec Spec) (string, error) {
+	switch {
+	case spec.Env != "":
+		return fromEnv(spec.Env)
+	case spec.File != "":
+		return fromFile(spec.File)
+	case spec.Cmd != "":
+		return fromCmd(ctx, spec.Cmd)
+	case spec.Keyring != "":
+		return Keyring(ctx, spec.Keyring)
+	}
+	return "", nil
+}
+
+// fromEnv reads a token from an environment variable.
+func fromEnv(name string) (string, error) {
+	token := strings.TrimSpace(os.Getenv(name))
+	if token == "" {
+		return "", fmt.Errorf("token variable %s is not set", name)
+	}
+	return token, nil
+}
+
+// fromFile reads a token from a file, refusing files other users can read.
+func fromFile(path string) (string, error) {
+	if strings.HasPrefix(path, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expanding home dir: %w", err)
+		}
+		path = home + path[1:]
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	if err := checkPrivate(path, info); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// checkPrivate refuses a credentials file other users can read.
+func checkPrivate(path string, info os.FileInfo) error {
+	// Windows has no meaningful Unix permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%w: %s (mode %04o, expected 0600)", ErrInsecureFile, path, info.Mode().Perm())
+	}
+	return nil
+}
+
+// fromCmd runs a command through the platform shell and returns the first
+// line of its output, matching the convention of `pass show`.
+func fromCmd(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("token command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("token command failed: %w", err)
+	}
+
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", errors.New("token command produced no output")
+	}
+	return token, nil
+}
@@ -0,0 +1,87 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveEmpty(t *testing.T) {
+	token, err := Resolve(context.Background(), Spec{})
+	if err != nil || token != "" {
+		t.Errorf("Resolve(empty) = %q, %v; expected no token", token, err)
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("BASAR_TEST_TOKEN", " secret \n")
+
+	token, err := Resolve(context.Background(), Spec{Env: "BASAR_TEST_TOKEN"})
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if token != "secret" {
+		t.Errorf("Resolve() = %q, expected %q", token, "secret")
+	}
+
+	if _, err := Resolve(context.Background(), Spec{Env: "BASAR_TEST_TOKEN_UNSET"}); err == nil {
+		t.Error("Resolve() should fail for an unset variable")
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+
+	if err := os.WriteFile(path, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	token, err := Resolve(context.Background(), Spec{File: path})
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if token != "file-secret" {
+		t.Errorf("Resolve() = %q, expected %q", token, "file-secret")
+	}
+}
+
+func TestResolveFileInsecure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+
+	_, err := Resolve(context.Background(), Spec{File: path})
+	if !errors.Is(err, ErrInsecureFile) {
+		t.Errorf("Resolve() error = %v, expected ErrInsecureFile", err)
+	}
+}
+
+func TestResolveCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	token, err := Resolve(context.Background(), Spec{Cmd: "printf 'cmd-secret\\nmetadata: x\\n'"})
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if token != "cmd-secret" {
+		t.Errorf("Resolve() = %q, expected first line %q", token, "cmd-secret")
+	}
+
+	if _, err := Resolve(context.Background(), Spec{Cmd: "exit 3"}); err == nil {
+		t.Error("Resolve() should fail when the command fails")
+	}
+}
@@ -0,0 +1,140 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoDockerLogin indicates the Docker config has no login for a
+// registry.
+var ErrNoDockerLogin = errors.New("no docker login")
+
+// dockerHubKey is the key Docker stores its Hub login under.
+const dockerHubKey = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of Docker's config.json naming logins.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// DockerConfigPath returns Docker's config file: config.json in
+// $DOCKER_CONFIG, or else in ~/.docker.
+func DockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating docker config: %w", err)
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+// Docker returns the login `docker login` or `oras login` stored for
+// registry, a host with or without a port: from the registry's credential
+// helper, else from the config file's auths, else from the default
+// credential store. Identity tokens are not supported.
+func Docker(ctx context.Context, registry string) (login, password string, err error) {
+	path, err := DockerConfigPath()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", fmt.Errorf("%w for %s", ErrNoDockerLogin, registry)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("reading docker config: %w", err)
+	}
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", "", fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	key := registry
+	if registry == "registry-1.docker.io" || registry == "docker.io" || registry == "index.docker.io" {
+		key = dockerHubKey
+	}
+	if helper := cfg.CredHelpers[key]; helper != "" {
+		return dockerHelper(ctx, helper, key)
+	}
+	for server, auth := range cfg.Auths {
+		if server != key && dockerServerHost(server) != key {
+			continue
+		}
+		if auth.Auth == "" {
+			if auth.Username != "" {
+				return auth.Username, auth.Password, nil
+			}
+			break
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("decoding docker login for %s: %w", registry, err)
+		}
+		login, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return "", "", fmt.Errorf("docker login for %s is not user:password", registry)
+		}
+		return login, password, nil
+	}
+	if cfg.CredsStore != "" {
+		return dockerHelper(ctx, cfg.CredsStore, key)
+	}
+	return "", "", fmt.Errorf("%w for %s in %s", ErrNoDockerLogin, registry, path)
+}
+
+// dockerServerHost strips the scheme and path Docker may store a server
+// with, e.g. https://ghcr.io/v1/.
+func dockerServerHost(server string) string {
+	_, rest, ok := strings.Cut(server, "://")
+	if !ok {
+		rest = server
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return host
+}
+
+// dockerHelper asks the credential helper docker-credential-NAME for the
+// login stored for server.
+func dockerHelper(ctx context.Context, name, server string) (login, password string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "docker-credential-"+name, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Helpers report an unknown server on stdout
+		if msg := strings.TrimSpace(string(out) + stderr.String()); strings.Contains(msg, "credentials not found") {
+			return "", "", fmt.Errorf("%w for %s in docker-credential-%s", ErrNoDockerLogin, server, name)
+		}
+		return "", "", fmt.Errorf("docker-credential-%s: %w", name, err)
+	}
+	var cred struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return "", "", fmt.Errorf("decoding docker-credential-%s output: %w", name, err)
+	}
+	if cred.Username == "<token>" {
+		return "", "", fmt.Errorf("docker-credential-%s holds an identity token for %s, which basar does not support", name, server)
+	}
+	return cred.Username, cred.Secret, nil
+}
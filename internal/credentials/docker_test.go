@@ -0,0 +1,77 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDocker(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	ctx := context.Background()
+
+	if _, _, err := Docker(ctx, "ghcr.io"); !errors.Is(err, ErrNoDockerLogin) {
+		t.Errorf("Docker() without a config error = %v, expected ErrNoDockerLogin", err)
+	}
+
+	config := `{"auths": {
+		"ghcr.io": {"auth": "YWxpY2U6czNjcmV0"},
+		"https://index.docker.io/v1/": {"auth": "Ym9iOmh1bnRlcjI="},
+		"https://registry.example.com/v2/": {"username": "carol", "password": "p"}
+	}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		registry, login, password string
+	}{
+		{"ghcr.io", "alice", "s3cret"},
+		{"registry-1.docker.io", "bob", "hunter2"},
+		{"registry.example.com", "carol", "p"},
+	}
+	for _, tt := range tests {
+		login, password, err := Docker(ctx, tt.registry)
+		if err != nil || login != tt.login || password != tt.password {
+			t.Errorf("Docker(%q) = %q, %q, %v; expected %q, %q", tt.registry, login, password, err, tt.login, tt.password)
+		}
+	}
+	if _, _, err := Docker(ctx, "other.example.com"); !errors.Is(err, ErrNoDockerLogin) {
+		t.Errorf("Docker() of an unknown registry error = %v, expected ErrNoDockerLogin", err)
+	}
+}
+
+func TestDockerHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helper is a shell script")
+	}
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	helper := `#!/bin/sh
+read server
+if [ "$server" = "registry.example.com" ]; then
+	echo '{"ServerURL": "registry.example.com", "Username": "dave", "Secret": "d"}'
+else
+	echo "credentials not found in native keychain"
+	exit 1
+fi
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker-credential-test"), []byte(helper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"credsStore": "test"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	login, password, err := Docker(context.Background(), "registry.example.com")
+	if err != nil || login != "dave" || password != "d" {
+		t.Errorf("Docker() = %q, %q, %v; expected the helper's login", login, password, err)
+	}
+	if _, _, err := Docker(context.Background(), "other.example.com"); !errors.Is(err, ErrNoDockerLogin) {
+		t.Errorf("Docker() of an unknown registry error = %v, expected ErrNoDockerLogin", err)
+	}
+}
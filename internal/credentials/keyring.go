@@ -0,0 +1,33 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyringService is the service basar's secrets are stored under in the OS
+// credential store.
+const KeyringService = "basar"
+
+// ErrNotInKeyring indicates the credential store holds no secret by that
+// name.
+var ErrNotInKeyring = errors.New("not in the credential store")
+
+// Keyring returns the secret stored as name in the OS credential store: the
+// login keychain on macOS (service basar, account NAME), the Secret Service
+// of GNOME Keyring or KWallet elsewhere on Unix (attributes service basar
+// and account NAME), or the Windows Credential Manager (generic credential
+// basar:NAME), which protects it with DPAPI.
+func Keyring(ctx context.Context, name string) (string, error) {
+	secret, err := keyringSecret(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("credential store secret %s: %w", name, err)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("credential store secret %s is empty", name)
+	}
+	return secret, nil
+}
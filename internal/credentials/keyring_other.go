@@ -0,0 +1,49 @@
+//go:build !windows
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringSecret looks name up with the credential store's command line
+// tool: security on macOS, secret-tool (libsecret) elsewhere.
+func keyringSecret(ctx context.Context, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", KeyringService, "-a", name, "-w")
+	} else {
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", KeyringService, "account", name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", fmt.Errorf("%s not found; install it to use the credential store", cmd.Args[0])
+	case errors.As(err, &exitErr) && stdout.Len() == 0 && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 44):
+		// secret-tool exits 1 and security 44 for missing items
+		return "", ErrNotInKeyring
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", cmd.Args[0], err)
+	}
+
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	return line, nil
+}
@@ -0,0 +1,51 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeSecretTool puts a secret-tool on PATH that knows one secret.
+func fakeSecretTool(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("uses secret-tool")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+[ "$1 $2 $3 $4" = "lookup service basar account" ] || { echo "bad args: $*" >&2; exit 2; }
+[ "$5" = internal-symbols ] || exit 1
+printf 'keyring-secret'
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestResolveKeyring(t *testing.T) {
+	fakeSecretTool(t)
+
+	token, err := Resolve(context.Background(), Spec{Keyring: "internal-symbols"})
+	if err != nil || token != "keyring-secret" {
+		t.Errorf("Resolve() = %q, %v; expected the stored secret", token, err)
+	}
+	if _, err := Keyring(context.Background(), "missing"); !errors.Is(err, ErrNotInKeyring) {
+		t.Errorf("Keyring() of a missing secret = %v, expected ErrNotInKeyring", err)
+	}
+}
+
+func TestKeyringWithoutTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the Credential Manager")
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	if _, err := Keyring(context.Background(), "internal-symbols"); err == nil {
+		t.Error("Keyring() should fail without the credential store tool")
+	}
+}
@@ -0,0 +1,84 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// advapi32 is loaded from the system directory, never the working one.
+var advapi32 = syscall.NewLazyDLL(filepath.Join(systemRoot(), "System32", "advapi32.dll"))
+
+var (
+	procCredRead = advapi32.NewProc("CredReadW")
+	procCredFree = advapi32.NewProc("CredFree")
+)
+
+func systemRoot() string {
+	if root := os.Getenv("SystemRoot"); root != "" {
+		return root
+	}
+	return `C:\Windows`
+}
+
+// Credential Manager constants, from wincred.h and winerror.h.
+const (
+	credTypeGeneric = 1
+	errorNotFound   = 1168
+)
+
+// credential mirrors CREDENTIALW.
+type credential struct {
+	flags          uint32
+	credType       uint32
+	targetName     *uint16
+	comment        *uint16
+	lastWritten    syscall.Filetime
+	blobSize       uint32
+	blob           *byte
+	persist        uint32
+	attributeCount uint32
+	attributes     uintptr
+	targetAlias    *uint16
+	userName       *uint16
+}
+
+// keyringSecret reads the generic credential basar:NAME, as stored by
+// `cmdkey /generic:basar:NAME /user:NAME /pass`, whose password is UTF-16.
+func keyringSecret(ctx context.Context, name string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(KeyringService + ":" + name)
+	if err != nil {
+		return "", err
+	}
+	if err := advapi32.Load(); err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	ok, _, callErr := procCredRead.Call(
+		uintptr(unsafe.Pointer(target)),
+		credTypeGeneric,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+	)
+	if ok == 0 {
+		if errno, isErrno := callErr.(syscall.Errno); isErrno && errno == errorNotFound {
+			return "", ErrNotInKeyring
+		}
+		return "", callErr
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.blob == nil || cred.blobSize < 2 {
+		return "", nil
+	}
+	blob := unsafe.Slice(cred.blob, cred.blobSize)
+	chars := make([]uint16, len(blob)/2)
+	for i := range chars {
+		chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(chars)), nil
+}
@@ -0,0 +1,115 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrNoNetrcEntry indicates the netrc file has no login for a host.
+var ErrNoNetrcEntry = errors.New("no netrc entry")
+
+// NetrcPath returns the netrc file: $NETRC, or else ~/.netrc, or on Windows
+// ~/_netrc when there is no ~/.netrc.
+func NetrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating netrc file: %w", err)
+	}
+	path := filepath.Join(home, ".netrc")
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(path); err != nil {
+			return filepath.Join(home, "_netrc"), nil
+		}
+	}
+	return path, nil
+}
+
+// Netrc returns the login and password the netrc file gives for host, a
+// host name with or without a port. The file must be private to the user.
+// A default entry is ignored, so credentials only go to the hosts the file
+// names.
+func Netrc(host string) (login, password string, err error) {
+	path, err := NetrcPath()
+	if err != nil {
+		return "", "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading netrc file: %w", err)
+	}
+	if err := checkPrivate(path, info); err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading netrc file: %w", err)
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	login, password, ok := parseNetrc(string(data), strings.Trim(host, "[]"))
+	if !ok {
+		return "", "", fmt.Errorf("%w for %s in %s", ErrNoNetrcEntry, host, path)
+	}
+	return login, password, nil
+}
+
+// parseNetrc returns the login and password of the first machine entry of
+// data naming host.
+func parseNetrc(data, host string) (login, password string, ok bool) {
+	var tokens []string
+	macro := false
+	for _, line := range strings.Split(data, "\n") {
+		if macro {
+			// A macro body runs to the next blank line
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				fields, macro = fields[:i], true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		var value string
+		if i+1 < len(tokens) {
+			value = tokens[i+1]
+		}
+		switch tokens[i] {
+		case "default":
+			return login, password, ok
+		case "machine":
+			if ok {
+				return login, password, true
+			}
+			ok = strings.EqualFold(value, host)
+		case "login":
+			if ok {
+				login = value
+			}
+		case "password":
+			if ok {
+				password = value
+			}
+		case "account":
+		default:
+			continue
+		}
+		i++
+	}
+	return login, password, ok
+}
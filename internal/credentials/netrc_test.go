@@ -0,0 +1,70 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const data = `# comments are not part of the format, but harmless
+machine symbols.corp.example login alice password s3cret
+machine proxy.corp.example
+	login bob
+	account ops
+	password hunter2
+macdef init
+machine evil.example login mallory password x
+
+machine Mirror.Example login carol password p
+default login anonymous password guest
+machine late.example login dave password d
+`
+	tests := []struct {
+		host            string
+		login, password string
+		ok              bool
+	}{
+		{"symbols.corp.example", "alice", "s3cret", true},
+		{"proxy.corp.example", "bob", "hunter2", true},
+		{"mirror.example", "carol", "p", true},
+		{"evil.example", "", "", false},
+		{"late.example", "", "", false},
+		{"other.example", "", "", false},
+	}
+	for _, tt := range tests {
+		login, password, ok := parseNetrc(data, tt.host)
+		if login != tt.login || password != tt.password || ok != tt.ok {
+			t.Errorf("parseNetrc(%q) = %q, %q, %v; expected %q, %q, %v",
+				tt.host, login, password, ok, tt.login, tt.password, tt.ok)
+		}
+	}
+}
+
+func TestNetrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte("machine symbols.corp.example login alice password s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", path)
+
+	login, password, err := Netrc("symbols.corp.example:8443")
+	if err != nil || login != "alice" || password != "s3cret" {
+		t.Errorf("Netrc() = %q, %q, %v; expected alice's login", login, password, err)
+	}
+	if _, _, err := Netrc("other.example"); !errors.Is(err, ErrNoNetrcEntry) {
+		t.Errorf("Netrc() of an unlisted host = %v, expected ErrNoNetrcEntry", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Netrc("symbols.corp.example"); !errors.Is(err, ErrInsecureFile) {
+		t.Errorf("Netrc() of a readable file = %v, expected ErrInsecureFile", err)
+	}
+}
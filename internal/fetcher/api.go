@@ -0,0 +1,169 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited indicates an API quota is exhausted and no cached response
+// is available to serve instead.
+var ErrRateLimited = errors.New("API rate limit exceeded")
+
+// APIResponse is a cached API response body with its validator.
+type APIResponse struct {
+	ETag      string    `json:"etag,omitempty"`
+	Body      []byte    `json:"body"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// RateLimit records the last known API quota for a host.
+type RateLimit struct {
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// APICache stores API responses and rate-limit state for API-based source
+// types, persisted in meta.json so scheduled runs reuse it.
+type APICache struct {
+	mu         sync.Mutex
+	Responses  map[string]APIResponse `json:"responses,omitempty"`
+	RateLimits map[string]RateLimit   `json:"rate_limits,omitempty"`
+}
+
+// NewAPICache returns an empty APICache.
+func NewAPICache() *APICache {
+	return &APICache{
+		Responses:  make(map[string]APIResponse),
+		RateLimits: make(map[string]RateLimit),
+	}
+}
+
+// init makes the maps usable after JSON decoding of an older meta file.
+func (a *APICache) init() {
+	if a.Responses == nil {
+		a.Responses = make(map[string]APIResponse)
+	}
+	if a.RateLimits == nil {
+		a.RateLimits = make(map[string]RateLimit)
+	}
+}
+
+// lookup returns the cached response and the host's quota state.
+func (a *APICache) lookup(rawURL, host string) (APIResponse, bool, RateLimit, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	resp, hasResp := a.Responses[rawURL]
+	limit, hasLimit := a.RateLimits[host]
+	return resp, hasResp, limit, hasLimit
+}
+
+// store records a fresh response for rawURL.
+func (a *APICache) store(rawURL string, resp APIResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	a.Responses[rawURL] = resp
+}
+
+// setLimit records the quota state for host.
+func (a *APICache) setLimit(host string, limit RateLimit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	a.RateLimits[host] = limit
+}
+
+// GetAPI performs a GET against a JSON API using the response cache. Cached
+// bodies are revalidated with If-None-Match, served as-is while the host's
+// quota is exhausted, and quota headers (X-RateLimit-*, Retry-After) are
+// recorded for the next call.
+func (f *Fetcher) GetAPI(ctx context.Context, api *APICache, rawURL string, header http.Header) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing API URL: %w", err)
+	}
+
+	cached, hasCached, limit, hasLimit := api.lookup(rawURL, u.Host)
+	if hasLimit && limit.Remaining == 0 && time.Now().Before(limit.Reset) {
+		if hasCached {
+			return cached.Body, nil
+		}
+		return nil, fmt.Errorf("%w for %s until %s", ErrRateLimited, u.Host, limit.Reset.Format(time.RFC3339))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if hasCached && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	l, hasNewLimit := parseRateLimit(resp.Header, time.Now())
+	if hasNewLimit {
+		api.setLimit(u.Host, l)
+	}
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && hasNewLimit && l.Remaining == 0)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		return cached.Body, nil
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+		api.store(rawURL, APIResponse{
+			ETag:      resp.Header.Get("ETag"),
+			Body:      body,
+			FetchedAt: time.Now(),
+		})
+		return body, nil
+	case limited:
+		if hasCached {
+			return cached.Body, nil
+		}
+		return nil, fmt.Errorf("%w for %s (status %d)", ErrRateLimited, u.Host, resp.StatusCode)
+	default:
+		return nil, &SourceError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+}
+
+// parseRateLimit extracts quota state from GitHub-style rate-limit headers or
+// a Retry-After header.
+func parseRateLimit(h http.Header, now time.Time) (RateLimit, bool) {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs > 0 {
+		return RateLimit{Remaining: 0, Reset: now.Add(time.Duration(secs) * time.Second)}, true
+	}
+
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+
+	limit := RateLimit{Remaining: remaining}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		limit.Reset = time.Unix(reset, 0)
+	}
+
+	return limit, true
+}
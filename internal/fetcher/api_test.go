@@ -0,0 +1,150 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetAPICachesWithETag(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"tree-v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"tree-v1"`)
+		_, _ = w.Write([]byte(`{"files":["banners.json"]}`))
+	}))
+	defer server.Close()
+
+	f := New()
+	api := NewAPICache()
+	ctx := context.Background()
+
+	first, err := f.GetAPI(ctx, api, server.URL+"/tree", nil)
+	if err != nil {
+		t.Fatalf("first GetAPI() failed: %v", err)
+	}
+
+	second, err := f.GetAPI(ctx, api, server.URL+"/tree", nil)
+	if err != nil {
+		t.Fatalf("second GetAPI() failed: %v", err)
+	}
+
+	if string(first) != string(second) {
+		t.Errorf("304 should serve the cached body, got %q then %q", first, second)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestGetAPIRespectsRateLimit(t *testing.T) {
+	var requests int32
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		_, _ = w.Write([]byte(`{"page":1}`))
+	}))
+	defer server.Close()
+
+	f := New()
+	api := NewAPICache()
+	ctx := context.Background()
+
+	if _, err := f.GetAPI(ctx, api, server.URL+"/a", nil); err != nil {
+		t.Fatalf("GetAPI() failed: %v", err)
+	}
+
+	// Quota is exhausted: cached URL is served locally, uncached one fails
+	body, err := f.GetAPI(ctx, api, server.URL+"/a", nil)
+	if err != nil || string(body) != `{"page":1}` {
+		t.Errorf("GetAPI() = %q, %v; expected cached body", body, err)
+	}
+
+	if _, err := f.GetAPI(ctx, api, server.URL+"/b", nil); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("GetAPI() error = %v, expected ErrRateLimited", err)
+	}
+
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("no requests should be sent while rate limited, got %d", requests)
+	}
+}
+
+func TestGetAPIForbiddenWithoutQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := New().GetAPI(context.Background(), NewAPICache(), server.URL, nil)
+	if err == nil || errors.Is(err, ErrRateLimited) {
+		t.Errorf("plain 403 should be an ordinary error, got %v", err)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name      string
+		header    map[string]string
+		wantOK    bool
+		remaining int
+		reset     time.Time
+	}{
+		{"no headers", map[string]string{}, false, 0, time.Time{}},
+		{"retry-after", map[string]string{"Retry-After": "60"}, true, 0, now.Add(time.Minute)},
+		{
+			"github headers",
+			map[string]string{"X-RateLimit-Remaining": "42", "X-RateLimit-Reset": "2000"},
+			true, 42, time.Unix(2000, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+
+			limit, ok := parseRateLimit(h, now)
+			if ok != tt.wantOK {
+				t.Fatalf("parseRateLimit() ok = %v, expected %v", ok, tt.wantOK)
+			}
+			if ok && (limit.Remaining != tt.remaining || !limit.Reset.Equal(tt.reset)) {
+				t.Errorf("parseRateLimit() = %+v, expected remaining=%d reset=%v", limit, tt.remaining, tt.reset)
+			}
+		})
+	}
+}
+
+func TestAPICachePersists(t *testing.T) {
+	meta := &MetaCache{Sources: map[string]SourceMeta{}, API: NewAPICache()}
+	meta.API.store("https://api.github.com/x", APIResponse{ETag: `"e"`, Body: []byte(`[]`)})
+
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var decoded MetaCache
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if decoded.API == nil || decoded.API.Responses["https://api.github.com/x"].ETag != `"e"` {
+		t.Errorf("API cache not round-tripped: %+v", decoded.API)
+	}
+}
@@ -0,0 +1,104 @@
+package fetcher
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only track where a link was
+// clicked, dropped from symbol URLs along with any utm_ parameter.
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+	"mc_cid": true,
+	"mc_eid": true,
+}
+
+// CanonicalURL returns rawURL in the form used to deduplicate symbol URLs:
+// scheme and host in lowercase, without a default port, a fragment,
+// tracking parameters, or an empty query. A host listed in redirects is
+// replaced by its target, a host or a scheme://host naming where it
+// redirects to. URLs other than http and https ones are returned as is.
+func CanonicalURL(rawURL string, redirects map[string]string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.Opaque != "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return rawURL
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if target, ok := redirects[u.Host]; ok {
+		if scheme, host, ok := strings.Cut(target, "://"); ok {
+			u.Scheme, u.Host = strings.ToLower(scheme), host
+		} else {
+			u.Host = target
+		}
+	}
+
+	u.Fragment, u.RawFragment = "", ""
+	u.ForceQuery = false
+	u.RawQuery = stripTracking(u.RawQuery)
+	return u.String()
+}
+
+// stripTracking removes the tracking and empty parameters of a raw query,
+// keeping the others in order and as encoded.
+func stripTracking(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		name = strings.ToLower(name)
+		if param == "" || trackingParams[name] || strings.HasPrefix(name, "utm_") {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
+
+// urlKey returns the key deduplicating a canonical URL, the same for its
+// http and https forms, and whether it is the https form.
+func urlKey(canonical string) (key string, https bool) {
+	if rest, ok := strings.CutPrefix(canonical, "https://"); ok {
+		return "//" + rest, true
+	}
+	if rest, ok := strings.CutPrefix(canonical, "http://"); ok {
+		return "//" + rest, false
+	}
+	return canonical, false
+}
+
+// appendCanonical appends the canonical forms of urls to existing,
+// skipping duplicates. Where a URL is listed over both http and https, the
+// https form is kept, in the place of whichever came first.
+func appendCanonical(existing, urls []string, redirects map[string]string) []string {
+	index := make(map[string]int, len(existing)+len(urls))
+	for i, v := range existing {
+		key, _ := urlKey(v)
+		index[key] = i
+	}
+
+	result := existing
+	for _, v := range urls {
+		v = CanonicalURL(v, redirects)
+		key, https := urlKey(v)
+		i, ok := index[key]
+		switch {
+		case !ok:
+			index[key] = len(result)
+			result = append(result, v)
+		case https:
+			result[i] = v
+		}
+	}
+	return result
+}
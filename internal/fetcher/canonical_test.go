@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	redirects := map[string]string{
+		"old.example.org":      "isf.example.org",
+		"plain.example.org:81": "https://isf.example.org",
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTPS://ISF.Example.org/Ubuntu/5.15.json.xz", "https://isf.example.org/Ubuntu/5.15.json.xz"},
+		{"https://isf.example.org:443/a.json", "https://isf.example.org/a.json"},
+		{"http://isf.example.org:80/a.json", "http://isf.example.org/a.json"},
+		{"http://isf.example.org:443/a.json", "http://isf.example.org:443/a.json"},
+		{"https://isf.example.org/a.json#section", "https://isf.example.org/a.json"},
+		{"https://isf.example.org/a.json?", "https://isf.example.org/a.json"},
+		{"https://isf.example.org/a.json?utm_source=x&raw=1&&fbclid=y", "https://isf.example.org/a.json?raw=1"},
+		{"https://isf.example.org/a.json?b=2&a=1", "https://isf.example.org/a.json?b=2&a=1"},
+		{"https://old.example.org/a.json", "https://isf.example.org/a.json"},
+		{"http://plain.example.org:81/a.json", "https://isf.example.org/a.json"},
+		{"file:///srv/isf/a.json", "file:///srv/isf/a.json"},
+		{"url1", "url1"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalURL(tt.in, redirects); got != tt.want {
+			t.Errorf("CanonicalURL(%q) = %q, expected %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMergeCanonical(t *testing.T) {
+	datasets := []*BannerData{
+		{Version: 1, Linux: map[string][]string{"banner1": {
+			"http://isf.example.org/a.json",
+			"https://mirror.example.org/a.json",
+			"http://other.example.org/a.json",
+		}}},
+		{Version: 1, Linux: map[string][]string{"banner1": {
+			"https://ISF.example.org:443/a.json?utm_medium=feed",
+			"http://mirror.example.org/a.json",
+			"https://old.example.org/a.json",
+		}}},
+	}
+
+	merged, _ := MergeSources(nil, datasets, map[string]string{"old.example.org": "other.example.org"})
+	want := []string{
+		"https://isf.example.org/a.json",
+		"https://mirror.example.org/a.json",
+		"https://other.example.org/a.json",
+	}
+	if got := merged.Linux["banner1"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged URLs = %q, expected %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// commandPrefix starts the sources read from the output of a local
+// command, e.g. command:///usr/local/bin/make-banners?arg=--all.
+const commandPrefix = "command://"
+
+// IsCommand reports whether source is a command source.
+func IsCommand(source string) bool {
+	return strings.HasPrefix(source, commandPrefix)
+}
+
+// parseCommandSource returns the executable of a command source, an
+// absolute path, and the arguments its arg= parameters give.
+func parseCommandSource(source string) (string, []string, error) {
+	rest, query, _ := strings.Cut(strings.TrimPrefix(source, commandPrefix), "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %w", ErrConfiguration, source, err)
+	}
+	path, err := expandHome(localPath("file://" + rest))
+	if err != nil {
+		return "", nil, err
+	}
+	if !filepath.IsAbs(path) {
+		return "", nil, fmt.Errorf("%w: %s: expected an absolute path to the command", ErrConfiguration, source)
+	}
+	return path, params["arg"], nil
+}
+
+// fetchCommand runs the command of a command source and decodes its
+// standard output as a banner index. The output's digest stands in for an
+// ETag, so an unchanged output is not merged again. Commands run offline
+// too, with BASAR_OFFLINE=1 set, as basar cannot tell what they reach.
+func (f *Fetcher) fetchCommand(ctx context.Context, source string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
+	path, args, err := parseCommandSource(source)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.clientFor(source).Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	if f.offline {
+		cmd.Env = append(os.Environ(), "BASAR_OFFLINE=1")
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return nil, nil, false, fmt.Errorf("%w: %s: %w", ErrConfiguration, source, err)
+	}
+	if err != nil && ctx.Err() != nil {
+		// Killed for running past the source's timeout
+		return nil, nil, false, fmt.Errorf("%s: %w", filepath.Base(path), ctx.Err())
+	}
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, nil, false, fmt.Errorf("%s: %s", filepath.Base(path), msg)
+		}
+		return nil, nil, false, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	now := time.Now()
+	digest := ociDigest(out)
+	if meta != nil && meta.ETag == digest {
+		meta.FetchedAt = now
+		meta.Status = StatusNotModified
+		meta.Error = ""
+		meta.Bytes = 0
+		return nil, meta, false, nil
+	}
+	data, err := decodeIndex(bytes.NewReader(out), f.formatOf(source), "", filepath.Base(path))
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("decoding output: %w", err)
+	}
+	return data, &SourceMeta{
+		ETag:      digest,
+		UpdatedAt: now,
+		FetchedAt: now,
+		Status:    StatusOK,
+		Entries:   len(data.Linux),
+		Bytes:     int64(len(out)),
+	}, true, nil
+}
+
+// probeCommand checks that the command of a command source is an
+// executable file, without running it.
+func probeCommand(source string) error {
+	path, _, err := parseCommandSource(source)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("%w: %s is not executable", ErrConfiguration, path)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeCommand writes an executable shell script, returning its command
+// source.
+func writeCommand(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts need a Unix shell")
+	}
+	path := filepath.Join(t.TempDir(), "make-banners")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return "command://" + path
+}
+
+func TestParseCommandSource(t *testing.T) {
+	path, args, err := parseCommandSource("command:///usr/local/bin/make-banners?arg=--region&arg=eu")
+	if err != nil || path != filepath.FromSlash("/usr/local/bin/make-banners") || strings.Join(args, " ") != "--region eu" {
+		t.Errorf("parseCommandSource() = %q, %q, %v", path, args, err)
+	}
+	if _, _, err := parseCommandSource("command://make-banners"); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("parseCommandSource(relative) error = %v, expected a configuration error", err)
+	}
+	if !IsCommand("command:///bin/true") || IsCommand("/bin/true") {
+		t.Error("IsCommand() misclassifies sources")
+	}
+}
+
+func TestFetchCommand(t *testing.T) {
+	source := writeCommand(t, `echo '{"version": 1, "linux": {"'"$1"'": ["https://example.com/1.json"]}}'`+"\n")
+	source += "?arg=Linux+version+6.1.0"
+
+	f := New()
+	ctx := context.Background()
+	data, meta, modified, err := f.FetchWithMeta(ctx, source, nil)
+	if err != nil {
+		t.Fatalf("FetchWithMeta() failed: %v", err)
+	}
+	if !modified || len(data.Linux["Linux version 6.1.0"]) != 1 || !strings.HasPrefix(meta.ETag, "sha256:") {
+		t.Errorf("FetchWithMeta() = %v, %+v, %v; expected the banner the command printed", data.Linux, meta, modified)
+	}
+
+	// The same output is not merged again
+	data, meta, modified, err = f.FetchWithMeta(ctx, source, meta)
+	if err != nil || modified || data != nil || meta.Status != StatusNotModified {
+		t.Errorf("rerun = %v, %+v, %v, %v; expected not modified", data, meta, modified, err)
+	}
+
+	// Commands run offline, told so
+	offline := writeCommand(t, `echo '{"version": 1, "linux": {"'"$BASAR_OFFLINE"'": ["https://example.com/1.json"]}}'`+"\n")
+	f.SetOffline(true)
+	if data, err := f.Fetch(ctx, offline); err != nil || data.Linux["1"] == nil {
+		t.Errorf("Fetch() offline = %v, %v; expected BASAR_OFFLINE=1 set", data, err)
+	}
+	if err := f.Probe(ctx, source); err != nil {
+		t.Errorf("Probe() failed: %v", err)
+	}
+}
+
+func TestFetchCommandErrors(t *testing.T) {
+	f := New()
+	ctx := context.Background()
+
+	failing := writeCommand(t, "echo 'no credentials for the symbol store' >&2\nexit 3\n")
+	if _, err := f.Fetch(ctx, failing); err == nil || !strings.Contains(err.Error(), "no credentials for the symbol store") {
+		t.Errorf("Fetch() of a failing command error = %v, expected its stderr", err)
+	}
+
+	garbage := writeCommand(t, "echo '<html>login</html>'\n")
+	if _, err := f.Fetch(ctx, garbage); err == nil || !strings.Contains(err.Error(), "decoding output") {
+		t.Errorf("Fetch() of non-index output error = %v", err)
+	}
+
+	missing := "command://" + filepath.Join(t.TempDir(), "missing")
+	if _, err := f.Fetch(ctx, missing); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("Fetch() of a missing command error = %v, expected a configuration error", err)
+	}
+	if err := f.Probe(ctx, missing); err == nil {
+		t.Error("Probe() of a missing command succeeded")
+	}
+
+	notExec := filepath.Join(t.TempDir(), "banners.json")
+	if err := os.WriteFile(notExec, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Probe(ctx, "command://"+notExec); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("Probe() of a non-executable error = %v, expected a configuration error", err)
+	}
+}
@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CountBanners validates a banner index read from r and returns the number
+// of banners in it. Unlike decoding into BannerData it streams the document
+// token by token, so memory use does not grow with the index size.
+func CountBanners(r io.Reader) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		if key, _ := tok.(string); key == "linux" {
+			n, err := countKeys(dec)
+			if err != nil {
+				return 0, fmt.Errorf("linux: %w", err)
+			}
+			count = n
+			continue
+		}
+		if err := skipValue(dec); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return 0, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return 0, errors.New("unexpected data after banner index")
+	}
+
+	return count, nil
+}
+
+// countKeys counts the keys of the object (or null) at the decoder's
+// position, skipping their values.
+func countKeys(dec *json.Decoder) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	if tok == nil {
+		return 0, nil
+	}
+	if tok != json.Delim('{') {
+		return 0, fmt.Errorf("expected object, got %v", tok)
+	}
+
+	n := 0
+	for dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+		if err := skipValue(dec); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	return n, expectDelim(dec, '}')
+}
+
+// skipValue consumes the next value, including nested arrays and objects.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// expectDelim consumes the next token, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// ScanBanners streams a banner index read from r, calling fn with each
+// banner and the byte offset and length of its URL list in the document,
+// so callers can later read single entries without decoding the rest.
+func ScanBanners(r io.Reader, fn func(banner string, offset, length int64) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := tok.(string); key != "linux" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('{') {
+			return fmt.Errorf("linux: expected object, got %v", tok)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			banner, _ := tok.(string)
+
+			// RawMessage keeps the value's bytes verbatim, so its end
+			// offset minus its length is where it starts
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("linux: %w", err)
+			}
+			end := dec.InputOffset()
+			if err := fn(banner, end-int64(len(raw)), int64(len(raw))); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
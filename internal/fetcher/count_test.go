@@ -0,0 +1,107 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCountBanners(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"two banners", `{"version":1,"linux":{"a":["u1","u2"],"b":["u3"]}}`, 2, false},
+		{"linux first", `{"linux":{"a":[]},"version":1}`, 1, false},
+		{"nested extra keys", `{"meta":{"x":[{"y":[1,2]}]},"linux":{"a":["u"]}}`, 1, false},
+		{"no linux", `{"version":1}`, 0, false},
+		{"null linux", `{"linux":null}`, 0, false},
+		{"empty", ``, 0, true},
+		{"truncated", `{"linux":{"a":["u"]`, 0, true},
+		{"not an object", `[1,2]`, 0, true},
+		{"linux not an object", `{"linux":["a"]}`, 0, true},
+		{"trailing data", `{"linux":{}} {}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CountBanners(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CountBanners() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CountBanners() = %d, expected %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// bannerIndex returns an encoded index with n banners.
+func bannerIndex(n int) string {
+	data := &BannerData{Version: 1, Linux: make(map[string][]string, n)}
+	for i := 0; i < n; i++ {
+		data.Linux[fmt.Sprintf("Linux version 5.%d.0-generic", i)] = []string{
+			fmt.Sprintf("https://example.com/symbols/5.%d.0.json.xz", i),
+		}
+	}
+	raw, _ := json.Marshal(data)
+	return string(raw)
+}
+
+func TestCountBannersMatchesDecode(t *testing.T) {
+	index := bannerIndex(1000)
+
+	var data BannerData
+	if err := json.Unmarshal([]byte(index), &data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := CountBanners(strings.NewReader(index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != len(data.Linux) {
+		t.Errorf("CountBanners() = %d, decode found %d", got, len(data.Linux))
+	}
+}
+
+func BenchmarkCountBanners(b *testing.B) {
+	index := bannerIndex(10000)
+	b.SetBytes(int64(len(index)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := CountBanners(strings.NewReader(index)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestScanBanners(t *testing.T) {
+	index := `{"version":1, "linux": {"a": ["u1", "u\"2"],"b":[] }, "extra":{"x":[1]}}`
+
+	got := make(map[string]string)
+	err := ScanBanners(strings.NewReader(index), func(banner string, offset, length int64) error {
+		got[banner] = index[offset : offset+length]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a": `["u1", "u\"2"]`, "b": `[]`}
+	if len(got) != len(want) {
+		t.Fatalf("ScanBanners() found %v, expected %v", got, want)
+	}
+	for banner, raw := range want {
+		if got[banner] != raw {
+			t.Errorf("value of %q = %q, expected %q", banner, got[banner], raw)
+		}
+	}
+
+	if err := ScanBanners(strings.NewReader(`{"linux":{"a":["u"]`), func(string, int64, int64) error { return nil }); err == nil {
+		t.Error("truncated index should fail")
+	}
+}
@@ -4,12 +4,17 @@ package fetcher
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,26 +22,134 @@ const (
 	// HTTPTimeout is the default timeout for HTTP requests.
 	HTTPTimeout = 30 * time.Second
 
-	// UserAgent identifies this tool in HTTP requests.
-	UserAgent = "basar/1.0"
+	// DownloadTimeout bounds a Download; symbol files can be far larger
+	// than indexes.
+	DownloadTimeout = 10 * time.Minute
 )
 
+// UserAgent identifies this tool in HTTP requests. The command sets it to
+// include the version of the build.
+var UserAgent = "basar/dev"
+
 // BannerData represents the volatility3 ISF banner format.
 type BannerData struct {
 	Version int                 `json:"version"`
 	Linux   map[string][]string `json:"linux"`
+
+	// Metadata holds the extra per-banner fields a source lists, if any.
+	// Merging drops it, so it never reaches the file volatility3 reads.
+	Metadata Metadata `json:"metadata,omitempty"`
 }
 
-// SourceMeta stores metadata for conditional requests.
+// Metadata maps banners to the extra fields some indexes list for them,
+// such as the symbol file size, build id, or compiler, kept verbatim since
+// basar does not interpret them.
+type Metadata map[string]map[string]json.RawMessage
+
+// UnmarshalJSON decodes metadata leniently: an index using the field for
+// something else, or a banner whose entry is not an object, is skipped
+// rather than failing the whole source.
+func (m *Metadata) UnmarshalJSON(raw []byte) error {
+	var banners map[string]json.RawMessage
+	if json.Unmarshal(raw, &banners) != nil {
+		return nil
+	}
+	for banner, entry := range banners {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(entry, &fields) != nil || len(fields) == 0 {
+			continue
+		}
+		if *m == nil {
+			*m = make(Metadata)
+		}
+		(*m)[banner] = fields
+	}
+	return nil
+}
+
+// Source fetch statuses recorded in SourceMeta.
+const (
+	StatusOK          = "ok"
+	StatusNotModified = "not_modified"
+	StatusError       = "error"
+)
+
+// SourceMeta stores metadata for conditional requests and the outcome of
+// the most recent fetch.
 type SourceMeta struct {
 	ETag         string    `json:"etag,omitempty"`
 	LastModified string    `json:"last_modified,omitempty"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	FetchedAt    time.Time `json:"fetched_at,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Entries      int       `json:"entries,omitempty"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	Failures     int       `json:"failures,omitempty"`
+	Quarantined  int       `json:"quarantined,omitempty"` // Banners dropped for straying from the schema
+	Commit       string    `json:"commit,omitempty"`      // Commit a git source was read from
+
+	// EntryHistory holds the entry counts of the source's recent fetches
+	// that changed it, oldest first.
+	EntryHistory []int `json:"entry_history,omitempty"`
+}
+
+// UpdateStatus records the outcome of the last cache update.
+type UpdateStatus struct {
+	At      time.Time `json:"at"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// MarshalJSON adds at_unix, the time in Unix seconds, to the encoded status.
+func (s UpdateStatus) MarshalJSON() ([]byte, error) {
+	type updateStatus UpdateStatus
+	var at int64
+	if !s.At.IsZero() {
+		at = s.At.Unix()
+	}
+	return json.Marshal(struct {
+		updateStatus
+		AtUnix int64 `json:"at_unix,omitempty"`
+	}{updateStatus(s), at})
 }
 
 // MetaCache stores metadata for all sources.
 type MetaCache struct {
-	Sources map[string]SourceMeta `json:"sources"`
+	Sources    map[string]SourceMeta `json:"sources"`
+	API        *APICache             `json:"api,omitempty"`
+	LastUpdate *UpdateStatus         `json:"last_update,omitempty"`
+
+	// Generation counts updates that changed the cache.
+	Generation uint64 `json:"generation,omitempty"`
+
+	// UpdatedAt is when the cache file was written and Checksum its
+	// SHA-256 in hex. Older versions did not record them.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+
+	// Vol3Compat is the volatility3 version the cache was tailored to,
+	// empty for none.
+	Vol3Compat string `json:"vol3_compat,omitempty"`
+
+	// Overrides is the SHA-256 of the overrides file applied to the cache
+	// in hex, empty for none.
+	Overrides string `json:"overrides,omitempty"`
+
+	// Filters is the SHA-256 of the URL and banner filters files the cache
+	// was filtered with in hex, empty for none, and Filtered what they
+	// removed.
+	Filters  string        `json:"filters,omitempty"`
+	Filtered *FilterCounts `json:"filtered,omitempty"`
+}
+
+// FilterCounts counts what filters removed from a merge: the banners
+// banner filters excluded, the symbol URLs URL filters dropped, and the
+// banners left without any URL.
+type FilterCounts struct {
+	Excluded int `json:"excluded"`
+	URLs     int `json:"urls"`
+	Banners  int `json:"banners"`
 }
 
 // Result contains the fetch result for a single source.
@@ -46,11 +159,89 @@ type Result struct {
 	Meta     *SourceMeta
 	Modified bool // true if content changed, false if 304 Not Modified
 	Err      error
+
+	// Quarantine holds the banners dropped from Data for straying from
+	// the schema, nil if none were.
+	Quarantine *Quarantine
 }
 
+// TokenFunc returns the bearer token for a source, or "" for none.
+type TokenFunc func(ctx context.Context, source string) (string, error)
+
+// AuthFunc returns the Authorization header authenticating a source to the
+// server on host, e.g. "Negotiate TOKEN" or "Basic CREDENTIALS", or "" if
+// the source authenticates with a bearer token or not at all.
+type AuthFunc func(ctx context.Context, source, host string) (string, error)
+
+// ProxyAuthFunc returns the Proxy-Authorization header authenticating to
+// the proxy on host.
+type ProxyAuthFunc func(ctx context.Context, host string) (string, error)
+
+// TimeoutFunc returns the HTTP timeout for a source, or 0 for HTTPTimeout.
+type TimeoutFunc func(source string) time.Duration
+
+// PagingFunc returns how a source paginates its responses.
+type PagingFunc func(source string) Paging
+
 // Fetcher fetches banner data from multiple sources.
 type Fetcher struct {
-	client *http.Client
+	client    *http.Client
+	transport *http.Transport
+	token     TokenFunc
+	auth      AuthFunc
+	timeout   TimeoutFunc
+	paging    PagingFunc
+	format    FormatFunc
+	schema    SchemaFunc
+	githubAPI string
+	jobs      int
+	failFast  bool
+	offline   bool
+	progress  ProgressFunc
+
+	stdin     io.Reader
+	stdinOnce sync.Once
+	stdinData []byte
+	stdinErr  error
+}
+
+// ErrConfiguration marks fetch errors caused by configuration rather than
+// transient conditions: rejected credentials, unusable tokens, or invalid
+// URLs. Retrying will not help.
+var ErrConfiguration = errors.New("configuration error")
+
+// ErrOffline is returned for network sources and URLs in offline mode.
+var ErrOffline = errors.New("offline mode forbids network access")
+
+// IsTimeout reports whether err is a fetch that ran past its deadline: the
+// source's timeout, or that of the context it ran under.
+func IsTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &t) && t.Timeout()
+}
+
+// SourceError is an error status returned by an HTTP source. Statuses 401
+// and 403 mean the source rejected its credentials, so they match
+// ErrConfiguration with errors.Is.
+type SourceError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *SourceError) Error() string {
+	if e.denied() {
+		return fmt.Sprintf("%v: access denied (status %d)", ErrConfiguration, e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status: %d", e.StatusCode)
+}
+
+// Is reports whether the error is ErrConfiguration.
+func (e *SourceError) Is(target error) bool {
+	return target == ErrConfiguration && e.denied()
+}
+
+func (e *SourceError) denied() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
 }
 
 // New creates a new Fetcher with default HTTP client.
@@ -62,41 +253,221 @@ func New() *Fetcher {
 	}
 }
 
+// SetTokenFunc sets how bearer tokens are resolved for HTTP sources.
+func (f *Fetcher) SetTokenFunc(fn TokenFunc) {
+	f.token = fn
+}
+
+// SetAuthFunc sets how Authorization headers other than bearer tokens are
+// resolved for HTTP sources; they take precedence over bearer tokens.
+func (f *Fetcher) SetAuthFunc(fn AuthFunc) {
+	f.auth = fn
+}
+
+// SetTimeoutFunc sets how per-source HTTP timeouts are resolved.
+func (f *Fetcher) SetTimeoutFunc(fn TimeoutFunc) {
+	f.timeout = fn
+}
+
+// SetPagingFunc sets how per-source pagination is resolved. Link headers
+// are followed regardless.
+func (f *Fetcher) SetPagingFunc(fn PagingFunc) {
+	f.paging = fn
+}
+
+// SetSchemaFunc sets how fetched sources are checked against the banner
+// index schema by FetchAllWithMeta. Without it nothing is checked.
+func (f *Fetcher) SetSchemaFunc(fn SchemaFunc) {
+	f.schema = fn
+}
+
+// SetProxy sets how the proxy of each request is chosen, instead of from
+// the http_proxy and https_proxy environment variables.
+func (f *Fetcher) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	f.ownTransport().Proxy = proxy
+}
+
+// SetProxyAuth authenticates to proxies with the Proxy-Authorization
+// headers fn returns: on the CONNECT requests tunneling https, and on the
+// http requests sent through a proxy.
+func (f *Fetcher) SetProxyAuth(fn ProxyAuthFunc) {
+	transport := f.ownTransport()
+	transport.GetProxyConnectHeader = func(ctx context.Context, proxyURL *url.URL, _ string) (http.Header, error) {
+		auth, err := fn(ctx, proxyURL.Host)
+		if err != nil {
+			return nil, fmt.Errorf("proxy authentication: %w", err)
+		}
+		return http.Header{"Proxy-Authorization": {auth}}, nil
+	}
+	f.client.Transport = &proxyAuthTransport{base: transport, auth: fn}
+}
+
+// ownTransport returns the fetcher's own transport, a copy of the default
+// one made on first use.
+func (f *Fetcher) ownTransport() *http.Transport {
+	if f.transport == nil {
+		f.transport = http.DefaultTransport.(*http.Transport).Clone()
+		f.client.Transport = f.transport
+	}
+	return f.transport
+}
+
+// proxyAuthTransport adds a Proxy-Authorization header to the plain http
+// requests its base transport sends through a proxy; https requests get
+// theirs on the CONNECT request.
+type proxyAuthTransport struct {
+	base *http.Transport
+	auth ProxyAuthFunc
+}
+
+func (t *proxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" || t.base.Proxy == nil {
+		return t.base.RoundTrip(req)
+	}
+	proxy, err := t.base.Proxy(req)
+	if err != nil || proxy == nil {
+		return t.base.RoundTrip(req)
+	}
+	auth, err := t.auth(req.Context(), proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("proxy authentication: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Proxy-Authorization", auth)
+	return t.base.RoundTrip(req)
+}
+
+// SetJobs limits how many sources FetchAllWithMeta fetches at once; zero or
+// less means no limit.
+func (f *Fetcher) SetJobs(jobs int) {
+	f.jobs = jobs
+}
+
+// clientFor returns the HTTP client for source, honoring its timeout.
+func (f *Fetcher) clientFor(source string) *http.Client {
+	if f.timeout == nil {
+		return f.client
+	}
+	timeout := f.timeout(source)
+	if timeout <= 0 || timeout == f.client.Timeout {
+		return f.client
+	}
+	client := *f.client
+	client.Timeout = timeout
+	return &client
+}
+
+// SetFailFast makes FetchAllWithMeta cancel the remaining fetches as soon as
+// one source fails with ErrConfiguration.
+func (f *Fetcher) SetFailFast(failFast bool) {
+	f.failFast = failFast
+}
+
+// SetOffline makes network sources and URLs fail with ErrOffline; local
+// files are still read.
+func (f *Fetcher) SetOffline(offline bool) {
+	f.offline = offline
+}
+
 // FetchAll fetches from all sources concurrently.
 func (f *Fetcher) FetchAll(ctx context.Context, sources []string) []Result {
 	return f.FetchAllWithMeta(ctx, sources, nil)
 }
 
-// FetchAllWithMeta fetches from all sources concurrently with conditional requests.
+// FetchAllWithMeta fetches from all sources concurrently with conditional
+// requests, at most SetJobs sources at a time. Results are in source order.
 func (f *Fetcher) FetchAllWithMeta(ctx context.Context, sources []string, meta *MetaCache) []Result {
 	results := make([]Result, len(sources))
 	var wg sync.WaitGroup
 
-	for i, src := range sources {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	workers := f.jobs
+	if workers <= 0 || workers > len(sources) {
+		workers = len(sources)
+	}
+
+	progress := newProgressTracker(f.progress)
+	defer progress.close()
+
+	work := make(chan int)
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(idx int, source string) {
+		go func() {
 			defer wg.Done()
-			var srcMeta *SourceMeta
-			if meta != nil && meta.Sources != nil {
-				if m, ok := meta.Sources[source]; ok {
-					srcMeta = &m
-				}
+			for idx := range work {
+				fetchCtx := progress.start(ctx, idx, sources[idx])
+				results[idx] = f.fetchOne(fetchCtx, cancel, sources[idx], meta)
+				progress.done(idx, results[idx])
 			}
-			data, newMeta, modified, err := f.FetchWithMeta(ctx, source, srcMeta)
-			results[idx] = Result{
-				Source:   source,
-				Data:     data,
-				Meta:     newMeta,
-				Modified: modified,
-				Err:      err,
-			}
-		}(i, src)
+		}()
+	}
+
+	for i := range sources {
+		work <- i
 	}
+	close(work)
 
 	wg.Wait()
 	return results
 }
 
+// fetchOne fetches a single source for FetchAllWithMeta, canceling the
+// remaining fetches on a configuration error in fail-fast mode.
+func (f *Fetcher) fetchOne(ctx context.Context, cancel context.CancelCauseFunc, source string, meta *MetaCache) Result {
+	var srcMeta *SourceMeta
+	if meta != nil && meta.Sources != nil {
+		if m, ok := meta.Sources[source]; ok {
+			srcMeta = &m
+		}
+	}
+
+	var (
+		data     *BannerData
+		newMeta  *SourceMeta
+		modified bool
+		err      = ctx.Err() // Queued behind a cancellation
+	)
+	if err == nil {
+		var api *APICache
+		if meta != nil {
+			api = meta.API
+		}
+		data, newMeta, modified, err = f.fetchWithMeta(ctx, source, srcMeta, api)
+	}
+
+	var quarantine *Quarantine
+	if err == nil && data != nil && f.schema != nil {
+		quarantine, err = applySchema(data, f.schema(source))
+		if err != nil {
+			data, newMeta, modified = nil, nil, false
+		} else if newMeta != nil {
+			newMeta.Entries = len(data.Linux)
+			if quarantine != nil {
+				newMeta.Quarantined = len(quarantine.Linux)
+			}
+		}
+	}
+
+	if err != nil && ctx.Err() != nil && context.Cause(ctx) != ctx.Err() {
+		// Report why a fail-fast cancellation stopped this source,
+		// without marking it as misconfigured itself
+		err = fmt.Errorf("canceled after %v", context.Cause(ctx))
+	} else if err != nil && f.failFast && errors.Is(err, ErrConfiguration) {
+		cancel(fmt.Errorf("%s: %w", source, err))
+	}
+
+	return Result{
+		Source:     source,
+		Data:       data,
+		Meta:       newMeta,
+		Modified:   modified,
+		Err:        err,
+		Quarantine: quarantine,
+	}
+}
+
 // Fetch retrieves banner data from a single source (URL or local file).
 func (f *Fetcher) Fetch(ctx context.Context, source string) (*BannerData, error) {
 	data, _, _, err := f.FetchWithMeta(ctx, source, nil)
@@ -104,18 +475,162 @@ func (f *Fetcher) Fetch(ctx context.Context, source string) (*BannerData, error)
 }
 
 // FetchWithMeta retrieves banner data with conditional request support.
+// On 304 Not Modified, meta is updated in place and returned.
 // Returns: data, metadata, modified (false if 304), error
 func (f *Fetcher) FetchWithMeta(ctx context.Context, source string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
+	return f.fetchWithMeta(ctx, source, meta, nil)
+}
+
+// fetchWithMeta is FetchWithMeta listing github:// sources through api.
+func (f *Fetcher) fetchWithMeta(ctx context.Context, source string, meta *SourceMeta, api *APICache) (*BannerData, *SourceMeta, bool, error) {
 	if isLocalPath(source) {
-		data, err := f.fetchLocal(source)
+		data, n, err := f.fetchLocal(source)
 		if err != nil {
 			return nil, nil, false, err
 		}
-		return data, &SourceMeta{UpdatedAt: time.Now()}, true, nil
+		now := time.Now()
+		return data, &SourceMeta{
+			UpdatedAt: now,
+			FetchedAt: now,
+			Status:    StatusOK,
+			Entries:   len(data.Linux),
+			Bytes:     n,
+		}, true, nil
+	}
+	if IsCommand(source) {
+		return f.fetchCommand(ctx, source, meta)
+	}
+	if f.offline {
+		return nil, nil, false, ErrOffline
+	}
+	if IsGitHub(source) {
+		return f.fetchGitHub(ctx, source, meta, api)
+	}
+	if IsGit(source) {
+		return f.fetchGit(ctx, source, meta)
+	}
+	if IsOCI(source) {
+		return f.fetchOCI(ctx, source, meta)
 	}
 	return f.fetchHTTPWithMeta(ctx, source, meta)
 }
 
+// Probe checks that a source is reachable without downloading it: local
+// files must exist (standard input is not read), HTTP sources must answer
+// a HEAD request (with the source's token) successfully, github:// sources
+// must list banner files, git sources must resolve their ref, oci://
+// sources must serve their manifest, and command sources must name an
+// executable.
+func (f *Fetcher) Probe(ctx context.Context, source string) error {
+	if source == StdinSource {
+		// Reading it would consume it
+		return nil
+	}
+	if isLocalPath(source) {
+		path, err := expandHome(localPath(source))
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		return nil
+	}
+	if IsCommand(source) {
+		return probeCommand(source)
+	}
+	if f.offline {
+		return ErrOffline
+	}
+	if IsGitHub(source) {
+		_, err := f.listGitHub(ctx, NewAPICache(), source)
+		return err
+	}
+	if IsGit(source) {
+		gs, err := parseGitSource(source)
+		if err != nil {
+			return err
+		}
+		_, err = f.remoteCommit(ctx, source, gs)
+		return err
+	}
+	if IsOCI(source) {
+		s, err := f.newOCISession(source, "pull")
+		if err != nil {
+			return err
+		}
+		_, _, err = s.getManifest(ctx)
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, source, nil)
+	if err != nil {
+		return fmt.Errorf("%w: creating request: %w", ErrConfiguration, err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if err := f.authorize(ctx, req, source); err != nil {
+		return err
+	}
+
+	resp, err := f.clientFor(source).Do(req)
+	if err != nil {
+		return fmt.Errorf("executing request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		// Some servers only allow GET; reaching them is enough
+		return nil
+	case resp.StatusCode >= 400:
+		return &SourceError{URL: source, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Schemes lists the source URL schemes Fetch supports. Sources without a
+// scheme are local paths.
+var Schemes = []string{"http", "https", "file", "github", "git+https", "git+http", "git+ssh", "git+file", "oci", "command"}
+
+// Download writes the body of a GET of rawURL to w, returning the number
+// of bytes written. Like the pages of a source, the request carries the
+// credentials of source when rawURL is on its server; "" sends none.
+func (f *Fetcher) Download(ctx context.Context, source, rawURL string, w io.Writer) (int64, error) {
+	if f.offline {
+		return 0, ErrOffline
+	}
+	ctx, cancel := context.WithTimeout(ctx, DownloadTimeout)
+	defer cancel()
+
+	req, err := f.newRequest(ctx, source, rawURL)
+	if err != nil {
+		return 0, err
+	}
+
+	client := *f.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, &SourceError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("reading response: %w", err)
+	}
+	return n, nil
+}
+
+// IsLocal reports whether source is a local file rather than a URL fetched
+// over the network.
+func IsLocal(source string) bool {
+	return isLocalPath(source)
+}
+
 // isLocalPath determines if the source is a local file path.
 func isLocalPath(source string) bool {
 	if strings.HasPrefix(source, "file://") {
@@ -130,41 +645,83 @@ func isLocalPath(source string) bool {
 	return false
 }
 
-// fetchLocal reads banner data from a local file.
-func (f *Fetcher) fetchLocal(source string) (*BannerData, error) {
-	path := source
-	path = strings.TrimPrefix(path, "file://")
+// localPath converts a local source to a filesystem path, accepting file://
+// URIs with Windows drive letters (file:///C:/symbols/banners.json).
+func localPath(source string) string {
+	if !strings.HasPrefix(source, "file://") {
+		return source
+	}
 
-	if strings.HasPrefix(path, "~") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("expanding home dir: %w", err)
-		}
-		path = filepath.Join(home, path[1:])
+	path := strings.TrimPrefix(source, "file://")
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// fetchLocal reads banner data from a local file, or from standard input
+// for StdinSource, returning the bytes read.
+func (f *Fetcher) fetchLocal(source string) (*BannerData, int64, error) {
+	if source == StdinSource {
+		return f.fetchStdin()
+	}
+	path, err := expandHome(localPath(source))
+	if err != nil {
+		return nil, 0, err
 	}
 
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+		return nil, 0, fmt.Errorf("opening file: %w", err)
 	}
 	defer file.Close()
 
-	var data BannerData
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return nil, fmt.Errorf("decoding JSON: %w", err)
+	cr := &countingReader{r: file}
+	data, err := decodeIndex(cr, f.formatOf(source), "", path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding index: %w", err)
 	}
 
-	return &data, nil
+	return data, cr.n, nil
 }
 
-// fetchHTTPWithMeta retrieves banner data via HTTP(S) with conditional request support.
-func (f *Fetcher) fetchHTTPWithMeta(ctx context.Context, url string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("creating request: %w", err)
+		return "", fmt.Errorf("expanding home dir: %w", err)
 	}
+	return filepath.Join(home, path[1:]), nil
+}
 
-	req.Header.Set("User-Agent", UserAgent)
+// countingReader counts bytes read through it, adding them to live too
+// while progress is tracked.
+type countingReader struct {
+	r    io.Reader
+	n    int64
+	live *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.live != nil {
+		c.live.Add(int64(n))
+	}
+	return n, err
+}
+
+// fetchHTTPWithMeta retrieves banner data via HTTP(S) with conditional
+// request support, following the source's pagination. Conditional headers
+// apply to the first page: when it is unchanged, the source is.
+func (f *Fetcher) fetchHTTPWithMeta(ctx context.Context, url string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
+	req, err := f.newRequest(ctx, url, url)
+	if err != nil {
+		return nil, nil, false, err
+	}
 
 	// Add conditional headers if we have metadata
 	if meta != nil {
@@ -176,53 +733,213 @@ func (f *Fetcher) fetchHTTPWithMeta(ctx context.Context, url string, meta *Sourc
 		}
 	}
 
-	resp, err := f.client.Do(req)
+	resp, err := f.clientFor(url).Do(req)
 	if err != nil {
 		return nil, nil, false, fmt.Errorf("executing request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Not modified - return nil data but no error
+	// Not modified - return nil data but no error, recording the outcome
+	// on the caller's metadata
 	if resp.StatusCode == http.StatusNotModified {
+		if meta == nil {
+			meta = &SourceMeta{}
+		}
+		meta.FetchedAt = time.Now()
+		meta.Status = StatusNotModified
+		meta.Error = ""
+		meta.Bytes = 0
 		return nil, meta, false, nil
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return nil, nil, false, &SourceError{URL: url, StatusCode: resp.StatusCode}
 	}
 
-	var data BannerData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	var paging Paging
+	if f.paging != nil {
+		paging = f.paging(url)
+	}
+
+	cr := newCountingReader(ctx, resp.Body)
+	data, next, err := decodePage(cr, resp.Header, url, paging, f.formatOf(url))
+	if err != nil {
 		return nil, nil, false, fmt.Errorf("decoding response: %w", err)
 	}
+	if next != "" {
+		n, err := f.fetchPages(ctx, url, next, paging, data)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		cr.n += n
+	}
 
 	// Store new metadata
+	now := time.Now()
 	newMeta := &SourceMeta{
 		ETag:         resp.Header.Get("ETag"),
 		LastModified: resp.Header.Get("Last-Modified"),
-		UpdatedAt:    time.Now(),
+		UpdatedAt:    now,
+		FetchedAt:    now,
+		Status:       StatusOK,
+		Entries:      len(data.Linux),
+		Bytes:        cr.n,
 	}
 
-	return &data, newMeta, true, nil
+	return data, newMeta, true, nil
 }
 
+// newRequest creates a GET request for pageURL of source, carrying the
+// source's token.
+func (f *Fetcher) newRequest(ctx context.Context, source, pageURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: creating request: %w", ErrConfiguration, err)
+	}
+
+	req.Header.Set("User-Agent", UserAgent)
+
+	if SameHost(source, pageURL) {
+		if err := f.authorize(ctx, req, source); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// authorize sets the Authorization header of req, a request to source's
+// server, with the header from the AuthFunc or else source's bearer token.
+func (f *Fetcher) authorize(ctx context.Context, req *http.Request, source string) error {
+	auth, err := f.authHeader(ctx, source, req.URL.Host)
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return nil
+}
+
+// authHeader returns the Authorization header authenticating source to the
+// server on host, "" for none.
+func (f *Fetcher) authHeader(ctx context.Context, source, host string) (string, error) {
+	if f.auth != nil {
+		auth, err := f.auth(ctx, source, host)
+		if err != nil {
+			return "", fmt.Errorf("%w: resolving credentials: %w", ErrConfiguration, err)
+		}
+		if auth != "" {
+			return auth, nil
+		}
+	}
+	if f.token != nil {
+		token, err := f.token(ctx, source)
+		if err != nil {
+			return "", fmt.Errorf("%w: resolving token: %w", ErrConfiguration, err)
+		}
+		if token != "" {
+			return "Bearer " + token, nil
+		}
+	}
+	return "", nil
+}
+
+// Provenance maps each banner to the sources that provided it.
+type Provenance map[string][]string
+
 // Merge combines multiple BannerData into one, deduplicating URLs per banner.
+// URLs are merged in their canonical form (see CanonicalURL), and one listed
+// over both http and https is kept once, over https.
 func Merge(datasets []*BannerData) *BannerData {
+	merged, _ := MergeSources(nil, datasets, nil)
+	return merged
+}
+
+// MergeSources merges datasets like Merge and records which source provided
+// each banner. sources[i] names the origin of datasets[i]; datasets without a
+// matching source name are merged but not attributed. The URLs of hosts in
+// redirects are rewritten to their targets before deduplicating.
+func MergeSources(sources []string, datasets []*BannerData, redirects map[string]string) (*BannerData, Provenance) {
 	merged := &BannerData{
 		Version: 1,
 		Linux:   make(map[string][]string),
 	}
+	prov := make(Provenance)
 
-	for _, data := range datasets {
+	for i, data := range datasets {
 		if data == nil {
 			continue
 		}
 
 		for banner, urls := range data.Linux {
-			merged.Linux[banner] = appendUnique(merged.Linux[banner], urls)
+			merged.Linux[banner] = appendCanonical(merged.Linux[banner], urls, redirects)
+			if i < len(sources) {
+				prov[banner] = appendUnique(prov[banner], []string{sources[i]})
+			}
 		}
 	}
 
+	return merged, prov
+}
+
+// URLProvenance maps each URL of merged, a merge of datasets by
+// MergeSources, to the sources listing it, in the order of sources.
+// URLs no attributed dataset lists are left out.
+func URLProvenance(merged *BannerData, sources []string, datasets []*BannerData, redirects map[string]string) map[string][]string {
+	byKey := make(map[string][]string)
+	for i, data := range datasets {
+		if data == nil || i >= len(sources) {
+			continue
+		}
+		for _, urls := range data.Linux {
+			for _, u := range urls {
+				key, _ := urlKey(CanonicalURL(u, redirects))
+				if !slices.Contains(byKey[key], sources[i]) {
+					byKey[key] = append(byKey[key], sources[i])
+				}
+			}
+		}
+	}
+
+	prov := make(map[string][]string)
+	for _, urls := range merged.Linux {
+		for _, u := range urls {
+			key, _ := urlKey(u)
+			if srcs, ok := byKey[key]; ok {
+				prov[u] = srcs
+			}
+		}
+	}
+	return prov
+}
+
+// MergeMetadata merges the metadata of datasets for the banners each
+// lists. Each field of a banner is taken from the first dataset that has
+// it, so earlier sources win conflicts. It returns nil when no dataset has
+// metadata.
+func MergeMetadata(datasets []*BannerData) Metadata {
+	var merged Metadata
+	for _, data := range datasets {
+		if data == nil {
+			continue
+		}
+		for banner, fields := range data.Metadata {
+			if _, ok := data.Linux[banner]; !ok {
+				continue
+			}
+			if merged == nil {
+				merged = make(Metadata)
+			}
+			if merged[banner] == nil {
+				merged[banner] = make(map[string]json.RawMessage, len(fields))
+			}
+			for name, value := range fields {
+				if _, ok := merged[banner][name]; !ok {
+					merged[banner][name] = value
+				}
+			}
+		}
+	}
 	return merged
 }
 
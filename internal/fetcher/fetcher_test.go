@@ -1,12 +1,19 @@
 package fetcher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -204,6 +211,101 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestMergeSources(t *testing.T) {
+	datasets := []*BannerData{
+		{Version: 1, Linux: map[string][]string{"banner1": {"url1"}, "banner2": {"url2"}}},
+		nil,
+		{Version: 1, Linux: map[string][]string{"banner1": {"url3"}}},
+	}
+	sources := []string{"src-a", "src-nil", "src-b"}
+
+	merged, prov := MergeSources(sources, datasets, nil)
+
+	if len(merged.Linux) != 2 {
+		t.Fatalf("expected 2 banners, got %d", len(merged.Linux))
+	}
+
+	if got := prov["banner1"]; len(got) != 2 || got[0] != "src-a" || got[1] != "src-b" {
+		t.Errorf("banner1 provenance = %v, expected [src-a src-b]", got)
+	}
+	if got := prov["banner2"]; len(got) != 1 || got[0] != "src-a" {
+		t.Errorf("banner2 provenance = %v, expected [src-a]", got)
+	}
+}
+
+func TestMergeSourcesUnattributed(t *testing.T) {
+	datasets := []*BannerData{
+		{Version: 1, Linux: map[string][]string{"banner1": {"url1"}}},
+		{Version: 1, Linux: map[string][]string{"banner2": {"url2"}}},
+	}
+
+	merged, prov := MergeSources([]string{"src-a"}, datasets, nil)
+
+	if len(merged.Linux) != 2 {
+		t.Errorf("expected 2 banners, got %d", len(merged.Linux))
+	}
+	if _, ok := prov["banner2"]; ok {
+		t.Error("banner2 should not be attributed without a source name")
+	}
+}
+
+func TestURLProvenance(t *testing.T) {
+	datasets := []*BannerData{
+		{Version: 1, Linux: map[string][]string{"banner1": {"http://Mirror.example.org/a.json"}}},
+		{Version: 1, Linux: map[string][]string{"banner1": {"https://mirror.example.org/a.json", "https://other.example.org/b.json"}}},
+		{Version: 1, Linux: map[string][]string{"banner2": {"https://unattributed.example.org/c.json"}}},
+	}
+	sources := []string{"src-a", "src-b"}
+	merged, _ := MergeSources(sources, datasets, nil)
+
+	prov := URLProvenance(merged, sources, datasets, nil)
+	if got := prov["https://mirror.example.org/a.json"]; len(got) != 2 || got[0] != "src-a" || got[1] != "src-b" {
+		t.Errorf("shared URL provenance = %v, expected [src-a src-b]", got)
+	}
+	if got := prov["https://other.example.org/b.json"]; len(got) != 1 || got[0] != "src-b" {
+		t.Errorf("other URL provenance = %v, expected [src-b]", got)
+	}
+	if _, ok := prov["https://unattributed.example.org/c.json"]; ok {
+		t.Error("a URL of an unattributed dataset should be left out")
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	var a, b BannerData
+	rawA := `{"version":1,"linux":{"banner1":["url1"]},"metadata":{"banner1":{"size":1024,"compiler":"gcc 12"},"gone":{"size":1}}}`
+	rawB := `{"version":1,"linux":{"banner1":["url2"],"banner2":["url3"]},"metadata":{"banner1":{"size":2048,"build_id":"abc"},"banner2":"not an object"}}`
+	if err := json.Unmarshal([]byte(rawA), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(rawB), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	md := MergeMetadata([]*BannerData{&a, nil, &b})
+	if len(md) != 1 {
+		t.Fatalf("MergeMetadata() = %v, expected only banner1", md)
+	}
+	fields := md["banner1"]
+	if string(fields["size"]) != "1024" || string(fields["compiler"]) != `"gcc 12"` || string(fields["build_id"]) != `"abc"` {
+		t.Errorf("banner1 metadata = %v, expected the first source's size and both sources' other fields", fields)
+	}
+
+	// Merging for volatility3 drops the metadata
+	merged, _ := MergeSources(nil, []*BannerData{&a, &b}, nil)
+	if merged.Metadata != nil {
+		t.Errorf("MergeSources() kept metadata %v", merged.Metadata)
+	}
+
+	// A metadata field of another shape is ignored
+	var c BannerData
+	if err := json.Unmarshal([]byte(`{"version":1,"linux":{"b":["u"]},"metadata":"v2"}`), &c); err != nil || c.Metadata != nil {
+		t.Errorf("decoding a string metadata field = %v, %v; expected it ignored", c.Metadata, err)
+	}
+	if MergeMetadata([]*BannerData{&c}) != nil {
+		t.Error("MergeMetadata() without metadata should be nil")
+	}
+}
+
 func TestIsLocalPath(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -282,6 +384,111 @@ func TestFetchHTTP(t *testing.T) {
 	}
 }
 
+func TestFetchHTTPWithToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}})
+	}))
+	defer server.Close()
+
+	f := New()
+	ctx := context.Background()
+
+	if _, err := f.Fetch(ctx, server.URL); err == nil {
+		t.Fatal("fetch without token should fail")
+	}
+
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) {
+		return "s3cret", nil
+	})
+	if _, err := f.Fetch(ctx, server.URL); err != nil {
+		t.Fatalf("fetch with token failed: %v", err)
+	}
+
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) {
+		return "", errors.New("vault sealed")
+	})
+	if _, err := f.Fetch(ctx, server.URL); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Errorf("token errors should fail the fetch, got %v", err)
+	}
+}
+
+func TestFetchHTTPWithNegotiate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Negotiate YIIGhg==" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}})
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) {
+		return "s3cret", nil
+	})
+	var gotHost string
+	f.SetAuthFunc(func(ctx context.Context, source, host string) (string, error) {
+		gotHost = host
+		return "Negotiate YIIGhg==", nil
+	})
+	if _, err := f.Fetch(context.Background(), server.URL); err != nil {
+		t.Fatalf("fetch with Negotiate failed: %v", err)
+	}
+	if gotHost != strings.TrimPrefix(server.URL, "http://") {
+		t.Errorf("Negotiate token asked for %q, expected the server's host", gotHost)
+	}
+	if err := f.Probe(context.Background(), server.URL); err != nil {
+		t.Errorf("Probe() with Negotiate failed: %v", err)
+	}
+
+	f.SetAuthFunc(func(ctx context.Context, source, host string) (string, error) {
+		return "", errors.New("no Kerberos ticket")
+	})
+	if _, err := f.Fetch(context.Background(), server.URL); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("Negotiate errors should fail the fetch as configuration errors, got %v", err)
+	}
+}
+
+func TestSetProxyAuth(t *testing.T) {
+	var httpAuth, connectAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			connectAuth = r.Header.Get("Proxy-Authorization")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		httpAuth = r.Header.Get("Proxy-Authorization")
+		_ = json.NewEncoder(w).Encode(BannerData{Version: 1, Linux: map[string][]string{"b": {"u"}}})
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	f := New()
+	f.SetProxy(http.ProxyURL(proxyURL))
+	f.SetProxyAuth(func(ctx context.Context, host string) (string, error) {
+		return "Negotiate token-for-" + host, nil
+	})
+
+	want := "Negotiate token-for-" + proxyURL.Host
+	if _, err := f.Fetch(context.Background(), "http://symbols.corp.example/banners.json"); err != nil {
+		t.Fatalf("fetch through the proxy failed: %v", err)
+	}
+	if httpAuth != want {
+		t.Errorf("http request Proxy-Authorization = %q, expected %q", httpAuth, want)
+	}
+
+	if _, err := f.Fetch(context.Background(), "https://symbols.corp.example/banners.json"); err == nil {
+		t.Error("fetch through a refusing proxy should fail")
+	}
+	if connectAuth != want {
+		t.Errorf("CONNECT Proxy-Authorization = %q, expected %q", connectAuth, want)
+	}
+}
+
 func TestFetchHTTPNotFound(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -292,8 +499,15 @@ func TestFetchHTTPNotFound(t *testing.T) {
 	ctx := context.Background()
 
 	_, err := f.Fetch(ctx, server.URL)
-	if err == nil {
-		t.Error("Fetch() should fail on 404")
+	var srcErr *SourceError
+	if !errors.As(err, &srcErr) {
+		t.Fatalf("Fetch() on 404 = %v, expected a SourceError", err)
+	}
+	if srcErr.URL != server.URL || srcErr.StatusCode != http.StatusNotFound {
+		t.Errorf("SourceError = %+v, expected URL %s and status 404", srcErr, server.URL)
+	}
+	if errors.Is(err, ErrConfiguration) {
+		t.Error("a 404 should not be a configuration error")
 	}
 }
 
@@ -757,3 +971,301 @@ func TestMetaCache(t *testing.T) {
 		t.Error("ETag not stored correctly")
 	}
 }
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: "/srv/banners.json", want: "/srv/banners.json"},
+		{source: "file:///srv/banners.json", want: filepath.FromSlash("/srv/banners.json")},
+		{source: "file:///C:/symbols/banners.json", want: filepath.FromSlash("C:/symbols/banners.json")},
+	}
+
+	for _, tt := range tests {
+		if got := localPath(tt.source); got != tt.want {
+			t.Errorf("localPath(%q) = %q, expected %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestFetchAllFailFast(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer denied.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	f := New()
+	f.SetFailFast(true)
+
+	start := time.Now()
+	results := f.FetchAll(context.Background(), []string{denied.URL, slow.URL})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("fail-fast should cancel the slow source, took %s", elapsed)
+	}
+
+	if !errors.Is(results[0].Err, ErrConfiguration) {
+		t.Errorf("401 should be a configuration error, got %v", results[0].Err)
+	}
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "canceled after") {
+		t.Errorf("slow source should report the cancellation cause, got %v", results[1].Err)
+	}
+}
+
+func TestFetchAllWithoutFailFast(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer denied.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"b":["u"]}}`))
+	}))
+	defer ok.Close()
+
+	results := New().FetchAll(context.Background(), []string{denied.URL, ok.URL})
+	if results[1].Err != nil {
+		t.Errorf("other sources should complete without fail-fast, got %v", results[1].Err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/get-only":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case "/denied":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	local := filepath.Join(t.TempDir(), "banners.json")
+	if err := os.WriteFile(local, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := New()
+	for _, source := range []string{server.URL + "/ok", server.URL + "/get-only", local} {
+		if err := f.Probe(context.Background(), source); err != nil {
+			t.Errorf("Probe(%q) failed: %v", source, err)
+		}
+	}
+	if err := f.Probe(context.Background(), server.URL+"/denied"); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("Probe(denied) should be a configuration error, got %v", err)
+	}
+	for _, source := range []string{server.URL + "/missing", local + ".missing"} {
+		if err := f.Probe(context.Background(), source); err == nil {
+			t.Errorf("Probe(%q) should fail", source)
+		}
+	}
+}
+
+func TestFetchAllJobsLimit(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{"` + r.URL.Path + `":["u"]}}`))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	var sources []string
+	for i := 0; i < 6; i++ {
+		sources = append(sources, fmt.Sprintf("%s/%d", server.URL, i))
+	}
+
+	f := New()
+	f.SetJobs(2)
+	results := f.FetchAll(context.Background(), sources)
+
+	if maxInFlight > 2 {
+		t.Errorf("%d fetches ran at once, expected at most 2", maxInFlight)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("source %d failed: %v", i, r.Err)
+		}
+		if _, ok := r.Data.Linux[fmt.Sprintf("/%d", i)]; r.Source != sources[i] || !ok {
+			t.Errorf("result %d is out of order: %+v", i, r)
+		}
+	}
+}
+
+func TestSourceTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"version":1,"linux":{}}`))
+	}))
+	defer server.Close()
+
+	slow := server.URL + "/slow"
+	f := New()
+	f.SetTimeoutFunc(func(source string) time.Duration {
+		if source == slow {
+			return 50 * time.Millisecond
+		}
+		return 0
+	})
+
+	if got := f.clientFor(server.URL).Timeout; got != HTTPTimeout {
+		t.Errorf("default timeout = %v, expected %v", got, HTTPTimeout)
+	}
+	if _, err := f.Fetch(context.Background(), slow); err == nil {
+		t.Error("Fetch() should time out with the per-source override")
+	}
+	if _, err := f.Fetch(context.Background(), server.URL); err != nil {
+		t.Errorf("Fetch() without override failed: %v", err)
+	}
+}
+
+func TestUpdateStatusJSON(t *testing.T) {
+	at := time.Date(2024, 1, 31, 6, 0, 0, 0, time.UTC)
+	raw, err := json.Marshal(UpdateStatus{At: at, Success: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"at":"2024-01-31T06:00:00Z","success":true,"at_unix":1706680800}`; string(raw) != want {
+		t.Errorf("Marshal() = %s, expected %s", raw, want)
+	}
+
+	var got UpdateStatus
+	if err := json.Unmarshal(raw, &got); err != nil || !got.At.Equal(at) || !got.Success {
+		t.Errorf("Unmarshal() = %+v, %v", got, err)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"version":1,"linux":{}}`))
+	}))
+	defer server.Close()
+
+	local := filepath.Join(t.TempDir(), "local.json")
+	if err := os.WriteFile(local, []byte(`{"version":1,"linux":{"b":["u"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := New()
+	f.SetOffline(true)
+	ctx := context.Background()
+
+	if _, err := f.Fetch(ctx, server.URL); !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch() of a URL offline = %v, expected ErrOffline", err)
+	}
+	if err := f.Probe(ctx, server.URL); !errors.Is(err, ErrOffline) {
+		t.Errorf("Probe() of a URL offline = %v, expected ErrOffline", err)
+	}
+	if _, err := f.Download(ctx, "", server.URL, io.Discard); !errors.Is(err, ErrOffline) {
+		t.Errorf("Download() offline = %v, expected ErrOffline", err)
+	}
+	if hits != 0 {
+		t.Errorf("offline fetcher made %d requests", hits)
+	}
+
+	if data, err := f.Fetch(ctx, local); err != nil || len(data.Linux) != 1 {
+		t.Errorf("Fetch() of a local file offline = %v, %v", data, err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != UserAgent {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("symbols"))
+	}))
+	defer server.Close()
+
+	f := New()
+	var buf bytes.Buffer
+	n, err := f.Download(context.Background(), "", server.URL+"/linux.json.xz", &buf)
+	if err != nil || n != 7 || buf.String() != "symbols" {
+		t.Errorf("Download() = %d, %v; body %q", n, err, buf.String())
+	}
+
+	var serr *SourceError
+	if _, err := f.Download(context.Background(), "", server.URL+"/missing", io.Discard); !errors.As(err, &serr) || serr.StatusCode != 404 {
+		t.Errorf("Download() of a missing file error = %v, expected status 404", err)
+	}
+}
+
+func TestDownloadAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("symbols"))
+	}))
+	defer server.Close()
+
+	source := server.URL + "/banners.json"
+	f := New()
+	f.SetTokenFunc(func(ctx context.Context, s string) (string, error) {
+		if s != source {
+			return "", nil
+		}
+		return "s3cret", nil
+	})
+
+	var buf bytes.Buffer
+	if _, err := f.Download(context.Background(), source, server.URL+"/linux.json.xz", &buf); err != nil || buf.String() != "symbols" {
+		t.Errorf("Download() with the source's token = %v; body %q", err, buf.String())
+	}
+	var serr *SourceError
+	if _, err := f.Download(context.Background(), "", server.URL+"/linux.json.xz", io.Discard); !errors.As(err, &serr) || serr.StatusCode != 401 {
+		t.Errorf("Download() without a source = %v, expected status 401", err)
+	}
+	if _, err := f.Download(context.Background(), "https://other.example/banners.json", server.URL+"/linux.json.xz", io.Discard); !errors.As(err, &serr) {
+		t.Errorf("Download() sent another server's token: %v", err)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetTimeoutFunc(func(string) time.Duration { return 50 * time.Millisecond })
+	_, err := f.Fetch(context.Background(), server.URL)
+	if !IsTimeout(err) {
+		t.Errorf("IsTimeout(%v) = false for a source past its timeout", err)
+	}
+	if !IsTimeout(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)) {
+		t.Error("IsTimeout() should match a context deadline")
+	}
+	if IsTimeout(errors.New("connection refused")) || IsTimeout(&SourceError{URL: server.URL, StatusCode: 503}) {
+		t.Error("IsTimeout() should not match other failures")
+	}
+}
@@ -0,0 +1,344 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Banner index formats a source may be published in, set per source with
+// SetFormatFunc. FormatAuto, the default, tells them apart by content.
+const (
+	FormatAuto = "auto"
+	// FormatISF is volatility3's own: {"version": 1, "linux": {BANNER:
+	// [URL, ...]}}, or the version 2 list of {"url": URL, ...} objects.
+	FormatISF = "isf"
+	// FormatDistro groups banners by distribution: {DISTRO: {BANNER: URL
+	// or [URL, ...]}, ...}. The distribution becomes the distro metadata
+	// field.
+	FormatDistro = "distro"
+	// FormatRecords lists an object per banner: [{"banner": BANNER, "url":
+	// URL or "urls": [URL, ...], ...}, ...]. Other fields become metadata.
+	FormatRecords = "records"
+	// FormatCSV has a header row naming banner and url (or urls) columns;
+	// a cell may list several URLs separated by spaces, and other columns
+	// become metadata.
+	FormatCSV = "csv"
+	// FormatText has a BANNER<TAB>URL[<TAB>URL...] line per banner.
+	FormatText = "text"
+)
+
+// Formats lists the formats a source may be set to.
+var Formats = []string{FormatAuto, FormatISF, FormatDistro, FormatRecords, FormatCSV, FormatText}
+
+// FormatFunc returns the format a source is published in, "" for
+// FormatAuto.
+type FormatFunc func(source string) string
+
+// ErrFormat indicates an unknown format.
+var ErrFormat = errors.New("unknown banner index format")
+
+// SetFormatFunc sets how the format of each source is resolved. Without it
+// every format is detected.
+func (f *Fetcher) SetFormatFunc(fn FormatFunc) {
+	f.format = fn
+}
+
+// formatOf returns the format of source.
+func (f *Fetcher) formatOf(source string) string {
+	if f.format == nil {
+		return FormatAuto
+	}
+	return f.format(source)
+}
+
+// decodeIndex reads a banner index in format from r, normalizing it into
+// BannerData. For FormatAuto it detects the format from the content, the
+// Content-Type of an HTTP response, and the file extension of name.
+func decodeIndex(r io.Reader, format, contentType, name string) (*BannerData, error) {
+	if format == FormatISF {
+		var data BannerData
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			return nil, schemaDecodeError("", err)
+		}
+		return &data, nil
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+	if format == "" || format == FormatAuto {
+		format = DetectFormat(body, contentType, name)
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/html" && format == FormatText {
+			return nil, &SchemaError{Problems: []string{"got an HTML page, not a banner index"}}
+		}
+	}
+	switch format {
+	case FormatISF:
+		var data BannerData
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, schemaDecodeError("", err)
+		}
+		return &data, nil
+	case FormatDistro:
+		return decodeDistro(body)
+	case FormatRecords:
+		return decodeRecords(body)
+	case FormatCSV:
+		return decodeCSV(body)
+	case FormatText:
+		return decodeText(body)
+	}
+	return nil, fmt.Errorf("%w %q", ErrFormat, format)
+}
+
+// DetectFormat returns the format of an index: a JSON object is FormatISF
+// when it has a linux or version field and FormatDistro otherwise, and a
+// JSON array FormatRecords. Other content is FormatCSV when served as
+// text/csv, named *.csv, or headed by a comma-separated row naming a
+// banner column, and FormatText otherwise.
+func DetectFormat(body []byte, contentType, name string) string {
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatRecords
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		if isISF(trimmed) {
+			return FormatISF
+		}
+		return FormatDistro
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		name = u.Path
+	}
+	if mediaType == "text/csv" || strings.EqualFold(path.Ext(name), ".csv") {
+		return FormatCSV
+	}
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		if line = strings.ToLower(strings.TrimSpace(line)); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "\t") && strings.Contains(line, ",") && strings.Contains(line, "banner") {
+			return FormatCSV
+		}
+		break
+	}
+	return FormatText
+}
+
+// isISF reports whether the JSON object in body has a linux or version
+// field, or none at all. Malformed JSON counts as ISF, so decoding it
+// reports why.
+func isISF(body []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if _, err := dec.Token(); err != nil {
+		return true
+	}
+	empty := true
+	for dec.More() {
+		empty = false
+		key, err := dec.Token()
+		if err != nil || key == "linux" || key == "version" {
+			return true
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return true
+		}
+	}
+	return empty
+}
+
+// newIndex returns empty BannerData for a format without a version.
+func newIndex() *BannerData {
+	return &BannerData{Version: IndexVersion, Linux: make(map[string][]string)}
+}
+
+// addMetadata sets field of banner unless an earlier entry set it.
+func (d *BannerData) addMetadata(banner, field string, value json.RawMessage) {
+	if d.Metadata == nil {
+		d.Metadata = make(Metadata)
+	}
+	if d.Metadata[banner] == nil {
+		d.Metadata[banner] = make(map[string]json.RawMessage)
+	}
+	if _, ok := d.Metadata[banner][field]; !ok {
+		d.Metadata[banner][field] = value
+	}
+}
+
+// urlList decodes a URL or a list of URLs.
+func urlList(raw json.RawMessage) ([]string, bool) {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return []string{one}, true
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		return many, true
+	}
+	return nil, false
+}
+
+func decodeDistro(body []byte) (*BannerData, error) {
+	var distros map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &distros); err != nil {
+		return nil, schemaDecodeError("", err)
+	}
+
+	names := make([]string, 0, len(distros))
+	for distro := range distros {
+		names = append(names, distro)
+	}
+	sort.Strings(names)
+
+	data := newIndex()
+	var problems []string
+	for _, distro := range names {
+		value, _ := json.Marshal(distro)
+		for banner, raw := range distros[distro] {
+			urls, ok := urlList(raw)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: banner %q: expected a URL or a list of URLs", distro, banner))
+				continue
+			}
+			data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+			data.addMetadata(banner, "distro", value)
+		}
+	}
+	if problems != nil {
+		sort.Strings(problems)
+		return nil, &SchemaError{Problems: problems}
+	}
+	return data, nil
+}
+
+func decodeRecords(body []byte) (*BannerData, error) {
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, schemaDecodeError("", err)
+	}
+
+	data := newIndex()
+	var problems []string
+	for i, record := range records {
+		var banner string
+		if json.Unmarshal(record["banner"], &banner) != nil || banner == "" {
+			problems = append(problems, fmt.Sprintf("record %d: missing banner", i))
+			continue
+		}
+		raw, ok := record["urls"]
+		if !ok {
+			raw = record["url"]
+		}
+		urls, ok := urlList(raw)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("record %d: banner %q: expected url or urls", i, banner))
+			continue
+		}
+		data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+		for field, value := range record {
+			if field != "banner" && field != "url" && field != "urls" {
+				data.addMetadata(banner, field, value)
+			}
+		}
+	}
+	if problems != nil {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return data, nil
+}
+
+func decodeCSV(body []byte) (*BannerData, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	bannerCol, urlCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "banner":
+			bannerCol = i
+		case "url", "urls":
+			urlCol = i
+		}
+	}
+	if bannerCol < 0 || urlCol < 0 {
+		return nil, &SchemaError{Problems: []string{"CSV header needs banner and url columns"}}
+	}
+
+	data := newIndex()
+	var problems []string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		banner := strings.TrimSpace(row[bannerCol])
+		urls := strings.Fields(row[urlCol])
+		if banner == "" || len(urls) == 0 {
+			problems = append(problems, fmt.Sprintf("line %d: expected a banner and a URL", line))
+			continue
+		}
+		data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+		for i, value := range row {
+			if i != bannerCol && i != urlCol && value != "" {
+				raw, _ := json.Marshal(value)
+				data.addMetadata(banner, strings.TrimSpace(header[i]), raw)
+			}
+		}
+	}
+	if problems != nil {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return data, nil
+}
+
+func decodeText(body []byte) (*BannerData, error) {
+	data := newIndex()
+	var problems []string
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		banner := strings.TrimSpace(fields[0])
+		var urls []string
+		for _, u := range fields[1:] {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		if banner == "" || len(urls) == 0 {
+			problems = append(problems, fmt.Sprintf("line %d: expected BANNER<TAB>URL", i+1))
+			continue
+		}
+		data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+	}
+	if problems != nil {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return data, nil
+}
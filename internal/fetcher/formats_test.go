@@ -0,0 +1,151 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, body, contentType, file string
+		want                          string
+	}{
+		{"isf", `{"version":1,"linux":{}}`, "", "", FormatISF},
+		{"isf with metadata first", `{"metadata":{},"linux":{}}`, "", "", FormatISF},
+		{"empty object", ` {}`, "", "", FormatISF},
+		{"malformed json", `{"linux":`, "", "", FormatISF},
+		{"distro", "\ufeff{\"ubuntu\":{\"b\":\"u\"}}", "", "", FormatDistro},
+		{"records", "\n[{\"banner\":\"b\",\"url\":\"u\"}]", "", "", FormatRecords},
+		{"csv content type", "b;u\n", "text/csv; charset=utf-8", "", FormatCSV},
+		{"csv extension", "b;u\n", "", "https://example.com/banners.CSV?rev=2", FormatCSV},
+		{"csv header", "# exported\nbanner,url,arch\n", "text/plain", "", FormatCSV},
+		{"text", "Linux version 6.1.0, SMP\thttps://example.com/a\n", "", "banners.txt", FormatText},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat([]byte(tt.body), tt.contentType, tt.file); got != tt.want {
+			t.Errorf("%s: DetectFormat() = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeIndexFormats(t *testing.T) {
+	want := map[string][]string{
+		"Linux version 5.15.0": {"https://example.com/a.json.xz", "https://mirror.example/a.json.xz"},
+		"Linux version 6.1.0":  {"https://example.com/b.json.xz"},
+	}
+	tests := []struct {
+		name, body string
+		metadata   string // JSON of the expected metadata, "" for none
+	}{
+		{
+			name: "distro",
+			body: `{"ubuntu": {"Linux version 5.15.0": ["https://example.com/a.json.xz", "https://mirror.example/a.json.xz"]},
+			        "debian": {"Linux version 6.1.0": "https://example.com/b.json.xz"}}`,
+			metadata: `{"Linux version 5.15.0":{"distro":"ubuntu"},"Linux version 6.1.0":{"distro":"debian"}}`,
+		},
+		{
+			name: "records",
+			body: `[{"banner": "Linux version 5.15.0", "url": "https://example.com/a.json.xz", "arch": "x86_64"},
+			        {"banner": "Linux version 5.15.0", "url": "https://mirror.example/a.json.xz"},
+			        {"banner": "Linux version 6.1.0", "urls": ["https://example.com/b.json.xz"], "size": 1843}]`,
+			metadata: `{"Linux version 5.15.0":{"arch":"x86_64"},"Linux version 6.1.0":{"size":1843}}`,
+		},
+		{
+			name: "csv",
+			body: "banner,urls,arch\n" +
+				"# a comment\n" +
+				"\"Linux version 5.15.0\",https://example.com/a.json.xz https://mirror.example/a.json.xz,x86_64\n" +
+				"Linux version 6.1.0,https://example.com/b.json.xz,\n",
+			metadata: `{"Linux version 5.15.0":{"arch":"x86_64"}}`,
+		},
+		{
+			name: "text",
+			body: "# banner<TAB>urls\r\n" +
+				"Linux version 5.15.0\thttps://example.com/a.json.xz\thttps://mirror.example/a.json.xz\r\n" +
+				"\n" +
+				"Linux version 6.1.0\thttps://example.com/b.json.xz\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := decodeIndex(strings.NewReader(tt.body), FormatAuto, "", "")
+			if err != nil {
+				t.Fatalf("decodeIndex() failed: %v", err)
+			}
+			if data.Version != IndexVersion || !reflect.DeepEqual(data.Linux, want) {
+				t.Errorf("decodeIndex() = %+v, expected version 1 and %v", data, want)
+			}
+			if got := mustJSON(t, data.Metadata); tt.metadata != "" && got != tt.metadata || tt.metadata == "" && data.Metadata != nil {
+				t.Errorf("metadata = %s, expected %s", got, tt.metadata)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestDecodeIndexErrors(t *testing.T) {
+	tests := []struct {
+		name, body, format, contentType, err string
+	}{
+		{"record without banner", `[{"url": "https://example.com/a"}]`, FormatAuto, "", "record 0: missing banner"},
+		{"distro with a number", `{"ubuntu": {"b": 1}}`, FormatAuto, "", `ubuntu: banner "b": expected a URL`},
+		{"csv without url column", "banner,file\nb,f\n", FormatCSV, "", "needs banner and url columns"},
+		{"text without url", "just a banner\n", FormatAuto, "", "line 1: expected BANNER<TAB>URL"},
+		{"html error page", "<html><body>Sign in</body></html>", FormatAuto, "text/html", "HTML page"},
+		{"unknown format", "{}", "xml", "", "unknown banner index format"},
+	}
+	for _, tt := range tests {
+		_, err := decodeIndex(strings.NewReader(tt.body), tt.format, tt.contentType, "")
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: decodeIndex() = %v, expected %q", tt.name, err, tt.err)
+		}
+		var se *SchemaError
+		if tt.format != "xml" && !errors.As(err, &se) {
+			t.Errorf("%s: expected a SchemaError, got %T", tt.name, err)
+		}
+	}
+}
+
+func TestFetchAlternativeFormats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprint(w, "banner,url\nb,https://example.com/b.json.xz\n")
+	}))
+	defer server.Close()
+
+	f := New()
+	data, err := f.Fetch(context.Background(), server.URL+"/export")
+	if err != nil || len(data.Linux["b"]) != 1 {
+		t.Fatalf("Fetch() of CSV = %+v, %v", data, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "banners.dat")
+	if err := os.WriteFile(path, []byte("b\thttps://example.com/b.json.xz\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f.SetFormatFunc(func(source string) string { return FormatISF })
+	if _, err := f.Fetch(context.Background(), path); err == nil {
+		t.Error("Fetch() of text forced to ISF should fail")
+	}
+	f.SetFormatFunc(func(source string) string { return FormatText })
+	if data, err := f.Fetch(context.Background(), path); err != nil || len(data.Linux["b"]) != 1 {
+		t.Errorf("Fetch() of text = %+v, %v", data, err)
+	}
+}
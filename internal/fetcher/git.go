@@ -0,0 +1,203 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitPrefix starts the sources fetched from a git repository, e.g.
+// git+https://example.com/symbols.git#path=banners.json.
+const gitPrefix = "git+"
+
+// gitSource is a parsed git+URL#path=PATH[&ref=REF] source.
+type gitSource struct {
+	Remote string // Repository URL, without the git+ prefix
+	Path   string // File in the repository
+	Ref    string // Branch, tag, or commit; empty for the remote's HEAD
+}
+
+// IsGit reports whether source is a git repository source.
+func IsGit(source string) bool {
+	if !strings.HasPrefix(source, gitPrefix) {
+		return false
+	}
+	scheme, _, ok := strings.Cut(strings.TrimPrefix(source, gitPrefix), "://")
+	return ok && scheme != ""
+}
+
+// parseGitSource splits a git source into the repository URL, the path of
+// the banner index in it, and the ref to read it from.
+func parseGitSource(source string) (gitSource, error) {
+	remote, fragment, _ := strings.Cut(strings.TrimPrefix(source, gitPrefix), "#")
+	params, err := url.ParseQuery(fragment)
+	if err != nil {
+		return gitSource{}, fmt.Errorf("%w: %s: %w", ErrConfiguration, source, err)
+	}
+	gs := gitSource{
+		Remote: remote,
+		Path:   strings.Trim(params.Get("path"), "/"),
+		Ref:    params.Get("ref"),
+	}
+	if gs.Path == "" {
+		return gitSource{}, fmt.Errorf("%w: %s: expected #path=FILE after the repository URL", ErrConfiguration, source)
+	}
+	if strings.HasPrefix(gs.Ref, "-") {
+		return gitSource{}, fmt.Errorf("%w: %s: invalid ref %q", ErrConfiguration, source, gs.Ref)
+	}
+	return gs, nil
+}
+
+// ref returns the ref to fetch, HEAD by default.
+func (gs gitSource) ref() string {
+	if gs.Ref == "" {
+		return "HEAD"
+	}
+	return gs.Ref
+}
+
+// isCommitHash reports whether ref is a full commit hash, which names the
+// same content forever.
+func isCommitHash(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// git runs git with args in dir, authenticating HTTP remotes of source
+// with its Authorization header, and returns its output.
+func (f *Fetcher) git(ctx context.Context, source, remote, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if u, err := url.Parse(remote); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		auth, err := f.authHeader(ctx, source, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if auth != "" {
+			// Through the environment, so the credentials stay out of
+			// the process list
+			cmd.Env = append(cmd.Env, "GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: "+auth)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%w: git sources need git installed: %w", ErrConfiguration, err)
+	}
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// lastLine returns the last non-empty line of s, where git reports why it
+// failed.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// remoteCommit returns the commit the source's ref points to, asking the
+// remote unless the ref is a commit hash.
+func (f *Fetcher) remoteCommit(ctx context.Context, source string, gs gitSource) (string, error) {
+	if isCommitHash(gs.Ref) {
+		return gs.Ref, nil
+	}
+	out, err := f.git(ctx, source, gs.Remote, "", "ls-remote", "--exit-code", "--", gs.Remote, gs.ref())
+	if err != nil {
+		return "", err
+	}
+	commit, _, _ := strings.Cut(string(out), "\t")
+	if !isCommitHash(commit) {
+		return "", fmt.Errorf("git ls-remote: unexpected output %q", lastLine(string(out)))
+	}
+	return commit, nil
+}
+
+// fetchGit reads the banner index of a git source. The commit its ref
+// points to is compared with the one meta records first, so an unchanged
+// repository costs a single ls-remote; otherwise the ref is fetched
+// shallowly and without blobs, which git then fetches for the index alone
+// where the server supports partial clones.
+func (f *Fetcher) fetchGit(ctx context.Context, source string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
+	gs, err := parseGitSource(source)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.clientFor(source).Timeout)
+	defer cancel()
+
+	commit, err := f.remoteCommit(ctx, source, gs)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if meta != nil && meta.Commit == commit {
+		meta.FetchedAt = time.Now()
+		meta.Status = StatusNotModified
+		meta.Error = ""
+		meta.Bytes = 0
+		return nil, meta, false, nil
+	}
+
+	dir, err := os.MkdirTemp("", "basar-git-")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The ref rather than the commit, which not every server lets
+	// clients ask for; FETCH_HEAD then tells if it moved meanwhile
+	steps := [][]string{
+		{"init", "--quiet", "--bare"},
+		{"remote", "add", "origin", gs.Remote},
+		{"fetch", "--quiet", "--depth=1", "--filter=blob:none", "--no-tags", "origin", gs.ref()},
+	}
+	for _, args := range steps {
+		if _, err := f.git(ctx, source, gs.Remote, dir, args...); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	out, err := f.git(ctx, source, gs.Remote, dir, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	commit = strings.TrimSpace(string(out))
+	body, err := f.git(ctx, source, gs.Remote, dir, "cat-file", "blob", commit+":"+gs.Path)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("reading %s at %.12s: %w", gs.Path, commit, err)
+	}
+
+	data, err := decodeIndex(bytes.NewReader(body), f.formatOf(source), "", gs.Path)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("decoding index: %w", err)
+	}
+	now := time.Now()
+	return data, &SourceMeta{
+		Commit:    commit,
+		UpdatedAt: now,
+		FetchedAt: now,
+		Status:    StatusOK,
+		Entries:   len(data.Linux),
+		Bytes:     int64(len(body)),
+	}, true, nil
+}
@@ -0,0 +1,165 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   gitSource
+		err    bool
+	}{
+		{"git+https://example.com/isf.git#path=banners.json", gitSource{Remote: "https://example.com/isf.git", Path: "banners.json"}, false},
+		{"git+ssh://git@example.com/isf.git#path=/linux/banners.json&ref=stable", gitSource{"ssh://git@example.com/isf.git", "linux/banners.json", "stable"}, false},
+		{"git+https://example.com/isf.git", gitSource{}, true},
+		{"git+https://example.com/isf.git#path=b.json&ref=--upload-pack=x", gitSource{}, true},
+	}
+	for _, tt := range tests {
+		if !IsGit(tt.source) {
+			t.Errorf("IsGit(%q) = false", tt.source)
+		}
+		got, err := parseGitSource(tt.source)
+		if tt.err {
+			if !errors.Is(err, ErrConfiguration) {
+				t.Errorf("parseGitSource(%q) error = %v, expected a configuration error", tt.source, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseGitSource(%q) = %+v, %v, expected %+v", tt.source, got, err, tt.want)
+		}
+	}
+	for _, source := range []string{"https://example.com/git+x", "git+banners.json", "/srv/git+isf/banners.json"} {
+		if IsGit(source) {
+			t.Errorf("IsGit(%q) = true", source)
+		}
+	}
+}
+
+// gitRepo is a local repository serving as a git source.
+type gitRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newGitRepo(t *testing.T) *gitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	r := &gitRepo{t: t, dir: t.TempDir()}
+	r.run("init", "--quiet", "--initial-branch=main")
+	r.run("config", "uploadpack.allowFilter", "true")
+	return r
+}
+
+func (r *gitRepo) run(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files and commits them, returning the commit hash.
+func (r *gitRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.run("add", "--all")
+	r.run("commit", "--quiet", "--message", "update")
+	return r.run("rev-parse", "HEAD")
+}
+
+func (r *gitRepo) source(fragment string) string {
+	return "git+file://" + filepath.ToSlash(r.dir) + "#" + fragment
+}
+
+func TestFetchGit(t *testing.T) {
+	repo := newGitRepo(t)
+	first := repo.commit(map[string]string{
+		"linux/banners.json": `{"version": 1, "linux": {"b1": ["https://example.com/1.json"]}}`,
+		"symbols/big.json":   strings.Repeat("x", 1<<16),
+	})
+
+	f := New()
+	ctx := context.Background()
+	source := repo.source("path=linux/banners.json")
+	data, meta, modified, err := f.FetchWithMeta(ctx, source, nil)
+	if err != nil {
+		t.Fatalf("FetchWithMeta() failed: %v", err)
+	}
+	if !modified || len(data.Linux) != 1 || meta.Commit != first || meta.Entries != 1 {
+		t.Errorf("FetchWithMeta() = %v, %+v, %v; expected b1 at %s", data.Linux, meta, modified, first)
+	}
+
+	// Unchanged: not modified, without fetching
+	data, meta, modified, err = f.FetchWithMeta(ctx, source, meta)
+	if err != nil || modified || data != nil || meta.Status != StatusNotModified {
+		t.Errorf("refetch = %v, %+v, %v, %v; expected not modified", data, meta, modified, err)
+	}
+
+	second := repo.commit(map[string]string{
+		"linux/banners.json": `{"version": 1, "linux": {"b1": ["https://example.com/1.json"], "b2": ["https://example.com/2.json"]}}`,
+	})
+	data, meta, modified, err = f.FetchWithMeta(ctx, source, meta)
+	if err != nil || !modified || len(data.Linux) != 2 || meta.Commit != second {
+		t.Errorf("fetch after a commit = %v, %+v, %v, %v; expected b1 and b2 at %s", data, meta, modified, err, second)
+	}
+
+	// Pinned to the first commit, by hash or by tag
+	repo.run("tag", "v1", first)
+	for _, ref := range []string{first, "v1"} {
+		data, meta, _, err := f.FetchWithMeta(ctx, repo.source("path=linux/banners.json&ref="+ref), nil)
+		if err != nil || len(data.Linux) != 1 || meta.Commit != first {
+			t.Errorf("FetchWithMeta(ref=%s) = %v, %+v, %v; expected b1 at %s", ref, data, meta, err, first)
+		}
+	}
+
+	if err := f.Probe(ctx, source); err != nil {
+		t.Errorf("Probe() failed: %v", err)
+	}
+}
+
+func TestFetchGitErrors(t *testing.T) {
+	repo := newGitRepo(t)
+	repo.commit(map[string]string{"banners.json": `{"version": 1, "linux": {}}`})
+
+	f := New()
+	ctx := context.Background()
+	for _, tt := range []struct{ source, err string }{
+		{repo.source("path=missing.json"), "missing.json"},
+		{repo.source("path=banners.json&ref=nonexistent"), "git ls-remote"},
+		{"git+file://" + filepath.ToSlash(t.TempDir()) + "#path=banners.json", "git ls-remote"},
+	} {
+		if _, err := f.Fetch(ctx, tt.source); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Fetch(%q) error = %v, expected %q", tt.source, err, tt.err)
+		}
+	}
+
+	f.SetOffline(true)
+	if _, err := f.Fetch(ctx, repo.source("path=banners.json")); !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch() offline error = %v, expected ErrOffline", err)
+	}
+}
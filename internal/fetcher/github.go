@@ -0,0 +1,263 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHubAPI is the GitHub REST API github:// sources are listed through,
+// unless SetGitHubAPI points them at a GitHub Enterprise server.
+const GitHubAPI = "https://api.github.com"
+
+// ErrNoBannerFiles indicates a github:// source lists no banner files.
+var ErrNoBannerFiles = errors.New("no banner files found")
+
+// gitHubSource is a parsed github://OWNER/REPO[/PATH][@REF] source.
+type gitHubSource struct {
+	Owner, Repo, Path, Ref string
+}
+
+// gitHubFile is a file of a repository listed by the contents API.
+type gitHubFile struct {
+	Type        string `json:"type"`
+	Path        string `json:"path"`
+	SHA         string `json:"sha"`
+	DownloadURL string `json:"download_url"`
+}
+
+// IsGitHub reports whether source is a github:// source, whose banner
+// files are discovered through the GitHub API.
+func IsGitHub(source string) bool {
+	return strings.HasPrefix(source, "github://")
+}
+
+// SetGitHubAPI sets the API endpoint github:// sources are listed through,
+// e.g. https://github.example.com/api/v3. Empty restores GitHubAPI.
+func (f *Fetcher) SetGitHubAPI(endpoint string) {
+	f.githubAPI = strings.TrimSuffix(endpoint, "/")
+}
+
+// parseGitHubSource splits a github:// source into its repository, path
+// and ref; the ref follows the last @ and defaults to the default branch.
+func parseGitHubSource(source string) (gitHubSource, error) {
+	rest := strings.TrimPrefix(source, "github://")
+	var gh gitHubSource
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		rest, gh.Ref = rest[:i], rest[i+1:]
+		if gh.Ref == "" {
+			return gh, fmt.Errorf("%w: %s: empty ref after @", ErrConfiguration, source)
+		}
+	}
+	parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return gh, fmt.Errorf("%w: %s: expected github://OWNER/REPO[/PATH][@REF]", ErrConfiguration, source)
+	}
+	gh.Owner, gh.Repo = parts[0], parts[1]
+	if len(parts) == 3 {
+		gh.Path = parts[2]
+	}
+	return gh, nil
+}
+
+// contentsURL returns the contents API URL listing the source's path.
+func (gh gitHubSource) contentsURL(api string) string {
+	u := fmt.Sprintf("%s/repos/%s/%s/contents", api, url.PathEscape(gh.Owner), url.PathEscape(gh.Repo))
+	if gh.Path != "" {
+		u += "/" + escapePath(gh.Path)
+	}
+	if gh.Ref != "" {
+		u += "?ref=" + url.QueryEscape(gh.Ref)
+	}
+	return u
+}
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// listGitHub returns the banner files of a github:// source: the file its
+// path names, or the *.json files directly in the directory it names,
+// sorted by path. Listings go through the API cache, so unchanged ones are
+// revalidated without spending quota and served from it while the quota
+// is exhausted.
+func (f *Fetcher) listGitHub(ctx context.Context, api *APICache, source string) ([]gitHubFile, error) {
+	gh, err := parseGitHubSource(source)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := f.githubAPI
+	if endpoint == "" {
+		endpoint = GitHubAPI
+	}
+	listURL := gh.contentsURL(endpoint)
+
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+	u, err := url.Parse(listURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrConfiguration, source, err)
+	}
+	auth, err := f.authHeader(ctx, source, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if auth != "" {
+		header.Set("Authorization", auth)
+	}
+
+	body, err := f.GetAPI(ctx, api, listURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", source, err)
+	}
+
+	var files []gitHubFile
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "{") {
+		var file gitHubFile
+		if err := json.Unmarshal(body, &file); err != nil {
+			return nil, fmt.Errorf("decoding listing: %w", err)
+		}
+		if file.Type == "file" {
+			files = append(files, file)
+		}
+	} else {
+		var entries []gitHubFile
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("decoding listing: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type == "file" && strings.EqualFold(path.Ext(entry.Path), ".json") {
+				files = append(files, entry)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: %w", source, ErrNoBannerFiles)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// gitHubValidator identifies the listed revision of files, so an update
+// skips downloading them when none changed.
+func gitHubValidator(files []gitHubFile) string {
+	h := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(h, "%s %s\n", file.Path, file.SHA)
+	}
+	return "github:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// fetchGitHub lists the banner files of a github:// source and merges
+// them into one index. When the listing shows the files meta was fetched
+// from are unchanged, it reports the source not modified.
+func (f *Fetcher) fetchGitHub(ctx context.Context, source string, meta *SourceMeta, api *APICache) (*BannerData, *SourceMeta, bool, error) {
+	if api == nil {
+		api = NewAPICache()
+	}
+	files, err := f.listGitHub(ctx, api, source)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	validator := gitHubValidator(files)
+	if meta != nil && meta.ETag == validator {
+		meta.FetchedAt = time.Now()
+		meta.Status = StatusNotModified
+		meta.Error = ""
+		meta.Bytes = 0
+		return nil, meta, false, nil
+	}
+
+	data := newIndex()
+	var n int64
+	for _, file := range files {
+		fileData, read, err := f.fetchGitHubFile(ctx, source, file)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		n += read
+		if fileData.Version != IndexVersion {
+			data.Version = fileData.Version
+		}
+		for banner, urls := range fileData.Linux {
+			data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+		}
+		for banner, fields := range fileData.Metadata {
+			for field, value := range fields {
+				data.addMetadata(banner, field, value)
+			}
+		}
+	}
+
+	now := time.Now()
+	return data, &SourceMeta{
+		ETag:      validator,
+		UpdatedAt: now,
+		FetchedAt: now,
+		Status:    StatusOK,
+		Entries:   len(data.Linux),
+		Bytes:     n,
+	}, true, nil
+}
+
+// fetchGitHubFile downloads and decodes a listed file, authenticating only
+// to GitHub's own hosts.
+func (f *Fetcher) fetchGitHubFile(ctx context.Context, source string, file gitHubFile) (*BannerData, int64, error) {
+	if file.DownloadURL == "" {
+		return nil, 0, errors.New("no download URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.DownloadURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if f.isGitHubHost(req.URL.Host) {
+		if err := f.authorize(ctx, req, source); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	resp, err := f.clientFor(source).Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, &SourceError{URL: file.DownloadURL, StatusCode: resp.StatusCode}
+	}
+
+	cr := newCountingReader(ctx, resp.Body)
+	data, err := decodeIndex(cr, f.formatOf(source), resp.Header.Get("Content-Type"), file.Path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding response: %w", err)
+	}
+	return data, cr.n, nil
+}
+
+// isGitHubHost reports whether host serves GitHub's raw files or the
+// configured API, so it may be sent the source's credentials.
+func (f *Fetcher) isGitHubHost(host string) bool {
+	if host == "raw.githubusercontent.com" {
+		return f.githubAPI == ""
+	}
+	if f.githubAPI == "" {
+		return false
+	}
+	u, err := url.Parse(f.githubAPI)
+	return err == nil && u.Host == host
+}
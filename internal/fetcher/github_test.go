@@ -0,0 +1,194 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseGitHubSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   gitHubSource
+		err    bool
+	}{
+		{"github://owner/repo", gitHubSource{Owner: "owner", Repo: "repo"}, false},
+		{"github://owner/repo/banners", gitHubSource{Owner: "owner", Repo: "repo", Path: "banners"}, false},
+		{"github://owner/repo/a/b/banners.json@v2", gitHubSource{"owner", "repo", "a/b/banners.json", "v2"}, false},
+		{"github://owner/repo/@release/1.0", gitHubSource{Owner: "owner", Repo: "repo", Ref: "release/1.0"}, false},
+		{"github://owner", gitHubSource{}, true},
+		{"github://owner/repo@", gitHubSource{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGitHubSource(tt.source)
+		if tt.err {
+			if !errors.Is(err, ErrConfiguration) {
+				t.Errorf("parseGitHubSource(%q) error = %v, expected a configuration error", tt.source, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseGitHubSource(%q) = %+v, %v, expected %+v", tt.source, got, err, tt.want)
+		}
+	}
+}
+
+// newGitHubServer serves a repository whose banners directory holds two
+// banner files, answering listings with a fixed ETag and counting the
+// files downloaded.
+func newGitHubServer(t *testing.T, downloads *int32, auth *string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/repos/owner/repo/contents/banners":
+			if r.URL.Query().Get("ref") != "main" {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Header.Get("If-None-Match") == `"list-v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"list-v1"`)
+			fmt.Fprintf(w, `[
+				{"type": "file", "path": "banners/ubuntu.json", "sha": "a1", "download_url": "%[1]s/raw/ubuntu.json"},
+				{"type": "file", "path": "banners/debian.json", "sha": "b2", "download_url": "%[1]s/raw/debian.json"},
+				{"type": "file", "path": "banners/README.md", "sha": "c3", "download_url": "%[1]s/raw/README.md"},
+				{"type": "dir", "path": "banners/old", "sha": "d4", "download_url": null}
+			]`, server.URL)
+		case "/repos/owner/repo/contents/banners/debian.json":
+			fmt.Fprintf(w, `{"type": "file", "path": "banners/debian.json", "sha": "b2", "download_url": "%s/raw/debian.json"}`, server.URL)
+		case "/raw/ubuntu.json":
+			atomic.AddInt32(downloads, 1)
+			fmt.Fprint(w, `{"version": 1, "linux": {"Linux version 5.15.0": ["https://example.com/a.json.xz"]}}`)
+		case "/raw/debian.json":
+			atomic.AddInt32(downloads, 1)
+			fmt.Fprint(w, `{"version": 1, "linux": {"Linux version 6.1.0": ["https://example.com/b.json.xz"],
+				"Linux version 5.15.0": ["https://example.com/a2.json.xz"]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchGitHubDirectory(t *testing.T) {
+	var downloads int32
+	var auth string
+	server := newGitHubServer(t, &downloads, &auth)
+
+	f := New()
+	f.SetGitHubAPI(server.URL + "/")
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) { return "gh-token", nil })
+	api := NewAPICache()
+	source := "github://owner/repo/banners@main"
+
+	data, meta, modified, err := f.fetchWithMeta(context.Background(), source, nil, api)
+	if err != nil {
+		t.Fatalf("fetchWithMeta() failed: %v", err)
+	}
+	if !modified || len(data.Linux) != 2 || len(data.Linux["Linux version 5.15.0"]) != 2 {
+		t.Errorf("fetchWithMeta() = %v, modified %v; expected both files merged", data.Linux, modified)
+	}
+	if downloads != 2 {
+		t.Errorf("downloaded %d files, expected the 2 JSON files", downloads)
+	}
+	if auth != "Bearer gh-token" {
+		t.Errorf("Authorization = %q, expected the source's token", auth)
+	}
+	if !strings.HasPrefix(meta.ETag, "github:") || meta.Entries != 2 {
+		t.Errorf("meta = %+v, expected a listing validator and 2 entries", meta)
+	}
+
+	// Unchanged listing: nothing is downloaded again
+	data, meta, modified, err = f.fetchWithMeta(context.Background(), source, meta, api)
+	if err != nil || modified || data != nil || meta.Status != StatusNotModified {
+		t.Errorf("refetch = %v, %+v, %v, %v; expected not modified", data, meta, modified, err)
+	}
+	if downloads != 2 {
+		t.Errorf("downloaded %d files after an unchanged listing, expected 2", downloads)
+	}
+}
+
+func TestFetchGitHubFile(t *testing.T) {
+	var downloads int32
+	var auth string
+	server := newGitHubServer(t, &downloads, &auth)
+
+	f := New()
+	f.SetGitHubAPI(server.URL)
+	data, err := f.Fetch(context.Background(), "github://owner/repo/banners/debian.json")
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if len(data.Linux) != 2 || downloads != 1 {
+		t.Errorf("Fetch() = %v after %d downloads, expected debian.json alone", data.Linux, downloads)
+	}
+	if err := f.Probe(context.Background(), "github://owner/repo/banners@main"); err != nil {
+		t.Errorf("Probe() failed: %v", err)
+	}
+}
+
+func TestFetchGitHubErrors(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/empty/contents":
+			fmt.Fprint(w, `[{"type": "file", "path": "README.md", "sha": "a1", "download_url": "x"}]`)
+		case "/repos/owner/limited/contents":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetGitHubAPI(server.URL)
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{"github://owner/empty", ErrNoBannerFiles},
+		{"github://owner/limited", ErrRateLimited},
+		{"github://owner", ErrConfiguration},
+	}
+	for _, tt := range tests {
+		if _, err := f.Fetch(context.Background(), tt.source); !errors.Is(err, tt.err) {
+			t.Errorf("Fetch(%q) error = %v, expected %v", tt.source, err, tt.err)
+		}
+	}
+
+	var se *SourceError
+	if _, err := f.Fetch(context.Background(), "github://owner/missing"); !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("Fetch() of a missing repository error = %v, expected status 404", err)
+	}
+
+	f.SetOffline(true)
+	if _, err := f.Fetch(context.Background(), "github://owner/empty"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch() offline error = %v, expected ErrOffline", err)
+	}
+}
+
+func TestGitHubCredentialsStayOnGitHub(t *testing.T) {
+	f := New()
+	if !f.isGitHubHost("raw.githubusercontent.com") || f.isGitHubHost("mirror.example.com") {
+		t.Error("without an API override only raw.githubusercontent.com should get credentials")
+	}
+	f.SetGitHubAPI("https://github.example.com/api/v3")
+	if !f.isGitHubHost("github.example.com") || f.isGitHubHost("raw.githubusercontent.com") {
+		t.Error("with an Enterprise API only its host should get credentials")
+	}
+}
@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// IndexVersionList is the banner index schema version that lists an
+// object per symbol file rather than a bare URL: {"version": 2, "linux":
+// {BANNER: [{"url": URL, FIELD: VALUE, ...}, ...]}}. Decoding accepts
+// either shape under either version, keeping the URLs in Linux and the
+// other fields as Metadata; encoding writes the shape of Version.
+const IndexVersionList = 2
+
+// plainBannerData is BannerData without its JSON methods.
+type plainBannerData BannerData
+
+// listBannerData is BannerData with each URL list decoded item by item.
+type listBannerData struct {
+	Version  int                          `json:"version"`
+	Linux    map[string][]json.RawMessage `json:"linux"`
+	Metadata Metadata                     `json:"metadata,omitempty"`
+}
+
+// UnmarshalJSON decodes an index of either version. URL lists of strings,
+// the common case, take the fast path; only an index with objects in them
+// is decoded item by item.
+func (d *BannerData) UnmarshalJSON(raw []byte) error {
+	err := json.Unmarshal(raw, (*plainBannerData)(d))
+	var te *json.UnmarshalTypeError
+	if err == nil || !errors.As(err, &te) || te.Value != "object" {
+		return err
+	}
+
+	var list listBannerData
+	if json.Unmarshal(raw, &list) != nil {
+		return err
+	}
+	out := BannerData{Version: list.Version, Metadata: list.Metadata}
+	if list.Linux != nil {
+		out.Linux = make(map[string][]string, len(list.Linux))
+	}
+	for banner, items := range list.Linux {
+		urls := make([]string, 0, len(items))
+		for _, item := range items {
+			u, fields, ok := decodeListItem(item)
+			if !ok {
+				return err
+			}
+			urls = append(urls, u)
+			for field, value := range fields {
+				out.addMetadata(banner, field, value)
+			}
+		}
+		out.Linux[banner] = urls
+	}
+	*d = out
+	return nil
+}
+
+// MarshalJSON encodes the index in the shape of its version. Version 2
+// entries carry the banner's metadata fields next to each URL. HTML
+// characters in URLs are left for the encoder to escape or not.
+func (d BannerData) MarshalJSON() ([]byte, error) {
+	if d.Version != IndexVersionList {
+		return marshalUnescaped(plainBannerData(d))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"version":2,"linux":{`)
+	banners := make([]string, 0, len(d.Linux))
+	for banner := range d.Linux {
+		banners = append(banners, banner)
+	}
+	sort.Strings(banners)
+	for i, banner := range banners {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalUnescaped(banner)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":[")
+		for j, u := range d.Linux[banner] {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			item := make(map[string]json.RawMessage, len(d.Metadata[banner])+1)
+			for field, value := range d.Metadata[banner] {
+				item[field] = value
+			}
+			if item["url"], err = marshalUnescaped(u); err != nil {
+				return nil, err
+			}
+			raw, err := marshalUnescaped(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(raw)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("}}")
+	return buf.Bytes(), nil
+}
+
+// decodeListItem decodes one entry of a URL list: a URL, or an object
+// with a url field whose other fields are metadata.
+func decodeListItem(raw json.RawMessage) (string, map[string]json.RawMessage, bool) {
+	var u string
+	if json.Unmarshal(raw, &u) == nil {
+		return u, nil, true
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil || json.Unmarshal(fields["url"], &u) != nil {
+		return "", nil, false
+	}
+	delete(fields, "url")
+	return u, fields, true
+}
+
+// DecodeURLs decodes the URL list of one banner in either version, as
+// read back from an index at an offset ScanBanners reported.
+func DecodeURLs(raw []byte) ([]string, error) {
+	var urls []string
+	err := json.Unmarshal(raw, &urls)
+	var te *json.UnmarshalTypeError
+	if err == nil || !errors.As(err, &te) || te.Value != "object" {
+		return urls, err
+	}
+
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		return nil, err
+	}
+	urls = make([]string, 0, len(items))
+	for _, item := range items {
+		u, _, ok := decodeListItem(item)
+		if !ok {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// marshalUnescaped is json.Marshal without escaping HTML characters.
+func marshalUnescaped(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,95 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBannerDataListFormat(t *testing.T) {
+	raw := `{"version":2,"linux":{
+		"b1":[{"url":"https://example.com/a.json.xz","size":10,"build_id":"ab"},"https://example.com/a2.json"],
+		"b2":["https://example.com/b.json"]}}`
+
+	var data BannerData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Version != IndexVersionList || len(data.Linux["b1"]) != 2 || data.Linux["b1"][1] != "https://example.com/a2.json" ||
+		len(data.Linux["b2"]) != 1 {
+		t.Fatalf("Unmarshal() = %+v", data)
+	}
+	if string(data.Metadata["b1"]["size"]) != "10" || string(data.Metadata["b1"]["build_id"]) != `"ab"` || data.Metadata["b2"] != nil {
+		t.Errorf("Metadata = %v, expected size and build_id of b1", data.Metadata)
+	}
+	if err := CheckSchema(&data); err != nil {
+		t.Errorf("CheckSchema() = %v", err)
+	}
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again BannerData
+	if err := json.Unmarshal(out, &again); err != nil {
+		t.Fatalf("Unmarshal(%s) = %v", out, err)
+	}
+	if len(again.Linux["b1"]) != 2 || string(again.Metadata["b1"]["size"]) != "10" {
+		t.Errorf("round trip = %+v from %s", again, out)
+	}
+
+	// Version 1 keeps plain URL lists
+	data.Version = IndexVersion
+	data.Metadata = nil
+	out, _ = json.Marshal(data)
+	if strings.Contains(string(out), `"url"`) {
+		t.Errorf("Marshal() of version 1 = %s, expected URL lists", out)
+	}
+}
+
+func TestBannerDataUnescapedURLs(t *testing.T) {
+	for _, version := range []int{IndexVersion, IndexVersionList} {
+		data := BannerData{Version: version, Linux: map[string][]string{"b": {"https://example.com/a.json?x=1&y=2"}}}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(data); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "x=1&y=2") {
+			t.Errorf("version %d: Encode() = %s, expected & unescaped", version, buf.String())
+		}
+	}
+}
+
+func TestBannerDataBadListItem(t *testing.T) {
+	var data BannerData
+	err := json.Unmarshal([]byte(`{"version":2,"linux":{"b":[{"size":10}]}}`), &data)
+	if err == nil {
+		t.Fatal("Unmarshal() of an entry without url succeeded")
+	}
+	if _, err := decodeIndex(strings.NewReader(`{"version":2,"linux":{"b":[{"size":10}]}}`), FormatISF, "", ""); err == nil ||
+		!strings.Contains(err.Error(), "invalid banner index") {
+		t.Errorf("decodeIndex() = %v, expected a schema error", err)
+	}
+}
+
+func TestDecodeURLs(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+		ok   bool
+	}{
+		{`["https://example.com/a"]`, []string{"https://example.com/a"}, true},
+		{`[{"url":"https://example.com/a","size":1},"https://example.com/b"]`, []string{"https://example.com/a", "https://example.com/b"}, true},
+		{`[{"size":1}]`, nil, false},
+		{`"https://example.com/a"`, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := DecodeURLs([]byte(tt.raw))
+		if (err == nil) != tt.ok || strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("DecodeURLs(%s) = %v, %v; expected %v", tt.raw, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Media types of the OCI artifacts basar publishes, which oci:// sources
+// look for first among the layers of a manifest.
+const (
+	OCIArtifactType   = "application/vnd.basar.banners.v1"
+	OCILayerMediaType = "application/vnd.basar.banners.v1+json"
+)
+
+const (
+	ociManifestType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociEmptyType       = "application/vnd.oci.empty.v1+json"
+	ociTitle           = "org.opencontainers.image.title"
+)
+
+// ociEmpty is the empty JSON config blob of artifacts without a config.
+var ociEmpty = []byte("{}")
+
+// ErrNoIndexLayer indicates an oci:// artifact has no layer holding a
+// banner index.
+var ErrNoIndexLayer = errors.New("no banner index layer found")
+
+// ociRef is a parsed oci://REGISTRY/REPOSITORY[:TAG|@DIGEST] reference.
+type ociRef struct {
+	Registry   string // Host, with the port if any
+	Repository string
+	Reference  string // Tag or digest; latest by default
+}
+
+// ociDescriptor describes a blob of an OCI manifest.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Data        []byte            `json:"data,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest is an OCI image manifest, or a Docker v2 one.
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsOCI reports whether source is an artifact in an OCI registry.
+func IsOCI(source string) bool {
+	return strings.HasPrefix(source, "oci://")
+}
+
+// parseOCIRef splits an oci:// reference into registry, repository, and
+// tag or digest. Repositories on Docker Hub without a namespace get the
+// library/ one, as docker pull does.
+func parseOCIRef(source string) (ociRef, error) {
+	rest := strings.TrimPrefix(source, "oci://")
+	registry, repo, ok := strings.Cut(rest, "/")
+	if !ok || registry == "" || repo == "" {
+		return ociRef{}, fmt.Errorf("%w: %s: expected oci://REGISTRY/REPOSITORY[:TAG|@DIGEST]", ErrConfiguration, source)
+	}
+	ref := ociRef{Registry: registry, Reference: "latest"}
+	if name, digest, ok := strings.Cut(repo, "@"); ok {
+		repo, ref.Reference = name, digest
+		if !strings.HasPrefix(digest, "sha256:") {
+			return ociRef{}, fmt.Errorf("%w: %s: only sha256 digests are supported", ErrConfiguration, source)
+		}
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, ref.Reference = repo[:i], repo[i+1:]
+	}
+	if repo == "" || ref.Reference == "" || strings.Contains(repo, "//") {
+		return ociRef{}, fmt.Errorf("%w: %s: expected oci://REGISTRY/REPOSITORY[:TAG|@DIGEST]", ErrConfiguration, source)
+	}
+	if registry == "docker.io" || registry == "index.docker.io" {
+		ref.Registry = "registry-1.docker.io"
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+	ref.Repository = repo
+	return ref, nil
+}
+
+// isDigest reports whether the reference names a manifest by digest.
+func (r ociRef) isDigest() bool {
+	return strings.HasPrefix(r.Reference, "sha256:")
+}
+
+// url returns the registry API URL of a repository endpoint such as
+// "manifests/latest". Registries on the loopback interface are spoken to
+// over plain HTTP, as local test registries expect.
+func (r ociRef) url(endpoint string) string {
+	scheme := "https"
+	host := r.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); host == "localhost" || ip != nil && ip.IsLoopback() {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, r.Registry, r.Repository, endpoint)
+}
+
+// ociSession sends a source's requests to its registry, answering the
+// registry's authentication challenges: Basic ones with the source's
+// credentials, Bearer ones with a token the registry's token service
+// grants for them.
+type ociSession struct {
+	f      *Fetcher
+	source string
+	ref    ociRef
+	scope  string // "pull" or "pull,push"
+	auth   string // Authorization header of registry requests, once known
+}
+
+// newOCISession parses an oci:// source for requests with scope.
+func (f *Fetcher) newOCISession(source, scope string) (*ociSession, error) {
+	ref, err := parseOCIRef(source)
+	if err != nil {
+		return nil, err
+	}
+	return &ociSession{f: f, source: source, ref: ref, scope: scope}, nil
+}
+
+// do sends a request with body to the registry, authenticating and
+// retrying once when challenged.
+func (s *ociSession) do(ctx context.Context, method, rawURL string, header http.Header, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: creating request: %w", ErrConfiguration, err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("User-Agent", UserAgent)
+		if s.auth != "" {
+			req.Header.Set("Authorization", s.auth)
+		}
+
+		resp, err := s.f.clientFor(s.source).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("executing request: %w", err)
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if resp.StatusCode != http.StatusUnauthorized || challenge == "" || attempt > 0 {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if s.auth, err = s.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authenticate returns the Authorization header answering a registry's
+// challenge. A source's bearer token is sent to the registry as is; other
+// credentials are exchanged for a token when the registry asks for one.
+func (s *ociSession) authenticate(ctx context.Context, challenge string) (string, error) {
+	cred, err := s.f.authHeader(ctx, s.source, s.ref.Registry)
+	if err != nil {
+		return "", err
+	}
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "Bearer") || strings.HasPrefix(cred, "Bearer ") {
+		if cred == "" {
+			return "", fmt.Errorf("%w: %s requires credentials", ErrConfiguration, s.ref.Registry)
+		}
+		return cred, nil
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", fmt.Errorf("invalid authentication challenge from %s: %q", s.ref.Registry, challenge)
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", "repository:"+s.ref.Repository+":"+s.scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: creating request: %w", ErrConfiguration, err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if cred != "" {
+		req.Header.Set("Authorization", cred)
+	}
+	resp, err := s.f.clientFor(s.source).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &SourceError{URL: realm.String(), StatusCode: resp.StatusCode}
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("no token granted by %s", realm.Host)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// parameters, whose quoted values may hold commas.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.Trim(key, " ,"))
+		value = strings.TrimLeft(value, " ")
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				end = len(value) - 1
+			}
+			params[key], rest = value[1:end+1], value[end+1:]
+			rest = strings.TrimPrefix(rest, `"`)
+		} else {
+			v, r, _ := strings.Cut(value, ",")
+			params[key], rest = strings.TrimSpace(v), r
+		}
+		rest = strings.TrimLeft(rest, " ,")
+	}
+	return scheme, params
+}
+
+// ociDigest returns the sha256 digest of content.
+func ociDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// getManifest fetches the manifest the source's reference names and
+// returns it with its digest.
+func (s *ociSession) getManifest(ctx context.Context) (*ociManifest, string, error) {
+	header := http.Header{}
+	header.Set("Accept", ociManifestType+", "+dockerManifestType)
+	resp, err := s.do(ctx, http.MethodGet, s.ref.url("manifests/"+s.ref.Reference), header, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &SourceError{URL: s.source, StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading manifest: %w", err)
+	}
+	digest := ociDigest(body)
+	if s.ref.isDigest() && digest != s.ref.Reference {
+		return nil, "", fmt.Errorf("manifest digest %s does not match %s", digest, s.ref.Reference)
+	}
+
+	var m ociManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, "", fmt.Errorf("decoding manifest: %w", err)
+	}
+	if m.MediaType == "" {
+		m.MediaType, _, _ = strings.Cut(resp.Header.Get("Content-Type"), ";")
+	}
+	if m.MediaType != ociManifestType && m.MediaType != dockerManifestType {
+		return nil, "", fmt.Errorf("%w: %s: expected an artifact manifest, got %s", ErrConfiguration, s.source, m.MediaType)
+	}
+	return &m, digest, nil
+}
+
+// indexLayer picks the layer of m holding the banner index: one of basar's
+// media type, else the first whose title is a JSON file, else the only one.
+func (m *ociManifest) indexLayer() (ociDescriptor, bool) {
+	for _, layer := range m.Layers {
+		if layer.MediaType == OCILayerMediaType {
+			return layer, true
+		}
+	}
+	for _, layer := range m.Layers {
+		if strings.EqualFold(path.Ext(layer.Annotations[ociTitle]), ".json") {
+			return layer, true
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], true
+	}
+	return ociDescriptor{}, false
+}
+
+// getBlob downloads a blob, checking its size and digest.
+func (s *ociSession) getBlob(ctx context.Context, desc ociDescriptor) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.ref.url("blobs/"+desc.Digest), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &SourceError{URL: s.source, StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, desc.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob: %w", err)
+	}
+	if int64(len(body)) != desc.Size || ociDigest(body) != desc.Digest {
+		return nil, fmt.Errorf("blob %.19s does not match its digest or size", desc.Digest)
+	}
+	return body, nil
+}
+
+// fetchOCI reads the banner index of an oci:// source. The manifest is
+// fetched first and its digest compared with the one meta records, so an
+// unchanged artifact costs a single small request.
+func (f *Fetcher) fetchOCI(ctx context.Context, source string, meta *SourceMeta) (*BannerData, *SourceMeta, bool, error) {
+	s, err := f.newOCISession(source, "pull")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	m, digest, err := s.getManifest(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if meta != nil && meta.ETag == digest {
+		meta.FetchedAt = time.Now()
+		meta.Status = StatusNotModified
+		meta.Error = ""
+		meta.Bytes = 0
+		return nil, meta, false, nil
+	}
+
+	layer, ok := m.indexLayer()
+	if !ok {
+		return nil, nil, false, fmt.Errorf("%s: %w", source, ErrNoIndexLayer)
+	}
+	body, err := s.getBlob(ctx, layer)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	data, err := decodeIndex(bytes.NewReader(body), f.formatOf(source), layer.MediaType, layer.Annotations[ociTitle])
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("decoding index: %w", err)
+	}
+	now := time.Now()
+	return data, &SourceMeta{
+		ETag:      digest,
+		UpdatedAt: now,
+		FetchedAt: now,
+		Status:    StatusOK,
+		Entries:   len(data.Linux),
+		Bytes:     int64(len(body)),
+	}, true, nil
+}
+
+// PushOCI publishes index, a banner index named name, to the registry as
+// a single-layer artifact tagged as ref says, authenticating like an
+// oci:// source of that reference. It returns the manifest digest.
+func (f *Fetcher) PushOCI(ctx context.Context, ref, name string, index []byte, annotations map[string]string) (string, error) {
+	if f.offline {
+		return "", ErrOffline
+	}
+	s, err := f.newOCISession(ref, "pull,push")
+	if err != nil {
+		return "", err
+	}
+	if s.ref.isDigest() {
+		return "", fmt.Errorf("%w: %s: publishing needs a tag, not a digest", ErrConfiguration, ref)
+	}
+
+	layer := ociDescriptor{
+		MediaType:   OCILayerMediaType,
+		Digest:      ociDigest(index),
+		Size:        int64(len(index)),
+		Annotations: map[string]string{ociTitle: name},
+	}
+	config := ociDescriptor{MediaType: ociEmptyType, Digest: ociDigest(ociEmpty), Size: int64(len(ociEmpty)), Data: ociEmpty}
+	if err := s.pushBlob(ctx, config, ociEmpty); err != nil {
+		return "", err
+	}
+	if err := s.pushBlob(ctx, layer, index); err != nil {
+		return "", err
+	}
+
+	manifest, err := json.Marshal(ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestType,
+		ArtifactType:  OCIArtifactType,
+		Config:        config,
+		Layers:        []ociDescriptor{layer},
+		Annotations:   annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", ociManifestType)
+	resp, err := s.do(ctx, http.MethodPut, s.ref.url("manifests/"+s.ref.Reference), header, manifest)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", &SourceError{URL: ref, StatusCode: resp.StatusCode}
+	}
+	return ociDigest(manifest), nil
+}
+
+// pushBlob uploads a blob unless the repository already has it, in a
+// single monolithic upload.
+func (s *ociSession) pushBlob(ctx context.Context, desc ociDescriptor, content []byte) error {
+	resp, err := s.do(ctx, http.MethodHead, s.ref.url("blobs/"+desc.Digest), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = s.do(ctx, http.MethodPost, s.ref.url("blobs/uploads/"), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return &SourceError{URL: s.source, StatusCode: resp.StatusCode}
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("registry %s returned no upload location", s.ref.Registry)
+	}
+	q := location.Query()
+	q.Set("digest", desc.Digest)
+	location.RawQuery = q.Encode()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	resp, err = s.do(ctx, http.MethodPut, location.String(), header, content)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return &SourceError{URL: s.source, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
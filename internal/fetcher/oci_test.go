@@ -0,0 +1,224 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseOCIRef(t *testing.T) {
+	tests := []struct {
+		source string
+		want   ociRef
+		err    bool
+	}{
+		{"oci://ghcr.io/org/banners:v1", ociRef{"ghcr.io", "org/banners", "v1"}, false},
+		{"oci://localhost:5000/banners", ociRef{"localhost:5000", "banners", "latest"}, false},
+		{"oci://docker.io/banners", ociRef{"registry-1.docker.io", "library/banners", "latest"}, false},
+		{"oci://r.example.com/a/b@sha256:abc", ociRef{"r.example.com", "a/b", "sha256:abc"}, false},
+		{"oci://r.example.com/a@md5:abc", ociRef{}, true},
+		{"oci://r.example.com", ociRef{}, true},
+		{"oci://r.example.com/a:", ociRef{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseOCIRef(tt.source)
+		if tt.err {
+			if !errors.Is(err, ErrConfiguration) {
+				t.Errorf("parseOCIRef(%q) error = %v, expected a configuration error", tt.source, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseOCIRef(%q) = %+v, %v, expected %+v", tt.source, got, err, tt.want)
+		}
+	}
+
+	if got := (ociRef{"127.0.0.1:5000", "a", "latest"}).url("manifests/latest"); got != "http://127.0.0.1:5000/v2/a/manifests/latest" {
+		t.Errorf("loopback registry URL = %s, expected plain HTTP", got)
+	}
+	if got := (ociRef{"ghcr.io", "a", "latest"}).url("blobs/x"); got != "https://ghcr.io/v2/a/blobs/x" {
+		t.Errorf("registry URL = %s, expected HTTPS", got)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a:pull,push"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.example.com/token" ||
+		params["service"] != "registry.example.com" || params["scope"] != "repository:a:pull,push" {
+		t.Errorf("parseChallenge() = %q, %v", scheme, params)
+	}
+	if scheme, params := parseChallenge(`Basic realm=registry`); scheme != "Basic" || params["realm"] != "registry" {
+		t.Errorf("parseChallenge(Basic) = %q, %v", scheme, params)
+	}
+}
+
+// ociRegistry is an in-memory registry granting tokens for the login
+// user:secret, and counting the blobs it serves.
+type ociRegistry struct {
+	*httptest.Server
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	blobGets  int
+	scopes    []string
+}
+
+func newOCIRegistry(t *testing.T) *ociRegistry {
+	t.Helper()
+	reg := &ociRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	reg.Server = httptest.NewServer(http.HandlerFunc(reg.serve))
+	t.Cleanup(reg.Close)
+	return reg
+}
+
+func (reg *ociRegistry) ref(repoTag string) string {
+	return "oci://" + strings.TrimPrefix(reg.URL, "http://") + "/" + repoTag
+}
+
+func (reg *ociRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if r.URL.Path == "/token" {
+		if r.Header.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.scopes = append(reg.scopes, r.URL.Query().Get("scope"))
+		fmt.Fprintf(w, `{"token": %q}`, r.URL.Query().Get("scope"))
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer repository:banners:pull") ||
+		r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get("Authorization") != "Bearer repository:banners:pull,push" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, reg.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/v2/banners/")
+	switch {
+	case r.Method == http.MethodPost && rest == "blobs/uploads/":
+		w.Header().Set("Location", "/v2/banners/blobs/uploads/session-1")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && strings.HasPrefix(rest, "blobs/uploads/"):
+		body, _ := io.ReadAll(r.Body)
+		if digest := r.URL.Query().Get("digest"); digest != ociDigest(body) {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		reg.blobs[ociDigest(body)] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(rest, "blobs/"):
+		blob, ok := reg.blobs[strings.TrimPrefix(rest, "blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			reg.blobGets++
+			w.Write(blob)
+		}
+	case strings.HasPrefix(rest, "manifests/"):
+		tag := strings.TrimPrefix(rest, "manifests/")
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			reg.manifests[tag] = body
+			reg.manifests[ociDigest(body)] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		manifest, ok := reg.manifests[tag]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", ociManifestType)
+		w.Write(manifest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPushAndFetchOCI(t *testing.T) {
+	reg := newOCIRegistry(t)
+	f := New()
+	f.SetAuthFunc(func(ctx context.Context, source, host string) (string, error) {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret")), nil
+	})
+	ctx := context.Background()
+	source := reg.ref("banners:v1")
+
+	index := []byte(`{"version": 1, "linux": {"Linux version 6.1.0": ["https://example.com/6.1.0.json.xz"]}}`)
+	digest, err := f.PushOCI(ctx, source, "banners.json", index, map[string]string{"org.opencontainers.image.created": "2026-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatalf("PushOCI() failed: %v", err)
+	}
+	if !strings.HasPrefix(digest, "sha256:") || len(reg.blobs) != 2 {
+		t.Errorf("PushOCI() = %s, stored %d blobs; expected a digest, the config, and the index", digest, len(reg.blobs))
+	}
+
+	data, meta, modified, err := f.FetchWithMeta(ctx, source, nil)
+	if err != nil {
+		t.Fatalf("FetchWithMeta() failed: %v", err)
+	}
+	if !modified || len(data.Linux) != 1 || meta.ETag != digest || meta.Entries != 1 {
+		t.Errorf("FetchWithMeta() = %v, %+v, %v; expected the pushed index at %s", data.Linux, meta, modified, digest)
+	}
+
+	// Unchanged manifest: the index is not downloaded again
+	data, meta, modified, err = f.FetchWithMeta(ctx, source, meta)
+	if err != nil || modified || data != nil || meta.Status != StatusNotModified || reg.blobGets != 1 {
+		t.Errorf("refetch = %v, %+v, %v, %v after %d blob downloads; expected not modified", data, meta, modified, err, reg.blobGets)
+	}
+
+	// Pinned by digest
+	if data, err := f.Fetch(ctx, reg.ref("banners@"+digest)); err != nil || len(data.Linux) != 1 {
+		t.Errorf("Fetch() by digest = %v, %v", data, err)
+	}
+	if err := f.Probe(ctx, source); err != nil {
+		t.Errorf("Probe() failed: %v", err)
+	}
+	if len(reg.scopes) == 0 || reg.scopes[0] != "repository:banners:pull,push" {
+		t.Errorf("token scopes = %v, expected push access for publishing", reg.scopes)
+	}
+}
+
+func TestFetchOCIErrors(t *testing.T) {
+	reg := newOCIRegistry(t)
+	ctx := context.Background()
+
+	// Anonymous: the token service refuses
+	if _, err := New().Fetch(ctx, reg.ref("banners:v1")); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("Fetch() without credentials error = %v, expected a configuration error", err)
+	}
+
+	f := New()
+	f.SetAuthFunc(func(ctx context.Context, source, host string) (string, error) {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret")), nil
+	})
+	var se *SourceError
+	if _, err := f.Fetch(ctx, reg.ref("banners:missing")); !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("Fetch() of a missing tag error = %v, expected status 404", err)
+	}
+
+	reg.manifests["images"] = []byte(`{"schemaVersion": 2, "mediaType": "` + ociManifestType + `", "layers": [
+		{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:a", "size": 1},
+		{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:b", "size": 1}]}`)
+	if _, err := f.Fetch(ctx, reg.ref("banners:images")); !errors.Is(err, ErrNoIndexLayer) {
+		t.Errorf("Fetch() of an image error = %v, expected ErrNoIndexLayer", err)
+	}
+
+	if _, err := f.PushOCI(ctx, reg.ref("banners@sha256:abc"), "banners.json", []byte("{}"), nil); !errors.Is(err, ErrConfiguration) {
+		t.Errorf("PushOCI() to a digest error = %v, expected a configuration error", err)
+	}
+	f.SetOffline(true)
+	if _, err := f.PushOCI(ctx, reg.ref("banners:v1"), "banners.json", []byte("{}"), nil); !errors.Is(err, ErrOffline) {
+		t.Errorf("PushOCI() offline error = %v, expected ErrOffline", err)
+	}
+}
@@ -0,0 +1,214 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxPages bounds how many pages are fetched from one source, so a
+// misbehaving API cannot keep an update running forever.
+const MaxPages = 1000
+
+// ErrPagination indicates a source's pages could not be assembled: a
+// pagination loop, too many pages, or an unusable cursor.
+var ErrPagination = errors.New("pagination error")
+
+// Paging describes how a source spreads its banners across responses.
+// Link headers with rel="next" are always followed; CursorField also
+// follows a cursor returned in each page's body.
+type Paging struct {
+	// CursorField names the top-level response field holding the cursor
+	// of the next page; an empty or missing cursor ends the listing.
+	CursorField string
+	// CursorParam is the query parameter the cursor is sent back in,
+	// "cursor" if empty.
+	CursorParam string
+}
+
+// fetchPages fetches the pages of source after the first, starting at next,
+// and merges them into data. It returns the bytes read.
+func (f *Fetcher) fetchPages(ctx context.Context, source, next string, paging Paging, data *BannerData) (int64, error) {
+	seen := map[string]bool{source: true}
+	var total int64
+
+	for pages := 1; next != ""; pages++ {
+		if pages >= MaxPages {
+			return total, fmt.Errorf("%w: more than %d pages", ErrPagination, MaxPages)
+		}
+		if seen[next] {
+			return total, fmt.Errorf("%w: page %s requested twice", ErrPagination, next)
+		}
+		seen[next] = true
+
+		page, n, nextPage, err := f.fetchPage(ctx, source, next, paging)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		for banner, urls := range page.Linux {
+			data.Linux[banner] = appendUnique(data.Linux[banner], urls)
+		}
+		for banner, fields := range page.Metadata {
+			if data.Metadata == nil {
+				data.Metadata = make(Metadata)
+			}
+			if data.Metadata[banner] == nil {
+				data.Metadata[banner] = fields
+			}
+		}
+		next = nextPage
+	}
+
+	return total, nil
+}
+
+// fetchPage fetches one page of source, returning its banners, the bytes
+// read, and the URL of the page after it, if any.
+func (f *Fetcher) fetchPage(ctx context.Context, source, pageURL string, paging Paging) (*BannerData, int64, string, error) {
+	req, err := f.newRequest(ctx, source, pageURL)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	resp, err := f.clientFor(source).Do(req)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, "", &SourceError{URL: pageURL, StatusCode: resp.StatusCode}
+	}
+
+	cr := newCountingReader(ctx, resp.Body)
+	data, next, err := decodePage(cr, resp.Header, pageURL, paging, f.formatOf(source))
+	if err != nil {
+		return nil, cr.n, "", fmt.Errorf("decoding page %s: %w", pageURL, err)
+	}
+	return data, cr.n, next, nil
+}
+
+// decodePage decodes one page of banners in format read from r and
+// resolves the URL of the next page: the Link header's rel="next" target,
+// or else the configured cursor sent back to pageURL. It returns "" on the
+// last page. Pages with a cursor are read as ISF whatever the format.
+func decodePage(r io.Reader, h http.Header, pageURL string, paging Paging, format string) (*BannerData, string, error) {
+	var data BannerData
+	var cursor string
+
+	if paging.CursorField == "" {
+		decoded, err := decodeIndex(r, format, h.Get("Content-Type"), pageURL)
+		if err != nil {
+			return nil, "", err
+		}
+		data = *decoded
+	} else {
+		var fields map[string]json.RawMessage
+		if err := json.NewDecoder(r).Decode(&fields); err != nil {
+			return nil, "", schemaDecodeError("", err)
+		}
+		if raw, ok := fields["version"]; ok {
+			if err := json.Unmarshal(raw, &data.Version); err != nil {
+				return nil, "", schemaDecodeError("version", err)
+			}
+		}
+		if raw, ok := fields["linux"]; ok {
+			if err := json.Unmarshal(raw, &data.Linux); err != nil {
+				return nil, "", schemaDecodeError("linux", err)
+			}
+		}
+		if raw, ok := fields["metadata"]; ok {
+			if err := json.Unmarshal(raw, &data.Metadata); err != nil {
+				return nil, "", schemaDecodeError("metadata", err)
+			}
+		}
+		if raw, ok := fields[paging.CursorField]; ok && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &cursor); err != nil {
+				return nil, "", fmt.Errorf("%w: %s is not a string", ErrPagination, paging.CursorField)
+			}
+		}
+	}
+	if data.Linux == nil {
+		data.Linux = make(map[string][]string)
+	}
+
+	if next := linkNext(h, pageURL); next != "" {
+		return &data, next, nil
+	}
+	if cursor == "" {
+		return &data, "", nil
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, "", err
+	}
+	param := paging.CursorParam
+	if param == "" {
+		param = "cursor"
+	}
+	q := u.Query()
+	q.Set(param, cursor)
+	u.RawQuery = q.Encode()
+	return &data, u.String(), nil
+}
+
+// linkNext returns the rel="next" target of an RFC 8288 Link header,
+// resolved against pageURL, or "" if there is none.
+func linkNext(h http.Header, pageURL string) string {
+	for _, header := range h.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") || !hasToken(strings.Trim(value, `"`), "next") {
+					continue
+				}
+				base, err := url.Parse(pageURL)
+				if err != nil {
+					return ""
+				}
+				ref, err := base.Parse(target[1 : len(target)-1])
+				if err != nil {
+					return ""
+				}
+				return ref.String()
+			}
+		}
+	}
+	return ""
+}
+
+// hasToken reports whether the space-separated list s contains token,
+// ignoring case.
+func hasToken(s, token string) bool {
+	for _, t := range strings.Fields(s) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameHost reports whether a and b are URLs on the same scheme and host, so
+// a source's token is never sent to another origin a page links to.
+func SameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host)
+}
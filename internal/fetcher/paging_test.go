@@ -0,0 +1,145 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchFollowsLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("page %s sent without the source's token", r.URL)
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Link", `</banners?page=2>; rel="next", </banners?page=3>; rel="last"`)
+			fmt.Fprint(w, `{"version":1,"linux":{"a":["u1"],"b":["u2"]}}`)
+		case "2":
+			w.Header().Set("Link", `<`+server.URL+`/banners?page=3>; rel="next"`)
+			fmt.Fprint(w, `{"linux":{"b":["u3"]}}`)
+		case "3":
+			fmt.Fprint(w, `{"linux":{"c":["u4"]}}`)
+		}
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) { return "secret", nil })
+
+	data, meta, _, err := f.FetchWithMeta(context.Background(), server.URL+"/banners", nil)
+	if err != nil {
+		t.Fatalf("FetchWithMeta() failed: %v", err)
+	}
+	if len(data.Linux) != 3 || len(data.Linux["b"]) != 2 || data.Version != 1 {
+		t.Errorf("pages not assembled: %+v", data)
+	}
+	if meta.ETag != `"v1"` || meta.Entries != 3 {
+		t.Errorf("metadata should describe the first page and all entries, got %+v", meta)
+	}
+}
+
+func TestFetchFollowsCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprint(w, `{"linux":{"a":["u1"]},"next":"c2"}`)
+		case "c2":
+			fmt.Fprint(w, `{"linux":{"b":["u2"]},"next":null}`)
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("after"))
+		}
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetPagingFunc(func(source string) Paging { return Paging{CursorField: "next", CursorParam: "after"} })
+
+	data, err := f.Fetch(context.Background(), server.URL+"/banners?kind=linux")
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if len(data.Linux) != 2 {
+		t.Errorf("pages not assembled: %+v", data.Linux)
+	}
+}
+
+func TestFetchPaginationErrors(t *testing.T) {
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</banners?page=2>; rel="next"`)
+		fmt.Fprint(w, `{"linux":{}}`)
+	}))
+	defer loop.Close()
+
+	if _, err := New().Fetch(context.Background(), loop.URL+"/banners"); !errors.Is(err, ErrPagination) {
+		t.Errorf("a pagination loop should fail with ErrPagination, got %v", err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Link", `</banners?page=2>; rel="next"`)
+		fmt.Fprint(w, `{"linux":{}}`)
+	}))
+	defer failing.Close()
+
+	_, err := New().Fetch(context.Background(), failing.URL+"/banners")
+	var srcErr *SourceError
+	if !errors.As(err, &srcErr) || !strings.Contains(srcErr.URL, "page=2") {
+		t.Errorf("a failed page should be a SourceError for that page, got %v", err)
+	}
+}
+
+func TestLinkNext(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{`<https://api.example/b?page=2>; rel="next"`, "https://api.example/b?page=2"},
+		{`</b?page=2>; rel=next`, "https://api.example/b?page=2"},
+		{`<https://api.example/b?page=1>; rel="prev", <https://api.example/b?page=3>; rel="next last"`, "https://api.example/b?page=3"},
+		{`<https://api.example/b?page=9>; rel="last"`, ""},
+		{``, ""},
+	}
+
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.link != "" {
+			h.Set("Link", tt.link)
+		}
+		if got := linkNext(h, "https://api.example/b"); got != tt.want {
+			t.Errorf("linkNext(%q) = %q, expected %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestTokenNotSentToOtherHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("token sent to a page on another host")
+		}
+		fmt.Fprint(w, `{"linux":{"b":["u2"]}}`)
+	}))
+	defer other.Close()
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<`+other.URL+`/page2>; rel="next"`)
+		fmt.Fprint(w, `{"linux":{"a":["u1"]}}`)
+	}))
+	defer source.Close()
+
+	f := New()
+	f.SetTokenFunc(func(ctx context.Context, source string) (string, error) { return "secret", nil })
+	data, err := f.Fetch(context.Background(), source.URL)
+	if err != nil || len(data.Linux) != 2 {
+		t.Errorf("Fetch() = %v, %v", data, err)
+	}
+}
@@ -0,0 +1,148 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressInterval is how often FetchAllWithMeta reports on the sources
+// still being fetched.
+const ProgressInterval = 200 * time.Millisecond
+
+// Progress describes a source FetchAllWithMeta is fetching: once when it
+// starts, every ProgressInterval while it runs, and once when it is done.
+type Progress struct {
+	Source string
+	// Bytes counts what has been downloaded so far, and what the source
+	// took in all once Done. Local files only report it when done.
+	Bytes   int64
+	Elapsed time.Duration
+	// Done is set on the last report, whose Status is StatusOK,
+	// StatusNotModified, or StatusError with Err.
+	Done   bool
+	Status string
+	Err    error
+}
+
+// ProgressFunc receives the progress of FetchAllWithMeta. Calls do not
+// overlap.
+type ProgressFunc func(Progress)
+
+// SetProgressFunc sets where FetchAllWithMeta reports progress. Without
+// it nothing is tracked.
+func (f *Fetcher) SetProgressFunc(fn ProgressFunc) {
+	f.progress = fn
+}
+
+// liveBytesKey is the context key of the counter downloads add to while
+// progress is tracked.
+type liveBytesKey struct{}
+
+// newCountingReader counts bytes read from r, adding them to the live
+// counter of ctx, if any.
+func newCountingReader(ctx context.Context, r io.Reader) *countingReader {
+	live, _ := ctx.Value(liveBytesKey{}).(*atomic.Int64)
+	return &countingReader{r: r, live: live}
+}
+
+// progressTracker reports on the sources of one FetchAllWithMeta.
+type progressTracker struct {
+	fn ProgressFunc
+
+	mu      sync.Mutex
+	running map[int]*trackedSource
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// trackedSource is a source being fetched.
+type trackedSource struct {
+	source  string
+	started time.Time
+	bytes   atomic.Int64
+}
+
+// newProgressTracker starts reporting to fn, nil if fn is.
+func newProgressTracker(fn ProgressFunc) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	t := &progressTracker{
+		fn:      fn,
+		running: make(map[int]*trackedSource),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.tick()
+	return t
+}
+
+// tick reports on the running sources every ProgressInterval.
+func (t *progressTracker) tick() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			for _, s := range t.running {
+				t.fn(Progress{Source: s.source, Bytes: s.bytes.Load(), Elapsed: time.Since(s.started)})
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// start reports that the source at idx started, returning ctx with the
+// counter its downloads add to.
+func (t *progressTracker) start(ctx context.Context, idx int, source string) context.Context {
+	if t == nil {
+		return ctx
+	}
+	s := &trackedSource{source: source, started: time.Now()}
+	t.mu.Lock()
+	t.running[idx] = s
+	t.fn(Progress{Source: source})
+	t.mu.Unlock()
+	return context.WithValue(ctx, liveBytesKey{}, &s.bytes)
+}
+
+// done reports the result of the source at idx.
+func (t *progressTracker) done(idx int, r Result) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.running[idx]
+	delete(t.running, idx)
+
+	p := Progress{Source: r.Source, Bytes: s.bytes.Load(), Elapsed: time.Since(s.started), Done: true, Err: r.Err}
+	switch {
+	case r.Err != nil:
+		p.Status = StatusError
+	case r.Data == nil:
+		p.Status = StatusNotModified
+	default:
+		p.Status = StatusOK
+	}
+	if r.Meta != nil && r.Meta.Bytes > 0 {
+		p.Bytes = r.Meta.Bytes
+	}
+	t.fn(p)
+}
+
+// close stops reporting.
+func (t *progressTracker) close() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.stopped
+}
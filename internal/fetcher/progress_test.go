@@ -0,0 +1,58 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchAllProgress(t *testing.T) {
+	body := `{"version":1,"linux":{"b":["https://example.com/b.json"]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(3 * ProgressInterval)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	missing := filepath.Join(t.TempDir(), "missing.json")
+
+	var (
+		mu      sync.Mutex
+		busy    bool
+		reports = make(map[string][]Progress)
+	)
+	f := New()
+	f.SetProgressFunc(func(p Progress) {
+		mu.Lock()
+		if busy {
+			t.Error("progress reports overlap")
+		}
+		busy = true
+		reports[p.Source] = append(reports[p.Source], p)
+		busy = false
+		mu.Unlock()
+	})
+	sources := []string{server.URL + "/slow", server.URL + "/fast", missing}
+	f.FetchAll(context.Background(), sources)
+
+	for _, source := range sources {
+		got := reports[source]
+		if len(got) < 2 || got[0].Done || !got[len(got)-1].Done {
+			t.Fatalf("%s: reports = %+v, expected a start and a done", source, got)
+		}
+	}
+	if slow := reports[sources[0]]; len(slow) < 3 {
+		t.Errorf("slow source reported %d times, expected reports while running", len(slow))
+	}
+	if done := reports[sources[1]][len(reports[sources[1]])-1]; done.Status != StatusOK || done.Bytes != int64(len(body)) {
+		t.Errorf("fast source done = %+v, expected ok with %d bytes", done, len(body))
+	}
+	if done := reports[missing][len(reports[missing])-1]; done.Status != StatusError || done.Err == nil {
+		t.Errorf("missing source done = %+v, expected an error", done)
+	}
+}
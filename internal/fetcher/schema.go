@@ -0,0 +1,207 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// IndexVersion is the banner index schema version volatility3 reads.
+const IndexVersion = 1
+
+// How sources that stray from the banner index schema are handled, set
+// per source.
+const (
+	// SchemaReject fails the source, so none of it is merged.
+	SchemaReject = "reject"
+	// SchemaQuarantine drops its malformed banners and merges the rest; a
+	// wrong version still fails it.
+	SchemaQuarantine = "quarantine"
+	// SchemaOff merges the source unchecked.
+	SchemaOff = "off"
+)
+
+// SchemaFunc returns how a source straying from the schema is handled,
+// one of SchemaReject, SchemaQuarantine, or SchemaOff.
+type SchemaFunc func(source string) string
+
+// SchemaError describes a source whose data does not follow the banner
+// index schema: a version field of 1 or 2 and a linux object mapping
+// banners to lists of absolute symbol URLs.
+type SchemaError struct {
+	Problems []string
+}
+
+// maxSchemaProblems bounds the problems a SchemaError message lists.
+const maxSchemaProblems = 3
+
+func (e *SchemaError) Error() string {
+	shown := e.Problems
+	if len(shown) > maxSchemaProblems {
+		shown = shown[:maxSchemaProblems]
+	}
+	msg := "invalid banner index: " + strings.Join(shown, "; ")
+	switch more := len(e.Problems) - len(shown); {
+	case more == 1:
+		msg += " (and 1 more problem)"
+	case more > 1:
+		msg += fmt.Sprintf(" (and %d more problems)", more)
+	}
+	return msg
+}
+
+// Quarantine holds the banners dropped from a source for straying from the
+// schema, with a problem for each.
+type Quarantine struct {
+	Linux    map[string][]string `json:"linux"`
+	Problems []string            `json:"problems"`
+}
+
+// CheckSchema returns how data strays from the banner index schema, nil if
+// it does not.
+func CheckSchema(data *BannerData) *SchemaError {
+	var problems []string
+	if p := versionProblem(data.Version); p != "" {
+		problems = append(problems, p)
+	}
+	for _, banner := range sortedBanners(data.Linux) {
+		if p := bannerProblem(banner, data.Linux[banner]); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &SchemaError{Problems: problems}
+}
+
+// applySchema checks the data of source as mode says, returning the
+// banners quarantined from it, or an error if it is rejected.
+func applySchema(data *BannerData, mode string) (*Quarantine, error) {
+	switch mode {
+	case SchemaOff:
+		return nil, nil
+	case SchemaQuarantine:
+		if p := versionProblem(data.Version); p != "" {
+			return nil, &SchemaError{Problems: []string{p}}
+		}
+		var q *Quarantine
+		for _, banner := range sortedBanners(data.Linux) {
+			p := bannerProblem(banner, data.Linux[banner])
+			if p == "" {
+				continue
+			}
+			if q == nil {
+				q = &Quarantine{Linux: make(map[string][]string)}
+			}
+			q.Linux[banner] = data.Linux[banner]
+			q.Problems = append(q.Problems, p)
+			delete(data.Linux, banner)
+			delete(data.Metadata, banner)
+		}
+		return q, nil
+	}
+	if err := CheckSchema(data); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func versionProblem(version int) string {
+	switch version {
+	case IndexVersion, IndexVersionList:
+		return ""
+	case 0:
+		return "missing version"
+	}
+	return fmt.Sprintf("unsupported version %d, expected %d or %d", version, IndexVersion, IndexVersionList)
+}
+
+// bannerProblem describes what is wrong with a banner and its URLs, ""
+// when nothing is.
+func bannerProblem(banner string, urls []string) string {
+	if strings.TrimSpace(banner) == "" {
+		return "empty banner"
+	}
+	if len(urls) == 0 {
+		return fmt.Sprintf("banner %q: no symbol URLs", banner)
+	}
+	for _, u := range urls {
+		if err := checkSymbolURL(u); err != nil {
+			return fmt.Sprintf("banner %q: URL %q: %v", banner, u, err)
+		}
+	}
+	return ""
+}
+
+// checkSymbolURL checks that u is an absolute URL: a scheme, and a host
+// unless it is a file URL.
+func checkSymbolURL(u string) error {
+	if strings.IndexFunc(u, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return errors.New("contains whitespace or control characters")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return errors.New("not a URL")
+	}
+	switch {
+	case parsed.Scheme == "":
+		return errors.New("not an absolute URL")
+	case parsed.Scheme == "file":
+		if parsed.Path == "" {
+			return errors.New("file URL without a path")
+		}
+	case parsed.Host == "":
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+func sortedBanners(linux map[string][]string) []string {
+	banners := make([]string, 0, len(linux))
+	for banner := range linux {
+		banners = append(banners, banner)
+	}
+	sort.Strings(banners)
+	return banners
+}
+
+// schemaDecodeError turns a JSON type mismatch found decoding the value of
+// field, "" for the whole index, into a SchemaError naming where it is,
+// leaving other decoding errors as they are.
+func schemaDecodeError(field string, err error) error {
+	var te *json.UnmarshalTypeError
+	if !errors.As(err, &te) {
+		return err
+	}
+	switch {
+	case field == "" && te.Field == "":
+		field = "index"
+	case field == "":
+		field = te.Field
+	case te.Field != "":
+		field += "." + te.Field
+	}
+	return &SchemaError{Problems: []string{
+		fmt.Sprintf("%s: got a JSON %s, expected %s (byte %d)", field, te.Value, jsonKind(te.Type.String()), te.Offset),
+	}}
+}
+
+// jsonKind names the JSON value a Go type decodes from.
+func jsonKind(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "map["), strings.HasPrefix(goType, "fetcher."):
+		return "an object"
+	case strings.HasPrefix(goType, "[]"):
+		return "an array"
+	case goType == "string":
+		return "a string"
+	case goType == "int":
+		return "a number"
+	}
+	return goType
+}
@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		data BannerData
+		want []string // Substrings of each problem, in order
+	}{
+		{
+			name: "valid",
+			data: BannerData{Version: 1, Linux: map[string][]string{
+				"Linux version 5.15.0": {"https://example.com/a.json.xz", "file:///srv/isf/a.json"},
+			}},
+		},
+		{
+			name: "missing version",
+			data: BannerData{Linux: map[string][]string{"b": {"https://example.com/b"}}},
+			want: []string{"missing version"},
+		},
+		{
+			name: "future version",
+			data: BannerData{Version: 3, Linux: map[string][]string{}},
+			want: []string{"unsupported version 3"},
+		},
+		{
+			name: "bad banners",
+			data: BannerData{Version: 1, Linux: map[string][]string{
+				" ":          {"https://example.com/x"},
+				"no-urls":    {},
+				"relative":   {"symbols/a.json"},
+				"no-host":    {"https:///a.json"},
+				"whitespace": {"https://example.com/a b.json"},
+			}},
+			want: []string{"empty banner", `"no-host": URL "https:///a.json": missing host`,
+				`"no-urls": no symbol URLs`, "not an absolute URL", "whitespace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSchema(&tt.data)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("CheckSchema() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || len(err.Problems) != len(tt.want) {
+				t.Fatalf("CheckSchema() = %v, want %d problems", err, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(err.Problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, err.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaErrorMessage(t *testing.T) {
+	err := &SchemaError{Problems: []string{"a", "b", "c", "d", "e"}}
+	if got, want := err.Error(), "invalid banner index: a; b; c (and 2 more problems)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestFetchDecodeSchemaError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banners.json")
+	if err := os.WriteFile(path, []byte(`{"version":1,"linux":{"b":"https://example.com/b"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New().Fetch(context.Background(), path)
+	var se *SchemaError
+	if !errors.As(err, &se) {
+		t.Fatalf("Fetch() = %v, want a SchemaError", err)
+	}
+	if !strings.Contains(err.Error(), "linux.b: got a JSON string, expected an array") {
+		t.Errorf("error should locate the mismatch, got %v", err)
+	}
+}
+
+func TestFetchAllChecksSchema(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	mixed := filepath.Join(dir, "mixed.json")
+	unchecked := filepath.Join(dir, "unchecked.json")
+	for _, path := range []string{bad, mixed, unchecked} {
+		raw := `{"version":1,"linux":{"good":["https://example.com/g"],"junk":["not a url"]},` +
+			`"metadata":{"junk":{"arch":"x86_64"}}}`
+		if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := New()
+	f.SetSchemaFunc(func(source string) string {
+		switch source {
+		case mixed:
+			return SchemaQuarantine
+		case unchecked:
+			return SchemaOff
+		}
+		return SchemaReject
+	})
+	results := f.FetchAll(context.Background(), []string{bad, mixed, unchecked})
+
+	var se *SchemaError
+	if !errors.As(results[0].Err, &se) || results[0].Data != nil {
+		t.Errorf("rejected source: err = %v, data = %v", results[0].Err, results[0].Data)
+	}
+
+	q := results[1]
+	if q.Err != nil {
+		t.Fatalf("quarantined source failed: %v", q.Err)
+	}
+	if _, ok := q.Data.Linux["junk"]; ok || len(q.Data.Linux) != 1 || len(q.Data.Metadata) != 0 {
+		t.Errorf("malformed banner should be dropped with its metadata, got %+v", q.Data)
+	}
+	if q.Quarantine == nil || len(q.Quarantine.Linux["junk"]) != 1 || len(q.Quarantine.Problems) != 1 {
+		t.Errorf("Quarantine = %+v, want the junk banner", q.Quarantine)
+	}
+	if q.Meta.Entries != 1 || q.Meta.Quarantined != 1 {
+		t.Errorf("meta = %+v, want 1 entry and 1 quarantined", q.Meta)
+	}
+
+	if results[2].Err != nil || len(results[2].Data.Linux) != 2 {
+		t.Errorf("unchecked source: err = %v, data = %+v", results[2].Err, results[2].Data)
+	}
+}
+
+func TestFetchAllQuarantineRejectsBadVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v3.json")
+	if err := os.WriteFile(path, []byte(`{"version":3,"linux":{"b":["https://example.com/b"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := New()
+	f.SetSchemaFunc(func(string) string { return SchemaQuarantine })
+	results := f.FetchAll(context.Background(), []string{path})
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "unsupported version 3") {
+		t.Errorf("Err = %v, want an unsupported version", results[0].Err)
+	}
+}
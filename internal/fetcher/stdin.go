@@ -0,0 +1,50 @@
+package fetcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// StdinSource is the source read from standard input, for piping a
+// generated index into an update or a merge.
+const StdinSource = "-"
+
+// SetStdin sets where StdinSource is read from, os.Stdin by default.
+func (f *Fetcher) SetStdin(r io.Reader) {
+	f.stdin = r
+}
+
+// readStdin reads standard input on first use; it can be read once only,
+// so later fetches of StdinSource get the same bytes.
+func (f *Fetcher) readStdin() ([]byte, error) {
+	f.stdinOnce.Do(func() {
+		r := f.stdin
+		if r == nil {
+			r = os.Stdin
+		}
+		if file, ok := r.(*os.File); ok {
+			if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				f.stdinErr = fmt.Errorf("%w: standard input is a terminal; pipe a banner index into the %s source", ErrConfiguration, StdinSource)
+				return
+			}
+		}
+		f.stdinData, f.stdinErr = io.ReadAll(r)
+	})
+	return f.stdinData, f.stdinErr
+}
+
+// fetchStdin decodes the banner index on standard input, returning the
+// bytes read.
+func (f *Fetcher) fetchStdin() (*BannerData, int64, error) {
+	body, err := f.readStdin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading standard input: %w", err)
+	}
+	data, err := decodeIndex(bytes.NewReader(body), f.formatOf(StdinSource), "", "")
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding index: %w", err)
+	}
+	return data, int64(len(body)), nil
+}
@@ -0,0 +1,36 @@
+package fetcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFetchStdin(t *testing.T) {
+	f := New()
+	f.SetStdin(strings.NewReader(`{"version": 1, "linux": {"Linux version 6.1.0": ["https://example.com/6.1.0.json"]}}`))
+	f.SetOffline(true)
+	ctx := context.Background()
+
+	data, meta, modified, err := f.FetchWithMeta(ctx, StdinSource, nil)
+	if err != nil {
+		t.Fatalf("FetchWithMeta(-) failed: %v", err)
+	}
+	if !modified || len(data.Linux) != 1 || meta.Entries != 1 || meta.Bytes == 0 {
+		t.Errorf("FetchWithMeta(-) = %v, %+v, %v; expected the piped banner", data.Linux, meta, modified)
+	}
+
+	// Standard input is read once; a second fetch gets the same index
+	if data, err := f.Fetch(ctx, StdinSource); err != nil || len(data.Linux) != 1 {
+		t.Errorf("second Fetch(-) = %v, %v", data, err)
+	}
+	if err := f.Probe(ctx, StdinSource); err != nil {
+		t.Errorf("Probe(-) failed: %v", err)
+	}
+
+	f = New()
+	f.SetStdin(strings.NewReader("<html>"))
+	if _, err := f.Fetch(ctx, StdinSource); err == nil || !strings.Contains(err.Error(), "decoding index") {
+		t.Errorf("Fetch(-) of garbage error = %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Package fixture generates synthetic banner caches of any size, with
+// realistic mixes of distributions, kernel versions, and symbol
+// URLs, for benchmarking storage and lookups against caches larger than
+// any real source.
+package fixture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// chunkSize is how many entries one worker generates at a time. Each chunk
+// has its own random source derived from the seed, so the output depends
+// only on the options, not on scheduling.
+const chunkSize = 4096
+
+// Options configures Generate.
+type Options struct {
+	// Entries is the number of banners to generate.
+	Entries int
+	// Seed makes the output reproducible: the same seed and entries give
+	// the same file.
+	Seed int64
+	// Jobs bounds how many chunks are generated at once, the number of
+	// CPUs if zero.
+	Jobs int
+}
+
+// distro describes how one family of kernels looks in a cache.
+type distro struct {
+	name    string
+	weight  int // out of the sum of all weights
+	arches  []string
+	kernels []string // base versions
+	builder string   // build host, %d for a number
+	gcc     string
+	suffix  string // after the ABI, e.g. "-generic"
+}
+
+var distros = []distro{
+	{"ubuntu", 40, []string{"amd64", "arm64"}, []string{"4.15.0", "5.4.0", "5.15.0", "6.2.0", "6.5.0", "6.8.0"},
+		"buildd@lcy02-amd64-%03d", "gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0", "-generic"},
+	{"debian", 20, []string{"amd64", "arm64"}, []string{"4.19.0", "5.10.0", "6.1.0"},
+		"debian-kernel@lists.debian.org", "gcc-12 (Debian 12.2.0-14) 12.2.0", "-amd64"},
+	{"rhel", 15, []string{"x86_64"}, []string{"3.10.0", "4.18.0", "5.14.0"},
+		"mockbuild@x86-vm-%02d.build.eng.bos.redhat.com", "gcc (GCC) 8.5.0 20210514 (Red Hat 8.5.0-20)", ".el8.x86_64"},
+	{"fedora", 10, []string{"x86_64"}, []string{"6.5.6", "6.7.9", "6.8.11"},
+		"mockbuild@bkernel%02d.iad2.fedoraproject.org", "gcc (GCC) 13.2.1 20240316 (Red Hat 13.2.1-7)", ".fc39.x86_64"},
+	{"suse", 5, []string{"x86_64"}, []string{"5.3.18", "5.14.21", "6.4.0"},
+		"geeko@buildhost", "gcc (SUSE Linux) 7.5.0", "-default"},
+	{"amazon", 5, []string{"x86_64"}, []string{"4.14.336", "5.10.209", "6.1.77"},
+		"mockbuild@ip-10-0-%d-1", "gcc10-gcc (GCC) 10.5.0 20230707 (Red Hat 10.5.0-1)", ".amzn2.x86_64"},
+	{"arch", 5, []string{"x86_64"}, []string{"6.6.10", "6.7.4", "6.8.2"},
+		"linux@archlinux", "gcc (GCC) 13.2.1 20230801", "-arch1-1"},
+}
+
+// hosts serve the synthetic symbol files; example domains, so a fixture
+// never sends a benchmark's traffic to real mirrors.
+var hosts = []string{
+	"https://isf.example.com/symbols",
+	"https://mirror.example.org/volatility3",
+	"https://cdn.example.net/isf",
+}
+
+var totalWeight = func() int {
+	sum := 0
+	for _, d := range distros {
+		sum += d.weight
+	}
+	return sum
+}()
+
+// Generate writes a cache of opts.Entries unique banners to w, in the
+// banners.json format volatility3 reads. Chunks of entries are generated
+// in parallel and written in order as they complete.
+func Generate(w io.Writer, opts Options) error {
+	if opts.Entries < 0 {
+		return fmt.Errorf("invalid number of entries %d", opts.Entries)
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	chunks := (opts.Entries + chunkSize - 1) / chunkSize
+
+	// Each chunk is rendered into its own buffer; the slots channel keeps
+	// at most jobs of them in memory
+	results := make([]chan []byte, chunks)
+	for i := range results {
+		results[i] = make(chan []byte, 1)
+	}
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	go func() {
+		for i := 0; i < chunks; i++ {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				first := i * chunkSize
+				last := first + chunkSize
+				if last > opts.Entries {
+					last = opts.Entries
+				}
+				results[i] <- renderChunk(opts.Seed, i, first, last)
+			}(i)
+		}
+	}()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"version":1,"linux":{`)
+	var err error
+	for i, ch := range results {
+		chunk := <-ch
+		<-slots
+		if err != nil {
+			continue // drain the rest, so no worker blocks
+		}
+		if i > 0 && len(chunk) > 0 {
+			bw.WriteByte(',')
+		}
+		_, err = bw.Write(chunk)
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	bw.WriteString("}}\n")
+	return bw.Flush()
+}
+
+// renderChunk renders entries first to last (exclusive) as comma-separated
+// JSON object members.
+func renderChunk(seed int64, chunk, first, last int) []byte {
+	rng := rand.New(rand.NewSource(seed ^ int64(chunk+1)*0x9E3779B97F4A7C))
+	var buf []byte
+	for i := first; i < last; i++ {
+		banner, urls := entry(rng, i)
+		if i > first {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(banner)
+		value, _ := json.Marshal(urls)
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// entry returns the banner and symbol URLs of entry i. Its build number is
+// derived from i, which makes every banner unique.
+func entry(rng *rand.Rand, i int) (string, []string) {
+	d := pickDistro(rng)
+	arch := d.arches[rng.Intn(len(d.arches))]
+	kernel := d.kernels[rng.Intn(len(d.kernels))]
+	abi := 1 + rng.Intn(200)
+	release := fmt.Sprintf("%s-%d%s", kernel, abi, d.suffix)
+	builder := d.builder
+	if strings.Contains(builder, "%") {
+		builder = fmt.Sprintf(builder, rng.Intn(64))
+	}
+	year := 2018 + rng.Intn(7)
+	date := fmt.Sprintf("%s %s %d %02d:%02d:%02d UTC %d",
+		weekdays[rng.Intn(len(weekdays))], months[rng.Intn(len(months))], 1+rng.Intn(28),
+		rng.Intn(24), rng.Intn(60), rng.Intn(60), year)
+
+	banner := fmt.Sprintf("Linux version %s (%s) (%s) #%d SMP %s", release, builder, d.gcc, i+1, date)
+
+	file := fmt.Sprintf("/%s/%s/%s-%d.json.xz", d.name, arch, release, i+1)
+	n := 1 + rng.Intn(len(hosts))
+	start := rng.Intn(len(hosts))
+	urls := make([]string, n)
+	for j := range urls {
+		urls[j] = hosts[(start+j)%len(hosts)] + file
+	}
+	return banner, urls
+}
+
+// pickDistro picks a distribution by weight.
+func pickDistro(rng *rand.Rand) *distro {
+	n := rng.Intn(totalWeight)
+	for i := range distros {
+		if n < distros[i].weight {
+			return &distros[i]
+		}
+		n -= distros[i].weight
+	}
+	return &distros[len(distros)-1]
+}
+
+var (
+	weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	months   = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+)
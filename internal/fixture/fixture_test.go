@@ -0,0 +1,73 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	entries := 2*chunkSize + 17
+	if err := Generate(&buf, Options{Entries: entries, Seed: 1, Jobs: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	var data struct {
+		Version int                 `json:"version"`
+		Linux   map[string][]string `json:"linux"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatalf("fixture is not valid JSON: %v", err)
+	}
+	if data.Version != 1 || len(data.Linux) != entries {
+		t.Fatalf("fixture has version %d and %d unique banners, expected 1 and %d", data.Version, len(data.Linux), entries)
+	}
+	for banner, urls := range data.Linux {
+		if !strings.HasPrefix(banner, "Linux version ") || len(urls) == 0 || len(urls) > len(hosts) {
+			t.Fatalf("unexpected entry %q: %v", banner, urls)
+		}
+		for _, u := range urls {
+			if !strings.HasPrefix(u, "https://") || !strings.HasSuffix(u, ".json.xz") {
+				t.Fatalf("unexpected URL %q", u)
+			}
+		}
+	}
+
+	// The same options give the same file, whatever the parallelism
+	var again bytes.Buffer
+	if err := Generate(&again, Options{Entries: entries, Seed: 1, Jobs: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("Generate() is not reproducible")
+	}
+
+	var other bytes.Buffer
+	if err := Generate(&other, Options{Entries: entries, Seed: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(buf.Bytes(), other.Bytes()) {
+		t.Error("different seeds gave the same fixture")
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Generate(&buf, Options{}); err != nil || buf.String() != "{\"version\":1,\"linux\":{}}\n" {
+		t.Errorf("Generate() of no entries = %q, %v", buf.String(), err)
+	}
+	if err := Generate(&buf, Options{Entries: -1}); err == nil {
+		t.Error("Generate() of -1 entries should fail")
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := Generate(&buf, Options{Entries: 100000, Seed: int64(i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
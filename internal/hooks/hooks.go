@@ -0,0 +1,91 @@
+// Package hooks runs user-supplied executables around cache updates, found
+// in the hooks.d directory under the config dir.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hook names, which are also the executable names in the hooks directory.
+const (
+	PreUpdate  = "pre-update"
+	PostUpdate = "post-update"
+)
+
+// Timeout bounds a single hook run.
+const Timeout = 5 * time.Minute
+
+// windowsExts are the hook file extensions tried on Windows, which has no
+// executable bit.
+var windowsExts = []string{".exe", ".bat", ".cmd"}
+
+// Run executes the hook name from dir, if present, with env added to the
+// environment. It reports whether a hook ran and returns its combined
+// output. A hook file that is not executable is an error rather than being
+// skipped silently.
+func Run(ctx context.Context, dir, name string, env []string) (bool, string, error) {
+	path, info, err := find(dir, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("%s hook: %w", name, err)
+	}
+	if info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+		return false, "", fmt.Errorf("%s hook %s is not executable", name, path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+	output := strings.TrimSpace(out.String())
+	if err != nil {
+		if output != "" {
+			return true, output, fmt.Errorf("%s hook failed: %w: %s", name, err, output)
+		}
+		return true, output, fmt.Errorf("%s hook failed: %w", name, err)
+	}
+
+	return true, output, nil
+}
+
+// Path returns the hook file Run executes for name in dir, and whether
+// there is one.
+func Path(dir, name string) (string, bool) {
+	path, _, err := find(dir, name)
+	return path, err == nil
+}
+
+// find locates the hook file for name in dir.
+func find(dir, name string) (string, os.FileInfo, error) {
+	if runtime.GOOS != "windows" {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		return path, info, err
+	}
+
+	for _, ext := range windowsExts {
+		path := filepath.Join(dir, name+ext)
+		if info, err := os.Stat(path); err == nil {
+			return path, info, nil
+		}
+	}
+	return "", nil, os.ErrNotExist
+}
@@ -0,0 +1,78 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeHook creates an executable shell script hook in dir.
+func writeHook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell hooks are not supported on windows")
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, PostUpdate, `echo "status=$BASAR_UPDATE_STATUS"`, 0755)
+
+	ran, output, err := Run(context.Background(), dir, PostUpdate, []string{"BASAR_UPDATE_STATUS=updated"})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if !ran {
+		t.Error("Run() should report the hook ran")
+	}
+	if output != "status=updated" {
+		t.Errorf("output = %q, expected %q", output, "status=updated")
+	}
+}
+
+func TestRunMissing(t *testing.T) {
+	ran, _, err := Run(context.Background(), t.TempDir(), PreUpdate, nil)
+	if err != nil || ran {
+		t.Errorf("Run() of a missing hook = %v, %v; expected false, nil", ran, err)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, PreUpdate, "echo 'not today' >&2\nexit 3\n", 0755)
+
+	_, _, err := Run(context.Background(), dir, PreUpdate, nil)
+	if err == nil {
+		t.Fatal("Run() should fail when the hook exits non-zero")
+	}
+	if !strings.Contains(err.Error(), "not today") {
+		t.Errorf("error should include hook output, got: %v", err)
+	}
+}
+
+func TestRunNotExecutable(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, PreUpdate, "exit 0\n", 0644)
+
+	if _, _, err := Run(context.Background(), dir, PreUpdate, nil); err == nil {
+		t.Error("Run() should reject a hook that is not executable")
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, PreUpdate, "exit 0\n", 0755)
+
+	if path, ok := Path(dir, PreUpdate); !ok || path != filepath.Join(dir, PreUpdate) {
+		t.Errorf("Path(%s) = %q, %v", PreUpdate, path, ok)
+	}
+	if _, ok := Path(dir, PostUpdate); ok {
+		t.Errorf("Path(%s) found a missing hook", PostUpdate)
+	}
+}
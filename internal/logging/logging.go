@@ -0,0 +1,130 @@
+// Package logging builds the slog logger used by basar's CLI and library.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats accepted by Options.Format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures New.
+type Options struct {
+	// Format is FormatText (default) or FormatJSON.
+	Format string
+	// Level is the minimum level logged.
+	Level slog.Level
+	// File, if set, receives a copy of every record (always with
+	// timestamps), e.g. for runs from systemd timers or cron.
+	File string
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// ParseLevel parses debug, info, warn, or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// New creates a logger writing to w and, if opts.File is set, to that file.
+// The returned close function releases the log file.
+func New(w io.Writer, opts Options) (*slog.Logger, func() error, error) {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return nil, nil, fmt.Errorf("invalid log format %q (want text or json)", opts.Format)
+	}
+
+	// Interactive text output drops timestamps; the terminal already knows
+	// when things happen
+	handler := newHandler(w, opts.Format, opts.Level, opts.Format == FormatText)
+	closeFn := func() error { return nil }
+
+	if opts.File != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return nil, nil, fmt.Errorf("creating log dir: %w", err)
+		}
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening log file: %w", err)
+		}
+		handler = multiHandler{handler, newHandler(f, opts.Format, opts.Level, false)}
+		closeFn = f.Close
+	}
+
+	return slog.New(handler), closeFn, nil
+}
+
+// newHandler returns a text or JSON handler for w.
+func newHandler(w io.Writer, format string, level slog.Level, dropTime bool) slog.Handler {
+	ho := &slog.HandlerOptions{Level: level}
+	if dropTime {
+		ho.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+	}
+
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, ho)
+	}
+	return slog.NewTextHandler(w, ho)
+}
+
+// multiHandler fans records out to several handlers.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
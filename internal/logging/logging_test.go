@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"loud", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, expected %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, closeFn, err := New(&buf, Options{Level: slog.LevelInfo})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer closeFn()
+
+	logger.Debug("hidden")
+	logger.Info("cached banners", "entries", 3)
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Error("debug record should be filtered at info level")
+	}
+	if !strings.Contains(out, `msg="cached banners" entries=3`) {
+		t.Errorf("unexpected text output: %s", out)
+	}
+	if strings.Contains(out, "time=") {
+		t.Errorf("terminal text output should omit timestamps: %s", out)
+	}
+}
+
+func TestNewJSONWithFile(t *testing.T) {
+	var buf bytes.Buffer
+	logFile := filepath.Join(t.TempDir(), "state", "basar.log")
+
+	logger, closeFn, err := New(&buf, Options{Format: FormatJSON, Level: slog.LevelWarn, File: logFile})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	logger.Warn("source failed", "source", "https://example.com")
+	if err := closeFn(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("stderr output is not JSON: %v: %s", err, buf.String())
+	}
+	if record["msg"] != "source failed" || record["source"] != "https://example.com" {
+		t.Errorf("unexpected record: %v", record)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("log file not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"time"`) {
+		t.Errorf("log file records should carry timestamps: %s", data)
+	}
+}
+
+func TestNewInvalidFormat(t *testing.T) {
+	if _, _, err := New(&bytes.Buffer{}, Options{Format: "xml"}); err == nil {
+		t.Error("New() should reject unknown formats")
+	}
+}
@@ -0,0 +1,115 @@
+// Package memscan finds Linux kernel banners in memory images, raw or
+// LiME, without parsing them, so the symbols for an image can be resolved
+// before volatility3 runs.
+package memscan
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// marker starts every Linux kernel banner.
+var marker = []byte("Linux version ")
+
+// MaxBannerLen bounds the length of a banner; longer strings starting with
+// the marker are not banners.
+const MaxBannerLen = 1024
+
+// chunkSize is how much of the image is scanned at a time.
+const chunkSize = 4 << 20
+
+// Banner is a kernel banner found in an image.
+type Banner struct {
+	// Text is the banner without its trailing newline.
+	Text string `json:"banner"`
+	// Offset is where it first occurs in the image, and Count how many
+	// times it does.
+	Offset int64 `json:"offset"`
+	Count  int   `json:"count"`
+}
+
+// Banners scans the image read from r for kernel banners: "Linux version "
+// followed by a release number and printable text up to a newline or NUL,
+// including a "#" build number, which rules out format strings and prose
+// quoting the marker. They are returned in the order of their first
+// occurrence. LiME range headers are scanned as data, so a banner split
+// across two ranges is missed, as it is by volatility3.
+func Banners(r io.Reader) ([]Banner, error) {
+	found := make(map[string]*Banner)
+	buf := make([]byte, chunkSize+MaxBannerLen)
+	var offset int64 // image offset of buf[0]
+	carry := 0
+	for {
+		n, err := io.ReadFull(r, buf[carry:])
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return nil, err
+		}
+		data := buf[:carry+n]
+
+		// Markers too close to the end to hold a whole banner are scanned
+		// again with the next chunk
+		limit := len(data)
+		if !eof {
+			limit -= MaxBannerLen
+		}
+		for i := 0; i < limit; {
+			j := bytes.Index(data[i:], marker)
+			if j < 0 || i+j >= limit {
+				break
+			}
+			p := i + j
+			if text, ok := bannerAt(data[p:]); ok {
+				if b := found[text]; b != nil {
+					b.Count++
+				} else {
+					found[text] = &Banner{Text: text, Offset: offset + int64(p), Count: 1}
+				}
+			}
+			i = p + len(marker)
+		}
+		if eof {
+			break
+		}
+
+		carry = copy(buf, data[limit:])
+		offset += int64(limit)
+	}
+
+	banners := make([]Banner, 0, len(found))
+	for _, b := range found {
+		banners = append(banners, *b)
+	}
+	sort.Slice(banners, func(i, j int) bool {
+		return banners[i].Offset < banners[j].Offset
+	})
+	return banners, nil
+}
+
+// bannerAt returns the banner at the start of data, which begins with the
+// marker, and whether it is one.
+func bannerAt(data []byte) (string, bool) {
+	if len(data) > MaxBannerLen {
+		data = data[:MaxBannerLen]
+	}
+	end := bytes.IndexAny(data, "\n\x00")
+	if end < 0 {
+		return "", false
+	}
+	text := data[:end]
+	if len(text) == len(marker) || text[len(marker)] < '0' || text[len(marker)] > '9' {
+		return "", false
+	}
+	for _, c := range text {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+	banner := strings.TrimRight(string(text), " ")
+	if !strings.Contains(banner, "#") {
+		return "", false
+	}
+	return banner, true
+}
@@ -0,0 +1,67 @@
+package memscan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const ubuntu = "Linux version 5.15.0-91-generic (buildd@lcy02-amd64-045) (gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0) #101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023"
+
+func TestBanners(t *testing.T) {
+	var image bytes.Buffer
+	image.WriteString("EMiL\x01\x00\x00\x00")
+	image.Write(make([]byte, 100))
+	image.WriteString(ubuntu + "\n\x00")
+	image.WriteString("Linux version %s (%s@%s) (%s) %s\n") // printk format
+	image.WriteString("Linux version 2.6 or later is required\n")
+	image.WriteString("Linux version 6.1.0 (no build number)\x00")
+	image.WriteString("Linux version 4.19.0 #1 SMP \xff\xfe\n") // binary junk
+	image.Write(make([]byte, 50))
+	image.WriteString("[    0.000000] " + ubuntu + "\n") // dmesg
+
+	banners, err := Banners(&image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banners) != 1 {
+		t.Fatalf("Banners() = %+v, expected only the Ubuntu banner", banners)
+	}
+	if b := banners[0]; b.Text != ubuntu || b.Offset != 108 || b.Count != 2 {
+		t.Errorf("Banners() = %+v, expected it at offset 108, twice", b)
+	}
+}
+
+func TestBannersAcrossChunks(t *testing.T) {
+	// Banners straddling the chunk boundary, and one at the very end
+	image := make([]byte, chunkSize-10)
+	image = append(image, ubuntu+"\n"...)
+	image = append(image, make([]byte, chunkSize-MaxBannerLen/2)...)
+	second := "Linux version 6.1.0-18-amd64 (debian-kernel@lists.debian.org) #1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1"
+	image = append(image, second+"\n"...)
+	image = append(image, make([]byte, 30)...)
+	image = append(image, "Linux version 3.10.0 #1\x00"...)
+
+	banners, err := Banners(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range banners {
+		got = append(got, b.Text)
+	}
+	want := []string{ubuntu, second, "Linux version 3.10.0 #1"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Banners() = %q, expected %q", got, want)
+	}
+	if banners[0].Offset != chunkSize-10 || banners[0].Count != 1 {
+		t.Errorf("first banner = offset %d count %d, expected %d and 1", banners[0].Offset, banners[0].Count, chunkSize-10)
+	}
+}
+
+func TestBannersEmpty(t *testing.T) {
+	banners, err := Banners(strings.NewReader("Linux version 5.15.0 #1"))
+	if err != nil || len(banners) != 0 {
+		t.Errorf("Banners() of an unterminated banner = %v, %v; expected none", banners, err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package metrics renders cache statistics in the Prometheus text
+// exposition format, for the serve endpoint and node_exporter's textfile
+// collector.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+// ContentType is the media type of the exposition format written by Write.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Write renders s as Prometheus metrics.
+func Write(w io.Writer, s cache.Stats) error {
+	var b bytes.Buffer
+
+	family(&b, "basar_cache_valid", "gauge", "Whether a readable banner cache exists.")
+	sample(&b, "basar_cache_valid", "", boolValue(s.Valid))
+
+	if s.Valid {
+		family(&b, "basar_cache_entries", "gauge", "Number of banners in the cache.")
+		sample(&b, "basar_cache_entries", "", float64(s.Entries))
+		family(&b, "basar_cache_size_bytes", "gauge", "Size of the cache file in bytes.")
+		sample(&b, "basar_cache_size_bytes", "", float64(s.Size))
+		family(&b, "basar_cache_age_seconds", "gauge", "Seconds since the cache file was written.")
+		sample(&b, "basar_cache_age_seconds", "", float64(s.AgeSeconds))
+	}
+
+	if s.LastUsed != nil {
+		family(&b, "basar_cache_last_used_timestamp_seconds", "gauge", "Unix time of the last read of the cache by a client.")
+		sample(&b, "basar_cache_last_used_timestamp_seconds", "", float64(s.LastUsed.LastUsed.Unix()))
+		family(&b, "basar_cache_reads_total", "counter", "Reads of the cache recorded by basar serve.")
+		sample(&b, "basar_cache_reads_total", "", float64(s.LastUsed.Reads))
+	}
+
+	if s.LastUpdate != nil {
+		family(&b, "basar_last_update_success", "gauge", "Whether the last update attempt succeeded.")
+		sample(&b, "basar_last_update_success", "", boolValue(s.LastUpdate.Success))
+		family(&b, "basar_last_update_timestamp_seconds", "gauge", "Unix time of the last update attempt.")
+		sample(&b, "basar_last_update_timestamp_seconds", "", float64(s.LastUpdate.At.Unix()))
+	}
+
+	if len(s.Sources) > 0 {
+		family(&b, "basar_source_up", "gauge", "Whether the last fetch of a source succeeded.")
+		for _, src := range s.Sources {
+			sample(&b, "basar_source_up", sourceLabel(src.Source), boolValue(src.Status != fetcher.StatusError))
+		}
+		family(&b, "basar_source_failures_total", "counter", "Failed fetches per source since metadata was cleared.")
+		for _, src := range s.Sources {
+			sample(&b, "basar_source_failures_total", sourceLabel(src.Source), float64(src.Failures))
+		}
+		family(&b, "basar_source_entries", "gauge", "Banners in the last successful fetch of a source.")
+		for _, src := range s.Sources {
+			sample(&b, "basar_source_entries", sourceLabel(src.Source), float64(src.Entries))
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteIntegrity renders the outcome of the last integrity check as
+// Prometheus metrics.
+func WriteIntegrity(w io.Writer, r cache.IntegrityReport) error {
+	var b bytes.Buffer
+
+	family(&b, "basar_integrity_ok", "gauge", "Whether the last integrity check found the cache intact.")
+	sample(&b, "basar_integrity_ok", "", boolValue(r.OK))
+	family(&b, "basar_integrity_timestamp_seconds", "gauge", "Unix time of the last integrity check.")
+	sample(&b, "basar_integrity_timestamp_seconds", "", float64(r.At.Unix()))
+	family(&b, "basar_integrity_check_ok", "gauge", "Whether each check of the last integrity check passed.")
+	for _, ch := range r.Checks {
+		sample(&b, "basar_integrity_check_ok", `{check="`+labelEscaper.Replace(ch.Name)+`"}`, boolValue(ch.OK))
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteFile renders s to path atomically, as the textfile collector requires.
+func WriteFile(path string, s cache.Stats) error {
+	var b bytes.Buffer
+	if err := Write(&b, s); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, b.Bytes(), cache.FileMode); err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming metrics: %w", err)
+	}
+
+	return nil
+}
+
+// family writes the HELP and TYPE lines of a metric.
+func family(b *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single metric line.
+func sample(b *bytes.Buffer, name, labels string, value float64) {
+	fmt.Fprintf(b, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// sourceLabel returns the label set identifying a source.
+func sourceLabel(source string) string {
+	return `{source="` + labelEscaper.Replace(source) + `"}`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// boolValue converts a condition to a 0/1 sample value.
+func boolValue(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
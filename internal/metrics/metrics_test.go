@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calilkhalil/basar/internal/cache"
+	"github.com/calilkhalil/basar/internal/fetcher"
+)
+
+func TestWrite(t *testing.T) {
+	stats := cache.Stats{
+		Valid:      true,
+		Entries:    42,
+		Size:       2048,
+		AgeSeconds: 300,
+		LastUpdate: &fetcher.UpdateStatus{At: time.Unix(1700000000, 0), Success: true},
+		LastUsed:   &cache.Usage{LastUsed: time.Unix(1700000100, 0), Via: cache.UsageViaServe, Reads: 7},
+		Sources: []cache.SourceStats{
+			{Source: "https://a.example/banners.json", Status: fetcher.StatusOK, Entries: 40},
+			{Source: `/tmp/odd "name".json`, Status: fetcher.StatusError, Failures: 3},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, stats); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE basar_cache_entries gauge\n",
+		"basar_cache_valid 1\n",
+		"basar_cache_entries 42\n",
+		"basar_cache_size_bytes 2048\n",
+		"basar_cache_age_seconds 300\n",
+		"basar_cache_last_used_timestamp_seconds 1700000100\n",
+		"basar_cache_reads_total 7\n",
+		"basar_last_update_success 1\n",
+		"basar_last_update_timestamp_seconds 1700000000\n",
+		"# TYPE basar_source_failures_total counter\n",
+		`basar_source_up{source="https://a.example/banners.json"} 1` + "\n",
+		`basar_source_up{source="/tmp/odd \"name\".json"} 0` + "\n",
+		`basar_source_failures_total{source="/tmp/odd \"name\".json"} 3` + "\n",
+		`basar_source_entries{source="https://a.example/banners.json"} 40` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteNoCache(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, cache.Stats{}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, "basar_cache_valid 0\n") {
+		t.Errorf("expected basar_cache_valid 0, got:\n%s", out)
+	}
+	for _, absent := range []string{"basar_cache_entries", "basar_last_update_success", "basar_source_up"} {
+		if strings.Contains(out, absent) {
+			t.Errorf("output should not contain %s without data", absent)
+		}
+	}
+}
+
+func TestWriteIntegrity(t *testing.T) {
+	report := cache.IntegrityReport{
+		At: time.Unix(1700000000, 0),
+		Checks: []cache.IntegrityCheck{
+			{Name: "checksum", OK: false},
+			{Name: "schema", OK: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteIntegrity(&buf, report); err != nil {
+		t.Fatalf("WriteIntegrity() failed: %v", err)
+	}
+	for _, want := range []string{
+		"basar_integrity_ok 0\n",
+		"basar_integrity_timestamp_seconds 1700000000\n",
+		`basar_integrity_check_ok{check="checksum"} 0` + "\n",
+		`basar_integrity_check_ok{check="schema"} 1` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "basar.prom")
+
+	if err := WriteFile(path, cache.Stats{Valid: true, Entries: 7}); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), "basar_cache_entries 7\n") {
+		t.Errorf("unexpected metrics file:\n%s", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temp file left behind: %v", entries)
+	}
+}
@@ -0,0 +1,95 @@
+// Package negotiate obtains the Kerberos tokens of HTTP Negotiate
+// authentication (SPNEGO, RFC 4559) with the current user's credentials,
+// for proxies and source servers in Active Directory environments. Windows
+// provides them through SSPI; elsewhere a command prints them, since
+// GSSAPI is not reachable without cgo.
+package negotiate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds how long a token command may run.
+const CommandTimeout = 30 * time.Second
+
+// ErrUnsupported indicates a system without built-in Negotiate support,
+// where a token command is needed.
+var ErrUnsupported = errors.New("Negotiate authentication needs BASAR_NEGOTIATE_CMD on this system")
+
+// Client obtains Negotiate tokens.
+type Client struct {
+	// Cmd, if set, prints a base64 token on its first line for the
+	// service principal in BASAR_NEGOTIATE_SPN, e.g. HTTP@proxy.corp.example.
+	// It replaces the system's own support.
+	Cmd string
+}
+
+// Service returns the GSSAPI host-based service name of the HTTP server on
+// host, a host name with or without a port: HTTP@host.
+func Service(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return "HTTP@" + strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// Token returns a base64 token authenticating to the HTTP server on host,
+// the value of an "Authorization: Negotiate" header. Each call makes a new
+// token, since servers may reject replayed ones.
+func (c *Client) Token(ctx context.Context, host string) (string, error) {
+	service := Service(host)
+	if c.Cmd != "" {
+		return fromCmd(ctx, c.Cmd, service)
+	}
+	token, err := systemToken(service)
+	if err != nil {
+		return "", fmt.Errorf("Negotiate token for %s: %w", service, err)
+	}
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// fromCmd runs command with service in BASAR_NEGOTIATE_SPN and returns the
+// token it prints.
+func fromCmd(ctx context.Context, command, service string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "BASAR_NEGOTIATE_SPN="+service)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("negotiate command failed for %s: %w: %s", service, err, msg)
+		}
+		return "", fmt.Errorf("negotiate command failed for %s: %w", service, err)
+	}
+
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("negotiate command produced no token for %s", service)
+	}
+	if _, err := base64.StdEncoding.DecodeString(token); err != nil {
+		return "", fmt.Errorf("negotiate command printed an invalid token for %s: %w", service, err)
+	}
+	return token, nil
+}
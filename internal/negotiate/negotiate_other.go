@@ -0,0 +1,8 @@
+//go:build !windows
+
+package negotiate
+
+// systemToken would ask GSSAPI for a token, which needs cgo.
+func systemToken(service string) ([]byte, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,57 @@
+package negotiate
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	tests := map[string]string{
+		"proxy.corp.example:8080": "HTTP@proxy.corp.example",
+		"Symbols.Corp.Example":    "HTTP@symbols.corp.example",
+		"[2001:db8::1]:3128":      "HTTP@2001:db8::1",
+	}
+	for host, want := range tests {
+		if got := Service(host); got != want {
+			t.Errorf("Service(%q) = %q, expected %q", host, got, want)
+		}
+	}
+}
+
+func TestTokenFromCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	c := &Client{Cmd: `printf '%s' "$BASAR_NEGOTIATE_SPN" | base64`}
+	token, err := c.Token(context.Background(), "proxy.corp.example:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := base64.StdEncoding.DecodeString(token); string(raw) != "HTTP@proxy.corp.example" {
+		t.Errorf("Token() = %q, expected the command's token for HTTP@proxy.corp.example", token)
+	}
+
+	for cmd, want := range map[string]string{
+		"true":                         "no token",
+		"echo not-base64!":             "invalid token",
+		"echo 'no ticket' >&2; exit 1": "no ticket",
+	} {
+		c := &Client{Cmd: cmd}
+		if _, err := c.Token(context.Background(), "proxy.corp.example"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Token() with %q = %v, expected an error mentioning %q", cmd, err, want)
+		}
+	}
+}
+
+func TestTokenUnsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows provides Negotiate tokens")
+	}
+	if _, err := (&Client{}).Token(context.Background(), "proxy.corp.example"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Token() without a command = %v, expected ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,127 @@
+package negotiate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// secur32 is loaded from the system directory, never the working one.
+var secur32 = syscall.NewLazyDLL(filepath.Join(systemRoot(), "System32", "secur32.dll"))
+
+var (
+	procAcquireCredentialsHandle  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContext = secur32.NewProc("InitializeSecurityContextW")
+	procFreeCredentialsHandle     = secur32.NewProc("FreeCredentialsHandle")
+	procDeleteSecurityContext     = secur32.NewProc("DeleteSecurityContext")
+	procFreeContextBuffer         = secur32.NewProc("FreeContextBuffer")
+)
+
+func systemRoot() string {
+	if root := os.Getenv("SystemRoot"); root != "" {
+		return root
+	}
+	return `C:\Windows`
+}
+
+// SSPI constants, from sspi.h.
+const (
+	secpkgCredOutbound      = 2
+	securityNativeDrep      = 0x10
+	iscReqAllocateMemory    = 0x100
+	iscReqConnection        = 0x800
+	secbufferToken          = 2
+	secbufferVersion        = 0
+	secEOK                  = 0
+	secIContinueNeeded      = 0x00090312
+	secICompleteNeeded      = 0x00090313
+	secICompleteAndContinue = 0x00090314
+)
+
+type secHandle struct {
+	lower, upper uintptr
+}
+
+type timeStamp struct {
+	low, high uint32
+}
+
+type secBuffer struct {
+	size       uint32
+	bufferType uint32
+	buffer     *byte
+}
+
+type secBufferDesc struct {
+	version uint32
+	count   uint32
+	buffers *secBuffer
+}
+
+// systemToken returns the first token of an SSPI Negotiate context with
+// the logged-on user's credentials. Kerberos needs no more; NTLM, which
+// takes a round trip, is not supported.
+func systemToken(service string) ([]byte, error) {
+	pkg, err := syscall.UTF16PtrFromString("Negotiate")
+	if err != nil {
+		return nil, err
+	}
+	// SSPI names services HTTP/host rather than HTTP@host
+	target, err := syscall.UTF16PtrFromString("HTTP/" + service[len("HTTP@"):])
+	if err != nil {
+		return nil, err
+	}
+	if err := secur32.Load(); err != nil {
+		return nil, err
+	}
+
+	var cred secHandle
+	var expiry timeStamp
+	status, _, _ := procAcquireCredentialsHandle.Call(
+		0,
+		uintptr(unsafe.Pointer(pkg)),
+		secpkgCredOutbound,
+		0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&cred)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if status != secEOK {
+		return nil, fmt.Errorf("AcquireCredentialsHandle: status %#x", uint32(status))
+	}
+	defer procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&cred)))
+
+	var ctx secHandle
+	out := secBuffer{bufferType: secbufferToken}
+	outDesc := secBufferDesc{version: secbufferVersion, count: 1, buffers: &out}
+	var attrs uint32
+	status, _, _ = procInitializeSecurityContext.Call(
+		uintptr(unsafe.Pointer(&cred)),
+		0,
+		uintptr(unsafe.Pointer(target)),
+		iscReqAllocateMemory|iscReqConnection,
+		0,
+		securityNativeDrep,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&ctx)),
+		uintptr(unsafe.Pointer(&outDesc)),
+		uintptr(unsafe.Pointer(&attrs)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	switch status {
+	case secEOK, secIContinueNeeded, secICompleteNeeded, secICompleteAndContinue:
+	default:
+		return nil, fmt.Errorf("InitializeSecurityContext: status %#x", uint32(status))
+	}
+	defer procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&ctx)))
+	if out.buffer == nil || out.size == 0 {
+		return nil, fmt.Errorf("InitializeSecurityContext returned no token")
+	}
+	defer procFreeContextBuffer.Call(uintptr(unsafe.Pointer(out.buffer)))
+
+	token := make([]byte, out.size)
+	copy(token, unsafe.Slice(out.buffer, out.size))
+	return token, nil
+}
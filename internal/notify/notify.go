@@ -0,0 +1,241 @@
+// Package notify sends update events to notification backends: webhooks,
+// the desktop, email over SMTP, and commands.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Timeout bounds a single notification.
+const Timeout = 30 * time.Second
+
+// Event is something worth telling people about, such as a failed update.
+type Event struct {
+	// Kind is the event name, e.g. "update-failure".
+	Kind string    `json:"event"`
+	At   time.Time `json:"at"`
+	Host string    `json:"host,omitempty"`
+
+	// Summary describes the event in one line.
+	Summary string `json:"summary"`
+
+	// Status is the update's outcome: "updated", "unchanged", or "failed".
+	Status     string   `json:"status,omitempty"`
+	Generation uint64   `json:"generation,omitempty"`
+	Entries    int      `json:"entries"`
+	Added      []string `json:"added,omitempty"`
+	Removed    []string `json:"removed,omitempty"`
+	// FailedSources lists the sources that failed, as "SOURCE: ERROR".
+	FailedSources []string `json:"failed_sources,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Notifier delivers events to one target.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// New returns the notifier for a backend ("webhook", "email", "command",
+// or "desktop") sending to target with its options.
+func New(backend, target string, options map[string]string) (Notifier, error) {
+	switch backend {
+	case "webhook":
+		return &Webhook{URL: target}, nil
+	case "email":
+		e := &Email{Addr: target, From: options["from"], To: strings.Split(options["to"], ",")}
+		if name := options["username_env"]; name != "" {
+			e.Username = os.Getenv(name)
+		}
+		if name := options["password_env"]; name != "" {
+			e.Password = os.Getenv(name)
+		}
+		return e, nil
+	case "command":
+		return &Command{Command: target}, nil
+	case "desktop":
+		return Desktop{}, nil
+	}
+	return nil, fmt.Errorf("unknown notification backend %q", backend)
+}
+
+// Webhook posts events as JSON to a URL. The payload also carries the
+// summary as "text", which Slack and Mattermost incoming webhooks display.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(struct {
+		Event
+		Text string `json:"text"`
+	}{e, e.Summary})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Email sends events through an SMTP server, authenticating with Username
+// and Password when set.
+type Email struct {
+	Addr     string // host:port
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+// sendMail is smtp.SendMail; replaced in tests.
+var sendMail = smtp.SendMail
+
+// Notify implements Notifier. net/smtp has no context support, so ctx only
+// stops a send that has not started.
+func (m *Email) Notify(ctx context.Context, e Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := strings.Cut(m.Addr, ":")
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return sendMail(m.Addr, auth, m.From, m.To, m.message(e))
+}
+
+// message renders e as a plain text mail.
+func (m *Email) message(e Event) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", e.Summary)
+	fmt.Fprintf(&b, "Date: %s\r\n", e.At.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", e.Summary)
+	fmt.Fprintf(&b, "Event: %s\r\nHost: %s\r\n", e.Kind, e.Host)
+	if e.Status != "" {
+		fmt.Fprintf(&b, "Status: %s\r\n", e.Status)
+	}
+	fmt.Fprintf(&b, "Entries: %d\r\n", e.Entries)
+	if e.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\r\n", e.Error)
+	}
+	writeList(&b, "Failed sources", e.FailedSources)
+	writeList(&b, "New banners", e.Added)
+	writeList(&b, "Removed banners", e.Removed)
+	return b.Bytes()
+}
+
+// writeList writes a titled list to a mail body, nothing if it is empty.
+func writeList(b *bytes.Buffer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\r\n%s (%d):\r\n", title, len(items))
+	for _, item := range items {
+		fmt.Fprintf(b, "  %s\r\n", item)
+	}
+}
+
+// Command runs a shell command for each event, with the event as JSON on
+// its stdin and BASAR_EVENT, BASAR_SUMMARY, BASAR_ENTRIES, and
+// BASAR_UPDATE_ERROR in its environment.
+type Command struct {
+	Command string
+}
+
+// Notify implements Notifier.
+func (c *Command) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.Command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"BASAR_EVENT="+e.Kind,
+		"BASAR_SUMMARY="+e.Summary,
+		fmt.Sprintf("BASAR_ENTRIES=%d", e.Entries),
+		"BASAR_UPDATE_ERROR="+e.Error,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if output := strings.TrimSpace(string(out)); output != "" {
+			return fmt.Errorf("notify command failed: %w: %s", err, output)
+		}
+		return fmt.Errorf("notify command failed: %w", err)
+	}
+	return nil
+}
+
+// ErrDesktopUnsupported indicates a platform without a supported desktop
+// notification tool.
+var ErrDesktopUnsupported = errors.New("desktop notifications are not supported on " + runtime.GOOS)
+
+// Desktop shows events in the desktop's notification center, through
+// notify-send on Linux and the BSDs and osascript on macOS.
+type Desktop struct{}
+
+// desktopCommand returns the command showing a notification, nil if the
+// platform has none; replaced in tests.
+var desktopCommand = func(ctx context.Context, title, body string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		return exec.CommandContext(ctx, "osascript", "-e", script)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return exec.CommandContext(ctx, "notify-send", "--app-name=basar", title, body)
+	}
+	return nil
+}
+
+// Notify implements Notifier.
+func (Desktop) Notify(ctx context.Context, e Event) error {
+	body := e.Summary
+	if e.Error != "" {
+		body = e.Error
+	}
+	cmd := desktopCommand(ctx, "basar: "+e.Kind, body)
+	if cmd == nil {
+		return ErrDesktopUnsupported
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var event = Event{
+	Kind:    "new-banners",
+	At:      time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	Host:    "analyst01",
+	Summary: "basar found 1 new banners",
+	Entries: 42,
+	Added:   []string{"Linux version 6.8.0-31-generic"},
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New("webhook", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got["event"] != "new-banners" || got["text"] != event.Summary || got["entries"] != float64(42) {
+		t.Errorf("webhook payload = %v", got)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := (&Webhook{URL: srv.URL}).Notify(context.Background(), event)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Notify() = %v, expected the status", err)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	t.Setenv("TEST_SMTP_USER", "basar")
+	t.Setenv("TEST_SMTP_PASS", "secret")
+	n, err := New("email", "smtp.example.com:587", map[string]string{
+		"to": "ir@example.com,soc@example.com", "from": "basar@example.com",
+		"username_env": "TEST_SMTP_USER", "password_env": "TEST_SMTP_PASS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := n.(*Email)
+	if m.Username != "basar" || m.Password != "secret" || len(m.To) != 2 {
+		t.Errorf("New() = %+v", m)
+	}
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	defer func(orig func(string, smtp.Auth, string, []string, []byte) error) { sendMail = orig }(sendMail)
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		if auth == nil {
+			t.Error("expected authentication")
+		}
+		return nil
+	}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "basar@example.com" || len(gotTo) != 2 {
+		t.Errorf("sent to %s from %s to %v", gotAddr, gotFrom, gotTo)
+	}
+	msg := string(gotMsg)
+	for _, want := range []string{"Subject: basar found 1 new banners\r\n", "To: ir@example.com, soc@example.com\r\n", "  Linux version 6.8.0-31-generic\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	n, _ := New("command", `{ echo "$BASAR_EVENT $BASAR_ENTRIES"; cat; } > `+out, nil)
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), "new-banners 42\n{\"event\":\"new-banners\"") {
+		t.Errorf("command got %q", got)
+	}
+
+	n, _ = New("command", "echo broken >&2; exit 3", nil)
+	if err := n.Notify(context.Background(), event); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Notify() = %v, expected the command's output", err)
+	}
+}
+
+func TestUnknownBackend(t *testing.T) {
+	if _, err := New("pager", "x", nil); err == nil {
+		t.Error("New() accepted an unknown backend")
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	m := &Email{From: "basar@example.com", To: []string{"ir@example.com"}}
+	digest := Event{
+		Kind: "update-digest", Status: "updated", Summary: "basar update on analyst01: updated, 41 banners",
+		Entries: 41, Removed: []string{"banner1", "banner2"}, FailedSources: []string{"https://down.example.com: timeout"},
+	}
+	msg := string(m.message(digest))
+	for _, want := range []string{"Status: updated\r\n", "Removed banners (2):\r\n  banner1\r\n  banner2\r\n", "Failed sources (1):\r\n  https://down.example.com: timeout\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "New banners") {
+		t.Errorf("message lists no new banners:\n%s", msg)
+	}
+}
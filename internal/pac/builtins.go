@@ -0,0 +1,308 @@
+package pac
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The functions every PAC environment provides, as documented by Netscape
+// and extended by Microsoft for IPv6.
+
+// dnsTimeout bounds one DNS lookup made by a script.
+const dnsTimeout = 5 * time.Second
+
+// lookupIP resolves host; replaced in tests.
+var lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// localAddrs returns the host's addresses for myIpAddress, preferring the
+// one that routes to the internet; replaced in tests.
+var localAddrs = func() []net.IP {
+	// Connecting a UDP socket sends nothing, but picks the source address
+	if conn, err := net.Dial("udp", "192.0.2.1:9"); err == nil {
+		defer conn.Close()
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsLoopback() {
+			return []net.IP{addr.IP}
+		}
+	}
+	var ips []net.IP
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() && !n.IP.IsLinkLocalUnicast() {
+			ips = append(ips, n.IP)
+		}
+	}
+	return ips
+}
+
+// now returns the current time for the date and time functions; replaced
+// in tests.
+var now = time.Now
+
+var globals = map[string]builtin{
+	"isPlainHostName": func(_ *interp, args []value) (value, error) {
+		return !strings.Contains(toString(arg(args, 0)), "."), nil
+	},
+	"dnsDomainIs": func(_ *interp, args []value) (value, error) {
+		host, domain := strings.ToLower(toString(arg(args, 0))), strings.ToLower(toString(arg(args, 1)))
+		return strings.HasSuffix(host, domain), nil
+	},
+	"localHostOrDomainIs": func(_ *interp, args []value) (value, error) {
+		host, hostdom := strings.ToLower(toString(arg(args, 0))), strings.ToLower(toString(arg(args, 1)))
+		if host == hostdom {
+			return true, nil
+		}
+		return !strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."), nil
+	},
+	"dnsDomainLevels": func(_ *interp, args []value) (value, error) {
+		return float64(strings.Count(toString(arg(args, 0)), ".")), nil
+	},
+	"shExpMatch": func(_ *interp, args []value) (value, error) {
+		return shExpMatch(toString(arg(args, 0)), toString(arg(args, 1))), nil
+	},
+	"isResolvable": func(in *interp, args []value) (value, error) {
+		return len(in.host.resolve(in.ctx, toString(arg(args, 0)))) > 0, nil
+	},
+	"isResolvableEx": func(in *interp, args []value) (value, error) {
+		return len(in.host.resolve(in.ctx, toString(arg(args, 0)))) > 0, nil
+	},
+	"dnsResolve": func(in *interp, args []value) (value, error) {
+		for _, ip := range in.host.resolve(in.ctx, toString(arg(args, 0))) {
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+		}
+		return null, nil
+	},
+	"dnsResolveEx": func(in *interp, args []value) (value, error) {
+		return joinIPs(in.host.resolve(in.ctx, toString(arg(args, 0)))), nil
+	},
+	"myIpAddress": func(_ *interp, _ []value) (value, error) {
+		for _, ip := range localAddrs() {
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+		}
+		return "127.0.0.1", nil
+	},
+	"myIpAddressEx": func(_ *interp, _ []value) (value, error) {
+		return joinIPs(localAddrs()), nil
+	},
+	"isInNet": func(in *interp, args []value) (value, error) {
+		ip := in.host.firstIPv4(in.ctx, toString(arg(args, 0)))
+		pattern := net.ParseIP(toString(arg(args, 1))).To4()
+		mask := net.ParseIP(toString(arg(args, 2))).To4()
+		if ip == nil || pattern == nil || mask == nil {
+			return false, nil
+		}
+		m := net.IPMask(mask)
+		return ip.Mask(m).Equal(pattern.Mask(m)), nil
+	},
+	"isInNetEx": func(in *interp, args []value) (value, error) {
+		_, prefix, err := net.ParseCIDR(toString(arg(args, 1)))
+		if err != nil {
+			return false, nil
+		}
+		host := toString(arg(args, 0))
+		if ip := net.ParseIP(host); ip != nil {
+			return prefix.Contains(ip), nil
+		}
+		for _, ip := range in.host.resolve(in.ctx, host) {
+			if prefix.Contains(ip) {
+				return true, nil
+			}
+		}
+		return false, nil
+	},
+	"convert_addr": func(_ *interp, args []value) (value, error) {
+		ip := net.ParseIP(toString(arg(args, 0))).To4()
+		if ip == nil {
+			return float64(0), nil
+		}
+		return float64(uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])), nil
+	},
+	"weekdayRange": func(_ *interp, args []value) (value, error) { return weekdayRange(args), nil },
+	"dateRange":    func(_ *interp, args []value) (value, error) { return dateRange(args), nil },
+	"timeRange":    func(_ *interp, args []value) (value, error) { return timeRange(args), nil },
+	"alert":        func(_ *interp, _ []value) (value, error) { return undefined, nil },
+}
+
+func joinIPs(ips []net.IP) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return strings.Join(s, ";")
+}
+
+// resolve returns the addresses of host, an IP address itself, remembering
+// lookups for the life of the script.
+func (s *Script) resolve(ctx context.Context, host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	if ips, ok := s.dns[host]; ok {
+		return ips
+	}
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	ips, err := lookupIP(ctx, host)
+	if err != nil && ctx.Err() != nil {
+		return nil // interrupted, so not remembered
+	}
+	s.dns[host] = ips
+	return ips
+}
+
+func (s *Script) firstIPv4(ctx context.Context, host string) net.IP {
+	for _, ip := range s.resolve(ctx, host) {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return nil
+}
+
+// shExpMatch matches s against a shell expression, in which * matches any
+// run of characters, / included, and ? a single one.
+func shExpMatch(s, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()).MatchString(s)
+}
+
+var (
+	weekdays = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	months   = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+)
+
+// clock returns the current time and the arguments without a trailing
+// "GMT", which selects UTC over local time.
+func clock(args []value) (time.Time, []value) {
+	t := now()
+	if n := len(args); n > 0 && toString(args[n-1]) == "GMT" {
+		return t.UTC(), args[:n-1]
+	}
+	return t, args
+}
+
+// inRange reports whether v lies in [lo, hi], wrapping around when lo is
+// after hi (e.g. FRI to MON).
+func inRange(v, lo, hi int) bool {
+	if lo <= hi {
+		return lo <= v && v <= hi
+	}
+	return v >= lo || v <= hi
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == strings.ToUpper(s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// weekdayRange implements weekdayRange(wd1[, wd2][, "GMT"]).
+func weekdayRange(args []value) bool {
+	t, args := clock(args)
+	if len(args) == 0 {
+		return false
+	}
+	lo := indexOf(weekdays, toString(args[0]))
+	hi := lo
+	if len(args) > 1 {
+		hi = indexOf(weekdays, toString(args[1]))
+	}
+	if lo < 0 || hi < 0 {
+		return false
+	}
+	return inRange(int(t.Weekday()), lo, hi)
+}
+
+// timeRange implements timeRange(hour1[, hour2]), with minutes and seconds
+// in the four and six argument forms, and an optional "GMT".
+func timeRange(args []value) bool {
+	t, args := clock(args)
+	n := make([]int, len(args))
+	for i := range args {
+		n[i] = toInt(args, i, -1)
+	}
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	switch len(n) {
+	case 1:
+		return t.Hour() == n[0]
+	case 2:
+		return inRange(t.Hour(), n[0], n[1])
+	case 4:
+		return inRange(t.Hour()*60+t.Minute(), n[0]*60+n[1], n[2]*60+n[3])
+	case 6:
+		return inRange(secs, n[0]*3600+n[1]*60+n[2], n[3]*3600+n[4]*60+n[5])
+	}
+	return false
+}
+
+// dateRange implements dateRange with days (1-31), months ("JAN"), and
+// years (four digits): one value matches that field, two a range of it,
+// and four or six a range of day-month, month-year, or full dates.
+func dateRange(args []value) bool {
+	t, args := clock(args)
+	type field struct{ kind, v int } // kind 0 day, 1 month, 2 year
+	fields := make([]field, len(args))
+	for i, a := range args {
+		if m := indexOf(months, toString(a)); m >= 0 {
+			fields[i] = field{1, m}
+			continue
+		}
+		n := toInt(args, i, -1)
+		switch {
+		case n >= 1 && n <= 31:
+			fields[i] = field{0, n}
+		case n > 31:
+			fields[i] = field{2, n}
+		default:
+			return false
+		}
+	}
+	current := [3]int{t.Day(), int(t.Month()) - 1, t.Year()}
+
+	switch len(fields) {
+	case 1:
+		return current[fields[0].kind] == fields[0].v
+	case 2, 4, 6:
+		half := len(fields) / 2
+		// Compare from the most significant field given
+		var lo, hi, cur int
+		for i := half - 1; i >= 0; i-- {
+			a, b := fields[i], fields[half+i]
+			if a.kind != b.kind {
+				return false
+			}
+			lo = lo*100 + a.v
+			hi = hi*100 + b.v
+			cur = cur*100 + current[a.kind]
+		}
+		// Ranges with years never wrap, but others may span a year end
+		if fields[half-1].kind == 2 {
+			return lo <= cur && cur <= hi
+		}
+		return inRange(cur, lo, hi)
+	}
+	return false
+}
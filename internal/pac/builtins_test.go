@@ -0,0 +1,118 @@
+package pac
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// stubNetwork replaces DNS and the local addresses for a test.
+func stubNetwork(t *testing.T, hosts map[string]string, local string) {
+	t.Helper()
+	origLookup, origLocal := lookupIP, localAddrs
+	t.Cleanup(func() { lookupIP, localAddrs = origLookup, origLocal })
+	lookupIP = func(_ context.Context, host string) ([]net.IP, error) {
+		if ip, ok := hosts[host]; ok {
+			return []net.IP{net.ParseIP(ip)}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	localAddrs = func() []net.IP { return []net.IP{net.ParseIP(local)} }
+}
+
+func TestBuiltins(t *testing.T) {
+	stubNetwork(t, map[string]string{"intranet.corp.example": "10.1.2.3", "example.com": "93.184.216.34"}, "10.20.30.40")
+
+	tests := []struct {
+		call string
+		want bool
+	}{
+		{`isPlainHostName("intranet")`, true},
+		{`isPlainHostName("intranet.corp.example")`, false},
+		{`dnsDomainIs("www.Corp.example", ".corp.example")`, true},
+		{`dnsDomainIs("www.example.com", ".corp.example")`, false},
+		{`localHostOrDomainIs("www", "www.corp.example")`, true},
+		{`localHostOrDomainIs("www.corp.example", "www.corp.example")`, true},
+		{`localHostOrDomainIs("www.other.example", "www.corp.example")`, false},
+		{`dnsDomainLevels("www.corp.example") == 2`, true},
+		{`shExpMatch("https://raw.github.com/a/b.json", "*.github.com/*")`, true},
+		{`shExpMatch("a.b", "a?b")`, true},
+		{`shExpMatch("axxb", "a?b")`, false},
+		{`shExpMatch("a+b(1)", "a+b(*)")`, true},
+		{`isResolvable("intranet.corp.example")`, true},
+		{`isResolvable("unknown.example")`, false},
+		{`dnsResolve("intranet.corp.example") == "10.1.2.3"`, true},
+		{`dnsResolve("unknown.example") === null`, true},
+		{`dnsResolveEx("example.com") == "93.184.216.34"`, true},
+		{`isInNet("intranet.corp.example", "10.0.0.0", "255.0.0.0")`, true},
+		{`isInNet("example.com", "10.0.0.0", "255.0.0.0")`, false},
+		{`isInNet("172.16.5.4", "172.16.0.0", "255.240.0.0")`, true},
+		{`isInNet("unknown.example", "0.0.0.0", "0.0.0.0")`, false},
+		{`isInNetEx("intranet.corp.example", "10.0.0.0/8")`, true},
+		{`isInNetEx("2001:db8::1", "2001:db8::/32")`, true},
+		{`isInNet(myIpAddress(), "10.20.0.0", "255.255.0.0")`, true},
+		{`myIpAddressEx() == "10.20.30.40"`, true},
+		{`convert_addr("10.0.0.1") == 167772161`, true},
+	}
+	for _, tt := range tests {
+		s, err := Parse("function FindProxyForURL(url, host) { return (" + tt.call + ") + \"\"; }")
+		if err != nil {
+			t.Errorf("Parse(%s) failed: %v", tt.call, err)
+			continue
+		}
+		got, err := s.FindProxyForURL(context.Background(), "", "")
+		if err != nil {
+			t.Errorf("%s failed: %v", tt.call, err)
+			continue
+		}
+		if want := map[bool]string{true: "true", false: "false"}[tt.want]; got != want {
+			t.Errorf("%s = %s, expected %s", tt.call, got, want)
+		}
+	}
+}
+
+func TestDateAndTimeRanges(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+	// Wednesday 15 March 2023, 14:30:15 UTC
+	now = func() time.Time { return time.Date(2023, 3, 15, 14, 30, 15, 0, time.FixedZone("X", 3600)) }
+
+	tests := []struct {
+		call string
+		want bool
+	}{
+		{`weekdayRange("WED")`, true},
+		{`weekdayRange("MON", "FRI")`, true},
+		{`weekdayRange("SAT", "SUN")`, false},
+		{`weekdayRange("FRI", "WED")`, true},
+		{`weekdayRange("WED", "GMT")`, true},
+		{`timeRange(14)`, true},
+		{`timeRange(13, "GMT")`, true},
+		{`timeRange(9, 17)`, true},
+		{`timeRange(22, 6)`, false},
+		{`timeRange(14, 0, 14, 29)`, false},
+		{`timeRange(14, 30, 0, 14, 30, 20)`, true},
+		{`dateRange(15)`, true},
+		{`dateRange("MAR")`, true},
+		{`dateRange(2023)`, true},
+		{`dateRange(1, 14)`, false},
+		{`dateRange("NOV", "MAR")`, true},
+		{`dateRange(2020, 2022)`, false},
+		{`dateRange(1, "MAR", 31, "MAR")`, true},
+		{`dateRange(20, "DEC", 10, "JAN")`, false},
+		{`dateRange("FEB", 2023, "APR", 2023)`, true},
+		{`dateRange(1, "JAN", 2023, 15, "MAR", 2023)`, true},
+		{`dateRange(16, "MAR", 2023, 1, "JAN", 2024)`, false},
+	}
+	for _, tt := range tests {
+		s, err := Parse("function FindProxyForURL(url, host) { return (" + tt.call + ") + \"\"; }")
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", tt.call, err)
+		}
+		got, _ := s.FindProxyForURL(context.Background(), "", "")
+		if want := map[bool]string{true: "true", false: "false"}[tt.want]; got != want {
+			t.Errorf("%s = %s, expected %s", tt.call, got, want)
+		}
+	}
+}
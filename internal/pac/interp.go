@@ -0,0 +1,676 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// value is a JavaScript value: undefined, null, a string, a float64, a
+// bool, an *array, a *regexpValue, or a function (*closure or builtin).
+type value any
+
+type undefinedType struct{}
+type nullType struct{}
+
+var (
+	undefined value = undefinedType{}
+	null      value = nullType{}
+)
+
+type array struct{ elems []value }
+
+type regexpValue struct{ re *regexp.Regexp }
+
+// closure is a function defined by the script.
+type closure struct {
+	fn  *funcDecl
+	env *scope
+}
+
+// builtin is a function provided by the interpreter.
+type builtin func(in *interp, args []value) (value, error)
+
+// maxSteps bounds the statements and calls one evaluation runs, so a
+// runaway script cannot hang an update.
+const maxSteps = 1_000_000
+
+// maxDepth bounds nested calls.
+const maxDepth = 500
+
+// errTooLong reports a script exceeding maxSteps.
+var errTooLong = errors.New("script ran too long")
+
+// errTooDeep reports a script exceeding maxDepth.
+var errTooDeep = errors.New("too much recursion")
+
+type scope struct {
+	vars   map[string]value
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{vars: make(map[string]value), parent: parent}
+}
+
+func (s *scope) lookup(name string) (value, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// set assigns to the innermost variable name, or declares it globally as
+// sloppy-mode JavaScript does.
+func (s *scope) set(name string, v value) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if _, ok := sc.vars[name]; ok {
+			sc.vars[name] = v
+			return
+		}
+		if sc.parent == nil {
+			sc.vars[name] = v
+		}
+	}
+}
+
+// interp evaluates one run of a script.
+type interp struct {
+	ctx   context.Context
+	steps int
+	depth int
+	// host holds the PAC functions' environment, such as DNS lookups.
+	host *Script
+}
+
+func (in *interp) step() error {
+	in.steps++
+	if in.steps > maxSteps {
+		return errTooLong
+	}
+	if in.steps%1024 == 0 {
+		return in.ctx.Err()
+	}
+	return nil
+}
+
+type control int
+
+const (
+	ctrlNone control = iota
+	ctrlReturn
+	ctrlBreak
+	ctrlContinue
+)
+
+// hoist declares the functions of body in env, so they can be called
+// before their declaration.
+func hoist(body []stmt, env *scope) {
+	for _, s := range body {
+		if fn, ok := s.(*funcDecl); ok {
+			env.vars[fn.name] = &closure{fn, env}
+		}
+	}
+}
+
+func (in *interp) execBody(body []stmt, env *scope) (control, value, error) {
+	for _, s := range body {
+		ctrl, v, err := in.exec(s, env)
+		if err != nil || ctrl != ctrlNone {
+			return ctrl, v, err
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+func (in *interp) exec(s stmt, env *scope) (control, value, error) {
+	if err := in.step(); err != nil {
+		return ctrlNone, nil, err
+	}
+	switch s := s.(type) {
+	case *funcDecl:
+		return ctrlNone, nil, nil // hoisted
+	case *varDecl:
+		for i, name := range s.names {
+			v := undefined
+			if s.inits[i] != nil {
+				var err error
+				if v, err = in.eval(s.inits[i], env); err != nil {
+					return ctrlNone, nil, err
+				}
+			} else if old, ok := env.vars[name]; ok {
+				v = old // var x; keeps an earlier value
+			}
+			env.vars[name] = v
+		}
+	case *exprStmt:
+		_, err := in.eval(s.x, env)
+		return ctrlNone, nil, err
+	case *blockStmt:
+		return in.execBody(s.body, env)
+	case *ifStmt:
+		cond, err := in.eval(s.cond, env)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		if truthy(cond) {
+			return in.exec(s.then, env)
+		} else if s.otherwise != nil {
+			return in.exec(s.otherwise, env)
+		}
+	case *forStmt:
+		return in.execLoop(s, env)
+	case *switchStmt:
+		return in.execSwitch(s, env)
+	case *returnStmt:
+		if s.value == nil {
+			return ctrlReturn, undefined, nil
+		}
+		v, err := in.eval(s.value, env)
+		return ctrlReturn, v, err
+	case *breakStmt:
+		return ctrlBreak, nil, nil
+	case *contStmt:
+		return ctrlContinue, nil, nil
+	default:
+		return ctrlNone, nil, fmt.Errorf("unsupported statement %T", s)
+	}
+	return ctrlNone, nil, nil
+}
+
+func (in *interp) execLoop(s *forStmt, env *scope) (control, value, error) {
+	if s.init != nil {
+		if _, _, err := in.exec(s.init, env); err != nil {
+			return ctrlNone, nil, err
+		}
+	}
+	for first := true; ; first = false {
+		if s.cond != nil && !(s.isDoWhile && first) {
+			cond, err := in.eval(s.cond, env)
+			if err != nil {
+				return ctrlNone, nil, err
+			}
+			if !truthy(cond) {
+				return ctrlNone, nil, nil
+			}
+		}
+		ctrl, v, err := in.exec(s.body, env)
+		if err != nil || ctrl == ctrlReturn {
+			return ctrl, v, err
+		}
+		if ctrl == ctrlBreak {
+			return ctrlNone, nil, nil
+		}
+		if s.post != nil {
+			if _, err := in.eval(s.post, env); err != nil {
+				return ctrlNone, nil, err
+			}
+		}
+		if err := in.step(); err != nil {
+			return ctrlNone, nil, err
+		}
+	}
+}
+
+func (in *interp) execSwitch(s *switchStmt, env *scope) (control, value, error) {
+	tag, err := in.eval(s.tag, env)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+	start := -1
+	for i, c := range s.cases {
+		if c.test == nil {
+			continue
+		}
+		v, err := in.eval(c.test, env)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		if strictEquals(tag, v) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		for i, c := range s.cases {
+			if c.test == nil {
+				start = i
+			}
+		}
+	}
+	if start < 0 {
+		return ctrlNone, nil, nil
+	}
+	// Fall through from the matching case until a break
+	for _, c := range s.cases[start:] {
+		ctrl, v, err := in.execBody(c.body, env)
+		if err != nil || ctrl == ctrlReturn || ctrl == ctrlContinue {
+			return ctrl, v, err
+		}
+		if ctrl == ctrlBreak {
+			return ctrlNone, nil, nil
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+func (in *interp) eval(x expr, env *scope) (value, error) {
+	switch x := x.(type) {
+	case *literal:
+		return x.value, nil
+	case *ident:
+		v, ok := env.lookup(x.name)
+		if !ok {
+			return nil, fmt.Errorf("%s is not defined", x.name)
+		}
+		return v, nil
+	case *arrayLit:
+		a := &array{elems: make([]value, len(x.elems))}
+		for i, e := range x.elems {
+			v, err := in.eval(e, env)
+			if err != nil {
+				return nil, err
+			}
+			a.elems[i] = v
+		}
+		return a, nil
+	case *regexpLit:
+		return &regexpValue{x.re}, nil
+	case *funcLit:
+		return &closure{x.fn, env}, nil
+	case *unary:
+		return in.evalUnary(x, env)
+	case *binary:
+		a, err := in.eval(x.x, env)
+		if err != nil {
+			return nil, err
+		}
+		b, err := in.eval(x.y, env)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(x.op, a, b), nil
+	case *logical:
+		a, err := in.eval(x.x, env)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(a) == (x.op == "||") {
+			return a, nil
+		}
+		return in.eval(x.y, env)
+	case *conditional:
+		cond, err := in.eval(x.cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return in.eval(x.then, env)
+		}
+		return in.eval(x.otherwise, env)
+	case *assign:
+		v, err := in.eval(x.value, env)
+		if err != nil {
+			return nil, err
+		}
+		if x.op != "=" {
+			old, err := in.eval(x.target, env)
+			if err != nil {
+				return nil, err
+			}
+			v = binaryOp(x.op[:1], old, v)
+		}
+		return v, in.store(x.target, v, env)
+	case *update:
+		old, err := in.eval(x.target, env)
+		if err != nil {
+			return nil, err
+		}
+		n := toNumber(old)
+		v := n + 1
+		if x.op == "--" {
+			v = n - 1
+		}
+		if err := in.store(x.target, v, env); err != nil {
+			return nil, err
+		}
+		if x.prefix {
+			return v, nil
+		}
+		return n, nil
+	case *member:
+		obj, err := in.eval(x.x, env)
+		if err != nil {
+			return nil, err
+		}
+		key := x.name
+		if x.computed {
+			k, err := in.eval(x.index, env)
+			if err != nil {
+				return nil, err
+			}
+			key = toString(k)
+		}
+		return getMember(obj, key)
+	case *call:
+		fn, err := in.eval(x.fn, env)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]value, len(x.args))
+		for i, a := range x.args {
+			if args[i], err = in.eval(a, env); err != nil {
+				return nil, err
+			}
+		}
+		return in.call(fn, args, x.fn)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+func (in *interp) evalUnary(x *unary, env *scope) (value, error) {
+	if x.op == "typeof" {
+		if id, ok := x.x.(*ident); ok {
+			if _, defined := env.lookup(id.name); !defined {
+				return "undefined", nil
+			}
+		}
+	}
+	v, err := in.eval(x.x, env)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "!":
+		return !truthy(v), nil
+	case "-":
+		return -toNumber(v), nil
+	case "+":
+		return toNumber(v), nil
+	}
+	return typeOf(v), nil
+}
+
+// store assigns v to a variable or an array element.
+func (in *interp) store(target expr, v value, env *scope) error {
+	switch t := target.(type) {
+	case *ident:
+		env.set(t.name, v)
+		return nil
+	case *member:
+		obj, err := in.eval(t.x, env)
+		if err != nil {
+			return err
+		}
+		a, ok := obj.(*array)
+		if !ok || !t.computed {
+			return fmt.Errorf("cannot assign to a property of %s", typeOf(obj))
+		}
+		k, err := in.eval(t.index, env)
+		if err != nil {
+			return err
+		}
+		i := toNumber(k)
+		if i < 0 || !isInteger(i) || i > float64(len(a.elems)+maxSteps) {
+			return fmt.Errorf("invalid array index %v", toString(k))
+		}
+		for int(i) >= len(a.elems) {
+			a.elems = append(a.elems, undefined)
+		}
+		a.elems[int(i)] = v
+		return nil
+	}
+	return fmt.Errorf("invalid assignment target")
+}
+
+func (in *interp) call(fn value, args []value, callee expr) (value, error) {
+	if err := in.step(); err != nil {
+		return nil, err
+	}
+	switch f := fn.(type) {
+	case builtin:
+		return f(in, args)
+	case *closure:
+		if in.depth >= maxDepth {
+			return nil, errTooDeep
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+		env := newScope(f.env)
+		for i, name := range f.fn.params {
+			if i < len(args) {
+				env.vars[name] = args[i]
+			} else {
+				env.vars[name] = undefined
+			}
+		}
+		hoist(f.fn.body, env)
+		ctrl, v, err := in.execBody(f.fn.body, env)
+		if err != nil || ctrl != ctrlReturn {
+			return undefined, err
+		}
+		return v, nil
+	}
+	name := "value"
+	if id, ok := callee.(*ident); ok {
+		name = id.name
+	}
+	return nil, fmt.Errorf("%s is not a function", name)
+}
+
+// binaryOp applies an arithmetic, comparison, or equality operator.
+func binaryOp(op string, a, b value) value {
+	switch op {
+	case "+":
+		_, as := a.(string)
+		_, bs := b.(string)
+		if as || bs || isObject(a) || isObject(b) {
+			return toString(a) + toString(b)
+		}
+		return toNumber(a) + toNumber(b)
+	case "-":
+		return toNumber(a) - toNumber(b)
+	case "*":
+		return toNumber(a) * toNumber(b)
+	case "/":
+		return toNumber(a) / toNumber(b)
+	case "%":
+		return math.Mod(toNumber(a), toNumber(b))
+	case "===":
+		return strictEquals(a, b)
+	case "!==":
+		return !strictEquals(a, b)
+	case "==":
+		return looseEquals(a, b)
+	case "!=":
+		return !looseEquals(a, b)
+	}
+
+	// Relational operators compare strings as strings, anything else as
+	// numbers
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		switch op {
+		case "<":
+			return as < bs
+		case ">":
+			return as > bs
+		case "<=":
+			return as <= bs
+		default:
+			return as >= bs
+		}
+	}
+	x, y := toNumber(a), toNumber(b)
+	switch op {
+	case "<":
+		return x < y
+	case ">":
+		return x > y
+	case "<=":
+		return x <= y
+	default:
+		return x >= y
+	}
+}
+
+func isObject(v value) bool {
+	switch v.(type) {
+	case *array, *regexpValue:
+		return true
+	}
+	return false
+}
+
+func strictEquals(a, b value) bool {
+	switch a := a.(type) {
+	case string, float64, bool, undefinedType, nullType, *array, *regexpValue, *closure:
+		return a == b
+	}
+	return false // builtins are not comparable
+}
+
+func looseEquals(a, b value) bool {
+	if isNullish(a) || isNullish(b) {
+		return isNullish(a) && isNullish(b)
+	}
+	switch a.(type) {
+	case string, float64, bool:
+		switch b.(type) {
+		case string, float64, bool:
+			_, as := a.(string)
+			_, bs := b.(string)
+			if as && bs {
+				return a == b
+			}
+			return toNumber(a) == toNumber(b)
+		}
+	}
+	if isObject(a) != isObject(b) {
+		return toString(a) == toString(b)
+	}
+	return strictEquals(a, b)
+}
+
+func isNullish(v value) bool { return v == undefined || v == null }
+
+func truthy(v value) bool {
+	switch v := v.(type) {
+	case undefinedType, nullType:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func typeOf(v value) string {
+	switch v.(type) {
+	case undefinedType:
+		return "undefined"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case builtin, *closure:
+		return "function"
+	}
+	return "object"
+}
+
+func toNumber(v value) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case nullType:
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		if n, err := parseNumber(s); err == nil {
+			return n
+		}
+		return math.NaN()
+	case *array:
+		if len(v.elems) == 0 {
+			return 0
+		}
+		if len(v.elems) == 1 {
+			return toNumber(v.elems[0])
+		}
+	}
+	return math.NaN()
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case undefinedType:
+		return "undefined"
+	case nullType:
+		return "null"
+	case *array:
+		parts := make([]string, len(v.elems))
+		for i, e := range v.elems {
+			if !isNullish(e) {
+				parts[i] = toString(e)
+			}
+		}
+		return strings.Join(parts, ",")
+	case *regexpValue:
+		return "/" + v.re.String() + "/"
+	}
+	return "function"
+}
+
+// toInt converts an argument to an integer, def if it is missing or NaN.
+func toInt(args []value, i, def int) int {
+	if i >= len(args) || args[i] == undefined {
+		return def
+	}
+	n := toNumber(args[i])
+	if math.IsNaN(n) {
+		return def
+	}
+	return int(n)
+}
+
+func arg(args []value, i int) value {
+	if i < len(args) {
+		return args[i]
+	}
+	return undefined
+}
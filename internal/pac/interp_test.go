@@ -0,0 +1,113 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInterpreter(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`return "DIRECT";`, "DIRECT"},
+		{`var p = "PROXY " + "a:" + 80; return p`, "PROXY a:80"},
+		{`return 1 + 2 * 3 + "";`, "7"},
+		{`return (7 % 4) / 2 + "";`, "1.5"},
+		{`if (host == "example.com") return "yes"; else return "no";`, "yes"},
+		{`return host === "EXAMPLE.COM" ? "yes" : "no";`, "no"},
+		{`return "1" == 1 && null == undefined && "1" !== 1 ? "loose" : "strict";`, "loose"},
+		{`return ("" || 0 || "fallback") + (1 && "and");`, "fallbackand"},
+		{`var n = 0; for (var i = 0; i < 10; i++) { if (i % 2) continue; n += i; } return n + "";`, "20"},
+		{`var i = 0; while (true) { if (++i >= 5) break; } return i + "";`, "5"},
+		{`var i = 10; do { i--; } while (false); return i + "";`, "9"},
+		{`var hosts = ["a.com", "example.com"]; for (var i = 0; i < hosts.length; i++) if (host == hosts[i]) return "found " + i; return "none";`, "found 1"},
+		{`var list = []; list.push("x", "y"); list[3] = "z"; return list.join("-") + " " + list.length + " " + list.indexOf("y");`, "x-y--z 4 1"},
+		{`switch (host.split(".")[1]) { case "org": return "org"; case "com": case "net": return "com or net"; default: return "other"; }`, "com or net"},
+		{`switch (1) { case 2: return "two"; default: var d = "default"; break; } return d;`, "default"},
+		{`return host.toUpperCase().substring(0, 3) + host.indexOf(".") + host.slice(-3) + host.substr(1, 2) + host.charAt(0);`, "EXA7comxae"},
+		{`return [host.startsWith("ex"), host.endsWith(".com"), host.includes("amp"), host.lastIndexOf("m")].join();`, "true,true,true,10"},
+		{`return host.replace("example", "test") + " " + url.replace(/\/a\//, "/") ;`, "test.com https://example.com/b.json"},
+		{`return /^EXAMPLE\./i.test(host) ? url.match(/\/(\w+)\.json$/)[1] : "no";`, "b"},
+		{`var x = 6 / 2; return x > 2 && "b" > "a" && !(x <= 2) ? typeof x + typeof host + typeof nope + typeof null : "no";`, "numberstringundefinedobject"},
+		{`function twice(s) { return s + s; } return twice(host.split(".")[1]);`, "comcom"},
+		{`var f = function (a, b) { return typeof b; }; return f(1);`, "undefined"},
+		{`return helper();`, "hoisted"},
+		{`counter = 1; counter += 2; return counter - 1 + "";`, "2"},
+		{`/* block */ var a = 'single' /* quotes */, b = "\x41B\n".trim(); return a + b; // comment`, "singleAB"},
+		{`return 0x10 + 1e1 + .5 + "";`, "26.5"},
+	}
+	helper := "\nfunction helper() { return \"hoisted\"; }"
+	for _, tt := range tests {
+		s, err := Parse("function FindProxyForURL(url, host) {\n" + tt.body + "\n}" + helper)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.body, err)
+			continue
+		}
+		got, err := s.FindProxyForURL(context.Background(), "https://example.com/a/b.json", "example.com")
+		if err != nil {
+			t.Errorf("%q failed: %v", tt.body, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %q, expected %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestInterpreterGlobalState(t *testing.T) {
+	s, err := Parse(`var calls = 0;
+var proxy = "PROXY " + ["p1", "example", "com"].join(".") + ":3128";
+function FindProxyForURL(url, host) { calls++; return proxy + "; " + calls; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"PROXY p1.example.com:3128; 1", "PROXY p1.example.com:3128; 2"} {
+		if got, _ := s.FindProxyForURL(context.Background(), "http://a/", "a"); got != want {
+			t.Errorf("FindProxyForURL() = %q, expected %q", got, want)
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	parseErrors := []string{
+		`function FindProxyForURL(url, host) { return "DIRECT"`,
+		`function FindProxyForURL(url, host) { return new Date(); }`,
+		`function FindProxyForURL(url, host) { for (var k in host) {} }`,
+		`function FindProxyForURL(url, host) { return "unterminated; }`,
+		`function FindProxyForURL(url, host) { 1 = 2; }`,
+		`function FindProxyForURL(url, host) { return /(a)\1/.test(host); }`,
+		`function FindProxyForURL(url, host) { return @; }`,
+		`function findProxyForURL(url, host) { return "DIRECT"; }`,
+		`var x = undefinedVariable;`,
+	}
+	for _, src := range parseErrors {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+	if _, err := Parse("function f() {}"); !errors.Is(err, ErrNoFindProxy) {
+		t.Errorf("Parse() without FindProxyForURL = %v, expected ErrNoFindProxy", err)
+	}
+
+	runErrors := map[string]string{
+		`return nope;`:                     "nope is not defined",
+		`return host();`:                   "host is not a function",
+		`var u; return u.length;`:          "cannot read property",
+		`while (true) {}`:                  errTooLong.Error(),
+		`function r() { return r(); } r()`: errTooDeep.Error(),
+		`return 42;`:                       "not a string",
+	}
+	for body, want := range runErrors {
+		s, err := Parse("function FindProxyForURL(url, host) {\n" + body + "\n}")
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", body, err)
+			continue
+		}
+		if _, err := s.FindProxyForURL(context.Background(), "http://a/", "a"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q = %v, expected %q", body, err, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package pac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokRegexp
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier, punctuator, string value, or regexp pattern
+	num  float64
+	// flags holds a regexp literal's flags.
+	flags string
+	line  int
+}
+
+// punctuators lists the operators the interpreter knows, longest first so
+// the lexer matches greedily.
+var punctuators = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=",
+	"{", "}", "(", ")", "[", "]", ";", ",", ".", "+", "-", "*", "/", "%",
+	"!", "=", "<", ">", "?", ":",
+}
+
+// lex splits a PAC script into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], line: line})
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			start := i
+			if c == '0' && i+1 < len(src) && (src[i+1] == 'x' || src[i+1] == 'X') {
+				i += 2
+				for i < len(src) && isHexDigit(src[i]) {
+					i++
+				}
+			} else {
+				for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+					i++
+				}
+				if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+					i++
+					if i < len(src) && (src[i] == '+' || src[i] == '-') {
+						i++
+					}
+					for i < len(src) && isDigit(src[i]) {
+						i++
+					}
+				}
+			}
+			n, err := parseNumber(src[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid number %q", line, src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokNumber, num: n, text: src[start:i], line: line})
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, line: line})
+			i += n
+		case c == '/' && regexpAllowed(tokens):
+			pattern, flags, n, err := lexRegexp(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			tokens = append(tokens, token{kind: tokRegexp, text: pattern, flags: flags, line: line})
+			i += n
+		default:
+			p := matchPunctuator(src[i:])
+			if p == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+			}
+			tokens = append(tokens, token{kind: tokPunct, text: p, line: line})
+			i += len(p)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, line: line}), nil
+}
+
+func matchPunctuator(s string) string {
+	for _, p := range punctuators {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// regexpAllowed reports whether a slash after tokens starts a regexp
+// literal rather than a division: it does where an operand is expected.
+func regexpAllowed(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	prev := tokens[len(tokens)-1]
+	switch prev.kind {
+	case tokNumber, tokString, tokRegexp:
+		return false
+	case tokIdent:
+		return prev.text == "return" || prev.text == "typeof"
+	case tokPunct:
+		return prev.text != ")" && prev.text != "]" && prev.text != "}"
+	}
+	return true
+}
+
+// lexString reads the quoted string at the start of s, returning its value
+// and length in s.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case '0':
+				b.WriteByte(0)
+			case 'x', 'u':
+				n := 2
+				if e == 'u' {
+					n = 4
+				}
+				if i+n >= len(s) {
+					return "", 0, fmt.Errorf("invalid escape in string")
+				}
+				code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape in string")
+				}
+				b.WriteRune(rune(code))
+				i += n
+			case '\n':
+				// Line continuation
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// lexRegexp reads the regexp literal at the start of s, returning its
+// pattern, flags, and length in s.
+func lexRegexp(s string) (string, string, int, error) {
+	inClass := false
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return "", "", 0, fmt.Errorf("unterminated regular expression")
+		case '/':
+			if inClass {
+				continue
+			}
+			end := i + 1
+			for end < len(s) && isIdentPart(s[end]) {
+				end++
+			}
+			return s[1:i], s[i+1 : end], end, nil
+		}
+	}
+	return "", "", 0, fmt.Errorf("unterminated regular expression")
+}
+
+func parseNumber(s string) (float64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, err := strconv.ParseUint(s[2:], 16, 64)
+		return float64(n), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool { return isIdentStart(c) || isDigit(c) }
@@ -0,0 +1,206 @@
+package pac
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// getMember returns the property key of obj: the length and methods of
+// strings and arrays, array elements, and the test method of regexps.
+func getMember(obj value, key string) (value, error) {
+	switch o := obj.(type) {
+	case string:
+		if key == "length" {
+			return float64(len(o)), nil
+		}
+		if i, err := strconv.Atoi(key); err == nil {
+			if i >= 0 && i < len(o) {
+				return o[i : i+1], nil
+			}
+			return undefined, nil
+		}
+		if m, ok := stringMethods[key]; ok {
+			return builtin(func(in *interp, args []value) (value, error) { return m(o, args) }), nil
+		}
+		return undefined, nil
+	case *array:
+		if key == "length" {
+			return float64(len(o.elems)), nil
+		}
+		if i, err := strconv.Atoi(key); err == nil {
+			if i >= 0 && i < len(o.elems) {
+				return o.elems[i], nil
+			}
+			return undefined, nil
+		}
+		if m, ok := arrayMethods[key]; ok {
+			return builtin(func(in *interp, args []value) (value, error) { return m(o, args) }), nil
+		}
+		return undefined, nil
+	case *regexpValue:
+		if key == "test" {
+			return builtin(func(in *interp, args []value) (value, error) {
+				return o.re.MatchString(toString(arg(args, 0))), nil
+			}), nil
+		}
+		return undefined, nil
+	case undefinedType, nullType:
+		return nil, fmt.Errorf("cannot read property %q of %s", key, toString(o))
+	}
+	return undefined, nil
+}
+
+// clampIndex resolves a string or array index argument against length n:
+// negative values count from the end when fromEnd is set, and are clamped
+// to zero otherwise, like substring.
+func clampIndex(args []value, i, def, n int, fromEnd bool) int {
+	v := toInt(args, i, def)
+	if v < 0 && fromEnd {
+		v += n
+	}
+	return max(0, min(v, n))
+}
+
+var stringMethods = map[string]func(s string, args []value) (value, error){
+	"toLowerCase": func(s string, _ []value) (value, error) { return strings.ToLower(s), nil },
+	"toUpperCase": func(s string, _ []value) (value, error) { return strings.ToUpper(s), nil },
+	"trim":        func(s string, _ []value) (value, error) { return strings.TrimSpace(s), nil },
+	"toString":    func(s string, _ []value) (value, error) { return s, nil },
+	"indexOf": func(s string, args []value) (value, error) {
+		from := clampIndex(args, 1, 0, len(s), false)
+		i := strings.Index(s[from:], toString(arg(args, 0)))
+		if i < 0 {
+			return float64(-1), nil
+		}
+		return float64(from + i), nil
+	},
+	"lastIndexOf": func(s string, args []value) (value, error) {
+		return float64(strings.LastIndex(s, toString(arg(args, 0)))), nil
+	},
+	"includes": func(s string, args []value) (value, error) {
+		return strings.Contains(s, toString(arg(args, 0))), nil
+	},
+	"startsWith": func(s string, args []value) (value, error) {
+		return strings.HasPrefix(s, toString(arg(args, 0))), nil
+	},
+	"endsWith": func(s string, args []value) (value, error) {
+		return strings.HasSuffix(s, toString(arg(args, 0))), nil
+	},
+	"charAt": func(s string, args []value) (value, error) {
+		i := toInt(args, 0, 0)
+		if i < 0 || i >= len(s) {
+			return "", nil
+		}
+		return s[i : i+1], nil
+	},
+	"substring": func(s string, args []value) (value, error) {
+		start := clampIndex(args, 0, 0, len(s), false)
+		end := clampIndex(args, 1, len(s), len(s), false)
+		if start > end {
+			start, end = end, start
+		}
+		return s[start:end], nil
+	},
+	"slice": func(s string, args []value) (value, error) {
+		start := clampIndex(args, 0, 0, len(s), true)
+		end := clampIndex(args, 1, len(s), len(s), true)
+		if start > end {
+			return "", nil
+		}
+		return s[start:end], nil
+	},
+	"substr": func(s string, args []value) (value, error) {
+		start := clampIndex(args, 0, 0, len(s), true)
+		n := toInt(args, 1, len(s)-start)
+		end := max(start, min(start+n, len(s)))
+		return s[start:end], nil
+	},
+	"split": func(s string, args []value) (value, error) {
+		if arg(args, 0) == undefined {
+			return &array{elems: []value{s}}, nil
+		}
+		var parts []string
+		if re, ok := arg(args, 0).(*regexpValue); ok {
+			parts = re.re.Split(s, -1)
+		} else {
+			parts = strings.Split(s, toString(args[0]))
+		}
+		a := &array{elems: make([]value, len(parts))}
+		for i, p := range parts {
+			a.elems[i] = p
+		}
+		return a, nil
+	},
+	"replace": func(s string, args []value) (value, error) {
+		repl := toString(arg(args, 1))
+		if re, ok := arg(args, 0).(*regexpValue); ok {
+			// Replace only the first match, like a regexp without the g flag
+			loc := re.re.FindStringIndex(s)
+			if loc == nil {
+				return s, nil
+			}
+			return s[:loc[0]] + repl + s[loc[1]:], nil
+		}
+		return strings.Replace(s, toString(arg(args, 0)), repl, 1), nil
+	},
+	"match": func(s string, args []value) (value, error) {
+		re, ok := arg(args, 0).(*regexpValue)
+		if !ok {
+			return nil, fmt.Errorf("match takes a regular expression")
+		}
+		m := re.re.FindStringSubmatch(s)
+		if m == nil {
+			return null, nil
+		}
+		a := &array{elems: make([]value, len(m))}
+		for i, g := range m {
+			a.elems[i] = g
+		}
+		return a, nil
+	},
+}
+
+var arrayMethods = map[string]func(a *array, args []value) (value, error){
+	"indexOf": func(a *array, args []value) (value, error) {
+		for i, e := range a.elems {
+			if strictEquals(e, arg(args, 0)) {
+				return float64(i), nil
+			}
+		}
+		return float64(-1), nil
+	},
+	"includes": func(a *array, args []value) (value, error) {
+		for _, e := range a.elems {
+			if strictEquals(e, arg(args, 0)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	},
+	"join": func(a *array, args []value) (value, error) {
+		sep := ","
+		if arg(args, 0) != undefined {
+			sep = toString(args[0])
+		}
+		parts := make([]string, len(a.elems))
+		for i, e := range a.elems {
+			if !isNullish(e) {
+				parts[i] = toString(e)
+			}
+		}
+		return strings.Join(parts, sep), nil
+	},
+	"push": func(a *array, args []value) (value, error) {
+		if len(a.elems)+len(args) > maxSteps {
+			return nil, errTooLong
+		}
+		a.elems = append(a.elems, args...)
+		return float64(len(a.elems)), nil
+	},
+	"toString": func(a *array, _ []value) (value, error) { return toString(a), nil },
+}
+
+// isInteger reports whether n is a whole number.
+func isInteger(n float64) bool { return n == math.Trunc(n) && !math.IsInf(n, 0) }
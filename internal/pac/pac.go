@@ -0,0 +1,174 @@
+// Package pac chooses proxies with proxy auto-config (PAC) files, for
+// networks where the proxy is only published that way. It evaluates the
+// subset of JavaScript PAC files are written in, with the standard PAC
+// functions (isInNet, shExpMatch, dnsResolve, ...), without a JavaScript
+// engine.
+package pac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxSize bounds the PAC files Load reads.
+const MaxSize = 1 << 20
+
+// FetchTimeout bounds downloading a PAC file.
+const FetchTimeout = 30 * time.Second
+
+// ErrNoFindProxy indicates a PAC file without a FindProxyForURL function.
+var ErrNoFindProxy = errors.New("no FindProxyForURL function")
+
+// Script is a parsed PAC file. It is safe for concurrent use.
+type Script struct {
+	mu     sync.Mutex
+	global *scope
+	// dns remembers the lookups made by the script.
+	dns map[string][]net.IP
+}
+
+// Parse parses a PAC file and runs its top-level statements.
+func Parse(src string) (*Script, error) {
+	body, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{global: newScope(nil), dns: make(map[string][]net.IP)}
+	for name, fn := range globals {
+		s.global.vars[name] = fn
+	}
+	hoist(body, s.global)
+	in := &interp{ctx: context.Background(), host: s}
+	if _, _, err := in.execBody(body, s.global); err != nil {
+		return nil, err
+	}
+	if _, ok := s.global.vars["FindProxyForURL"].(*closure); !ok {
+		return nil, ErrNoFindProxy
+	}
+	return s, nil
+}
+
+// FindProxyForURL calls the script's FindProxyForURL, returning its
+// result, e.g. "PROXY proxy.example.com:8080; DIRECT".
+func (s *Script) FindProxyForURL(ctx context.Context, rawURL, host string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in := &interp{ctx: ctx, host: s}
+	v, err := in.call(s.global.vars["FindProxyForURL"], []value{rawURL, host}, nil)
+	if err != nil {
+		return "", fmt.Errorf("FindProxyForURL: %w", err)
+	}
+	if _, ok := v.(string); !ok {
+		return "", fmt.Errorf("FindProxyForURL returned %s, not a string", typeOf(v))
+	}
+	return v.(string), nil
+}
+
+// Proxy is one entry of a FindProxyForURL result.
+type Proxy struct {
+	// Type is DIRECT, PROXY, HTTP, HTTPS, SOCKS, SOCKS4, or SOCKS5.
+	Type string
+	// Addr is the proxy's host:port, empty for DIRECT.
+	Addr string
+}
+
+// URL returns the proxy URL the standard library's HTTP transport accepts,
+// nil for DIRECT. It fails for SOCKS4 proxies, which Go cannot use.
+func (p Proxy) URL() (*url.URL, error) {
+	switch p.Type {
+	case "DIRECT":
+		return nil, nil
+	case "PROXY", "HTTP":
+		return &url.URL{Scheme: "http", Host: p.Addr}, nil
+	case "HTTPS":
+		return &url.URL{Scheme: "https", Host: p.Addr}, nil
+	case "SOCKS", "SOCKS5":
+		return &url.URL{Scheme: "socks5", Host: p.Addr}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy type %s", p.Type)
+}
+
+// ParseResult splits a FindProxyForURL result into its entries, skipping
+// malformed ones.
+func ParseResult(result string) []Proxy {
+	var proxies []Proxy
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		switch {
+		case len(fields) == 1 && strings.EqualFold(fields[0], "DIRECT"):
+			proxies = append(proxies, Proxy{Type: "DIRECT"})
+		case len(fields) == 2:
+			proxies = append(proxies, Proxy{Type: strings.ToUpper(fields[0]), Addr: fields[1]})
+		}
+	}
+	return proxies
+}
+
+// Load reads a PAC file from an http, https, or file URL, or a local path.
+// PAC files are downloaded directly, never through a proxy.
+func Load(ctx context.Context, location string) (*Script, error) {
+	src, err := read(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	return s, nil
+}
+
+func read(ctx context.Context, location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		path := location
+		if err == nil && u.Scheme == "file" {
+			path = u.Path
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return readLimited(f, location)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: unexpected status: %d", location, resp.StatusCode)
+	}
+	return readLimited(resp.Body, location)
+}
+
+func readLimited(r io.Reader, location string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxSize {
+		return "", fmt.Errorf("%s: PAC file larger than %d bytes", location, MaxSize)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,175 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// corporatePAC is shaped like the PAC files of managed networks.
+const corporatePAC = `// Managed by IT
+var proxies = "PROXY proxy1.corp.example:8080; PROXY proxy2.corp.example:8080; DIRECT";
+var bypass = [".corp.example", ".internal"];
+
+function FindProxyForURL(url, host) {
+    host = host.toLowerCase();
+    if (isPlainHostName(host) || shExpMatch(host, "127.*") || host == "localhost")
+        return "DIRECT";
+    for (var i = 0; i < bypass.length; i++) {
+        if (dnsDomainIs(host, bypass[i]))
+            return "DIRECT";
+    }
+    var ip = dnsResolve(host);
+    if (ip && (isInNet(ip, "10.0.0.0", "255.0.0.0") || isInNet(ip, "192.168.0.0", "255.255.0.0")))
+        return "DIRECT";
+    if (url.substring(0, 6) == "https:")
+        return "HTTPS secure.corp.example:443";
+    return proxies;
+}
+`
+
+func TestCorporatePAC(t *testing.T) {
+	stubNetwork(t, map[string]string{"raw.githubusercontent.com": "185.199.108.133", "nas.home": "192.168.1.10"}, "10.0.0.5")
+	s, err := Parse(corporatePAC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ url, want string }{
+		{"http://intranet/", "DIRECT"},
+		{"https://symbols.corp.example/banners.json", "DIRECT"},
+		{"http://nas.home/banners.json", "DIRECT"},
+		{"https://raw.githubusercontent.com/a/banners.json", "HTTPS secure.corp.example:443"},
+		{"http://raw.githubusercontent.com/a/banners.json", "PROXY proxy1.corp.example:8080; PROXY proxy2.corp.example:8080; DIRECT"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		got, err := s.FindProxyForURL(context.Background(), tt.url, u.Hostname())
+		if err != nil || got != tt.want {
+			t.Errorf("FindProxyForURL(%s) = %q, %v; expected %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	got := ParseResult(" PROXY a:8080;SOCKS5 b:1080 ;direct; junk; HTTPS c:443")
+	want := []Proxy{{"PROXY", "a:8080"}, {"SOCKS5", "b:1080"}, {"DIRECT", ""}, {"HTTPS", "c:443"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseResult() = %+v, expected %+v", got, want)
+	}
+
+	if u, err := (Proxy{"PROXY", "a:8080"}).URL(); err != nil || u.String() != "http://a:8080" {
+		t.Errorf("URL() = %v, %v", u, err)
+	}
+	if u, err := (Proxy{"SOCKS", "b:1080"}).URL(); err != nil || u.String() != "socks5://b:1080" {
+		t.Errorf("URL() = %v, %v", u, err)
+	}
+	if u, err := (Proxy{Type: "DIRECT"}).URL(); err != nil || u != nil {
+		t.Errorf("URL() of DIRECT = %v, %v", u, err)
+	}
+	if _, err := (Proxy{"SOCKS4", "b:1080"}).URL(); err == nil {
+		t.Error("URL() accepted SOCKS4")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy.pac" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		w.Write([]byte(`function FindProxyForURL(url, host) { return "PROXY from-http:3128"; }`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	if err := os.WriteFile(path, []byte(`function FindProxyForURL(url, host) { return "DIRECT"; }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for location, want := range map[string]string{
+		srv.URL + "/proxy.pac": "PROXY from-http:3128",
+		path:                   "DIRECT",
+		(&url.URL{Scheme: "file", Path: path}).String(): "DIRECT",
+	} {
+		s, err := Load(ctx, location)
+		if err != nil {
+			t.Errorf("Load(%s) failed: %v", location, err)
+			continue
+		}
+		if got, _ := s.FindProxyForURL(ctx, "http://a/", "a"); got != want {
+			t.Errorf("Load(%s) script returned %q, expected %q", location, got, want)
+		}
+	}
+
+	if _, err := Load(ctx, srv.URL+"/missing.pac"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Load() of a missing URL = %v", err)
+	}
+	if _, err := Load(ctx, filepath.Join(t.TempDir(), "missing.pac")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of a missing file = %v", err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	pac := `function FindProxyForURL(url, host) {
+    if (host == "direct.example") return "DIRECT";
+    if (host == "socks4.example") return "SOCKS4 old:1080; PROXY new:3128";
+    if (host == "broken.example") return broken();
+    return "PROXY proxy.example:3128; DIRECT";
+}`
+	if err := os.WriteFile(path, []byte(pac), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, _ := url.Parse("http://fallback:8080")
+	r := NewResolver(path)
+	r.Fallback = func(*http.Request) (*url.URL, error) { return fallback, nil }
+	var errs []error
+	r.OnError = func(err error) { errs = append(errs, err) }
+
+	tests := []struct{ url, want string }{
+		{"https://symbols.example/banners.json", "http://proxy.example:3128"},
+		{"https://direct.example/banners.json", ""},
+		{"https://socks4.example/banners.json", "http://new:3128"},
+		{"https://broken.example/banners.json", "http://fallback:8080"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		proxy, err := r.Proxy(req)
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Proxy(%s) = %q, %v; expected %q", tt.url, got, err, tt.want)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken is not defined") {
+		t.Errorf("OnError got %v, expected the broken evaluation", errs)
+	}
+
+	// An unreachable PAC file falls back, reporting the failure once
+	errs = nil
+	r = NewResolver(filepath.Join(t.TempDir(), "missing.pac"))
+	r.Fallback = func(*http.Request) (*url.URL, error) { return fallback, nil }
+	r.OnError = func(err error) { errs = append(errs, err) }
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://symbols.example/", nil)
+		if proxy, err := r.Proxy(req); err != nil || proxy != fallback {
+			t.Errorf("Proxy() = %v, %v; expected the fallback", proxy, err)
+		}
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError got %v, expected one load failure", errs)
+	}
+}
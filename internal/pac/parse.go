@@ -0,0 +1,656 @@
+package pac
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The syntax tree of the JavaScript subset PAC files are written in:
+// functions, var/let/const, if, for, while, switch, and the usual
+// expressions over strings, numbers, booleans, arrays, and regexps.
+
+type (
+	stmt any
+	expr any
+)
+
+type (
+	funcDecl struct {
+		name   string
+		params []string
+		body   []stmt
+	}
+	varDecl struct {
+		names []string
+		inits []expr // nil for a declaration without initializer
+	}
+	ifStmt struct {
+		cond            expr
+		then, otherwise stmt
+	}
+	forStmt struct {
+		init      stmt // nil, *varDecl, or *exprStmt
+		cond      expr
+		post      expr
+		body      stmt
+		isDoWhile bool
+	}
+	switchStmt struct {
+		tag   expr
+		cases []switchCase
+	}
+	switchCase struct {
+		test expr // nil for default
+		body []stmt
+	}
+	returnStmt struct{ value expr }
+	breakStmt  struct{}
+	contStmt   struct{}
+	blockStmt  struct{ body []stmt }
+	exprStmt   struct{ x expr }
+)
+
+type (
+	literal   struct{ value value }
+	ident     struct{ name string }
+	arrayLit  struct{ elems []expr }
+	regexpLit struct{ re *regexp.Regexp }
+	funcLit   struct{ fn *funcDecl }
+	unary     struct {
+		op string
+		x  expr
+	}
+	binary struct {
+		op   string
+		x, y expr
+	}
+	logical struct {
+		op   string
+		x, y expr
+	}
+	conditional struct{ cond, then, otherwise expr }
+	assign      struct {
+		op     string // "=", "+=", or "-="
+		target expr   // *ident or *member
+		value  expr
+	}
+	update struct {
+		op     string // "++" or "--"
+		prefix bool
+		target expr
+	}
+	member struct {
+		x        expr
+		name     string // for x.name
+		index    expr   // for x[index]
+		computed bool
+	}
+	call struct {
+		fn   expr
+		args []expr
+	}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses a PAC script into its statements.
+func parse(src string) ([]stmt, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var body []stmt
+	for p.peek().kind != tokEOF {
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+	}
+	return body, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the next token is the punctuator or keyword s.
+func (p *parser) is(s string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokIdent) && t.text == s
+}
+
+// accept consumes the next token if it is s.
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	found := t.text
+	if t.kind == tokEOF {
+		found = "end of script"
+	}
+	return fmt.Errorf("line %d: %s, found %q", t.line, fmt.Sprintf(format, args...), found)
+}
+
+func (p *parser) identifier() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent || keywords[t.text] {
+		return "", p.errorf("expected a name")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+var keywords = map[string]bool{
+	"function": true, "var": true, "let": true, "const": true, "if": true, "else": true,
+	"for": true, "while": true, "do": true, "switch": true, "case": true, "default": true,
+	"return": true, "break": true, "continue": true, "true": true, "false": true,
+	"null": true, "undefined": true, "typeof": true, "new": true, "in": true,
+}
+
+// endStatement consumes an optional semicolon.
+func (p *parser) endStatement() { p.accept(";") }
+
+func (p *parser) statement() (stmt, error) {
+	switch {
+	case p.is("function"):
+		p.next()
+		return p.function(true)
+	case p.is("var") || p.is("let") || p.is("const"):
+		p.next()
+		d, err := p.varDecl()
+		p.endStatement()
+		return d, err
+	case p.accept("if"):
+		return p.ifStatement()
+	case p.accept("for"):
+		return p.forStatement()
+	case p.accept("while"):
+		cond, err := p.parenExpr()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.statement()
+		return &forStmt{cond: cond, body: body}, err
+	case p.accept("do"):
+		body, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("while"); err != nil {
+			return nil, err
+		}
+		cond, err := p.parenExpr()
+		p.endStatement()
+		return &forStmt{cond: cond, body: body, isDoWhile: true}, err
+	case p.accept("switch"):
+		return p.switchStatement()
+	case p.accept("return"):
+		var value expr
+		if !p.is(";") && !p.is("}") && p.peek().kind != tokEOF {
+			var err error
+			if value, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		p.endStatement()
+		return &returnStmt{value}, nil
+	case p.accept("break"):
+		p.endStatement()
+		return &breakStmt{}, nil
+	case p.accept("continue"):
+		p.endStatement()
+		return &contStmt{}, nil
+	case p.is("{"):
+		body, err := p.block()
+		return &blockStmt{body}, err
+	case p.accept(";"):
+		return &blockStmt{}, nil
+	}
+
+	x, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	p.endStatement()
+	return &exprStmt{x}, nil
+}
+
+func (p *parser) block() ([]stmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var body []stmt
+	for !p.accept("}") {
+		if p.peek().kind == tokEOF {
+			return nil, p.errorf("expected %q", "}")
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+	}
+	return body, nil
+}
+
+// function parses a function after its keyword; declarations are named.
+func (p *parser) function(named bool) (*funcDecl, error) {
+	fn := &funcDecl{}
+	if named || p.peek().kind == tokIdent {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		fn.name = name
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.accept(")") {
+		if len(fn.params) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		fn.params = append(fn.params, name)
+	}
+	body, err := p.block()
+	fn.body = body
+	return fn, err
+}
+
+func (p *parser) varDecl() (*varDecl, error) {
+	d := &varDecl{}
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		var init expr
+		if p.accept("=") {
+			if init, err = p.assignment(); err != nil {
+				return nil, err
+			}
+		}
+		d.names = append(d.names, name)
+		d.inits = append(d.inits, init)
+		if !p.accept(",") {
+			return d, nil
+		}
+	}
+}
+
+func (p *parser) parenExpr() (expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	x, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return x, p.expect(")")
+}
+
+func (p *parser) ifStatement() (stmt, error) {
+	cond, err := p.parenExpr()
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{cond: cond, then: then}
+	if p.accept("else") {
+		s.otherwise, err = p.statement()
+	}
+	return s, err
+}
+
+func (p *parser) forStatement() (stmt, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	s := &forStmt{}
+	var err error
+	switch {
+	case p.is("var") || p.is("let") || p.is("const"):
+		p.next()
+		s.init, err = p.varDecl()
+	case !p.is(";"):
+		var x expr
+		x, err = p.expression()
+		s.init = &exprStmt{x}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.is("in") || p.is("of") {
+		return nil, p.errorf("for-in and for-of loops are not supported")
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	if !p.is(";") {
+		if s.cond, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	if !p.is(")") {
+		if s.post, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	s.body, err = p.statement()
+	return s, err
+}
+
+func (p *parser) switchStatement() (stmt, error) {
+	tag, err := p.parenExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	s := &switchStmt{tag: tag}
+	for !p.accept("}") {
+		var c switchCase
+		switch {
+		case p.accept("case"):
+			if c.test, err = p.expression(); err != nil {
+				return nil, err
+			}
+		case p.accept("default"):
+		default:
+			return nil, p.errorf("expected case or default")
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		for !p.is("case") && !p.is("default") && !p.is("}") {
+			if p.peek().kind == tokEOF {
+				return nil, p.errorf("expected %q", "}")
+			}
+			body, err := p.statement()
+			if err != nil {
+				return nil, err
+			}
+			c.body = append(c.body, body)
+		}
+		s.cases = append(s.cases, c)
+	}
+	return s, nil
+}
+
+func (p *parser) expression() (expr, error) { return p.assignment() }
+
+func (p *parser) assignment() (expr, error) {
+	x, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "+=", "-="} {
+		if p.accept(op) {
+			if !assignable(x) {
+				return nil, p.errorf("invalid assignment target")
+			}
+			value, err := p.assignment()
+			return &assign{op: op, target: x, value: value}, err
+		}
+	}
+	return x, nil
+}
+
+func assignable(x expr) bool {
+	switch x.(type) {
+	case *ident, *member:
+		return true
+	}
+	return false
+}
+
+func (p *parser) conditional() (expr, error) {
+	cond, err := p.binaryLevel(0)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.assignment()
+	return &conditional{cond, then, els}, err
+}
+
+// precedence lists the binary operators from the loosest binding.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"===", "!==", "==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binaryLevel(level int) (expr, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	x, err := p.binaryLevel(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || !contains(precedence[level], t.text) {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binaryLevel(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "&&" || t.text == "||" {
+			x = &logical{t.text, x, y}
+		} else {
+			x = &binary{t.text, x, y}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unary() (expr, error) {
+	for _, op := range []string{"!", "-", "+", "typeof"} {
+		if p.accept(op) {
+			x, err := p.unary()
+			return &unary{op, x}, err
+		}
+	}
+	for _, op := range []string{"++", "--"} {
+		if p.accept(op) {
+			x, err := p.unary()
+			if err == nil && !assignable(x) {
+				err = p.errorf("invalid %s operand", op)
+			}
+			return &update{op: op, prefix: true, target: x}, err
+		}
+	}
+
+	x, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"++", "--"} {
+		if p.accept(op) {
+			if !assignable(x) {
+				return nil, p.errorf("invalid %s operand", op)
+			}
+			return &update{op: op, target: x}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.errorf("expected a property name")
+			}
+			x = &member{x: x, name: t.text}
+		case p.accept("["):
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &member{x: x, index: index, computed: true}
+		case p.accept("("):
+			args, err := p.list(")")
+			if err != nil {
+				return nil, err
+			}
+			x = &call{fn: x, args: args}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// list parses comma-separated expressions up to end.
+func (p *parser) list(end string) ([]expr, error) {
+	var xs []expr
+	for !p.accept(end) {
+		if len(xs) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			if p.accept(end) { // trailing comma
+				break
+			}
+		}
+		x, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		return &literal{t.num}, nil
+	case tokString:
+		p.next()
+		return &literal{t.text}, nil
+	case tokRegexp:
+		p.next()
+		return newRegexpLit(t)
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &literal{t.text == "true"}, nil
+		case "null":
+			p.next()
+			return &literal{null}, nil
+		case "undefined":
+			p.next()
+			return &literal{undefined}, nil
+		case "function":
+			p.next()
+			fn, err := p.function(false)
+			return &funcLit{fn}, err
+		case "new":
+			return nil, p.errorf("new is not supported")
+		}
+		name, err := p.identifier()
+		return &ident{name}, err
+	case tokPunct:
+		switch {
+		case p.accept("("):
+			x, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case p.accept("["):
+			elems, err := p.list("]")
+			return &arrayLit{elems}, err
+		}
+	}
+	return nil, p.errorf("unexpected token")
+}
+
+// newRegexpLit compiles a regexp literal. Go's syntax covers what PAC
+// files use, but not backreferences or lookaround.
+func newRegexpLit(t token) (expr, error) {
+	pattern := t.text
+	if strings.Contains(t.flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: unsupported regular expression /%s/: %v", t.line, t.text, err)
+	}
+	return &regexpLit{re}, nil
+}
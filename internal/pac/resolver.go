@@ -0,0 +1,104 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RetryInterval is how long a Resolver that failed to load its PAC file
+// waits before trying again.
+const RetryInterval = 5 * time.Minute
+
+// Resolver picks the proxy of each HTTP request with a PAC file, loaded on
+// first use. Its Proxy method fits http.Transport.Proxy.
+type Resolver struct {
+	location string
+
+	// Fallback picks the proxy while the PAC file cannot be loaded or
+	// evaluated, http.ProxyFromEnvironment by default.
+	Fallback func(*http.Request) (*url.URL, error)
+	// OnError is called with each failure to load or evaluate the PAC
+	// file, if set.
+	OnError func(error)
+
+	mu       sync.Mutex
+	script   *Script
+	failedAt time.Time
+}
+
+// NewResolver returns a resolver using the PAC file at location, a URL or
+// a local path.
+func NewResolver(location string) *Resolver {
+	return &Resolver{location: location, Fallback: http.ProxyFromEnvironment}
+}
+
+// Location returns where the PAC file is loaded from.
+func (r *Resolver) Location() string { return r.location }
+
+// Script returns the loaded PAC file, loading it if needed.
+func (r *Resolver) Script(ctx context.Context) (*Script, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.script != nil {
+		return r.script, nil
+	}
+	if !r.failedAt.IsZero() && time.Since(r.failedAt) < RetryInterval {
+		return nil, errLoadFailed
+	}
+	s, err := Load(ctx, r.location)
+	if err != nil {
+		r.failedAt = time.Now()
+		return nil, err
+	}
+	r.script = s
+	return s, nil
+}
+
+// errLoadFailed reports a PAC file that failed to load recently; the
+// failure itself was already reported.
+var errLoadFailed = errors.New("PAC file failed to load recently")
+
+// Proxy returns the proxy for req: the first entry of the PAC file's
+// result that Go can use, nil for DIRECT.
+func (r *Resolver) Proxy(req *http.Request) (*url.URL, error) {
+	s, err := r.Script(req.Context())
+	if err == nil {
+		var proxy *url.URL
+		proxy, err = r.proxyFor(req, s)
+		if err == nil {
+			return proxy, nil
+		}
+	}
+	if err != errLoadFailed && r.OnError != nil {
+		r.OnError(err)
+	}
+	if r.Fallback == nil {
+		return nil, nil
+	}
+	return r.Fallback(req)
+}
+
+func (r *Resolver) proxyFor(req *http.Request, s *Script) (*url.URL, error) {
+	result, err := s.FindProxyForURL(req.Context(), req.URL.String(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, p := range ParseResult(result) {
+		proxy, err := p.URL()
+		if err == nil {
+			return proxy, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, nil // an empty result means DIRECT
+}